@@ -321,6 +321,9 @@ func testLoadBalancing(client *distributed.DistributedClient) {
 		}
 		fmt.Printf("     %s %s (失败: %d)\n", healthIcon, node, status.FailureCount)
 	}
+	routing := client.GetRoutingStats()
+	fmt.Printf("   🗺️ 直连路由: 拓扑版本 %d，直连 %d 次，转发 %d 次，重定向 %d 次\n",
+		routing.Epoch, routing.Direct, routing.Proxied, routing.Redirects)
 
 	// 清理测试数据
 	for _, key := range testKeys {
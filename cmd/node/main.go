@@ -6,7 +6,9 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"tdd-learning/core"
 	"tdd-learning/distributed"
 
 	"gopkg.in/yaml.v3"
@@ -83,6 +85,10 @@ func validateConfig(config *distributed.NodeConfig) error {
 		return fmt.Errorf("address 不能为空")
 	}
 	
+	// 开启gossip时可以只配置种子节点，本节点地址取自 address
+	if len(config.ClusterNodes) == 0 && config.Gossip {
+		config.ClusterNodes = map[string]string{config.NodeID: config.Address}
+	}
 	if len(config.ClusterNodes) == 0 {
 		return fmt.Errorf("cluster_nodes 不能为空")
 	}
@@ -107,6 +113,122 @@ func validateConfig(config *distributed.NodeConfig) error {
 	if config.VirtualNodes == 0 {
 		config.VirtualNodes = 150
 	}
+
+	if config.MemoryLimit < 0 {
+		return fmt.Errorf("memory_limit 不能为负数")
+	}
+
+	if _, err := core.NewStorage(config.StorageEngine, 1, 0); err != nil {
+		return fmt.Errorf("storage_engine 无效: %v (可选: %s)", err, strings.Join(core.StorageEngineNames, " / "))
+	}
+
+	switch config.AdmissionPolicy {
+	case "", distributed.AdmissionPolicyNone, distributed.AdmissionPolicyTinyLFU:
+	default:
+		return fmt.Errorf("未知的准入策略: %s", config.AdmissionPolicy)
+	}
+
+	for nodeID, weight := range config.NodeWeights {
+		if weight < 0 {
+			return fmt.Errorf("节点 %s 的 weight 不能为负数", nodeID)
+		}
+	}
+
+	if _, err := core.NewHasher(config.HashFunction); err != nil {
+		return fmt.Errorf("hash_function 无效: %v (可选: %s)", err, strings.Join(core.HasherNames, " / "))
+	}
+
+	if config.Placement != "" && config.Placement != core.PlacementRing {
+		if _, err := core.NewPlacement(config.Placement, nil); err != nil {
+			return fmt.Errorf("placement 无效: %v (可选: %s)", err, strings.Join(core.PlacementNames, " / "))
+		}
+		if config.Placement == core.PlacementJump && len(config.NodeWeights) > 0 {
+			return fmt.Errorf("placement jump 不支持节点权重")
+		}
+	}
+
+	if _, err := core.NormalizeReplicaConstraint(config.ReplicaConstraint); err != nil {
+		return fmt.Errorf("replica_constraint 无效: %v", err)
+	}
+
+	if config.BoundedLoadEpsilon < 0 {
+		return fmt.Errorf("bounded_load_epsilon 不能为负数")
+	}
+	if config.BoundedLoadEpsilon > 0 {
+		if _, err := core.NormalizeBoundedLoadMode(config.BoundedLoadMode); err != nil {
+			return fmt.Errorf("bounded_load_mode 无效: %v", err)
+		}
+		if config.Placement != "" && config.Placement != core.PlacementRing {
+			return fmt.Errorf("有界负载仅支持 ring 放置算法")
+		}
+	}
+
+	// 读写仲裁数不能超过副本数（未配置副本数时为1）
+	quorum := distributed.Quorum{N: config.ReplicationFactor, R: config.ReadQuorum, W: config.WriteQuorum}
+	if quorum.N == 0 {
+		quorum.N = 1
+	}
+	if err := quorum.Validate(); err != nil {
+		return fmt.Errorf("replication_factor / read_quorum / write_quorum 无效: %v", err)
+	}
+
+	if config.MaxHints < 0 || config.HintTTL < 0 {
+		return fmt.Errorf("max_hints 和 hint_ttl 不能为负数")
+	}
+	if chance := config.ReadRepairChance; chance != nil && (*chance < 0 || *chance > 1) {
+		return fmt.Errorf("read_repair_chance 必须在0到1之间")
+	}
+	if config.AntiEntropyInterval < 0 {
+		return fmt.Errorf("anti_entropy_interval 不能为负数")
+	}
+
+	if config.PhiThreshold < 0 {
+		return fmt.Errorf("phi_threshold 不能为负数")
+	}
+	if config.HealthCheckInterval < 0 {
+		return fmt.Errorf("health_check_interval 不能为负数")
+	}
+	if config.EvictionGracePeriod < 0 {
+		return fmt.Errorf("eviction_grace_period 不能为负数")
+	}
+	switch config.RaftMode {
+	case "", distributed.RaftModeBootstrap, distributed.RaftModeJoin:
+	default:
+		return fmt.Errorf("未知的raft启动模式: %s", config.RaftMode)
+	}
+	if config.RaftElectionTimeout < 0 || config.RaftHeartbeatInterval < 0 {
+		return fmt.Errorf("raft 时间参数不能为负数")
+	}
+	if config.RaftElectionTimeout > 0 && config.RaftHeartbeatInterval >= config.RaftElectionTimeout {
+		return fmt.Errorf("raft_heartbeat_interval 必须小于 raft_election_timeout")
+	}
+
+	if config.Gossip {
+		switch config.GossipTransport {
+		case "", distributed.GossipTransportHTTP:
+		case distributed.GossipTransportUDP:
+			if config.GossipAddress == "" {
+				return fmt.Errorf("gossip_transport 为 udp 时 gossip_address 不能为空")
+			}
+			if len(config.Seeds) == 0 && len(config.ClusterNodes) > 1 {
+				return fmt.Errorf("gossip_transport 为 udp 时需要配置 seeds（其他节点的gossip地址）")
+			}
+		default:
+			return fmt.Errorf("未知的gossip传输方式: %s", config.GossipTransport)
+		}
+		if config.GossipInterval < 0 || config.GossipProbeTimeout < 0 || config.GossipSuspicionTimeout < 0 || config.GossipSyncInterval < 0 {
+			return fmt.Errorf("gossip 时间参数不能为负数")
+		}
+		if config.GossipIndirectProbes < 0 {
+			return fmt.Errorf("gossip_indirect_probes 不能为负数")
+		}
+	}
+
+	switch config.RESPMode {
+	case "", distributed.RESPModeProxy, distributed.RESPModeRedirect:
+	default:
+		return fmt.Errorf("未知的RESP模式: %s", config.RESPMode)
+	}
 	
 	return nil
 }
\ No newline at end of file
@@ -0,0 +1,199 @@
+// 准入策略 - W-TinyLFU（准入窗口 + TinyLFU + Doorkeeper）
+
+package core
+
+// TinyLFU 准入过滤器
+// 新key离开准入窗口、缓存需要淘汰时，比较它与被淘汰key的访问频率估计值，
+// 新key频率不高于被淘汰key时淘汰新key，防止爬虫、批量扫描等一次性访问冲刷缓存。
+// 不加锁，由 LRUCache 在持有自身锁时调用。
+type TinyLFU struct {
+	sketch     *countMinSketch
+	doorkeeper *bloomFilter
+
+	// 频率衰减：累计记录次数达到 sampleSize 后计数器减半
+	additions  int
+	sampleSize int
+
+	stats AdmissionStats
+}
+
+// AdmissionStats 准入统计
+type AdmissionStats struct {
+	Admitted int64 `json:"admitted"`
+	Rejected int64 `json:"rejected"`
+}
+
+// NewTinyLFU 创建TinyLFU过滤器
+// counters: 频率计数器数量，一般取缓存容量的10倍
+func NewTinyLFU(counters int) *TinyLFU {
+	if counters <= 0 {
+		panic("计数器数量必须大于0")
+	}
+	width := nextPowerOfTwo(counters)
+	return &TinyLFU{
+		sketch:     newCountMinSketch(width),
+		doorkeeper: newBloomFilter(width),
+		sampleSize: width * 10,
+	}
+}
+
+// Record 记录一次访问（命中、未命中和写入都应记录）
+func (t *TinyLFU) Record(key string) {
+	hash := fnv64a(key)
+
+	// 第一次出现的key只进入doorkeeper，不占用计数器
+	if t.doorkeeper.addIfAbsent(hash) {
+		t.sketch.increment(hash)
+	}
+
+	t.additions++
+	if t.additions >= t.sampleSize {
+		t.reset()
+	}
+}
+
+// Estimate 估计key的访问频率
+func (t *TinyLFU) Estimate(key string) int {
+	hash := fnv64a(key)
+	freq := t.sketch.estimate(hash)
+	if t.doorkeeper.contains(hash) {
+		freq++
+	}
+	return freq
+}
+
+// admissionWindowDivisor 准入窗口占总容量的比例（1/100）
+const admissionWindowDivisor = 100
+
+// admissionWindowSize 准入窗口容量，至少为1
+func admissionWindowSize(capacity int) int {
+	if n := capacity / admissionWindowDivisor; n > 0 {
+		return n
+	}
+	return 1
+}
+
+// Admit 判断候选key能否替换被淘汰的key，并更新统计
+func (t *TinyLFU) Admit(candidate, victim string) bool {
+	if t.Estimate(candidate) > t.Estimate(victim) {
+		t.stats.Admitted++
+		return true
+	}
+	t.stats.Rejected++
+	return false
+}
+
+// Stats 获取准入统计
+func (t *TinyLFU) Stats() AdmissionStats {
+	return t.stats
+}
+
+// reset 计数器减半并清空doorkeeper，使频率随时间衰减
+func (t *TinyLFU) reset() {
+	t.additions = 0
+	t.sketch.halve()
+	t.doorkeeper.clear()
+}
+
+// ===== Count-Min Sketch（4位计数器） =====
+
+const sketchDepth = 4
+
+type countMinSketch struct {
+	rows [sketchDepth][]byte // 每个字节存放两个4位计数器
+	mask uint64
+}
+
+func newCountMinSketch(width int) *countMinSketch {
+	s := &countMinSketch{mask: uint64(width - 1)}
+	for i := range s.rows {
+		s.rows[i] = make([]byte, width/2+1)
+	}
+	return s
+}
+
+// indexOf 双重哈希计算第i行的计数器位置
+func (s *countMinSketch) indexOf(hash uint64, row int) uint64 {
+	h1, h2 := hash, hash>>32|hash<<32
+	return (h1 + uint64(row)*h2) & s.mask
+}
+
+func (s *countMinSketch) increment(hash uint64) {
+	for row := range s.rows {
+		idx := s.indexOf(hash, row)
+		shift := (idx & 1) * 4
+		if (s.rows[row][idx/2]>>shift)&0x0f < 15 {
+			s.rows[row][idx/2] += 1 << shift
+		}
+	}
+}
+
+func (s *countMinSketch) estimate(hash uint64) int {
+	min := 15
+	for row := range s.rows {
+		idx := s.indexOf(hash, row)
+		count := int(s.rows[row][idx/2]>>((idx&1)*4)) & 0x0f
+		if count < min {
+			min = count
+		}
+	}
+	return min
+}
+
+func (s *countMinSketch) halve() {
+	for _, row := range s.rows {
+		for i := range row {
+			row[i] = (row[i] >> 1) & 0x77
+		}
+	}
+}
+
+// ===== Doorkeeper 布隆过滤器 =====
+
+type bloomFilter struct {
+	bits []uint64
+	mask uint64
+}
+
+func newBloomFilter(size int) *bloomFilter {
+	return &bloomFilter{
+		bits: make([]uint64, (size+63)/64),
+		mask: uint64(size - 1),
+	}
+}
+
+// positions 由一个64位哈希派生两个比特位置
+func (b *bloomFilter) positions(hash uint64) (uint64, uint64) {
+	return hash & b.mask, (hash >> 32) & b.mask
+}
+
+func (b *bloomFilter) contains(hash uint64) bool {
+	p1, p2 := b.positions(hash)
+	return b.bits[p1/64]&(1<<(p1%64)) != 0 && b.bits[p2/64]&(1<<(p2%64)) != 0
+}
+
+// addIfAbsent 添加哈希，返回添加前是否已存在
+func (b *bloomFilter) addIfAbsent(hash uint64) bool {
+	if b.contains(hash) {
+		return true
+	}
+	p1, p2 := b.positions(hash)
+	b.bits[p1/64] |= 1 << (p1 % 64)
+	b.bits[p2/64] |= 1 << (p2 % 64)
+	return false
+}
+
+func (b *bloomFilter) clear() {
+	for i := range b.bits {
+		b.bits[i] = 0
+	}
+}
+
+// nextPowerOfTwo 向上取整到2的幂
+func nextPowerOfTwo(n int) int {
+	power := 1
+	for power < n {
+		power <<= 1
+	}
+	return power
+}
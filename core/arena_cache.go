@@ -0,0 +1,1103 @@
+// 基于arena的LRU存储引擎 - GC友好
+
+package core
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// ArenaLRUCache 基于字节数组arena的LRU缓存
+// 与 LRUCache 提供相同的API和淘汰语义（同样实现 Storage 接口），但所有键值都序列化在一块大的 []byte 中，
+// 索引为 map[uint64]uint32，链表用槽位下标代替指针，哈希相同的条目通过槽位的 chain 串成链。
+// 条目的元数据、TTL、版本号和环哈希都以标量保存在槽位中，GC 无需扫描百万级对象；
+// 只有数量很少的删除标记仍保存在 map 中。
+type ArenaLRUCache struct {
+	capacity int
+	size     int
+
+	index     map[uint64]uint32       // key哈希 -> 链首槽位下标
+	hash      func(key string) uint64 // key哈希函数，默认FNV-1a
+	entries   []arenaEntry            // 槽位表，下标0为主缓存哨兵，下标1为准入窗口哨兵
+	freeSlots []uint32                // 可复用的槽位
+
+	arena   []byte // 键值数据区：key字节紧跟value字节
+	garbage int    // arena中已失效的字节数，超过一半时触发压缩
+
+	mu sync.RWMutex
+
+	// 异步清理
+	cleanupInterval time.Duration
+	stopCleanup     chan struct{}
+	cleanupStats    CleanupStats
+	// 统计
+	stats CacheStats
+
+	// 内存限制
+	memoryUsage int64
+	memoryLimit int64
+
+	// 准入策略（nil表示不启用），新key先进入准入窗口，与 LRUCache 相同
+	admission  *TinyLFU
+	windowSize int
+
+	// 最近分配的版本号
+	lastVersion uint64
+
+	// 环哈希函数（nil表示不启用），写入时把环哈希保存在槽位中，按哈希区间取数据时扫描槽位表
+	ringHash func(key string) uint32
+
+	// 多副本删除标记：key -> 删除时的版本号
+	tombstones     map[string]uint64
+	tombstonePurge time.Time
+}
+
+// arenaEntry 槽位，只包含标量字段
+type arenaEntry struct {
+	hash        uint64
+	offset      uint32 // 在arena中的起始位置
+	keyLen      uint32
+	valueLen    uint32
+	prev        uint32 // 前一个槽位（更近使用）
+	next        uint32 // 后一个槽位（更久未使用）
+	chain       uint32 // 哈希相同的下一个槽位（0表示链尾）
+	expireAt    int64  // 过期时间（UnixNano），0表示永不过期
+	createdAt   int64  // 首次写入时间（UnixNano）
+	lastAccess  int64  // 最后访问时间（UnixNano）
+	accessCount int64  // 读命中次数
+	version     uint64 // 每次写入都会变化的版本号
+	flags       uint32 // 客户端自定义标志
+	ringHash    uint32 // 环哈希，启用后写入时计算
+	inWindow    bool   // 是否位于准入窗口
+}
+
+// arenaWindowSlot 准入窗口链表的哨兵槽位（主缓存链表的哨兵为0）
+const arenaWindowSlot uint32 = 1
+
+// arena 压缩阈值：失效字节少于该值时不压缩，避免小缓存频繁拷贝
+const arenaCompactMinGarbage = 1 << 20
+
+// arenaMaxBytes arena的最大字节数，槽位中的偏移和长度为uint32
+const arenaMaxBytes = math.MaxUint32
+
+// NewArenaLRUCache 创建基于arena的LRU缓存
+func NewArenaLRUCache(capacity int) *ArenaLRUCache {
+	if capacity <= 0 {
+		panic("容量必须大于0")
+	}
+
+	c := &ArenaLRUCache{
+		capacity: capacity,
+		index:    make(map[uint64]uint32, capacity),
+		hash:     fnv64a,
+		entries:  make([]arenaEntry, 2, capacity+2),
+	}
+	// 两个哨兵槽位各自成环
+	c.entries[arenaWindowSlot].prev = arenaWindowSlot
+	c.entries[arenaWindowSlot].next = arenaWindowSlot
+
+	return c
+}
+
+// 带内存限制的构造函数
+func NewArenaLRUCacheWithMemoryLimit(capacity int, memoryLimitBytes int64) *ArenaLRUCache {
+	c := NewArenaLRUCache(capacity)
+	c.memoryLimit = memoryLimitBytes
+	return c
+}
+
+// NewArenaLRUCacheWithHasher 使用指定的64位key哈希函数创建缓存
+func NewArenaLRUCacheWithHasher(capacity int, hash func(key string) uint64) *ArenaLRUCache {
+	c := NewArenaLRUCache(capacity)
+	c.hash = hash
+	return c
+}
+
+// 带TTL清理的构造函数
+func NewArenaLRUCacheWithCleanup(capacity int, cleanupInterval time.Duration) *ArenaLRUCache {
+	c := NewArenaLRUCache(capacity)
+	c.cleanupInterval = cleanupInterval
+	c.stopCleanup = make(chan struct{})
+	go c.startCleanupRoutine()
+	return c
+}
+
+// EnableAdmission 启用TinyLFU准入策略
+// counters <= 0 时按容量的10倍分配计数器
+func (c *ArenaLRUCache) EnableAdmission(counters int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if counters <= 0 {
+		counters = c.capacity * 10
+	}
+	c.admission = NewTinyLFU(counters)
+}
+
+// GetAdmissionStats 获取准入统计，未启用准入策略时第二个返回值为false
+func (c *ArenaLRUCache) GetAdmissionStats() (AdmissionStats, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.admission == nil {
+		return AdmissionStats{}, false
+	}
+	return c.admission.Stats(), true
+}
+
+// 后台清理例程
+func (c *ArenaLRUCache) startCleanupRoutine() {
+	ticker := time.NewTicker(c.cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.cleanupExpiredKeys()
+		case <-c.stopCleanup:
+			return
+		}
+	}
+}
+
+// 清理过期键
+func (c *ArenaLRUCache) cleanupExpiredKeys() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	nowNano := now.UnixNano()
+
+	// 删除会修改链表，先收集再删除
+	var expired []uint32
+	c.forEachSlot(func(slot uint32) {
+		if c.isExpired(slot, nowNano) {
+			expired = append(expired, slot)
+		}
+	})
+	for _, slot := range expired {
+		c.removeSlot(slot)
+	}
+	c.maybeCompact()
+
+	c.cleanupStats.CleanedKeys += int64(len(expired))
+	c.cleanupStats.CleanupRuns++
+	c.cleanupStats.LastCleanup = now
+}
+
+// Close 停止后台清理
+func (c *ArenaLRUCache) Close() {
+	if c.stopCleanup != nil {
+		close(c.stopCleanup)
+	}
+}
+
+func (c *ArenaLRUCache) GetCleanupStats() CleanupStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cleanupStats
+}
+
+// ===== 槽位与链表操作（调用方持有锁） =====
+
+// fnv64a FNV-1a 64位哈希，内联实现避免分配
+func fnv64a(key string) uint64 {
+	hash := uint64(14695981039346656037)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint64(key[i])
+		hash *= 1099511628211
+	}
+	return hash
+}
+
+// keyEquals 比较槽位中保存的key是否与给定key相同
+func (c *ArenaLRUCache) keyEquals(slot uint32, key string) bool {
+	e := &c.entries[slot]
+	if int(e.keyLen) != len(key) {
+		return false
+	}
+	return string(c.arena[e.offset:e.offset+e.keyLen]) == key
+}
+
+func (c *ArenaLRUCache) keyAt(slot uint32) string {
+	e := &c.entries[slot]
+	return string(c.arena[e.offset : e.offset+e.keyLen])
+}
+
+func (c *ArenaLRUCache) valueAt(slot uint32) string {
+	e := &c.entries[slot]
+	start := e.offset + e.keyLen
+	return string(c.arena[start : start+e.valueLen])
+}
+
+// sizeAt 槽位的估算内存占用，与 calculateMemoryUsage 一致
+func (c *ArenaLRUCache) sizeAt(slot uint32) int64 {
+	e := &c.entries[slot]
+	return int64(e.keyLen) + int64(e.valueLen) + 64
+}
+
+// lookup 查找key对应的槽位
+func (c *ArenaLRUCache) lookup(key string) (uint32, bool) {
+	return c.find(c.hash(key), key)
+}
+
+// find 沿哈希链查找key对应的槽位
+func (c *ArenaLRUCache) find(hash uint64, key string) (uint32, bool) {
+	for slot := c.index[hash]; slot != 0; slot = c.entries[slot].chain {
+		if c.keyEquals(slot, key) {
+			return slot, true
+		}
+	}
+	return 0, false
+}
+
+// unindex 把槽位从哈希链中摘除
+func (c *ArenaLRUCache) unindex(slot uint32) {
+	e := &c.entries[slot]
+	head := c.index[e.hash]
+	if head == slot {
+		if e.chain == 0 {
+			delete(c.index, e.hash)
+		} else {
+			c.index[e.hash] = e.chain
+		}
+		return
+	}
+	for prev := head; prev != 0; prev = c.entries[prev].chain {
+		if c.entries[prev].chain == slot {
+			c.entries[prev].chain = e.chain
+			return
+		}
+	}
+}
+
+// linkAfter 将槽位插入到哨兵之后（最近使用）
+func (c *ArenaLRUCache) linkAfter(sentinel, slot uint32) {
+	first := c.entries[sentinel].next
+	c.entries[slot].prev = sentinel
+	c.entries[slot].next = first
+	c.entries[first].prev = slot
+	c.entries[sentinel].next = slot
+}
+
+// linkToHead 将槽位插入到主缓存链表头部
+func (c *ArenaLRUCache) linkToHead(slot uint32) {
+	c.linkAfter(0, slot)
+}
+
+// linkToWindow 将槽位插入到准入窗口链表头部
+func (c *ArenaLRUCache) linkToWindow(slot uint32) {
+	c.entries[slot].inWindow = true
+	c.linkAfter(arenaWindowSlot, slot)
+	c.windowSize++
+}
+
+// unlink 从链表中摘除槽位
+func (c *ArenaLRUCache) unlink(slot uint32) {
+	prev, next := c.entries[slot].prev, c.entries[slot].next
+	c.entries[prev].next = next
+	c.entries[next].prev = prev
+	if c.entries[slot].inWindow {
+		c.windowSize--
+	}
+}
+
+// moveToHead 将槽位移动到所在链表（主缓存或准入窗口）的头部
+func (c *ArenaLRUCache) moveToHead(slot uint32) {
+	c.unlink(slot)
+	if c.entries[slot].inWindow {
+		c.linkToWindow(slot)
+	} else {
+		c.linkToHead(slot)
+	}
+}
+
+// forEachSlot 依次遍历主缓存和准入窗口中的槽位（从最近使用到最久未使用），fn 中不能增删槽位
+func (c *ArenaLRUCache) forEachSlot(fn func(slot uint32)) {
+	for _, sentinel := range [...]uint32{0, arenaWindowSlot} {
+		for slot := c.entries[sentinel].next; slot != sentinel; slot = c.entries[slot].next {
+			fn(slot)
+		}
+	}
+}
+
+// removeSlot 删除槽位：摘链、删索引、回收槽位并累计失效字节
+func (c *ArenaLRUCache) removeSlot(slot uint32) {
+	c.unindex(slot)
+	c.unlink(slot)
+	e := c.entries[slot]
+	c.memoryUsage -= int64(e.keyLen) + int64(e.valueLen) + 64
+	c.garbage += int(e.keyLen + e.valueLen)
+	c.entries[slot] = arenaEntry{}
+	c.freeSlots = append(c.freeSlots, slot)
+	c.size--
+}
+
+// allocSlot 分配一个空闲槽位
+func (c *ArenaLRUCache) allocSlot() uint32 {
+	if n := len(c.freeSlots); n > 0 {
+		slot := c.freeSlots[n-1]
+		c.freeSlots = c.freeSlots[:n-1]
+		return slot
+	}
+	c.entries = append(c.entries, arenaEntry{})
+	return uint32(len(c.entries) - 1)
+}
+
+// appendData 把key和value写入arena，返回起始偏移
+// 超出 arenaMaxBytes 时先压缩，压缩后仍放不下则返回false，由调用方拒绝写入
+func (c *ArenaLRUCache) appendData(key, value string) (uint32, bool) {
+	need := len(key) + len(value)
+	if len(c.arena)+need > arenaMaxBytes {
+		c.compact()
+		if len(c.arena)+need > arenaMaxBytes {
+			return 0, false
+		}
+	}
+	offset := uint32(len(c.arena))
+	c.arena = append(c.arena, key...)
+	c.arena = append(c.arena, value...)
+	return offset, true
+}
+
+// maybeCompact 失效字节超过arena一半时压缩
+func (c *ArenaLRUCache) maybeCompact() {
+	if c.garbage >= arenaCompactMinGarbage && c.garbage*2 >= len(c.arena) {
+		c.compact()
+	}
+}
+
+// compact 按链表顺序把存活数据拷贝到新的arena
+func (c *ArenaLRUCache) compact() {
+	newArena := make([]byte, 0, len(c.arena)-c.garbage)
+	c.forEachSlot(func(slot uint32) {
+		e := &c.entries[slot]
+		end := e.offset + e.keyLen + e.valueLen
+		newOffset := uint32(len(newArena))
+		newArena = append(newArena, c.arena[e.offset:end]...)
+		e.offset = newOffset
+	})
+	c.arena = newArena
+	c.garbage = 0
+}
+
+// isExpired 检查槽位是否过期
+func (c *ArenaLRUCache) isExpired(slot uint32, now int64) bool {
+	expireAt := c.entries[slot].expireAt
+	return expireAt != 0 && now > expireAt
+}
+
+// liveSlot 获取未过期的槽位，过期槽位会被顺带删除
+func (c *ArenaLRUCache) liveSlot(key string) (uint32, bool) {
+	slot, exists := c.lookup(key)
+	if !exists {
+		return 0, false
+	}
+	if c.isExpired(slot, time.Now().UnixNano()) {
+		c.removeSlot(slot)
+		return 0, false
+	}
+	return slot, true
+}
+
+// touch 记录一次读命中
+func (c *ArenaLRUCache) touch(slot uint32) {
+	e := &c.entries[slot]
+	e.lastAccess = time.Now().UnixNano()
+	e.accessCount++
+}
+
+// setTTL 设置槽位的过期时间，ttl <= 0 表示移除过期时间
+func (c *ArenaLRUCache) setTTL(slot uint32, ttl time.Duration) {
+	if ttl <= 0 {
+		c.entries[slot].expireAt = 0
+		return
+	}
+	c.entries[slot].expireAt = time.Now().Add(ttl).UnixNano()
+}
+
+// nextVersion 生成单调递增的版本号（纳秒时间戳，同一纳秒内递增）
+func (c *ArenaLRUCache) nextVersion() uint64 {
+	version := uint64(time.Now().UnixNano())
+	if version <= c.lastVersion {
+		version = c.lastVersion + 1
+	}
+	c.lastVersion = version
+	return version
+}
+
+// needsEviction 写入新条目前是否需要淘汰
+func (c *ArenaLRUCache) needsEviction(newMemory int64) bool {
+	if c.size == 0 {
+		return false
+	}
+	return c.size >= c.capacity ||
+		(c.memoryLimit > 0 && c.memoryUsage+newMemory > c.memoryLimit)
+}
+
+// overLimits 当前是否超出容量或内存限制
+func (c *ArenaLRUCache) overLimits() bool {
+	if c.size == 0 {
+		return false
+	}
+	return c.size > c.capacity ||
+		(c.memoryLimit > 0 && c.memoryUsage > c.memoryLimit)
+}
+
+// evictOldest 淘汰最久未使用的条目（主缓存为空时取准入窗口的尾部）
+func (c *ArenaLRUCache) evictOldest() {
+	slot := c.entries[0].prev
+	if slot == 0 {
+		slot = c.entries[arenaWindowSlot].prev
+	}
+	c.removeSlot(slot)
+}
+
+// ===== 公共API =====
+
+func (c *ArenaLRUCache) SetWithTTL(key, value string, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if slot := c.setInternal(key, value); slot != 0 {
+		c.setTTL(slot, ttl)
+	}
+}
+
+func (c *ArenaLRUCache) Set(key, value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setInternal(key, value)
+}
+
+// TrySet 写入键值，因内存限制或arena已满被拒绝时返回false
+func (c *ArenaLRUCache) TrySet(key, value string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.setInternal(key, value) != 0
+}
+
+// SetBypassAdmission 写入键值，新key跳过准入窗口直接进入主缓存，被拒绝时返回false
+func (c *ArenaLRUCache) SetBypassAdmission(key, value string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.writeSlot(key, value, false) != 0
+}
+
+// setInternal 写入键值，返回槽位（0表示因内存限制或arena已满被拒绝）
+// 启用准入策略时新key先进入准入窗口
+func (c *ArenaLRUCache) setInternal(key, value string) uint32 {
+	return c.writeSlot(key, value, c.admission != nil)
+}
+
+// writeSlot 写入键值并返回槽位，useWindow 为true时新key进入准入窗口，否则直接进入主缓存
+func (c *ArenaLRUCache) writeSlot(key, value string, useWindow bool) uint32 {
+	newMemory := calculateMemoryUsage(key, value)
+	if c.memoryLimit > 0 && newMemory > c.memoryLimit {
+		return 0
+	}
+	if c.admission != nil {
+		c.admission.Record(key)
+	}
+	now := time.Now().UnixNano()
+
+	hash := c.hash(key)
+	if slot, exists := c.find(hash, key); exists {
+		// 更新：旧数据成为垃圾，新数据追加到arena
+		oldBytes := int(c.entries[slot].keyLen + c.entries[slot].valueLen)
+		offset, ok := c.appendData(key, value)
+		if !ok {
+			return 0
+		}
+		e := &c.entries[slot]
+		c.memoryUsage = c.memoryUsage - int64(oldBytes+64) + newMemory
+		c.garbage += oldBytes
+		e.offset = offset
+		e.keyLen = uint32(len(key))
+		e.valueLen = uint32(len(value))
+		e.lastAccess = now
+		e.version = c.nextVersion()
+		c.moveToHead(slot)
+		c.maybeCompact()
+		return slot
+	}
+
+	if !useWindow {
+		// 检查内存限制和容量限制，从尾部淘汰
+		for c.needsEviction(newMemory) {
+			c.evictOldest()
+		}
+	}
+
+	offset, ok := c.appendData(key, value)
+	if !ok {
+		return 0
+	}
+	slot := c.allocSlot()
+	c.entries[slot] = arenaEntry{
+		hash:       hash,
+		offset:     offset,
+		keyLen:     uint32(len(key)),
+		valueLen:   uint32(len(value)),
+		chain:      c.index[hash],
+		createdAt:  now,
+		lastAccess: now,
+		version:    c.nextVersion(),
+	}
+	if c.ringHash != nil {
+		c.entries[slot].ringHash = c.ringHash(key)
+	}
+	if useWindow {
+		c.linkToWindow(slot)
+	} else {
+		c.linkToHead(slot)
+	}
+	c.index[hash] = slot
+	c.memoryUsage += newMemory
+	c.size++
+	if useWindow {
+		c.admitFromWindow()
+	}
+	c.maybeCompact()
+	return slot
+}
+
+// admitFromWindow 准入窗口超出容量时，把窗口中最久未使用的条目移入主缓存
+// 缓存已满时由准入策略比较它与主缓存最久未使用条目的频率，频率不高于对方时淘汰它自己
+func (c *ArenaLRUCache) admitFromWindow() {
+	for c.windowSize > admissionWindowSize(c.capacity) {
+		candidate := c.entries[arenaWindowSlot].prev
+		if victim := c.entries[0].prev; victim != 0 && c.overLimits() &&
+			!c.admission.Admit(c.keyAt(candidate), c.keyAt(victim)) {
+			c.removeSlot(candidate)
+			continue
+		}
+		c.unlink(candidate)
+		c.entries[candidate].inWindow = false
+		c.linkToHead(candidate)
+	}
+	for c.overLimits() {
+		c.evictOldest()
+	}
+}
+
+func (c *ArenaLRUCache) Get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stats.TotalRequests++
+	if c.admission != nil {
+		c.admission.Record(key)
+	}
+
+	slot, exists := c.liveSlot(key)
+	if !exists {
+		c.stats.Misses++
+		return "", false
+	}
+	c.stats.Hits++
+	c.touch(slot)
+	c.moveToHead(slot)
+	return c.valueAt(slot), true
+}
+
+func (c *ArenaLRUCache) Delete(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	slot, exists := c.lookup(key)
+	if !exists {
+		return false
+	}
+	c.removeSlot(slot)
+	c.maybeCompact()
+	return true
+}
+
+func (c *ArenaLRUCache) Size() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.size
+}
+
+func (c *ArenaLRUCache) GetStats() CacheStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.stats
+}
+
+func (c *ArenaLRUCache) GetMemoryUsage() int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.memoryUsage
+}
+
+// ArenaBytes 返回arena当前占用的字节数（包含未压缩的失效数据）
+func (c *ArenaLRUCache) ArenaBytes() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.arena)
+}
+
+// 批量操作
+func (c *ArenaLRUCache) SetMulti(data map[string]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, value := range data {
+		c.setInternal(key, value)
+	}
+}
+
+func (c *ArenaLRUCache) GetMulti(keys []string) map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	results := make(map[string]string)
+
+	for _, key := range keys {
+		c.stats.TotalRequests++
+		if c.admission != nil {
+			c.admission.Record(key)
+		}
+
+		slot, exists := c.liveSlot(key)
+		if !exists {
+			c.stats.Misses++
+			continue
+		}
+		c.stats.Hits++
+		c.touch(slot)
+		c.moveToHead(slot)
+		results[key] = c.valueAt(slot)
+	}
+	return results
+}
+
+func (c *ArenaLRUCache) DeleteMulti(keys []string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	deletedCount := 0
+	for _, key := range keys {
+		if slot, exists := c.lookup(key); exists {
+			c.removeSlot(slot)
+			deletedCount++
+		}
+	}
+	c.maybeCompact()
+	return deletedCount
+}
+
+// GetAllData 获取缓存中的所有数据 - 用于数据迁移
+func (c *ArenaLRUCache) GetAllData() map[string]string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	result := make(map[string]string, c.size)
+	now := time.Now().UnixNano()
+
+	c.forEachSlot(func(slot uint32) {
+		if !c.isExpired(slot, now) {
+			result[c.keyAt(slot)] = c.valueAt(slot)
+		}
+	})
+	return result
+}
+
+// ===== 运行时调整容量与内存限制 =====
+
+// Capacity 获取当前容量
+func (c *ArenaLRUCache) Capacity() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.capacity
+}
+
+// GetMemoryLimit 获取当前内存限制（0表示无限制）
+func (c *ArenaLRUCache) GetMemoryLimit() int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.memoryLimit
+}
+
+// Resize 运行时调整容量，缩容时分批淘汰最久未使用的条目
+// 返回被淘汰的条目数
+func (c *ArenaLRUCache) Resize(capacity int) (int, error) {
+	if capacity <= 0 {
+		return 0, fmt.Errorf("容量必须大于0: %d", capacity)
+	}
+
+	c.mu.Lock()
+	c.capacity = capacity
+	c.mu.Unlock()
+
+	return c.evictToLimits(), nil
+}
+
+// SetMemoryLimit 运行时调整内存限制（0表示无限制），缩小时分批淘汰
+// 返回被淘汰的条目数
+func (c *ArenaLRUCache) SetMemoryLimit(memoryLimitBytes int64) (int, error) {
+	if memoryLimitBytes < 0 {
+		return 0, fmt.Errorf("内存限制不能为负数: %d", memoryLimitBytes)
+	}
+
+	c.mu.Lock()
+	c.memoryLimit = memoryLimitBytes
+	c.mu.Unlock()
+
+	return c.evictToLimits(), nil
+}
+
+// evictToLimits 分批淘汰，直到容量和内存都满足限制
+func (c *ArenaLRUCache) evictToLimits() int {
+	evicted := 0
+	for {
+		c.mu.Lock()
+		batch := 0
+		for batch < resizeEvictBatch && c.overLimits() {
+			c.evictOldest()
+			batch++
+		}
+		done := !c.overLimits()
+		if done {
+			c.maybeCompact()
+		}
+		c.mu.Unlock()
+
+		evicted += batch
+		if done {
+			return evicted
+		}
+	}
+}
+
+// ===== 按哈希区间迁移 =====
+
+// EnableHashIndex 启用环哈希，已有的key会被立即计算
+// arena引擎不维护按桶分组的key集合（会引入大量指针），按区间取数据时扫描槽位表
+func (c *ArenaLRUCache) EnableHashIndex(hash func(key string) uint32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ringHash = hash
+	c.forEachSlot(func(slot uint32) {
+		c.entries[slot].ringHash = hash(c.keyAt(slot))
+	})
+}
+
+// GetDataInHashRange 获取环哈希在 (start, end] 内的未过期数据，start >= end 时区间跨过0点
+// 需要先启用环哈希，否则返回nil
+func (c *ArenaLRUCache) GetDataInHashRange(start, end uint32) map[string]string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.ringHash == nil {
+		return nil
+	}
+
+	result := make(map[string]string)
+	now := time.Now().UnixNano()
+	c.forEachSlot(func(slot uint32) {
+		if !c.isExpired(slot, now) && hashInRange(c.entries[slot].ringHash, start, end) {
+			result[c.keyAt(slot)] = c.valueAt(slot)
+		}
+	})
+	return result
+}
+
+// VersionedEntriesInHashRanges 取出多个互不相交的哈希区间内的未过期条目和删除标记，结果与 ranges 一一对应
+// 只扫描一遍槽位表，每个槽位二分查找所在的区间；需要先启用环哈希，否则返回nil
+func (c *ArenaLRUCache) VersionedEntriesInHashRanges(ranges []HashInterval) []map[string]VersionedEntry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.ringHash == nil {
+		return nil
+	}
+
+	result := make([]map[string]VersionedEntry, len(ranges))
+	for i := range result {
+		result[i] = make(map[string]VersionedEntry)
+	}
+	locator := newIntervalLocator(ranges)
+	now := time.Now()
+	nowNano := now.UnixNano()
+	c.forEachSlot(func(slot uint32) {
+		e := &c.entries[slot]
+		if c.isExpired(slot, nowNano) {
+			return
+		}
+		if i := locator.locate(e.ringHash); i >= 0 {
+			result[i][c.keyAt(slot)] = VersionedEntry{Value: c.valueAt(slot), Version: e.version, Flags: e.flags, ExpiresAt: e.expireAt}
+		}
+	})
+	for key, version := range c.tombstones {
+		if tombstoneExpired(version, now) {
+			continue
+		}
+		if i := locator.locate(c.ringHash(key)); i >= 0 {
+			result[i][key] = VersionedEntry{Version: version, Tombstone: true}
+		}
+	}
+	return result
+}
+
+// ===== 单key元数据 =====
+
+// metadataOf 构造槽位元数据
+func (c *ArenaLRUCache) metadataOf(slot uint32) KeyMetadata {
+	e := &c.entries[slot]
+	meta := KeyMetadata{
+		Key:         c.keyAt(slot),
+		Size:        c.sizeAt(slot),
+		CreatedAt:   time.Unix(0, e.createdAt),
+		LastAccess:  time.Unix(0, e.lastAccess),
+		AccessCount: e.accessCount,
+	}
+	if e.expireAt != 0 {
+		expireTime := time.Unix(0, e.expireAt)
+		meta.ExpiresAt = &expireTime
+	}
+	return meta
+}
+
+// Inspect 获取key的元数据，不影响LRU顺序和访问统计
+func (c *ArenaLRUCache) Inspect(key string) (KeyMetadata, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	slot, exists := c.lookup(key)
+	if !exists || c.isExpired(slot, time.Now().UnixNano()) {
+		return KeyMetadata{}, false
+	}
+	return c.metadataOf(slot), true
+}
+
+// LargestKeys 按内存占用返回最大的n个key的元数据（从大到小）
+func (c *ArenaLRUCache) LargestKeys(n int) []KeyMetadata {
+	if n <= 0 {
+		return []KeyMetadata{}
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	// 维护大小为n的最小堆，遍历一次即可得到TopN
+	top := make(slotSizeHeap, 0, n)
+	c.forEachSlot(func(slot uint32) {
+		size := c.sizeAt(slot)
+		if len(top) < n {
+			heap.Push(&top, slotSize{slot: slot, size: size})
+		} else if size > top[0].size {
+			top[0] = slotSize{slot: slot, size: size}
+			heap.Fix(&top, 0)
+		}
+	})
+
+	result := make([]KeyMetadata, len(top))
+	for i := len(top) - 1; i >= 0; i-- {
+		result[i] = c.metadataOf(heap.Pop(&top).(slotSize).slot)
+	}
+	return result
+}
+
+// slotSize / slotSizeHeap 按大小排序的槽位最小堆
+type slotSize struct {
+	slot uint32
+	size int64
+}
+
+type slotSizeHeap []slotSize
+
+func (h slotSizeHeap) Len() int            { return len(h) }
+func (h slotSizeHeap) Less(i, j int) bool  { return h[i].size < h[j].size }
+func (h slotSizeHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *slotSizeHeap) Push(x interface{}) { *h = append(*h, x.(slotSize)) }
+func (h *slotSizeHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// ===== 原子读改写 =====
+
+// entryAt 槽位的条目
+func (c *ArenaLRUCache) entryAt(slot uint32) Entry {
+	e := &c.entries[slot]
+	return Entry{Value: c.valueAt(slot), Flags: e.flags, Version: e.version}
+}
+
+// GetEntry 获取条目（计入访问统计并更新LRU顺序）
+func (c *ArenaLRUCache) GetEntry(key string) (Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stats.TotalRequests++
+	if c.admission != nil {
+		c.admission.Record(key)
+	}
+
+	slot, found := c.liveSlot(key)
+	if !found {
+		c.stats.Misses++
+		return Entry{}, false
+	}
+	c.stats.Hits++
+	c.touch(slot)
+	c.moveToHead(slot)
+	return c.entryAt(slot), true
+}
+
+// Update 在写锁内执行读-改-写，保证条件操作的原子性
+// 返回操作后的条目以及是否发生写入
+func (c *ArenaLRUCache) Update(key string, fn UpdateFunc) (Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var current Entry
+	slot, found := c.liveSlot(key)
+	if found {
+		current = c.entryAt(slot)
+	}
+
+	next, ttl, write := fn(current, found)
+	if !write {
+		return current, false
+	}
+	if ttl == DeleteTTL {
+		tombstone := VersionedEntry{Version: c.nextVersion(), Tombstone: true}
+		c.setVersioned(key, tombstone, false)
+		return Entry{Version: tombstone.Version}, true
+	}
+
+	slot = c.setInternal(key, next.Value)
+	if slot == 0 {
+		return current, false
+	}
+	c.entries[slot].flags = next.Flags
+	if ttl != KeepTTL {
+		c.setTTL(slot, ttl)
+	}
+	return c.entryAt(slot), true
+}
+
+// Expire 修改已存在key的过期时间，ttl <= 0 表示永不过期
+func (c *ArenaLRUCache) Expire(key string, ttl time.Duration) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	slot, found := c.liveSlot(key)
+	if !found {
+		return false
+	}
+	c.setTTL(slot, ttl)
+	return true
+}
+
+// ===== 多副本版本与删除标记 =====
+
+// NewVersion 分配一个比本缓存已有版本都大的版本号（纳秒时间戳）
+func (c *ArenaLRUCache) NewVersion() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.nextVersion()
+}
+
+// GetVersioned 获取条目及其版本号，key已被删除且删除标记未过期时返回删除标记
+func (c *ArenaLRUCache) GetVersioned(key string) (VersionedEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stats.TotalRequests++
+	if slot, found := c.liveSlot(key); found {
+		c.stats.Hits++
+		c.touch(slot)
+		c.moveToHead(slot)
+		e := &c.entries[slot]
+		return VersionedEntry{Value: c.valueAt(slot), Version: e.version, Flags: e.flags, ExpiresAt: e.expireAt}, true
+	}
+	c.stats.Misses++
+
+	if version, deleted := c.tombstones[key]; deleted && !tombstoneExpired(version, time.Now()) {
+		return VersionedEntry{Version: version, Tombstone: true}, true
+	}
+	return VersionedEntry{}, false
+}
+
+// SetVersioned 写入带版本号的条目，版本不比现有条目新时不修改
+// 只有因内存限制或arena已满被拒绝时返回false
+func (c *ArenaLRUCache) SetVersioned(key string, entry VersionedEntry) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.setVersioned(key, entry, c.admission != nil)
+}
+
+// SetVersionedBypassAdmission 写入带版本号的条目，新key跳过准入窗口直接进入主缓存
+func (c *ArenaLRUCache) SetVersionedBypassAdmission(key string, entry VersionedEntry) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.setVersioned(key, entry, false)
+}
+
+// setVersioned 写入带版本号的条目，useWindow 为true时新key进入准入窗口（调用方持有锁）
+func (c *ArenaLRUCache) setVersioned(key string, entry VersionedEntry, useWindow bool) bool {
+	var current VersionedEntry
+	slot, found := c.liveSlot(key)
+	if found {
+		current = VersionedEntry{Version: c.entries[slot].version}
+	} else if version, deleted := c.tombstones[key]; deleted {
+		current = VersionedEntry{Version: version, Tombstone: true}
+	}
+	if !entry.NewerThan(current) {
+		return true
+	}
+	if entry.Version > c.lastVersion {
+		c.lastVersion = entry.Version
+	}
+
+	if entry.Tombstone {
+		if found {
+			c.removeSlot(slot)
+			c.maybeCompact()
+		}
+		if c.tombstones == nil {
+			c.tombstones = make(map[string]uint64)
+		}
+		c.tombstones[key] = entry.Version
+		purgeExpiredTombstones(c.tombstones, &c.tombstonePurge)
+		return true
+	}
+
+	slot = c.writeSlot(key, entry.Value, useWindow)
+	if slot == 0 {
+		return false
+	}
+	c.entries[slot].version = entry.Version
+	c.entries[slot].flags = entry.Flags
+	c.entries[slot].expireAt = entry.ExpiresAt
+	delete(c.tombstones, key)
+	return true
+}
+
+// VersionedEntries 获取所有未过期条目和删除标记的版本快照（不含value），用于副本间对比
+func (c *ArenaLRUCache) VersionedEntries() map[string]VersionedEntry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	now := time.Now()
+	nowNano := now.UnixNano()
+	entries := make(map[string]VersionedEntry, c.size+len(c.tombstones))
+	c.forEachSlot(func(slot uint32) {
+		if !c.isExpired(slot, nowNano) {
+			entries[c.keyAt(slot)] = VersionedEntry{Version: c.entries[slot].version}
+		}
+	})
+	for key, version := range c.tombstones {
+		if !tombstoneExpired(version, now) {
+			entries[key] = VersionedEntry{Version: version, Tombstone: true}
+		}
+	}
+	return entries
+}
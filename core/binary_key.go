@@ -0,0 +1,22 @@
+// 二进制安全的key编码 - 用于在URL路径中传递任意字节的key
+
+package core
+
+import (
+	"encoding/base64"
+	"strings"
+)
+
+// EncodeKey 将任意字节的key编码为base64url（无填充），可直接作为URL路径段
+func EncodeKey(key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+// DecodeKey 解码 EncodeKey 生成的key，同时兼容带'='填充的写法
+func DecodeKey(encoded string) (string, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "="))
+	if err != nil {
+		return "", err
+	}
+	return string(decoded), nil
+}
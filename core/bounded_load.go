@@ -0,0 +1,195 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync/atomic"
+)
+
+// 有界负载一致性哈希 (Consistent Hashing with Bounded Loads, Mirrokni et al.)
+// 每个节点的容量上限为 ceil((1+ε) × 总负载 × 节点权重占比)，
+// 查找时若顺时针遇到的节点负载已达上限，则继续向后查找下一个未满的节点。
+// 负载由调用方通过 ReportNodeLoad 上报（key数量或请求速率），
+// 负载变化后部分key的归属会随之变化，对缓存而言只是一次未命中。
+// 仅虚拟节点哈希环支持，其他放置算法没有"顺时针下一个节点"的概念。
+
+// 负载度量方式
+const (
+	BoundedLoadModeKeys     = "keys"     // 按节点的key数量
+	BoundedLoadModeRequests = "requests" // 按节点的请求速率
+)
+
+// BoundedLoadModes 支持的负载度量方式
+var BoundedLoadModes = []string{BoundedLoadModeKeys, BoundedLoadModeRequests}
+
+// NormalizeBoundedLoadMode 规范化负载度量方式，空字符串表示按key数量
+func NormalizeBoundedLoadMode(mode string) (string, error) {
+	if mode == "" {
+		return BoundedLoadModeKeys, nil
+	}
+	for _, m := range BoundedLoadModes {
+		if m == mode {
+			return mode, nil
+		}
+	}
+	return "", fmt.Errorf("未知的负载度量方式: %s (支持: %v)", mode, BoundedLoadModes)
+}
+
+// BoundedLoad 有界负载配置与状态
+type BoundedLoad struct {
+	Epsilon   float64            // 允许超出平均负载的比例
+	loads     map[string]float64 // 各节点最近上报的负载
+	redirects uint64             // 因主节点超载而改选其他节点的查找次数
+}
+
+// BoundedLoadStats 有界负载统计
+type BoundedLoadStats struct {
+	Enabled      bool               `json:"enabled"`
+	Epsilon      float64            `json:"epsilon"`
+	Loads        map[string]float64 `json:"loads"`
+	Capacities   map[string]float64 `json:"capacities"`
+	AverageLoad  float64            `json:"average_load"`
+	MaxLoadRatio float64            `json:"max_load_ratio"` // 按权重归一化后的最大负载 / 平均负载
+	Redirects    uint64             `json:"redirects"`
+}
+
+// EnableBoundedLoad 开启有界负载模式
+func (dc *DistributedCache) EnableBoundedLoad(epsilon float64) error {
+	if epsilon <= 0 || math.IsNaN(epsilon) || math.IsInf(epsilon, 0) {
+		return fmt.Errorf("有界负载的 ε 必须为正数: %v", epsilon)
+	}
+
+	dc.Mu.Lock()
+	defer dc.Mu.Unlock()
+
+	if dc.Placement != nil {
+		return fmt.Errorf("放置算法 %s 不支持有界负载", dc.Placement.Name())
+	}
+	if dc.BoundedLoad != nil {
+		dc.BoundedLoad.Epsilon = epsilon
+		return nil
+	}
+	dc.BoundedLoad = &BoundedLoad{
+		Epsilon: epsilon,
+		loads:   make(map[string]float64),
+	}
+	return nil
+}
+
+// DisableBoundedLoad 关闭有界负载模式
+func (dc *DistributedCache) DisableBoundedLoad() {
+	dc.Mu.Lock()
+	defer dc.Mu.Unlock()
+
+	dc.BoundedLoad = nil
+}
+
+// ReportNodeLoad 上报节点当前负载，未开启有界负载时忽略
+func (dc *DistributedCache) ReportNodeLoad(node string, load float64) {
+	if load < 0 || math.IsNaN(load) || math.IsInf(load, 0) {
+		return
+	}
+
+	dc.Mu.Lock()
+	defer dc.Mu.Unlock()
+
+	if dc.BoundedLoad != nil {
+		dc.BoundedLoad.loads[node] = load
+	}
+}
+
+// GetBoundedLoadStats 获取有界负载统计，反映上报负载下的实际均衡程度
+func (dc *DistributedCache) GetBoundedLoadStats() BoundedLoadStats {
+	dc.Mu.RLock()
+	defer dc.Mu.RUnlock()
+
+	bl := dc.BoundedLoad
+	if bl == nil {
+		return BoundedLoadStats{}
+	}
+
+	stats := BoundedLoadStats{
+		Enabled:    true,
+		Epsilon:    bl.Epsilon,
+		Loads:      make(map[string]float64, len(dc.Nodes)),
+		Capacities: dc.boundedCapacities(),
+		Redirects:  atomic.LoadUint64(&bl.redirects),
+	}
+
+	for _, node := range dc.Nodes {
+		stats.Loads[node] = bl.loads[node]
+	}
+	total, totalWeight := dc.boundedTotals()
+	if total == 0 {
+		return stats
+	}
+
+	stats.AverageLoad = total / float64(len(dc.Nodes))
+	for _, node := range dc.Nodes {
+		// 权重大的节点理应承担更多负载，按权重占比归一化后再比较
+		expected := total * dc.nodeWeight(node) / totalWeight
+		if ratio := bl.loads[node] / expected; ratio > stats.MaxLoadRatio {
+			stats.MaxLoadRatio = ratio
+		}
+	}
+	return stats
+}
+
+// boundedCapacities 计算各节点的容量上限，总负载为0时返回nil
+func (dc *DistributedCache) boundedCapacities() map[string]float64 {
+	total, totalWeight := dc.boundedTotals()
+	if total == 0 {
+		return nil
+	}
+
+	capacities := make(map[string]float64, len(dc.Nodes))
+	for _, node := range dc.Nodes {
+		capacities[node] = dc.boundedCapacity(node, total, totalWeight)
+	}
+	return capacities
+}
+
+// boundedTotals 统计哈希环中节点的总负载与总权重
+func (dc *DistributedCache) boundedTotals() (total, totalWeight float64) {
+	for _, node := range dc.Nodes {
+		total += dc.BoundedLoad.loads[node]
+		totalWeight += dc.nodeWeight(node)
+	}
+	return total, totalWeight
+}
+
+// boundedCapacity 节点容量上限 = ceil((1+ε) × 总负载 × 权重占比)
+func (dc *DistributedCache) boundedCapacity(node string, total, totalWeight float64) float64 {
+	return math.Ceil((1 + dc.BoundedLoad.Epsilon) * total * dc.nodeWeight(node) / totalWeight)
+}
+
+// boundedRingLookup 从key的哈希位置顺时针查找第一个未超载的节点
+// 至少有一个节点的负载不超过其权重份额，必然低于容量上限；找不到时退回主节点
+func (dc *DistributedCache) boundedRingLookup(key string) string {
+	hash := dc.hashKey(key)
+	idx := sort.Search(len(dc.SortedHashes), func(i int) bool {
+		return dc.SortedHashes[i] >= hash
+	})
+	if idx == len(dc.SortedHashes) {
+		idx = 0
+	}
+	primary := dc.HashRing[dc.SortedHashes[idx]]
+
+	total, totalWeight := dc.boundedTotals()
+	if total == 0 {
+		return primary
+	}
+
+	bl := dc.BoundedLoad
+	for i := 0; i < len(dc.SortedHashes); i++ {
+		node := dc.HashRing[dc.SortedHashes[(idx+i)%len(dc.SortedHashes)]]
+		if bl.loads[node] < dc.boundedCapacity(node, total, totalWeight) {
+			if node != primary {
+				atomic.AddUint64(&bl.redirects, 1)
+			}
+			return node
+		}
+	}
+	return primary
+}
@@ -4,7 +4,6 @@
 package core
 
 import (
-	"crypto/sha1"
 	"fmt"
 	"slices"
 	"sort"
@@ -27,6 +26,13 @@ type DistributedCache struct {
 	VirtualNodes int                  // 每个节点的虚拟节点数量
 	SortedHashes []uint32             // 排序后的哈希值列表，用于快速查找
 	LocalCaches  map[string]*LRUCache // 每个节点的本地缓存
+	Hasher       Hasher               // 哈希函数，为nil时使用SHA-1
+	Weights      map[string]float64   // 节点权重，虚拟节点数 = VirtualNodes × 权重，未设置时为1
+	Placement    Placement            // 放置算法，为nil时使用上面的虚拟节点哈希环
+	BoundedLoad  *BoundedLoad         // 有界负载模式，为nil时关闭
+	Locations    map[string]NodeLocation // 节点所在的可用区和机架，用于副本放置
+	ReplicaConstraint string          // 副本位置约束: none(默认) / zone / rack
+	epoch        uint64               // 拓扑版本号，每次拓扑变更加1（原子访问）
 
 	// 基础数据迁移相关
 	BasicMigrationStats BasicMigrationStats     // 基础迁移统计信息
@@ -46,7 +52,7 @@ func NewDistributedCache(nodes []string) *DistributedCache {
 	dc.buildHashRing()
 	// 2. 为每个节点创建本地缓存实例
 	for _, node := range nodes {
-		dc.LocalCaches[node] = NewLRUCache(1000)
+		dc.LocalCaches[node] = dc.newLocalCache()
 	}
 	// 调用 buildHashRing() 构建哈希环
 	// 默认虚拟节点数量可以设为 150（经验值）
@@ -66,12 +72,40 @@ func NewDistributedCacheWithVirtualNodes(nodes []string, virtualNodeCount int) *
 	dc.buildHashRing()
 	// 2. 为每个节点创建本地缓存实例
 	for _, node := range nodes {
-		dc.LocalCaches[node] = NewLRUCache(1000)
+		dc.LocalCaches[node] = dc.newLocalCache()
 	}
 	
 	return dc
 }
 
+// NewDistributedCacheWithHasher 创建使用指定哈希函数的分布式缓存
+// virtualNodeCount <= 0 时使用默认值150，hasher 为nil时使用SHA-1
+func NewDistributedCacheWithHasher(nodes []string, virtualNodeCount int, hasher Hasher) *DistributedCache {
+	if virtualNodeCount <= 0 {
+		virtualNodeCount = 150
+	}
+	dc := &DistributedCache{
+		Nodes:        nodes,
+		VirtualNodes: virtualNodeCount,
+		LocalCaches:  make(map[string]*LRUCache),
+		Hasher:       hasher,
+	}
+	dc.buildHashRing()
+	for _, node := range nodes {
+		dc.LocalCaches[node] = dc.newLocalCache()
+	}
+
+	return dc
+}
+
+// HasherName 获取哈希环使用的哈希函数名称
+func (dc *DistributedCache) HasherName() string {
+	if dc.Hasher == nil {
+		return HasherSHA1
+	}
+	return dc.Hasher.Name()
+}
+
 // GetNodeForKey 根据键获取对应的节点
 // 这是一致性哈希的核心算法
 func (dc *DistributedCache) GetNodeForKey(key string) string {
@@ -84,6 +118,14 @@ func (dc *DistributedCache) GetNodeForKey(key string) string {
 
 // getNodeForKeyUnsafe 不加锁的内部版本，用于已持有锁的上下文
 func (dc *DistributedCache) getNodeForKeyUnsafe(key string) string {
+	return dc.placement().GetNodeForKey(key)
+}
+
+// ringLookup 在虚拟节点哈希环上查找负责节点
+func (dc *DistributedCache) ringLookup(key string) string {
+	if dc.BoundedLoad != nil && len(dc.SortedHashes) > 0 {
+		return dc.boundedRingLookup(key)
+	}
 	// 1. 计算键的哈希值
 	hash := dc.hashKey(key)
 	// 2. 在排序的哈希环中找到第一个大于等于该哈希值的虚拟节点
@@ -111,21 +153,18 @@ func (dc *DistributedCache) AddNode(node string) error {
 	// 2. 记录迁移开始时间
 	startTime := time.Now()
 
-	// 3. 记录添加节点前的哈希环状态（用于确定需要迁移的数据范围）
-	oldSortedHashes := make([]uint32, len(dc.SortedHashes))
-	copy(oldSortedHashes, dc.SortedHashes)
-
-	// 4. 添加新节点到集群
+	// 3. 添加新节点到集群
 	dc.Nodes = append(dc.Nodes, node)
-	dc.LocalCaches[node] = NewLRUCache(1000)
-
-	// 5. 将新节点添加到哈希环（增量操作）
-	dc.addNodeToHashRing(node)
+	dc.LocalCaches[node] = dc.newLocalCache()
 
-	// 6. 执行一致性哈希数据迁移
-	migratedCount := dc.migrateDataForAddedNode(node, oldSortedHashes)
+	// 4. 将新节点加入放置算法（哈希环为增量操作），并迁移归属发生变化的数据
+	// 哈希环只迁移新虚拟节点接管的区间，其他放置算法对比所有key的新旧归属
+	migratedCount := dc.migrateAfter(func() {
+		dc.placement().AddNode(node)
+	})
+	dc.bumpEpoch()
 
-	// 7. 更新统计信息
+	// 6. 更新统计信息
 	dc.updateBasicMigrationStats(migratedCount, time.Since(startTime))
 
 	return nil
@@ -151,11 +190,14 @@ func (dc *DistributedCache) RemoveNode(node string) error {
 	dc.removeNodeFromNodes(node)
 	delete(dc.LocalCaches, node)
 
-	// 5. 从哈希环中移除该节点（增量操作）
-	dc.removeNodeFromHashRing(node)
+	// 5. 从放置算法中移除该节点（哈希环为增量操作），其余节点之间归属变化的数据（如Maglev/Jump）一并迁移
+	migratedCount := dc.migrateAfter(func() {
+		dc.placement().RemoveNode(node)
+	})
+	dc.bumpEpoch()
 
-	// 6. 将被移除节点的数据按一致性哈希重新分布
-	migratedCount := dc.redistributeDataBasic(nodeData)
+	// 6. 将被移除节点的数据重新分布
+	migratedCount += dc.redistributeDataBasic(nodeData)
 
 	// 7. 更新统计信息
 	dc.updateBasicMigrationStats(migratedCount, time.Since(startTime))
@@ -177,8 +219,8 @@ func (dc *DistributedCache) buildHashRing() {
 
 // addNodeToHashRing 向哈希环中添加单个节点 - 扩容时使用
 func (dc *DistributedCache) addNodeToHashRing(node string) {
-	// 为新节点创建虚拟节点并添加到哈希环
-	for i := 0; i < dc.VirtualNodes; i++ {
+	// 为新节点创建虚拟节点并添加到哈希环，数量按节点权重缩放
+	for i := 0; i < dc.virtualNodeCount(node); i++ {
 		virtualNode := node + "#" + strconv.Itoa(i)
 		hash := dc.hashKey(virtualNode)
 		dc.HashRing[hash] = node
@@ -215,19 +257,18 @@ func (dc *DistributedCache) removeNodeFromHashRing(node string) {
 	dc.SortedHashes = newSortedHashes
 }
 
+// HashKey 计算键在哈希环上的哈希值
+func (dc *DistributedCache) HashKey(key string) uint32 {
+	return dc.hashKey(key)
+}
+
 // hashKey 计算键的哈希值
 func (dc *DistributedCache) hashKey(key string) uint32 {
-	// TODO: 实现哈希函数
-	// 提示：
-	// 1. 使用 SHA1 算法：sha1.Sum([]byte(key))
-	hashBytes := sha1.Sum([]byte(key))
-	// 2. 取前4个字节转换为 uint32
-	// 确保哈希分布均匀
-	hash := uint32(hashBytes[0])<<24 + 
-		   uint32(hashBytes[1])<<16 + 
-		   uint32(hashBytes[2])<<8 + 
-		   uint32(hashBytes[3])
-	return hash
+	// 未指定哈希函数时使用SHA-1（取前4个字节），与旧版本的环布局保持一致
+	if dc.Hasher == nil {
+		return sha1Hasher{}.Hash(key)
+	}
+	return dc.Hasher.Hash(key)
 }
 
 // Set 在分布式缓存中设置键值对
@@ -328,78 +369,6 @@ func (dc *DistributedCache) GetMigrationStats() BasicMigrationStats {
 
 // ===== 基础数据迁移辅助方法 =====
 
-// migrateDataForAddedNode 为新添加的节点执行数据迁移
-// 根据一致性哈希原理：新节点只需要接管部分数据，这些数据原本存储在其他节点
-func (dc *DistributedCache) migrateDataForAddedNode(newNode string, oldSortedHashes []uint32) int {
-	migratedCount := 0
-
-	// 获取新节点在哈希环上的所有虚拟节点位置
-	newNodeHashes := make([]uint32, 0)
-	for hash, node := range dc.HashRing {
-		if node == newNode {
-			newNodeHashes = append(newNodeHashes, hash)
-		}
-	}
-
-	// 对于每个新的虚拟节点，找出需要从其他节点迁移过来的数据
-	for _, newHash := range newNodeHashes {
-		// 在旧的哈希环中，这个位置的数据应该存储在哪个节点？
-		oldResponsibleNode := dc.findResponsibleNodeInOldRing(newHash, oldSortedHashes)
-		if oldResponsibleNode == "" || oldResponsibleNode == newNode {
-			continue // 没有需要迁移的数据
-		}
-
-		// 从旧的负责节点中找出需要迁移的数据
-		oldCache := dc.LocalCaches[oldResponsibleNode]
-		if oldCache == nil {
-			continue
-		}
-
-		// 获取该节点的所有数据，检查哪些应该迁移到新节点
-		allData := oldCache.GetAllData()
-		for key, value := range allData {
-			// 重新计算这个key现在应该存储在哪个节点（使用不加锁版本，因为已持有写锁）
-			currentResponsibleNode := dc.getNodeForKeyUnsafe(key)
-			if currentResponsibleNode == newNode {
-				// 这个key现在应该存储在新节点，需要迁移
-				dc.LocalCaches[newNode].Set(key, value)
-				oldCache.Delete(key)
-				migratedCount++
-			}
-		}
-	}
-
-	return migratedCount
-}
-
-// findResponsibleNodeInOldRing 在旧的哈希环中找到负责指定哈希值的节点
-func (dc *DistributedCache) findResponsibleNodeInOldRing(hash uint32, oldSortedHashes []uint32) string {
-	if len(oldSortedHashes) == 0 {
-		return ""
-	}
-
-	// 在旧的排序哈希列表中找到第一个大于等于hash的位置
-	idx := sort.Search(len(oldSortedHashes), func(i int) bool {
-		return oldSortedHashes[i] >= hash
-	})
-
-	// 如果没找到，则使用环的第一个节点（环形特性）
-	if idx == len(oldSortedHashes) {
-		idx = 0
-	}
-
-	// 通过哈希值找到对应的节点（需要在旧的映射中查找）
-	// 这里简化处理：遍历当前HashRing找到对应节点
-	targetHash := oldSortedHashes[idx]
-	for ringHash, node := range dc.HashRing {
-		if ringHash == targetHash {
-			return node
-		}
-	}
-
-	return ""
-}
-
 // getAllDataFromCache 获取指定缓存的所有数据
 func (dc *DistributedCache) getAllDataFromCache(cache *LRUCache) map[string]string {
 	return cache.GetAllData()
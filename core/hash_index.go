@@ -0,0 +1,205 @@
+package core
+
+import (
+	"sort"
+	"time"
+)
+
+// key哈希索引 - 按哈希值的高16位分桶记录key，支持按哈希区间取出数据
+// 哈希环增删节点时只有若干区间的归属发生变化，借助索引只需访问这些区间内的key，
+// 而不必遍历整个缓存。哈希值在key写入时计算一次并保存在节点上，删除和淘汰时直接使用。
+
+// HashInterval 环上的哈希区间 (Start, End]，Start >= End 时区间跨过0点，相等表示整个环
+type HashInterval struct {
+	Start uint32 `json:"start"`
+	End   uint32 `json:"end"`
+}
+
+// intervalLocator 在互不相交的区间中定位哈希值所在的区间
+type intervalLocator struct {
+	intervals []HashInterval
+	order     []int // 不跨过0点的区间按起点排序后的下标
+	wrap      []int // 跨过0点的区间下标
+}
+
+func newIntervalLocator(intervals []HashInterval) *intervalLocator {
+	l := &intervalLocator{intervals: intervals}
+	for i, r := range intervals {
+		if r.Start < r.End {
+			l.order = append(l.order, i)
+		} else {
+			l.wrap = append(l.wrap, i)
+		}
+	}
+	sort.Slice(l.order, func(a, b int) bool { return intervals[l.order[a]].Start < intervals[l.order[b]].Start })
+	return l
+}
+
+// locate 返回哈希值所在区间的下标，不在任何区间内时返回-1
+func (l *intervalLocator) locate(h uint32) int {
+	// 起点小于h的最后一个区间
+	idx := sort.Search(len(l.order), func(i int) bool { return l.intervals[l.order[i]].Start >= h }) - 1
+	if idx >= 0 && h <= l.intervals[l.order[idx]].End {
+		return l.order[idx]
+	}
+	for _, i := range l.wrap {
+		if hashInRange(h, l.intervals[i].Start, l.intervals[i].End) {
+			return i
+		}
+	}
+	return -1
+}
+
+// keyHashIndex 哈希分桶索引：桶号 -> key -> 哈希值
+type keyHashIndex struct {
+	hash    func(key string) uint32
+	buckets map[uint16]map[string]uint32
+}
+
+func newKeyHashIndex(hash func(key string) uint32) *keyHashIndex {
+	return &keyHashIndex{hash: hash, buckets: make(map[uint16]map[string]uint32)}
+}
+
+func (idx *keyHashIndex) add(key string, h uint32) {
+	bucket := idx.buckets[uint16(h>>16)]
+	if bucket == nil {
+		bucket = make(map[string]uint32)
+		idx.buckets[uint16(h>>16)] = bucket
+	}
+	bucket[key] = h
+}
+
+func (idx *keyHashIndex) remove(key string, h uint32) {
+	b := uint16(h >> 16)
+	if bucket := idx.buckets[b]; bucket != nil {
+		delete(bucket, key)
+		if len(bucket) == 0 {
+			delete(idx.buckets, b)
+		}
+	}
+}
+
+// keysInRange 返回哈希值在 (start, end] 内的key，start >= end 时区间跨过0点，相等表示整个环
+func (idx *keyHashIndex) keysInRange(start, end uint32) []string {
+	var keys []string
+	collect := func(bucket map[string]uint32) {
+		for key, h := range bucket {
+			if hashInRange(h, start, end) {
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	// 区间覆盖的桶数多于非空桶数时直接遍历非空桶
+	// 桶号为uint16，跨过0点的区间相减后自然回绕
+	first, last := uint16(start>>16), uint16(end>>16)
+	span := int(last-first) + 1
+	if start >= end && first == last {
+		span = 1<<16 + 1
+	}
+	if span > len(idx.buckets) {
+		for _, bucket := range idx.buckets {
+			collect(bucket)
+		}
+		return keys
+	}
+	for b := first; ; b++ {
+		collect(idx.buckets[b])
+		if b == last {
+			return keys
+		}
+	}
+}
+
+// hashInRange 哈希值是否在环上的区间 (start, end] 内
+func hashInRange(h, start, end uint32) bool {
+	if start < end {
+		return h > start && h <= end
+	}
+	return h > start || h <= end
+}
+
+// EnableHashIndex 启用key哈希索引，已有的key会被立即索引
+func (lru *LRUCache) EnableHashIndex(hash func(key string) uint32) {
+	lru.mu.Lock()
+	defer lru.mu.Unlock()
+
+	lru.hashIndex = newKeyHashIndex(hash)
+	for _, node := range lru.cache {
+		lru.indexKey(node)
+	}
+}
+
+// GetDataInHashRange 获取哈希值在 (start, end] 内的未过期数据，start >= end 时区间跨过0点
+// 需要先启用哈希索引，否则返回nil
+func (lru *LRUCache) GetDataInHashRange(start, end uint32) map[string]string {
+	lru.mu.RLock()
+	defer lru.mu.RUnlock()
+
+	if lru.hashIndex == nil {
+		return nil
+	}
+
+	result := make(map[string]string)
+	now := time.Now()
+	for _, key := range lru.hashIndex.keysInRange(start, end) {
+		if expireTime, hasTTL := lru.ttlMap[key]; hasTTL && now.After(expireTime) {
+			continue
+		}
+		result[key] = lru.cache[key].value
+	}
+	return result
+}
+
+// VersionedEntriesInHashRanges 取出多个互不相交的哈希区间内的未过期条目和删除标记，结果与 ranges 一一对应
+// 需要先启用哈希索引，否则返回nil
+func (lru *LRUCache) VersionedEntriesInHashRanges(ranges []HashInterval) []map[string]VersionedEntry {
+	lru.mu.RLock()
+	defer lru.mu.RUnlock()
+
+	if lru.hashIndex == nil {
+		return nil
+	}
+
+	result := make([]map[string]VersionedEntry, len(ranges))
+	now := time.Now()
+	for i, r := range ranges {
+		result[i] = make(map[string]VersionedEntry)
+		for _, key := range lru.hashIndex.keysInRange(r.Start, r.End) {
+			if expireTime, hasTTL := lru.ttlMap[key]; hasTTL && now.After(expireTime) {
+				continue
+			}
+			node := lru.cache[key]
+			result[i][key] = VersionedEntry{Value: node.value, Version: node.version, Flags: node.flags, ExpiresAt: lru.expiresAt(key)}
+		}
+	}
+
+	// 删除标记不在索引中，数量受保留时间限制，逐个计算哈希
+	if len(lru.tombstones) > 0 {
+		locator := newIntervalLocator(ranges)
+		for key, version := range lru.tombstones {
+			if tombstoneExpired(version, now) {
+				continue
+			}
+			if i := locator.locate(lru.hashIndex.hash(key)); i >= 0 {
+				result[i][key] = VersionedEntry{Version: version, Tombstone: true}
+			}
+		}
+	}
+	return result
+}
+
+// indexKey 新key写入后计算哈希值并更新索引（调用方持有锁）
+func (lru *LRUCache) indexKey(node *LRUNode) {
+	if lru.hashIndex != nil {
+		node.ringHash = lru.hashIndex.hash(node.key)
+		lru.hashIndex.add(node.key, node.ringHash)
+	}
+}
+
+// unindexKey key删除后按写入时的哈希值更新索引（调用方持有锁）
+func (lru *LRUCache) unindexKey(node *LRUNode) {
+	if lru.hashIndex != nil {
+		lru.hashIndex.remove(node.key, node.ringHash)
+	}
+}
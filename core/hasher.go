@@ -0,0 +1,209 @@
+package core
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+)
+
+// Hasher 一致性哈希环使用的哈希函数
+// 集群中所有节点必须使用同一种哈希函数，否则对key的归属判断不一致
+type Hasher interface {
+	Name() string
+	Hash(key string) uint32
+}
+
+// 哈希函数名称
+const (
+	HasherSHA1    = "sha1"    // 默认，兼容旧版本的环布局
+	HasherFNV1a   = "fnv1a"   // FNV-1a 32位
+	HasherMurmur3 = "murmur3" // MurmurHash3 x86_32，seed 0
+	HasherXXHash  = "xxhash"  // XXH32，seed 0
+)
+
+// HasherNames 支持的哈希函数名称
+var HasherNames = []string{HasherSHA1, HasherFNV1a, HasherMurmur3, HasherXXHash}
+
+// NewHasher 根据名称创建哈希函数，空字符串表示默认的SHA-1
+func NewHasher(name string) (Hasher, error) {
+	switch name {
+	case "", HasherSHA1:
+		return sha1Hasher{}, nil
+	case HasherFNV1a:
+		return fnv1aHasher{}, nil
+	case HasherMurmur3:
+		return murmur3Hasher{}, nil
+	case HasherXXHash:
+		return xxHasher{}, nil
+	default:
+		return nil, fmt.Errorf("未知的哈希函数: %s", name)
+	}
+}
+
+// NormalizeHasherName 规范化哈希函数名称，未配置（旧版本节点）等同于SHA-1
+func NormalizeHasherName(name string) string {
+	if name == "" {
+		return HasherSHA1
+	}
+	return name
+}
+
+// ===== SHA-1 =====
+
+// sha1Hasher 取SHA-1摘要的前4个字节（大端序）
+type sha1Hasher struct{}
+
+func (sha1Hasher) Name() string { return HasherSHA1 }
+
+func (sha1Hasher) Hash(key string) uint32 {
+	sum := sha1.Sum([]byte(key))
+	return binary.BigEndian.Uint32(sum[:4])
+}
+
+// ===== FNV-1a =====
+
+const (
+	fnv32Offset = 2166136261
+	fnv32Prime  = 16777619
+)
+
+// fnv1aHasher 与 hash/fnv.New32a 结果一致，不分配内存
+type fnv1aHasher struct{}
+
+func (fnv1aHasher) Name() string { return HasherFNV1a }
+
+func (fnv1aHasher) Hash(key string) uint32 {
+	hash := uint32(fnv32Offset)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint32(key[i])
+		hash *= fnv32Prime
+	}
+	return hash
+}
+
+// ===== MurmurHash3 =====
+
+const (
+	murmurC1 = 0xcc9e2d51
+	murmurC2 = 0x1b873593
+)
+
+// murmur3Hasher MurmurHash3 x86_32
+type murmur3Hasher struct{}
+
+func (murmur3Hasher) Name() string { return HasherMurmur3 }
+
+func (murmur3Hasher) Hash(key string) uint32 {
+	var hash uint32
+	length := len(key)
+
+	// 每次处理4字节
+	i := 0
+	for ; i+4 <= length; i += 4 {
+		k := readUint32LE(key, i)
+		k *= murmurC1
+		k = bits.RotateLeft32(k, 15)
+		k *= murmurC2
+
+		hash ^= k
+		hash = bits.RotateLeft32(hash, 13)
+		hash = hash*5 + 0xe6546b64
+	}
+
+	// 剩余不足4字节的部分
+	var k uint32
+	switch length - i {
+	case 3:
+		k ^= uint32(key[i+2]) << 16
+		fallthrough
+	case 2:
+		k ^= uint32(key[i+1]) << 8
+		fallthrough
+	case 1:
+		k ^= uint32(key[i])
+		k *= murmurC1
+		k = bits.RotateLeft32(k, 15)
+		k *= murmurC2
+		hash ^= k
+	}
+
+	// 最终混合
+	hash ^= uint32(length)
+	hash ^= hash >> 16
+	hash *= 0x85ebca6b
+	hash ^= hash >> 13
+	hash *= 0xc2b2ae35
+	hash ^= hash >> 16
+	return hash
+}
+
+// ===== xxHash =====
+
+const (
+	xxPrime1 = 2654435761
+	xxPrime2 = 2246822519
+	xxPrime3 = 3266489917
+	xxPrime4 = 668265263
+	xxPrime5 = 374761393
+)
+
+// xxHasher XXH32
+type xxHasher struct{}
+
+func (xxHasher) Name() string { return HasherXXHash }
+
+func (xxHasher) Hash(key string) uint32 {
+	length := len(key)
+	i := 0
+	var hash uint32
+
+	if length >= 16 {
+		// 4路并行累加，每轮处理16字节
+		prime1, prime2 := uint32(xxPrime1), uint32(xxPrime2)
+		v1 := prime1 + prime2
+		v2 := prime2
+		v3 := uint32(0)
+		v4 := -prime1
+		for ; i+16 <= length; i += 16 {
+			v1 = xxRound(v1, readUint32LE(key, i))
+			v2 = xxRound(v2, readUint32LE(key, i+4))
+			v3 = xxRound(v3, readUint32LE(key, i+8))
+			v4 = xxRound(v4, readUint32LE(key, i+12))
+		}
+		hash = bits.RotateLeft32(v1, 1) + bits.RotateLeft32(v2, 7) +
+			bits.RotateLeft32(v3, 12) + bits.RotateLeft32(v4, 18)
+	} else {
+		hash = xxPrime5
+	}
+
+	hash += uint32(length)
+
+	for ; i+4 <= length; i += 4 {
+		hash += readUint32LE(key, i) * xxPrime3
+		hash = bits.RotateLeft32(hash, 17) * xxPrime4
+	}
+	for ; i < length; i++ {
+		hash += uint32(key[i]) * xxPrime5
+		hash = bits.RotateLeft32(hash, 11) * xxPrime1
+	}
+
+	hash ^= hash >> 15
+	hash *= xxPrime2
+	hash ^= hash >> 13
+	hash *= xxPrime3
+	hash ^= hash >> 16
+	return hash
+}
+
+// xxRound XXH32单轮累加
+func xxRound(acc, input uint32) uint32 {
+	acc += input * xxPrime2
+	acc = bits.RotateLeft32(acc, 13)
+	return acc * xxPrime1
+}
+
+// readUint32LE 从字符串读取小端序uint32，避免转换为[]byte
+func readUint32LE(s string, i int) uint32 {
+	return uint32(s[i]) | uint32(s[i+1])<<8 | uint32(s[i+2])<<16 | uint32(s[i+3])<<24
+}
@@ -3,40 +3,68 @@
 package core
 
 import (
+	"container/heap"
+	"fmt"
 	"sync"
 	"time"
 )
 
 // 双向链表节点
 type LRUNode struct {
-	key string
+	key   string
 	value string
-	prev *LRUNode
-	next *LRUNode
+	prev  *LRUNode
+	next  *LRUNode
+
+	// 元数据
+	createdAt   time.Time // 首次写入时间
+	lastAccess  time.Time // 最后访问时间（读或写）
+	accessCount int64     // 读命中次数
+	version     uint64    // 每次写入都会变化的版本号（memcached CAS）
+	flags       uint32    // 客户端自定义标志（memcached flags）
+	ringHash    uint32    // 启用哈希索引时key的环哈希，写入时计算一次
+	inWindow    bool      // 是否位于准入窗口
 }
 
 // LRU缓存结构
 type LRUCache struct {
-	capacity int  
-	size int
-	cache map[string]*LRUNode // 哈希表 ： key -> 节点
-	head *LRUNode // 虚拟头节点 （最近使用）
-	tail *LRUNode // 虚拟尾节点 （最久未使用）
-	mu sync.RWMutex
-
-	// TTL 
+	capacity int
+	size     int
+	cache    map[string]*LRUNode // 哈希表 ： key -> 节点
+	head     *LRUNode            // 虚拟头节点 （最近使用）
+	tail     *LRUNode            // 虚拟尾节点 （最久未使用）
+	mu       sync.RWMutex
+
+	// TTL
 	ttlMap map[string]time.Time
 
 	// 异步清理
 	cleanupInterval time.Duration
-	stopCleanup chan struct{}
-	cleanupStats CleanupStats
+	stopCleanup     chan struct{}
+	cleanupStats    CleanupStats
 	// 统计
 	stats CacheStats
 
 	// 内存限制
 	memoryUsage int64 // 当前内存使用量
 	memoryLimit int64 // 内存限制（0表示无限制）
+
+	// 准入策略（nil表示不启用）
+	// 启用时新key先进入占总容量1%的窗口LRU，离开窗口时才与主缓存的淘汰者比较频率（W-TinyLFU）
+	admission  *TinyLFU
+	windowHead *LRUNode // 准入窗口虚拟头节点
+	windowTail *LRUNode // 准入窗口虚拟尾节点
+	windowSize int
+
+	// 最近分配的版本号
+	lastVersion uint64
+
+	// key哈希索引（nil表示不启用），用于按哈希区间迁移数据
+	hashIndex *keyHashIndex
+
+	// 多副本删除标记：key -> 删除时的版本号，防止旧副本的数据在合并时复活
+	tombstones     map[string]uint64
+	tombstonePurge time.Time // 上次清理过期删除标记的时间
 }
 
 type CleanupStats struct {
@@ -47,33 +75,33 @@ type CleanupStats struct {
 
 // 统计结构
 type CacheStats struct {
-	Hits int64
-	Misses int64
+	Hits          int64
+	Misses        int64
 	TotalRequests int64
 }
 
 // 2. API响应结构（面向客户端）
 type StatsAPIResponse struct {
-    Hits         int64   `json:"hits"`
-    Misses       int64   `json:"misses"`
-    TotalRequests int64  `json:"total_requests"`
-    HitRate      float64 `json:"hit_rate"`
-    CacheSize    int     `json:"cache_size"`
-    MemoryUsage  int64   `json:"memory_usage"`
-    Uptime       string  `json:"uptime,omitempty"`
+	Hits          int64   `json:"hits"`
+	Misses        int64   `json:"misses"`
+	TotalRequests int64   `json:"total_requests"`
+	HitRate       float64 `json:"hit_rate"`
+	CacheSize     int     `json:"cache_size"`
+	MemoryUsage   int64   `json:"memory_usage"`
+	Uptime        string  `json:"uptime,omitempty"`
 }
 
 // 3. 转换函数
 func (s *CacheServer) buildStatsResponse() *StatsAPIResponse {
-    stats := s.cache.GetStats()
-    return &StatsAPIResponse{
-        Hits:         stats.Hits,
-        Misses:       stats.Misses,
-        TotalRequests: stats.TotalRequests,
-        HitRate:      stats.HitRate(),
-        CacheSize:    s.cache.Size(),
-        MemoryUsage:  s.cache.GetMemoryUsage(),
-    }
+	stats := s.cache.GetStats()
+	return &StatsAPIResponse{
+		Hits:          stats.Hits,
+		Misses:        stats.Misses,
+		TotalRequests: stats.TotalRequests,
+		HitRate:       stats.HitRate(),
+		CacheSize:     s.cache.Size(),
+		MemoryUsage:   s.cache.GetMemoryUsage(),
+	}
 }
 
 func NewLRUCache(capacity int) *LRUCache {
@@ -83,9 +111,9 @@ func NewLRUCache(capacity int) *LRUCache {
 
 	lru := &LRUCache{
 		capacity: capacity,
-		cache: make(map[string]*LRUNode),
-		head: &LRUNode{},
-		tail: &LRUNode{},
+		cache:    make(map[string]*LRUNode),
+		head:     &LRUNode{},
+		tail:     &LRUNode{},
 		// 内存限制
 		memoryLimit: 0,
 	}
@@ -104,6 +132,39 @@ func NewLRUCacheWithMemoryLimit(capacity int, memoryLimitBytes int64) *LRUCache
 	return lru
 }
 
+// 带准入策略的构造函数
+func NewLRUCacheWithAdmission(capacity int, counters int) *LRUCache {
+	lru := NewLRUCache(capacity)
+	lru.EnableAdmission(counters)
+	return lru
+}
+
+// EnableAdmission 启用TinyLFU准入策略
+// counters <= 0 时按容量的10倍分配计数器
+func (lru *LRUCache) EnableAdmission(counters int) {
+	lru.mu.Lock()
+	defer lru.mu.Unlock()
+	if counters <= 0 {
+		counters = lru.capacity * 10
+	}
+	lru.admission = NewTinyLFU(counters)
+	if lru.windowHead == nil {
+		lru.windowHead, lru.windowTail = &LRUNode{}, &LRUNode{}
+		lru.windowHead.next = lru.windowTail
+		lru.windowTail.prev = lru.windowHead
+	}
+}
+
+// GetAdmissionStats 获取准入统计，未启用准入策略时第二个返回值为false
+func (lru *LRUCache) GetAdmissionStats() (AdmissionStats, bool) {
+	lru.mu.RLock()
+	defer lru.mu.RUnlock()
+	if lru.admission == nil {
+		return AdmissionStats{}, false
+	}
+	return lru.admission.Stats(), true
+}
+
 // 带TTL的构造函数
 func NewLRUCacheWithCleanup(capacity int, cleanupInterval time.Duration) *LRUCache {
 	c := NewLRUCache(capacity)
@@ -118,7 +179,7 @@ func NewLRUCacheWithCleanup(capacity int, cleanupInterval time.Duration) *LRUCac
 func (lru *LRUCache) startCleanupRoutine() {
 	ticker := time.NewTicker(lru.cleanupInterval)
 	defer ticker.Stop()
-	
+
 	for {
 		select {
 		case <-ticker.C:
@@ -130,7 +191,7 @@ func (lru *LRUCache) startCleanupRoutine() {
 }
 
 // 清理过期键
-func(lru *LRUCache) cleanupExpiredkeys() {
+func (lru *LRUCache) cleanupExpiredkeys() {
 	lru.mu.Lock()
 	defer lru.mu.Unlock()
 
@@ -144,6 +205,7 @@ func(lru *LRUCache) cleanupExpiredkeys() {
 				lru.memoryUsage -= calculateMemoryUsage(key, node.value)
 				lru.removeNode(node)
 				delete(lru.cache, key)
+				lru.unindexKey(node)
 				lru.size--
 			}
 			delete(lru.ttlMap, key)
@@ -165,7 +227,6 @@ func (lru *LRUCache) GetCleanupStats() CleanupStats {
 	return lru.cleanupStats
 }
 
-
 // 内存计算辅助函数
 func calculateMemoryUsage(key, value string) int64 {
 	// 节点结构开销：key + value + 指针 = 24 + 24 + 16 = 64字节
@@ -180,21 +241,41 @@ func (lru *LRUCache) addToHead(node *LRUNode) {
 	lru.head.next = node
 }
 
+// 将节点添加到准入窗口头部
+func (lru *LRUCache) addToWindow(node *LRUNode) {
+	node.inWindow = true
+	node.prev = lru.windowHead
+	node.next = lru.windowHead.next
+	lru.windowHead.next.prev = node
+	lru.windowHead.next = node
+	lru.windowSize++
+}
+
 // 从链表中删除节点
-func (lru *LRUCache) removeNode (node *LRUNode) {
+func (lru *LRUCache) removeNode(node *LRUNode) {
 	node.prev.next = node.next
 	node.next.prev = node.prev
+	if node.inWindow {
+		lru.windowSize--
+	}
 }
 
-// 将节点移动到头部
+// 将节点移动到所在链表（主缓存或准入窗口）的头部
 func (lru *LRUCache) moveToHead(node *LRUNode) {
 	lru.removeNode(node)
-	lru.addToHead(node)
+	if node.inWindow {
+		lru.addToWindow(node)
+	} else {
+		lru.addToHead(node)
+	}
 }
 
-// 从链表中删除尾部节点 返回被删除的节点
+// 从链表中删除尾部节点 返回被删除的节点（主缓存为空时取准入窗口的尾部）
 func (lru *LRUCache) removeTail() *LRUNode {
 	lastNode := lru.tail.prev
+	if lastNode == lru.head && lru.windowTail != nil {
+		lastNode = lru.windowTail.prev
+	}
 	lru.removeNode(lastNode)
 	return lastNode
 }
@@ -202,8 +283,9 @@ func (lru *LRUCache) removeTail() *LRUNode {
 func (lru *LRUCache) SetWithTTL(key, value string, ttl time.Duration) {
 	lru.mu.Lock()
 	defer lru.mu.Unlock()
-	lru.SetInternal(key, value)
-	lru.ttlMap[key] = time.Now().Add(ttl)
+	if node := lru.setNode(key, value); node != nil {
+		lru.setTTL(key, ttl)
+	}
 }
 
 // 添加内存限制检查的Set方法
@@ -214,39 +296,126 @@ func (lru *LRUCache) Set(key, value string) {
 }
 
 func (lru *LRUCache) SetInternal(key, value string) {
+	lru.setNode(key, value)
+}
+
+// TrySet 写入键值，因内存限制被拒绝时返回false
+func (lru *LRUCache) TrySet(key, value string) bool {
+	lru.mu.Lock()
+	defer lru.mu.Unlock()
+	return lru.setNode(key, value) != nil
+}
+
+// SetBypassAdmission 写入键值，新key跳过准入窗口直接进入主缓存，因内存限制被拒绝时返回false
+// 用于数据迁移：迁移来的key在本节点没有访问记录，经过准入会被成批淘汰
+func (lru *LRUCache) SetBypassAdmission(key, value string) bool {
+	lru.mu.Lock()
+	defer lru.mu.Unlock()
+	return lru.writeNode(key, value, false) != nil
+}
+
+// setNode 写入键值并返回节点，因内存限制被拒绝时返回nil
+// 启用准入策略时新key先进入准入窗口
+func (lru *LRUCache) setNode(key, value string) *LRUNode {
+	return lru.writeNode(key, value, lru.admission != nil)
+}
+
+// writeNode 写入键值并返回节点，useWindow 为true时新key进入准入窗口，否则直接进入主缓存
+func (lru *LRUCache) writeNode(key, value string, useWindow bool) *LRUNode {
 	newMemory := calculateMemoryUsage(key, value)
 	if lru.memoryLimit > 0 && newMemory > lru.memoryLimit {
-		return
+		return nil
+	}
+	if lru.admission != nil {
+		lru.admission.Record(key)
 	}
 
 	if node, exists := lru.cache[key]; exists {
 		// 更新内存使用量
 		lru.memoryUsage = lru.memoryUsage - calculateMemoryUsage(key, node.value) + newMemory
-		
+
 		node.value = value
+		node.lastAccess = time.Now()
+		node.version = lru.nextVersion()
 		lru.moveToHead(node)
+		return node
+	}
+
+	now := time.Now()
+	newNode := &LRUNode{key: key, value: value, createdAt: now, lastAccess: now, version: lru.nextVersion()}
+	if useWindow {
+		lru.addToWindow(newNode)
 	} else {
 		// 检查内存限制和容量限制
-		for (lru.memoryLimit > 0 && lru.memoryUsage + newMemory > lru.memoryLimit && 
-			lru.size > 0 ) || lru.size >= lru.capacity {
-			if lru.size == 0 {
-				break
-			}
-			// 从链表删除
-			lastNode := lru.removeTail()
-			
-			oldMemory := calculateMemoryUsage(lastNode.key, lastNode.value)
-			lru.memoryUsage -= oldMemory
-			// 从哈希表删除
-			delete(lru.cache, lastNode.key)
-			lru.size --
+		for lru.needsEviction(newMemory) {
+			lru.evictOldest()
 		}
-		newNode := &LRUNode{key: key, value: value}
 		lru.addToHead(newNode)
-		lru.cache[key] = newNode
-		lru.memoryUsage += newMemory
-		lru.size ++
 	}
+	lru.cache[key] = newNode
+	lru.indexKey(newNode)
+	lru.memoryUsage += newMemory
+	lru.size++
+	if useWindow {
+		lru.admitFromWindow()
+	}
+	return newNode
+}
+
+// admitFromWindow 准入窗口超出容量时，把窗口中最久未使用的条目移入主缓存（调用方持有锁）
+// 缓存已满时由准入策略比较它与主缓存最久未使用条目的频率，频率不高于对方时淘汰它自己
+func (lru *LRUCache) admitFromWindow() {
+	for lru.windowSize > admissionWindowSize(lru.capacity) {
+		candidate := lru.windowTail.prev
+		lru.removeNode(candidate)
+		candidate.inWindow = false
+		if victim := lru.tail.prev; victim != lru.head && lru.overLimits() &&
+			!lru.admission.Admit(candidate.key, victim.key) {
+			lru.evictNode(candidate)
+			continue
+		}
+		lru.addToHead(candidate)
+	}
+	for lru.overLimits() {
+		lru.evictOldest()
+	}
+}
+
+// setTTL 设置key的过期时间，ttl <= 0 表示移除过期时间（调用方持有锁）
+func (lru *LRUCache) setTTL(key string, ttl time.Duration) {
+	if ttl <= 0 {
+		delete(lru.ttlMap, key)
+		return
+	}
+	if lru.ttlMap == nil {
+		lru.ttlMap = make(map[string]time.Time)
+	}
+	lru.ttlMap[key] = time.Now().Add(ttl)
+}
+
+// nextVersion 生成单调递增的版本号（纳秒时间戳，同一纳秒内递增）
+func (lru *LRUCache) nextVersion() uint64 {
+	version := uint64(time.Now().UnixNano())
+	if version <= lru.lastVersion {
+		version = lru.lastVersion + 1
+	}
+	lru.lastVersion = version
+	return version
+}
+
+// needsEviction 写入新条目前是否需要淘汰
+func (lru *LRUCache) needsEviction(newMemory int64) bool {
+	if lru.size == 0 {
+		return false
+	}
+	return lru.size >= lru.capacity ||
+		(lru.memoryLimit > 0 && lru.memoryUsage+newMemory > lru.memoryLimit)
+}
+
+// touch 记录一次读命中
+func (node *LRUNode) touch() {
+	node.lastAccess = time.Now()
+	node.accessCount++
 }
 
 // 添加统计的Get方法
@@ -256,27 +425,32 @@ func (lru *LRUCache) Get(key string) (string, bool) {
 	defer lru.mu.Unlock()
 
 	// 总请求数
-	lru.stats.TotalRequests ++
+	lru.stats.TotalRequests++
+	if lru.admission != nil {
+		lru.admission.Record(key)
+	}
 
 	node, exists := lru.cache[key]
 	if !exists {
-		lru.stats.Misses ++
+		lru.stats.Misses++
 		return "", false
 	}
 	// 检查是否过期
 	if expireTime, hasTTL := lru.ttlMap[key]; hasTTL {
 		if time.Now().After(expireTime) {
 			// 过期了，删除并返回未找到
-            lru.removeNode(node)
-            delete(lru.cache, key)
-            delete(lru.ttlMap, key)
-            lru.size--
-            lru.stats.Misses++
-            return "", false
+			lru.removeNode(node)
+			delete(lru.cache, key)
+			lru.unindexKey(node)
+			delete(lru.ttlMap, key)
+			lru.size--
+			lru.stats.Misses++
+			return "", false
 		}
 	}
 	// 命中
-	lru.stats.Hits ++
+	lru.stats.Hits++
+	node.touch()
 	lru.moveToHead(node)
 	return node.value, true
 }
@@ -293,9 +467,10 @@ func (lru *LRUCache) Delete(key string) bool {
 		lru.removeNode(targetNode)
 		// 从哈希表中删除
 		delete(lru.cache, key)
+		lru.unindexKey(targetNode)
 		// 清理TTL映射
 		delete(lru.ttlMap, key)
-		lru.size --
+		lru.size--
 		return true
 	}
 	return false
@@ -329,7 +504,7 @@ func (s *CacheStats) HitRate() float64 {
 func (lru *LRUCache) SetMulti(data map[string]string) {
 	lru.mu.Lock()
 	defer lru.mu.Unlock()
-	
+
 	for key, value := range data {
 		lru.SetInternal(key, value)
 	}
@@ -338,19 +513,23 @@ func (lru *LRUCache) SetMulti(data map[string]string) {
 func (lru *LRUCache) GetMulti(keys []string) map[string]string {
 	lru.mu.Lock()
 	defer lru.mu.Unlock()
-	
+
 	results := make(map[string]string)
-	
+
 	for _, key := range keys {
 		// 统计
-		lru.stats.TotalRequests ++
+		lru.stats.TotalRequests++
+		if lru.admission != nil {
+			lru.admission.Record(key)
+		}
 
 		if node, exists := lru.cache[key]; exists {
-			lru.stats.Hits ++
+			lru.stats.Hits++
+			node.touch()
 			lru.moveToHead(node)
 			results[key] = node.value
 		} else {
-			lru.stats.Misses ++
+			lru.stats.Misses++
 		}
 	}
 	return results
@@ -366,6 +545,7 @@ func (lru *LRUCache) DeleteMulti(keys []string) int {
 		if node, exists := lru.cache[key]; exists {
 			lru.removeNode(node)
 			delete(lru.cache, key)
+			lru.unindexKey(node)
 
 			// 更新内存使用量
 			lru.memoryUsage -= calculateMemoryUsage(key, node.value)
@@ -373,8 +553,8 @@ func (lru *LRUCache) DeleteMulti(keys []string) int {
 			// 清理TTL映射
 			delete(lru.ttlMap, key)
 
-			lru.size --
-			deletedCount ++
+			lru.size--
+			deletedCount++
 		}
 	}
 	return deletedCount
@@ -400,4 +580,288 @@ func (lru *LRUCache) GetAllData() map[string]string {
 	}
 
 	return result
-}
\ No newline at end of file
+}
+
+// ===== 运行时调整容量与内存限制 =====
+
+// resizeEvictBatch 缩容时每次持锁淘汰的最大条目数
+// 分批淘汰可以避免一次性长时间持有写锁阻塞读写请求
+const resizeEvictBatch = 256
+
+// Capacity 获取当前容量
+func (lru *LRUCache) Capacity() int {
+	lru.mu.RLock()
+	defer lru.mu.RUnlock()
+	return lru.capacity
+}
+
+// GetMemoryLimit 获取当前内存限制（0表示无限制）
+func (lru *LRUCache) GetMemoryLimit() int64 {
+	lru.mu.RLock()
+	defer lru.mu.RUnlock()
+	return lru.memoryLimit
+}
+
+// Resize 运行时调整容量，缩容时分批淘汰最久未使用的条目
+// 返回被淘汰的条目数
+func (lru *LRUCache) Resize(capacity int) (int, error) {
+	if capacity <= 0 {
+		return 0, fmt.Errorf("容量必须大于0: %d", capacity)
+	}
+
+	lru.mu.Lock()
+	lru.capacity = capacity
+	lru.mu.Unlock()
+
+	return lru.evictToLimits(), nil
+}
+
+// SetMemoryLimit 运行时调整内存限制（0表示无限制），缩小时分批淘汰
+// 返回被淘汰的条目数
+func (lru *LRUCache) SetMemoryLimit(memoryLimitBytes int64) (int, error) {
+	if memoryLimitBytes < 0 {
+		return 0, fmt.Errorf("内存限制不能为负数: %d", memoryLimitBytes)
+	}
+
+	lru.mu.Lock()
+	lru.memoryLimit = memoryLimitBytes
+	lru.mu.Unlock()
+
+	return lru.evictToLimits(), nil
+}
+
+// evictToLimits 分批淘汰，直到容量和内存都满足限制
+// 新限制在淘汰开始前已生效，期间的写入同样受新限制约束
+func (lru *LRUCache) evictToLimits() int {
+	evicted := 0
+	for {
+		lru.mu.Lock()
+		batch := 0
+		for batch < resizeEvictBatch && lru.overLimits() {
+			lru.evictOldest()
+			batch++
+		}
+		done := !lru.overLimits()
+		lru.mu.Unlock()
+
+		evicted += batch
+		if done {
+			return evicted
+		}
+	}
+}
+
+// overLimits 当前是否超出容量或内存限制（调用方持有锁）
+func (lru *LRUCache) overLimits() bool {
+	if lru.size == 0 {
+		return false
+	}
+	return lru.size > lru.capacity ||
+		(lru.memoryLimit > 0 && lru.memoryUsage > lru.memoryLimit)
+}
+
+// evictOldest 淘汰最久未使用的条目（调用方持有锁）
+func (lru *LRUCache) evictOldest() {
+	lru.evictNode(lru.removeTail())
+}
+
+// evictNode 删除已从链表摘除的节点（调用方持有锁）
+func (lru *LRUCache) evictNode(node *LRUNode) {
+	lru.memoryUsage -= calculateMemoryUsage(node.key, node.value)
+	delete(lru.cache, node.key)
+	lru.unindexKey(node)
+	delete(lru.ttlMap, node.key)
+	lru.size--
+}
+
+// ===== 单key元数据 =====
+
+// KeyMetadata 单个key的元数据（类似Redis OBJECT/DEBUG OBJECT）
+type KeyMetadata struct {
+	Key         string     `json:"key"`
+	Size        int64      `json:"size"`                 // 估算内存占用（字节）
+	CreatedAt   time.Time  `json:"created_at"`           // 首次写入时间
+	LastAccess  time.Time  `json:"last_access"`          // 最后访问时间
+	AccessCount int64      `json:"access_count"`         // 读命中次数
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"` // TTL截止时间，nil表示永不过期
+}
+
+// metadataOf 构造节点元数据（调用方持有锁）
+func (lru *LRUCache) metadataOf(node *LRUNode) KeyMetadata {
+	meta := KeyMetadata{
+		Key:         node.key,
+		Size:        calculateMemoryUsage(node.key, node.value),
+		CreatedAt:   node.createdAt,
+		LastAccess:  node.lastAccess,
+		AccessCount: node.accessCount,
+	}
+	if expireTime, hasTTL := lru.ttlMap[node.key]; hasTTL {
+		meta.ExpiresAt = &expireTime
+	}
+	return meta
+}
+
+// Inspect 获取key的元数据，不影响LRU顺序和访问统计
+func (lru *LRUCache) Inspect(key string) (KeyMetadata, bool) {
+	lru.mu.RLock()
+	defer lru.mu.RUnlock()
+
+	node, exists := lru.cache[key]
+	if !exists {
+		return KeyMetadata{}, false
+	}
+	if expireTime, hasTTL := lru.ttlMap[key]; hasTTL && time.Now().After(expireTime) {
+		return KeyMetadata{}, false
+	}
+	return lru.metadataOf(node), true
+}
+
+// LargestKeys 按内存占用返回最大的n个key的元数据（从大到小）
+func (lru *LRUCache) LargestKeys(n int) []KeyMetadata {
+	if n <= 0 {
+		return []KeyMetadata{}
+	}
+
+	lru.mu.RLock()
+	defer lru.mu.RUnlock()
+
+	// 维护大小为n的最小堆，遍历一次即可得到TopN
+	top := make(keySizeHeap, 0, n)
+	for _, node := range lru.cache {
+		size := calculateMemoryUsage(node.key, node.value)
+		if len(top) < n {
+			heap.Push(&top, keySizeEntry{node: node, size: size})
+		} else if size > top[0].size {
+			top[0] = keySizeEntry{node: node, size: size}
+			heap.Fix(&top, 0)
+		}
+	}
+
+	result := make([]KeyMetadata, len(top))
+	for i := len(top) - 1; i >= 0; i-- {
+		result[i] = lru.metadataOf(heap.Pop(&top).(keySizeEntry).node)
+	}
+	return result
+}
+
+// keySizeEntry / keySizeHeap 按大小排序的最小堆
+type keySizeEntry struct {
+	node *LRUNode
+	size int64
+}
+
+type keySizeHeap []keySizeEntry
+
+func (h keySizeHeap) Len() int            { return len(h) }
+func (h keySizeHeap) Less(i, j int) bool  { return h[i].size < h[j].size }
+func (h keySizeHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *keySizeHeap) Push(x interface{}) { *h = append(*h, x.(keySizeEntry)) }
+func (h *keySizeHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// ===== 原子读改写（用于 memcached/Redis 等协议的条件操作） =====
+
+// KeepTTL 在 Update 中表示保持原有过期时间
+const KeepTTL time.Duration = -1
+
+// DeleteTTL 在 Update 中表示写入时已经过期：删除key并留下删除标记
+const DeleteTTL time.Duration = -2
+
+// Entry 缓存条目的值及其附加信息
+type Entry struct {
+	Value   string
+	Flags   uint32 // 客户端自定义标志
+	Version uint64 // 每次写入都会变化，可用作CAS令牌
+}
+
+// UpdateFunc 根据当前条目计算新条目
+// 返回 write=false 时不做修改；ttl 为 KeepTTL 时保持原有过期时间，0 表示永不过期，DeleteTTL 表示删除key
+type UpdateFunc func(current Entry, found bool) (next Entry, ttl time.Duration, write bool)
+
+// liveNode 获取未过期的节点（调用方持有锁），过期节点会被顺带删除
+func (lru *LRUCache) liveNode(key string) (*LRUNode, bool) {
+	node, exists := lru.cache[key]
+	if !exists {
+		return nil, false
+	}
+	if expireTime, hasTTL := lru.ttlMap[key]; hasTTL && time.Now().After(expireTime) {
+		lru.memoryUsage -= calculateMemoryUsage(key, node.value)
+		lru.removeNode(node)
+		delete(lru.cache, key)
+		lru.unindexKey(node)
+		delete(lru.ttlMap, key)
+		lru.size--
+		return nil, false
+	}
+	return node, true
+}
+
+// GetEntry 获取条目（计入访问统计并更新LRU顺序）
+func (lru *LRUCache) GetEntry(key string) (Entry, bool) {
+	lru.mu.Lock()
+	defer lru.mu.Unlock()
+
+	lru.stats.TotalRequests++
+	if lru.admission != nil {
+		lru.admission.Record(key)
+	}
+
+	node, found := lru.liveNode(key)
+	if !found {
+		lru.stats.Misses++
+		return Entry{}, false
+	}
+	lru.stats.Hits++
+	node.touch()
+	lru.moveToHead(node)
+	return Entry{Value: node.value, Flags: node.flags, Version: node.version}, true
+}
+
+// Update 在写锁内执行读-改-写，保证条件操作的原子性
+// 返回操作后的条目以及是否发生写入
+func (lru *LRUCache) Update(key string, fn UpdateFunc) (Entry, bool) {
+	lru.mu.Lock()
+	defer lru.mu.Unlock()
+
+	var current Entry
+	node, found := lru.liveNode(key)
+	if found {
+		current = Entry{Value: node.value, Flags: node.flags, Version: node.version}
+	}
+
+	next, ttl, write := fn(current, found)
+	if !write {
+		return current, false
+	}
+	if ttl == DeleteTTL {
+		tombstone := VersionedEntry{Version: lru.nextVersion(), Tombstone: true}
+		lru.setVersioned(key, tombstone, false)
+		return Entry{Version: tombstone.Version}, true
+	}
+
+	node = lru.setNode(key, next.Value)
+	if node == nil {
+		return current, false
+	}
+	node.flags = next.Flags
+	if ttl != KeepTTL {
+		lru.setTTL(key, ttl)
+	}
+	return Entry{Value: node.value, Flags: node.flags, Version: node.version}, true
+}
+
+// Expire 修改已存在key的过期时间，ttl <= 0 表示永不过期
+func (lru *LRUCache) Expire(key string, ttl time.Duration) bool {
+	lru.mu.Lock()
+	defer lru.mu.Unlock()
+
+	if _, found := lru.liveNode(key); !found {
+		return false
+	}
+	lru.setTTL(key, ttl)
+	return true
+}
@@ -0,0 +1,117 @@
+package core
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+)
+
+// Merkle树 - 按key哈希值把哈希空间等分为若干区间，每个叶子汇总一个区间内所有key的版本，
+// 父节点为两个子节点的哈希。两个副本对比根节点即可判断数据是否一致，不一致时逐层向下
+// 找到不同的叶子，只需交换这些哈希区间内的key。
+
+// DefaultMerkleLeaves 默认叶子数（哈希空间分为1024个区间）
+const DefaultMerkleLeaves = 1024
+
+// MerkleTree 完全二叉树，Nodes[1]为根，节点i的子节点为2i和2i+1，叶子为 Nodes[leaves:]
+type MerkleTree struct {
+	Nodes []uint64 `json:"nodes"`
+}
+
+// NewMerkleTree 创建空树，叶子数向上取整为2的幂
+func NewMerkleTree(leaves int) *MerkleTree {
+	size := 1
+	for size < leaves {
+		size <<= 1
+	}
+	return &MerkleTree{Nodes: make([]uint64, 2*size)}
+}
+
+// Leaves 叶子数
+func (t *MerkleTree) Leaves() int {
+	return len(t.Nodes) / 2
+}
+
+// LeafFor 哈希值所在的叶子编号
+func (t *MerkleTree) LeafFor(hash uint32) int {
+	return int(uint64(hash) * uint64(t.Leaves()) >> 32)
+}
+
+// LeafRange 叶子覆盖的哈希区间 [start, end]
+func (t *MerkleTree) LeafRange(leaf int) (uint32, uint32) {
+	width := (uint64(1) << 32) / uint64(t.Leaves())
+	start := uint64(leaf) * width
+	return uint32(start), uint32(start + width - 1)
+}
+
+// Add 把一个条目计入所在叶子，叶子值为区间内条目摘要之和，与加入顺序无关
+func (t *MerkleTree) Add(hash uint32, key string, entry VersionedEntry) {
+	t.Nodes[t.Leaves()+t.LeafFor(hash)] += entryDigest(key, entry)
+}
+
+// Build 叶子加入完成后计算内部节点
+func (t *MerkleTree) Build() {
+	for i := t.Leaves() - 1; i >= 1; i-- {
+		t.Nodes[i] = combineDigests(t.Nodes[2*i], t.Nodes[2*i+1])
+	}
+}
+
+// Root 根节点哈希
+func (t *MerkleTree) Root() uint64 {
+	if len(t.Nodes) < 2 {
+		return 0
+	}
+	return t.Nodes[1]
+}
+
+// Diff 从根节点向下对比，返回不同的叶子编号；两棵树的叶子数不同时返回所有叶子
+func (t *MerkleTree) Diff(other *MerkleTree) []int {
+	if other == nil || len(other.Nodes) != len(t.Nodes) {
+		leaves := make([]int, t.Leaves())
+		for i := range leaves {
+			leaves[i] = i
+		}
+		return leaves
+	}
+
+	var diff []int
+	var walk func(i int)
+	walk = func(i int) {
+		if t.Nodes[i] == other.Nodes[i] {
+			return
+		}
+		if i >= t.Leaves() {
+			diff = append(diff, i-t.Leaves())
+			return
+		}
+		walk(2 * i)
+		walk(2*i + 1)
+	}
+	walk(1)
+	return diff
+}
+
+// entryDigest 单个条目的摘要（FNV-1a 64位）
+func entryDigest(key string, entry VersionedEntry) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	var buf [9]byte
+	binary.BigEndian.PutUint64(buf[:8], entry.Version)
+	if entry.Tombstone {
+		buf[8] = 1
+	}
+	h.Write(buf[:])
+	return h.Sum64()
+}
+
+// combineDigests 父节点哈希，两个子树都为空时仍为0
+func combineDigests(left, right uint64) uint64 {
+	if left == 0 && right == 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	var buf [16]byte
+	binary.BigEndian.PutUint64(buf[:8], left)
+	binary.BigEndian.PutUint64(buf[8:], right)
+	h.Write(buf[:])
+	return h.Sum64()
+}
@@ -0,0 +1,122 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// 节点权重 - 机器配置不同时按权重分配数据
+// 哈希环的虚拟节点按 "node#i" 编号，调整权重只增加或减少编号靠后的虚拟节点，
+// 其余虚拟节点的位置不变，因此只有这些虚拟节点覆盖的区间需要迁移。
+// Rendezvous/Maglev 按权重计算分数或查找表份额，Jump 不支持权重。
+
+// DefaultNodeWeight 未配置权重时的默认值
+const DefaultNodeWeight = 1.0
+
+// GetNodeWeight 获取节点权重
+func (dc *DistributedCache) GetNodeWeight(node string) float64 {
+	dc.Mu.RLock()
+	defer dc.Mu.RUnlock()
+
+	return dc.nodeWeight(node)
+}
+
+// GetVirtualNodeCount 获取节点的虚拟节点数量
+func (dc *DistributedCache) GetVirtualNodeCount(node string) int {
+	dc.Mu.RLock()
+	defer dc.Mu.RUnlock()
+
+	return dc.virtualNodeCount(node)
+}
+
+// SetNodeWeight 设置节点权重并迁移归属变化的数据，返回迁移的key数量
+// 节点尚未加入时只记录权重，加入时生效
+func (dc *DistributedCache) SetNodeWeight(node string, weight float64) (int, error) {
+	dc.Mu.Lock()
+	defer dc.Mu.Unlock()
+
+	weighted, err := dc.weightedPlacement(weight)
+	if err != nil || weighted == nil {
+		return 0, err
+	}
+
+	if dc.Weights == nil {
+		dc.Weights = make(map[string]float64)
+	}
+	oldWeight := dc.nodeWeight(node)
+	dc.Weights[node] = weight
+
+	// 哈希环的节点尚未加入时只记录权重，加入时按权重创建虚拟节点
+	if dc.Placement == nil && (!dc.hasNode(node) || dc.virtualNodeCount(node) == dc.virtualNodeCountFor(oldWeight)) {
+		return 0, nil
+	}
+
+	startTime := time.Now()
+
+	// 调整该节点的权重，其他节点的放置不变；只有归属发生变化的key需要迁移
+	migratedCount := dc.migrateAfter(func() {
+		weighted.SetNodeWeight(node, weight)
+	})
+	dc.bumpEpoch()
+
+	dc.updateBasicMigrationStats(migratedCount, time.Since(startTime))
+	return migratedCount, nil
+}
+
+// ValidateNodeWeight 检查权重能否设置，通过检查的权重 SetNodeWeight 不会返回错误
+func (dc *DistributedCache) ValidateNodeWeight(weight float64) error {
+	dc.Mu.RLock()
+	defer dc.Mu.RUnlock()
+
+	_, err := dc.weightedPlacement(weight)
+	return err
+}
+
+// weightedPlacement 检查权重为正数且放置算法支持节点权重（调用方持有锁）
+// 不支持权重的放置算法上设置默认权重不改变放置，返回nil
+func (dc *DistributedCache) weightedPlacement(weight float64) (WeightedPlacement, error) {
+	if weight <= 0 || math.IsNaN(weight) || math.IsInf(weight, 0) {
+		return nil, fmt.Errorf("节点权重必须为正数: %v", weight)
+	}
+	weighted, ok := dc.placement().(WeightedPlacement)
+	if !ok && weight == DefaultNodeWeight {
+		return nil, nil
+	}
+	if !ok {
+		return nil, fmt.Errorf("放置算法 %s 不支持节点权重", dc.placement().Name())
+	}
+	return weighted, nil
+}
+
+// nodeWeight 不加锁的权重查询
+func (dc *DistributedCache) nodeWeight(node string) float64 {
+	if weight, ok := dc.Weights[node]; ok && weight > 0 {
+		return weight
+	}
+	return DefaultNodeWeight
+}
+
+// virtualNodeCount 按权重计算虚拟节点数量，至少为1
+func (dc *DistributedCache) virtualNodeCount(node string) int {
+	return dc.virtualNodeCountFor(dc.nodeWeight(node))
+}
+
+// virtualNodeCountFor 指定权重对应的虚拟节点数量
+func (dc *DistributedCache) virtualNodeCountFor(weight float64) int {
+	count := int(math.Round(float64(dc.VirtualNodes) * weight))
+	if count < 1 {
+		count = 1
+	}
+	return count
+}
+
+// hasNode 节点是否在哈希环中
+func (dc *DistributedCache) hasNode(node string) bool {
+	for _, n := range dc.Nodes {
+		if n == node {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,405 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Placement 数据放置算法 - 决定key由哪个节点负责
+// 由 DistributedCache 在持有锁的情况下调用，实现本身不需要并发安全
+type Placement interface {
+	Name() string
+	GetNodeForKey(key string) string
+	AddNode(node string)
+	RemoveNode(node string)
+}
+
+// WeightedPlacement 支持节点权重的放置算法
+type WeightedPlacement interface {
+	Placement
+	SetNodeWeight(node string, weight float64)
+}
+
+// 放置算法名称
+const (
+	PlacementRing       = "ring"       // 虚拟节点一致性哈希环（默认）
+	PlacementRendezvous = "rendezvous" // 最高随机权重（HRW）哈希
+	PlacementJump       = "jump"       // Jump一致性哈希
+	PlacementMaglev     = "maglev"     // Maglev查找表
+)
+
+// PlacementNames 支持的放置算法名称
+var PlacementNames = []string{PlacementRing, PlacementRendezvous, PlacementJump, PlacementMaglev}
+
+// NormalizePlacementName 规范化放置算法名称，未配置（旧版本节点）等同于哈希环
+func NormalizePlacementName(name string) string {
+	if name == "" {
+		return PlacementRing
+	}
+	return name
+}
+
+// NewPlacement 创建独立的放置算法（哈希环依赖 DistributedCache 的虚拟节点数据，
+// 通过 NewDistributedCacheWithPlacement 创建）
+func NewPlacement(name string, hasher Hasher) (Placement, error) {
+	if hasher == nil {
+		hasher = sha1Hasher{}
+	}
+	switch name {
+	case PlacementRendezvous:
+		return NewRendezvousPlacement(hasher), nil
+	case PlacementJump:
+		return NewJumpPlacement(hasher), nil
+	case PlacementMaglev:
+		return NewMaglevPlacement(hasher, DefaultMaglevTableSize), nil
+	default:
+		return nil, fmt.Errorf("未知的放置算法: %s", name)
+	}
+}
+
+// NewDistributedCacheWithPlacement 创建使用指定放置算法的分布式缓存
+// name 为空或 "ring" 时等同于 NewDistributedCacheWithHasher
+func NewDistributedCacheWithPlacement(nodes []string, virtualNodeCount int, hasher Hasher, name string) (*DistributedCache, error) {
+	if NormalizePlacementName(name) == PlacementRing {
+		return NewDistributedCacheWithHasher(nodes, virtualNodeCount, hasher), nil
+	}
+
+	placement, err := NewPlacement(name, hasher)
+	if err != nil {
+		return nil, err
+	}
+
+	if virtualNodeCount <= 0 {
+		virtualNodeCount = 150
+	}
+	dc := &DistributedCache{
+		Nodes:        nodes,
+		VirtualNodes: virtualNodeCount,
+		LocalCaches:  make(map[string]*LRUCache),
+		Hasher:       hasher,
+		Placement:    placement,
+		HashRing:     make(map[uint32]string),
+		SortedHashes: make([]uint32, 0),
+	}
+
+	// 按节点名排序后加入，保证所有节点构建出相同的放置结果（jump依赖加入顺序）
+	sorted := append([]string(nil), nodes...)
+	sort.Strings(sorted)
+	for _, node := range sorted {
+		placement.AddNode(node)
+		dc.LocalCaches[node] = dc.newLocalCache()
+	}
+
+	return dc, nil
+}
+
+// PlacementName 获取使用的放置算法名称
+func (dc *DistributedCache) PlacementName() string {
+	return dc.placement().Name()
+}
+
+// placement 当前放置算法，未设置时使用虚拟节点哈希环
+func (dc *DistributedCache) placement() Placement {
+	if dc.Placement != nil {
+		return dc.Placement
+	}
+	return ringPlacement{dc: dc}
+}
+
+// migrateChangedOwnership 对比所有本地缓存中key的新旧负责节点，迁移归属发生变化的key
+// 与具体放置算法无关，调用方需持有写锁
+func (dc *DistributedCache) migrateChangedOwnership() int {
+	migratedCount := 0
+	for owner, cache := range dc.LocalCaches {
+		for key, value := range cache.GetAllData() {
+			target := dc.getNodeForKeyUnsafe(key)
+			if target == owner {
+				continue
+			}
+			if targetCache := dc.LocalCaches[target]; targetCache != nil {
+				targetCache.Set(key, value)
+				cache.Delete(key)
+				migratedCount++
+			}
+		}
+	}
+	return migratedCount
+}
+
+// ===== 哈希环 =====
+
+// ringPlacement 虚拟节点哈希环，数据保存在 DistributedCache 的 HashRing/SortedHashes 中
+type ringPlacement struct {
+	dc *DistributedCache
+}
+
+func (r ringPlacement) Name() string { return PlacementRing }
+
+func (r ringPlacement) GetNodeForKey(key string) string {
+	return r.dc.ringLookup(key)
+}
+
+func (r ringPlacement) AddNode(node string) {
+	r.dc.addNodeToHashRing(node)
+}
+
+func (r ringPlacement) RemoveNode(node string) {
+	r.dc.removeNodeFromHashRing(node)
+}
+
+// SetNodeWeight 权重已记录在 dc.Weights 中，重建该节点的虚拟节点
+func (r ringPlacement) SetNodeWeight(node string, weight float64) {
+	r.dc.removeNodeFromHashRing(node)
+	r.dc.addNodeToHashRing(node)
+}
+
+// ===== Rendezvous =====
+
+// RendezvousPlacement 最高随机权重哈希：对每个节点计算 (key, node) 的分数，分数最高者负责
+// 增删节点只影响该节点负责的key，查找复杂度 O(节点数)
+type RendezvousPlacement struct {
+	hasher  Hasher
+	nodes   []string
+	seeds   []uint64 // 节点名哈希，避免每次查找重复计算
+	weights map[string]float64
+}
+
+// NewRendezvousPlacement 创建Rendezvous放置算法
+func NewRendezvousPlacement(hasher Hasher) *RendezvousPlacement {
+	return &RendezvousPlacement{hasher: hasher, weights: make(map[string]float64)}
+}
+
+func (p *RendezvousPlacement) Name() string { return PlacementRendezvous }
+
+func (p *RendezvousPlacement) GetNodeForKey(key string) string {
+	keyHash := uint64(p.hasher.Hash(key)) << 32
+
+	// 未设置权重时直接比较哈希值，避免每个节点计算一次对数
+	if len(p.weights) == 0 {
+		best, bestScore := "", uint64(0)
+		for i, node := range p.nodes {
+			score := mix64(keyHash | p.seeds[i])
+			if best == "" || score > bestScore || (score == bestScore && node < best) {
+				best, bestScore = node, score
+			}
+		}
+		return best
+	}
+
+	best, bestScore := "", math.Inf(-1)
+	for i, node := range p.nodes {
+		// 映射到 (0,1) 后按 -w/ln(x) 计算加权分数
+		x := (float64(mix64(keyHash|p.seeds[i])>>11) + 0.5) / (1 << 53)
+		score := -p.weight(node) / math.Log(x)
+		if score > bestScore || (score == bestScore && node < best) {
+			best, bestScore = node, score
+		}
+	}
+	return best
+}
+
+func (p *RendezvousPlacement) AddNode(node string) {
+	for _, n := range p.nodes {
+		if n == node {
+			return
+		}
+	}
+	p.nodes = append(p.nodes, node)
+	p.seeds = append(p.seeds, uint64(p.hasher.Hash(node)))
+}
+
+func (p *RendezvousPlacement) RemoveNode(node string) {
+	for i, n := range p.nodes {
+		if n == node {
+			p.nodes = append(p.nodes[:i], p.nodes[i+1:]...)
+			p.seeds = append(p.seeds[:i], p.seeds[i+1:]...)
+			return
+		}
+	}
+}
+
+func (p *RendezvousPlacement) SetNodeWeight(node string, weight float64) {
+	p.weights[node] = weight
+}
+
+func (p *RendezvousPlacement) weight(node string) float64 {
+	if weight, ok := p.weights[node]; ok && weight > 0 {
+		return weight
+	}
+	return DefaultNodeWeight
+}
+
+// ===== Jump =====
+
+// JumpPlacement Jump一致性哈希：O(ln n) 时间、无额外内存，节点编号为加入顺序
+// 只有在末尾增删节点时迁移量最小；删除中间节点时用最后一个节点补位，
+// 额外迁移最后一个节点的数据。不支持权重。
+type JumpPlacement struct {
+	hasher Hasher
+	nodes  []string
+}
+
+// NewJumpPlacement 创建Jump放置算法
+func NewJumpPlacement(hasher Hasher) *JumpPlacement {
+	return &JumpPlacement{hasher: hasher}
+}
+
+func (p *JumpPlacement) Name() string { return PlacementJump }
+
+func (p *JumpPlacement) GetNodeForKey(key string) string {
+	if len(p.nodes) == 0 {
+		return ""
+	}
+	return p.nodes[jumpHash(mix64(uint64(p.hasher.Hash(key))), len(p.nodes))]
+}
+
+func (p *JumpPlacement) AddNode(node string) {
+	for _, n := range p.nodes {
+		if n == node {
+			return
+		}
+	}
+	p.nodes = append(p.nodes, node)
+}
+
+func (p *JumpPlacement) RemoveNode(node string) {
+	last := len(p.nodes) - 1
+	for i, n := range p.nodes {
+		if n == node {
+			p.nodes[i] = p.nodes[last]
+			p.nodes = p.nodes[:last]
+			return
+		}
+	}
+}
+
+// jumpHash Lamping & Veach, "A Fast, Minimal Memory, Consistent Hash Algorithm"
+func jumpHash(key uint64, buckets int) int {
+	var b, j int64 = -1, 0
+	for j < int64(buckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
+
+// ===== Maglev =====
+
+// DefaultMaglevTableSize 默认查找表大小（质数，远大于节点数）
+const DefaultMaglevTableSize = 65537
+
+// MaglevPlacement Maglev哈希：每个节点按自己的排列轮流填充查找表，O(1) 查找，
+// 各节点负责的槽位数几乎完全均匀；增删节点时其他节点之间有少量槽位变化
+type MaglevPlacement struct {
+	hasher  Hasher
+	size    uint64
+	nodes   []string // 按名称排序，保证所有节点构建出相同的查找表
+	weights map[string]float64
+	table   []int32
+}
+
+// NewMaglevPlacement 创建Maglev放置算法，tableSize 应为质数
+func NewMaglevPlacement(hasher Hasher, tableSize int) *MaglevPlacement {
+	if tableSize <= 1 {
+		tableSize = DefaultMaglevTableSize
+	}
+	return &MaglevPlacement{hasher: hasher, size: uint64(tableSize), weights: make(map[string]float64)}
+}
+
+func (p *MaglevPlacement) Name() string { return PlacementMaglev }
+
+func (p *MaglevPlacement) GetNodeForKey(key string) string {
+	if len(p.table) == 0 {
+		return ""
+	}
+	return p.nodes[p.table[uint64(p.hasher.Hash(key))%p.size]]
+}
+
+func (p *MaglevPlacement) AddNode(node string) {
+	idx := sort.SearchStrings(p.nodes, node)
+	if idx < len(p.nodes) && p.nodes[idx] == node {
+		return
+	}
+	p.nodes = append(p.nodes, "")
+	copy(p.nodes[idx+1:], p.nodes[idx:])
+	p.nodes[idx] = node
+	p.rebuild()
+}
+
+func (p *MaglevPlacement) RemoveNode(node string) {
+	idx := sort.SearchStrings(p.nodes, node)
+	if idx < len(p.nodes) && p.nodes[idx] == node {
+		p.nodes = append(p.nodes[:idx], p.nodes[idx+1:]...)
+		p.rebuild()
+	}
+}
+
+func (p *MaglevPlacement) SetNodeWeight(node string, weight float64) {
+	p.weights[node] = weight
+	p.rebuild()
+}
+
+// rebuild 重新填充查找表，权重高的节点每轮获得更多填充机会
+func (p *MaglevPlacement) rebuild() {
+	n := len(p.nodes)
+	if n == 0 {
+		p.table = nil
+		return
+	}
+
+	offsets := make([]uint64, n)
+	skips := make([]uint64, n)
+	shares := make([]float64, n)
+	maxWeight := 0.0
+	for i, node := range p.nodes {
+		h := mix64(uint64(p.hasher.Hash(node)))
+		offsets[i] = (h >> 32) % p.size
+		skips[i] = (h&0xffffffff)%(p.size-1) + 1
+		shares[i] = DefaultNodeWeight
+		if weight, ok := p.weights[node]; ok && weight > 0 {
+			shares[i] = weight
+		}
+		maxWeight = math.Max(maxWeight, shares[i])
+	}
+
+	table := make([]int32, p.size)
+	for i := range table {
+		table[i] = -1
+	}
+	next := make([]uint64, n)
+	credits := make([]float64, n)
+
+	filled := uint64(0)
+	for filled < p.size {
+		for i := 0; i < n && filled < p.size; i++ {
+			credits[i] += shares[i] / maxWeight
+			if credits[i] < 1 {
+				continue
+			}
+			credits[i]--
+
+			// 沿该节点的排列找到第一个空槽
+			slot := (offsets[i] + next[i]*skips[i]) % p.size
+			for table[slot] >= 0 {
+				next[i]++
+				slot = (offsets[i] + next[i]*skips[i]) % p.size
+			}
+			table[slot] = int32(i)
+			next[i]++
+			filled++
+		}
+	}
+	p.table = table
+}
+
+// mix64 SplitMix64 终结函数，把32位哈希扩展为分布均匀的64位值
+func mix64(z uint64) uint64 {
+	z ^= z >> 30
+	z *= 0xbf58476d1ce4e5b9
+	z ^= z >> 27
+	z *= 0x94d049bb133111eb
+	z ^= z >> 31
+	return z
+}
@@ -0,0 +1,227 @@
+package core
+
+import "sort"
+
+// 按区间迁移 - 对比变更前后的哈希环，只迁移归属发生变化的哈希区间内的key
+// 区间计算只与虚拟节点数量有关，借助本地缓存的哈希索引，迁移开销与迁移的key数量成正比。
+// 非哈希环放置算法或开启有界负载时，归属不由区间决定，仍对比所有key的新旧归属。
+
+// RingSnapshot 哈希环快照，按哈希值排序的虚拟节点及其所属节点
+type RingSnapshot struct {
+	hashes []uint32
+	owners []string
+}
+
+// HashRange 归属发生变化的哈希区间 (Start, End]，Start >= End 时区间跨过0点
+type HashRange struct {
+	Start uint32 `json:"start"`
+	End   uint32 `json:"end"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// SnapshotRing 获取哈希环快照，归属不能按区间计算时返回false
+func (dc *DistributedCache) SnapshotRing() (RingSnapshot, bool) {
+	dc.Mu.RLock()
+	defer dc.Mu.RUnlock()
+
+	if !dc.rangeMigratable() {
+		return RingSnapshot{}, false
+	}
+	return dc.snapshotRing(), true
+}
+
+// rangeMigratable 当前放置方式下key的归属是否只由哈希区间决定
+func (dc *DistributedCache) rangeMigratable() bool {
+	return dc.Placement == nil && dc.BoundedLoad == nil
+}
+
+// snapshotRing 不加锁的内部版本
+func (dc *DistributedCache) snapshotRing() RingSnapshot {
+	snapshot := RingSnapshot{
+		hashes: append([]uint32(nil), dc.SortedHashes...),
+		owners: make([]string, len(dc.SortedHashes)),
+	}
+	for i, hash := range snapshot.hashes {
+		snapshot.owners[i] = dc.HashRing[hash]
+	}
+	return snapshot
+}
+
+// ownerAt 哈希值所在区间的负责节点，空环返回空字符串
+func (s RingSnapshot) ownerAt(hash uint32) string {
+	if len(s.hashes) == 0 {
+		return ""
+	}
+	idx := sort.Search(len(s.hashes), func(i int) bool {
+		return s.hashes[i] >= hash
+	})
+	if idx == len(s.hashes) {
+		idx = 0
+	}
+	return s.owners[idx]
+}
+
+// ChangedRanges 计算两个快照之间归属发生变化的哈希区间，相邻且迁移方向相同的区间会被合并
+func ChangedRanges(before, after RingSnapshot) []HashRange {
+	// 两个环的虚拟节点合在一起把环切成若干小区间，每个小区间在新旧环上各只有一个负责节点
+	points := make([]uint32, 0, len(before.hashes)+len(after.hashes))
+	points = append(points, before.hashes...)
+	points = append(points, after.hashes...)
+	sort.Slice(points, func(i, j int) bool { return points[i] < points[j] })
+
+	var ranges []HashRange
+	prev := -1
+	for i, end := range points {
+		if i > 0 && end == points[i-1] {
+			continue
+		}
+		start := points[len(points)-1]
+		if prev >= 0 {
+			start = points[prev]
+		}
+		prev = i
+
+		from, to := before.ownerAt(end), after.ownerAt(end)
+		if from == to || from == "" || to == "" {
+			continue
+		}
+		if n := len(ranges); n > 0 && ranges[n-1].End == start && ranges[n-1].From == from && ranges[n-1].To == to {
+			ranges[n-1].End = end
+			continue
+		}
+		ranges = append(ranges, HashRange{Start: start, End: end, From: from, To: to})
+	}
+	return ranges
+}
+
+// migrateAfter 执行放置变更并迁移归属发生变化的数据，调用方需持有写锁
+func (dc *DistributedCache) migrateAfter(change func()) int {
+	if !dc.rangeMigratable() {
+		change()
+		return dc.migrateChangedOwnership()
+	}
+
+	before := dc.snapshotRing()
+	change()
+	return dc.migrateRanges(ChangedRanges(before, dc.snapshotRing()))
+}
+
+// migrateRanges 把每个区间内的数据从原负责节点移动到新负责节点，调用方需持有写锁
+func (dc *DistributedCache) migrateRanges(ranges []HashRange) int {
+	migratedCount := 0
+	for _, r := range ranges {
+		source, target := dc.LocalCaches[r.From], dc.LocalCaches[r.To]
+		if source == nil || target == nil {
+			continue
+		}
+		for key, value := range source.GetDataInHashRange(r.Start, r.End) {
+			target.Set(key, value)
+			source.Delete(key)
+			migratedCount++
+		}
+	}
+	return migratedCount
+}
+
+// newLocalCache 创建节点本地缓存，启用哈希索引以支持按区间迁移
+func (dc *DistributedCache) newLocalCache() *LRUCache {
+	cache := NewLRUCache(1000)
+	cache.EnableHashIndex(dc.hashKey)
+	return cache
+}
+
+// ReplicaRange 副本集合发生变化的哈希区间，Before/After 为变更前后负责该区间的副本节点，第一个为主节点
+type ReplicaRange struct {
+	HashInterval
+	Before []string `json:"before"`
+	After  []string `json:"after"`
+}
+
+// ChangedReplicaRanges 计算两个快照之间n副本集合发生变化的哈希区间，相邻且变化相同的区间会被合并
+// 副本只比较集合，主副本顺序变化不需要迁移数据；位置约束按当前的节点位置计算
+func (dc *DistributedCache) ChangedReplicaRanges(before, after RingSnapshot, n int) []ReplicaRange {
+	dc.Mu.RLock()
+	defer dc.Mu.RUnlock()
+
+	levels := dc.replicaDomainLevels()
+
+	points := make([]uint32, 0, len(before.hashes)+len(after.hashes))
+	points = append(points, before.hashes...)
+	points = append(points, after.hashes...)
+	sort.Slice(points, func(i, j int) bool { return points[i] < points[j] })
+
+	var ranges []ReplicaRange
+	prev := -1
+	for i, end := range points {
+		if i > 0 && end == points[i-1] {
+			continue
+		}
+		start := points[len(points)-1]
+		if prev >= 0 {
+			start = points[prev]
+		}
+		prev = i
+
+		from, to := before.replicasAt(end, n, levels), after.replicasAt(end, n, levels)
+		if len(from) == 0 || len(to) == 0 || sameNodes(from, to) {
+			continue
+		}
+		if last := len(ranges) - 1; last >= 0 && ranges[last].End == start &&
+			sameNodes(ranges[last].Before, from) && sameNodes(ranges[last].After, to) {
+			ranges[last].End = end
+			continue
+		}
+		ranges = append(ranges, ReplicaRange{HashInterval: HashInterval{Start: start, End: end}, Before: from, After: to})
+	}
+	return ranges
+}
+
+// replicasAt 哈希值所在区间的n个副本节点，与 GetNodesForKey 在哈希环上的选择一致
+func (s RingSnapshot) replicasAt(hash uint32, n int, levels []func(node string) string) []string {
+	if len(s.hashes) == 0 || n <= 0 {
+		return nil
+	}
+	idx := sort.Search(len(s.hashes), func(i int) bool {
+		return s.hashes[i] >= hash
+	})
+
+	// 有位置约束时需要全部候选以便跳过同区节点
+	var candidates []string
+	visited := make(map[string]bool)
+	for i := 0; i < len(s.hashes); i++ {
+		node := s.owners[(idx+i)%len(s.hashes)]
+		if visited[node] {
+			continue
+		}
+		visited[node] = true
+		candidates = append(candidates, node)
+		if len(levels) == 0 && len(candidates) == n {
+			break
+		}
+	}
+	if n > len(candidates) {
+		n = len(candidates)
+	}
+	return selectReplicas(candidates, n, levels)
+}
+
+// sameNodes 两组节点是否相同（不考虑顺序）
+func sameNodes(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, x := range a {
+		found := false
+		for _, y := range b {
+			if x == y {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
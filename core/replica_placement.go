@@ -0,0 +1,271 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// 多副本放置 - 为key选出n个不同的物理节点
+// 第一个节点始终是 GetNodeForKey 返回的负责节点，其余节点按放置算法的优先顺序选取：
+// 哈希环沿顺时针方向，Rendezvous 按分数从高到低，其他算法按 (key, node) 哈希排序。
+// 设置了位置约束时，优先选择与已选副本不在同一可用区/机架的节点，候选不足时再放宽约束。
+
+// 副本位置约束
+const (
+	ReplicaConstraintNone = "none" // 不考虑位置
+	ReplicaConstraintZone = "zone" // 副本尽量分布在不同可用区，其次不同机架
+	ReplicaConstraintRack = "rack" // 副本尽量分布在不同机架
+)
+
+// ReplicaConstraints 支持的副本位置约束
+var ReplicaConstraints = []string{ReplicaConstraintNone, ReplicaConstraintZone, ReplicaConstraintRack}
+
+// NodeLocation 节点所在的可用区和机架，未设置的节点不与任何节点冲突
+type NodeLocation struct {
+	Zone string `json:"zone,omitempty" yaml:"zone"`
+	Rack string `json:"rack,omitempty" yaml:"rack"`
+}
+
+// candidateWalker 能按优先顺序遍历key候选节点的放置算法，visit返回false时停止
+type candidateWalker interface {
+	walkCandidates(key string, visit func(node string) bool)
+}
+
+// SetNodeLocation 设置节点的可用区和机架
+func (dc *DistributedCache) SetNodeLocation(node string, location NodeLocation) {
+	dc.Mu.Lock()
+	defer dc.Mu.Unlock()
+
+	if dc.Locations == nil {
+		dc.Locations = make(map[string]NodeLocation)
+	}
+	dc.Locations[node] = location
+}
+
+// GetNodeLocation 获取节点的可用区和机架
+func (dc *DistributedCache) GetNodeLocation(node string) NodeLocation {
+	dc.Mu.RLock()
+	defer dc.Mu.RUnlock()
+
+	return dc.Locations[node]
+}
+
+// maxLocationLength 可用区和机架名称的最大长度
+const maxLocationLength = 64
+
+// ValidateNodeLocation 检查可用区和机架名称：不超过64字节，不含'/'和空白字符（'/'用于拼接机架所在的位置域）
+func ValidateNodeLocation(location NodeLocation) error {
+	for _, name := range []string{location.Zone, location.Rack} {
+		if len(name) > maxLocationLength || strings.ContainsAny(name, "/ \t\r\n") {
+			return fmt.Errorf("可用区或机架名称无效: %q", name)
+		}
+	}
+	return nil
+}
+
+// NormalizeReplicaConstraint 规范化副本位置约束，空字符串表示不考虑位置
+func NormalizeReplicaConstraint(constraint string) (string, error) {
+	if constraint == "" {
+		return ReplicaConstraintNone, nil
+	}
+	for _, c := range ReplicaConstraints {
+		if c == constraint {
+			return constraint, nil
+		}
+	}
+	return "", fmt.Errorf("未知的副本位置约束: %s (支持: %v)", constraint, ReplicaConstraints)
+}
+
+// SetReplicaConstraint 设置副本位置约束，空字符串表示不考虑位置
+func (dc *DistributedCache) SetReplicaConstraint(constraint string) error {
+	constraint, err := NormalizeReplicaConstraint(constraint)
+	if err != nil {
+		return err
+	}
+
+	dc.Mu.Lock()
+	defer dc.Mu.Unlock()
+
+	dc.ReplicaConstraint = constraint
+	return nil
+}
+
+// GetNodesForKey 获取负责key的n个不同物理节点，第一个为主节点
+// 节点数不足n时返回全部节点
+func (dc *DistributedCache) GetNodesForKey(key string, n int) []string {
+	dc.Mu.RLock()
+	defer dc.Mu.RUnlock()
+
+	return dc.getNodesForKeyUnsafe(key, n)
+}
+
+// getNodesForKeyUnsafe 不加锁的内部版本
+func (dc *DistributedCache) getNodesForKeyUnsafe(key string, n int) []string {
+	if n <= 0 || len(dc.Nodes) == 0 {
+		return nil
+	}
+	if n > len(dc.Nodes) {
+		n = len(dc.Nodes)
+	}
+
+	primary := dc.getNodeForKeyUnsafe(key)
+	levels := dc.replicaDomainLevels()
+
+	// 没有位置约束时只需要前n个候选；有约束时需要全部候选以便跳过同区节点
+	limit := n
+	if len(levels) > 0 {
+		limit = len(dc.Nodes)
+	}
+	candidates := []string{primary}
+	dc.walkCandidates(key, func(node string) bool {
+		if node != primary {
+			candidates = append(candidates, node)
+		}
+		return len(candidates) < limit
+	})
+	return selectReplicas(candidates, n, levels)
+}
+
+// selectReplicas 按优先顺序从候选节点中选出n个副本，levels 为空时取前n个
+func selectReplicas(candidates []string, n int, levels []func(node string) string) []string {
+	if len(levels) == 0 {
+		if len(candidates) > n {
+			return candidates[:n]
+		}
+		return candidates
+	}
+
+	// 逐级放宽约束：先要求位置域不重复，最后不限制
+	chosen := make([]string, 0, n)
+	selected := make(map[string]bool, n)
+	for _, domain := range append(levels, nil) {
+		used := make(map[string]bool, n)
+		if domain != nil {
+			for _, node := range chosen {
+				used[domain(node)] = true
+			}
+		}
+		for _, node := range candidates {
+			if len(chosen) == n {
+				return chosen
+			}
+			if selected[node] {
+				continue
+			}
+			if domain != nil {
+				if used[domain(node)] {
+					continue
+				}
+				used[domain(node)] = true
+			}
+			chosen = append(chosen, node)
+			selected[node] = true
+		}
+	}
+	return chosen
+}
+
+// replicaDomainLevels 按约束从严到宽返回位置域函数，不考虑位置时为空
+func (dc *DistributedCache) replicaDomainLevels() []func(node string) string {
+	zone := func(node string) string {
+		if location := dc.Locations[node]; location.Zone != "" {
+			return "zone:" + location.Zone
+		}
+		return "node:" + node
+	}
+	rack := func(node string) string {
+		if location := dc.Locations[node]; location.Rack != "" {
+			return "rack:" + location.Zone + "/" + location.Rack
+		}
+		return "node:" + node
+	}
+
+	switch dc.ReplicaConstraint {
+	case ReplicaConstraintZone:
+		return []func(string) string{zone, rack}
+	case ReplicaConstraintRack:
+		return []func(string) string{rack}
+	}
+	return nil
+}
+
+// walkCandidates 按放置算法的优先顺序遍历key的候选物理节点
+func (dc *DistributedCache) walkCandidates(key string, visit func(node string) bool) {
+	if walker, ok := dc.placement().(candidateWalker); ok {
+		walker.walkCandidates(key, visit)
+		return
+	}
+
+	// 没有天然顺序的算法（jump/maglev）：主节点之后按 (key, node) 哈希排序，结果与节点顺序无关
+	keyHash := uint64(dc.hashKey(key)) << 32
+	nodes := append([]string(nil), dc.Nodes...)
+	scores := make(map[string]uint64, len(nodes))
+	for _, node := range nodes {
+		scores[node] = mix64(keyHash | uint64(dc.hashKey(node)))
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		if scores[nodes[i]] != scores[nodes[j]] {
+			return scores[nodes[i]] > scores[nodes[j]]
+		}
+		return nodes[i] < nodes[j]
+	})
+	for _, node := range nodes {
+		if !visit(node) {
+			return
+		}
+	}
+}
+
+// walkCandidates 从key的哈希位置顺时针遍历不同的物理节点
+func (r ringPlacement) walkCandidates(key string, visit func(node string) bool) {
+	dc := r.dc
+	if len(dc.SortedHashes) == 0 {
+		return
+	}
+	hash := dc.hashKey(key)
+	idx := sort.Search(len(dc.SortedHashes), func(i int) bool {
+		return dc.SortedHashes[i] >= hash
+	})
+
+	visited := make(map[string]bool)
+	for i := 0; i < len(dc.SortedHashes) && len(visited) < len(dc.Nodes); i++ {
+		node := dc.HashRing[dc.SortedHashes[(idx+i)%len(dc.SortedHashes)]]
+		if visited[node] {
+			continue
+		}
+		visited[node] = true
+		if !visit(node) {
+			return
+		}
+	}
+}
+
+// walkCandidates 按分数从高到低遍历节点
+func (p *RendezvousPlacement) walkCandidates(key string, visit func(node string) bool) {
+	keyHash := uint64(p.hasher.Hash(key)) << 32
+	scores := make(map[string]float64, len(p.nodes))
+	for i, node := range p.nodes {
+		if len(p.weights) == 0 {
+			// 与 GetNodeForKey 的无权重路径一致，保留高位比较即可
+			scores[node] = float64(mix64(keyHash|p.seeds[i]) >> 11)
+			continue
+		}
+		x := (float64(mix64(keyHash|p.seeds[i])>>11) + 0.5) / (1 << 53)
+		scores[node] = -p.weight(node) / math.Log(x)
+	}
+
+	nodes := append([]string(nil), p.nodes...)
+	sort.Slice(nodes, func(i, j int) bool {
+		if scores[nodes[i]] != scores[nodes[j]] {
+			return scores[nodes[i]] > scores[nodes[j]]
+		}
+		return nodes[i] < nodes[j]
+	})
+	for _, node := range nodes {
+		if !visit(node) {
+			return
+		}
+	}
+}
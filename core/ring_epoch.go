@@ -0,0 +1,52 @@
+package core
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"sync/atomic"
+)
+
+// 拓扑版本 - 每次增删节点或修改权重时版本号加1，节点间据此判断谁的哈希环视图更新；
+// 校验和只取决于放置算法、哈希函数和节点权重，视图相同的节点校验和一定相同。
+
+// Epoch 当前拓扑版本号
+func (dc *DistributedCache) Epoch() uint64 {
+	return atomic.LoadUint64(&dc.epoch)
+}
+
+// ObserveEpoch 把版本号推进到至少epoch，用于应用其他节点广播的变更后与集群对齐，返回推进后的版本号
+func (dc *DistributedCache) ObserveEpoch(epoch uint64) uint64 {
+	for {
+		current := atomic.LoadUint64(&dc.epoch)
+		if epoch <= current {
+			return current
+		}
+		if atomic.CompareAndSwapUint64(&dc.epoch, current, epoch) {
+			return epoch
+		}
+	}
+}
+
+// bumpEpoch 拓扑变更后版本号加1，调用方需持有写锁
+func (dc *DistributedCache) bumpEpoch() {
+	atomic.AddUint64(&dc.epoch, 1)
+}
+
+// RingChecksum 当前放置视图的校验和（FNV-1a 64位，十六进制）
+// 有界负载的动态改选不计入，它由各节点上报的负载决定
+func (dc *DistributedCache) RingChecksum() string {
+	dc.Mu.RLock()
+	defer dc.Mu.RUnlock()
+
+	nodes := append([]string(nil), dc.Nodes...)
+	sort.Strings(nodes)
+
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s|%s|%d", dc.placement().Name(), dc.HasherName(), dc.VirtualNodes)
+	for _, node := range nodes {
+		fmt.Fprintf(h, "|%s=%s", node, strconv.FormatFloat(dc.nodeWeight(node), 'g', -1, 64))
+	}
+	return fmt.Sprintf("%016x", h.Sum64())
+}
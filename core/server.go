@@ -3,6 +3,7 @@ package core
 
 import (
 	"encoding/json"
+	"io"
 	"net/http"
 )
 
@@ -23,6 +24,7 @@ func NewCacheServer(cache *LRUCache) *CacheServer {
 	}
 	mux.HandleFunc("/cache", s.handleCache)
     mux.HandleFunc("/cache/", s.handleCacheWithKey)
+    mux.HandleFunc("/bin/", s.handleBinary)
     mux.HandleFunc("/stats", s.handleStats)
     return s
 }
@@ -91,6 +93,47 @@ func (s *CacheServer) handleCacheWithKey(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+// 二进制接口单个value的大小上限
+const maxBinaryValueSize = 64 * 1024 * 1024
+
+// handleBinary 处理 GET/PUT/DELETE /bin/{base64url key}
+// key和value都按原始字节处理，value通过 application/octet-stream 请求/响应体传输
+func (s *CacheServer) handleBinary(w http.ResponseWriter, r *http.Request) {
+	key, err := DecodeKey(r.URL.Path[len("/bin/"):])
+	if err != nil {
+		http.Error(w, "Invalid base64url key", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		value, exists := s.cache.Get(key)
+		if !exists {
+			http.Error(w, "Key not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, value)
+	case http.MethodPut:
+		value, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBinaryValueSize))
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		s.cache.Set(key, string(value))
+		w.WriteHeader(http.StatusOK)
+	case http.MethodDelete:
+		if !s.cache.Delete(key) {
+			http.Error(w, "Key not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
 // handleStats 处理 GET /stats
 func (s *CacheServer) handleStats(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodGet {
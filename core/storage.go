@@ -0,0 +1,84 @@
+package core
+
+import (
+	"fmt"
+	"time"
+)
+
+// 存储引擎 - 分布式节点的本地缓存通过 Storage 接口访问，
+// 可以在指针链表实现的 LRUCache 和字节数组arena实现的 ArenaLRUCache 之间选择，两者语义一致。
+
+// 存储引擎名称
+const (
+	StorageEngineHeap  = "heap"  // LRUCache：每个条目一个链表节点
+	StorageEngineArena = "arena" // ArenaLRUCache：键值保存在一块 []byte 中，GC 无需扫描单个条目
+)
+
+// StorageEngineNames 支持的存储引擎名称
+var StorageEngineNames = []string{StorageEngineHeap, StorageEngineArena}
+
+// Storage 本地存储引擎
+type Storage interface {
+	// 读写
+	Get(key string) (string, bool)
+	Set(key, value string)
+	SetWithTTL(key, value string, ttl time.Duration)
+	TrySet(key, value string) bool
+	SetBypassAdmission(key, value string) bool
+	Delete(key string) bool
+	DeleteMulti(keys []string) int
+	GetAllData() map[string]string
+
+	// 统计
+	Size() int
+	GetStats() CacheStats
+	GetMemoryUsage() int64
+
+	// 运行时调整容量与内存限制
+	Capacity() int
+	GetMemoryLimit() int64
+	Resize(capacity int) (int, error)
+	SetMemoryLimit(memoryLimitBytes int64) (int, error)
+
+	// 准入策略
+	EnableAdmission(counters int)
+	GetAdmissionStats() (AdmissionStats, bool)
+
+	// 按哈希区间迁移
+	EnableHashIndex(hash func(key string) uint32)
+	GetDataInHashRange(start, end uint32) map[string]string
+	VersionedEntriesInHashRanges(ranges []HashInterval) []map[string]VersionedEntry
+
+	// 单key元数据
+	Inspect(key string) (KeyMetadata, bool)
+	LargestKeys(n int) []KeyMetadata
+
+	// 原子读改写
+	GetEntry(key string) (Entry, bool)
+	Update(key string, fn UpdateFunc) (Entry, bool)
+	Expire(key string, ttl time.Duration) bool
+
+	// 多副本版本与删除标记
+	NewVersion() uint64
+	GetVersioned(key string) (VersionedEntry, bool)
+	SetVersioned(key string, entry VersionedEntry) bool
+	SetVersionedBypassAdmission(key string, entry VersionedEntry) bool
+	VersionedEntries() map[string]VersionedEntry
+}
+
+var (
+	_ Storage = (*LRUCache)(nil)
+	_ Storage = (*ArenaLRUCache)(nil)
+)
+
+// NewStorage 按名称创建存储引擎，空字符串表示默认的 heap
+func NewStorage(engine string, capacity int, memoryLimitBytes int64) (Storage, error) {
+	switch engine {
+	case "", StorageEngineHeap:
+		return NewLRUCacheWithMemoryLimit(capacity, memoryLimitBytes), nil
+	case StorageEngineArena:
+		return NewArenaLRUCacheWithMemoryLimit(capacity, memoryLimitBytes), nil
+	default:
+		return nil, fmt.Errorf("未知的存储引擎: %s", engine)
+	}
+}
@@ -0,0 +1,186 @@
+package core
+
+import "time"
+
+// 带版本的条目 - 多副本写入时由协调节点分配版本号，各副本只接受比现有条目更新的版本，
+// 读取时按版本号合并（最后写入胜出）。删除以删除标记的形式保存，避免旧副本上的数据在合并时复活。
+
+// TombstoneTTL 删除标记的保留时间，超过后不再参与合并
+const TombstoneTTL = 10 * time.Minute
+
+// VersionedEntry 带版本号的条目，Tombstone 表示该版本为删除
+// 标志和过期时间随版本一起复制，各副本上的同一版本过期时刻相同
+type VersionedEntry struct {
+	Value     string
+	Version   uint64
+	Tombstone bool
+	Flags     uint32 // 客户端自定义标志
+	ExpiresAt int64  // 过期时间（UnixNano），0表示永不过期
+}
+
+// ExpiresAtAfter 把写入时的ttl换算为 ExpiresAt，ttl <= 0 表示永不过期
+func ExpiresAtAfter(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	return time.Now().Add(ttl).UnixNano()
+}
+
+// NewerThan 是否比另一个条目新，版本号相同时删除优先
+func (e VersionedEntry) NewerThan(other VersionedEntry) bool {
+	if e.Version != other.Version {
+		return e.Version > other.Version
+	}
+	return e.Tombstone && !other.Tombstone
+}
+
+// NewVersion 分配一个比本缓存已有版本都大的版本号（纳秒时间戳）
+func (lru *LRUCache) NewVersion() uint64 {
+	lru.mu.Lock()
+	defer lru.mu.Unlock()
+	return lru.nextVersion()
+}
+
+// GetVersioned 获取条目及其版本号，key已被删除且删除标记未过期时返回删除标记
+func (lru *LRUCache) GetVersioned(key string) (VersionedEntry, bool) {
+	lru.mu.Lock()
+	defer lru.mu.Unlock()
+
+	lru.stats.TotalRequests++
+	if node, found := lru.liveNode(key); found {
+		lru.stats.Hits++
+		node.touch()
+		lru.moveToHead(node)
+		return VersionedEntry{Value: node.value, Version: node.version, Flags: node.flags, ExpiresAt: lru.expiresAt(key)}, true
+	}
+	lru.stats.Misses++
+
+	if version, deleted := lru.tombstones[key]; deleted && !tombstoneExpired(version, time.Now()) {
+		return VersionedEntry{Version: version, Tombstone: true}, true
+	}
+	return VersionedEntry{}, false
+}
+
+// SetVersioned 写入带版本号的条目，版本不比现有条目新时不修改
+// 只有因内存限制被拒绝时返回false
+func (lru *LRUCache) SetVersioned(key string, entry VersionedEntry) bool {
+	lru.mu.Lock()
+	defer lru.mu.Unlock()
+	return lru.setVersioned(key, entry, lru.admission != nil)
+}
+
+// SetVersionedBypassAdmission 写入带版本号的条目，新key跳过准入窗口直接进入主缓存
+// 用于数据迁移：保留原版本号，目标节点上更新的版本不会被迁移来的旧数据覆盖
+func (lru *LRUCache) SetVersionedBypassAdmission(key string, entry VersionedEntry) bool {
+	lru.mu.Lock()
+	defer lru.mu.Unlock()
+	return lru.setVersioned(key, entry, false)
+}
+
+// setVersioned 写入带版本号的条目，useWindow 为true时新key进入准入窗口（调用方持有锁）
+func (lru *LRUCache) setVersioned(key string, entry VersionedEntry, useWindow bool) bool {
+	var current VersionedEntry
+	if node, found := lru.liveNode(key); found {
+		current = VersionedEntry{Value: node.value, Version: node.version}
+	} else if version, deleted := lru.tombstones[key]; deleted {
+		current = VersionedEntry{Version: version, Tombstone: true}
+	}
+	if !entry.NewerThan(current) {
+		return true
+	}
+	if entry.Version > lru.lastVersion {
+		lru.lastVersion = entry.Version
+	}
+
+	if entry.Tombstone {
+		if node, exists := lru.cache[key]; exists {
+			lru.memoryUsage -= calculateMemoryUsage(key, node.value)
+			lru.removeNode(node)
+			delete(lru.cache, key)
+			lru.unindexKey(node)
+			delete(lru.ttlMap, key)
+			lru.size--
+		}
+		if lru.tombstones == nil {
+			lru.tombstones = make(map[string]uint64)
+		}
+		lru.tombstones[key] = entry.Version
+		lru.purgeTombstones()
+		return true
+	}
+
+	node := lru.writeNode(key, entry.Value, useWindow)
+	if node == nil {
+		return false
+	}
+	node.version = entry.Version
+	node.flags = entry.Flags
+	lru.setExpiresAt(key, entry.ExpiresAt)
+	delete(lru.tombstones, key)
+	return true
+}
+
+// expiresAt 获取key的过期时间（UnixNano），0表示永不过期（调用方持有锁）
+func (lru *LRUCache) expiresAt(key string) int64 {
+	if expireTime, hasTTL := lru.ttlMap[key]; hasTTL {
+		return expireTime.UnixNano()
+	}
+	return 0
+}
+
+// setExpiresAt 按绝对时间设置过期时间，0表示永不过期（调用方持有锁）
+func (lru *LRUCache) setExpiresAt(key string, expiresAt int64) {
+	if expiresAt == 0 {
+		delete(lru.ttlMap, key)
+		return
+	}
+	if lru.ttlMap == nil {
+		lru.ttlMap = make(map[string]time.Time)
+	}
+	lru.ttlMap[key] = time.Unix(0, expiresAt)
+}
+
+// VersionedEntries 获取所有未过期条目和删除标记的版本快照（不含value），用于副本间对比
+func (lru *LRUCache) VersionedEntries() map[string]VersionedEntry {
+	lru.mu.RLock()
+	defer lru.mu.RUnlock()
+
+	now := time.Now()
+	entries := make(map[string]VersionedEntry, len(lru.cache)+len(lru.tombstones))
+	for key, node := range lru.cache {
+		if expireTime, hasTTL := lru.ttlMap[key]; hasTTL && now.After(expireTime) {
+			continue
+		}
+		entries[key] = VersionedEntry{Version: node.version}
+	}
+	for key, version := range lru.tombstones {
+		if !tombstoneExpired(version, now) {
+			entries[key] = VersionedEntry{Version: version, Tombstone: true}
+		}
+	}
+	return entries
+}
+
+// purgeTombstones 清理过期的删除标记，每 TombstoneTTL/10 最多执行一次（调用方持有锁）
+func (lru *LRUCache) purgeTombstones() {
+	purgeExpiredTombstones(lru.tombstones, &lru.tombstonePurge)
+}
+
+// purgeExpiredTombstones 清理过期的删除标记，lastPurge 为上次清理时间
+func purgeExpiredTombstones(tombstones map[string]uint64, lastPurge *time.Time) {
+	now := time.Now()
+	if now.Sub(*lastPurge) < TombstoneTTL/10 {
+		return
+	}
+	*lastPurge = now
+	for key, version := range tombstones {
+		if tombstoneExpired(version, now) {
+			delete(tombstones, key)
+		}
+	}
+}
+
+// tombstoneExpired 版本号为纳秒时间戳，据此判断删除标记是否过期
+func tombstoneExpired(version uint64, now time.Time) bool {
+	return now.Sub(time.Unix(0, int64(version))) > TombstoneTTL
+}
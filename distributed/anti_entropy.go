@@ -0,0 +1,335 @@
+package distributed
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"tdd-learning/core"
+)
+
+// 反熵修复（Anti-Entropy）
+//
+// 分区恢复或迁移失败后副本之间可能不一致。开启多副本时，节点定期与每个对端对比双方共同负责的key：
+// 双方各自把这些key的版本汇总成Merkle树（叶子对应一段哈希区间），对比后只交换不同区间内的key版本，
+// 版本更新的一方覆盖另一方（最后写入胜出，删除标记同样参与对比）。
+
+// ReplicaDigest 副本上单个key的版本摘要，key按字节传输（JSON中为base64）
+type ReplicaDigest struct {
+	Key       []byte `json:"key"`
+	Version   uint64 `json:"version"`
+	Tombstone bool   `json:"tombstone,omitempty"`
+}
+
+// digestRequest 请求对端指定叶子区间内的版本摘要
+type digestRequest struct {
+	Peer   string `json:"peer"`
+	Leaves int    `json:"leaves"`
+	Ranges []int  `json:"ranges"`
+}
+
+// AntiEntropyReport 一轮反熵修复的结果
+type AntiEntropyReport struct {
+	StartedAt       time.Time     `json:"started_at"`
+	Duration        time.Duration `json:"duration"`
+	PeersCompared   int           `json:"peers_compared"`
+	DifferingRanges int           `json:"differing_ranges"` // Merkle树中不同的叶子区间数
+	KeysCompared    int           `json:"keys_compared"`    // 不同区间内对比的key数
+	Discrepancies   int           `json:"discrepancies"`    // 版本不一致的key数
+	KeysPushed      int           `json:"keys_pushed"`      // 推送到对端的key数
+	KeysPulled      int           `json:"keys_pulled"`      // 从对端拉取的key数
+	Errors          []string      `json:"errors,omitempty"`
+}
+
+// AntiEntropyStats 反熵修复的累计统计
+type AntiEntropyStats struct {
+	Enabled         bool               `json:"enabled"`
+	Interval        string             `json:"interval"`
+	Running         bool               `json:"running"`
+	Rounds          uint64             `json:"rounds"`
+	DifferingRanges uint64             `json:"differing_ranges"`
+	Discrepancies   uint64             `json:"discrepancies"`
+	KeysPushed      uint64             `json:"keys_pushed"`
+	KeysPulled      uint64             `json:"keys_pulled"`
+	Errors          uint64             `json:"errors"`
+	LastRound       *AntiEntropyReport `json:"last_round,omitempty"`
+}
+
+// antiEntropy 反熵修复的状态
+type antiEntropy struct {
+	interval time.Duration
+	stopChan chan struct{}
+	runMu    sync.Mutex // 同一时间只执行一轮
+
+	mu    sync.Mutex
+	stats AntiEntropyStats
+}
+
+// StartAntiEntropy 按配置的间隔启动后台反熵修复，未开启多副本或未配置间隔时不启动
+func (dn *DistributedNode) StartAntiEntropy() {
+	ae := dn.antiEntropy
+	if !dn.replicated() || ae.interval <= 0 || ae.stopChan != nil {
+		return
+	}
+	ae.stopChan = make(chan struct{})
+	log.Printf("🌳 启动反熵修复，间隔 %v", ae.interval)
+
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(ae.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				dn.RunAntiEntropy()
+			case <-stop:
+				return
+			}
+		}
+	}(ae.stopChan)
+}
+
+// StopAntiEntropy 停止后台反熵修复
+func (dn *DistributedNode) StopAntiEntropy() {
+	if dn.antiEntropy.stopChan != nil {
+		close(dn.antiEntropy.stopChan)
+		dn.antiEntropy.stopChan = nil
+	}
+}
+
+// GetAntiEntropyStats 获取反熵修复统计
+func (dn *DistributedNode) GetAntiEntropyStats() AntiEntropyStats {
+	ae := dn.antiEntropy
+	ae.mu.Lock()
+	defer ae.mu.Unlock()
+
+	stats := ae.stats
+	stats.Enabled = dn.replicated() && ae.interval > 0
+	stats.Interval = ae.interval.String()
+	return stats
+}
+
+// RunAntiEntropy 立即与所有对端执行一轮反熵修复
+func (dn *DistributedNode) RunAntiEntropy() AntiEntropyReport {
+	ae := dn.antiEntropy
+	ae.runMu.Lock()
+	defer ae.runMu.Unlock()
+
+	report := AntiEntropyReport{StartedAt: time.Now()}
+	if !dn.replicated() {
+		return report
+	}
+	ae.setRunning(true)
+
+	peers := dn.GetClusterNodes()
+	peerIDs := make([]string, 0, len(peers))
+	for nodeID := range peers {
+		if nodeID != dn.nodeID {
+			peerIDs = append(peerIDs, nodeID)
+		}
+	}
+	sort.Strings(peerIDs)
+
+	for _, peer := range peerIDs {
+		if err := dn.repairWithPeer(peer, peers[peer], &report); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", peer, err))
+			continue
+		}
+		report.PeersCompared++
+	}
+	report.Duration = time.Since(report.StartedAt)
+
+	if report.Discrepancies > 0 || len(report.Errors) > 0 {
+		log.Printf("🌳 反熵修复完成: 对比 %d 个节点，%d 个区间不同，修复 %d 个key（推送 %d，拉取 %d），%d 个错误",
+			report.PeersCompared, report.DifferingRanges, report.Discrepancies, report.KeysPushed, report.KeysPulled, len(report.Errors))
+	}
+	ae.record(report)
+	return report
+}
+
+// repairWithPeer 与单个对端对比Merkle树并修复不同区间内的key
+func (dn *DistributedNode) repairWithPeer(peer, address string, report *AntiEntropyReport) error {
+	local, entries := dn.merkleTree(peer, core.DefaultMerkleLeaves)
+
+	var remote core.MerkleTree
+	url := fmt.Sprintf("http://%s/internal/anti-entropy/tree?peer=%s&leaves=%d", address, dn.nodeID, local.Leaves())
+	if err := dn.getJSON(url, &remote); err != nil {
+		return err
+	}
+	ranges := local.Diff(&remote)
+	if len(ranges) == 0 {
+		return nil
+	}
+	report.DifferingRanges += len(ranges)
+
+	remoteDigests, err := dn.fetchDigests(address, local.Leaves(), ranges)
+	if err != nil {
+		return err
+	}
+
+	// 对比不同区间内双方的版本
+	inRanges := make(map[int]bool, len(ranges))
+	for _, leaf := range ranges {
+		inRanges[leaf] = true
+	}
+	remoteEntries := make(map[string]core.VersionedEntry, len(remoteDigests))
+	for _, digest := range remoteDigests {
+		remoteEntries[string(digest.Key)] = core.VersionedEntry{Version: digest.Version, Tombstone: digest.Tombstone}
+	}
+	keys := make(map[string]bool, len(remoteEntries))
+	for key := range remoteEntries {
+		keys[key] = true
+	}
+	for key := range entries {
+		if inRanges[local.LeafFor(dn.hashRing.HashKey(key))] {
+			keys[key] = true
+		}
+	}
+
+	for key := range keys {
+		report.KeysCompared++
+		localEntry, hasLocal := entries[key]
+		remoteEntry, hasRemote := remoteEntries[key]
+		switch {
+		case hasLocal && (!hasRemote || localEntry.NewerThan(remoteEntry)):
+			report.Discrepancies++
+			if err := dn.pushToPeer(peer, key); err != nil {
+				return err
+			}
+			report.KeysPushed++
+		case hasRemote && (!hasLocal || remoteEntry.NewerThan(localEntry)):
+			report.Discrepancies++
+			if err := dn.pullFromPeer(peer, key); err != nil {
+				return err
+			}
+			report.KeysPulled++
+		}
+	}
+	return nil
+}
+
+// pushToPeer 把本地最新的条目写入对端
+func (dn *DistributedNode) pushToPeer(peer, key string) error {
+	entry, found := dn.localCache.GetVersioned(key)
+	if !found {
+		return nil
+	}
+	return dn.replicaWrite(peer, key, entry)
+}
+
+// pullFromPeer 从对端读取条目写入本地
+func (dn *DistributedNode) pullFromPeer(peer, key string) error {
+	entry, found, err := dn.replicaRead(peer, key)
+	if err != nil || !found {
+		return err
+	}
+	return dn.SetLocalVersioned(key, entry)
+}
+
+// fetchDigests 获取对端指定区间内的版本摘要
+func (dn *DistributedNode) fetchDigests(address string, leaves int, ranges []int) ([]ReplicaDigest, error) {
+	body, err := json.Marshal(digestRequest{Peer: dn.nodeID, Leaves: leaves, Ranges: ranges})
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求失败: %v", err)
+	}
+	resp, err := dn.httpClient.Post(fmt.Sprintf("http://%s/internal/anti-entropy/digests", address), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, &NodeUnavailableError{Address: address, Err: err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("目标节点返回错误: %d", resp.StatusCode)
+	}
+
+	var digests []ReplicaDigest
+	if err := json.NewDecoder(resp.Body).Decode(&digests); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %v", err)
+	}
+	return digests, nil
+}
+
+// sharedWith 本节点和对端是否都是该key的副本
+func (dn *DistributedNode) sharedWith(key, peer string) bool {
+	self, other := false, false
+	for _, nodeID := range dn.hashRing.GetNodesForKey(key, dn.quorum.N) {
+		self = self || nodeID == dn.nodeID
+		other = other || nodeID == peer
+	}
+	return self && other
+}
+
+// sharedEntries 本节点与对端共同负责的key的版本快照
+func (dn *DistributedNode) sharedEntries(peer string) map[string]core.VersionedEntry {
+	entries := dn.localCache.VersionedEntries()
+	for key := range entries {
+		if !dn.sharedWith(key, peer) {
+			delete(entries, key)
+		}
+	}
+	return entries
+}
+
+// merkleTree 构建本节点与对端共同负责的key的Merkle树，同时返回这些key的版本
+func (dn *DistributedNode) merkleTree(peer string, leaves int) (*core.MerkleTree, map[string]core.VersionedEntry) {
+	entries := dn.sharedEntries(peer)
+	tree := core.NewMerkleTree(leaves)
+	for key, entry := range entries {
+		tree.Add(dn.hashRing.HashKey(key), key, entry)
+	}
+	tree.Build()
+	return tree, entries
+}
+
+// MerkleTreeFor 本节点与对端共同负责的key的Merkle树 - 用于内部API
+func (dn *DistributedNode) MerkleTreeFor(peer string, leaves int) *core.MerkleTree {
+	if leaves <= 0 {
+		leaves = core.DefaultMerkleLeaves
+	}
+	tree, _ := dn.merkleTree(peer, leaves)
+	return tree
+}
+
+// DigestsInRanges 本节点与对端共同负责、位于指定叶子区间内的key版本摘要 - 用于内部API
+func (dn *DistributedNode) DigestsInRanges(peer string, leaves int, ranges []int) []ReplicaDigest {
+	if leaves <= 0 {
+		leaves = core.DefaultMerkleLeaves
+	}
+	tree := core.NewMerkleTree(leaves)
+	wanted := make(map[int]bool, len(ranges))
+	for _, leaf := range ranges {
+		wanted[leaf] = true
+	}
+
+	digests := make([]ReplicaDigest, 0)
+	for key, entry := range dn.sharedEntries(peer) {
+		if wanted[tree.LeafFor(dn.hashRing.HashKey(key))] {
+			digests = append(digests, ReplicaDigest{Key: []byte(key), Version: entry.Version, Tombstone: entry.Tombstone})
+		}
+	}
+	return digests
+}
+
+func (ae *antiEntropy) setRunning(running bool) {
+	ae.mu.Lock()
+	ae.stats.Running = running
+	ae.mu.Unlock()
+}
+
+// record 累计一轮的结果
+func (ae *antiEntropy) record(report AntiEntropyReport) {
+	ae.mu.Lock()
+	defer ae.mu.Unlock()
+
+	ae.stats.Running = false
+	ae.stats.Rounds++
+	ae.stats.DifferingRanges += uint64(report.DifferingRanges)
+	ae.stats.Discrepancies += uint64(report.Discrepancies)
+	ae.stats.KeysPushed += uint64(report.KeysPushed)
+	ae.stats.KeysPulled += uint64(report.KeysPulled)
+	ae.stats.Errors += uint64(len(report.Errors))
+	ae.stats.LastRound = &report
+}
@@ -4,10 +4,14 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+
+	"tdd-learning/core"
 )
 
 // APIHandlers API处理器
@@ -16,6 +20,10 @@ type APIHandlers struct {
 	node        *DistributedNode
 	cluster     *ClusterManager
 	coordinator *ClusterCoordinator
+	gossip      *Gossiper // 未开启gossip时为nil
+	evictor     *NodeEvictor
+	raft        *Raft            // 未开启Raft时为nil
+	metadata    *clusterMetadata // 未开启Raft时为nil
 }
 
 // CacheRequest 缓存请求
@@ -62,11 +70,19 @@ func NewAPIHandlers(node *DistributedNode, cluster *ClusterManager) *APIHandlers
 // HandleGet 处理GET请求
 func (h *APIHandlers) HandleGet(c *gin.Context) {
 	key := c.Param("key")
+	// 客户端按拓扑直连时携带版本号，负责节点已变更时返回421
+	if !h.checkRoute(c, key) {
+		return
+	}
+	quorum, ok := h.quorumOverride(c)
+	if !ok {
+		return
+	}
 
-	// 使用DistributedNode的Get方法，它会自动处理路由和转发
-	value, found, err := h.node.Get(key)
+	// 使用DistributedNode的Get方法，它会自动处理路由、转发和多副本读取
+	value, found, err := h.node.GetWithQuorum(key, quorum)
 	if err != nil {
-		h.sendError(c, http.StatusInternalServerError, "cache_error", err.Error())
+		h.sendCacheError(c, err)
 		return
 	}
 
@@ -81,6 +97,14 @@ func (h *APIHandlers) HandleGet(c *gin.Context) {
 // HandleSet 处理PUT请求
 func (h *APIHandlers) HandleSet(c *gin.Context) {
 	key := c.Param("key")
+	// 客户端按拓扑直连时携带版本号，负责节点已变更时返回421
+	if !h.checkRoute(c, key) {
+		return
+	}
+	quorum, ok := h.quorumOverride(c)
+	if !ok {
+		return
+	}
 
 	var req CacheRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -88,9 +112,9 @@ func (h *APIHandlers) HandleSet(c *gin.Context) {
 		return
 	}
 
-	// 使用DistributedNode的Set方法，它会自动处理路由和转发
-	if err := h.node.Set(key, req.Value); err != nil {
-		h.sendError(c, http.StatusInternalServerError, "cache_error", err.Error())
+	// 使用DistributedNode的Set方法，它会自动处理路由、转发和多副本写入
+	if err := h.node.SetWithQuorum(key, req.Value, quorum); err != nil {
+		h.sendCacheError(c, err)
 		return
 	}
 
@@ -106,10 +130,18 @@ func (h *APIHandlers) HandleSet(c *gin.Context) {
 // HandleDelete 处理DELETE请求
 func (h *APIHandlers) HandleDelete(c *gin.Context) {
 	key := c.Param("key")
+	// 客户端按拓扑直连时携带版本号，负责节点已变更时返回421
+	if !h.checkRoute(c, key) {
+		return
+	}
+	quorum, ok := h.quorumOverride(c)
+	if !ok {
+		return
+	}
 
-	// 使用DistributedNode的Delete方法，它会自动处理路由和转发
-	if err := h.node.Delete(key); err != nil {
-		h.sendError(c, http.StatusInternalServerError, "cache_error", err.Error())
+	// 使用DistributedNode的Delete方法，它会自动处理路由、转发和多副本删除
+	if err := h.node.DeleteWithQuorum(key, quorum); err != nil {
+		h.sendCacheError(c, err)
 		return
 	}
 
@@ -141,11 +173,298 @@ func (h *APIHandlers) HandleHealthCheck(c *gin.Context) {
 		status = "unhealthy"
 	}
 	
+	response := gin.H{
+		"status":        status,
+		"node_id":       h.cluster.nodeID,
+		"hash_function": h.node.GetHashFunction(),
+		"placement":     h.node.GetPlacement(),
+		"timestamp":     time.Now().Format(time.RFC3339),
+		"uptime":        time.Now().Unix(),
+	}
+	if load, enabled := h.node.LocalLoad(); enabled {
+		response["load"] = load
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// ===== 二进制安全接口 =====
+// key通过base64url编码放在路径中，value使用 application/octet-stream 原样传输，
+// 可以携带包含 '/'、'?'、'%' 或非UTF-8字节的key和任意二进制value。
+
+// 二进制接口单个value的大小上限
+const maxBinaryValueSize = 64 * 1024 * 1024
+
+// binaryKey 解码路径参数 :key64
+func (h *APIHandlers) binaryKey(c *gin.Context) (string, bool) {
+	key, err := core.DecodeKey(c.Param("key64"))
+	if err != nil {
+		h.sendError(c, http.StatusBadRequest, "invalid_key", "key必须是base64url编码: "+err.Error())
+		return "", false
+	}
+	return key, true
+}
+
+// binaryValue 读取 application/octet-stream 请求体
+func (h *APIHandlers) binaryValue(c *gin.Context) (string, bool) {
+	value, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxBinaryValueSize))
+	if err != nil {
+		h.sendError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return "", false
+	}
+	return string(value), true
+}
+
+// checkRoute 转发来的请求或直连的客户端携带拓扑版本号时，检查key是否应由本节点处理，不应处理时返回421重定向
+// 数据迁移不携带版本号，始终写入本地；响应始终携带本节点的版本号，客户端据此发现拓扑变更
+func (h *APIHandlers) checkRoute(c *gin.Context, key string) bool {
+	c.Header(ClusterEpochHeader, strconv.FormatUint(h.node.GetRingEpoch(), 10))
+	header := c.GetHeader(ClusterEpochHeader)
+	if header == "" {
+		return true
+	}
+	epoch, err := strconv.ParseUint(header, 10, 64)
+	if err != nil {
+		h.sendError(c, http.StatusBadRequest, "invalid_epoch", ClusterEpochHeader+"必须为非负整数: "+header)
+		return false
+	}
+
+	if redirect := h.node.checkRoute(key, epoch); redirect != nil {
+		c.Header(ClusterEpochHeader, strconv.FormatUint(redirect.Epoch, 10))
+		c.JSON(http.StatusMisdirectedRequest, redirect)
+		return false
+	}
+	return true
+}
+
+// writeBinaryValue 返回原始字节，未找到时返回404
+func (h *APIHandlers) writeBinaryValue(c *gin.Context, value string, found bool) {
+	c.Header("X-Node-ID", h.node.GetNodeID())
+	if !found {
+		h.sendError(c, http.StatusNotFound, "not_found", "key不存在")
+		return
+	}
+	c.Data(http.StatusOK, "application/octet-stream", []byte(value))
+}
+
+// HandleBinaryGet 处理 GET /api/v1/bin/:key64
+func (h *APIHandlers) HandleBinaryGet(c *gin.Context) {
+	key, ok := h.binaryKey(c)
+	if !ok {
+		return
+	}
+	// 客户端按拓扑直连时携带版本号，负责节点已变更时返回421
+	if !h.checkRoute(c, key) {
+		return
+	}
+	quorum, ok := h.quorumOverride(c)
+	if !ok {
+		return
+	}
+
+	value, found, err := h.node.GetWithQuorum(key, quorum)
+	if err != nil {
+		h.sendCacheError(c, err)
+		return
+	}
+	h.writeBinaryValue(c, value, found)
+}
+
+// HandleBinarySet 处理 PUT /api/v1/bin/:key64
+func (h *APIHandlers) HandleBinarySet(c *gin.Context) {
+	key, ok := h.binaryKey(c)
+	if !ok {
+		return
+	}
+	// 客户端按拓扑直连时携带版本号，负责节点已变更时返回421
+	if !h.checkRoute(c, key) {
+		return
+	}
+	quorum, ok := h.quorumOverride(c)
+	if !ok {
+		return
+	}
+	value, ok := h.binaryValue(c)
+	if !ok {
+		return
+	}
+
+	if err := h.node.SetWithQuorum(key, value, quorum); err != nil {
+		h.sendCacheError(c, err)
+		return
+	}
+	c.Header("X-Node-ID", h.node.GetNodeID())
+	c.Status(http.StatusOK)
+}
+
+// HandleBinaryDelete 处理 DELETE /api/v1/bin/:key64
+func (h *APIHandlers) HandleBinaryDelete(c *gin.Context) {
+	key, ok := h.binaryKey(c)
+	if !ok {
+		return
+	}
+	// 客户端按拓扑直连时携带版本号，负责节点已变更时返回421
+	if !h.checkRoute(c, key) {
+		return
+	}
+	quorum, ok := h.quorumOverride(c)
+	if !ok {
+		return
+	}
+
+	if err := h.node.DeleteWithQuorum(key, quorum); err != nil {
+		h.sendCacheError(c, err)
+		return
+	}
+	c.Header("X-Node-ID", h.node.GetNodeID())
+	c.Status(http.StatusOK)
+}
+
+// HandleInternalBinaryGet 内部接口：直接读取本地缓存
+func (h *APIHandlers) HandleInternalBinaryGet(c *gin.Context) {
+	key, ok := h.binaryKey(c)
+	if !ok || !h.checkRoute(c, key) {
+		return
+	}
+	value, found := h.node.GetLocal(key)
+	h.writeBinaryValue(c, value, found)
+}
+
+// HandleInternalBinarySet 内部接口：直接写入本地缓存（转发使用）
+func (h *APIHandlers) HandleInternalBinarySet(c *gin.Context) {
+	key, ok := h.binaryKey(c)
+	if !ok || !h.checkRoute(c, key) {
+		return
+	}
+	value, ok := h.binaryValue(c)
+	if !ok {
+		return
+	}
+
+	if err := h.node.SetLocal(key, value); err != nil {
+		h.sendError(c, http.StatusInternalServerError, "cache_error", err.Error())
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// HandleInternalBinaryDelete 内部接口：直接从本地缓存删除
+func (h *APIHandlers) HandleInternalBinaryDelete(c *gin.Context) {
+	key, ok := h.binaryKey(c)
+	if !ok || !h.checkRoute(c, key) {
+		return
+	}
+	h.node.DeleteLocal(key)
+	c.Status(http.StatusOK)
+}
+
+// ===== 多副本 =====
+
+// quorumOverride 解析请求头中的 N/R/W 覆盖值，未携带的字段为0（使用集群配置）
+func (h *APIHandlers) quorumOverride(c *gin.Context) (Quorum, bool) {
+	var override Quorum
+	fields := []struct {
+		header string
+		value  *int
+	}{
+		{QuorumHeaderN, &override.N},
+		{QuorumHeaderR, &override.R},
+		{QuorumHeaderW, &override.W},
+	}
+	for _, field := range fields {
+		raw := c.GetHeader(field.header)
+		if raw == "" {
+			continue
+		}
+		value, err := strconv.Atoi(raw)
+		if err != nil || value <= 0 {
+			h.sendError(c, http.StatusBadRequest, "invalid_quorum", field.header+"必须为正整数: "+raw)
+			return Quorum{}, false
+		}
+		*field.value = value
+	}
+	if _, err := h.node.ResolveQuorum(override); err != nil {
+		h.sendError(c, http.StatusBadRequest, "invalid_quorum", err.Error())
+		return Quorum{}, false
+	}
+	return override, true
+}
+
+// sendCacheError 未达到仲裁数时返回503，其他错误返回500
+func (h *APIHandlers) sendCacheError(c *gin.Context, err error) {
+	if _, ok := err.(*QuorumError); ok {
+		h.sendError(c, http.StatusServiceUnavailable, "quorum_error", err.Error())
+		return
+	}
+	h.sendError(c, http.StatusInternalServerError, "cache_error", err.Error())
+}
+
+// HandleReplicaGet 内部接口：读取本地副本及其版本号，删除标记通过响应头返回
+func (h *APIHandlers) HandleReplicaGet(c *gin.Context) {
+	key, ok := h.binaryKey(c)
+	if !ok {
+		return
+	}
+	entry, found := h.node.GetLocalVersioned(key)
+	if !found {
+		h.sendError(c, http.StatusNotFound, "not_found", "key不存在")
+		return
+	}
+	setEntryHeaders(c.Writer.Header(), entry)
+	c.Data(http.StatusOK, "application/octet-stream", []byte(entry.Value))
+}
+
+// HandleReplicaSet 内部接口：写入带版本号的副本，PUT写入数据，DELETE写入删除标记
+// 数据迁移的请求携带 MigrationHeader，跳过准入策略
+func (h *APIHandlers) HandleReplicaSet(c *gin.Context) {
+	key, ok := h.binaryKey(c)
+	if !ok {
+		return
+	}
+	entry, err := parseEntryHeaders(c.Request.Header)
+	if err != nil {
+		h.sendError(c, http.StatusBadRequest, "invalid_entry", err.Error())
+		return
+	}
+	entry.Tombstone = c.Request.Method == http.MethodDelete
+	if !entry.Tombstone {
+		if entry.Value, ok = h.binaryValue(c); !ok {
+			return
+		}
+	}
+
+	set := h.node.SetLocalVersioned
+	if c.GetHeader(MigrationHeader) != "" {
+		set = h.node.MigrateLocal
+	}
+	if err := set(key, entry); err != nil {
+		h.sendError(c, http.StatusInternalServerError, "cache_error", err.Error())
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// HandleMerkleTree 内部接口：本节点与请求方共同负责的key的Merkle树
+func (h *APIHandlers) HandleMerkleTree(c *gin.Context) {
+	leaves, _ := strconv.Atoi(c.Query("leaves"))
+	c.JSON(http.StatusOK, h.node.MerkleTreeFor(c.Query("peer"), leaves))
+}
+
+// HandleReplicaDigests 内部接口：指定Merkle叶子区间内的key版本摘要
+func (h *APIHandlers) HandleReplicaDigests(c *gin.Context) {
+	var request digestRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.sendError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, h.node.DigestsInRanges(request.Peer, request.Leaves, request.Ranges))
+}
+
+// HandleAntiEntropy 立即执行一轮反熵修复
+func (h *APIHandlers) HandleAntiEntropy(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
-		"status":    status,
-		"node_id":   h.cluster.nodeID,
+		"node_id":   h.node.GetNodeID(),
+		"report":    h.node.RunAntiEntropy(),
 		"timestamp": time.Now().Format(time.RFC3339),
-		"uptime":    time.Now().Unix(),
 	})
 }
 
@@ -192,6 +511,11 @@ func (h *APIHandlers) HandleInternalSet(c *gin.Context) {
 	})
 }
 
+// HandleRPCInfo 返回本节点的二进制RPC地址，供其他节点决定使用RPC还是HTTP
+func (h *APIHandlers) HandleRPCInfo(c *gin.Context) {
+	c.JSON(http.StatusOK, h.node.GetRPCInfo())
+}
+
 // HandleInternalDelete 处理内部DELETE请求
 func (h *APIHandlers) HandleInternalDelete(c *gin.Context) {
 	key := c.Param("key")
@@ -206,6 +530,28 @@ func (h *APIHandlers) HandleInternalDelete(c *gin.Context) {
 	})
 }
 
+// HandleInternalOp 处理内部条件操作请求（直接在本地缓存执行，key为base64url编码）
+func (h *APIHandlers) HandleInternalOp(c *gin.Context) {
+	key, ok := h.binaryKey(c)
+	if !ok || !h.checkRoute(c, key) {
+		return
+	}
+
+	var op CacheOp
+	if err := c.ShouldBindJSON(&op); err != nil {
+		h.sendError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	result, err := h.node.ExecuteLocal(key, op)
+	if err != nil {
+		h.sendError(c, http.StatusBadRequest, "invalid_op", err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
 // HandleNodeJoin 处理节点加入通知
 func (h *APIHandlers) HandleNodeJoin(c *gin.Context) {
 	var joinData map[string]string
@@ -217,8 +563,38 @@ func (h *APIHandlers) HandleNodeJoin(c *gin.Context) {
 	nodeID := joinData["node_id"]
 	address := joinData["address"]
 
+	// 哈希函数不一致的节点不能加入，未携带该字段的旧版本节点视为SHA-1
+	if remote, local := core.NormalizeHasherName(joinData["hash_function"]), h.node.GetHashFunction(); remote != local {
+		h.sendError(c, http.StatusConflict, "hash_function_mismatch",
+			fmt.Sprintf("节点 %s 使用哈希函数 %s，集群使用 %s", nodeID, remote, local))
+		return
+	}
+	if remote, local := core.NormalizePlacementName(joinData["placement"]), h.node.GetPlacement(); remote != local {
+		h.sendError(c, http.StatusConflict, "placement_mismatch",
+			fmt.Sprintf("节点 %s 使用放置算法 %s，集群使用 %s", nodeID, remote, local))
+		return
+	}
+
+	// 权重可选，未携带时使用默认权重
+	var weight float64
+	if raw := joinData["weight"]; raw != "" {
+		parsed, err := strconv.ParseFloat(raw, 64)
+		if err != nil || parsed <= 0 {
+			h.sendError(c, http.StatusBadRequest, "invalid_weight", "weight必须为正数: "+raw)
+			return
+		}
+		weight = parsed
+	}
+
 	// 使用集群协调器添加节点（包含数据迁移和广播）
-	if err := h.coordinator.AddNodeToCluster(nodeID, address); err != nil {
+	request := NodeChangeRequest{
+		NodeID:  nodeID,
+		Address: address,
+		Weight:  weight,
+		Zone:    joinData["zone"],
+		Rack:    joinData["rack"],
+	}
+	if err := h.coordinator.AddNodeToCluster(request); err != nil {
 		h.sendError(c, http.StatusInternalServerError, "add_node_error", err.Error())
 		return
 	}
@@ -253,11 +629,83 @@ func (h *APIHandlers) HandleNodeLeave(c *gin.Context) {
 
 // HandleClusterHealth 处理集群健康检查
 func (h *APIHandlers) HandleClusterHealth(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"status":    "healthy",
-		"node_id":   h.cluster.nodeID,
-		"timestamp": time.Now().Format(time.RFC3339),
-	})
+	response := gin.H{
+		"status":        "healthy",
+		"node_id":       h.cluster.nodeID,
+		"hash_function": h.node.GetHashFunction(),
+		"placement":     h.node.GetPlacement(),
+		"epoch":         h.node.GetRingEpoch(),
+		"ring_checksum": h.node.GetRingChecksum(),
+		"timestamp":     time.Now().Format(time.RFC3339),
+	}
+	// 开启有界负载时公布本节点负载，其他节点的健康检查据此调整哈希环
+	if load, enabled := h.node.LocalLoad(); enabled {
+		response["load"] = load
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// HandleGossip 处理gossip消息（http传输）
+func (h *APIHandlers) HandleGossip(c *gin.Context) {
+	if h.gossip == nil {
+		h.sendError(c, http.StatusNotFound, "gossip_disabled", "本节点未开启gossip")
+		return
+	}
+	var msg gossipMessage
+	if err := c.ShouldBindJSON(&msg); err != nil {
+		h.sendError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, h.gossip.handle(msg))
+}
+
+// HandleRaftVote 处理Raft投票请求
+func (h *APIHandlers) HandleRaftVote(c *gin.Context) {
+	var req RequestVoteRequest
+	if !h.bindRaftRequest(c, &req) {
+		return
+	}
+	c.JSON(http.StatusOK, h.raft.HandleRequestVote(req))
+}
+
+// HandleRaftAppend 处理Raft日志复制和心跳
+func (h *APIHandlers) HandleRaftAppend(c *gin.Context) {
+	var req AppendEntriesRequest
+	if !h.bindRaftRequest(c, &req) {
+		return
+	}
+	c.JSON(http.StatusOK, h.raft.HandleAppendEntries(req))
+}
+
+// HandleRaftPropose 处理其他节点转发给领导者的提议
+func (h *APIHandlers) HandleRaftPropose(c *gin.Context) {
+	var req ProposeRequest
+	if !h.bindRaftRequest(c, &req) {
+		return
+	}
+	c.JSON(http.StatusOK, h.raft.HandlePropose(req))
+}
+
+// HandleRaftSnapshot 处理领导者发送的快照
+func (h *APIHandlers) HandleRaftSnapshot(c *gin.Context) {
+	var req InstallSnapshotRequest
+	if !h.bindRaftRequest(c, &req) {
+		return
+	}
+	c.JSON(http.StatusOK, h.raft.HandleInstallSnapshot(req))
+}
+
+// bindRaftRequest 检查是否开启Raft并解析请求
+func (h *APIHandlers) bindRaftRequest(c *gin.Context, req interface{}) bool {
+	if h.raft == nil {
+		h.sendError(c, http.StatusNotFound, "raft_disabled", "本节点未开启Raft")
+		return false
+	}
+	if err := c.ShouldBindJSON(req); err != nil {
+		h.sendError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return false
+	}
+	return true
 }
 
 // HandleSyncAddNode 处理同步添加节点请求（接收广播）
@@ -268,10 +716,13 @@ func (h *APIHandlers) HandleSyncAddNode(c *gin.Context) {
 		return
 	}
 
-	if err := h.coordinator.SyncAddNode(request.NodeID, request.Address); err != nil {
+	if err := h.coordinator.SyncAddNode(request); err != nil {
 		h.sendError(c, http.StatusInternalServerError, "sync_add_error", err.Error())
 		return
 	}
+	// 应用变更后与发起方的拓扑版本对齐
+	h.node.ObserveRingEpoch(request.Epoch)
+	h.evictor.forget(request.NodeID)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "node synced successfully",
@@ -291,6 +742,12 @@ func (h *APIHandlers) HandleSyncRemoveNode(c *gin.Context) {
 		h.sendError(c, http.StatusInternalServerError, "sync_remove_error", err.Error())
 		return
 	}
+	h.node.ObserveRingEpoch(request.Epoch)
+	// 被驱逐的节点记录下来，领导者变化后由新的领导者负责重新接纳
+	if request.Reason == NodeChangeEvicted {
+		h.evictor.remember(request)
+		h.cluster.RecordEvent(EventNodeEvicted, request.NodeID, "节点已被集群驱逐，移出哈希环")
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "node removed successfully",
@@ -298,6 +755,61 @@ func (h *APIHandlers) HandleSyncRemoveNode(c *gin.Context) {
 	})
 }
 
+// HandleEvictionVote 对驱逐提议投票
+func (h *APIHandlers) HandleEvictionVote(c *gin.Context) {
+	var request map[string]string
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.sendError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, h.evictor.Vote(request["node_id"]))
+}
+
+// HandleReadmit 被驱逐的节点恢复后由领导者通知：清空旧数据并对齐拓扑，之后领导者把它加回哈希环
+func (h *APIHandlers) HandleReadmit(c *gin.Context) {
+	var request ReadmitRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.sendError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	if request.NodeID != h.node.GetNodeID() {
+		h.sendError(c, http.StatusBadRequest, "node_mismatch",
+			fmt.Sprintf("重新接纳的节点为 %s，本节点为 %s", request.NodeID, h.node.GetNodeID()))
+		return
+	}
+	if err := h.evictor.Readmit(request); err != nil {
+		h.sendError(c, http.StatusInternalServerError, "readmit_error", err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "node ready for readmission",
+		"node_id": request.NodeID,
+	})
+}
+
+// HandleSyncNodeWeight 处理同步节点权重请求（接收广播）
+func (h *APIHandlers) HandleSyncNodeWeight(c *gin.Context) {
+	var request NodeChangeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.sendError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	migratedCount, err := h.coordinator.SyncNodeWeight(request.NodeID, request.Weight)
+	if err != nil {
+		h.sendError(c, http.StatusBadRequest, "sync_weight_error", err.Error())
+		return
+	}
+	h.node.ObserveRingEpoch(request.Epoch)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "node weight synced successfully",
+		"node_id":        request.NodeID,
+		"migrated_count": migratedCount,
+	})
+}
+
 // ===== 管理API处理器 =====
 
 // HandleGetCluster 获取集群信息
@@ -307,6 +819,9 @@ func (h *APIHandlers) HandleGetCluster(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"cluster_status": clusterStatus,
 		"current_node":   h.cluster.nodeID,
+		"epoch":          h.node.GetRingEpoch(),
+		"ring_checksum":  h.node.GetRingChecksum(),
+		"topology":       h.node.Topology(),
 		"timestamp":      time.Now().Format(time.RFC3339),
 	})
 }
@@ -318,10 +833,101 @@ func (h *APIHandlers) HandleGetNodes(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"nodes":     nodes,
 		"count":     len(nodes),
+		"weights":   h.node.GetNodeWeights(),
+		"locations": h.node.GetNodeLocations(),
 		"timestamp": time.Now().Format(time.RFC3339),
 	})
 }
 
+// HandleGetMembers 获取gossip成员列表
+func (h *APIHandlers) HandleGetMembers(c *gin.Context) {
+	if h.gossip == nil {
+		h.sendError(c, http.StatusNotFound, "gossip_disabled", "本节点未开启gossip")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"members":   h.gossip.Members(),
+		"gossip":    h.gossip.GetStats(),
+		"node_id":   h.node.GetNodeID(),
+		"timestamp": time.Now().Format(time.RFC3339),
+	})
+}
+
+// HandleGetRaft 获取Raft状态和复制的集群元数据
+func (h *APIHandlers) HandleGetRaft(c *gin.Context) {
+	if h.raft == nil {
+		h.sendError(c, http.StatusNotFound, "raft_disabled", "本节点未开启Raft")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"raft":      h.raft.Status(),
+		"metadata":  h.metadata.snapshot(),
+		"node_id":   h.node.GetNodeID(),
+		"timestamp": time.Now().Format(time.RFC3339),
+	})
+}
+
+// HandleGetEvents 获取最近的集群事件和被驱逐的节点，limit 参数限制返回的事件数
+func (h *APIHandlers) HandleGetEvents(c *gin.Context) {
+	limit := 0
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 0 {
+			h.sendError(c, http.StatusBadRequest, "invalid_limit", "limit必须为非负整数: "+raw)
+			return
+		}
+		limit = parsed
+	}
+
+	events := h.cluster.GetEvents(limit)
+	c.JSON(http.StatusOK, gin.H{
+		"events":    events,
+		"count":     len(events),
+		"evicted":   h.evictor.Evicted(),
+		"node_id":   h.node.GetNodeID(),
+		"timestamp": time.Now().Format(time.RFC3339),
+	})
+}
+
+// NodeWeightRequest 节点权重修改请求
+type NodeWeightRequest struct {
+	Weight float64 `json:"weight" binding:"required"`
+}
+
+// HandleSetNodeWeight 在线修改节点权重，只迁移受影响区间的数据
+func (h *APIHandlers) HandleSetNodeWeight(c *gin.Context) {
+	nodeID := c.Param("node_id")
+
+	var request NodeWeightRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.sendError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	if request.Weight <= 0 {
+		h.sendError(c, http.StatusBadRequest, "invalid_weight", "weight必须为正数")
+		return
+	}
+	if _, exists := h.node.GetClusterNodes()[nodeID]; !exists {
+		h.sendError(c, http.StatusNotFound, "node_not_found", "节点不存在: "+nodeID)
+		return
+	}
+
+	migratedCount, err := h.coordinator.UpdateNodeWeight(nodeID, request.Weight)
+	if err != nil {
+		h.sendError(c, http.StatusBadRequest, "update_weight_error", err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "node weight updated",
+		"node_id":        nodeID,
+		"weight":         request.Weight,
+		"virtual_nodes":  h.node.hashRing.GetVirtualNodeCount(nodeID),
+		"migrated_count": migratedCount,
+		"timestamp":      time.Now().Format(time.RFC3339),
+	})
+}
+
 // HandleRebalance 处理集群重平衡
 func (h *APIHandlers) HandleRebalance(c *gin.Context) {
 	if err := h.coordinator.TriggerRebalance(); err != nil {
@@ -342,15 +948,176 @@ func (h *APIHandlers) HandleGetMetrics(c *gin.Context) {
 	migrationStats := h.coordinator.GetMigrationStats()
 	clusterStats := h.cluster.GetClusterStatus()
 
-	c.JSON(http.StatusOK, gin.H{
+	metrics := gin.H{
 		"node_id":         h.node.GetNodeID(),
 		"cache_stats":     cacheStats,
 		"migration_stats": migrationStats,
 		"cluster_stats":   clusterStats,
+		"bounded_load":    h.node.GetBoundedLoadStats(),
+		"replication":     h.node.GetReplicationStats(),
+		"hinted_handoff":  h.node.GetHintStats(),
+		"anti_entropy":    h.node.GetAntiEntropyStats(),
 		"timestamp":       time.Now().Format(time.RFC3339),
+	}
+	if h.gossip != nil {
+		metrics["gossip"] = h.gossip.GetStats()
+	}
+	if h.raft != nil {
+		metrics["raft"] = h.raft.Status()
+	}
+	c.JSON(http.StatusOK, metrics)
+}
+
+// ConfigUpdateRequest 运行时配置更新请求，未提供的字段保持不变
+type ConfigUpdateRequest struct {
+	CacheSize   *int   `json:"cache_size"`
+	MemoryLimit *int64 `json:"memory_limit"`
+}
+
+// HandleGetConfig 获取本地缓存运行时配置
+func (h *APIHandlers) HandleGetConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"node_id":   h.node.GetNodeID(),
+		"config":    h.node.GetCacheConfig(),
+		"timestamp": time.Now().Format(time.RFC3339),
+	})
+}
+
+// HandleUpdateConfig 运行时调整缓存容量和内存限制，无需重启
+func (h *APIHandlers) HandleUpdateConfig(c *gin.Context) {
+	var req ConfigUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.sendError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	if req.CacheSize == nil && req.MemoryLimit == nil {
+		h.sendError(c, http.StatusBadRequest, "invalid_request", "cache_size 和 memory_limit 至少提供一个")
+		return
+	}
+
+	// scope=cluster 时写入Raft日志，所有节点按相同顺序应用
+	if c.Query("scope") == "cluster" {
+		if h.metadata == nil {
+			h.sendError(c, http.StatusBadRequest, "raft_disabled", "集群级配置需要开启Raft")
+			return
+		}
+		evicted, err := h.metadata.proposeConfig(req)
+		if err != nil {
+			h.sendError(c, http.StatusBadRequest, "invalid_config", err.Error())
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message":      "cluster config updated",
+			"node_id":      h.node.GetNodeID(),
+			"config":       h.node.GetCacheConfig(),
+			"evicted_keys": evicted,
+			"timestamp":    time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	evicted := 0
+	if req.CacheSize != nil {
+		count, err := h.node.ResizeCache(*req.CacheSize)
+		if err != nil {
+			h.sendError(c, http.StatusBadRequest, "invalid_config", err.Error())
+			return
+		}
+		evicted += count
+	}
+	if req.MemoryLimit != nil {
+		count, err := h.node.SetCacheMemoryLimit(*req.MemoryLimit)
+		if err != nil {
+			h.sendError(c, http.StatusBadRequest, "invalid_config", err.Error())
+			return
+		}
+		evicted += count
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "config updated",
+		"node_id":      h.node.GetNodeID(),
+		"config":       h.node.GetCacheConfig(),
+		"evicted_keys": evicted,
+		"timestamp":    time.Now().Format(time.RFC3339),
 	})
 }
 
+// HandleInspectKey 查询key的元数据（创建时间、访问时间、访问次数、大小、TTL），路由到负责节点
+func (h *APIHandlers) HandleInspectKey(c *gin.Context) {
+	h.inspectKey(c, c.Param("key"))
+}
+
+// HandleInspectBinaryKey 按base64url编码的key查询元数据，用于包含'/'或非UTF-8字节的key
+func (h *APIHandlers) HandleInspectBinaryKey(c *gin.Context) {
+	key, ok := h.binaryKey(c)
+	if !ok {
+		return
+	}
+	h.inspectKey(c, key)
+}
+
+// inspectKey 查询key的元数据并返回，key不存在时返回404
+func (h *APIHandlers) inspectKey(c *gin.Context, key string) {
+	result, err := h.node.InspectKey(key)
+	if err != nil {
+		h.sendError(c, http.StatusBadGateway, "inspect_error", err.Error())
+		return
+	}
+
+	status := http.StatusOK
+	if !result.Found {
+		status = http.StatusNotFound
+	}
+	c.JSON(status, result)
+}
+
+// HandleLargeKeys 每个节点占用内存最大的N个key
+func (h *APIHandlers) HandleLargeKeys(c *gin.Context) {
+	n, ok := h.parseTopN(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"top":       n,
+		"nodes":     h.node.LargestKeysByNode(n),
+		"timestamp": time.Now().Format(time.RFC3339),
+	})
+}
+
+// HandleInternalInspectKey 内部接口：查询本地key元数据（key为base64url编码）
+func (h *APIHandlers) HandleInternalInspectKey(c *gin.Context) {
+	key, ok := h.binaryKey(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, h.node.InspectLocal(key))
+}
+
+// HandleInternalLargeKeys 内部接口：本地大key报告
+func (h *APIHandlers) HandleInternalLargeKeys(c *gin.Context) {
+	n, ok := h.parseTopN(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, LargeKeysReport{
+		NodeID: h.node.GetNodeID(),
+		Keys:   h.node.LargestLocalKeys(n),
+	})
+}
+
+// parseTopN 解析查询参数 n（默认10，最大1000）
+func (h *APIHandlers) parseTopN(c *gin.Context) (int, bool) {
+	n, err := strconv.Atoi(c.DefaultQuery("n", "10"))
+	if err != nil || n <= 0 || n > 1000 {
+		h.sendError(c, http.StatusBadRequest, "invalid_request", "n 必须是 1-1000 之间的整数")
+		return 0, false
+	}
+	return n, true
+}
+
 // ===== 辅助方法 =====
 
 // sendError 发送错误响应
@@ -0,0 +1,194 @@
+package distributed
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// 批量操作 - 按负责节点分组，每个远端节点一次RPC请求（对端不支持RPC时逐个key通过HTTP转发）
+// 开启多副本时逐个key按仲裁读写
+
+// BatchSet 批量设置缓存数据
+func (dn *DistributedNode) BatchSet(data map[string]string) error {
+	if dn.replicated() {
+		for key, value := range data {
+			if err := dn.Set(key, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+
+	return dn.forEachNodeGroup(keys, func(nodeID, address string, keys []string) error {
+		group := make(map[string]string, len(keys))
+		for _, key := range keys {
+			group[key] = data[key]
+		}
+
+		if nodeID == dn.nodeID {
+			for key, value := range group {
+				if err := dn.SetLocal(key, value); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		if handled, err := dn.forwardViaRPC(address, func(pool *rpcPool) error {
+			return pool.batchSet(group)
+		}); handled {
+			return err
+		}
+		for key, value := range group {
+			if err := dn.forwardSetRequestSafe(address, key, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// BatchGet 批量获取缓存数据，结果只包含存在的key
+func (dn *DistributedNode) BatchGet(keys []string) (map[string]string, error) {
+	result := make(map[string]string, len(keys))
+	if dn.replicated() {
+		for _, key := range keys {
+			value, found, err := dn.Get(key)
+			if err != nil {
+				return nil, err
+			}
+			if found {
+				result[key] = value
+			}
+		}
+		return result, nil
+	}
+
+	var resultMu sync.Mutex
+
+	err := dn.forEachNodeGroup(keys, func(nodeID, address string, keys []string) error {
+		values := make(map[string]string, len(keys))
+
+		if nodeID == dn.nodeID {
+			dn.recordRequests(len(keys))
+			for _, key := range keys {
+				if value, found := dn.localCache.Get(key); found {
+					values[key] = value
+				}
+			}
+		} else if handled, err := dn.forwardViaRPC(address, func(pool *rpcPool) (err error) {
+			values, err = pool.batchGet(keys)
+			return err
+		}); handled {
+			if err != nil {
+				return err
+			}
+		} else {
+			for _, key := range keys {
+				value, found, err := dn.forwardGetRequestSafe(address, key)
+				if err != nil {
+					return err
+				}
+				if found {
+					values[key] = value
+				}
+			}
+		}
+
+		resultMu.Lock()
+		for key, value := range values {
+			result[key] = value
+		}
+		resultMu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// BatchDelete 批量删除缓存数据
+func (dn *DistributedNode) BatchDelete(keys []string) error {
+	if dn.replicated() {
+		for _, key := range keys {
+			if err := dn.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return dn.forEachNodeGroup(keys, func(nodeID, address string, keys []string) error {
+		if nodeID == dn.nodeID {
+			dn.recordRequests(len(keys))
+			for _, key := range keys {
+				dn.deleteLocal(key)
+			}
+			return nil
+		}
+
+		if handled, err := dn.forwardViaRPC(address, func(pool *rpcPool) error {
+			return pool.batchDelete(keys)
+		}); handled {
+			return err
+		}
+		for _, key := range keys {
+			if err := dn.forwardDeleteRequestSafe(address, key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// forEachNodeGroup 按负责节点分组并行执行，返回按节点ID排序后的第一个错误
+func (dn *DistributedNode) forEachNodeGroup(keys []string, fn func(nodeID, address string, keys []string) error) error {
+	groups := make(map[string][]string)
+	for _, key := range keys {
+		nodeID := dn.hashRing.GetNodeForKey(key)
+		groups[nodeID] = append(groups[nodeID], key)
+	}
+
+	nodeIDs := make([]string, 0, len(groups))
+	for nodeID := range groups {
+		nodeIDs = append(nodeIDs, nodeID)
+	}
+	sort.Strings(nodeIDs)
+
+	dn.mu.RLock()
+	addresses := make(map[string]string, len(nodeIDs))
+	for _, nodeID := range nodeIDs {
+		addresses[nodeID] = dn.clusterNodes[nodeID]
+	}
+	dn.mu.RUnlock()
+
+	errs := make([]error, len(nodeIDs))
+	var wg sync.WaitGroup
+	for i, nodeID := range nodeIDs {
+		if nodeID != dn.nodeID && addresses[nodeID] == "" {
+			errs[i] = fmt.Errorf("目标节点不存在: %s", nodeID)
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, nodeID string) {
+			defer wg.Done()
+			errs[i] = fn(nodeID, addresses[nodeID], groups[nodeID])
+		}(i, nodeID)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
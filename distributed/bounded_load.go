@@ -0,0 +1,117 @@
+package distributed
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"tdd-learning/core"
+)
+
+// 有界负载 - 哈希环跳过负载超过 (1+ε)×平均值 的节点
+// 每个节点在健康检查响应中公布自己的负载（key数量或请求速率），
+// 健康检查循环把自己和对端的负载上报给本地哈希环，各节点据此做出相同的选择。
+
+// requestRateMeterAlpha 请求速率的指数平滑系数
+const requestRateMeterAlpha = 0.5
+
+// requestRateMeter 本地请求速率统计
+type requestRateMeter struct {
+	count uint64 // 累计请求数，原子操作
+
+	mu        sync.Mutex
+	lastTime  time.Time
+	lastCount uint64
+	rate      float64 // 平滑后的每秒请求数
+}
+
+func newRequestRateMeter() *requestRateMeter {
+	return &requestRateMeter{lastTime: time.Now()}
+}
+
+// Record 记录n次请求
+func (m *requestRateMeter) Record(n int) {
+	atomic.AddUint64(&m.count, uint64(n))
+}
+
+// Rate 获取每秒请求数，距上次计算不足1秒时返回上次的结果
+func (m *requestRateMeter) Rate() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	elapsed := now.Sub(m.lastTime).Seconds()
+	if elapsed < 1 {
+		return m.rate
+	}
+
+	count := atomic.LoadUint64(&m.count)
+	current := float64(count-m.lastCount) / elapsed
+	if m.lastCount == 0 && m.rate == 0 {
+		m.rate = current
+	} else {
+		m.rate = requestRateMeterAlpha*current + (1-requestRateMeterAlpha)*m.rate
+	}
+	m.lastTime, m.lastCount = now, count
+	return m.rate
+}
+
+// enableBoundedLoad 按配置开启有界负载，配置无效时保持关闭
+func (dn *DistributedNode) enableBoundedLoad(epsilon float64, mode string) {
+	mode, err := core.NormalizeBoundedLoadMode(mode)
+	if err != nil {
+		log.Printf("⚠️ %v，未开启有界负载", err)
+		return
+	}
+	if err := dn.hashRing.EnableBoundedLoad(epsilon); err != nil {
+		log.Printf("⚠️ %v，未开启有界负载", err)
+		return
+	}
+	dn.loadMode = mode
+	if mode == core.BoundedLoadModeRequests {
+		dn.requestMeter = newRequestRateMeter()
+	}
+	log.Printf("⚖️ 已开启有界负载: ε=%.2f, 负载度量=%s", epsilon, mode)
+}
+
+// recordRequests 记录本地处理的请求数，用于按请求速率度量负载
+func (dn *DistributedNode) recordRequests(n int) {
+	if dn.requestMeter != nil {
+		dn.requestMeter.Record(n)
+	}
+}
+
+// LocalLoad 获取本节点当前负载，未开启有界负载时返回false
+func (dn *DistributedNode) LocalLoad() (float64, bool) {
+	switch dn.loadMode {
+	case core.BoundedLoadModeKeys:
+		return float64(dn.localCache.Size()), true
+	case core.BoundedLoadModeRequests:
+		return dn.requestMeter.Rate(), true
+	}
+	return 0, false
+}
+
+// ReportNodeLoad 上报节点负载到哈希环
+func (dn *DistributedNode) ReportNodeLoad(nodeID string, load float64) {
+	dn.hashRing.ReportNodeLoad(nodeID, load)
+}
+
+// GetBoundedLoadStats 获取有界负载统计
+func (dn *DistributedNode) GetBoundedLoadStats() map[string]interface{} {
+	stats := dn.hashRing.GetBoundedLoadStats()
+	if !stats.Enabled {
+		return map[string]interface{}{"enabled": false}
+	}
+	return map[string]interface{}{
+		"enabled":        true,
+		"mode":           dn.loadMode,
+		"epsilon":        stats.Epsilon,
+		"loads":          stats.Loads,
+		"capacities":     stats.Capacities,
+		"average_load":   stats.AverageLoad,
+		"max_load_ratio": stats.MaxLoadRatio,
+		"redirects":      stats.Redirects,
+	}
+}
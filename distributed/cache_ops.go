@@ -0,0 +1,406 @@
+package distributed
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"tdd-learning/core"
+)
+
+// 条件缓存操作 - memcached / Redis 协议共用
+// 所有操作都在负责该key的节点上原子执行，非本地key会转发到目标节点
+// （优先使用二进制RPC，否则 POST /internal/op/:key64，key为base64url编码，JSON中的value为base64编码）
+// 开启多副本时读取、写入和删除按仲裁读写所有副本；条件操作（CAS、自增等）在主副本上执行后，
+// 结果作为带版本的写入复制到其他副本
+
+// 操作类型
+const (
+	OpGet     = "get"
+	OpSet     = "set"
+	OpAdd     = "add"     // 仅当key不存在时写入
+	OpReplace = "replace" // 仅当key存在时写入
+	OpCAS     = "cas"     // 版本号匹配时写入
+	OpIncr    = "incr"
+	OpDecr    = "decr"
+	OpIncrBy  = "incrby" // 有符号增量，key不存在时按0计算（Redis语义）
+	OpTouch   = "touch"  // 只修改过期时间
+	OpDelete  = "delete"
+)
+
+// 操作结果状态
+const (
+	OpStatusFound     = "found"
+	OpStatusStored    = "stored"
+	OpStatusNotStored = "not_stored"
+	OpStatusExists    = "exists" // CAS版本不匹配
+	OpStatusNotFound  = "not_found"
+	OpStatusDeleted   = "deleted"
+	OpStatusTouched   = "touched"
+	OpStatusInvalid   = "invalid" // 例如对非数字值执行incr
+)
+
+// CacheOp 缓存操作
+type CacheOp struct {
+	Op        string `json:"op"`
+	Value     string `json:"value,omitempty"`
+	Flags     uint32 `json:"flags,omitempty"`
+	TTLMillis int64  `json:"ttl_ms,omitempty"`   // 过期时间（毫秒），0表示永不过期
+	KeepTTL   bool   `json:"keep_ttl,omitempty"` // 写入时保留原有过期时间
+	Expired   bool   `json:"expired,omitempty"`  // 写入时已经过期：条件满足时删除key（memcached 的过期exptime）
+	CAS       uint64 `json:"cas,omitempty"`      // OpCAS 期望的版本号
+	Delta     uint64 `json:"delta,omitempty"`    // OpIncr / OpDecr 的增量
+	Amount    int64  `json:"amount,omitempty"`   // OpIncrBy 的有符号增量
+}
+
+// CacheOpResult 缓存操作结果
+type CacheOpResult struct {
+	Status string `json:"status"`
+	Value  string `json:"value,omitempty"`
+	Flags  uint32 `json:"flags,omitempty"`
+	CAS    uint64 `json:"cas,omitempty"`
+	NodeID string `json:"node_id"`
+}
+
+// MarshalJSON value按base64编码，保证任意字节都能无损传输
+func (op CacheOp) MarshalJSON() ([]byte, error) {
+	type alias CacheOp
+	return json.Marshal(struct {
+		alias
+		Value []byte `json:"value,omitempty"`
+	}{alias(op), []byte(op.Value)})
+}
+
+// UnmarshalJSON 与 MarshalJSON 对应
+func (op *CacheOp) UnmarshalJSON(data []byte) error {
+	type alias CacheOp
+	aux := struct {
+		*alias
+		Value []byte `json:"value,omitempty"`
+	}{alias: (*alias)(op)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	op.Value = string(aux.Value)
+	return nil
+}
+
+// MarshalJSON value按base64编码，保证任意字节都能无损传输
+func (r CacheOpResult) MarshalJSON() ([]byte, error) {
+	type alias CacheOpResult
+	return json.Marshal(struct {
+		alias
+		Value []byte `json:"value,omitempty"`
+	}{alias(r), []byte(r.Value)})
+}
+
+// UnmarshalJSON 与 MarshalJSON 对应
+func (r *CacheOpResult) UnmarshalJSON(data []byte) error {
+	type alias CacheOpResult
+	aux := struct {
+		*alias
+		Value []byte `json:"value,omitempty"`
+	}{alias: (*alias)(r)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	r.Value = string(aux.Value)
+	return nil
+}
+
+// ttl 计算写入时使用的过期时间
+func (op CacheOp) ttl() time.Duration {
+	if op.Expired {
+		return core.DeleteTTL
+	}
+	if op.KeepTTL {
+		return core.KeepTTL
+	}
+	return time.Duration(op.TTLMillis) * time.Millisecond
+}
+
+// Execute 执行缓存操作，自动路由到负责该key的节点
+func (dn *DistributedNode) Execute(key string, op CacheOp) (CacheOpResult, error) {
+	if dn.replicated() {
+		switch {
+		case op.Op == OpGet:
+			return dn.getReplicated(key)
+		case op.Op == OpSet && !op.KeepTTL && !op.Expired:
+			return dn.setReplicated(key, op)
+		case op.Op == OpDelete:
+			return dn.deleteReplicated(key)
+		}
+	}
+	return dn.executeOnPrimary(key, op)
+}
+
+// executeOnPrimary 在负责该key的节点（主副本）上执行操作
+func (dn *DistributedNode) executeOnPrimary(key string, op CacheOp) (CacheOpResult, error) {
+	targetNodeID := dn.hashRing.GetNodeForKey(key)
+	if targetNodeID == dn.nodeID {
+		return dn.ExecuteLocal(key, op)
+	}
+
+	dn.mu.RLock()
+	targetAddress, exists := dn.clusterNodes[targetNodeID]
+	dn.mu.RUnlock()
+
+	if !exists {
+		return CacheOpResult{}, fmt.Errorf("目标节点不存在: %s", targetNodeID)
+	}
+
+	var result CacheOpResult
+	err := dn.forwardWithRedirect(targetAddress, func(address string) (err error) {
+		result, err = dn.forwardOpRequestSafe(address, key, op)
+		return err
+	})
+	return result, err
+}
+
+// SetWithTTL 设置带过期时间的缓存数据
+func (dn *DistributedNode) SetWithTTL(key, value string, ttl time.Duration) error {
+	_, err := dn.Execute(key, CacheOp{Op: OpSet, Value: value, TTLMillis: ttl.Milliseconds()})
+	return err
+}
+
+// getReplicated 按仲裁读取，返回版本号最新的条目
+func (dn *DistributedNode) getReplicated(key string) (CacheOpResult, error) {
+	result := CacheOpResult{NodeID: dn.nodeID, Status: OpStatusNotFound}
+	entry, found, err := dn.GetEntryWithQuorum(key, Quorum{})
+	if err != nil || !found {
+		return result, err
+	}
+	result.Status = OpStatusFound
+	result.Value, result.Flags, result.CAS = entry.Value, entry.Flags, entry.Version
+	return result, nil
+}
+
+// setReplicated 按仲裁写入，标志和过期时间随版本一起复制
+func (dn *DistributedNode) setReplicated(key string, op CacheOp) (CacheOpResult, error) {
+	entry, err := dn.SetEntryWithQuorum(key, core.VersionedEntry{
+		Value:     op.Value,
+		Flags:     op.Flags,
+		ExpiresAt: core.ExpiresAtAfter(op.ttl()),
+	}, Quorum{})
+	if err != nil {
+		return CacheOpResult{NodeID: dn.nodeID}, err
+	}
+	return CacheOpResult{
+		Status: OpStatusStored,
+		Value:  entry.Value,
+		Flags:  entry.Flags,
+		CAS:    entry.Version,
+		NodeID: dn.nodeID,
+	}, nil
+}
+
+// deleteReplicated 按仲裁写入删除标记，按删除前的仲裁读结果返回是否存在
+func (dn *DistributedNode) deleteReplicated(key string) (CacheOpResult, error) {
+	result := CacheOpResult{NodeID: dn.nodeID, Status: OpStatusNotFound}
+	_, found, err := dn.GetEntryWithQuorum(key, Quorum{})
+	if err != nil {
+		return result, err
+	}
+	if err := dn.DeleteWithQuorum(key, Quorum{}); err != nil {
+		return result, err
+	}
+	if found {
+		result.Status = OpStatusDeleted
+	}
+	return result, nil
+}
+
+// ExecuteLocal 在本地缓存上原子执行操作 - 用于内部API
+// 开启多副本时写入结果按仲裁复制到其他副本
+func (dn *DistributedNode) ExecuteLocal(key string, op CacheOp) (CacheOpResult, error) {
+	result := CacheOpResult{NodeID: dn.nodeID}
+	dn.recordRequests(1)
+
+	switch op.Op {
+	case OpGet:
+		entry, found := dn.localCache.GetEntry(key)
+		if !found {
+			result.Status = OpStatusNotFound
+			return result, nil
+		}
+		result.Status = OpStatusFound
+		result.Value, result.Flags, result.CAS = entry.Value, entry.Flags, entry.Version
+		return result, nil
+
+	case OpDelete:
+		tombstone, found := dn.deleteLocal(key)
+		if found {
+			result.Status = OpStatusDeleted
+		} else {
+			result.Status = OpStatusNotFound
+		}
+		return result, dn.replicateLocal(key, tombstone)
+
+	case OpTouch:
+		if dn.replicated() {
+			// 修改过期时间也要分配新版本，其他副本才会接受
+			return dn.replicateUpdate(key, dn.updateLocal(key, op))
+		}
+		if dn.localCache.Expire(key, op.ttl()) {
+			result.Status = OpStatusTouched
+		} else {
+			result.Status = OpStatusNotFound
+		}
+		return result, nil
+
+	case OpSet, OpAdd, OpReplace, OpCAS, OpIncr, OpDecr, OpIncrBy:
+		return dn.replicateUpdate(key, dn.updateLocal(key, op))
+	}
+
+	return result, fmt.Errorf("未知操作: %s", op.Op)
+}
+
+// replicateUpdate 把本地写入后的条目复制到其他副本
+func (dn *DistributedNode) replicateUpdate(key string, result CacheOpResult) (CacheOpResult, error) {
+	if !dn.replicated() || (result.Status != OpStatusStored && result.Status != OpStatusTouched) {
+		return result, nil
+	}
+	entry, found := dn.localCache.GetVersioned(key)
+	if !found {
+		return result, nil
+	}
+	return result, dn.replicateLocal(key, entry)
+}
+
+// replicateLocal 开启多副本时把本地已写入的条目按仲裁写入所有副本（本地副本版本相同，不会重复写入）
+func (dn *DistributedNode) replicateLocal(key string, entry core.VersionedEntry) error {
+	if !dn.replicated() {
+		return nil
+	}
+	return dn.writeQuorum(key, entry, dn.quorum)
+}
+
+// updateLocal 执行写类操作
+func (dn *DistributedNode) updateLocal(key string, op CacheOp) CacheOpResult {
+	result := CacheOpResult{NodeID: dn.nodeID}
+
+	entry, written := dn.localCache.Update(key, func(current core.Entry, found bool) (core.Entry, time.Duration, bool) {
+		next := core.Entry{Value: op.Value, Flags: op.Flags}
+
+		switch op.Op {
+		case OpTouch:
+			if !found {
+				result.Status = OpStatusNotFound
+				return current, 0, false
+			}
+			return current, op.ttl(), true
+		case OpAdd:
+			if found {
+				result.Status = OpStatusNotStored
+				return current, 0, false
+			}
+		case OpReplace:
+			if !found {
+				result.Status = OpStatusNotStored
+				return current, 0, false
+			}
+		case OpCAS:
+			if !found {
+				result.Status = OpStatusNotFound
+				return current, 0, false
+			}
+			if current.Version != op.CAS {
+				result.Status = OpStatusExists
+				return current, 0, false
+			}
+		case OpIncr, OpDecr:
+			if !found {
+				result.Status = OpStatusNotFound
+				return current, 0, false
+			}
+			number, err := strconv.ParseUint(current.Value, 10, 64)
+			if err != nil {
+				result.Status = OpStatusInvalid
+				return current, 0, false
+			}
+			if op.Op == OpIncr {
+				number += op.Delta // 与memcached一致，溢出时回绕
+			} else if op.Delta > number {
+				number = 0 // 与memcached一致，不会减到负数
+			} else {
+				number -= op.Delta
+			}
+			// 数值操作保留原有标志和过期时间
+			return core.Entry{Value: strconv.FormatUint(number, 10), Flags: current.Flags}, core.KeepTTL, true
+		case OpIncrBy:
+			var number int64
+			if found {
+				var err error
+				if number, err = strconv.ParseInt(current.Value, 10, 64); err != nil {
+					result.Status = OpStatusInvalid
+					return current, 0, false
+				}
+			}
+			// 与Redis一致，溢出时报错而不是回绕
+			if (op.Amount > 0 && number > math.MaxInt64-op.Amount) || (op.Amount < 0 && number < math.MinInt64-op.Amount) {
+				result.Status = OpStatusInvalid
+				return current, 0, false
+			}
+			return core.Entry{Value: strconv.FormatInt(number+op.Amount, 10), Flags: current.Flags}, core.KeepTTL, true
+		}
+
+		return next, op.ttl(), true
+	})
+
+	if written && op.Op == OpTouch {
+		result.Status = OpStatusTouched
+	} else if written {
+		result.Status = OpStatusStored
+		result.Value, result.Flags, result.CAS = entry.Value, entry.Flags, entry.Version
+	} else if result.Status == "" {
+		// 被内存限制或准入策略拒绝
+		result.Status = OpStatusNotStored
+	}
+	return result
+}
+
+// forwardOpRequestSafe 转发缓存操作到目标节点（线程安全版本）
+func (dn *DistributedNode) forwardOpRequestSafe(targetAddress, key string, op CacheOp) (CacheOpResult, error) {
+	var result CacheOpResult
+	if handled, err := dn.forwardViaRPC(targetAddress, func(pool *rpcPool) (err error) {
+		result, err = pool.execute(key, op, dn.hashRing.Epoch())
+		return err
+	}); handled {
+		return result, err
+	}
+
+	jsonData, err := json.Marshal(op)
+	if err != nil {
+		return CacheOpResult{}, fmt.Errorf("序列化请求失败: %v", err)
+	}
+
+	url := fmt.Sprintf("http://%s/internal/op/%s", targetAddress, core.EncodeKey(key))
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return CacheOpResult{}, fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	dn.setEpochHeader(req)
+
+	resp, err := dn.httpClient.Do(req)
+	if err != nil {
+		return CacheOpResult{}, fmt.Errorf("转发请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusMisdirectedRequest {
+		return CacheOpResult{}, decodeStaleRoute(resp)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return CacheOpResult{}, fmt.Errorf("目标节点返回错误: %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return CacheOpResult{}, fmt.Errorf("解析响应失败: %v", err)
+	}
+	return result, nil
+}
@@ -5,10 +5,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"slices"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"tdd-learning/core"
 )
 
 // NodeStatus 节点状态
@@ -52,6 +57,9 @@ type DistributedClient struct {
 	// 新增：节点管理
 	nodeManager  *NodeManager
 	config       ClientConfig
+
+	// 按拓扑直连负责节点，ProxyOnly 时为nil
+	router       *clientRouter
 }
 
 // ClientConfig 客户端配置
@@ -65,6 +73,12 @@ type ClientConfig struct {
 	HealthCheckInterval   time.Duration `yaml:"health_check_interval"`   // 默认30s
 	FailureThreshold      int           `yaml:"failure_threshold"`       // 默认3次失败
 	RecoveryCheckInterval time.Duration `yaml:"recovery_check_interval"` // 默认60s
+
+	// 多副本读写的默认仲裁参数，0表示使用集群配置
+	Quorum                Quorum        `yaml:"quorum"`
+
+	// 只经任意节点转发，不按集群拓扑直连负责节点
+	ProxyOnly             bool          `yaml:"proxy_only"`
 }
 
 // NewDistributedClient 创建分布式缓存客户端
@@ -101,6 +115,10 @@ func NewDistributedClient(config ClientConfig) *DistributedClient {
 	// 创建节点管理器
 	client.nodeManager = NewNodeManager(config)
 
+	if !config.ProxyOnly {
+		client.router = newClientRouter(client.httpClient, client.nodeManager.GetHealthyNodes)
+	}
+
 	return client
 }
 
@@ -234,6 +252,22 @@ func (dc *DistributedClient) GetNodeStatus() map[string]*NodeStatus {
 	return status
 }
 
+// GetRoutingStats 获取直连路由统计
+func (dc *DistributedClient) GetRoutingStats() ClientRoutingStats {
+	if dc.router == nil {
+		return ClientRoutingStats{}
+	}
+	return dc.router.stats()
+}
+
+// RefreshTopology 立即从节点获取集群拓扑
+func (dc *DistributedClient) RefreshTopology() error {
+	if dc.router == nil {
+		return fmt.Errorf("客户端未开启直连路由")
+	}
+	return dc.router.refresh(0)
+}
+
 // GetTimeout 获取客户端超时时间
 func (dc *DistributedClient) GetTimeout() time.Duration {
 	return dc.timeout
@@ -241,20 +275,35 @@ func (dc *DistributedClient) GetTimeout() time.Duration {
 
 // Set 设置缓存
 func (dc *DistributedClient) Set(key, value string) error {
+	return dc.SetWithQuorum(key, value, dc.config.Quorum)
+}
+
+// Get 获取缓存
+func (dc *DistributedClient) Get(key string) (string, bool, error) {
+	return dc.GetWithQuorum(key, dc.config.Quorum)
+}
+
+// Delete 删除缓存
+func (dc *DistributedClient) Delete(key string) error {
+	return dc.DeleteWithQuorum(key, dc.config.Quorum)
+}
+
+// SetWithQuorum 设置缓存，按指定的副本数和写仲裁数（0表示使用集群配置）
+func (dc *DistributedClient) SetWithQuorum(key, value string, quorum Quorum) error {
 	req := CacheRequest{Value: value}
 	
-	return dc.executeWithRetry(func(node string) error {
-		return dc.setToNode(node, key, req)
+	return dc.executeOnOwner(key, func(node string, direct bool) error {
+		return dc.setToNode(node, key, req, quorum, direct)
 	})
 }
 
-// Get 获取缓存
-func (dc *DistributedClient) Get(key string) (string, bool, error) {
+// GetWithQuorum 获取缓存，按指定的副本数和读仲裁数（0表示使用集群配置）
+func (dc *DistributedClient) GetWithQuorum(key string, quorum Quorum) (string, bool, error) {
 	var result string
 	var found bool
 	
-	err := dc.executeWithRetry(func(node string) error {
-		value, exists, err := dc.getFromNode(node, key)
+	err := dc.executeOnOwner(key, func(node string, direct bool) error {
+		value, exists, err := dc.getFromNode(node, key, quorum, direct)
 		if err != nil {
 			return err
 		}
@@ -266,10 +315,42 @@ func (dc *DistributedClient) Get(key string) (string, bool, error) {
 	return result, found, err
 }
 
-// Delete 删除缓存
-func (dc *DistributedClient) Delete(key string) error {
-	return dc.executeWithRetry(func(node string) error {
-		return dc.deleteFromNode(node, key)
+// DeleteWithQuorum 删除缓存，按指定的副本数和写仲裁数（0表示使用集群配置）
+func (dc *DistributedClient) DeleteWithQuorum(key string, quorum Quorum) error {
+	return dc.executeOnOwner(key, func(node string, direct bool) error {
+		return dc.deleteFromNode(node, key, quorum, direct)
+	})
+}
+
+// SetBytes 设置缓存（二进制安全：key和value可以包含任意字节）
+func (dc *DistributedClient) SetBytes(key, value []byte) error {
+	return dc.executeOnOwner(string(key), func(node string, direct bool) error {
+		return dc.setBinaryToNode(node, key, value, direct)
+	})
+}
+
+// GetBytes 获取缓存（二进制安全）
+func (dc *DistributedClient) GetBytes(key []byte) ([]byte, bool, error) {
+	var result []byte
+	var found bool
+
+	err := dc.executeOnOwner(string(key), func(node string, direct bool) error {
+		value, exists, err := dc.getBinaryFromNode(node, key, direct)
+		if err != nil {
+			return err
+		}
+		result = value
+		found = exists
+		return nil
+	})
+
+	return result, found, err
+}
+
+// DeleteBytes 删除缓存（二进制安全）
+func (dc *DistributedClient) DeleteBytes(key []byte) error {
+	return dc.executeOnOwner(string(key), func(node string, direct bool) error {
+		return dc.deleteBinaryFromNode(node, key, direct)
 	})
 }
 
@@ -354,6 +435,54 @@ func (dc *DistributedClient) executeWithRetry(operation func(string) error) erro
 	return fmt.Errorf("所有节点都不可用，最后错误: %v", lastErr)
 }
 
+// executeOnOwner 执行单key操作：拓扑已知时直接发给负责节点，收到421时刷新拓扑并按重定向重试一次，
+// 拓扑未知或直连失败时回退到经任意节点转发
+func (dc *DistributedClient) executeOnOwner(key string, operation func(node string, direct bool) error) error {
+	if dc.router != nil {
+		if address, ok := dc.router.route(key); ok {
+			err := operation(address, true)
+			if err == nil {
+				atomic.AddUint64(&dc.router.direct, 1)
+				return nil
+			}
+			if redirect, ok := err.(*StaleRouteError); ok {
+				atomic.AddUint64(&dc.router.redirects, 1)
+				if err := dc.router.refresh(redirect.Epoch); err != nil {
+					log.Printf("⚠️ 刷新集群拓扑失败: %v", err)
+				}
+				if redirect.Address != "" && operation(redirect.Address, false) == nil {
+					return nil
+				}
+			}
+		}
+		atomic.AddUint64(&dc.router.proxied, 1)
+	}
+
+	return dc.executeWithRetry(func(node string) error {
+		return operation(node, false)
+	})
+}
+
+// send 发送请求；直连负责节点时携带客户端的拓扑版本号，421重定向转换为 *StaleRouteError
+func (dc *DistributedClient) send(req *http.Request, direct bool) (*http.Response, error) {
+	if direct && dc.router != nil {
+		req.Header.Set(ClusterEpochHeader, strconv.FormatUint(dc.router.epoch(), 10))
+	}
+
+	resp, err := dc.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求失败: %v", err)
+	}
+	if dc.router != nil {
+		dc.router.observe(resp)
+	}
+	if resp.StatusCode == http.StatusMisdirectedRequest {
+		defer resp.Body.Close()
+		return nil, decodeStaleRoute(resp)
+	}
+	return resp, nil
+}
+
 // getNextNode 获取下一个节点（轮询）- 保持向后兼容
 func (dc *DistributedClient) getNextNode() string {
 	dc.mu.Lock()
@@ -390,7 +519,7 @@ func (dc *DistributedClient) getNextHealthyNode() string {
 }
 
 // setToNode 向指定节点设置缓存
-func (dc *DistributedClient) setToNode(node, key string, req CacheRequest) error {
+func (dc *DistributedClient) setToNode(node, key string, req CacheRequest, quorum Quorum, direct bool) error {
 	jsonData, err := json.Marshal(req)
 	if err != nil {
 		return fmt.Errorf("序列化请求失败: %v", err)
@@ -402,10 +531,11 @@ func (dc *DistributedClient) setToNode(node, key string, req CacheRequest) error
 		return fmt.Errorf("创建请求失败: %v", err)
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
+	setQuorumHeaders(httpReq, quorum)
 	
-	resp, err := dc.httpClient.Do(httpReq)
+	resp, err := dc.send(httpReq, direct)
 	if err != nil {
-		return fmt.Errorf("请求失败: %v", err)
+		return err
 	}
 	defer resp.Body.Close()
 	
@@ -418,12 +548,18 @@ func (dc *DistributedClient) setToNode(node, key string, req CacheRequest) error
 }
 
 // getFromNode 从指定节点获取缓存
-func (dc *DistributedClient) getFromNode(node, key string) (string, bool, error) {
+func (dc *DistributedClient) getFromNode(node, key string, quorum Quorum, direct bool) (string, bool, error) {
 	url := fmt.Sprintf("http://%s/api/v1/cache/%s", node, key)
 	
-	resp, err := dc.httpClient.Get(url)
+	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return "", false, fmt.Errorf("请求失败: %v", err)
+		return "", false, fmt.Errorf("创建请求失败: %v", err)
+	}
+	setQuorumHeaders(req, quorum)
+	
+	resp, err := dc.send(req, direct)
+	if err != nil {
+		return "", false, err
 	}
 	defer resp.Body.Close()
 	
@@ -441,17 +577,18 @@ func (dc *DistributedClient) getFromNode(node, key string) (string, bool, error)
 }
 
 // deleteFromNode 从指定节点删除缓存
-func (dc *DistributedClient) deleteFromNode(node, key string) error {
+func (dc *DistributedClient) deleteFromNode(node, key string, quorum Quorum, direct bool) error {
 	url := fmt.Sprintf("http://%s/api/v1/cache/%s", node, key)
 	
 	req, err := http.NewRequest("DELETE", url, nil)
 	if err != nil {
 		return fmt.Errorf("创建请求失败: %v", err)
 	}
+	setQuorumHeaders(req, quorum)
 	
-	resp, err := dc.httpClient.Do(req)
+	resp, err := dc.send(req, direct)
 	if err != nil {
-		return fmt.Errorf("请求失败: %v", err)
+		return err
 	}
 	defer resp.Body.Close()
 	
@@ -463,6 +600,98 @@ func (dc *DistributedClient) deleteFromNode(node, key string) error {
 	return nil
 }
 
+// setQuorumHeaders 在请求上携带非零的仲裁参数
+func setQuorumHeaders(req *http.Request, quorum Quorum) {
+	if quorum.N > 0 {
+		req.Header.Set(QuorumHeaderN, strconv.Itoa(quorum.N))
+	}
+	if quorum.R > 0 {
+		req.Header.Set(QuorumHeaderR, strconv.Itoa(quorum.R))
+	}
+	if quorum.W > 0 {
+		req.Header.Set(QuorumHeaderW, strconv.Itoa(quorum.W))
+	}
+}
+
+// binaryURL 二进制接口地址，key使用base64url编码
+func binaryURL(node string, key []byte) string {
+	return fmt.Sprintf("http://%s/api/v1/bin/%s", node, core.EncodeKey(string(key)))
+}
+
+// setBinaryToNode 向指定节点设置缓存（二进制接口）
+func (dc *DistributedClient) setBinaryToNode(node string, key, value []byte, direct bool) error {
+	httpReq, err := http.NewRequest("PUT", binaryURL(node, key), bytes.NewReader(value))
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %v", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/octet-stream")
+	setQuorumHeaders(httpReq, dc.config.Quorum)
+
+	resp, err := dc.send(httpReq, direct)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("设置失败: %s", string(body))
+	}
+
+	return nil
+}
+
+// getBinaryFromNode 从指定节点获取缓存（二进制接口，404表示不存在）
+func (dc *DistributedClient) getBinaryFromNode(node string, key []byte, direct bool) ([]byte, bool, error) {
+	req, err := http.NewRequest("GET", binaryURL(node, key), nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("创建请求失败: %v", err)
+	}
+	setQuorumHeaders(req, dc.config.Quorum)
+
+	resp, err := dc.send(req, direct)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, false, fmt.Errorf("获取失败: %s", string(body))
+	}
+
+	value, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, fmt.Errorf("读取响应失败: %v", err)
+	}
+	return value, true, nil
+}
+
+// deleteBinaryFromNode 从指定节点删除缓存（二进制接口）
+func (dc *DistributedClient) deleteBinaryFromNode(node string, key []byte, direct bool) error {
+	req, err := http.NewRequest("DELETE", binaryURL(node, key), nil)
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %v", err)
+	}
+	setQuorumHeaders(req, dc.config.Quorum)
+
+	resp, err := dc.send(req, direct)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("删除失败: %s", string(body))
+	}
+
+	return nil
+}
+
 // getStatsFromNode 从指定节点获取统计信息
 func (dc *DistributedClient) getStatsFromNode(node string) (map[string]interface{}, error) {
 	url := fmt.Sprintf("http://%s/api/v1/stats", node)
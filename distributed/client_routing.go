@@ -0,0 +1,247 @@
+package distributed
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"tdd-learning/core"
+)
+
+// 客户端直连路由
+//
+// 客户端从 GET /admin/cluster 获取拓扑（节点地址、哈希函数、放置算法、虚拟节点数和权重），
+// 在本地构建与节点相同的 core.DistributedCache，把单key请求直接发给负责节点，省去一次转发。
+// 直连请求携带客户端的拓扑版本号：节点不负责该key且客户端版本不比自己新时返回421，
+// 客户端刷新拓扑并按重定向重试；响应中的版本号比客户端新时在后台刷新拓扑。
+// 拓扑未知、校验和不一致（视图正在变更）或节点开启了有界负载（负责节点由实时负载决定）时，
+// 退回到经任意节点转发。
+
+// topologyRetryInterval 拓扑未知时重新获取的最小间隔
+const topologyRetryInterval = 5 * time.Second
+
+// ClusterTopology 客户端构建哈希环所需的集群拓扑
+type ClusterTopology struct {
+	Epoch        uint64             `json:"epoch"`
+	Checksum     string             `json:"ring_checksum"`
+	HashFunction string             `json:"hash_function"`
+	Placement    string             `json:"placement"`
+	VirtualNodes int                `json:"virtual_nodes"`
+	Nodes        map[string]string  `json:"nodes"`   // nodeID -> 地址
+	Weights      map[string]float64 `json:"weights"` // 节点权重
+	BoundedLoad  bool               `json:"bounded_load"`
+}
+
+// Topology 获取本节点的拓扑视图
+func (dn *DistributedNode) Topology() ClusterTopology {
+	_, boundedLoad := dn.LocalLoad()
+	return ClusterTopology{
+		Epoch:        dn.GetRingEpoch(),
+		Checksum:     dn.GetRingChecksum(),
+		HashFunction: dn.GetHashFunction(),
+		Placement:    dn.GetPlacement(),
+		VirtualNodes: dn.hashRing.VirtualNodes,
+		Nodes:        dn.GetClusterNodes(),
+		Weights:      dn.GetNodeWeights(),
+		BoundedLoad:  boundedLoad,
+	}
+}
+
+// buildTopologyRing 按拓扑构建哈希环，校验和与节点的视图不一致时返回错误
+func buildTopologyRing(topology ClusterTopology) (*core.DistributedCache, error) {
+	hasher, err := core.NewHasher(topology.HashFunction)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]string, 0, len(topology.Nodes))
+	for nodeID := range topology.Nodes {
+		nodes = append(nodes, nodeID)
+	}
+	sort.Strings(nodes)
+
+	ring, err := core.NewDistributedCacheWithPlacement(nodes, topology.VirtualNodes, hasher, topology.Placement)
+	if err != nil {
+		return nil, err
+	}
+	for nodeID, weight := range topology.Weights {
+		if weight != 1 {
+			if _, err := ring.SetNodeWeight(nodeID, weight); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if checksum := ring.RingChecksum(); checksum != topology.Checksum {
+		return nil, fmt.Errorf("拓扑校验和不一致: 本地 %s，节点 %s", checksum, topology.Checksum)
+	}
+	ring.ObserveEpoch(topology.Epoch)
+	return ring, nil
+}
+
+// ClientRoutingStats 客户端路由统计
+type ClientRoutingStats struct {
+	Enabled       bool   `json:"enabled"`        // 是否开启直连路由
+	TopologyKnown bool   `json:"topology_known"` // 是否已构建可用的哈希环
+	Epoch         uint64 `json:"epoch"`          // 客户端拓扑版本号
+	Nodes         int    `json:"nodes"`
+	Direct        uint64 `json:"direct"`             // 直接发给负责节点并成功的请求数
+	Proxied       uint64 `json:"proxied"`            // 经任意节点转发的请求数
+	Redirects     uint64 `json:"redirects"`          // 收到421重定向的次数
+	Refreshes     uint64 `json:"topology_refreshes"` // 拓扑更新次数
+}
+
+// clientRouter 客户端路由表
+type clientRouter struct {
+	httpClient *http.Client
+	seeds      func() []string // 获取拓扑时依次尝试的节点
+
+	mu          sync.RWMutex
+	topology    ClusterTopology
+	ring        *core.DistributedCache // 拓扑未知或无法在客户端复现时为nil
+	lastAttempt time.Time
+
+	refreshMu  sync.Mutex // 串行化拓扑刷新
+	refreshing int32      // 后台刷新进行中
+
+	direct    uint64
+	proxied   uint64
+	redirects uint64
+	refreshes uint64
+}
+
+// newClientRouter 创建客户端路由表，拓扑在第一次请求时获取
+func newClientRouter(httpClient *http.Client, seeds func() []string) *clientRouter {
+	return &clientRouter{httpClient: httpClient, seeds: seeds}
+}
+
+// route 获取key的负责节点地址，拓扑未知时在后台获取并返回false
+func (r *clientRouter) route(key string) (string, bool) {
+	r.mu.RLock()
+	ring, nodes, lastAttempt := r.ring, r.topology.Nodes, r.lastAttempt
+	r.mu.RUnlock()
+
+	if ring == nil {
+		if time.Since(lastAttempt) >= topologyRetryInterval {
+			r.refreshAsync(0)
+		}
+		return "", false
+	}
+	address, exists := nodes[ring.GetNodeForKey(key)]
+	return address, exists
+}
+
+// epoch 客户端当前的拓扑版本号
+func (r *clientRouter) epoch() uint64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.topology.Epoch
+}
+
+// refresh 从种子节点获取拓扑；minEpoch 不为0且已有不旧于它的拓扑时跳过
+func (r *clientRouter) refresh(minEpoch uint64) error {
+	r.refreshMu.Lock()
+	defer r.refreshMu.Unlock()
+
+	r.mu.Lock()
+	if minEpoch > 0 && r.ring != nil && r.topology.Epoch >= minEpoch {
+		r.mu.Unlock()
+		return nil
+	}
+	r.lastAttempt = time.Now()
+	r.mu.Unlock()
+
+	lastErr := fmt.Errorf("没有可用的节点")
+	for _, node := range r.seeds() {
+		topology, err := r.fetchTopology(node)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		var ring *core.DistributedCache
+		if !topology.BoundedLoad {
+			if ring, err = buildTopologyRing(topology); err != nil {
+				lastErr = fmt.Errorf("节点 %s 的拓扑无法使用: %v", node, err)
+				continue
+			}
+		}
+
+		r.mu.Lock()
+		r.topology, r.ring = topology, ring
+		r.mu.Unlock()
+		atomic.AddUint64(&r.refreshes, 1)
+		log.Printf("🗺️ 客户端拓扑已更新: 版本 %d，%d 个节点", topology.Epoch, len(topology.Nodes))
+		return nil
+	}
+	return lastErr
+}
+
+// refreshAsync 在后台刷新拓扑，已有刷新进行中时跳过
+func (r *clientRouter) refreshAsync(minEpoch uint64) {
+	if !atomic.CompareAndSwapInt32(&r.refreshing, 0, 1) {
+		return
+	}
+	go func() {
+		defer atomic.StoreInt32(&r.refreshing, 0)
+		if err := r.refresh(minEpoch); err != nil {
+			log.Printf("⚠️ 获取集群拓扑失败，经任意节点转发: %v", err)
+		}
+	}()
+}
+
+// observe 响应携带的拓扑版本号比客户端新时在后台刷新
+func (r *clientRouter) observe(resp *http.Response) {
+	epoch, err := strconv.ParseUint(resp.Header.Get(ClusterEpochHeader), 10, 64)
+	if err == nil && epoch > r.epoch() {
+		r.refreshAsync(epoch)
+	}
+}
+
+// fetchTopology 从指定节点获取拓扑
+func (r *clientRouter) fetchTopology(node string) (ClusterTopology, error) {
+	resp, err := r.httpClient.Get(fmt.Sprintf("http://%s/admin/cluster", node))
+	if err != nil {
+		return ClusterTopology{}, fmt.Errorf("请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return ClusterTopology{}, fmt.Errorf("获取集群信息失败: %s", string(body))
+	}
+
+	var info struct {
+		Topology *ClusterTopology `json:"topology"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return ClusterTopology{}, fmt.Errorf("解析响应失败: %v", err)
+	}
+	if info.Topology == nil || len(info.Topology.Nodes) == 0 {
+		return ClusterTopology{}, fmt.Errorf("节点 %s 未返回拓扑", node)
+	}
+	return *info.Topology, nil
+}
+
+// stats 获取路由统计
+func (r *clientRouter) stats() ClientRoutingStats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return ClientRoutingStats{
+		Enabled:       true,
+		TopologyKnown: r.ring != nil,
+		Epoch:         r.topology.Epoch,
+		Nodes:         len(r.topology.Nodes),
+		Direct:        atomic.LoadUint64(&r.direct),
+		Proxied:       atomic.LoadUint64(&r.proxied),
+		Redirects:     atomic.LoadUint64(&r.redirects),
+		Refreshes:     atomic.LoadUint64(&r.refreshes),
+	}
+}
@@ -6,8 +6,11 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
+
+	"tdd-learning/core"
 )
 
 // ClusterCoordinator 集群协调器
@@ -17,15 +20,24 @@ type ClusterCoordinator struct {
 	cluster     *ClusterManager
 	httpClient  *http.Client
 	mu          sync.RWMutex
+	metadata    *clusterMetadata // 开启Raft时拓扑变更写入日志，未开启时为nil
 }
 
 // NodeChangeRequest 节点变更请求
 type NodeChangeRequest struct {
 	NodeID    string `json:"node_id"`
-	Address   string `json:"address,omitempty"`
-	Operation string `json:"operation"` // "add" or "remove"
+	Address   string  `json:"address,omitempty"`
+	Weight    float64 `json:"weight,omitempty"` // 节点权重，0表示默认
+	Zone      string  `json:"zone,omitempty"`   // 节点所在可用区
+	Rack      string  `json:"rack,omitempty"`   // 节点所在机架
+	Operation string  `json:"operation"`        // "add" / "remove" / "weight"
+	Epoch     uint64  `json:"epoch,omitempty"`  // 发起方应用变更后的拓扑版本号，接收方据此对齐
+	Reason    string  `json:"reason,omitempty"` // 移除原因，evicted 表示长时间不健康被驱逐
 }
 
+// NodeChangeEvicted 节点因长时间不健康被驱逐
+const NodeChangeEvicted = "evicted"
+
 // MigrationResult 数据迁移结果
 type MigrationResult struct {
 	Success       bool   `json:"success"`
@@ -43,33 +55,56 @@ func NewClusterCoordinator(node *DistributedNode, cluster *ClusterManager) *Clus
 	}
 }
 
-// AddNodeToCluster 向集群添加节点
-func (cc *ClusterCoordinator) AddNodeToCluster(nodeID, address string) error {
+// AddNodeToCluster 向集群添加节点，权重为0时使用默认权重
+func (cc *ClusterCoordinator) AddNodeToCluster(request NodeChangeRequest) error {
+	// 开启Raft时变更写入日志，由每个节点按日志顺序应用，不再广播
+	if cc.metadata != nil {
+		request.Operation = "add"
+		_, err := cc.metadata.proposeChange(request)
+		return err
+	}
+
+	// 先检查权重和位置，避免节点已加入集群管理器后才失败
+	if err := cc.validateNodeAttributes(request); err != nil {
+		return err
+	}
+
 	cc.mu.Lock()
 	defer cc.mu.Unlock()
+
+	nodeID, address := request.NodeID, request.Address
 	
 	log.Printf("🔄 开始添加节点到集群: %s (%s)", nodeID, address)
 	
 	// 1. 添加节点到集群管理器
 	cc.cluster.AddNode(nodeID, address)
+	cc.node.AddClusterNode(nodeID, address)
 	
 	// 2. 获取当前节点的哈希环实例
 	hashRing := cc.node.hashRing
+
+	// 节点加入前先记录权重和位置，加入时按权重创建虚拟节点
+	if err := cc.applyNodeAttributes(request); err != nil {
+		return err
+	}
 	
-	// 3. 调用你实现的AddNode方法，执行数据迁移
+	// 3. 调用你实现的AddNode方法，执行数据迁移；记录变更前的哈希环用于计算迁移区间
+	before, rangeMigratable := hashRing.SnapshotRing()
 	if err := hashRing.AddNode(nodeID); err != nil {
 		log.Printf("❌ 添加节点到哈希环失败: %v", err)
 		return fmt.Errorf("添加节点失败: %v", err)
 	}
 
 	// 4. 执行真实的网络数据迁移
-	if err := cc.performNetworkDataMigration(nodeID, address); err != nil {
+	if err := cc.performNetworkDataMigration(nodeID, before, rangeMigratable); err != nil {
 		log.Printf("⚠️ 网络数据迁移失败: %v", err)
 		// 不返回错误，因为哈希环已经更新，数据迁移可以稍后重试
 	}
 	
 	// 4. 广播节点变更到集群中的所有其他节点
-	if err := cc.broadcastNodeChange(nodeID, address, "add"); err != nil {
+	request.Operation = "add"
+	request.Epoch = hashRing.Epoch()
+	if err := cc.broadcastNodeChange(request); err != nil {
 		log.Printf("⚠️ 广播节点添加失败: %v", err)
 		// 注意：即使广播失败，本地操作已经成功，不回滚
 	}
@@ -80,8 +115,27 @@ func (cc *ClusterCoordinator) AddNodeToCluster(nodeID, address string) error {
 
 // RemoveNodeFromCluster 从集群移除节点
 func (cc *ClusterCoordinator) RemoveNodeFromCluster(nodeID string) error {
+	return cc.removeNode(NodeChangeRequest{NodeID: nodeID})
+}
+
+// EvictNode 驱逐长时间不健康的节点，广播中携带它的地址、权重和位置，供其他节点在它恢复后重新接纳
+func (cc *ClusterCoordinator) EvictNode(request NodeChangeRequest) error {
+	request.Reason = NodeChangeEvicted
+	return cc.removeNode(request)
+}
+
+// removeNode 从哈希环和集群管理器中移除节点并广播
+func (cc *ClusterCoordinator) removeNode(request NodeChangeRequest) error {
+	if cc.metadata != nil {
+		request.Operation = "remove"
+		_, err := cc.metadata.proposeChange(request)
+		return err
+	}
+
 	cc.mu.Lock()
 	defer cc.mu.Unlock()
+
+	nodeID := request.NodeID
 	
 	log.Printf("🔄 开始从集群移除节点: %s", nodeID)
 	
@@ -96,9 +150,12 @@ func (cc *ClusterCoordinator) RemoveNodeFromCluster(nodeID string) error {
 	
 	// 3. 从集群管理器中移除节点
 	cc.cluster.RemoveNode(nodeID)
+	cc.node.RemoveClusterNode(nodeID)
 	
 	// 4. 广播节点变更到集群中的所有其他节点
-	if err := cc.broadcastNodeChange(nodeID, "", "remove"); err != nil {
+	request.Operation = "remove"
+	request.Epoch = hashRing.Epoch()
+	if err := cc.broadcastNodeChange(request); err != nil {
 		log.Printf("⚠️ 广播节点移除失败: %v", err)
 		// 注意：即使广播失败，本地操作已经成功，不回滚
 	}
@@ -108,13 +165,8 @@ func (cc *ClusterCoordinator) RemoveNodeFromCluster(nodeID string) error {
 }
 
 // broadcastNodeChange 广播节点变更到集群中的所有其他节点
-func (cc *ClusterCoordinator) broadcastNodeChange(nodeID, address, operation string) error {
-	request := NodeChangeRequest{
-		NodeID:    nodeID,
-		Address:   address,
-		Operation: operation,
-	}
-	
+func (cc *ClusterCoordinator) broadcastNodeChange(request NodeChangeRequest) error {
+	operation := request.Operation
 	jsonData, err := json.Marshal(request)
 	if err != nil {
 		return fmt.Errorf("序列化请求失败: %v", err)
@@ -165,6 +217,9 @@ func (cc *ClusterCoordinator) sendNodeChangeRequest(targetAddress string, jsonDa
 	case "remove":
 		url = fmt.Sprintf("http://%s/internal/cluster/sync-remove", targetAddress)
 		method = "POST"
+	case "weight":
+		url = fmt.Sprintf("http://%s/internal/cluster/sync-weight", targetAddress)
+		method = "POST"
 	default:
 		return fmt.Errorf("未知操作: %s", operation)
 	}
@@ -189,7 +244,17 @@ func (cc *ClusterCoordinator) sendNodeChangeRequest(targetAddress string, jsonDa
 }
 
 // SyncAddNode 同步添加节点（接收广播）
-func (cc *ClusterCoordinator) SyncAddNode(nodeID, address string) error {
+// 权重和位置在修改集群配置前检查，检查通过后不会失败
+func (cc *ClusterCoordinator) SyncAddNode(request NodeChangeRequest) error {
+	if err := cc.validateNodeAttributes(request); err != nil {
+		return err
+	}
+
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	nodeID, address := request.NodeID, request.Address
+
 	log.Printf("🔄 同步添加节点: %s (%s)", nodeID, address)
 
 	// 1. 添加到集群管理器
@@ -197,15 +262,19 @@ func (cc *ClusterCoordinator) SyncAddNode(nodeID, address string) error {
 
 	// 2. 更新本地节点的集群配置
 	cc.node.AddClusterNode(nodeID, address)
+	if err := cc.applyNodeAttributes(request); err != nil {
+		return err
+	}
 
 	// 3. 添加到哈希环（这会触发数据迁移）
+	before, rangeMigratable := cc.node.hashRing.SnapshotRing()
 	if err := cc.node.hashRing.AddNode(nodeID); err != nil {
 		log.Printf("❌ 同步添加节点到哈希环失败: %v", err)
 		return err
 	}
 
 	// 4. 执行真实的网络数据迁移
-	if err := cc.performNetworkDataMigration(nodeID, address); err != nil {
+	if err := cc.performNetworkDataMigration(nodeID, before, rangeMigratable); err != nil {
 		log.Printf("⚠️ 同步数据迁移失败: %v", err)
 		// 不返回错误，因为哈希环已经更新
 	}
@@ -214,6 +283,16 @@ func (cc *ClusterCoordinator) SyncAddNode(nodeID, address string) error {
 	return nil
 }
 
+// UpdateNodeAddress 更新已在哈希环中的节点地址（节点换了地址重启后重新加入），哈希环和数据分布不变
+func (cc *ClusterCoordinator) UpdateNodeAddress(nodeID, address string) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	log.Printf("🔄 更新节点地址: %s (%s)", nodeID, address)
+	cc.cluster.AddNode(nodeID, address)
+	cc.node.AddClusterNode(nodeID, address)
+}
+
 // SyncRemoveNode 同步移除节点（接收广播）
 func (cc *ClusterCoordinator) SyncRemoveNode(nodeID string) error {
 	log.Printf("🔄 同步移除节点: %s", nodeID)
@@ -235,58 +314,166 @@ func (cc *ClusterCoordinator) SyncRemoveNode(nodeID string) error {
 }
 
 // performNetworkDataMigration 执行真实的网络数据迁移
-func (cc *ClusterCoordinator) performNetworkDataMigration(newNodeID, newNodeAddress string) error {
-	log.Printf("🔄 开始网络数据迁移到节点: %s (%s)", newNodeID, newNodeAddress)
+// 哈希环只迁移本节点交给新节点的哈希区间（借助本地缓存的哈希索引，开销与迁移量成正比）；
+// 其他放置方式按新的归属对比全部本地数据，Maglev 已有节点之间的少量调整一并迁移
+func (cc *ClusterCoordinator) performNetworkDataMigration(newNodeID string, before core.RingSnapshot, rangeMigratable bool) error {
+	log.Printf("🔄 开始网络数据迁移，新节点: %s", newNodeID)
 
-	startTime := time.Now()
-	migratedCount := 0
+	migratedCount := cc.migrateChangedData(before, rangeMigratable)
+
+	log.Printf("✅ 网络数据迁移完成: 迁移了 %d 个key", migratedCount)
+	return nil
+}
 
-	// 获取本地缓存的所有数据
-	localCache := cc.node.localCache
-	allData := localCache.GetAllData()
+// migrateChangedData 对比变更前后的哈希环，只迁移副本集合变化的哈希区间；
+// 变更前后有一方不能按区间计算归属时，对比全部本地数据，调用方需持有 cc.mu
+func (cc *ClusterCoordinator) migrateChangedData(before core.RingSnapshot, rangeMigratable bool) int {
+	if after, ok := cc.node.hashRing.SnapshotRing(); rangeMigratable && ok {
+		return cc.migrateRanges(cc.node.hashRing.ChangedReplicaRanges(before, after, cc.node.replicaCount()))
+	}
+	return cc.rebalanceLocalData()
+}
 
-	// 检查每个数据项是否应该迁移到新节点
-	for key, value := range allData {
-		// 重新计算这个key现在应该存储在哪个节点
-		targetNodeID := cc.node.hashRing.GetNodeForKey(key)
+// migrationPlan 迁移计划：发往各节点的条目，以及全部发送成功后本节点可以删除的key
+type migrationPlan struct {
+	targets map[string]map[string]core.VersionedEntry
+	release map[string]bool
+}
 
-		if targetNodeID == newNodeID {
-			// 这个key现在应该存储在新节点，需要迁移
-			if err := cc.migrateKeyToNode(key, value, newNodeAddress); err != nil {
-				log.Printf("❌ 迁移key失败: %s -> %s, 错误: %v", key, newNodeID, err)
-				continue
+func newMigrationPlan() *migrationPlan {
+	return &migrationPlan{
+		targets: make(map[string]map[string]core.VersionedEntry),
+		release: make(map[string]bool),
+	}
+}
+
+// add 把条目加入发往targets的迁移，release 为true表示本节点已不再是该key的副本
+func (p *migrationPlan) add(key string, entry core.VersionedEntry, targets []string, release bool) {
+	for _, target := range targets {
+		if p.targets[target] == nil {
+			p.targets[target] = make(map[string]core.VersionedEntry)
+		}
+		p.targets[target][key] = entry
+	}
+	if release {
+		p.release[key] = true
+	}
+}
+
+// executeMigration 把条目发往各目标节点，本节点不再是副本且所有目标都写入成功的key从本地删除
+// 条目带着原版本号写入，目标节点上更新的版本不会被覆盖；返回迁移成功的key数量
+func (cc *ClusterCoordinator) executeMigration(plan *migrationPlan) int {
+	clusterNodes := cc.node.GetClusterNodes()
+	shipped := make(map[string]bool)
+	failed := make(map[string]bool)
+	for target, entries := range plan.targets {
+		for key := range entries {
+			shipped[key] = true
+		}
+		address, exists := clusterNodes[target]
+		if !exists {
+			log.Printf("⚠️ 跳过地址未知的节点: %s (%d 个key)", target, len(entries))
+			for key := range entries {
+				failed[key] = true
 			}
+			continue
+		}
+		for key := range cc.migrateKeys(target, address, entries) {
+			failed[key] = true
+		}
+	}
 
-			// 迁移成功，从本地缓存删除
-			localCache.Delete(key)
-			migratedCount++
-			log.Printf("✅ 迁移key: %s -> %s", key, newNodeID)
+	for key := range plan.release {
+		if !failed[key] {
+			cc.node.localCache.Delete(key)
 		}
 	}
+	return len(shipped) - len(failed)
+}
 
-	log.Printf("✅ 网络数据迁移完成: 迁移了 %d 个key到节点 %s", migratedCount, newNodeID)
+// migrateKeys 将条目写入目标节点，返回写入失败的key
+func (cc *ClusterCoordinator) migrateKeys(targetNodeID, targetAddress string, entries map[string]core.VersionedEntry) map[string]bool {
+	failed := make(map[string]bool)
+
+	for _, batch := range splitMigrationBatches(entries, migrationBatchSize, migrationBatchBytes) {
+		// 优先通过RPC批量迁移
+		if handled, err := cc.node.forwardViaRPC(targetAddress, func(pool *rpcPool) error {
+			return pool.migrate(batch)
+		}); handled {
+			if err != nil {
+				log.Printf("❌ 批量迁移失败: %d 个key -> %s, 错误: %v", len(batch), targetNodeID, err)
+				for key := range batch {
+					failed[key] = true
+				}
+				continue
+			}
+			log.Printf("✅ 批量迁移 %d 个key -> %s", len(batch), targetNodeID)
+			continue
+		}
 
-	// 更新迁移统计信息
-	cc.updateMigrationStats(migratedCount, time.Since(startTime))
+		// 对端不支持RPC，逐个key通过HTTP迁移
+		for key, entry := range batch {
+			if err := cc.migrateKeyToNode(key, entry, targetAddress); err != nil {
+				log.Printf("❌ 迁移key失败: %s -> %s, 错误: %v", key, targetNodeID, err)
+				failed[key] = true
+				continue
+			}
+			log.Printf("✅ 迁移key: %s -> %s", key, targetNodeID)
+		}
+	}
 
-	return nil
+	return failed
 }
 
-// migrateKeyToNode 将单个key迁移到指定节点
-func (cc *ClusterCoordinator) migrateKeyToNode(key, value, nodeAddress string) error {
-	url := fmt.Sprintf("http://%s/internal/cache/%s", nodeAddress, key)
+// 每个RPC迁移请求最多携带的key数量和字节数，单个条目超过字节数时单独成批（仍小于RPC帧上限）
+const (
+	migrationBatchSize  = 500
+	migrationBatchBytes = 4 * 1024 * 1024
+)
 
-	requestBody := map[string]string{"value": value}
-	jsonData, err := json.Marshal(requestBody)
-	if err != nil {
-		return err
+// splitMigrationBatches 将待迁移条目按key数量和字节数拆分批次
+func splitMigrationBatches(entries map[string]core.VersionedEntry, batchSize, batchBytes int) []map[string]core.VersionedEntry {
+	var batches []map[string]core.VersionedEntry
+	current := make(map[string]core.VersionedEntry, batchSize)
+	currentBytes := 0
+	for key, entry := range entries {
+		// 版本号、标志等字段编码后不超过32字节
+		size := len(key) + len(entry.Value) + 32
+		if len(current) > 0 && currentBytes+size > batchBytes {
+			batches = append(batches, current)
+			current = make(map[string]core.VersionedEntry, batchSize)
+			currentBytes = 0
+		}
+		current[key] = entry
+		currentBytes += size
+		if len(current) >= batchSize {
+			batches = append(batches, current)
+			current = make(map[string]core.VersionedEntry, batchSize)
+			currentBytes = 0
+		}
+	}
+	if len(current) > 0 {
+		batches = append(batches, current)
 	}
+	return batches
+}
+
+// MigrationHeader 标记迁移写入的HTTP头，目标节点跳过准入策略
+const MigrationHeader = "X-Cache-Migration"
 
-	req, err := http.NewRequest("PUT", url, bytes.NewBuffer(jsonData))
+// migrateKeyToNode 将单个条目通过副本接口迁移到指定节点，删除标记以DELETE写入
+func (cc *ClusterCoordinator) migrateKeyToNode(key string, entry core.VersionedEntry, nodeAddress string) error {
+	method := "PUT"
+	if entry.Tombstone {
+		method = "DELETE"
+	}
+	req, err := http.NewRequest(method, replicaURL(nodeAddress, key), strings.NewReader(entry.Value))
 	if err != nil {
 		return err
 	}
-	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	setEntryHeaders(req.Header, entry)
+	req.Header.Set(MigrationHeader, "1")
 
 	resp, err := cc.httpClient.Do(req)
 	if err != nil {
@@ -323,6 +510,7 @@ func (cc *ClusterCoordinator) GetMigrationStats() map[string]interface{} {
 }
 
 // TriggerRebalance 触发集群重平衡
+// 按当前哈希环（含节点权重）检查本地数据，不再由本节点负责的key迁移到新的负责节点
 func (cc *ClusterCoordinator) TriggerRebalance() error {
 	log.Printf("🔄 开始集群重平衡...")
 	
@@ -331,13 +519,149 @@ func (cc *ClusterCoordinator) TriggerRebalance() error {
 	if len(nodes) < 2 {
 		return fmt.Errorf("健康节点数量不足，无法执行重平衡")
 	}
-	
-	// TODO: 实现重平衡逻辑
-	// 1. 分析当前数据分布 
-	// 2. 计算理想分布
-	// 3. 执行数据迁移
-	// 涉及权重相关，暂搁置
 
-	log.Printf("✅ 集群重平衡完成")
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	migratedCount := cc.rebalanceLocalData()
+
+	log.Printf("✅ 集群重平衡完成: 迁移了 %d 个key", migratedCount)
+	return nil
+}
+
+// UpdateNodeWeight 修改节点权重并广播到集群中的其他节点
+// 每个节点只迁移本地因权重变化而改变归属的key，返回本节点迁移的key数量
+func (cc *ClusterCoordinator) UpdateNodeWeight(nodeID string, weight float64) (int, error) {
+	if cc.metadata != nil {
+		return cc.metadata.proposeChange(NodeChangeRequest{NodeID: nodeID, Weight: weight, Operation: "weight"})
+	}
+
+	migratedCount, err := cc.SyncNodeWeight(nodeID, weight)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := cc.broadcastNodeChange(NodeChangeRequest{NodeID: nodeID, Weight: weight, Operation: "weight", Epoch: cc.node.GetRingEpoch()}); err != nil {
+		log.Printf("⚠️ 广播节点权重失败: %v", err)
+		// 注意：即使广播失败，本地操作已经成功，不回滚
+	}
+	return migratedCount, nil
+}
+
+// SyncNodeWeight 修改本地哈希环中的节点权重并迁移受影响的数据（接收广播）
+func (cc *ClusterCoordinator) SyncNodeWeight(nodeID string, weight float64) (int, error) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	if _, exists := cc.node.GetClusterNodes()[nodeID]; !exists {
+		return 0, fmt.Errorf("节点不存在: %s", nodeID)
+	}
+
+	log.Printf("🔄 修改节点权重: %s -> %v", nodeID, weight)
+	before, rangeMigratable := cc.node.hashRing.SnapshotRing()
+	if _, err := cc.node.hashRing.SetNodeWeight(nodeID, weight); err != nil {
+		return 0, err
+	}
+
+	migratedCount := cc.migrateChangedData(before, rangeMigratable)
+	log.Printf("✅ 节点权重修改完成: %s，迁移了 %d 个key", nodeID, migratedCount)
+	return migratedCount, nil
+}
+
+// rebalanceLocalData 将本节点已不再是副本的本地数据迁移到当前的副本节点，调用方需持有 cc.mu
+// 不知道变更前的副本集合，本节点仍是副本的key保持不动，新副本上缺少的数据由反熵修复补齐
+func (cc *ClusterCoordinator) rebalanceLocalData() int {
+	startTime := time.Now()
+	self := cc.node.GetNodeID()
+	n := cc.node.replicaCount()
+
+	plan := newMigrationPlan()
+	for _, entries := range cc.node.localCache.VersionedEntriesInHashRanges([]core.HashInterval{{}}) {
+		for key, entry := range entries {
+			replicas := cc.node.hashRing.GetNodesForKey(key, n)
+			if containsNode(replicas, self) {
+				continue
+			}
+			plan.add(key, entry, replicas, true)
+		}
+	}
+
+	migratedCount := cc.executeMigration(plan)
+	cc.updateMigrationStats(migratedCount, time.Since(startTime))
+	return migratedCount
+}
+
+// migrateRanges 把副本集合发生变化、且本节点原本持有的哈希区间内的条目复制到新加入的副本节点，
+// 本节点不再是副本的区间在复制成功后删除本地数据
+func (cc *ClusterCoordinator) migrateRanges(ranges []core.ReplicaRange) int {
+	startTime := time.Now()
+	self := cc.node.GetNodeID()
+
+	// 所有区间一次取出，避免每个区间各扫描一遍本地缓存
+	var held []core.ReplicaRange
+	var intervals []core.HashInterval
+	for _, r := range ranges {
+		if containsNode(r.Before, self) {
+			held = append(held, r)
+			intervals = append(intervals, r.HashInterval)
+		}
+	}
+	plan := newMigrationPlan()
+	if len(intervals) > 0 {
+		for i, entries := range cc.node.localCache.VersionedEntriesInHashRanges(intervals) {
+			targets := addedNodes(held[i].Before, held[i].After)
+			release := !containsNode(held[i].After, self)
+			for key, entry := range entries {
+				plan.add(key, entry, targets, release)
+			}
+		}
+	}
+
+	migratedCount := cc.executeMigration(plan)
+	cc.updateMigrationStats(migratedCount, time.Since(startTime))
+	return migratedCount
+}
+
+// containsNode 节点列表中是否包含指定节点
+func containsNode(nodes []string, nodeID string) bool {
+	for _, node := range nodes {
+		if node == nodeID {
+			return true
+		}
+	}
+	return false
+}
+
+// addedNodes after 中不在 before 里的节点
+func addedNodes(before, after []string) []string {
+	var added []string
+	for _, node := range after {
+		if !containsNode(before, node) {
+			added = append(added, node)
+		}
+	}
+	return added
+}
+
+// validateNodeAttributes 检查节点的权重和位置，通过检查后 applyNodeAttributes 不会失败
+func (cc *ClusterCoordinator) validateNodeAttributes(request NodeChangeRequest) error {
+	if request.Weight != 0 {
+		if err := cc.node.hashRing.ValidateNodeWeight(request.Weight); err != nil {
+			return err
+		}
+	}
+	return core.ValidateNodeLocation(core.NodeLocation{Zone: request.Zone, Rack: request.Rack})
+}
+
+// applyNodeAttributes 节点加入哈希环前记录其权重和位置
+func (cc *ClusterCoordinator) applyNodeAttributes(request NodeChangeRequest) error {
+	if request.Weight != 0 {
+		if _, err := cc.node.hashRing.SetNodeWeight(request.NodeID, request.Weight); err != nil {
+			return err
+		}
+	}
+	if request.Zone != "" || request.Rack != "" {
+		cc.node.hashRing.SetNodeLocation(request.NodeID, core.NodeLocation{Zone: request.Zone, Rack: request.Rack})
+	}
 	return nil
 }
@@ -0,0 +1,71 @@
+package distributed
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// 集群事件 - 节点状态变化、驱逐和重新接纳的每一步都记录下来，通过 GET /admin/events 查看
+
+// 集群事件类型
+const (
+	EventNodeDown          = "node_down"          // 节点从健康变为不健康
+	EventNodeUp            = "node_up"            // 节点恢复健康
+	EventEvictionProposed  = "eviction_proposed"  // 节点不健康超过宽限期，发起驱逐
+	EventEvictionRejected  = "eviction_rejected"  // 同意驱逐的节点未达到多数派
+	EventEvictionFailed    = "eviction_failed"    // 移出哈希环失败
+	EventNodeEvicted       = "node_evicted"       // 节点已移出哈希环
+	EventNodeReturned      = "node_returned"      // 被驱逐的节点恢复响应
+	EventDataReset         = "data_reset"         // 重新加入前清空旧数据
+	EventNodeReadmitted    = "node_readmitted"    // 节点重新加入哈希环
+	EventReadmissionFailed = "readmission_failed" // 重新接纳失败，下次检查时重试
+)
+
+// maxClusterEvents 保留的事件数
+const maxClusterEvents = 1000
+
+// ClusterEvent 集群事件
+type ClusterEvent struct {
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"`
+	NodeID  string    `json:"node_id"`
+	Message string    `json:"message"`
+}
+
+// clusterEvents 最近的集群事件
+type clusterEvents struct {
+	mu     sync.Mutex
+	events []ClusterEvent
+}
+
+// RecordEvent 记录集群事件
+func (cm *ClusterManager) RecordEvent(eventType, nodeID, format string, args ...interface{}) {
+	event := ClusterEvent{
+		Time:    time.Now(),
+		Type:    eventType,
+		NodeID:  nodeID,
+		Message: fmt.Sprintf(format, args...),
+	}
+	log.Printf("📋 集群事件 [%s] %s: %s", event.Type, event.NodeID, event.Message)
+
+	cm.events.mu.Lock()
+	defer cm.events.mu.Unlock()
+	cm.events.events = append(cm.events.events, event)
+	if overflow := len(cm.events.events) - maxClusterEvents; overflow > 0 {
+		cm.events.events = append([]ClusterEvent(nil), cm.events.events[overflow:]...)
+	}
+}
+
+// GetEvents 获取最近的集群事件，按时间先后排列，limit <= 0 表示全部
+func (cm *ClusterManager) GetEvents(limit int) []ClusterEvent {
+	cm.events.mu.Lock()
+	defer cm.events.mu.Unlock()
+
+	events := cm.events.events
+	if limit > 0 && len(events) > limit {
+		events = events[len(events)-limit:]
+	}
+	return append([]ClusterEvent(nil), events...)
+}
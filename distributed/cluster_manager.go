@@ -6,8 +6,11 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
+
+	"tdd-learning/core"
 )
 
 // ClusterManager 集群管理器
@@ -19,15 +22,49 @@ type ClusterManager struct {
 	httpClient   *http.Client
 	healthTicker *time.Ticker
 	stopChan     chan struct{}
+
+	// 本节点的哈希函数和放置算法，加入集群时由对端校验
+	hashFunction string
+	placement    string
+
+	// 本节点权重和位置，加入集群时通知对端，权重0表示默认
+	weight   float64
+	location core.NodeLocation
+
+	// 有界负载：本节点负载来源和负载上报目标，未开启时为nil
+	loadSource func() (float64, bool)
+	loadSink   func(nodeID string, load float64)
+
+	// 拓扑版本：本节点的版本号和哈希环校验和，以及视图一致时对齐到对端版本的回调
+	ringSource  func() (uint64, string)
+	epochSink   func(epoch uint64)
+
+	// 节点恢复健康（从不健康或未知变为健康）时的回调，用于重放提示
+	recoveryHandler func(nodeID string)
+
+	// 成员和健康状态由gossip维护，不再轮询健康检查和宣告加入
+	gossip bool
+
+	// Phi Accrual 故障检测：每个节点的心跳间隔统计，φ 超过阈值才判定为不健康
+	detectors     map[string]*phiAccrual
+	phiThreshold  float64
+	checkInterval time.Duration
+
+	// 最近的集群事件
+	events clusterEvents
 }
 
 // NodeInfo 节点信息
 type NodeInfo struct {
 	NodeID      string    `json:"node_id"`
 	Address     string    `json:"address"`
-	Status      string    `json:"status"`      // "healthy", "unhealthy", "unknown"
+	Status      string    `json:"status"`      // "healthy", "suspect", "unhealthy", "unknown"
 	LastSeen    time.Time `json:"last_seen"`
 	ResponseTime int64    `json:"response_time"` // 响应时间(毫秒)
+	Phi          float64  `json:"phi"`                     // 故障怀疑程度，超过阈值时判定为不健康
+	Epoch        uint64   `json:"epoch"`                   // 对端公布的拓扑版本号
+	RingChecksum string   `json:"ring_checksum,omitempty"` // 对端公布的哈希环校验和
+	RingDiverged bool     `json:"ring_diverged"`           // 对端的哈希环视图与本节点不一致
 }
 
 // ClusterStatus 集群状态
@@ -48,6 +85,9 @@ func NewClusterManager(nodeID string, clusterNodes map[string]string) *ClusterMa
 			Timeout: 3 * time.Second,
 		},
 		stopChan: make(chan struct{}),
+		detectors:     make(map[string]*phiAccrual),
+		phiThreshold:  DefaultPhiThreshold,
+		checkInterval: DefaultHealthCheckInterval,
 	}
 	
 	// 初始化节点信息
@@ -66,9 +106,16 @@ func NewClusterManager(nodeID string, clusterNodes map[string]string) *ClusterMa
 // Start 启动集群管理器
 func (cm *ClusterManager) Start() error {
 	log.Printf("🌐 启动集群管理器，节点ID: %s", cm.nodeID)
+
+	if cm.gossip {
+		cm.mu.Lock()
+		cm.refreshSelf()
+		cm.mu.Unlock()
+		return nil
+	}
 	
 	// 启动健康检查
-	cm.healthTicker = time.NewTicker(10 * time.Second)
+	cm.healthTicker = time.NewTicker(cm.checkInterval)
 	go cm.healthCheckLoop()
 	
 	// 向其他节点宣告自己的存在
@@ -100,8 +147,19 @@ func (cm *ClusterManager) announceToCluster() {
 	}
 	
 	joinData := map[string]string{
-		"node_id": cm.nodeID,
-		"address": currentNode.Address,
+		"node_id":       cm.nodeID,
+		"address":       currentNode.Address,
+		"hash_function": cm.hashFunction,
+		"placement":     cm.placement,
+	}
+	if cm.weight != 0 {
+		joinData["weight"] = strconv.FormatFloat(cm.weight, 'f', -1, 64)
+	}
+	if cm.location.Zone != "" {
+		joinData["zone"] = cm.location.Zone
+	}
+	if cm.location.Rack != "" {
+		joinData["rack"] = cm.location.Rack
 	}
 	
 	for nodeID, node := range cm.nodes {
@@ -123,11 +181,107 @@ func (cm *ClusterManager) notifyNodeJoin(targetAddr string, joinData map[string]
 	}
 	defer resp.Body.Close()
 	
-	if resp.StatusCode == http.StatusOK {
+	switch resp.StatusCode {
+	case http.StatusOK:
 		log.Printf("✅ 成功通知节点 %s 关于节点加入", targetAddr)
+	case http.StatusConflict:
+		log.Printf("❌ 节点 %s 拒绝加入请求: 哈希函数或放置算法不一致", targetAddr)
 	}
 }
 
+// SetRingConfig 设置本节点的哈希函数和放置算法
+func (cm *ClusterManager) SetRingConfig(hashFunction, placement string) {
+	cm.hashFunction = core.NormalizeHasherName(hashFunction)
+	cm.placement = core.NormalizePlacementName(placement)
+}
+
+// SetWeight 设置本节点权重
+func (cm *ClusterManager) SetWeight(weight float64) {
+	cm.weight = weight
+}
+
+// SetLocation 设置本节点所在的可用区和机架
+func (cm *ClusterManager) SetLocation(location core.NodeLocation) {
+	cm.location = location
+}
+
+// SetLoadReporter 设置负载上报，健康检查时把本节点和对端公布的负载交给sink
+func (cm *ClusterManager) SetLoadReporter(source func() (float64, bool), sink func(nodeID string, load float64)) {
+	cm.loadSource = source
+	cm.loadSink = sink
+}
+
+// SetRingReporter 设置拓扑版本来源，健康检查时对比对端的哈希环校验和，视图一致时对齐到较新的版本号
+func (cm *ClusterManager) SetRingReporter(source func() (uint64, string), sink func(epoch uint64)) {
+	cm.ringSource = source
+	cm.epochSink = sink
+}
+
+// SetRecoveryHandler 设置节点恢复健康时的回调，回调在独立的协程中执行
+func (cm *ClusterManager) SetRecoveryHandler(handler func(nodeID string)) {
+	cm.recoveryHandler = handler
+}
+
+// SetGossipMembership 成员和健康状态改由gossip维护
+func (cm *ClusterManager) SetGossipMembership(enabled bool) {
+	cm.gossip = enabled
+}
+
+// SetFailureDetector 设置故障检测的φ阈值和健康检查间隔，0表示使用默认值
+// 需在 Start 之前调用
+func (cm *ClusterManager) SetFailureDetector(phiThreshold float64, checkInterval time.Duration) {
+	if phiThreshold > 0 {
+		cm.phiThreshold = phiThreshold
+	}
+	if checkInterval > 0 {
+		cm.checkInterval = checkInterval
+	}
+}
+
+// CheckHealth 立即执行一次健康检查
+func (cm *ClusterManager) CheckHealth() {
+	cm.performHealthCheck()
+}
+
+// VerifyRingConfig 启动前校验所有可达节点的哈希函数和放置算法与本节点一致
+// 暂时不可达的节点跳过，它们启动时会执行同样的校验
+func (cm *ClusterManager) VerifyRingConfig() error {
+	cm.mu.RLock()
+	peers := make(map[string]string)
+	for nodeID, node := range cm.nodes {
+		if nodeID != cm.nodeID {
+			peers[nodeID] = node.Address
+		}
+	}
+	cm.mu.RUnlock()
+
+	expected := core.NormalizeHasherName(cm.hashFunction)
+	for nodeID, address := range peers {
+		resp, err := cm.httpClient.Get(fmt.Sprintf("http://%s/internal/cluster/health", address))
+		if err != nil {
+			continue
+		}
+
+		var health struct {
+			HashFunction string `json:"hash_function"`
+			Placement    string `json:"placement"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&health)
+		resp.Body.Close()
+		if err != nil || resp.StatusCode != http.StatusOK {
+			continue
+		}
+
+		if actual := core.NormalizeHasherName(health.HashFunction); actual != expected {
+			return fmt.Errorf("哈希函数与节点 %s 不一致: 本节点 %s，对端 %s", nodeID, expected, actual)
+		}
+		if actual := core.NormalizePlacementName(health.Placement); actual != cm.placement {
+			return fmt.Errorf("放置算法与节点 %s 不一致: 本节点 %s，对端 %s", nodeID, cm.placement, actual)
+		}
+	}
+	return nil
+}
+
 // healthCheckLoop 健康检查循环
 func (cm *ClusterManager) healthCheckLoop() {
 	for {
@@ -140,46 +294,225 @@ func (cm *ClusterManager) healthCheckLoop() {
 	}
 }
 
+// healthCheckResult 单个节点的健康检查结果
+type healthCheckResult struct {
+	nodeID       string
+	healthy      bool
+	health       nodeHealth
+	responseTime int64
+}
+
 // performHealthCheck 执行健康检查
+// 并行检查所有对端，网络请求期间不持有 cm.mu；成功的检查记为一次心跳，节点状态由 φ 决定
 func (cm *ClusterManager) performHealthCheck() {
 	cm.mu.Lock()
-	defer cm.mu.Unlock()
-	
+	cm.refreshSelf()
+	peers := make(map[string]string, len(cm.nodes))
 	for nodeID, node := range cm.nodes {
-		if nodeID == cm.nodeID {
-			// 自己总是健康的
-			node.Status = "healthy"
-			node.LastSeen = time.Now()
-			continue
+		if nodeID != cm.nodeID {
+			peers[nodeID] = node.Address
 		}
-		
-		// 检查其他节点
-		start := time.Now()
-		healthy := cm.checkNodeHealth(node.Address)
-		responseTime := time.Since(start).Milliseconds()
-		
-		if healthy {
-			node.Status = "healthy"
-			node.LastSeen = time.Now()
-			node.ResponseTime = responseTime
+	}
+	cm.mu.Unlock()
+
+	results := make(chan healthCheckResult, len(peers))
+	for nodeID, address := range peers {
+		go func(nodeID, address string) {
+			start := time.Now()
+			healthy, health := cm.checkNodeHealth(address)
+			results <- healthCheckResult{
+				nodeID:       nodeID,
+				healthy:      healthy,
+				health:       health,
+				responseTime: time.Since(start).Milliseconds(),
+			}
+		}(nodeID, address)
+	}
+
+	collected := make([]healthCheckResult, 0, len(peers))
+	for range peers {
+		collected = append(collected, <-results)
+	}
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	for _, result := range collected {
+		node := cm.nodes[result.nodeID]
+		if node == nil {
+			continue // 检查期间节点已被移除
+		}
+
+		now := time.Now()
+		detector := cm.detector(result.nodeID)
+		if result.healthy {
+			detector.heartbeat(now)
+			node.LastSeen = now
+			node.ResponseTime = result.responseTime
+			cm.applyHealth(node, result.health)
 		} else {
-			node.Status = "unhealthy"
 			node.ResponseTime = -1
 		}
+
+		node.Phi = detector.phi(now)
+		switch {
+		case !detector.seen():
+			node.Status = "unhealthy" // 从未检查成功过
+		case node.Phi < cm.phiThreshold:
+			cm.setStatus(node, "healthy")
+		default:
+			if node.Status == "healthy" {
+				log.Printf("⚠️ 节点 %s 的φ值 %.1f 超过阈值 %.1f，判定为不健康", node.NodeID, node.Phi, cm.phiThreshold)
+			}
+			cm.setStatus(node, "unhealthy")
+		}
+	}
+}
+
+// detector 获取节点的故障检测器，调用方需持有 cm.mu
+func (cm *ClusterManager) detector(nodeID string) *phiAccrual {
+	detector := cm.detectors[nodeID]
+	if detector == nil {
+		detector = newPhiAccrual(cm.checkInterval)
+		cm.detectors[nodeID] = detector
+	}
+	return detector
+}
+
+// copyNode 返回节点信息的副本，φ 按当前时间计算，调用方需持有 cm.mu
+func (cm *ClusterManager) copyNode(node *NodeInfo, now time.Time) *NodeInfo {
+	nodeCopy := *node
+	if detector := cm.detectors[node.NodeID]; detector != nil {
+		nodeCopy.Phi = detector.phi(now)
+	}
+	return &nodeCopy
+}
+
+// refreshSelf 更新本节点的状态（自己总是健康的），调用方需持有 cm.mu
+func (cm *ClusterManager) refreshSelf() {
+	node := cm.nodes[cm.nodeID]
+	if node == nil {
+		return
+	}
+	node.Status = "healthy"
+	node.LastSeen = time.Now()
+	if cm.ringSource != nil {
+		node.Epoch, node.RingChecksum = cm.ringSource()
+	}
+	if cm.loadSource != nil && cm.loadSink != nil {
+		if load, ok := cm.loadSource(); ok {
+			cm.loadSink(cm.nodeID, load)
+		}
+	}
+}
+
+// setStatus 更新节点状态并记录健康变化事件，从其他状态变为健康时触发恢复回调，调用方需持有 cm.mu
+func (cm *ClusterManager) setStatus(node *NodeInfo, status string) {
+	previous := node.Status
+	node.Status = status
+	switch {
+	case status == "healthy" && previous != "healthy":
+		if previous != "unknown" {
+			cm.RecordEvent(EventNodeUp, node.NodeID, "节点恢复健康（之前为 %s）", previous)
+		}
+		if cm.recoveryHandler != nil {
+			go cm.recoveryHandler(node.NodeID)
+		}
+	case status == "unhealthy" && previous == "healthy":
+		cm.RecordEvent(EventNodeDown, node.NodeID, "节点不健康，φ=%.1f", node.Phi)
 	}
 }
 
-// checkNodeHealth 检查单个节点健康状态
-func (cm *ClusterManager) checkNodeHealth(address string) bool {
+// applyHealth 处理对端公布的负载和拓扑版本，调用方需持有 cm.mu
+func (cm *ClusterManager) applyHealth(node *NodeInfo, health nodeHealth) {
+	if health.Load != nil && cm.loadSink != nil {
+		cm.loadSink(node.NodeID, *health.Load)
+	}
+	cm.compareRing(node, health)
+}
+
+// ObserveMember 记录gossip得到的成员状态，未知的节点先加入列表
+func (cm *ClusterManager) ObserveMember(nodeID, address, status string) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	node := cm.nodes[nodeID]
+	if node == nil {
+		node = &NodeInfo{NodeID: nodeID, Address: address, Status: "unknown", LastSeen: time.Now()}
+		cm.nodes[nodeID] = node
+	}
+	if address != "" {
+		node.Address = address
+	}
+	if status == "healthy" {
+		node.LastSeen = time.Now()
+		cm.detector(nodeID).heartbeat(node.LastSeen)
+	} else {
+		node.ResponseTime = -1
+	}
+	cm.setStatus(node, status)
+}
+
+// observeHealth 处理gossip探测ack中公布的状态
+func (cm *ClusterManager) observeHealth(nodeID string, health nodeHealth) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	// 没有健康检查轮询，借探测刷新本节点的负载和拓扑版本
+	cm.refreshSelf()
+	if node := cm.nodes[nodeID]; node != nil {
+		node.LastSeen = time.Now()
+		cm.detector(nodeID).heartbeat(node.LastSeen)
+		cm.applyHealth(node, health)
+	}
+}
+
+// nodeHealth 对端健康检查响应中公布的状态
+type nodeHealth struct {
+	Load         *float64 `json:"load,omitempty"` // 未开启有界负载时为nil
+	Epoch        uint64   `json:"epoch"`
+	RingChecksum string   `json:"ring_checksum"`
+}
+
+// checkNodeHealth 检查单个节点健康状态，同时返回对端公布的负载和拓扑版本
+func (cm *ClusterManager) checkNodeHealth(address string) (bool, nodeHealth) {
 	url := fmt.Sprintf("http://%s/internal/cluster/health", address)
 	
+	var health nodeHealth
 	resp, err := cm.httpClient.Get(url)
 	if err != nil {
-		return false
+		return false, health
 	}
 	defer resp.Body.Close()
 	
-	return resp.StatusCode == http.StatusOK
+	if resp.StatusCode != http.StatusOK {
+		return false, health
+	}
+
+	json.NewDecoder(resp.Body).Decode(&health)
+	return true, health
+}
+
+// compareRing 记录对端的拓扑版本，对比哈希环校验和，调用方需持有 cm.mu
+// 校验和一致说明视图相同，只是版本号落后（例如节点重启后从配置重建哈希环），直接对齐；
+// 不一致说明有节点错过了拓扑变更，记录下来供 /admin/cluster 查看
+func (cm *ClusterManager) compareRing(node *NodeInfo, health nodeHealth) {
+	if cm.ringSource == nil || health.RingChecksum == "" {
+		return
+	}
+	node.Epoch = health.Epoch
+
+	epoch, checksum := cm.ringSource()
+	diverged := health.RingChecksum != checksum
+	if diverged && (!node.RingDiverged || node.RingChecksum != health.RingChecksum) {
+		log.Printf("⚠️ 节点 %s 的哈希环视图与本节点不一致: 对端版本 %d 校验和 %s，本节点版本 %d 校验和 %s",
+			node.NodeID, health.Epoch, health.RingChecksum, epoch, checksum)
+	}
+	node.RingChecksum = health.RingChecksum
+	node.RingDiverged = diverged
+
+	if !diverged && health.Epoch > epoch && cm.epochSink != nil {
+		cm.epochSink(health.Epoch)
+	}
 }
 
 // AddNode 添加节点到集群
@@ -203,6 +536,7 @@ func (cm *ClusterManager) RemoveNode(nodeID string) {
 	defer cm.mu.Unlock()
 	
 	delete(cm.nodes, nodeID)
+	delete(cm.detectors, nodeID)
 	log.Printf("➖ 从集群中移除节点: %s", nodeID)
 }
 
@@ -212,10 +546,10 @@ func (cm *ClusterManager) GetNodes() map[string]*NodeInfo {
 	defer cm.mu.RUnlock()
 	
 	// 返回副本
+	now := time.Now()
 	nodes := make(map[string]*NodeInfo)
 	for id, node := range cm.nodes {
-		nodeCopy := *node
-		nodes[id] = &nodeCopy
+		nodes[id] = cm.copyNode(node, now)
 	}
 	
 	return nodes
@@ -226,11 +560,11 @@ func (cm *ClusterManager) GetHealthyNodes() map[string]*NodeInfo {
 	cm.mu.RLock()
 	defer cm.mu.RUnlock()
 	
+	now := time.Now()
 	healthyNodes := make(map[string]*NodeInfo)
 	for id, node := range cm.nodes {
 		if node.Status == "healthy" {
-			nodeCopy := *node
-			healthyNodes[id] = &nodeCopy
+			healthyNodes[id] = cm.copyNode(node, now)
 		}
 	}
 	
@@ -249,8 +583,7 @@ func (cm *ClusterManager) GetClusterStatus() *ClusterStatus {
 	}
 	
 	for _, node := range cm.nodes {
-		nodeCopy := *node
-		status.Nodes = append(status.Nodes, &nodeCopy)
+		status.Nodes = append(status.Nodes, cm.copyNode(node, status.LastUpdate))
 		
 		if node.Status == "healthy" {
 			status.HealthyNodes++
@@ -280,6 +613,11 @@ func (cm *ClusterManager) IsHealthy() bool {
 
 // Leave 离开集群
 func (cm *ClusterManager) Leave() error {
+	// 开启gossip时由gossip广播离开
+	if cm.gossip {
+		return nil
+	}
+
 	leaveData := map[string]string{
 		"node_id": cm.nodeID,
 	}
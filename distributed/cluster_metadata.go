@@ -0,0 +1,376 @@
+package distributed
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// 集群元数据状态机
+//
+// 开启Raft后，节点加入、离开、驱逐、权重修改和集群级缓存配置都作为命令写入Raft日志，
+// 每个节点的状态机按日志顺序把命令应用到本地哈希环（并迁移数据），取代协调器的广播。
+// 加入和离开同时变更Raft投票成员。命令的应用是幂等的：重复的加入或移除不改变哈希环，节点重启后可以重放日志。
+// 命令在提议前检查一次，应用时先检查再修改哈希环：检查只依赖命令本身和集群一致的放置配置，
+// 所有节点对同一条命令得出相同的结论，通过检查的命令不会应用到一半。
+// 状态机支持快照，日志压缩后落后的节点或新加入的节点通过快照把哈希环对齐到快照中的元数据。
+
+// maxMetadataResults 保留的命令应用结果数，提议节点应用后据此返回迁移数量等结果
+const maxMetadataResults = 256
+
+// MetadataNode 元数据中的节点
+type MetadataNode struct {
+	Address string  `json:"address"`
+	Weight  float64 `json:"weight,omitempty"`
+	Zone    string  `json:"zone,omitempty"`
+	Rack    string  `json:"rack,omitempty"`
+}
+
+// ClusterMetadata Raft复制的集群元数据
+type ClusterMetadata struct {
+	Nodes        map[string]MetadataNode `json:"nodes"`
+	Epoch        uint64                  `json:"epoch"`         // 最近一次拓扑变更所在的日志索引，节点的拓扑版本号不小于它
+	AppliedIndex uint64                  `json:"applied_index"` // 已应用的日志索引
+	Config       ConfigUpdateRequest     `json:"config"`        // 集群级缓存配置，未设置的字段为null
+}
+
+// metadataCommand 写入Raft日志的元数据命令
+type metadataCommand struct {
+	Change *NodeChangeRequest   `json:"change,omitempty"`
+	Config *ConfigUpdateRequest `json:"config,omitempty"`
+}
+
+// metadataSnapshot 元数据状态机的快照，被驱逐的节点一并保存，领导者变化后仍可重新接纳
+type metadataSnapshot struct {
+	Metadata ClusterMetadata `json:"metadata"`
+	Evicted  []EvictedNode   `json:"evicted,omitempty"`
+}
+
+// metadataResult 命令在本节点的应用结果
+type metadataResult struct {
+	count int // 迁移或淘汰的key数量
+	err   error
+}
+
+// clusterMetadata 集群元数据状态机
+type clusterMetadata struct {
+	raft        *Raft
+	node        *DistributedNode
+	cluster     *ClusterManager
+	coordinator *ClusterCoordinator
+	evictor     *NodeEvictor
+
+	mu      sync.Mutex
+	state   ClusterMetadata
+	results map[uint64]metadataResult
+}
+
+// newClusterMetadata 以本节点启动时的集群配置作为初始元数据
+func newClusterMetadata(node *DistributedNode, cluster *ClusterManager, coordinator *ClusterCoordinator, evictor *NodeEvictor) *clusterMetadata {
+	weights := node.GetNodeWeights()
+	locations := node.GetNodeLocations()
+	nodes := make(map[string]MetadataNode)
+	for nodeID, address := range node.GetClusterNodes() {
+		nodes[nodeID] = MetadataNode{
+			Address: address,
+			Weight:  weights[nodeID],
+			Zone:    locations[nodeID].Zone,
+			Rack:    locations[nodeID].Rack,
+		}
+	}
+
+	return &clusterMetadata{
+		node:        node,
+		cluster:     cluster,
+		coordinator: coordinator,
+		evictor:     evictor,
+		state:       ClusterMetadata{Nodes: nodes},
+		results:     make(map[uint64]metadataResult),
+	}
+}
+
+// Apply 应用已提交的元数据命令（实现 RaftFSM）
+func (m *clusterMetadata) Apply(index uint64, command []byte) {
+	var cmd metadataCommand
+	var result metadataResult
+	if err := json.Unmarshal(command, &cmd); err != nil {
+		result.err = fmt.Errorf("解析元数据命令失败: %v", err)
+	} else if cmd.Change != nil {
+		result = m.applyChange(index, *cmd.Change)
+	} else if cmd.Config != nil {
+		result = m.applyConfig(*cmd.Config)
+	}
+	if result.err != nil {
+		log.Printf("⚠️ 应用元数据命令 %d 失败: %v", index, result.err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.state.AppliedIndex = index
+	m.results[index] = result
+	if index > maxMetadataResults {
+		delete(m.results, index-maxMetadataResults)
+	}
+}
+
+// applyChange 把拓扑变更应用到本地哈希环；本节点始终保留在自己的哈希环中
+// 未通过检查的命令在所有节点上都不生效；通过检查后元数据按命令更新，与哈希环保持一致
+func (m *clusterMetadata) applyChange(index uint64, change NodeChangeRequest) metadataResult {
+	if err := m.validateChange(change); err != nil {
+		return metadataResult{err: err}
+	}
+
+	var result metadataResult
+	nodeID := change.NodeID
+	address, inRing := m.node.GetClusterNodes()[nodeID]
+	self := nodeID == m.node.GetNodeID()
+
+	switch change.Operation {
+	case "add":
+		switch {
+		case self:
+		case !inRing:
+			result.err = m.coordinator.SyncAddNode(change)
+		case change.Address != "" && change.Address != address:
+			// 已在哈希环中的节点换了地址重新加入
+			m.coordinator.UpdateNodeAddress(nodeID, change.Address)
+		}
+	case "remove":
+		if inRing && !self {
+			result.err = m.coordinator.SyncRemoveNode(nodeID)
+		}
+		// 被驱逐的节点记录下来，领导者变化后由新的领导者负责重新接纳
+		if change.Reason == NodeChangeEvicted && !m.evictor.isEvicted(nodeID) {
+			m.evictor.remember(change)
+			m.cluster.RecordEvent(EventNodeEvicted, nodeID, "节点已被集群驱逐，移出哈希环")
+		}
+	case "weight":
+		// 提议后节点被移除时权重修改不再生效，各节点按日志顺序得出相同结果
+		if inRing {
+			result.count, result.err = m.coordinator.SyncNodeWeight(nodeID, change.Weight)
+		}
+	}
+	if result.err != nil {
+		log.Printf("❌ 应用已检查的拓扑变更失败: %s %s: %v", change.Operation, nodeID, result.err)
+	}
+
+	m.mu.Lock()
+	switch change.Operation {
+	case "add":
+		m.state.Nodes[nodeID] = MetadataNode{Address: change.Address, Weight: change.Weight, Zone: change.Zone, Rack: change.Rack}
+	case "remove":
+		delete(m.state.Nodes, nodeID)
+	case "weight":
+		if node, exists := m.state.Nodes[nodeID]; exists {
+			node.Weight = change.Weight
+			m.state.Nodes[nodeID] = node
+		}
+	}
+	m.state.Epoch = index
+	m.mu.Unlock()
+
+	m.node.ObserveRingEpoch(index)
+	return result
+}
+
+// validateChange 检查拓扑变更，提议前和应用前各检查一次，通过检查的变更应用时不会失败
+func (m *clusterMetadata) validateChange(change NodeChangeRequest) error {
+	if change.NodeID == "" {
+		return fmt.Errorf("节点ID不能为空")
+	}
+	switch change.Operation {
+	case "add":
+		return m.coordinator.validateNodeAttributes(change)
+	case "remove":
+		return nil
+	case "weight":
+		return m.node.hashRing.ValidateNodeWeight(change.Weight)
+	}
+	return fmt.Errorf("未知操作: %s", change.Operation)
+}
+
+// validateConfig 检查集群级缓存配置，通过检查的配置应用时不会失败
+func validateConfig(config ConfigUpdateRequest) error {
+	if config.CacheSize != nil && *config.CacheSize <= 0 {
+		return fmt.Errorf("容量必须大于0: %d", *config.CacheSize)
+	}
+	if config.MemoryLimit != nil && *config.MemoryLimit < 0 {
+		return fmt.Errorf("内存限制不能为负数: %d", *config.MemoryLimit)
+	}
+	return nil
+}
+
+// applyConfig 应用集群级缓存配置
+func (m *clusterMetadata) applyConfig(config ConfigUpdateRequest) metadataResult {
+	if err := validateConfig(config); err != nil {
+		return metadataResult{err: err}
+	}
+
+	var result metadataResult
+	if config.CacheSize != nil {
+		count, err := m.node.ResizeCache(*config.CacheSize)
+		if err != nil {
+			return metadataResult{err: err}
+		}
+		result.count += count
+	}
+	if config.MemoryLimit != nil {
+		count, err := m.node.SetCacheMemoryLimit(*config.MemoryLimit)
+		if err != nil {
+			return metadataResult{err: err}
+		}
+		result.count += count
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if config.CacheSize != nil {
+		m.state.Config.CacheSize = config.CacheSize
+	}
+	if config.MemoryLimit != nil {
+		m.state.Config.MemoryLimit = config.MemoryLimit
+	}
+	return result
+}
+
+// proposeChange 提交拓扑变更，在本节点应用后返回迁移的key数量；加入和离开同时变更Raft投票成员
+func (m *clusterMetadata) proposeChange(change NodeChangeRequest) (int, error) {
+	if err := m.validateChange(change); err != nil {
+		return 0, err
+	}
+	if change.Operation == "weight" {
+		if _, exists := m.snapshot().Nodes[change.NodeID]; !exists {
+			return 0, fmt.Errorf("节点不存在: %s", change.NodeID)
+		}
+	}
+
+	command, err := json.Marshal(metadataCommand{Change: &change})
+	if err != nil {
+		return 0, fmt.Errorf("序列化元数据命令失败: %v", err)
+	}
+
+	var index uint64
+	switch change.Operation {
+	case "add":
+		index, err = m.raft.ProposeMembership(command, RaftMembershipChange{NodeID: change.NodeID, Address: change.Address})
+	case "remove":
+		index, err = m.raft.ProposeMembership(command, RaftMembershipChange{NodeID: change.NodeID, Remove: true})
+	default:
+		index, err = m.raft.Propose(command)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("提交集群元数据变更失败: %v", err)
+	}
+	return m.result(index)
+}
+
+// proposeConfig 提交集群级缓存配置，在本节点应用后返回淘汰的key数量
+func (m *clusterMetadata) proposeConfig(config ConfigUpdateRequest) (int, error) {
+	if err := validateConfig(config); err != nil {
+		return 0, err
+	}
+
+	command, err := json.Marshal(metadataCommand{Config: &config})
+	if err != nil {
+		return 0, fmt.Errorf("序列化元数据命令失败: %v", err)
+	}
+	index, err := m.raft.Propose(command)
+	if err != nil {
+		return 0, fmt.Errorf("提交集群配置失败: %v", err)
+	}
+	return m.result(index)
+}
+
+// result 获取命令在本节点的应用结果
+func (m *clusterMetadata) result(index uint64) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := m.results[index]
+	return result.count, result.err
+}
+
+// snapshot 获取元数据副本
+func (m *clusterMetadata) snapshot() ClusterMetadata {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := m.state
+	snapshot.Nodes = make(map[string]MetadataNode, len(m.state.Nodes))
+	for nodeID, node := range m.state.Nodes {
+		snapshot.Nodes[nodeID] = node
+	}
+	return snapshot
+}
+
+// Snapshot 序列化当前元数据（实现 RaftSnapshotter）
+func (m *clusterMetadata) Snapshot() ([]byte, error) {
+	return json.Marshal(metadataSnapshot{Metadata: m.snapshot(), Evicted: m.evictor.Evicted()})
+}
+
+// Restore 用快照替换元数据，并把本地哈希环对齐到快照中的节点（实现 RaftSnapshotter）
+// 先检查快照中的所有节点和配置，再修改哈希环，不会对齐到一半失败
+func (m *clusterMetadata) Restore(data []byte) error {
+	var snapshot metadataSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("解析元数据快照失败: %v", err)
+	}
+	state := snapshot.Metadata
+	if state.Nodes == nil {
+		state.Nodes = make(map[string]MetadataNode)
+	}
+	for nodeID, node := range state.Nodes {
+		change := NodeChangeRequest{NodeID: nodeID, Address: node.Address, Weight: node.Weight, Zone: node.Zone, Rack: node.Rack, Operation: "add"}
+		if err := m.validateChange(change); err != nil {
+			return fmt.Errorf("快照中的节点 %s 无效: %v", nodeID, err)
+		}
+	}
+	if err := validateConfig(state.Config); err != nil {
+		return fmt.Errorf("快照中的集群配置无效: %v", err)
+	}
+
+	selfID := m.node.GetNodeID()
+	for nodeID := range m.node.GetClusterNodes() {
+		if _, exists := state.Nodes[nodeID]; !exists && nodeID != selfID {
+			if err := m.coordinator.SyncRemoveNode(nodeID); err != nil {
+				log.Printf("❌ 从快照恢复时移除节点 %s 失败: %v", nodeID, err)
+			}
+		}
+	}
+	for nodeID, node := range state.Nodes {
+		address, inRing := m.node.GetClusterNodes()[nodeID]
+		switch {
+		case nodeID == selfID:
+		case !inRing:
+			change := NodeChangeRequest{NodeID: nodeID, Address: node.Address, Weight: node.Weight, Zone: node.Zone, Rack: node.Rack, Operation: "add"}
+			if err := m.coordinator.SyncAddNode(change); err != nil {
+				log.Printf("❌ 从快照恢复时添加节点 %s 失败: %v", nodeID, err)
+			}
+		case node.Address != "" && node.Address != address:
+			m.coordinator.UpdateNodeAddress(nodeID, node.Address)
+		}
+	}
+	weights := m.node.GetNodeWeights()
+	for nodeID, node := range state.Nodes {
+		if node.Weight > 0 && node.Weight != weights[nodeID] {
+			if _, err := m.coordinator.SyncNodeWeight(nodeID, node.Weight); err != nil {
+				log.Printf("❌ 从快照恢复时设置节点 %s 权重失败: %v", nodeID, err)
+			}
+		}
+	}
+	if result := m.applyConfig(state.Config); result.err != nil {
+		log.Printf("❌ 从快照恢复集群配置失败: %v", result.err)
+	}
+	for _, evicted := range snapshot.Evicted {
+		if !m.evictor.isEvicted(evicted.NodeID) {
+			m.evictor.remember(NodeChangeRequest{NodeID: evicted.NodeID, Address: evicted.Address, Weight: evicted.Weight, Zone: evicted.Zone, Rack: evicted.Rack})
+		}
+	}
+
+	m.mu.Lock()
+	m.state = state
+	m.mu.Unlock()
+	m.node.ObserveRingEpoch(state.Epoch)
+	log.Printf("📸 从快照恢复集群元数据: %d 个节点，已应用索引 %d", len(state.Nodes), state.AppliedIndex)
+	return nil
+}
@@ -1,10 +1,13 @@
 package distributed
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -22,13 +25,37 @@ type DistributedNode struct {
 	hashRing    *core.DistributedCache
 	
 	// 本地缓存 - 只存储分配给当前节点的数据
-	localCache  *core.LRUCache
+	localCache  core.Storage
 	
 	// 集群节点映射 - nodeID -> address
 	clusterNodes map[string]string
 	
 	// HTTP客户端 - 用于节点间通信
 	httpClient  *http.Client
+
+	// 节点间二进制RPC - 对端支持时优先使用，否则回退到HTTP
+	rpc         *rpcTransport
+	rpcAddress  string // 本节点公布的RPC地址，为空表示未启用
+
+	// 有界负载 - 负载度量方式，为空表示未开启；按请求速率度量时统计本地请求
+	loadMode     string
+	requestMeter *requestRateMeter
+
+	// 转发请求收到重定向（目标节点不负责该key）的次数
+	staleRedirects uint64
+
+	// 多副本仲裁参数，以及未达到仲裁数的读写次数
+	quorum              Quorum
+	quorumWriteFailures uint64
+	quorumReadFailures  uint64
+	readRepairState     *readRepairState
+
+	// 提示移交 - 目标节点不可达时暂存写入，恢复后重放；nil表示未开启
+	hints    *hintStore
+	replayMu sync.Mutex // 同一时间只有一次重放
+
+	// 反熵修复 - 定期与副本对端对比Merkle树并修复不一致的key
+	antiEntropy *antiEntropy
 	
 	// 并发控制
 	mu          sync.RWMutex
@@ -39,10 +66,86 @@ type NodeConfig struct {
 	NodeID       string            `yaml:"node_id"`
 	Address      string            `yaml:"address"`
 	ClusterNodes map[string]string `yaml:"cluster_nodes"`
+	NodeWeights  map[string]float64 `yaml:"-"` // 节点权重，来自 cluster_nodes 中的 weight 字段，未配置为1
+	NodeLocations map[string]core.NodeLocation `yaml:"-"` // 节点位置，来自 cluster_nodes 中的 zone/rack 字段
+	ReplicaConstraint string `yaml:"replica_constraint"` // 副本位置约束: zone(默认) / rack / none
 	CacheSize    int               `yaml:"cache_size"`
+	MemoryLimit  int64             `yaml:"memory_limit"` // 本地缓存内存限制（字节），0表示无限制
+	StorageEngine string           `yaml:"storage_engine"` // 本地存储引擎: heap(默认) / arena
 	VirtualNodes int               `yaml:"virtual_nodes"`
+	HashFunction string            `yaml:"hash_function"` // 哈希环使用的哈希函数: sha1(默认) / fnv1a / murmur3 / xxhash，集群内必须一致
+	Placement    string            `yaml:"placement"`     // 数据放置算法: ring(默认) / rendezvous / jump / maglev，集群内必须一致
+
+	// 有界负载: ε > 0 时开启，节点负载超过 (1+ε)×平均负载 后由哈希环上的下一个节点接收数据（仅 ring 放置算法）
+	BoundedLoadEpsilon float64 `yaml:"bounded_load_epsilon"`
+	BoundedLoadMode    string  `yaml:"bounded_load_mode"` // 负载度量: keys(默认，key数量) / requests(请求速率)
+
+	// 准入策略: "" / "none" 表示不启用, "tinylfu" 启用TinyLFU
+	AdmissionPolicy   string `yaml:"admission_policy"`
+	AdmissionCounters int    `yaml:"admission_counters"` // 频率计数器数量，默认为缓存大小的10倍
+
+	// memcached 文本协议监听地址，为空表示不启用
+	MemcachedAddress string `yaml:"memcached_address"`
+
+	// Redis RESP 协议监听地址，为空表示不启用
+	RESPAddress string            `yaml:"resp_address"`
+	RESPMode    string            `yaml:"resp_mode"`  // 非本地key的处理方式: "proxy"(默认) / "redirect"
+	RESPPeers   map[string]string `yaml:"resp_peers"` // 各节点的RESP地址，redirect模式返回MOVED时使用
+
+	// 节点间二进制RPC监听地址，为空表示不启用（其他节点转发到本节点时使用HTTP）
+	RPCAddress  string `yaml:"rpc_address"`
+	RPCPoolSize int    `yaml:"rpc_pool_size"` // 到每个节点的RPC连接数，默认4
+
+	// 多副本: 每个key保存在N个节点上，写入W个副本确认后返回，读取R个副本后按版本号合并
+	ReplicationFactor int `yaml:"replication_factor"` // 副本数N，默认1（不复制）
+	ReadQuorum        int `yaml:"read_quorum"`        // 读仲裁数R，默认为N的多数派
+	WriteQuorum       int `yaml:"write_quorum"`       // 写仲裁数W，默认为N的多数派
+	ReadRepairChance  *float64 `yaml:"read_repair_chance"` // 仲裁读取后在后台修复落后副本的概率，默认0.1，0表示关闭
+
+	// 提示移交: 写入的目标节点不可达时由本节点暂存，目标节点恢复健康后重放
+	HintedHandoff bool          `yaml:"hinted_handoff"`
+	HintDir       string        `yaml:"hint_dir"`  // 提示持久化目录，为空时只保存在内存中
+	MaxHints      int           `yaml:"max_hints"` // 最多暂存的提示数，默认10000
+	HintTTL       time.Duration `yaml:"hint_ttl"`  // 提示保留时间，默认1小时
+
+	// 反熵修复间隔（仅多副本），0表示不定期执行（仍可通过 POST /admin/anti-entropy 手动触发）
+	AntiEntropyInterval time.Duration `yaml:"anti_entropy_interval"`
+
+	// Phi Accrual 故障检测: 健康检查成功记为心跳，按心跳间隔计算 φ，超过阈值才判定节点不健康
+	PhiThreshold        float64       `yaml:"phi_threshold"`         // 默认8，越大越不容易误判但发现故障越慢
+	HealthCheckInterval time.Duration `yaml:"health_check_interval"` // 健康检查间隔，默认10s
+
+	// 节点驱逐: 节点不健康超过宽限期后经多数派同意移出哈希环，恢复后清空旧数据重新加入，0表示不驱逐
+	EvictionGracePeriod time.Duration `yaml:"eviction_grace_period"`
+
+	// SWIM gossip 成员管理: 开启后由gossip探测节点存活并传播成员变更，取代健康检查轮询；
+	// 新节点只需在 seeds 中配置一个已有节点的地址即可加入，cluster_nodes 可以只包含本节点
+	Gossip                 bool          `yaml:"gossip"`
+	GossipTransport        string        `yaml:"gossip_transport"`         // http(默认，复用节点HTTP服务) / udp
+	GossipAddress          string        `yaml:"gossip_address"`           // udp 传输的监听地址
+	Seeds                  []string      `yaml:"seeds"`                    // 种子节点的gossip地址，http传输时为空则使用 cluster_nodes 中的其他节点
+	GossipInterval         time.Duration `yaml:"gossip_interval"`          // 探测间隔，默认1s
+	GossipProbeTimeout     time.Duration `yaml:"gossip_probe_timeout"`     // 直接探测超时，默认500ms
+	GossipIndirectProbes   int           `yaml:"gossip_indirect_probes"`   // 间接探测的成员数，默认3
+	GossipSuspicionTimeout time.Duration `yaml:"gossip_suspicion_timeout"` // 可疑成员判定为下线的时间，默认5s
+	GossipSyncInterval     time.Duration `yaml:"gossip_sync_interval"`     // 与随机成员全量同步的间隔，默认30s
+
+	// Raft 集群元数据: 开启后节点加入、离开、驱逐、权重和集群级配置写入Raft日志，所有节点按相同顺序应用；
+	// 引导模式下 cluster_nodes 为初始投票成员，之后加入的节点使用加入模式
+	Raft                  bool          `yaml:"raft"`
+	RaftMode              string        `yaml:"raft_mode"`               // bootstrap（默认）/ join
+	RaftDir               string        `yaml:"raft_dir"`                // 持久化目录，为空时只保存在内存中（raft-node1.state / .log / .snapshot）
+	RaftElectionTimeout   time.Duration `yaml:"raft_election_timeout"`   // 选举超时，默认1s
+	RaftHeartbeatInterval time.Duration `yaml:"raft_heartbeat_interval"` // 领导者心跳间隔，默认100ms
+	RaftSnapshotThreshold int           `yaml:"raft_snapshot_threshold"` // 应用多少条目后生成快照并压缩日志，默认1024
 }
 
+// 准入策略名称
+const (
+	AdmissionPolicyNone    = "none"
+	AdmissionPolicyTinyLFU = "tinylfu"
+)
+
 // NewDistributedNode 创建分布式节点实例
 func NewDistributedNode(config NodeConfig) *DistributedNode {
 	// 1. 创建全局哈希环 - 包含所有集群节点
@@ -52,11 +155,32 @@ func NewDistributedNode(config NodeConfig) *DistributedNode {
 	}
 	
 	// 使用节点ID作为哈希环的标识符，而不是地址
-	var hashRing *core.DistributedCache
-	if config.VirtualNodes > 0 {
-		hashRing = core.NewDistributedCacheWithVirtualNodes(allNodes, config.VirtualNodes)
-	} else {
-		hashRing = core.NewDistributedCache(allNodes)
+	hasher, err := core.NewHasher(config.HashFunction)
+	if err != nil {
+		log.Printf("⚠️ %v，使用默认哈希函数 %s", err, core.HasherSHA1)
+		hasher, _ = core.NewHasher(core.HasherSHA1)
+	}
+	hashRing, err := core.NewDistributedCacheWithPlacement(allNodes, config.VirtualNodes, hasher, config.Placement)
+	if err != nil {
+		log.Printf("⚠️ %v，使用默认放置算法 %s", err, core.PlacementRing)
+		hashRing = core.NewDistributedCacheWithHasher(allNodes, config.VirtualNodes, hasher)
+	}
+	for nodeID, weight := range config.NodeWeights {
+		if _, err := hashRing.SetNodeWeight(nodeID, weight); err != nil {
+			log.Printf("⚠️ 节点 %s 权重无效，使用默认权重: %v", nodeID, err)
+		}
+	}
+	for nodeID, location := range config.NodeLocations {
+		hashRing.SetNodeLocation(nodeID, location)
+	}
+	// 默认要求副本不在同一可用区，未标注位置的节点不受约束
+	replicaConstraint := config.ReplicaConstraint
+	if replicaConstraint == "" {
+		replicaConstraint = core.ReplicaConstraintZone
+	}
+	if err := hashRing.SetReplicaConstraint(replicaConstraint); err != nil {
+		log.Printf("⚠️ %v，使用默认约束 %s", err, core.ReplicaConstraintZone)
+		hashRing.SetReplicaConstraint(core.ReplicaConstraintZone)
 	}
 	
 	// 2. 创建本地缓存
@@ -64,30 +188,64 @@ func NewDistributedNode(config NodeConfig) *DistributedNode {
 	if cacheSize <= 0 {
 		cacheSize = 1000 // 默认大小
 	}
-	localCache := core.NewLRUCache(cacheSize)
+	localCache, err := core.NewStorage(config.StorageEngine, cacheSize, config.MemoryLimit)
+	if err != nil {
+		log.Printf("⚠️ %v，使用默认存储引擎 %s", err, core.StorageEngineHeap)
+		localCache, _ = core.NewStorage(core.StorageEngineHeap, cacheSize, config.MemoryLimit)
+	}
+	if config.AdmissionPolicy == AdmissionPolicyTinyLFU {
+		localCache.EnableAdmission(config.AdmissionCounters)
+	}
+	// 按哈希索引本地key，节点加入时只需迁移变化的哈希区间
+	localCache.EnableHashIndex(hashRing.HashKey)
 	
+	// 集群节点映射会随节点加入/离开而修改，复制一份避免影响调用方的配置
+	clusterNodes := make(map[string]string, len(config.ClusterNodes))
+	for nodeID, address := range config.ClusterNodes {
+		clusterNodes[nodeID] = address
+	}
+
 	// 3. 创建节点实例
 	node := &DistributedNode{
 		nodeID:       config.NodeID,
 		nodeAddress:  config.ClusterNodes[config.NodeID], // 从配置中获取自己的地址
 		hashRing:     hashRing,
 		localCache:   localCache,
-		clusterNodes: config.ClusterNodes,
+		clusterNodes: clusterNodes,
 		httpClient: createNodeHTTPClient(5 * time.Second),
+		rpc:        newRPCTransport(config.RPCPoolSize, 5*time.Second),
+		quorum:     newQuorum(config.ReplicationFactor, config.ReadQuorum, config.WriteQuorum),
+		antiEntropy: &antiEntropy{interval: config.AntiEntropyInterval},
+		readRepairState: newReadRepairState(config.ReadRepairChance),
+	}
+	if config.BoundedLoadEpsilon > 0 {
+		node.enableBoundedLoad(config.BoundedLoadEpsilon, config.BoundedLoadMode)
+	}
+	if config.HintedHandoff {
+		hints, err := newHintStore(config.NodeID, config.HintDir, config.MaxHints, config.HintTTL)
+		if err != nil {
+			log.Printf("⚠️ %v，提示只保存在内存中", err)
+			hints, _ = newHintStore(config.NodeID, "", config.MaxHints, config.HintTTL)
+		}
+		node.hints = hints
 	}
 	
 	return node
 }
 
-// Set 设置缓存数据
+// Set 设置缓存数据（按集群配置的副本数和写仲裁数）
 func (dn *DistributedNode) Set(key, value string) error {
+	return dn.SetWithQuorum(key, value, Quorum{})
+}
+
+// setOnOwner 单副本写入 - 写入哈希环上负责该key的节点
+func (dn *DistributedNode) setOnOwner(key, value string) error {
 	// 1. 通过哈希环确定数据应该存储在哪个节点
 	targetNodeID := dn.hashRing.GetNodeForKey(key)
 
 	// 2. 如果是本地节点，直接存储
 	if targetNodeID == dn.nodeID {
-		dn.localCache.Set(key, value)
-		return nil
+		return dn.SetLocal(key, value)
 	}
 
 	// 3. 如果是远程节点，转发请求（需要读取集群配置，加读锁保护）
@@ -99,16 +257,29 @@ func (dn *DistributedNode) Set(key, value string) error {
 		return fmt.Errorf("目标节点不存在: %s", targetNodeID)
 	}
 
-	return dn.forwardSetRequestSafe(targetAddress, key, value)
+	err := dn.forwardWithRedirect(targetAddress, func(address string) error {
+		return dn.forwardSetRequestSafe(address, key, value)
+	})
+	if err != nil {
+		// 目标节点不可达时由本节点暂存，恢复后重放
+		return dn.storeHint(targetNodeID, key, core.VersionedEntry{Value: value, Version: dn.localCache.NewVersion()}, err)
+	}
+	return nil
 }
 
-// Get 获取缓存数据
+// Get 获取缓存数据（按集群配置的副本数和读仲裁数）
 func (dn *DistributedNode) Get(key string) (string, bool, error) {
+	return dn.GetWithQuorum(key, Quorum{})
+}
+
+// getOnOwner 单副本读取 - 从哈希环上负责该key的节点读取
+func (dn *DistributedNode) getOnOwner(key string) (string, bool, error) {
 	// 1. 通过哈希环确定数据存储在哪个节点
 	targetNodeID := dn.hashRing.GetNodeForKey(key)
 
 	// 2. 如果是本地节点，直接获取
 	if targetNodeID == dn.nodeID {
+		dn.recordRequests(1)
 		value, found := dn.localCache.Get(key)
 		return value, found, nil
 	}
@@ -122,17 +293,35 @@ func (dn *DistributedNode) Get(key string) (string, bool, error) {
 		return "", false, fmt.Errorf("目标节点不存在: %s", targetNodeID)
 	}
 
-	return dn.forwardGetRequestSafe(targetAddress, key)
+	var value string
+	var found bool
+	err := dn.forwardWithRedirect(targetAddress, func(address string) (err error) {
+		value, found, err = dn.forwardGetRequestSafe(address, key)
+		return err
+	})
+	if isNodeUnavailable(err) {
+		// 目标节点不可达期间，返回本节点代为接收的写入
+		if value, found, hinted := dn.hintedValue(targetNodeID, key); hinted {
+			return value, found, nil
+		}
+	}
+	return value, found, err
 }
 
-// Delete 删除缓存数据
+// Delete 删除缓存数据（按集群配置的副本数和写仲裁数）
 func (dn *DistributedNode) Delete(key string) error {
+	return dn.DeleteWithQuorum(key, Quorum{})
+}
+
+// deleteOnOwner 单副本删除 - 删除哈希环上负责该key的节点中的数据
+func (dn *DistributedNode) deleteOnOwner(key string) error {
 	// 1. 通过哈希环确定数据存储在哪个节点
 	targetNodeID := dn.hashRing.GetNodeForKey(key)
 
 	// 2. 如果是本地节点，直接删除
 	if targetNodeID == dn.nodeID {
-		dn.localCache.Delete(key)
+		dn.recordRequests(1)
+		dn.deleteLocal(key)
 		return nil
 	}
 
@@ -145,19 +334,66 @@ func (dn *DistributedNode) Delete(key string) error {
 		return fmt.Errorf("目标节点不存在: %s", targetNodeID)
 	}
 
-	return dn.forwardDeleteRequestSafe(targetAddress, key)
+	err := dn.forwardWithRedirect(targetAddress, func(address string) error {
+		return dn.forwardDeleteRequestSafe(address, key)
+	})
+	if err != nil {
+		return dn.storeHint(targetNodeID, key, core.VersionedEntry{Version: dn.localCache.NewVersion(), Tombstone: true}, err)
+	}
+	return nil
 }
 
 // GetLocalStats 获取本地缓存统计信息
 func (dn *DistributedNode) GetLocalStats() map[string]interface{} {
 	stats := dn.localCache.GetStats()
-	return map[string]interface{}{
+	result := map[string]interface{}{
 		"total_Hits":     stats.Hits,
 		"total_Misses":   stats.Misses,
 		"total_Size":     dn.localCache.Size(),
 		"hit_Rate":       stats.HitRate(),
 		"total_Requests": stats.TotalRequests,
 	}
+
+	// 启用准入策略时附带准入统计
+	if admission, enabled := dn.localCache.GetAdmissionStats(); enabled {
+		result["admission_Admitted"] = admission.Admitted
+		result["admission_Rejected"] = admission.Rejected
+	}
+
+	rpcStats := dn.rpc.stats()
+	result["rpc_Calls"] = rpcStats.Calls
+	result["rpc_HTTPFallbacks"] = rpcStats.HTTPFallbacks
+	result["route_StaleRedirects"] = dn.GetStaleRedirects()
+
+	return result
+}
+
+// CacheConfig 本地缓存的运行时配置
+type CacheConfig struct {
+	CacheSize   int   `json:"cache_size"`
+	MemoryLimit int64 `json:"memory_limit"`
+	Size        int   `json:"size"`
+	MemoryUsage int64 `json:"memory_usage"`
+}
+
+// GetCacheConfig 获取本地缓存当前的容量和内存限制
+func (dn *DistributedNode) GetCacheConfig() CacheConfig {
+	return CacheConfig{
+		CacheSize:   dn.localCache.Capacity(),
+		MemoryLimit: dn.localCache.GetMemoryLimit(),
+		Size:        dn.localCache.Size(),
+		MemoryUsage: dn.localCache.GetMemoryUsage(),
+	}
+}
+
+// ResizeCache 运行时调整本地缓存容量，返回被淘汰的条目数
+func (dn *DistributedNode) ResizeCache(capacity int) (int, error) {
+	return dn.localCache.Resize(capacity)
+}
+
+// SetCacheMemoryLimit 运行时调整本地缓存内存限制，返回被淘汰的条目数
+func (dn *DistributedNode) SetCacheMemoryLimit(memoryLimitBytes int64) (int, error) {
+	return dn.localCache.SetMemoryLimit(memoryLimitBytes)
 }
 
 // GetNodeID 获取节点ID
@@ -176,32 +412,94 @@ func (dn *DistributedNode) IsLocalKey(key string) bool {
 	return targetNodeID == dn.nodeID
 }
 
+// GetNodeForKey 获取负责该key的节点ID
+func (dn *DistributedNode) GetNodeForKey(key string) string {
+	return dn.hashRing.GetNodeForKey(key)
+}
+
+// GetNodeWeights 获取集群中各节点的权重
+func (dn *DistributedNode) GetNodeWeights() map[string]float64 {
+	weights := make(map[string]float64)
+	for nodeID := range dn.GetClusterNodes() {
+		weights[nodeID] = dn.hashRing.GetNodeWeight(nodeID)
+	}
+	return weights
+}
+
+// GetNodesForKey 获取负责key的n个不同节点，第一个为主节点，其余按副本位置约束选取
+func (dn *DistributedNode) GetNodesForKey(key string, n int) []string {
+	return dn.hashRing.GetNodesForKey(key, n)
+}
+
+// GetNodeLocations 获取集群中已标注位置的节点
+func (dn *DistributedNode) GetNodeLocations() map[string]core.NodeLocation {
+	locations := make(map[string]core.NodeLocation)
+	for nodeID := range dn.GetClusterNodes() {
+		if location := dn.hashRing.GetNodeLocation(nodeID); location != (core.NodeLocation{}) {
+			locations[nodeID] = location
+		}
+	}
+	return locations
+}
+
+// GetPlacement 获取数据放置算法名称
+func (dn *DistributedNode) GetPlacement() string {
+	return dn.hashRing.PlacementName()
+}
+
+// GetHashFunction 获取哈希环使用的哈希函数名称
+func (dn *DistributedNode) GetHashFunction() string {
+	return dn.hashRing.HasherName()
+}
+
 // ===== 内部方法 =====
 
+// forwardViaRPC 优先通过二进制RPC转发
+// 返回 handled=false 表示对端不支持RPC或请求没有发出，由调用方回退到HTTP；
+// 请求发出后超时或连接断开时对端可能已经执行，直接返回错误，避免非幂等操作执行两次
+func (dn *DistributedNode) forwardViaRPC(targetAddress string, call func(pool *rpcPool) error) (bool, error) {
+	if pool := dn.rpc.poolFor(targetAddress); pool != nil {
+		err := call(pool)
+		if !isRPCNotSent(err) {
+			dn.rpc.recordCall(true)
+			if isRPCTransportError(err) {
+				return true, &NodeUnavailableError{Address: targetAddress, Err: err}
+			}
+			return true, err
+		}
+		log.Printf("⚠️ RPC转发失败，回退到HTTP: %s, 错误: %v", targetAddress, err)
+		dn.rpc.markFailed(targetAddress)
+	}
+	dn.rpc.recordCall(false)
+	return false, nil
+}
+
 // forwardSetRequestSafe 转发SET请求到目标节点（线程安全版本）
 func (dn *DistributedNode) forwardSetRequestSafe(targetAddress, key, value string) error {
-	
-	// 构造请求数据
-	reqData := map[string]string{"value": value}
-	jsonData, err := json.Marshal(reqData)
-	if err != nil {
-		return fmt.Errorf("序列化请求失败: %v", err)
+	if handled, err := dn.forwardViaRPC(targetAddress, func(pool *rpcPool) error {
+		return pool.set(key, value, dn.hashRing.Epoch())
+	}); handled {
+		return err
 	}
 	
-	// 发送内部API请求
-	url := fmt.Sprintf("http://%s/internal/cache/%s", targetAddress, key)
-	req, err := http.NewRequest("PUT", url, bytes.NewBuffer(jsonData))
+	// 发送内部API请求（key使用base64url编码，value原样作为请求体，保证二进制安全）
+	url := fmt.Sprintf("http://%s/internal/bin/%s", targetAddress, core.EncodeKey(key))
+	req, err := http.NewRequest("PUT", url, strings.NewReader(value))
 	if err != nil {
 		return fmt.Errorf("创建请求失败: %v", err)
 	}
-	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	dn.setEpochHeader(req)
 	
 	resp, err := dn.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("转发请求失败: %v", err)
+		return &NodeUnavailableError{Address: targetAddress, Err: err}
 	}
 	defer resp.Body.Close()
 	
+	if resp.StatusCode == http.StatusMisdirectedRequest {
+		return decodeStaleRoute(resp)
+	}
 	if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("目标节点返回错误: %d", resp.StatusCode)
 	}
@@ -211,49 +509,73 @@ func (dn *DistributedNode) forwardSetRequestSafe(targetAddress, key, value strin
 
 // forwardGetRequestSafe 转发GET请求到目标节点（线程安全版本）
 func (dn *DistributedNode) forwardGetRequestSafe(targetAddress, key string) (string, bool, error) {
+	var value string
+	var found bool
+	if handled, err := dn.forwardViaRPC(targetAddress, func(pool *rpcPool) (err error) {
+		value, found, err = pool.get(key, dn.hashRing.Epoch())
+		return err
+	}); handled {
+		return value, found, err
+	}
 	
 	// 发送内部API请求
-	url := fmt.Sprintf("http://%s/internal/cache/%s", targetAddress, key)
-	resp, err := dn.httpClient.Get(url)
+	url := fmt.Sprintf("http://%s/internal/bin/%s", targetAddress, core.EncodeKey(key))
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", false, fmt.Errorf("创建请求失败: %v", err)
+	}
+	dn.setEpochHeader(req)
+
+	resp, err := dn.httpClient.Do(req)
 	if err != nil {
-		return "", false, fmt.Errorf("转发请求失败: %v", err)
+		return "", false, &NodeUnavailableError{Address: targetAddress, Err: err}
 	}
 	defer resp.Body.Close()
 	
+	if resp.StatusCode == http.StatusNotFound {
+		return "", false, nil
+	}
+	if resp.StatusCode == http.StatusMisdirectedRequest {
+		return "", false, decodeStaleRoute(resp)
+	}
 	if resp.StatusCode != http.StatusOK {
 		return "", false, fmt.Errorf("目标节点返回错误: %d", resp.StatusCode)
 	}
 	
-	// 解析响应
-	var response struct {
-		Key   string `json:"key"`
-		Value string `json:"value"`
-		Found bool   `json:"found"`
-	}
-	
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return "", false, fmt.Errorf("解析响应失败: %v", err)
+	// 响应体即为原始value
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", false, fmt.Errorf("读取响应失败: %v", err)
 	}
 	
-	return response.Value, response.Found, nil
+	return string(data), true, nil
 }
 
 // forwardDeleteRequestSafe 转发DELETE请求到目标节点（线程安全版本）
 func (dn *DistributedNode) forwardDeleteRequestSafe(targetAddress, key string) error {
+	if handled, err := dn.forwardViaRPC(targetAddress, func(pool *rpcPool) error {
+		return pool.delete(key, dn.hashRing.Epoch())
+	}); handled {
+		return err
+	}
 	
 	// 发送内部API请求
-	url := fmt.Sprintf("http://%s/internal/cache/%s", targetAddress, key)
+	url := fmt.Sprintf("http://%s/internal/bin/%s", targetAddress, core.EncodeKey(key))
 	req, err := http.NewRequest("DELETE", url, nil)
 	if err != nil {
 		return fmt.Errorf("创建请求失败: %v", err)
 	}
+	dn.setEpochHeader(req)
 	
 	resp, err := dn.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("转发请求失败: %v", err)
+		return &NodeUnavailableError{Address: targetAddress, Err: err}
 	}
 	defer resp.Body.Close()
 	
+	if resp.StatusCode == http.StatusMisdirectedRequest {
+		return decodeStaleRoute(resp)
+	}
 	if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("目标节点返回错误: %d", resp.StatusCode)
 	}
@@ -263,18 +585,189 @@ func (dn *DistributedNode) forwardDeleteRequestSafe(targetAddress, key string) e
 
 // SetLocal 直接设置到本地缓存 - 用于内部API
 func (dn *DistributedNode) SetLocal(key, value string) error {
-	dn.localCache.Set(key, value)
+	dn.recordRequests(1)
+	if !dn.localCache.TrySet(key, value) {
+		return fmt.Errorf("节点 %s 拒绝写入: 超出内存限制", dn.nodeID)
+	}
+	return nil
+}
+
+// MigrateLocal 写入迁移来的条目 - 保留原版本号，本地已有更新的版本时不覆盖；
+// 跳过准入策略直接进入主缓存，源节点在迁移成功后即删除本地数据，迁移来的key不能因为在本节点没有访问记录而被淘汰
+func (dn *DistributedNode) MigrateLocal(key string, entry core.VersionedEntry) error {
+	dn.recordRequests(1)
+	if !dn.localCache.SetVersionedBypassAdmission(key, entry) {
+		return fmt.Errorf("节点 %s 拒绝迁移写入: 超出内存限制", dn.nodeID)
+	}
 	return nil
 }
 
 // GetLocal 直接从本地缓存获取 - 用于内部API
 func (dn *DistributedNode) GetLocal(key string) (string, bool) {
+	dn.recordRequests(1)
 	return dn.localCache.Get(key)
 }
 
-// DeleteLocal 直接从本地缓存删除 - 用于内部API
+// DeleteLocal 在本地缓存写入删除标记 - 用于内部API
 func (dn *DistributedNode) DeleteLocal(key string) {
-	dn.localCache.Delete(key)
+	dn.recordRequests(1)
+	dn.deleteLocal(key)
+}
+
+// deleteLocal 以删除标记的形式删除本地数据，避免其他副本上的旧版本在读修复或反熵时复活
+// 返回写入的删除标记以及删除前key是否存在
+func (dn *DistributedNode) deleteLocal(key string) (core.VersionedEntry, bool) {
+	_, found := dn.localCache.Inspect(key)
+	tombstone := core.VersionedEntry{Version: dn.localCache.NewVersion(), Tombstone: true}
+	dn.localCache.SetVersioned(key, tombstone)
+	return tombstone, found
+}
+
+// ResetLocalData 清空本地缓存，返回删除的key数量 - 被驱逐的节点重新加入前丢弃过期数据
+func (dn *DistributedNode) ResetLocalData() int {
+	data := dn.localCache.GetAllData()
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	return dn.localCache.DeleteMulti(keys)
+}
+
+// ===== Key元数据查询 =====
+
+// KeyInspection key元数据查询结果
+type KeyInspection struct {
+	Key      string            `json:"key"`
+	NodeID   string            `json:"node_id"` // 负责该key的节点
+	Found    bool              `json:"found"`
+	Metadata *core.KeyMetadata `json:"metadata,omitempty"`
+}
+
+// InspectLocal 查询本地缓存中key的元数据
+func (dn *DistributedNode) InspectLocal(key string) KeyInspection {
+	result := KeyInspection{Key: key, NodeID: dn.nodeID}
+	if meta, found := dn.localCache.Inspect(key); found {
+		result.Found = true
+		result.Metadata = &meta
+	}
+	return result
+}
+
+// InspectKey 查询key的元数据，自动路由到负责该key的节点
+func (dn *DistributedNode) InspectKey(key string) (KeyInspection, error) {
+	targetNodeID := dn.hashRing.GetNodeForKey(key)
+	if targetNodeID == dn.nodeID {
+		return dn.InspectLocal(key), nil
+	}
+
+	dn.mu.RLock()
+	targetAddress, exists := dn.clusterNodes[targetNodeID]
+	dn.mu.RUnlock()
+
+	if !exists {
+		return KeyInspection{}, fmt.Errorf("目标节点不存在: %s", targetNodeID)
+	}
+
+	var result KeyInspection
+	url := fmt.Sprintf("http://%s/internal/key/%s", targetAddress, core.EncodeKey(key))
+	if err := dn.getJSON(url, &result); err != nil {
+		return KeyInspection{}, err
+	}
+	// JSON无法无损表示非UTF-8字节，使用请求时的原始key
+	result.Key = key
+	if result.Metadata != nil {
+		result.Metadata.Key = key
+	}
+	return result, nil
+}
+
+// LargestLocalKeys 本地缓存中占用内存最大的n个key
+func (dn *DistributedNode) LargestLocalKeys(n int) []core.KeyMetadata {
+	return dn.localCache.LargestKeys(n)
+}
+
+// LargeKeysReport 单个节点的大key报告
+type LargeKeysReport struct {
+	NodeID string             `json:"node_id"`
+	Keys   []core.KeyMetadata `json:"keys"`
+	Error  string             `json:"error,omitempty"`
+}
+
+// LargestKeysByNode 收集集群中每个节点占用内存最大的n个key
+func (dn *DistributedNode) LargestKeysByNode(n int) []LargeKeysReport {
+	nodes := dn.GetClusterNodes()
+
+	nodeIDs := make([]string, 0, len(nodes))
+	for nodeID := range nodes {
+		nodeIDs = append(nodeIDs, nodeID)
+	}
+	sort.Strings(nodeIDs)
+
+	reports := make([]LargeKeysReport, len(nodeIDs))
+	var wg sync.WaitGroup
+	for i, nodeID := range nodeIDs {
+		if nodeID == dn.nodeID {
+			reports[i] = LargeKeysReport{NodeID: nodeID, Keys: dn.LargestLocalKeys(n)}
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, nodeID, address string) {
+			defer wg.Done()
+			report := LargeKeysReport{NodeID: nodeID}
+			url := fmt.Sprintf("http://%s/internal/large-keys?n=%d", address, n)
+			if err := dn.getJSON(url, &report); err != nil {
+				report.Error = err.Error()
+			}
+			reports[i] = report
+		}(i, nodeID, nodes[nodeID])
+	}
+	wg.Wait()
+
+	return reports
+}
+
+// getJSON 向其他节点发送GET请求并解析JSON响应
+func (dn *DistributedNode) getJSON(url string, out interface{}) error {
+	resp, err := dn.httpClient.Get(url)
+	if err != nil {
+		return fmt.Errorf("转发请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("目标节点返回错误: %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("解析响应失败: %v", err)
+	}
+	return nil
+}
+
+// ===== 节点间RPC =====
+
+// setRPCAddress 设置本节点公布的RPC地址（由RPCServer启动/停止时调用）
+func (dn *DistributedNode) setRPCAddress(address string) {
+	dn.mu.Lock()
+	defer dn.mu.Unlock()
+	dn.rpcAddress = address
+}
+
+// GetRPCInfo 获取本节点的RPC信息，供其他节点发现
+func (dn *DistributedNode) GetRPCInfo() RPCInfo {
+	dn.mu.RLock()
+	defer dn.mu.RUnlock()
+	return RPCInfo{NodeID: dn.nodeID, RPCAddress: dn.rpcAddress}
+}
+
+// GetRPCStats 获取节点间转发的RPC统计
+func (dn *DistributedNode) GetRPCStats() RPCStats {
+	return dn.rpc.stats()
+}
+
+// CloseRPC 关闭到其他节点的RPC连接
+func (dn *DistributedNode) CloseRPC() {
+	dn.rpc.close()
 }
 
 // ===== 集群配置管理方法 =====
@@ -0,0 +1,51 @@
+package distributed
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+)
+
+// 持久化文件的落盘辅助函数
+//
+// 追加写入的日志在返回前 fsync；整体重写时先写临时文件并 fsync，
+// 再 rename 替换并 fsync 所在目录，崩溃后看到的要么是旧文件，要么是完整的新文件。
+
+// replaceFile 原子地重写文件，write 向缓冲写入新内容
+func replaceFile(path string, write func(w *bufio.Writer) error) error {
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	if err := write(writer); err != nil {
+		file.Close()
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// syncDir fsync目录，使目录中文件的创建和重命名落盘
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
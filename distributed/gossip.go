@@ -0,0 +1,773 @@
+package distributed
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SWIM gossip 成员管理
+//
+// 每个探测周期按随机轮转的顺序选一个成员直接 ping；超时后请 k 个其他成员代为 ping（ping-req），
+// 仍无响应则把它标记为可疑（suspect），可疑超过 suspicion_timeout 未被反驳则判定为下线（dead）。
+// 节点收到关于自己的可疑或下线消息时递增化身号（incarnation）并广播存活，反驳怀疑。
+// 成员变更捎带在 ping/ack 消息中传播，每条变更重传 O(log n) 次。
+// 新节点只需知道一个种子地址：与种子交换完整成员列表（push-pull）即可加入；之后定期与随机成员全量同步，修复分区后的视图。
+
+// 成员状态
+const (
+	MemberAlive   = "alive"
+	MemberSuspect = "suspect"
+	MemberDead    = "dead"
+	MemberLeft    = "left" // 主动离开
+)
+
+// gossip 传输方式
+const (
+	GossipTransportHTTP = "http" // 复用节点HTTP服务（POST /internal/gossip）
+	GossipTransportUDP  = "udp"
+)
+
+// gossip 默认参数
+const (
+	DefaultGossipInterval         = time.Second
+	DefaultGossipProbeTimeout     = 500 * time.Millisecond
+	DefaultGossipIndirectProbes   = 3
+	DefaultGossipSuspicionTimeout = 5 * time.Second
+	DefaultGossipSyncInterval     = 30 * time.Second
+
+	gossipMaxPiggyback   = 8 // 每条消息最多捎带的成员变更数
+	gossipRetransmitMult = 3 // 每条变更重传 3×log2(n+2) 次
+)
+
+// gossip 消息类型
+const (
+	gossipPing    = "ping"
+	gossipPingReq = "ping-req"
+	gossipAck     = "ack"
+	gossipNack    = "nack"
+	gossipSync    = "sync"
+)
+
+// GossipConfig gossip 参数，零值使用默认值
+type GossipConfig struct {
+	Transport        string        // http(默认) / udp
+	BindAddress      string        // udp 监听地址
+	Seeds            []string      // 种子节点的gossip地址
+	ProbeInterval    time.Duration // 探测间隔
+	ProbeTimeout     time.Duration // 直接探测的超时，间接探测为其2倍
+	IndirectProbes   int           // 直接探测失败后代为探测的成员数k
+	SuspicionTimeout time.Duration // 可疑成员被判定为下线的时间
+	SyncInterval     time.Duration // 与随机成员全量同步的间隔
+}
+
+// withDefaults 填充默认值
+func (c GossipConfig) withDefaults() GossipConfig {
+	if c.Transport == "" {
+		c.Transport = GossipTransportHTTP
+	}
+	if c.ProbeInterval <= 0 {
+		c.ProbeInterval = DefaultGossipInterval
+	}
+	if c.ProbeTimeout <= 0 {
+		c.ProbeTimeout = DefaultGossipProbeTimeout
+	}
+	if c.IndirectProbes <= 0 {
+		c.IndirectProbes = DefaultGossipIndirectProbes
+	}
+	if c.SuspicionTimeout <= 0 {
+		c.SuspicionTimeout = DefaultGossipSuspicionTimeout
+	}
+	if c.SyncInterval <= 0 {
+		c.SyncInterval = DefaultGossipSyncInterval
+	}
+	return c
+}
+
+// Member 集群成员，同时作为gossip消息中传播的成员变更
+type Member struct {
+	NodeID        string    `json:"node_id"`
+	Address       string    `json:"address"`        // HTTP地址
+	GossipAddress string    `json:"gossip_address"` // gossip地址，http传输时与HTTP地址相同
+	State         string    `json:"state"`
+	Incarnation   uint64    `json:"incarnation"`
+	Weight        float64   `json:"weight,omitempty"`
+	Zone          string    `json:"zone,omitempty"`
+	Rack          string    `json:"rack,omitempty"`
+	StateChanged  time.Time `json:"state_changed"`
+}
+
+// MemberEvent 成员状态变化，Previous 为空表示新成员
+type MemberEvent struct {
+	Member   Member
+	Previous string
+}
+
+// GossipStats gossip 统计
+type GossipStats struct {
+	Transport         string `json:"transport"`
+	GossipAddress     string `json:"gossip_address"`
+	Incarnation       uint64 `json:"incarnation"`
+	Alive             int    `json:"alive"`
+	Suspect           int    `json:"suspect"`
+	Dead              int    `json:"dead"`
+	Left              int    `json:"left"`
+	Probes            uint64 `json:"probes"`
+	ProbeFailures     uint64 `json:"probe_failures"`     // 直接探测失败次数
+	IndirectProbes    uint64 `json:"indirect_probes"`    // 发出的ping-req数
+	Suspicions        uint64 `json:"suspicions"`         // 成员进入可疑状态的次数
+	Refutations       uint64 `json:"refutations"`        // 反驳关于本节点怀疑的次数
+	PendingBroadcasts int    `json:"pending_broadcasts"` // 待传播的成员变更数
+}
+
+// gossipMessage gossip 消息
+type gossipMessage struct {
+	Type         string      `json:"type"`
+	Seq          uint64      `json:"seq"`
+	Reply        bool        `json:"reply,omitempty"`
+	From         string      `json:"from"`
+	TargetID     string      `json:"target_id,omitempty"`     // 探测目标的节点ID，地址被其他节点复用时拒绝
+	Target       string      `json:"target,omitempty"`        // ping-req 代为探测的gossip地址
+	Members      []Member    `json:"members,omitempty"`       // 捎带的成员变更，sync 时为完整成员列表
+	Health       *nodeHealth `json:"health,omitempty"`        // ack 中公布的负载和拓扑版本
+	HashFunction string      `json:"hash_function,omitempty"` // sync 时校验哈希函数
+	Placement    string      `json:"placement,omitempty"`     // sync 时校验放置算法
+	Error        string      `json:"error,omitempty"`
+}
+
+// gossipBroadcast 待传播的成员变更
+type gossipBroadcast struct {
+	member    Member
+	transmits int
+}
+
+// Gossiper SWIM 成员管理
+type Gossiper struct {
+	config    GossipConfig
+	transport gossipTransport
+	seq       uint64
+
+	mu         sync.Mutex
+	self       Member
+	members    map[string]*Member // 不包含本节点
+	queue      map[string]*gossipBroadcast
+	probeOrder []string
+	stats      GossipStats
+
+	hashFunction string
+	placement    string
+
+	eventHandler func(MemberEvent)
+	healthSource func() nodeHealth
+	healthSink   func(nodeID string, health nodeHealth)
+
+	events   chan MemberEvent
+	stopChan chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewGossiper 创建gossip成员管理，self 为本节点（需要NodeID和HTTP地址）
+// 使用udp传输时立即开始监听，监听地址的主机部分为空时使用HTTP地址的主机
+func NewGossiper(config GossipConfig, self Member) (*Gossiper, error) {
+	g := &Gossiper{
+		config:  config.withDefaults(),
+		members: make(map[string]*Member),
+		queue:   make(map[string]*gossipBroadcast),
+		events:  make(chan MemberEvent, 256),
+		done:    make(chan struct{}),
+	}
+
+	switch g.config.Transport {
+	case GossipTransportHTTP:
+		g.transport = newHTTPGossipTransport()
+		if self.GossipAddress == "" {
+			self.GossipAddress = self.Address
+		}
+	case GossipTransportUDP:
+		transport, err := newUDPGossipTransport(g.config.BindAddress, g.handle)
+		if err != nil {
+			return nil, fmt.Errorf("启动gossip UDP监听失败: %v", err)
+		}
+		g.transport = transport
+		self.GossipAddress = advertiseAddress(transport.localAddr(), self.Address)
+	default:
+		return nil, fmt.Errorf("未知的gossip传输方式: %s", g.config.Transport)
+	}
+
+	// 化身号从启动时间开始，重启的节点自然比之前的记录新
+	self.State = MemberAlive
+	self.Incarnation = uint64(time.Now().UnixNano())
+	self.StateChanged = time.Now()
+	g.self = self
+	g.stats.Transport = g.config.Transport
+
+	if transport, ok := g.transport.(*udpGossipTransport); ok {
+		transport.serve()
+	}
+	go g.dispatchEvents()
+	return g, nil
+}
+
+// SetRingConfig 设置本节点的哈希函数和放置算法，不一致的节点不能通过本节点加入
+func (g *Gossiper) SetRingConfig(hashFunction, placement string) {
+	g.hashFunction = hashFunction
+	g.placement = placement
+}
+
+// SetEventHandler 设置成员状态变化的回调，回调按发生顺序在同一个协程中执行
+func (g *Gossiper) SetEventHandler(handler func(MemberEvent)) {
+	g.eventHandler = handler
+}
+
+// SetHealthReporter 设置ack中公布的本节点状态，以及收到对端ack时的处理
+func (g *Gossiper) SetHealthReporter(source func() nodeHealth, sink func(nodeID string, health nodeHealth)) {
+	g.healthSource = source
+	g.healthSink = sink
+}
+
+// Start 启动后台探测：先加入种子节点，之后每个探测周期探测一个成员，定期与随机成员全量同步
+func (g *Gossiper) Start() {
+	if g.stopChan != nil {
+		return
+	}
+	g.stopChan = make(chan struct{})
+	log.Printf("💬 启动gossip成员管理 (%s %s)，种子节点: %v", g.config.Transport, g.self.GossipAddress, g.config.Seeds)
+
+	go func(stop chan struct{}) {
+		probe := time.NewTicker(g.config.ProbeInterval)
+		defer probe.Stop()
+		sync := time.NewTicker(g.config.SyncInterval)
+		defer sync.Stop()
+
+		g.joinSeeds()
+		for {
+			select {
+			case <-probe.C:
+				if !g.joined() {
+					g.joinSeeds()
+				}
+				g.Probe()
+			case <-sync.C:
+				g.syncRandom()
+			case <-stop:
+				return
+			}
+		}
+	}(g.stopChan)
+}
+
+// Stop 停止探测和监听
+func (g *Gossiper) Stop() {
+	g.stopOnce.Do(func() {
+		if g.stopChan != nil {
+			close(g.stopChan)
+		}
+		close(g.done)
+		g.transport.close()
+	})
+}
+
+// Join 与种子节点交换完整成员列表，任一种子成功即返回已知的成员数（不含本节点）
+func (g *Gossiper) Join(seeds []string) (int, error) {
+	var lastErr error
+	for _, seed := range seeds {
+		if seed == g.self.GossipAddress {
+			continue
+		}
+		if err := g.pushPull(seed); err != nil {
+			lastErr = err
+			continue
+		}
+		g.mu.Lock()
+		count := len(g.members)
+		g.mu.Unlock()
+		log.Printf("🤝 通过种子节点 %s 加入集群，已知 %d 个成员", seed, count)
+		return count, nil
+	}
+	if lastErr != nil {
+		return 0, fmt.Errorf("加入集群失败，所有种子节点均不可用: %v", lastErr)
+	}
+	return 0, nil
+}
+
+// Leave 广播本节点主动离开，其他节点收到后把本节点移出集群
+func (g *Gossiper) Leave() {
+	g.mu.Lock()
+	g.self.State = MemberLeft
+	g.self.Incarnation++
+	left := g.self
+	targets := g.membersInState(MemberAlive, MemberSuspect)
+	g.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, target := range targets {
+		wg.Add(1)
+		go func(target Member) {
+			defer wg.Done()
+			msg := g.newMessage(gossipPing)
+			msg.TargetID = target.NodeID
+			msg.Members = []Member{left}
+			g.transport.call(target.GossipAddress, msg, g.config.ProbeTimeout)
+		}(target)
+	}
+	wg.Wait()
+	log.Printf("👋 已通知 %d 个成员本节点离开", len(targets))
+}
+
+// Probe 执行一次探测：先处理超时的可疑成员，再探测下一个成员
+func (g *Gossiper) Probe() {
+	g.expireSuspects()
+
+	target, ok := g.nextTarget()
+	if !ok {
+		return
+	}
+	atomic.AddUint64(&g.stats.Probes, 1)
+	if health, ok := g.ping(target.GossipAddress, target.NodeID); ok {
+		g.observeHealth(target.NodeID, health)
+		return
+	}
+
+	atomic.AddUint64(&g.stats.ProbeFailures, 1)
+	if g.indirectPing(target) {
+		return
+	}
+	g.merge([]Member{{
+		NodeID:      target.NodeID,
+		Address:     target.Address,
+		State:       MemberSuspect,
+		Incarnation: target.Incarnation,
+	}})
+}
+
+// Members 所有成员（包含本节点），按节点ID排序
+func (g *Gossiper) Members() []Member {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.snapshot()
+}
+
+// GetStats 获取gossip统计
+func (g *Gossiper) GetStats() GossipStats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	stats := GossipStats{
+		Transport:         g.stats.Transport,
+		GossipAddress:     g.self.GossipAddress,
+		Incarnation:       g.self.Incarnation,
+		Probes:            atomic.LoadUint64(&g.stats.Probes),
+		ProbeFailures:     atomic.LoadUint64(&g.stats.ProbeFailures),
+		IndirectProbes:    atomic.LoadUint64(&g.stats.IndirectProbes),
+		Suspicions:        g.stats.Suspicions,
+		Refutations:       g.stats.Refutations,
+		PendingBroadcasts: len(g.queue),
+	}
+	for _, member := range g.members {
+		switch member.State {
+		case MemberAlive:
+			stats.Alive++
+		case MemberSuspect:
+			stats.Suspect++
+		case MemberDead:
+			stats.Dead++
+		case MemberLeft:
+			stats.Left++
+		}
+	}
+	return stats
+}
+
+// handle 处理收到的gossip消息并返回回复
+func (g *Gossiper) handle(msg gossipMessage) gossipMessage {
+	if msg.Type == gossipSync && (msg.HashFunction != g.hashFunction || msg.Placement != g.placement) {
+		reply := g.newReply(msg, gossipNack)
+		reply.Error = fmt.Sprintf("节点 %s 使用哈希函数 %s、放置算法 %s，集群使用 %s、%s",
+			msg.From, msg.HashFunction, msg.Placement, g.hashFunction, g.placement)
+		return reply
+	}
+	// 地址可能已被其他节点复用，ping 的目标不是本节点时不能回复ack
+	if msg.Type == gossipPing && msg.TargetID != "" && msg.TargetID != g.self.NodeID {
+		reply := g.newReply(msg, gossipNack)
+		reply.Error = fmt.Sprintf("本节点是 %s，不是 %s", g.self.NodeID, msg.TargetID)
+		return reply
+	}
+	g.merge(msg.Members)
+
+	switch msg.Type {
+	case gossipPing:
+		reply := g.newReply(msg, gossipAck)
+		reply.Members = g.piggyback(msg.From)
+		if g.healthSource != nil {
+			health := g.healthSource()
+			reply.Health = &health
+		}
+		return reply
+	case gossipPingReq:
+		health, ok := g.ping(msg.Target, msg.TargetID)
+		if !ok {
+			return g.newReply(msg, gossipNack)
+		}
+		reply := g.newReply(msg, gossipAck)
+		reply.Health = health
+		return reply
+	case gossipSync:
+		reply := g.newReply(msg, gossipSync)
+		g.mu.Lock()
+		reply.Members = g.snapshot()
+		g.mu.Unlock()
+		return reply
+	}
+	reply := g.newReply(msg, gossipNack)
+	reply.Error = "未知的消息类型: " + msg.Type
+	return reply
+}
+
+// ping 直接探测，成功时返回对端公布的状态
+func (g *Gossiper) ping(address, nodeID string) (*nodeHealth, bool) {
+	msg := g.newMessage(gossipPing)
+	msg.TargetID = nodeID
+	msg.Members = g.piggyback(nodeID)
+
+	reply, err := g.transport.call(address, msg, g.config.ProbeTimeout)
+	if err != nil {
+		return nil, false
+	}
+	g.merge(reply.Members)
+	return reply.Health, reply.Type == gossipAck
+}
+
+// indirectPing 请k个存活成员代为探测，任一成功即认为目标存活
+func (g *Gossiper) indirectPing(target Member) bool {
+	g.mu.Lock()
+	helpers := g.membersInState(MemberAlive)
+	g.mu.Unlock()
+	rand.Shuffle(len(helpers), func(i, j int) { helpers[i], helpers[j] = helpers[j], helpers[i] })
+
+	results := make(chan *gossipMessage, g.config.IndirectProbes)
+	sent := 0
+	for _, helper := range helpers {
+		if sent == g.config.IndirectProbes {
+			break
+		}
+		if helper.NodeID == target.NodeID {
+			continue
+		}
+		sent++
+		atomic.AddUint64(&g.stats.IndirectProbes, 1)
+
+		msg := g.newMessage(gossipPingReq)
+		msg.Target = target.GossipAddress
+		msg.TargetID = target.NodeID
+		msg.Members = g.piggyback(helper.NodeID)
+		go func(address string, msg gossipMessage) {
+			reply, err := g.transport.call(address, msg, 2*g.config.ProbeTimeout)
+			if err != nil {
+				results <- nil
+				return
+			}
+			results <- &reply
+		}(helper.GossipAddress, msg)
+	}
+
+	acked := false
+	for i := 0; i < sent; i++ {
+		reply := <-results
+		if reply == nil {
+			continue
+		}
+		g.merge(reply.Members)
+		if reply.Type == gossipAck && !acked {
+			acked = true
+			g.observeHealth(target.NodeID, reply.Health)
+		}
+	}
+	return acked
+}
+
+// pushPull 与指定地址交换完整成员列表
+func (g *Gossiper) pushPull(address string) error {
+	msg := g.newMessage(gossipSync)
+	msg.HashFunction, msg.Placement = g.hashFunction, g.placement
+	g.mu.Lock()
+	msg.Members = g.snapshot()
+	g.mu.Unlock()
+
+	reply, err := g.transport.call(address, msg, 4*g.config.ProbeTimeout)
+	if err != nil {
+		return err
+	}
+	if reply.Type == gossipNack {
+		return fmt.Errorf("节点 %s 拒绝同步: %s", address, reply.Error)
+	}
+	g.merge(reply.Members)
+	return nil
+}
+
+// joinSeeds 加入种子节点，失败时下个探测周期重试
+func (g *Gossiper) joinSeeds() {
+	if len(g.config.Seeds) == 0 {
+		return
+	}
+	if _, err := g.Join(g.config.Seeds); err != nil {
+		log.Printf("⚠️ %v", err)
+	}
+}
+
+// joined 是否已经知道其他成员（未配置种子时视为已加入）
+func (g *Gossiper) joined() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.members) > 0 || len(g.config.Seeds) == 0
+}
+
+// syncRandom 与随机成员全量同步，包括已下线的成员，分区恢复后双方借此重新发现对方
+func (g *Gossiper) syncRandom() {
+	g.mu.Lock()
+	candidates := g.membersInState(MemberAlive, MemberSuspect, MemberDead)
+	g.mu.Unlock()
+	if len(candidates) == 0 {
+		return
+	}
+	g.pushPull(candidates[rand.Intn(len(candidates))].GossipAddress)
+}
+
+// nextTarget 按随机轮转的顺序选择下一个探测目标，一轮结束后重新打乱
+func (g *Gossiper) nextTarget() (Member, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for attempt := 0; attempt < 2; attempt++ {
+		for len(g.probeOrder) > 0 {
+			nodeID := g.probeOrder[0]
+			g.probeOrder = g.probeOrder[1:]
+			if member, ok := g.members[nodeID]; ok && (member.State == MemberAlive || member.State == MemberSuspect) {
+				return *member, true
+			}
+		}
+		for _, member := range g.membersInState(MemberAlive, MemberSuspect) {
+			g.probeOrder = append(g.probeOrder, member.NodeID)
+		}
+		rand.Shuffle(len(g.probeOrder), func(i, j int) {
+			g.probeOrder[i], g.probeOrder[j] = g.probeOrder[j], g.probeOrder[i]
+		})
+	}
+	return Member{}, false
+}
+
+// expireSuspects 可疑超过 suspicion_timeout 的成员判定为下线
+func (g *Gossiper) expireSuspects() {
+	g.mu.Lock()
+	var events []MemberEvent
+	for _, member := range g.members {
+		if member.State == MemberSuspect && time.Since(member.StateChanged) >= g.config.SuspicionTimeout {
+			dead := *member
+			dead.State = MemberDead
+			if event := g.applyUpdate(dead); event != nil {
+				events = append(events, *event)
+			}
+		}
+	}
+	g.mu.Unlock()
+	g.emit(events)
+}
+
+// merge 合并收到的成员变更
+func (g *Gossiper) merge(updates []Member) {
+	if len(updates) == 0 {
+		return
+	}
+	g.mu.Lock()
+	var events []MemberEvent
+	for _, update := range updates {
+		if event := g.applyUpdate(update); event != nil {
+			events = append(events, *event)
+		}
+	}
+	g.mu.Unlock()
+	g.emit(events)
+}
+
+// applyUpdate 按SWIM规则应用一条成员变更，状态变化时返回事件，调用方需持有 g.mu
+func (g *Gossiper) applyUpdate(update Member) *MemberEvent {
+	if update.NodeID == "" {
+		return nil
+	}
+
+	// 关于本节点的可疑或下线消息：递增化身号并广播存活
+	if update.NodeID == g.self.NodeID {
+		if g.self.State == MemberAlive && update.State != MemberAlive && update.Incarnation >= g.self.Incarnation {
+			g.self.Incarnation = update.Incarnation + 1
+			g.stats.Refutations++
+			g.broadcast(g.self)
+			log.Printf("🛡️ 反驳关于本节点的 %s 消息，化身号递增到 %d", update.State, g.self.Incarnation)
+		}
+		return nil
+	}
+
+	now := time.Now()
+	current, exists := g.members[update.NodeID]
+	if !exists {
+		member := update
+		member.StateChanged = now
+		g.members[member.NodeID] = &member
+		if member.State != MemberAlive && member.State != MemberSuspect {
+			return nil
+		}
+		g.broadcast(member)
+		log.Printf("➕ gossip发现新成员: %s (%s)", member.NodeID, member.Address)
+		return &MemberEvent{Member: member}
+	}
+	if !supersedes(update, *current) {
+		return nil
+	}
+
+	previous := current.State
+	if update.State == MemberAlive {
+		// 存活消息由节点自己发出，携带最新的地址和属性
+		*current = update
+	} else {
+		current.State = update.State
+		current.Incarnation = update.Incarnation
+	}
+	current.StateChanged = now
+	g.broadcast(*current)
+
+	if current.State == previous {
+		return nil
+	}
+	if current.State == MemberSuspect {
+		g.stats.Suspicions++
+	}
+	log.Printf("🔁 成员 %s 状态变化: %s -> %s (化身号 %d)", current.NodeID, previous, current.State, current.Incarnation)
+	return &MemberEvent{Member: *current, Previous: previous}
+}
+
+// supersedes 成员变更是否覆盖当前状态：
+// 存活需要更大的化身号；可疑覆盖同一化身号的存活；下线和离开覆盖同一化身号的存活和可疑，之后只有更大化身号的存活能恢复
+func supersedes(update, current Member) bool {
+	switch update.State {
+	case MemberAlive:
+		return update.Incarnation > current.Incarnation
+	case MemberSuspect:
+		switch current.State {
+		case MemberAlive:
+			return update.Incarnation >= current.Incarnation
+		case MemberSuspect:
+			return update.Incarnation > current.Incarnation
+		}
+	case MemberDead, MemberLeft:
+		if current.State == MemberDead || current.State == MemberLeft {
+			return false
+		}
+		return update.Incarnation >= current.Incarnation
+	}
+	return false
+}
+
+// broadcast 把成员变更加入待传播队列，同一成员只保留最新的变更，调用方需持有 g.mu
+func (g *Gossiper) broadcast(member Member) {
+	g.queue[member.NodeID] = &gossipBroadcast{member: member}
+}
+
+// piggyback 取出待传播的成员变更捎带在消息中，优先传播次数少的变更；
+// 发给可疑成员的消息总是带上对它的怀疑，让它有机会反驳
+func (g *Gossiper) piggyback(targetID string) []Member {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	pending := make([]*gossipBroadcast, 0, len(g.queue))
+	for _, item := range g.queue {
+		pending = append(pending, item)
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].transmits < pending[j].transmits })
+
+	limit := gossipRetransmitMult * int(math.Ceil(math.Log2(float64(len(g.members)+2))))
+	updates := make([]Member, 0, gossipMaxPiggyback+1)
+	includesTarget := false
+	for _, item := range pending {
+		if len(updates) == gossipMaxPiggyback {
+			break
+		}
+		updates = append(updates, item.member)
+		includesTarget = includesTarget || item.member.NodeID == targetID
+		if item.transmits++; item.transmits >= limit {
+			delete(g.queue, item.member.NodeID)
+		}
+	}
+	if target, ok := g.members[targetID]; ok && target.State == MemberSuspect && !includesTarget {
+		updates = append(updates, *target)
+	}
+	return updates
+}
+
+// snapshot 所有成员（包含本节点）的副本，调用方需持有 g.mu
+func (g *Gossiper) snapshot() []Member {
+	members := make([]Member, 0, len(g.members)+1)
+	members = append(members, g.self)
+	for _, member := range g.members {
+		members = append(members, *member)
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].NodeID < members[j].NodeID })
+	return members
+}
+
+// membersInState 处于指定状态的成员，调用方需持有 g.mu
+func (g *Gossiper) membersInState(states ...string) []Member {
+	var members []Member
+	for _, member := range g.members {
+		for _, state := range states {
+			if member.State == state {
+				members = append(members, *member)
+				break
+			}
+		}
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].NodeID < members[j].NodeID })
+	return members
+}
+
+// observeHealth 把探测ack中公布的状态交给健康处理回调
+func (g *Gossiper) observeHealth(nodeID string, health *nodeHealth) {
+	if health != nil && g.healthSink != nil {
+		g.healthSink(nodeID, *health)
+	}
+}
+
+func (g *Gossiper) newMessage(messageType string) gossipMessage {
+	return gossipMessage{Type: messageType, Seq: atomic.AddUint64(&g.seq, 1), From: g.self.NodeID}
+}
+
+func (g *Gossiper) newReply(msg gossipMessage, messageType string) gossipMessage {
+	return gossipMessage{Type: messageType, Seq: msg.Seq, Reply: true, From: g.self.NodeID}
+}
+
+// emit 把事件交给事件协程，保证回调按发生顺序执行且不阻塞探测和消息处理
+func (g *Gossiper) emit(events []MemberEvent) {
+	for _, event := range events {
+		select {
+		case g.events <- event:
+		case <-g.done:
+			return
+		}
+	}
+}
+
+func (g *Gossiper) dispatchEvents() {
+	for {
+		select {
+		case event := <-g.events:
+			if g.eventHandler != nil {
+				g.eventHandler(event)
+			}
+		case <-g.done:
+			return
+		}
+	}
+}
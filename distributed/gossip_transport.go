@@ -0,0 +1,206 @@
+package distributed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxGossipPacket UDP报文上限，完整成员列表（sync）超过时需要改用http传输
+const maxGossipPacket = 65507
+
+// gossipTransport gossip 消息的请求-响应传输
+type gossipTransport interface {
+	call(address string, msg gossipMessage, timeout time.Duration) (gossipMessage, error)
+	close()
+}
+
+// httpGossipTransport 通过节点HTTP服务的 POST /internal/gossip 传输
+type httpGossipTransport struct {
+	client *http.Client
+}
+
+func newHTTPGossipTransport() *httpGossipTransport {
+	return &httpGossipTransport{client: &http.Client{}}
+}
+
+func (t *httpGossipTransport) call(address string, msg gossipMessage, timeout time.Duration) (gossipMessage, error) {
+	var reply gossipMessage
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return reply, fmt.Errorf("序列化gossip消息失败: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("http://%s/internal/gossip", address), bytes.NewReader(body))
+	if err != nil {
+		return reply, fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return reply, &NodeUnavailableError{Address: address, Err: err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return reply, fmt.Errorf("目标节点返回错误: %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return reply, fmt.Errorf("解析响应失败: %v", err)
+	}
+	return reply, nil
+}
+
+func (t *httpGossipTransport) close() {
+	t.client.CloseIdleConnections()
+}
+
+// udpGossipTransport 每条消息一个JSON报文，回复按序号匹配到等待中的请求
+type udpGossipTransport struct {
+	conn    *net.UDPConn
+	handler func(gossipMessage) gossipMessage
+
+	mu      sync.Mutex
+	pending map[uint64]chan gossipMessage
+	closed  chan struct{}
+	once    sync.Once
+}
+
+func newUDPGossipTransport(bindAddress string, handler func(gossipMessage) gossipMessage) (*udpGossipTransport, error) {
+	addr, err := net.ResolveUDPAddr("udp", bindAddress)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &udpGossipTransport{
+		conn:    conn,
+		handler: handler,
+		pending: make(map[uint64]chan gossipMessage),
+		closed:  make(chan struct{}),
+	}, nil
+}
+
+// serve 开始接收报文，本节点状态初始化完成后调用
+func (t *udpGossipTransport) serve() {
+	go t.readLoop()
+}
+
+func (t *udpGossipTransport) localAddr() string {
+	return t.conn.LocalAddr().String()
+}
+
+func (t *udpGossipTransport) call(address string, msg gossipMessage, timeout time.Duration) (gossipMessage, error) {
+	var reply gossipMessage
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return reply, fmt.Errorf("序列化gossip消息失败: %v", err)
+	}
+	if len(data) > maxGossipPacket {
+		return reply, fmt.Errorf("gossip消息 %d 字节，超过UDP报文上限", len(data))
+	}
+	addr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return reply, fmt.Errorf("解析地址失败: %v", err)
+	}
+
+	replies := make(chan gossipMessage, 1)
+	t.mu.Lock()
+	t.pending[msg.Seq] = replies
+	t.mu.Unlock()
+	defer func() {
+		t.mu.Lock()
+		delete(t.pending, msg.Seq)
+		t.mu.Unlock()
+	}()
+
+	if _, err := t.conn.WriteToUDP(data, addr); err != nil {
+		return reply, &NodeUnavailableError{Address: address, Err: err}
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case reply = <-replies:
+		return reply, nil
+	case <-timer.C:
+		return reply, &NodeUnavailableError{Address: address, Err: fmt.Errorf("等待回复超时 (%v)", timeout)}
+	case <-t.closed:
+		return reply, fmt.Errorf("gossip传输已关闭")
+	}
+}
+
+// readLoop 回复交给等待中的请求，其他消息交给handler处理后原路回复
+func (t *udpGossipTransport) readLoop() {
+	buf := make([]byte, maxGossipPacket)
+	for {
+		n, from, err := t.conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-t.closed:
+				return
+			default:
+				continue
+			}
+		}
+
+		var msg gossipMessage
+		if err := json.Unmarshal(buf[:n], &msg); err != nil {
+			continue
+		}
+		if msg.Reply {
+			t.mu.Lock()
+			replies := t.pending[msg.Seq]
+			t.mu.Unlock()
+			if replies != nil {
+				select {
+				case replies <- msg:
+				default:
+				}
+			}
+			continue
+		}
+
+		go func(msg gossipMessage, from *net.UDPAddr) {
+			data, err := json.Marshal(t.handler(msg))
+			if err != nil || len(data) > maxGossipPacket {
+				return
+			}
+			t.conn.WriteToUDP(data, from)
+		}(msg, from)
+	}
+}
+
+func (t *udpGossipTransport) close() {
+	t.once.Do(func() {
+		close(t.closed)
+		t.conn.Close()
+	})
+}
+
+// advertiseAddress 监听地址的主机部分为空或为通配地址时，使用HTTP地址的主机（默认localhost）
+func advertiseAddress(listenAddress, httpAddress string) string {
+	host, port, err := net.SplitHostPort(listenAddress)
+	if err != nil {
+		return listenAddress
+	}
+	if ip := net.ParseIP(host); host != "" && (ip == nil || !ip.IsUnspecified()) {
+		return listenAddress
+	}
+	host = "localhost"
+	if httpHost, _, err := net.SplitHostPort(httpAddress); err == nil && httpHost != "" {
+		if ip := net.ParseIP(httpHost); ip == nil || !ip.IsUnspecified() {
+			host = httpHost
+		}
+	}
+	return net.JoinHostPort(host, port)
+}
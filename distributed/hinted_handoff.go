@@ -0,0 +1,494 @@
+package distributed
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"tdd-learning/core"
+)
+
+// 提示移交（Hinted Handoff）
+//
+// 写入的目标节点暂时不可达时，接收请求的节点把这次写入连同版本号作为提示（hint）暂存下来，
+// 集群管理器发现该节点恢复健康后按原版本号重放，目标节点上更新的数据不会被覆盖。
+// 单副本（N=1）时由本节点代为接收写入并返回成功，目标节点恢复前读取该key会返回提示中的数据；
+// 多副本时提示不计入写仲裁数，只保证宕机的副本恢复后追上其他副本。
+// 每个目标节点的每个key只保留最新的一条提示，总数和保留时间有上限，配置目录后以追加日志的形式持久化：
+// 每次追加都 fsync 后才返回，重放成功的提示追加一条删除记录，失效记录超过一半时才重写日志。
+
+// 提示存储的默认上限
+const (
+	DefaultMaxHints = 10000
+	DefaultHintTTL  = time.Hour
+)
+
+// hintCompactMinDead 日志压缩阈值：失效记录少于该值时不重写，避免每次重放都重写整个文件
+const hintCompactMinDead = 1000
+
+// NodeUnavailableError 目标节点不可达（连接失败、超时），此时可以为该节点暂存提示
+type NodeUnavailableError struct {
+	Address string
+	Err     error
+}
+
+func (e *NodeUnavailableError) Error() string {
+	return fmt.Sprintf("转发请求失败: %v", e.Err)
+}
+
+// isNodeUnavailable 判断错误是否由目标节点不可达引起
+func isNodeUnavailable(err error) bool {
+	var unavailable *NodeUnavailableError
+	return errors.As(err, &unavailable)
+}
+
+// Hint 暂存的一次写入
+type Hint struct {
+	NodeID    string
+	Key       string
+	Entry     core.VersionedEntry
+	CreatedAt time.Time
+}
+
+// hintRecord 提示在日志文件中的格式，key和value按字节保存（JSON中为base64），保证二进制安全
+type hintRecord struct {
+	NodeID    string    `json:"node_id"`
+	Key       []byte    `json:"key"`
+	Value     []byte    `json:"value,omitempty"`
+	Version   uint64    `json:"version"`
+	Tombstone bool      `json:"tombstone,omitempty"`
+	Flags     uint32    `json:"flags,omitempty"`
+	ExpiresAt int64     `json:"expires_at,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	Removed   bool      `json:"removed,omitempty"` // 删除记录：该提示已重放，加载时删除版本不新于它的提示
+}
+
+func (h Hint) record() hintRecord {
+	return hintRecord{
+		NodeID:    h.NodeID,
+		Key:       []byte(h.Key),
+		Value:     []byte(h.Entry.Value),
+		Version:   h.Entry.Version,
+		Tombstone: h.Entry.Tombstone,
+		Flags:     h.Entry.Flags,
+		ExpiresAt: h.Entry.ExpiresAt,
+		CreatedAt: h.CreatedAt,
+	}
+}
+
+func (r hintRecord) hint() Hint {
+	return Hint{
+		NodeID: r.NodeID,
+		Key:    string(r.Key),
+		Entry: core.VersionedEntry{
+			Value:     string(r.Value),
+			Version:   r.Version,
+			Tombstone: r.Tombstone,
+			Flags:     r.Flags,
+			ExpiresAt: r.ExpiresAt,
+		},
+		CreatedAt: r.CreatedAt,
+	}
+}
+
+// HintStats 提示移交统计
+type HintStats struct {
+	Enabled       bool           `json:"enabled"`
+	Pending       int            `json:"pending"`         // 待重放的提示数
+	PendingByNode map[string]int `json:"pending_by_node"` // 各目标节点待重放的提示数
+	Stored        uint64         `json:"stored"`          // 累计暂存的提示数
+	Replayed      uint64         `json:"replayed"`        // 累计重放成功的提示数
+	Dropped       uint64         `json:"dropped"`         // 存储已满被丢弃的提示数
+	Expired       uint64         `json:"expired"`         // 超过保留时间被清理的提示数
+	MaxHints      int            `json:"max_hints"`
+	TTL           string         `json:"ttl"`
+	Path          string         `json:"path,omitempty"` // 持久化文件，为空表示只保存在内存中
+}
+
+// hintStore 按目标节点和key保存提示
+type hintStore struct {
+	mu       sync.Mutex
+	hints    map[string]map[string]Hint // nodeID -> key -> hint
+	count    int
+	maxHints int
+	ttl      time.Duration
+
+	path    string   // 追加日志文件，为空表示不持久化
+	file    *os.File // 追加写入的日志文件
+	records int      // 日志文件中的记录数（包括已失效的记录）
+
+	stored, replayed, dropped, expired uint64
+}
+
+// newHintStore 创建提示存储，dir不为空时从 dir/hints-<nodeID>.log 恢复未重放的提示
+func newHintStore(nodeID, dir string, maxHints int, ttl time.Duration) (*hintStore, error) {
+	if maxHints <= 0 {
+		maxHints = DefaultMaxHints
+	}
+	if ttl <= 0 {
+		ttl = DefaultHintTTL
+	}
+	hs := &hintStore{
+		hints:    make(map[string]map[string]Hint),
+		maxHints: maxHints,
+		ttl:      ttl,
+	}
+	if dir == "" {
+		return hs, nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建提示目录失败: %v", err)
+	}
+	hs.path = filepath.Join(dir, fmt.Sprintf("hints-%s.log", nodeID))
+	if err := hs.load(); err != nil {
+		return nil, err
+	}
+	if err := hs.maybeCompact(); err != nil {
+		return nil, err
+	}
+	if hs.count > 0 {
+		log.Printf("📬 从 %s 恢复 %d 条待重放的提示", hs.path, hs.count)
+	}
+	return hs, nil
+}
+
+// load 读取日志，同一节点同一key保留版本最新的一条，跳过过期的提示
+func (hs *hintStore) load() error {
+	file, err := os.Open(hs.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("打开提示文件失败: %v", err)
+	}
+	defer file.Close()
+
+	now := time.Now()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 2*maxBinaryValueSize)
+	for scanner.Scan() {
+		hs.records++
+		var record hintRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			log.Printf("⚠️ 跳过损坏的提示记录: %v", err)
+			continue
+		}
+		hint := record.hint()
+		if record.Removed {
+			hs.drop(hint)
+			continue
+		}
+		if now.Sub(hint.CreatedAt) > hs.ttl {
+			continue
+		}
+		hs.put(hint)
+	}
+	return scanner.Err()
+}
+
+// put 保存提示，返回是否新增了一条（调用方持有锁）
+func (hs *hintStore) put(hint Hint) bool {
+	byKey, exists := hs.hints[hint.NodeID]
+	if !exists {
+		byKey = make(map[string]Hint)
+		hs.hints[hint.NodeID] = byKey
+	}
+	if current, exists := byKey[hint.Key]; exists {
+		if hint.Entry.NewerThan(current.Entry) {
+			byKey[hint.Key] = hint
+		}
+		return false
+	}
+	byKey[hint.Key] = hint
+	hs.count++
+	return true
+}
+
+// drop 删除已重放的提示，该key有更新的提示时保留，返回是否删除（调用方持有锁）
+func (hs *hintStore) drop(replayed Hint) bool {
+	current, exists := hs.hints[replayed.NodeID][replayed.Key]
+	if !exists || current.Entry.NewerThan(replayed.Entry) {
+		return false
+	}
+	delete(hs.hints[replayed.NodeID], replayed.Key)
+	if len(hs.hints[replayed.NodeID]) == 0 {
+		delete(hs.hints, replayed.NodeID)
+	}
+	hs.count--
+	return true
+}
+
+// add 为目标节点暂存一次写入，存储已满时返回false
+func (hs *hintStore) add(nodeID, key string, entry core.VersionedEntry) bool {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	_, exists := hs.hints[nodeID][key]
+	if !exists && hs.count >= hs.maxHints {
+		hs.purgeExpired()
+		if hs.count >= hs.maxHints {
+			hs.dropped++
+			return false
+		}
+	}
+
+	hint := Hint{NodeID: nodeID, Key: key, Entry: entry, CreatedAt: time.Now()}
+	hs.put(hint)
+	hs.stored++
+	if err := hs.appendLog(hint.record()); err != nil {
+		log.Printf("⚠️ 写入提示文件失败: %v", err)
+	}
+	return true
+}
+
+// lookup 获取目标节点某个key的提示
+func (hs *hintStore) lookup(nodeID, key string) (Hint, bool) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	hint, exists := hs.hints[nodeID][key]
+	if exists && time.Since(hint.CreatedAt) > hs.ttl {
+		return Hint{}, false
+	}
+	return hint, exists
+}
+
+// pending 获取目标节点未过期的提示，按创建时间排序
+func (hs *hintStore) pending(nodeID string) []Hint {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	hs.purgeExpired()
+	hints := make([]Hint, 0, len(hs.hints[nodeID]))
+	for _, hint := range hs.hints[nodeID] {
+		hints = append(hints, hint)
+	}
+	sort.Slice(hints, func(i, j int) bool { return hints[i].CreatedAt.Before(hints[j].CreatedAt) })
+	return hints
+}
+
+// remove 删除已重放的提示，重放期间该key有更新的写入时保留
+func (hs *hintStore) remove(replayed []Hint) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	removed := make([]hintRecord, 0, len(replayed))
+	for _, hint := range replayed {
+		if !hs.drop(hint) {
+			continue
+		}
+		hs.replayed++
+		record := hint.record()
+		record.Value = nil
+		record.Removed = true
+		removed = append(removed, record)
+	}
+	// 记录删除，避免重启后再次加载已重放的提示
+	if err := hs.appendLog(removed...); err != nil {
+		log.Printf("⚠️ 写入提示文件失败: %v", err)
+	}
+	if err := hs.maybeCompact(); err != nil {
+		log.Printf("⚠️ 压缩提示文件失败: %v", err)
+	}
+}
+
+// purgeExpired 清理超过保留时间的提示（调用方持有锁）
+func (hs *hintStore) purgeExpired() {
+	now := time.Now()
+	purged := 0
+	for nodeID, byKey := range hs.hints {
+		for key, hint := range byKey {
+			if now.Sub(hint.CreatedAt) > hs.ttl {
+				delete(byKey, key)
+				purged++
+			}
+		}
+		if len(byKey) == 0 {
+			delete(hs.hints, nodeID)
+		}
+	}
+	if purged == 0 {
+		return
+	}
+	hs.count -= purged
+	hs.expired += uint64(purged)
+	if err := hs.maybeCompact(); err != nil {
+		log.Printf("⚠️ 压缩提示文件失败: %v", err)
+	}
+}
+
+// appendLog 把记录追加到日志文件并 fsync（调用方持有锁）
+func (hs *hintStore) appendLog(records ...hintRecord) error {
+	if hs.path == "" || len(records) == 0 {
+		return nil
+	}
+	if hs.file == nil {
+		file, err := os.OpenFile(hs.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		hs.file = file
+	}
+
+	var buf []byte
+	for _, record := range records {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, data...), '\n')
+	}
+	if _, err := hs.file.Write(buf); err != nil {
+		return err
+	}
+	hs.records += len(records)
+	return hs.file.Sync()
+}
+
+// maybeCompact 失效记录超过日志一半时压缩（调用方持有锁）
+func (hs *hintStore) maybeCompact() error {
+	dead := hs.records - hs.count
+	if dead >= hintCompactMinDead && dead*2 >= hs.records {
+		return hs.compact()
+	}
+	return nil
+}
+
+// compact 用当前未重放的提示重写日志文件（调用方持有锁）
+func (hs *hintStore) compact() error {
+	if hs.path == "" {
+		return nil
+	}
+	if hs.file != nil {
+		hs.file.Close()
+		hs.file = nil
+	}
+
+	err := replaceFile(hs.path, func(writer *bufio.Writer) error {
+		encoder := json.NewEncoder(writer)
+		for _, byKey := range hs.hints {
+			for _, hint := range byKey {
+				if err := encoder.Encode(hint.record()); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	hs.records = hs.count
+	return nil
+}
+
+// close 关闭日志文件
+func (hs *hintStore) close() {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	if hs.file != nil {
+		hs.file.Close()
+		hs.file = nil
+	}
+}
+
+// stats 统计信息
+func (hs *hintStore) stats() HintStats {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	byNode := make(map[string]int, len(hs.hints))
+	for nodeID, byKey := range hs.hints {
+		byNode[nodeID] = len(byKey)
+	}
+	return HintStats{
+		Enabled:       true,
+		Pending:       hs.count,
+		PendingByNode: byNode,
+		Stored:        hs.stored,
+		Replayed:      hs.replayed,
+		Dropped:       hs.dropped,
+		Expired:       hs.expired,
+		MaxHints:      hs.maxHints,
+		TTL:           hs.ttl.String(),
+		Path:          hs.path,
+	}
+}
+
+// ===== DistributedNode 集成 =====
+
+// storeHint 目标节点不可达时为它暂存写入，未开启或存储已满时返回原始错误
+func (dn *DistributedNode) storeHint(nodeID, key string, entry core.VersionedEntry, cause error) error {
+	if dn.hints == nil || !isNodeUnavailable(cause) {
+		return cause
+	}
+	if !dn.hints.add(nodeID, key, entry) {
+		log.Printf("⚠️ 提示存储已满，无法为节点 %s 暂存写入: %v", nodeID, cause)
+		return cause
+	}
+	log.Printf("📬 节点 %s 不可达，已暂存写入提示: %q", nodeID, key)
+	return nil
+}
+
+// hintedValue 目标节点不可达时从提示中读取本节点代为接收的写入
+func (dn *DistributedNode) hintedValue(nodeID, key string) (value string, found, hinted bool) {
+	if dn.hints == nil {
+		return "", false, false
+	}
+	hint, exists := dn.hints.lookup(nodeID, key)
+	if !exists {
+		return "", false, false
+	}
+	return hint.Entry.Value, !hint.Entry.Tombstone, true
+}
+
+// ReplayHints 把暂存的提示重放到已恢复的节点，返回重放成功的数量
+// 重放中途失败时停止，剩余的提示等待下次恢复
+func (dn *DistributedNode) ReplayHints(nodeID string) (int, error) {
+	if dn.hints == nil {
+		return 0, nil
+	}
+	dn.replayMu.Lock()
+	defer dn.replayMu.Unlock()
+
+	hints := dn.hints.pending(nodeID)
+	if len(hints) == 0 {
+		return 0, nil
+	}
+
+	replayed := make([]Hint, 0, len(hints))
+	var replayErr error
+	for _, hint := range hints {
+		if err := dn.replicaWrite(nodeID, hint.Key, hint.Entry); err != nil {
+			replayErr = fmt.Errorf("重放提示到节点 %s 失败: %v", nodeID, err)
+			break
+		}
+		replayed = append(replayed, hint)
+	}
+	dn.hints.remove(replayed)
+
+	log.Printf("📮 向节点 %s 重放 %d/%d 条提示", nodeID, len(replayed), len(hints))
+	return len(replayed), replayErr
+}
+
+// GetHintStats 获取提示移交统计
+func (dn *DistributedNode) GetHintStats() HintStats {
+	if dn.hints == nil {
+		return HintStats{PendingByNode: map[string]int{}}
+	}
+	return dn.hints.stats()
+}
+
+// CloseHints 关闭提示日志文件
+func (dn *DistributedNode) CloseHints() {
+	if dn.hints != nil {
+		dn.hints.close()
+	}
+}
@@ -0,0 +1,412 @@
+package distributed
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// MemcachedServer memcached 文本协议监听器
+// 将 get/gets/set/add/replace/cas/delete/incr/decr/touch/stats 映射到 DistributedNode，
+// 非本地key由 DistributedNode 透明转发到负责节点，旧服务无需修改代码即可接入集群。
+type MemcachedServer struct {
+	node      *DistributedNode
+	listener  *tcpListener
+	startTime time.Time
+	stats     memcachedStats
+}
+
+// memcachedStats 协议层统计
+type memcachedStats struct {
+	cmdGet    int64
+	cmdSet    int64
+	cmdTouch  int64
+	getHits   int64
+	getMisses int64
+}
+
+const (
+	memcachedMaxKeyLength  = 250
+	memcachedMaxValueSize  = 1024 * 1024 // 与memcached默认的单条目上限一致
+	memcachedMaxLineLength = 64 * 1024
+	// exptime 超过30天时按Unix时间戳解释
+	memcachedRelativeExpireLimit = 60 * 60 * 24 * 30
+	memcachedVersion             = "1.6.0-tdd-learning"
+)
+
+// NewMemcachedServer 创建memcached协议服务器
+func NewMemcachedServer(node *DistributedNode, address string) *MemcachedServer {
+	ms := &MemcachedServer{node: node}
+	ms.listener = newTCPListener("memcached", address, ms.serveConn)
+	return ms
+}
+
+// Start 开始监听，连接在后台协程中处理
+func (ms *MemcachedServer) Start() error {
+	ms.startTime = time.Now()
+	return ms.listener.start()
+}
+
+// Addr 实际监听地址
+func (ms *MemcachedServer) Addr() string {
+	return ms.listener.addr()
+}
+
+// Stop 停止监听并关闭所有连接
+func (ms *MemcachedServer) Stop() {
+	ms.listener.stop()
+}
+
+// serveConn 处理单个连接上的命令（支持流水线）
+func (ms *MemcachedServer) serveConn(conn net.Conn) {
+	reader := bufio.NewReaderSize(conn, memcachedMaxLineLength)
+	writer := bufio.NewWriter(conn)
+
+	for {
+		line, err := reader.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			writer.WriteString("CLIENT_ERROR line too long\r\n")
+			writer.Flush()
+			return
+		}
+		if err != nil {
+			return
+		}
+
+		fields := strings.Fields(string(line))
+		if len(fields) == 0 {
+			writer.WriteString("ERROR\r\n")
+		} else if quit := ms.dispatch(fields, reader, writer); quit {
+			writer.Flush()
+			return
+		}
+
+		// 流水线中还有待处理命令时合并写出
+		if reader.Buffered() == 0 {
+			if err := writer.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// dispatch 分发命令，返回是否关闭连接
+func (ms *MemcachedServer) dispatch(fields []string, reader *bufio.Reader, writer *bufio.Writer) bool {
+	switch cmd := strings.ToLower(fields[0]); cmd {
+	case "get", "gets":
+		ms.handleGet(fields, writer, cmd == "gets")
+	case "set", "add", "replace", "cas":
+		return ms.handleStorage(cmd, fields, reader, writer)
+	case "delete":
+		ms.handleDelete(fields, writer)
+	case "incr", "decr":
+		ms.handleArithmetic(cmd, fields, writer)
+	case "touch":
+		ms.handleTouch(fields, writer)
+	case "stats":
+		ms.handleStats(writer)
+	case "version":
+		writer.WriteString("VERSION " + memcachedVersion + "\r\n")
+	case "verbosity":
+		if !isNoReply(fields, 2) {
+			writer.WriteString("OK\r\n")
+		}
+	case "quit":
+		return true
+	default:
+		writer.WriteString("ERROR\r\n")
+	}
+	return false
+}
+
+// handleGet get/gets <key>*
+// 多key读取时响应一旦开始输出VALUE就必须以END结束：读取失败的key按未命中跳过，否则客户端解析会错位
+func (ms *MemcachedServer) handleGet(fields []string, writer *bufio.Writer, withCAS bool) {
+	if len(fields) < 2 {
+		writer.WriteString("ERROR\r\n")
+		return
+	}
+	for _, key := range fields[1:] {
+		if !validMemcachedKey(key) {
+			writer.WriteString("CLIENT_ERROR bad command line format\r\n")
+			return
+		}
+	}
+
+	for _, key := range fields[1:] {
+		atomic.AddInt64(&ms.stats.cmdGet, 1)
+
+		result, err := ms.node.Execute(key, CacheOp{Op: OpGet})
+		if err != nil && len(fields) == 2 {
+			writer.WriteString("SERVER_ERROR " + err.Error() + "\r\n")
+			return
+		}
+		if err != nil || result.Status != OpStatusFound {
+			atomic.AddInt64(&ms.stats.getMisses, 1)
+			continue
+		}
+		atomic.AddInt64(&ms.stats.getHits, 1)
+
+		if withCAS {
+			fmt.Fprintf(writer, "VALUE %s %d %d %d\r\n", key, result.Flags, len(result.Value), result.CAS)
+		} else {
+			fmt.Fprintf(writer, "VALUE %s %d %d\r\n", key, result.Flags, len(result.Value))
+		}
+		writer.WriteString(result.Value)
+		writer.WriteString("\r\n")
+	}
+	writer.WriteString("END\r\n")
+}
+
+// handleStorage set/add/replace <key> <flags> <exptime> <bytes> [noreply]
+// cas <key> <flags> <exptime> <bytes> <cas unique> [noreply]
+func (ms *MemcachedServer) handleStorage(cmd string, fields []string, reader *bufio.Reader, writer *bufio.Writer) bool {
+	argCount := 5
+	if cmd == "cas" {
+		argCount = 6
+	}
+	if len(fields) < argCount || len(fields) > argCount+1 {
+		writer.WriteString("ERROR\r\n")
+		return false
+	}
+
+	key := fields[1]
+	flags, flagsErr := strconv.ParseUint(fields[2], 10, 32)
+	exptime, expErr := strconv.ParseInt(fields[3], 10, 64)
+	size, sizeErr := strconv.Atoi(fields[4])
+	if flagsErr != nil || expErr != nil || sizeErr != nil || size < 0 {
+		writer.WriteString("CLIENT_ERROR bad command line format\r\n")
+		// 无法确定数据块长度，关闭连接以免把数据当作命令解析
+		return true
+	}
+	var casUnique uint64
+	if cmd == "cas" {
+		var err error
+		if casUnique, err = strconv.ParseUint(fields[5], 10, 64); err != nil {
+			writer.WriteString("CLIENT_ERROR bad command line format\r\n")
+			return true
+		}
+	}
+	noReply := isNoReply(fields, argCount)
+
+	// 读取数据块（含结尾的\r\n）
+	if size > memcachedMaxValueSize {
+		io.CopyN(io.Discard, reader, int64(size)+2)
+		writer.WriteString("SERVER_ERROR object too large for cache\r\n")
+		return false
+	}
+	data := make([]byte, size+2)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return true
+	}
+	if string(data[size:]) != "\r\n" {
+		writer.WriteString("CLIENT_ERROR bad data chunk\r\n")
+		return false
+	}
+	if !validMemcachedKey(key) {
+		writer.WriteString("CLIENT_ERROR bad command line format\r\n")
+		return false
+	}
+	atomic.AddInt64(&ms.stats.cmdSet, 1)
+
+	ttl, expired := memcachedTTL(exptime)
+	op := CacheOp{
+		Op:        cmd,
+		Value:     string(data[:size]),
+		Flags:     uint32(flags),
+		TTLMillis: ttl.Milliseconds(),
+		Expired:   expired,
+		CAS:       casUnique,
+	}
+	// 过期时间已过：与memcached一致，写入成功但立即不可见
+	// set 直接按删除执行；add/replace/cas 在检查条件的同一次操作中删除key
+	var result CacheOpResult
+	var err error
+	if expired && cmd == OpSet {
+		_, err = ms.node.Execute(key, CacheOp{Op: OpDelete})
+		result.Status = OpStatusStored
+	} else {
+		result, err = ms.node.Execute(key, op)
+	}
+
+	if noReply {
+		return false
+	}
+	if err != nil {
+		writer.WriteString("SERVER_ERROR " + err.Error() + "\r\n")
+		return false
+	}
+	switch result.Status {
+	case OpStatusStored:
+		writer.WriteString("STORED\r\n")
+	case OpStatusExists:
+		writer.WriteString("EXISTS\r\n")
+	case OpStatusNotFound:
+		writer.WriteString("NOT_FOUND\r\n")
+	default:
+		writer.WriteString("NOT_STORED\r\n")
+	}
+	return false
+}
+
+// handleDelete delete <key> [noreply]
+func (ms *MemcachedServer) handleDelete(fields []string, writer *bufio.Writer) {
+	if len(fields) < 2 || len(fields) > 3 || !validMemcachedKey(fields[1]) {
+		writer.WriteString("CLIENT_ERROR bad command line format\r\n")
+		return
+	}
+
+	result, err := ms.node.Execute(fields[1], CacheOp{Op: OpDelete})
+	if isNoReply(fields, 2) {
+		return
+	}
+	switch {
+	case err != nil:
+		writer.WriteString("SERVER_ERROR " + err.Error() + "\r\n")
+	case result.Status == OpStatusDeleted:
+		writer.WriteString("DELETED\r\n")
+	default:
+		writer.WriteString("NOT_FOUND\r\n")
+	}
+}
+
+// handleArithmetic incr/decr <key> <value> [noreply]
+func (ms *MemcachedServer) handleArithmetic(cmd string, fields []string, writer *bufio.Writer) {
+	if len(fields) < 3 || len(fields) > 4 || !validMemcachedKey(fields[1]) {
+		writer.WriteString("ERROR\r\n")
+		return
+	}
+	delta, err := strconv.ParseUint(fields[2], 10, 64)
+	if err != nil {
+		writer.WriteString("CLIENT_ERROR invalid numeric delta argument\r\n")
+		return
+	}
+
+	result, err := ms.node.Execute(fields[1], CacheOp{Op: cmd, Delta: delta})
+	if isNoReply(fields, 3) {
+		return
+	}
+	switch {
+	case err != nil:
+		writer.WriteString("SERVER_ERROR " + err.Error() + "\r\n")
+	case result.Status == OpStatusStored:
+		writer.WriteString(result.Value + "\r\n")
+	case result.Status == OpStatusInvalid:
+		writer.WriteString("CLIENT_ERROR cannot increment or decrement non-numeric value\r\n")
+	default:
+		writer.WriteString("NOT_FOUND\r\n")
+	}
+}
+
+// handleTouch touch <key> <exptime> [noreply]
+func (ms *MemcachedServer) handleTouch(fields []string, writer *bufio.Writer) {
+	if len(fields) < 3 || len(fields) > 4 || !validMemcachedKey(fields[1]) {
+		writer.WriteString("ERROR\r\n")
+		return
+	}
+	exptime, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		writer.WriteString("CLIENT_ERROR invalid exptime argument\r\n")
+		return
+	}
+	atomic.AddInt64(&ms.stats.cmdTouch, 1)
+
+	var result CacheOpResult
+	ttl, expired := memcachedTTL(exptime)
+	if expired {
+		// 过期时间已过，等同于删除
+		result, err = ms.node.Execute(fields[1], CacheOp{Op: OpDelete})
+		if result.Status == OpStatusDeleted {
+			result.Status = OpStatusTouched
+		}
+	} else {
+		result, err = ms.node.Execute(fields[1], CacheOp{Op: OpTouch, TTLMillis: ttl.Milliseconds()})
+	}
+
+	if isNoReply(fields, 3) {
+		return
+	}
+	switch {
+	case err != nil:
+		writer.WriteString("SERVER_ERROR " + err.Error() + "\r\n")
+	case result.Status == OpStatusTouched:
+		writer.WriteString("TOUCHED\r\n")
+	default:
+		writer.WriteString("NOT_FOUND\r\n")
+	}
+}
+
+// handleStats stats
+func (ms *MemcachedServer) handleStats(writer *bufio.Writer) {
+	now := time.Now()
+	config := ms.node.GetCacheConfig()
+	cacheStats := ms.node.localCache.GetStats()
+
+	stat := func(name string, value interface{}) {
+		fmt.Fprintf(writer, "STAT %s %v\r\n", name, value)
+	}
+	stat("pid", os.Getpid())
+	stat("uptime", int64(now.Sub(ms.startTime).Seconds()))
+	stat("time", now.Unix())
+	stat("version", memcachedVersion)
+	stat("node_id", ms.node.GetNodeID())
+	currConnections, totalConnections := ms.listener.connectionStats()
+	stat("curr_connections", currConnections)
+	stat("total_connections", totalConnections)
+	stat("cmd_get", atomic.LoadInt64(&ms.stats.cmdGet))
+	stat("cmd_set", atomic.LoadInt64(&ms.stats.cmdSet))
+	stat("cmd_touch", atomic.LoadInt64(&ms.stats.cmdTouch))
+	stat("get_hits", atomic.LoadInt64(&ms.stats.getHits))
+	stat("get_misses", atomic.LoadInt64(&ms.stats.getMisses))
+	stat("local_hits", cacheStats.Hits)
+	stat("local_misses", cacheStats.Misses)
+	stat("curr_items", config.Size)
+	stat("bytes", config.MemoryUsage)
+	stat("limit_maxbytes", config.MemoryLimit)
+	stat("limit_maxitems", config.CacheSize)
+	writer.WriteString("END\r\n")
+}
+
+// ===== 辅助函数 =====
+
+// memcachedTTL 将exptime转换为过期时间，第二个返回值表示已经过期
+func memcachedTTL(exptime int64) (time.Duration, bool) {
+	switch {
+	case exptime == 0:
+		return 0, false
+	case exptime < 0:
+		return 0, true
+	case exptime > memcachedRelativeExpireLimit:
+		ttl := time.Until(time.Unix(exptime, 0))
+		if ttl <= 0 {
+			return 0, true
+		}
+		return ttl, false
+	default:
+		return time.Duration(exptime) * time.Second, false
+	}
+}
+
+// validMemcachedKey key长度不超过250且不含控制字符
+func validMemcachedKey(key string) bool {
+	if len(key) == 0 || len(key) > memcachedMaxKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] <= ' ' || key[i] == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// isNoReply 判断位置index上的参数是否为noreply
+func isNoReply(fields []string, index int) bool {
+	return len(fields) > index && fields[index] == "noreply"
+}
@@ -0,0 +1,74 @@
+package distributed
+
+import (
+	"gopkg.in/yaml.v3"
+
+	"tdd-learning/core"
+)
+
+// ClusterNodeSpec cluster_nodes 中的单个节点
+// 可以直接写地址字符串，也可以写成带权重和位置的对象：
+//
+//	cluster_nodes:
+//	  node1: "localhost:8001"
+//	  node2: {address: "localhost:8002", weight: 4, zone: "zone-b", rack: "r1"}
+type ClusterNodeSpec struct {
+	Address string  `yaml:"address"`
+	Weight  float64 `yaml:"weight"` // 相对权重，虚拟节点数 = virtual_nodes × weight，默认1
+	Zone    string  `yaml:"zone"`   // 可用区，副本尽量分布在不同可用区
+	Rack    string  `yaml:"rack"`   // 机架，可用区不足时副本尽量分布在不同机架
+}
+
+// UnmarshalYAML 兼容字符串和对象两种写法
+func (s *ClusterNodeSpec) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		return value.Decode(&s.Address)
+	}
+	type plain ClusterNodeSpec
+	return value.Decode((*plain)(s))
+}
+
+// UnmarshalYAML 解析节点配置，cluster_nodes 拆分为地址、权重和位置
+func (c *NodeConfig) UnmarshalYAML(value *yaml.Node) error {
+	type plain NodeConfig
+	if value.Kind != yaml.MappingNode {
+		return value.Decode((*plain)(c))
+	}
+
+	// cluster_nodes 单独解析，其余字段按默认规则解析
+	rest := *value
+	rest.Content = nil
+	var specs map[string]ClusterNodeSpec
+	for i := 0; i+1 < len(value.Content); i += 2 {
+		if value.Content[i].Value == "cluster_nodes" {
+			if err := value.Content[i+1].Decode(&specs); err != nil {
+				return err
+			}
+			continue
+		}
+		rest.Content = append(rest.Content, value.Content[i], value.Content[i+1])
+	}
+	if err := rest.Decode((*plain)(c)); err != nil {
+		return err
+	}
+
+	if specs != nil {
+		c.ClusterNodes = make(map[string]string, len(specs))
+		for nodeID, spec := range specs {
+			c.ClusterNodes[nodeID] = spec.Address
+			if spec.Weight != 0 {
+				if c.NodeWeights == nil {
+					c.NodeWeights = make(map[string]float64)
+				}
+				c.NodeWeights[nodeID] = spec.Weight
+			}
+			if spec.Zone != "" || spec.Rack != "" {
+				if c.NodeLocations == nil {
+					c.NodeLocations = make(map[string]core.NodeLocation)
+				}
+				c.NodeLocations[nodeID] = core.NodeLocation{Zone: spec.Zone, Rack: spec.Rack}
+			}
+		}
+	}
+	return nil
+}
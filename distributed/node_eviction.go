@@ -0,0 +1,362 @@
+package distributed
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"tdd-learning/core"
+)
+
+// 节点驱逐与重新接纳
+//
+// 不健康的节点只影响请求的成败，不改变数据归属；节点长时间不恢复时，它负责的区间一直不可用。
+// 驱逐器在节点不健康超过宽限期后征求其他节点的意见，多数派同意才把它移出哈希环，区间交给其余节点。
+// 被驱逐的节点恢复响应后，先清空它的旧数据（驱逐期间这些key可能已被改写或删除），
+// 再按当前拓扑重新加入哈希环，由各节点迁移它负责的数据。
+// 驱逐和重新接纳都只由领导者（健康节点中ID最小的）发起，每一步都记录为集群事件。
+
+// EvictedNode 被驱逐的节点，重新接纳时恢复它的权重和位置
+type EvictedNode struct {
+	NodeID    string    `json:"node_id"`
+	Address   string    `json:"address"`
+	Weight    float64   `json:"weight,omitempty"`
+	Zone      string    `json:"zone,omitempty"`
+	Rack      string    `json:"rack,omitempty"`
+	EvictedAt time.Time `json:"evicted_at"`
+}
+
+// EvictionVote 驱逐投票结果
+type EvictionVote struct {
+	NodeID string `json:"node_id"`
+	Agree  bool   `json:"agree"`
+	Status string `json:"status"` // 投票节点看到的目标节点状态
+}
+
+// ReadmitRequest 重新接纳请求，携带领导者当前的拓扑
+type ReadmitRequest struct {
+	NodeID    string                       `json:"node_id"`
+	Nodes     map[string]string            `json:"nodes"`
+	Weights   map[string]float64           `json:"weights,omitempty"`
+	Locations map[string]core.NodeLocation `json:"locations,omitempty"`
+}
+
+// NodeEvictor 节点驱逐器
+type NodeEvictor struct {
+	node        *DistributedNode
+	cluster     *ClusterManager
+	coordinator *ClusterCoordinator
+	grace       time.Duration
+	httpClient  *http.Client
+
+	mu      sync.Mutex
+	evicted map[string]EvictedNode
+
+	runMu    sync.Mutex // 串行执行检查，避免同一节点被重复驱逐或接纳
+	stopChan chan struct{}
+	stopOnce sync.Once
+}
+
+// NewNodeEvictor 创建节点驱逐器，grace 为节点不健康多久后驱逐，0表示不驱逐
+func NewNodeEvictor(node *DistributedNode, cluster *ClusterManager, coordinator *ClusterCoordinator, grace time.Duration) *NodeEvictor {
+	return &NodeEvictor{
+		node:        node,
+		cluster:     cluster,
+		coordinator: coordinator,
+		grace:       grace,
+		httpClient:  &http.Client{Timeout: 3 * time.Second},
+		evicted:     make(map[string]EvictedNode),
+		stopChan:    make(chan struct{}),
+	}
+}
+
+// Start 按间隔定期检查，宽限期为0时不启动
+func (e *NodeEvictor) Start(interval time.Duration) {
+	if e.grace <= 0 {
+		return
+	}
+	log.Printf("🪓 启动节点驱逐检查，宽限期 %v，检查间隔 %v", e.grace, interval)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				e.Check()
+			case <-e.stopChan:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止定期检查
+func (e *NodeEvictor) Stop() {
+	e.stopOnce.Do(func() { close(e.stopChan) })
+}
+
+// Check 执行一次检查：领导者驱逐超过宽限期的不健康节点，并重新接纳已恢复的被驱逐节点
+func (e *NodeEvictor) Check() {
+	e.runMu.Lock()
+	defer e.runMu.Unlock()
+
+	if e.grace <= 0 || !e.isLeader() {
+		return
+	}
+	e.evictDead()
+	e.readmitReturned()
+}
+
+// Evicted 获取被驱逐且尚未重新接纳的节点
+func (e *NodeEvictor) Evicted() []EvictedNode {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	nodes := make([]EvictedNode, 0, len(e.evicted))
+	for _, node := range e.evicted {
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].NodeID < nodes[j].NodeID })
+	return nodes
+}
+
+// Vote 对驱逐提议投票：本节点也认为目标节点不健康（或已不在集群中）时同意
+func (e *NodeEvictor) Vote(nodeID string) EvictionVote {
+	vote := EvictionVote{NodeID: e.node.GetNodeID(), Status: "absent", Agree: true}
+	if node, exists := e.cluster.GetNodes()[nodeID]; exists {
+		vote.Status = node.Status
+		vote.Agree = node.Status == "unhealthy"
+	}
+	return vote
+}
+
+// Readmit 被驱逐的节点重新加入前清空旧数据，并把哈希环对齐到领导者的拓扑
+func (e *NodeEvictor) Readmit(request ReadmitRequest) error {
+	selfID := e.node.GetNodeID()
+	removed := e.node.ResetLocalData()
+	e.cluster.RecordEvent(EventDataReset, selfID, "重新加入前清空 %d 个旧key", removed)
+
+	local := e.node.GetClusterNodes()
+	for nodeID, address := range request.Nodes {
+		if _, exists := local[nodeID]; exists || nodeID == selfID {
+			continue
+		}
+		location := request.Locations[nodeID]
+		change := NodeChangeRequest{
+			NodeID:  nodeID,
+			Address: address,
+			Weight:  request.Weights[nodeID],
+			Zone:    location.Zone,
+			Rack:    location.Rack,
+		}
+		if err := e.coordinator.SyncAddNode(change); err != nil {
+			return fmt.Errorf("同步添加节点 %s 失败: %v", nodeID, err)
+		}
+	}
+	for nodeID := range local {
+		if _, exists := request.Nodes[nodeID]; exists || nodeID == selfID {
+			continue
+		}
+		if err := e.coordinator.SyncRemoveNode(nodeID); err != nil {
+			return fmt.Errorf("同步移除节点 %s 失败: %v", nodeID, err)
+		}
+	}
+
+	e.forget(selfID)
+	return nil
+}
+
+// isLeader 本节点是否为健康节点中ID最小的
+func (e *NodeEvictor) isLeader() bool {
+	selfID := e.node.GetNodeID()
+	for nodeID := range e.cluster.GetHealthyNodes() {
+		if nodeID < selfID {
+			return false
+		}
+	}
+	return true
+}
+
+// evictDead 驱逐不健康超过宽限期的节点
+func (e *NodeEvictor) evictDead() {
+	selfID := e.node.GetNodeID()
+	ring := e.node.GetClusterNodes()
+	nodes := e.cluster.GetNodes()
+
+	for nodeID, info := range nodes {
+		if _, inRing := ring[nodeID]; !inRing || nodeID == selfID || info.Status != "unhealthy" {
+			continue
+		}
+		down := time.Since(info.LastSeen)
+		if down < e.grace {
+			continue
+		}
+
+		e.cluster.RecordEvent(EventEvictionProposed, nodeID, "节点已 %v 无响应，超过宽限期 %v", down.Round(time.Millisecond), e.grace)
+
+		// 包括目标节点在内的多数派同意才驱逐，少数派分区中的领导者无法驱逐其他节点
+		required := len(ring)/2 + 1
+		agreed := []string{selfID}
+		for peerID, peer := range nodes {
+			if peerID == selfID || peerID == nodeID || peer.Status != "healthy" {
+				continue
+			}
+			vote, err := e.requestVote(peer.Address, nodeID)
+			if err != nil {
+				log.Printf("⚠️ 向节点 %s 征求驱逐意见失败: %v", peerID, err)
+				continue
+			}
+			if vote.Agree {
+				agreed = append(agreed, peerID)
+			}
+		}
+		sort.Strings(agreed)
+		if len(agreed) < required {
+			e.cluster.RecordEvent(EventEvictionRejected, nodeID, "同意驱逐的节点 %v 不足 %d 个", agreed, required)
+			continue
+		}
+
+		location := e.node.GetNodeLocations()[nodeID]
+		request := NodeChangeRequest{
+			NodeID:  nodeID,
+			Address: info.Address,
+			Weight:  e.node.GetNodeWeights()[nodeID],
+			Zone:    location.Zone,
+			Rack:    location.Rack,
+		}
+		e.remember(request)
+		if err := e.coordinator.EvictNode(request); err != nil {
+			e.forget(nodeID)
+			e.cluster.RecordEvent(EventEvictionFailed, nodeID, "移出哈希环失败: %v", err)
+			continue
+		}
+		e.cluster.RecordEvent(EventNodeEvicted, nodeID, "节点 %v 同意驱逐，已移出哈希环", agreed)
+	}
+}
+
+// readmitReturned 重新接纳已恢复响应的被驱逐节点
+func (e *NodeEvictor) readmitReturned() {
+	for _, evicted := range e.Evicted() {
+		if !e.reachable(evicted.Address) {
+			continue
+		}
+		nodeID := evicted.NodeID
+		e.cluster.RecordEvent(EventNodeReturned, nodeID, "被驱逐的节点恢复响应 (%s)", evicted.Address)
+
+		if err := e.sendReadmit(evicted); err != nil {
+			e.cluster.RecordEvent(EventReadmissionFailed, nodeID, "%v", err)
+			continue
+		}
+
+		request := NodeChangeRequest{
+			NodeID:  nodeID,
+			Address: evicted.Address,
+			Weight:  evicted.Weight,
+			Zone:    evicted.Zone,
+			Rack:    evicted.Rack,
+		}
+		if err := e.coordinator.AddNodeToCluster(request); err != nil {
+			e.cluster.RecordEvent(EventReadmissionFailed, nodeID, "加入哈希环失败: %v", err)
+			continue
+		}
+		e.cluster.ObserveMember(nodeID, evicted.Address, "healthy")
+		e.forget(nodeID)
+		e.cluster.RecordEvent(EventNodeReadmitted, nodeID, "节点已重新加入哈希环并同步数据")
+	}
+}
+
+// requestVote 向对端征求驱逐意见
+func (e *NodeEvictor) requestVote(address, nodeID string) (EvictionVote, error) {
+	var vote EvictionVote
+	jsonData, err := json.Marshal(map[string]string{"node_id": nodeID})
+	if err != nil {
+		return vote, fmt.Errorf("序列化请求失败: %v", err)
+	}
+
+	url := fmt.Sprintf("http://%s/internal/cluster/eviction-vote", address)
+	resp, err := e.httpClient.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return vote, fmt.Errorf("发送请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return vote, fmt.Errorf("目标节点返回错误状态: %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&vote); err != nil {
+		return vote, fmt.Errorf("解析响应失败: %v", err)
+	}
+	return vote, nil
+}
+
+// sendReadmit 通知被驱逐的节点清空旧数据并对齐拓扑
+func (e *NodeEvictor) sendReadmit(evicted EvictedNode) error {
+	nodes := e.node.GetClusterNodes()
+	nodes[evicted.NodeID] = evicted.Address
+	request := ReadmitRequest{
+		NodeID:    evicted.NodeID,
+		Nodes:     nodes,
+		Weights:   e.node.GetNodeWeights(),
+		Locations: e.node.GetNodeLocations(),
+	}
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("序列化请求失败: %v", err)
+	}
+
+	url := fmt.Sprintf("http://%s/internal/cluster/readmit", evicted.Address)
+	resp, err := e.httpClient.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("发送重新接纳请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("节点 %s 拒绝重新接纳: %d", evicted.NodeID, resp.StatusCode)
+	}
+	return nil
+}
+
+// reachable 节点的健康检查接口是否可访问
+func (e *NodeEvictor) reachable(address string) bool {
+	resp, err := e.httpClient.Get(fmt.Sprintf("http://%s/internal/cluster/health", address))
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+// remember 记录被驱逐的节点
+func (e *NodeEvictor) remember(request NodeChangeRequest) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.evicted[request.NodeID] = EvictedNode{
+		NodeID:    request.NodeID,
+		Address:   request.Address,
+		Weight:    request.Weight,
+		Zone:      request.Zone,
+		Rack:      request.Rack,
+		EvictedAt: time.Now(),
+	}
+}
+
+// forget 节点已重新加入，不再视为被驱逐
+func (e *NodeEvictor) forget(nodeID string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.evicted, nodeID)
+}
+
+// isEvicted 节点是否被驱逐且尚未重新接纳
+func (e *NodeEvictor) isEvicted(nodeID string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, evicted := e.evicted[nodeID]
+	return evicted
+}
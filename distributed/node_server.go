@@ -21,6 +21,12 @@ type NodeServer struct {
 	handlers    *APIHandlers
 	router      *gin.Engine
 	server      *http.Server
+	memcached   *MemcachedServer
+	resp        *RESPServer
+	rpc         *RPCServer
+	gossip      *Gossiper
+	evictor     *NodeEvictor
+	raft        *Raft
 }
 
 
@@ -32,15 +38,53 @@ func NewNodeServer(config NodeConfig) *NodeServer {
 
 	// 创建集群管理器
 	cluster := NewClusterManager(config.NodeID, config.ClusterNodes)
+	cluster.SetRingConfig(node.GetHashFunction(), node.GetPlacement())
+	cluster.SetWeight(config.NodeWeights[config.NodeID])
+	cluster.SetLocation(config.NodeLocations[config.NodeID])
+	cluster.SetFailureDetector(config.PhiThreshold, config.HealthCheckInterval)
+	if _, enabled := node.LocalLoad(); enabled {
+		cluster.SetLoadReporter(node.LocalLoad, node.ReportNodeLoad)
+	}
+	cluster.SetRingReporter(func() (uint64, string) {
+		return node.GetRingEpoch(), node.GetRingChecksum()
+	}, node.ObserveRingEpoch)
+	cluster.SetRecoveryHandler(func(nodeID string) {
+		if _, err := node.ReplayHints(nodeID); err != nil {
+			log.Printf("⚠️ %v", err)
+		}
+	})
 
 	// 创建API处理器
 	handlers := NewAPIHandlers(node, cluster)
+	evictor := NewNodeEvictor(node, cluster, handlers.coordinator, config.EvictionGracePeriod)
+	handlers.evictor = evictor
 
 	// 创建节点服务器
 	server := &NodeServer{
 		node:     node,
 		cluster:  cluster,
 		handlers: handlers,
+		evictor:  evictor,
+	}
+
+	if config.MemcachedAddress != "" {
+		server.memcached = NewMemcachedServer(node, config.MemcachedAddress)
+	}
+	if config.RPCAddress != "" {
+		server.rpc = NewRPCServer(node, config.RPCAddress)
+	}
+	if config.RESPAddress != "" {
+		server.resp = NewRESPServer(node, config.RESPAddress, config.RESPMode, config.RESPPeers)
+	}
+	if config.Raft {
+		if err := server.setupRaft(config); err != nil {
+			log.Printf("❌ %v，拓扑变更继续使用广播", err)
+		}
+	}
+	if config.Gossip {
+		if err := server.setupGossip(config); err != nil {
+			log.Printf("❌ %v，继续使用健康检查轮询", err)
+		}
 	}
 
 	// 设置路由
@@ -49,6 +93,132 @@ func NewNodeServer(config NodeConfig) *NodeServer {
 	return server
 }
 
+// setupRaft 创建Raft节点，拓扑变更和集群级配置改为写入Raft日志
+func (ns *NodeServer) setupRaft(config NodeConfig) error {
+	metadata := newClusterMetadata(ns.node, ns.cluster, ns.handlers.coordinator, ns.evictor)
+
+	// 加入模式以空的投票成员集合启动，宣告加入后由现有领导者通过成员变更条目加入，
+	// 避免 cluster_nodes 不完整的节点自己选举出另一个领导者
+	peers := config.ClusterNodes
+	if config.RaftMode == RaftModeJoin {
+		peers = nil
+	}
+	raft, err := NewRaft(RaftConfig{
+		NodeID:            config.NodeID,
+		Address:           ns.node.GetNodeAddress(),
+		Peers:             peers,
+		ElectionTimeout:   config.RaftElectionTimeout,
+		HeartbeatInterval: config.RaftHeartbeatInterval,
+		Dir:               config.RaftDir,
+		SnapshotThreshold: config.RaftSnapshotThreshold,
+	}, metadata, newHTTPRaftTransport(DefaultRaftCommitTimeout))
+	if err != nil {
+		return fmt.Errorf("创建Raft节点失败: %v", err)
+	}
+
+	metadata.raft = raft
+	ns.raft = raft
+	ns.handlers.raft = raft
+	ns.handlers.metadata = metadata
+	ns.handlers.coordinator.metadata = metadata
+	return nil
+}
+
+// setupGossip 创建gossip成员管理，成员变化时更新集群管理器和哈希环
+func (ns *NodeServer) setupGossip(config NodeConfig) error {
+	seeds := config.Seeds
+	if len(seeds) == 0 && config.GossipTransport != GossipTransportUDP {
+		for nodeID, address := range config.ClusterNodes {
+			if nodeID != config.NodeID {
+				seeds = append(seeds, address)
+			}
+		}
+	}
+
+	location := config.NodeLocations[config.NodeID]
+	gossip, err := NewGossiper(GossipConfig{
+		Transport:        config.GossipTransport,
+		BindAddress:      config.GossipAddress,
+		Seeds:            seeds,
+		ProbeInterval:    config.GossipInterval,
+		ProbeTimeout:     config.GossipProbeTimeout,
+		IndirectProbes:   config.GossipIndirectProbes,
+		SuspicionTimeout: config.GossipSuspicionTimeout,
+		SyncInterval:     config.GossipSyncInterval,
+	}, Member{
+		NodeID:  config.NodeID,
+		Address: ns.node.GetNodeAddress(),
+		Weight:  config.NodeWeights[config.NodeID],
+		Zone:    location.Zone,
+		Rack:    location.Rack,
+	})
+	if err != nil {
+		return err
+	}
+
+	gossip.SetRingConfig(ns.node.GetHashFunction(), ns.node.GetPlacement())
+	gossip.SetEventHandler(ns.handleMemberEvent)
+	gossip.SetHealthReporter(func() nodeHealth {
+		health := nodeHealth{Epoch: ns.node.GetRingEpoch(), RingChecksum: ns.node.GetRingChecksum()}
+		if load, enabled := ns.node.LocalLoad(); enabled {
+			health.Load = &load
+		}
+		return health
+	}, ns.cluster.observeHealth)
+
+	ns.gossip = gossip
+	ns.handlers.gossip = gossip
+	ns.cluster.SetGossipMembership(true)
+	return nil
+}
+
+// handleMemberEvent 处理gossip成员变化：新成员加入哈希环并迁移数据，主动离开的成员移出哈希环，
+// 可疑和下线只更新集群管理器中的状态，不改变数据归属
+func (ns *NodeServer) handleMemberEvent(event MemberEvent) {
+	member := event.Member
+	_, known := ns.node.GetClusterNodes()[member.NodeID]
+
+	switch member.State {
+	case MemberAlive:
+		if !known && ns.evictor.isEvicted(member.NodeID) {
+			return // 被驱逐的节点由驱逐器清空旧数据后重新接纳
+		}
+		if !known {
+			request := NodeChangeRequest{
+				NodeID:  member.NodeID,
+				Address: member.Address,
+				Weight:  member.Weight,
+				Zone:    member.Zone,
+				Rack:    member.Rack,
+			}
+			// 开启Raft时写入日志，所有节点按相同顺序加入
+			add := ns.handlers.coordinator.SyncAddNode
+			if ns.raft != nil {
+				add = ns.handlers.coordinator.AddNodeToCluster
+			}
+			if err := add(request); err != nil {
+				log.Printf("⚠️ 添加gossip成员 %s 失败: %v", member.NodeID, err)
+				return
+			}
+		}
+		ns.cluster.ObserveMember(member.NodeID, member.Address, "healthy")
+	case MemberSuspect:
+		ns.cluster.ObserveMember(member.NodeID, member.Address, "suspect")
+	case MemberDead:
+		ns.cluster.ObserveMember(member.NodeID, member.Address, "unhealthy")
+	case MemberLeft:
+		if known {
+			remove := ns.handlers.coordinator.SyncRemoveNode
+			if ns.raft != nil {
+				remove = ns.handlers.coordinator.RemoveNodeFromCluster
+			}
+			if err := remove(member.NodeID); err != nil {
+				log.Printf("⚠️ 移除离开的成员 %s 失败: %v", member.NodeID, err)
+			}
+		}
+	}
+}
+
 // setupRoutes 设置HTTP路由
 func (ns *NodeServer) setupRoutes() {
 	// 设置Gin模式
@@ -66,6 +236,9 @@ func (ns *NodeServer) setupRoutes() {
 		clientAPI.GET("/cache/:key", ns.handlers.HandleGet)
 		clientAPI.PUT("/cache/:key", ns.handlers.HandleSet)
 		clientAPI.DELETE("/cache/:key", ns.handlers.HandleDelete)
+		clientAPI.GET("/bin/:key64", ns.handlers.HandleBinaryGet)
+		clientAPI.PUT("/bin/:key64", ns.handlers.HandleBinarySet)
+		clientAPI.DELETE("/bin/:key64", ns.handlers.HandleBinaryDelete)
 		clientAPI.GET("/stats", ns.handlers.HandleGetStats)
 		clientAPI.GET("/health", ns.handlers.HandleHealthCheck)
 	}
@@ -76,11 +249,31 @@ func (ns *NodeServer) setupRoutes() {
 		internalAPI.GET("/cache/:key", ns.handlers.HandleInternalGet)
 		internalAPI.PUT("/cache/:key", ns.handlers.HandleInternalSet)
 		internalAPI.DELETE("/cache/:key", ns.handlers.HandleInternalDelete)
+		internalAPI.GET("/bin/:key64", ns.handlers.HandleInternalBinaryGet)
+		internalAPI.PUT("/bin/:key64", ns.handlers.HandleInternalBinarySet)
+		internalAPI.DELETE("/bin/:key64", ns.handlers.HandleInternalBinaryDelete)
+		internalAPI.POST("/op/:key64", ns.handlers.HandleInternalOp)
+		internalAPI.GET("/replica/:key64", ns.handlers.HandleReplicaGet)
+		internalAPI.PUT("/replica/:key64", ns.handlers.HandleReplicaSet)
+		internalAPI.DELETE("/replica/:key64", ns.handlers.HandleReplicaSet)
+		internalAPI.GET("/anti-entropy/tree", ns.handlers.HandleMerkleTree)
+		internalAPI.POST("/anti-entropy/digests", ns.handlers.HandleReplicaDigests)
+		internalAPI.GET("/rpc/info", ns.handlers.HandleRPCInfo)
 		internalAPI.POST("/cluster/join", ns.handlers.HandleNodeJoin)
 		internalAPI.POST("/cluster/leave", ns.handlers.HandleNodeLeave)
 		internalAPI.POST("/cluster/sync-add", ns.handlers.HandleSyncAddNode)
 		internalAPI.POST("/cluster/sync-remove", ns.handlers.HandleSyncRemoveNode)
+		internalAPI.POST("/cluster/sync-weight", ns.handlers.HandleSyncNodeWeight)
+		internalAPI.POST("/cluster/eviction-vote", ns.handlers.HandleEvictionVote)
+		internalAPI.POST("/cluster/readmit", ns.handlers.HandleReadmit)
 		internalAPI.GET("/cluster/health", ns.handlers.HandleClusterHealth)
+		internalAPI.POST("/gossip", ns.handlers.HandleGossip)
+		internalAPI.POST("/raft/vote", ns.handlers.HandleRaftVote)
+		internalAPI.POST("/raft/append", ns.handlers.HandleRaftAppend)
+		internalAPI.POST("/raft/propose", ns.handlers.HandleRaftPropose)
+		internalAPI.POST("/raft/snapshot", ns.handlers.HandleRaftSnapshot)
+		internalAPI.GET("/key/:key64", ns.handlers.HandleInternalInspectKey)
+		internalAPI.GET("/large-keys", ns.handlers.HandleInternalLargeKeys)
 	}
 	
 	// 管理API - 集群管理
@@ -88,8 +281,18 @@ func (ns *NodeServer) setupRoutes() {
 	{
 		adminAPI.GET("/cluster", ns.handlers.HandleGetCluster)
 		adminAPI.GET("/nodes", ns.handlers.HandleGetNodes)
+		adminAPI.GET("/members", ns.handlers.HandleGetMembers)
+		adminAPI.GET("/events", ns.handlers.HandleGetEvents)
+		adminAPI.GET("/raft", ns.handlers.HandleGetRaft)
+		adminAPI.PUT("/nodes/:node_id/weight", ns.handlers.HandleSetNodeWeight)
 		adminAPI.POST("/cluster/rebalance", ns.handlers.HandleRebalance)
 		adminAPI.GET("/metrics", ns.handlers.HandleGetMetrics)
+		adminAPI.POST("/anti-entropy", ns.handlers.HandleAntiEntropy)
+		adminAPI.GET("/config", ns.handlers.HandleGetConfig)
+		adminAPI.PUT("/config", ns.handlers.HandleUpdateConfig)
+		adminAPI.GET("/key/:key", ns.handlers.HandleInspectKey)
+		adminAPI.GET("/bin-key/:key64", ns.handlers.HandleInspectBinaryKey)
+		adminAPI.GET("/large-keys", ns.handlers.HandleLargeKeys)
 	}
 }
 
@@ -98,7 +301,7 @@ func (ns *NodeServer) corsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, "+QuorumHeaderN+", "+QuorumHeaderR+", "+QuorumHeaderW)
 		
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(http.StatusNoContent)
@@ -117,11 +320,43 @@ func (ns *NodeServer) Start() error {
 		Handler: ns.router,
 	}
 
+	// 哈希函数或放置算法与集群不一致时拒绝启动，否则各节点对key归属的判断不同
+	if err := ns.cluster.VerifyRingConfig(); err != nil {
+		return err
+	}
+
 	// 启动集群管理器
 	if err := ns.cluster.Start(); err != nil {
 		return fmt.Errorf("启动集群管理器失败: %v", err)
 	}
 
+	// 启动节点间RPC监听
+	if ns.rpc != nil {
+		if err := ns.rpc.Start(); err != nil {
+			return err
+		}
+	}
+
+	// 启动memcached协议监听
+	if ns.memcached != nil {
+		if err := ns.memcached.Start(); err != nil {
+			return err
+		}
+	}
+
+	// 启动RESP协议监听
+	if ns.resp != nil {
+		if err := ns.resp.Start(); err != nil {
+			return err
+		}
+	}
+
+	// 多副本时定期与副本对端做反熵修复
+	ns.node.StartAntiEntropy()
+
+	// 按健康检查间隔检查是否需要驱逐或重新接纳节点
+	ns.evictor.Start(ns.cluster.checkInterval)
+
 	log.Printf("🚀 启动分布式缓存节点: %s", ns.node.GetNodeID())
 	log.Printf("📡 监听地址: %s", ns.node.GetNodeAddress())
 	log.Printf("🌐 集群节点数: %d", len(ns.cluster.GetNodes()))
@@ -132,6 +367,16 @@ func (ns *NodeServer) Start() error {
 			log.Fatalf("HTTP服务器启动失败: %v", err)
 		}
 	}()
+
+	// HTTP服务启动后再开始Raft选举和gossip
+	if ns.raft != nil {
+		ns.raft.Start()
+	}
+
+	// HTTP服务启动后再加入种子节点，对端会立即向本节点迁移数据
+	if ns.gossip != nil {
+		ns.gossip.Start()
+	}
 	
 	// 等待关闭信号
 	ns.waitForShutdown()
@@ -157,15 +402,41 @@ func (ns *NodeServer) Shutdown() {
 	defer cancel()
 	
 	// 从集群中移除节点
+	if ns.gossip != nil {
+		ns.gossip.Leave()
+		ns.gossip.Stop()
+	}
 	if err := ns.cluster.Leave(); err != nil {
 		log.Printf("⚠️ 离开集群失败: %v", err)
 	}
+	if ns.raft != nil {
+		ns.raft.Stop()
+	}
 	
 	// 关闭HTTP服务器
 	if err := ns.server.Shutdown(ctx); err != nil {
 		log.Printf("⚠️ HTTP服务器关闭失败: %v", err)
 	}
 	
+	// 关闭memcached协议监听
+	if ns.memcached != nil {
+		ns.memcached.Stop()
+	}
+
+	// 关闭RESP协议监听
+	if ns.resp != nil {
+		ns.resp.Stop()
+	}
+
+	// 关闭节点间RPC
+	if ns.rpc != nil {
+		ns.rpc.Stop()
+	}
+	ns.node.CloseRPC()
+	ns.node.CloseHints()
+	ns.node.StopAntiEntropy()
+	ns.evictor.Stop()
+
 	// 停止集群管理器
 	ns.cluster.Stop()
 	
@@ -187,6 +458,49 @@ func (ns *NodeServer) GetNode() *DistributedNode {
 	return ns.node
 }
 
+// Handler 获取HTTP路由处理器（便于嵌入其他服务或使用httptest测试）
+func (ns *NodeServer) Handler() http.Handler {
+	return ns.router
+}
+
+// GetMemcachedServer 获取memcached协议服务器，未启用时返回nil
+func (ns *NodeServer) GetMemcachedServer() *MemcachedServer {
+	return ns.memcached
+}
+
+// GetRESPServer 获取RESP协议服务器，未启用时返回nil
+func (ns *NodeServer) GetRESPServer() *RESPServer {
+	return ns.resp
+}
+
+// GetRPCServer 获取节点间RPC服务器，未启用时返回nil
+func (ns *NodeServer) GetRPCServer() *RPCServer {
+	return ns.rpc
+}
+
+// GetGossiper 获取gossip成员管理，未开启时返回nil
+func (ns *NodeServer) GetGossiper() *Gossiper {
+	return ns.gossip
+}
+
+// GetEvictor 获取节点驱逐器
+func (ns *NodeServer) GetEvictor() *NodeEvictor {
+	return ns.evictor
+}
+
+// GetRaft 获取Raft节点，未开启时返回nil
+func (ns *NodeServer) GetRaft() *Raft {
+	return ns.raft
+}
+
+// GetClusterMetadata 获取Raft复制的集群元数据，未开启Raft时返回空元数据
+func (ns *NodeServer) GetClusterMetadata() ClusterMetadata {
+	if ns.handlers.metadata == nil {
+		return ClusterMetadata{}
+	}
+	return ns.handlers.metadata.snapshot()
+}
+
 // GetCluster 获取集群管理器
 func (ns *NodeServer) GetCluster() *ClusterManager {
 	return ns.cluster
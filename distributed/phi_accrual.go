@@ -0,0 +1,91 @@
+package distributed
+
+import (
+	"math"
+	"time"
+)
+
+// Phi Accrual 故障检测
+//
+// 不再用单次检查的成败判断节点状态，而是根据心跳（成功的健康检查或gossip探测）的到达间隔估计正态分布，
+// 用距上次心跳的时间计算 φ = -log10(P(间隔 > t))：φ 为 1 表示误判概率约10%，为 8 表示约1e-8。
+// φ 超过阈值才判定为不健康，偶发的GC停顿或单次超时不会让节点状态来回切换。
+
+// 故障检测默认参数
+const (
+	DefaultPhiThreshold        = 8.0
+	DefaultHealthCheckInterval = 10 * time.Second
+
+	phiWindowSize = 100   // 保留的心跳间隔样本数
+	phiMax        = 100.0 // φ 上限，避免概率下溢为0时得到无穷大
+)
+
+// phiAccrual 单个节点的心跳间隔统计
+type phiAccrual struct {
+	intervals  []float64 // 最近的心跳间隔（毫秒）
+	sum        float64
+	sumSquares float64
+	last       time.Time
+
+	estimate  float64 // 没有样本时的间隔估计（毫秒），即检查间隔
+	minStdDev float64 // 标准差下限，避免心跳过于规律时轻微延迟就被判定故障
+	pause     float64 // 可接受的心跳停顿，允许错过一次检查
+}
+
+// newPhiAccrual 按心跳间隔创建检测器：标准差下限为间隔的1/4，可接受错过一次心跳
+func newPhiAccrual(interval time.Duration) *phiAccrual {
+	ms := float64(interval) / float64(time.Millisecond)
+	return &phiAccrual{estimate: ms, minStdDev: ms / 4, pause: ms}
+}
+
+// heartbeat 记录一次心跳
+func (d *phiAccrual) heartbeat(now time.Time) {
+	if !d.last.IsZero() {
+		interval := float64(now.Sub(d.last)) / float64(time.Millisecond)
+		d.intervals = append(d.intervals, interval)
+		d.sum += interval
+		d.sumSquares += interval * interval
+		if len(d.intervals) > phiWindowSize {
+			oldest := d.intervals[0]
+			d.intervals = d.intervals[1:]
+			d.sum -= oldest
+			d.sumSquares -= oldest * oldest
+		}
+	}
+	d.last = now
+}
+
+// seen 是否收到过心跳
+func (d *phiAccrual) seen() bool {
+	return !d.last.IsZero()
+}
+
+// phi 当前的怀疑程度，未收到过心跳时为0
+func (d *phiAccrual) phi(now time.Time) float64 {
+	if d.last.IsZero() {
+		return 0
+	}
+
+	mean, stdDev := d.estimate, d.estimate/4
+	if n := float64(len(d.intervals)); n > 0 {
+		mean = d.sum / n
+		stdDev = math.Sqrt(math.Max(d.sumSquares/n-mean*mean, 0))
+	}
+	stdDev = math.Max(stdDev, d.minStdDev)
+	mean += d.pause
+
+	// 正态分布累积函数的逻辑斯谛近似
+	elapsed := float64(now.Sub(d.last)) / float64(time.Millisecond)
+	y := (elapsed - mean) / stdDev
+	e := math.Exp(-y * (1.5976 + 0.070566*y*y))
+	var phi float64
+	if elapsed > mean {
+		phi = -math.Log10(e / (1 + e))
+	} else {
+		phi = -math.Log10(1 - 1/(1+e))
+	}
+	if math.IsNaN(phi) || phi > phiMax {
+		return phiMax
+	}
+	return math.Max(phi, 0)
+}
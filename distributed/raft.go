@@ -0,0 +1,1373 @@
+package distributed
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Raft 一致性协议
+//
+// 集群元数据（成员、权重、拓扑版本、集群配置）的变更先追加到Raft日志，多数派确认后由每个节点按日志顺序应用，
+// 并发的加入、离开和权重修改因此在所有节点上以相同的顺序生效，不再依赖各节点互相广播。
+//
+// 实现包括：
+//   - 领导者选举：选举前先预投票，只有多数派最近没有收到领导者消息且日志不比自己新时才递增任期，
+//     被隔离或尚未加入的节点不会推高任期打断现有领导者
+//   - 日志复制：每个对端一个复制协程，日志不一致时按对端返回的最后索引回退
+//   - 成员变更：每次增删一个投票成员，新成员集合随条目追加即生效。上一次变更提交、
+//     并被所有在线成员应用（状态机完成数据迁移）之前不接受新的变更，相邻两次拓扑变更的数据迁移不会交错
+//   - 启动模式：引导模式以配置的成员作为初始投票成员；加入模式以空成员集合启动，不参与选举，
+//     由现有领导者追加包含本节点的成员变更条目后才成为投票成员，不会另外形成一个集群
+//   - 持久化：配置目录时任期和投票整体重写到状态文件，日志条目追加到日志文件，都在 fsync 之后才回复对端；
+//     重写文件时先 fsync 临时文件再 rename 并 fsync 目录
+//   - 快照：状态机实现 RaftSnapshotter 时，距上次快照应用的条目达到阈值后生成快照并压缩日志，
+//     需要的条目已被压缩的对端由领导者发送快照；重启后先从快照恢复状态机，再重放之后的条目
+
+// Raft 节点角色
+const (
+	RaftFollower  = "follower"
+	RaftCandidate = "candidate"
+	RaftLeader    = "leader"
+)
+
+// Raft 默认参数
+const (
+	DefaultRaftElectionTimeout   = time.Second
+	DefaultRaftHeartbeatInterval = 100 * time.Millisecond
+	DefaultRaftCommitTimeout     = 5 * time.Second
+	DefaultRaftSnapshotThreshold = 1024
+
+	raftMaxAppendEntries = 64 // 单次复制的最大条目数
+)
+
+// 日志条目类型
+const (
+	RaftEntryCommand = "command" // 状态机命令
+	RaftEntryConfig  = "config"  // 投票成员变更，可同时携带状态机命令
+	RaftEntryNoop    = "noop"    // 新领导者上任时追加，用于提交之前任期的条目
+)
+
+// Raft 启动模式
+const (
+	RaftModeBootstrap = "bootstrap" // 以 cluster_nodes 作为初始投票成员引导集群
+	RaftModeJoin      = "join"      // 以空成员集合启动，由现有领导者通过成员变更加入
+)
+
+var errRaftNotLeader = errors.New("本节点不是Raft领导者")
+
+// RaftConfig Raft配置
+type RaftConfig struct {
+	NodeID            string
+	Address           string            // 本节点地址，为空时使用 Peers 中的地址
+	Peers             map[string]string // 初始投票成员（含本节点）nodeID -> 地址，为空时以加入模式启动；日志中有成员变更时以日志为准
+	ElectionTimeout   time.Duration     // 选举超时，实际超时在 [T, 2T) 之间随机，默认1s
+	HeartbeatInterval time.Duration     // 领导者心跳间隔，默认100ms
+	CommitTimeout     time.Duration     // 提议等待应用的超时，默认5s
+	Dir               string            // 持久化目录，为空时只保存在内存中
+	SnapshotThreshold int               // 距上次快照应用多少条目后生成快照并压缩日志，默认1024；压缩后保留最近一半阈值的条目
+}
+
+// RaftEntry 日志条目
+type RaftEntry struct {
+	Index   uint64            `json:"index"`
+	Term    uint64            `json:"term"`
+	Type    string            `json:"type"`
+	Command []byte            `json:"command,omitempty"`
+	Peers   map[string]string `json:"peers,omitempty"` // 配置条目中的新投票成员集合
+}
+
+// RaftMembershipChange 投票成员变更，每次增加或移除一个成员
+type RaftMembershipChange struct {
+	NodeID  string `json:"node_id"`
+	Address string `json:"address,omitempty"`
+	Remove  bool   `json:"remove,omitempty"`
+}
+
+// RaftFSM 复制状态机
+type RaftFSM interface {
+	// Apply 按日志顺序应用已提交的命令。节点重启后会重放快照之后的日志，实现需要幂等
+	Apply(index uint64, command []byte)
+}
+
+// RaftSnapshotter 支持快照的状态机，未实现时日志不压缩
+type RaftSnapshotter interface {
+	// Snapshot 序列化当前已应用的状态，在应用协程中调用，期间不会应用新的条目
+	Snapshot() ([]byte, error)
+	// Restore 用快照替换状态机的全部状态
+	Restore(data []byte) error
+}
+
+// RaftSnapshot 状态机快照，包含截至 Index 的所有已应用条目
+type RaftSnapshot struct {
+	Index       uint64            `json:"index"`
+	Term        uint64            `json:"term"`
+	Peers       map[string]string `json:"peers"`        // 截至 Index 的投票成员
+	ConfigIndex uint64            `json:"config_index"` // 投票成员所在的日志索引
+	Data        []byte            `json:"data"`
+}
+
+// RaftStatus Raft状态
+type RaftStatus struct {
+	NodeID        string            `json:"node_id"`
+	State         string            `json:"state"`
+	Term          uint64            `json:"term"`
+	Leader        string            `json:"leader"`
+	LastIndex     uint64            `json:"last_index"`
+	CommitIndex   uint64            `json:"commit_index"`
+	AppliedIndex  uint64            `json:"applied_index"`
+	SnapshotIndex uint64            `json:"snapshot_index"` // 最近一次快照包含的最后索引，0表示没有快照
+	Peers         map[string]string `json:"peers"`
+}
+
+// raftState 持久化的任期和投票
+type raftState struct {
+	Term     uint64 `json:"term"`
+	VotedFor string `json:"voted_for"`
+}
+
+// Raft 节点
+type Raft struct {
+	config    RaftConfig
+	fsm       RaftFSM
+	transport RaftTransport
+
+	mu            sync.Mutex
+	state         string
+	currentTerm   uint64
+	votedFor      string
+	log           []RaftEntry   // log[0] 为哨兵，索引和任期为压缩点（未压缩时为0）
+	snapshot      *RaftSnapshot // 最近一次快照，nil表示没有
+	logFile       *os.File      // 追加写入的日志文件
+	commitIndex   uint64
+	lastApplied   uint64
+	leaderID      string
+	leaderAddress string
+	peers         map[string]string // 当前投票成员
+	configIndex   uint64            // 当前成员集合所在的日志索引，0表示初始配置
+
+	lastContact     time.Time     // 最近收到领导者消息或开始选举的时间
+	electionTimeout time.Duration // 本轮随机选举超时
+	electing        bool
+
+	nextIndex   map[string]uint64
+	matchIndex  map[string]uint64
+	replicating map[string]bool
+	peerApplied map[string]uint64    // 对端最近报告的已应用索引
+	peerContact map[string]time.Time // 最近一次收到对端复制响应的时间
+
+	applyMu   sync.Mutex    // 应用条目与安装快照互斥
+	applyCh   chan struct{} // 提交索引前进时通知应用协程
+	appliedCh chan struct{} // 每应用一批条目关闭并替换，唤醒等待的提议
+	stopChan  chan struct{}
+	startOnce sync.Once
+	stopOnce  sync.Once
+}
+
+// NewRaft 创建Raft节点，配置了持久化目录时加载之前的状态
+func NewRaft(config RaftConfig, fsm RaftFSM, transport RaftTransport) (*Raft, error) {
+	if config.ElectionTimeout <= 0 {
+		config.ElectionTimeout = DefaultRaftElectionTimeout
+	}
+	if config.HeartbeatInterval <= 0 {
+		config.HeartbeatInterval = DefaultRaftHeartbeatInterval
+	}
+	if config.CommitTimeout <= 0 {
+		config.CommitTimeout = DefaultRaftCommitTimeout
+	}
+	if config.SnapshotThreshold <= 0 {
+		config.SnapshotThreshold = DefaultRaftSnapshotThreshold
+	}
+	if config.Address == "" {
+		config.Address = config.Peers[config.NodeID]
+	}
+
+	r := &Raft{
+		config:      config,
+		fsm:         fsm,
+		transport:   transport,
+		state:       RaftFollower,
+		log:         []RaftEntry{{}},
+		lastContact: time.Now(),
+		nextIndex:   make(map[string]uint64),
+		matchIndex:  make(map[string]uint64),
+		replicating: make(map[string]bool),
+		peerApplied: make(map[string]uint64),
+		peerContact: make(map[string]time.Time),
+		applyCh:     make(chan struct{}, 1),
+		appliedCh:   make(chan struct{}),
+		stopChan:    make(chan struct{}),
+	}
+	r.resetElectionTimeout()
+
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	r.reloadConfig()
+	return r, nil
+}
+
+// Start 启动选举计时和日志应用
+func (r *Raft) Start() {
+	r.startOnce.Do(func() {
+		log.Printf("🗳️ 启动Raft节点 %s，投票成员 %d 个", r.config.NodeID, len(r.peers))
+		go r.run()
+		go r.applyLoop()
+	})
+}
+
+// Stop 停止Raft节点
+func (r *Raft) Stop() {
+	r.stopOnce.Do(func() {
+		close(r.stopChan)
+		r.mu.Lock()
+		r.state = RaftFollower
+		if r.logFile != nil {
+			r.logFile.Close()
+			r.logFile = nil
+		}
+		r.mu.Unlock()
+	})
+}
+
+// Propose 提议一条状态机命令，在本节点应用后返回日志索引；非领导者转发给领导者
+func (r *Raft) Propose(command []byte) (uint64, error) {
+	return r.submit(command, nil)
+}
+
+// ProposeMembership 提议投票成员变更，命令随变更一起应用
+func (r *Raft) ProposeMembership(command []byte, change RaftMembershipChange) (uint64, error) {
+	return r.submit(command, &change)
+}
+
+// IsLeader 本节点是否为领导者
+func (r *Raft) IsLeader() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.state == RaftLeader
+}
+
+// Leader 当前领导者ID，未知时为空
+func (r *Raft) Leader() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.leaderID
+}
+
+// Status 获取Raft状态
+func (r *Raft) Status() RaftStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	peers := make(map[string]string, len(r.peers))
+	for nodeID, address := range r.peers {
+		peers[nodeID] = address
+	}
+	return RaftStatus{
+		NodeID:        r.config.NodeID,
+		State:         r.state,
+		Term:          r.currentTerm,
+		Leader:        r.leaderID,
+		LastIndex:     r.lastIndex(),
+		CommitIndex:   r.commitIndex,
+		AppliedIndex:  r.lastApplied,
+		SnapshotIndex: r.snapshotIndex(),
+		Peers:         peers,
+	}
+}
+
+// ===== 提议 =====
+
+// submit 提议并等待本节点应用；没有领导者时在超时内重试
+func (r *Raft) submit(command []byte, change *RaftMembershipChange) (uint64, error) {
+	deadline := time.Now().Add(r.config.CommitTimeout)
+	for {
+		index, term, err := r.propose(command, change, deadline)
+		if err == nil {
+			return index, r.waitApplied(index, term, deadline)
+		}
+		if err != errRaftNotLeader {
+			return 0, err
+		}
+
+		r.mu.Lock()
+		leader := RaftPeer{ID: r.leaderID, Address: r.leaderAddress}
+		r.mu.Unlock()
+		if leader.ID != "" {
+			resp, err := r.transport.Propose(leader, ProposeRequest{Command: command, Change: change})
+			switch {
+			case err != nil || resp.NotLeader:
+				// 领导者不可达或已变更，等待新的领导者
+			case resp.Error != "":
+				return 0, fmt.Errorf("领导者 %s 拒绝提议: %s", leader.ID, resp.Error)
+			default:
+				return resp.Index, r.waitApplied(resp.Index, resp.Term, deadline)
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return 0, fmt.Errorf("提议超时: 没有可用的Raft领导者")
+		}
+		select {
+		case <-time.After(r.config.HeartbeatInterval):
+		case <-r.stopChan:
+			return 0, fmt.Errorf("Raft节点已停止")
+		}
+	}
+}
+
+// propose 领导者追加条目并开始复制，返回条目的索引和任期
+func (r *Raft) propose(command []byte, change *RaftMembershipChange, deadline time.Time) (uint64, uint64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.state != RaftLeader {
+		return 0, 0, errRaftNotLeader
+	}
+
+	entry := RaftEntry{Type: RaftEntryCommand, Command: command}
+	if change != nil {
+		// 上一次成员变更提交前不接受新的变更，保证新旧成员集合的多数派相交；
+		// 同时等待在线成员应用完上一次变更，避免新变更的数据迁移与上一次的交错
+		for !r.configSettled() {
+			ch := r.appliedCh
+			r.mu.Unlock()
+			select {
+			case <-ch:
+			case <-time.After(r.config.HeartbeatInterval): // 对端的应用进度随心跳响应更新
+			case <-time.After(time.Until(deadline)):
+				r.mu.Lock()
+				return 0, 0, fmt.Errorf("提议超时: 上一次成员变更尚未被所有成员应用")
+			}
+			r.mu.Lock()
+			if r.state != RaftLeader {
+				return 0, 0, errRaftNotLeader
+			}
+		}
+		if peers, changed := applyMembershipChange(r.peers, *change); changed {
+			entry.Type = RaftEntryConfig
+			entry.Peers = peers
+		}
+	}
+
+	entry.Index = r.lastIndex() + 1
+	entry.Term = r.currentTerm
+	if err := r.appendLog(entry); err != nil {
+		return 0, 0, err
+	}
+	r.advanceCommit()
+	r.broadcastAppend()
+	return entry.Index, entry.Term, nil
+}
+
+// configSettled 上一次成员变更已提交，且本节点和最近有响应的对端都已应用，调用方需持有 r.mu
+func (r *Raft) configSettled() bool {
+	if r.configIndex > r.commitIndex || r.configIndex > r.lastApplied {
+		return false
+	}
+	for nodeID := range r.peers {
+		if nodeID == r.config.NodeID || time.Since(r.peerContact[nodeID]) >= r.config.ElectionTimeout {
+			continue // 宕机的成员不阻塞变更，恢复后按日志顺序追上
+		}
+		if r.peerApplied[nodeID] < r.configIndex {
+			return false
+		}
+	}
+	return true
+}
+
+// waitApplied 等待本节点应用到指定索引，条目被其他领导者的条目覆盖时返回错误
+func (r *Raft) waitApplied(index, term uint64, deadline time.Time) error {
+	for {
+		r.mu.Lock()
+		if r.lastApplied >= index {
+			if index < r.firstIndex() {
+				r.mu.Unlock()
+				return fmt.Errorf("索引 %d 已被压缩，无法确认提议是否提交", index)
+			}
+			entryTerm := r.termAt(index)
+			r.mu.Unlock()
+			if entryTerm != term {
+				return fmt.Errorf("领导者变更，提议未被提交")
+			}
+			return nil
+		}
+		ch := r.appliedCh
+		r.mu.Unlock()
+
+		select {
+		case <-ch:
+		case <-time.After(time.Until(deadline)):
+			return fmt.Errorf("提议超时: 索引 %d 未在 %v 内应用", index, r.config.CommitTimeout)
+		case <-r.stopChan:
+			return fmt.Errorf("Raft节点已停止")
+		}
+	}
+}
+
+// applyMembershipChange 计算变更后的成员集合，成员集合不变时返回false
+func applyMembershipChange(current map[string]string, change RaftMembershipChange) (map[string]string, bool) {
+	address, exists := current[change.NodeID]
+	if change.Remove && !exists || !change.Remove && exists && address == change.Address {
+		return nil, false
+	}
+
+	peers := make(map[string]string, len(current)+1)
+	for nodeID, address := range current {
+		peers[nodeID] = address
+	}
+	if change.Remove {
+		delete(peers, change.NodeID)
+	} else {
+		peers[change.NodeID] = change.Address
+	}
+	return peers, true
+}
+
+// ===== 选举 =====
+
+// run 选举计时和领导者心跳
+func (r *Raft) run() {
+	ticker := time.NewTicker(r.config.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.tick()
+		case <-r.stopChan:
+			return
+		}
+	}
+}
+
+// tick 领导者发送心跳，其他节点在选举超时后发起选举
+func (r *Raft) tick() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.state == RaftLeader {
+		r.broadcastAppend()
+		return
+	}
+	if _, voter := r.peers[r.config.NodeID]; !voter || r.electing || time.Since(r.lastContact) < r.electionTimeout {
+		return
+	}
+
+	r.electing = true
+	r.leaderID, r.leaderAddress = "", ""
+	r.lastContact = time.Now()
+	r.resetElectionTimeout()
+	go r.campaign()
+}
+
+// campaign 预投票通过后递增任期正式选举
+func (r *Raft) campaign() {
+	defer func() {
+		r.mu.Lock()
+		r.electing = false
+		r.mu.Unlock()
+	}()
+
+	if !r.requestVotes(true) {
+		return
+	}
+
+	r.mu.Lock()
+	if r.state == RaftLeader {
+		r.mu.Unlock()
+		return
+	}
+	previousTerm, previousVote := r.currentTerm, r.votedFor
+	r.currentTerm++
+	r.votedFor = r.config.NodeID
+	if err := r.saveState(); err != nil {
+		// 投票未落盘时不能请求投票，否则重启后可能在同一任期再投给别人
+		log.Printf("❌ 保存Raft状态失败: %v", err)
+		r.currentTerm, r.votedFor = previousTerm, previousVote
+		r.mu.Unlock()
+		return
+	}
+	r.state = RaftCandidate
+	term := r.currentTerm
+	r.mu.Unlock()
+	log.Printf("🗳️ 节点 %s 发起选举，任期 %d", r.config.NodeID, term)
+
+	if !r.requestVotes(false) {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.state == RaftCandidate && r.currentTerm == term {
+		r.becomeLeader()
+	}
+}
+
+// requestVotes 向所有投票成员请求投票，得到多数派同意时返回true
+func (r *Raft) requestVotes(preVote bool) bool {
+	r.mu.Lock()
+	startTerm := r.currentTerm
+	req := RequestVoteRequest{
+		Term:         r.currentTerm,
+		CandidateID:  r.config.NodeID,
+		LastLogIndex: r.lastIndex(),
+		LastLogTerm:  r.termAt(r.lastIndex()),
+		PreVote:      preVote,
+	}
+	if preVote {
+		req.Term++
+	}
+	peers := make(map[string]string, len(r.peers))
+	for nodeID, address := range r.peers {
+		peers[nodeID] = address
+	}
+	r.mu.Unlock()
+
+	needed := len(peers)/2 + 1
+	granted := 1 // 自己的一票
+	results := make(chan bool, len(peers))
+	for nodeID, address := range peers {
+		if nodeID == r.config.NodeID {
+			continue
+		}
+		go func(peer RaftPeer) {
+			resp, err := r.transport.RequestVote(peer, req)
+			if err != nil {
+				results <- false
+				return
+			}
+			r.mu.Lock()
+			if resp.Term > r.currentTerm {
+				r.becomeFollower(resp.Term, "", "")
+			}
+			r.mu.Unlock()
+			results <- resp.Granted
+		}(RaftPeer{ID: nodeID, Address: address})
+	}
+
+	for remaining := len(peers) - 1; granted < needed && remaining > 0; remaining-- {
+		select {
+		case ok := <-results:
+			if ok {
+				granted++
+			}
+		case <-r.stopChan:
+			return false
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// 投票期间收到了更高的任期或承认了其他领导者
+	if r.currentTerm != startTerm || r.leaderID != "" {
+		return false
+	}
+	return granted >= needed
+}
+
+// becomeLeader 成为领导者并追加空条目，调用方需持有 r.mu
+func (r *Raft) becomeLeader() {
+	log.Printf("👑 节点 %s 成为Raft领导者，任期 %d", r.config.NodeID, r.currentTerm)
+	r.state = RaftLeader
+	r.leaderID, r.leaderAddress = r.config.NodeID, r.config.Address
+	for nodeID := range r.peers {
+		r.nextIndex[nodeID] = r.lastIndex() + 1
+		r.matchIndex[nodeID] = 0
+	}
+
+	if err := r.appendLog(RaftEntry{Index: r.lastIndex() + 1, Term: r.currentTerm, Type: RaftEntryNoop}); err != nil {
+		log.Printf("❌ 保存Raft日志失败: %v", err)
+		r.becomeFollower(r.currentTerm, "", "")
+		return
+	}
+	r.advanceCommit()
+	r.broadcastAppend()
+}
+
+// becomeFollower 转为跟随者，任期更高时清空投票，调用方需持有 r.mu
+func (r *Raft) becomeFollower(term uint64, leaderID, leaderAddress string) {
+	if term > r.currentTerm {
+		r.currentTerm = term
+		r.votedFor = ""
+		if err := r.saveState(); err != nil {
+			log.Printf("❌ 保存Raft状态失败: %v", err)
+		}
+	}
+	if r.state == RaftLeader {
+		log.Printf("⚠️ 节点 %s 不再是Raft领导者，任期 %d", r.config.NodeID, r.currentTerm)
+	}
+	r.state = RaftFollower
+	r.leaderID, r.leaderAddress = leaderID, leaderAddress
+}
+
+// HandleRequestVote 处理投票请求
+func (r *Raft) HandleRequestVote(req RequestVoteRequest) RequestVoteResponse {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	lastTerm := r.termAt(r.lastIndex())
+	upToDate := req.LastLogTerm > lastTerm || req.LastLogTerm == lastTerm && req.LastLogIndex >= r.lastIndex()
+
+	// 只为当前投票成员投票：尚未被加入或已被移除的节点无法当选，也不会推高任期
+	if _, member := r.peers[req.CandidateID]; !member {
+		return RequestVoteResponse{Term: r.currentTerm}
+	}
+
+	if req.PreVote {
+		// 预投票不改变任何状态；最近收到过领导者消息时拒绝，避免重新连通的节点打断现有领导者
+		leaderAlive := r.state == RaftLeader || r.leaderID != "" && time.Since(r.lastContact) < r.config.ElectionTimeout
+		return RequestVoteResponse{
+			Term:    r.currentTerm,
+			Granted: req.Term > r.currentTerm && upToDate && !leaderAlive,
+		}
+	}
+
+	if req.Term < r.currentTerm {
+		return RequestVoteResponse{Term: r.currentTerm}
+	}
+	if req.Term > r.currentTerm {
+		r.becomeFollower(req.Term, "", "")
+	}
+
+	granted := false
+	if (r.votedFor == "" || r.votedFor == req.CandidateID) && upToDate {
+		previous := r.votedFor
+		r.votedFor = req.CandidateID
+		if err := r.saveState(); err != nil {
+			// 投票落盘后才能回复
+			log.Printf("❌ 保存Raft状态失败: %v", err)
+			r.votedFor = previous
+		} else {
+			granted = true
+			r.lastContact = time.Now()
+		}
+	}
+	return RequestVoteResponse{Term: r.currentTerm, Granted: granted}
+}
+
+// resetElectionTimeout 重新随机选举超时，调用方需持有 r.mu
+func (r *Raft) resetElectionTimeout() {
+	r.electionTimeout = r.config.ElectionTimeout + time.Duration(rand.Int63n(int64(r.config.ElectionTimeout)))
+}
+
+// ===== 日志复制 =====
+
+// broadcastAppend 向所有投票成员复制日志（空闲时即为心跳），调用方需持有 r.mu
+func (r *Raft) broadcastAppend() {
+	for nodeID := range r.peers {
+		if nodeID == r.config.NodeID || r.replicating[nodeID] {
+			continue
+		}
+		r.replicating[nodeID] = true
+		go r.replicate(nodeID)
+	}
+}
+
+// replicate 向一个对端复制日志，直到对端追上或请求失败
+func (r *Raft) replicate(peerID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	defer func() { r.replicating[peerID] = false }()
+
+	for {
+		address, voter := r.peers[peerID]
+		if r.state != RaftLeader || !voter {
+			return
+		}
+
+		term := r.currentTerm
+		next := r.nextIndex[peerID]
+		if next == 0 || next > r.lastIndex()+1 {
+			next = r.lastIndex() + 1
+		}
+		if next <= r.firstIndex() {
+			// 对端需要的条目已被压缩，改为发送快照
+			if !r.sendSnapshot(peerID, address, term) {
+				return
+			}
+			continue
+		}
+		end := r.lastIndex() + 1
+		if end-next > raftMaxAppendEntries {
+			end = next + raftMaxAppendEntries
+		}
+		req := AppendEntriesRequest{
+			Term:          term,
+			LeaderID:      r.config.NodeID,
+			LeaderAddress: r.config.Address,
+			PrevLogIndex:  next - 1,
+			PrevLogTerm:   r.termAt(next - 1),
+			Entries:       append([]RaftEntry(nil), r.log[next-r.firstIndex():end-r.firstIndex()]...),
+			LeaderCommit:  r.commitIndex,
+		}
+
+		r.mu.Unlock()
+		resp, err := r.transport.AppendEntries(RaftPeer{ID: peerID, Address: address}, req)
+		r.mu.Lock()
+
+		if err != nil || r.state != RaftLeader || r.currentTerm != term {
+			return
+		}
+		if resp.Term > r.currentTerm {
+			r.becomeFollower(resp.Term, "", "")
+			return
+		}
+		r.peerContact[peerID] = time.Now()
+		r.peerApplied[peerID] = resp.Applied
+
+		if resp.Success {
+			match := req.PrevLogIndex + uint64(len(req.Entries))
+			if match > r.matchIndex[peerID] {
+				r.matchIndex[peerID] = match
+			}
+			r.nextIndex[peerID] = match + 1
+			r.advanceCommit()
+			if r.nextIndex[peerID] > r.lastIndex() {
+				return
+			}
+			continue
+		}
+
+		// 日志不一致：回退到对端最后的索引之后重试
+		next = req.PrevLogIndex
+		if resp.LastIndex+1 < next {
+			next = resp.LastIndex + 1
+		}
+		if next < 1 {
+			next = 1
+		}
+		r.nextIndex[peerID] = next
+	}
+}
+
+// sendSnapshot 向对端发送最近的快照，成功后从快照之后继续复制，调用方需持有 r.mu
+func (r *Raft) sendSnapshot(peerID, address string, term uint64) bool {
+	req := InstallSnapshotRequest{
+		Term:          term,
+		LeaderID:      r.config.NodeID,
+		LeaderAddress: r.config.Address,
+		Snapshot:      *r.snapshot,
+	}
+
+	r.mu.Unlock()
+	resp, err := r.transport.InstallSnapshot(RaftPeer{ID: peerID, Address: address}, req)
+	r.mu.Lock()
+
+	if err != nil || r.state != RaftLeader || r.currentTerm != term {
+		return false
+	}
+	if resp.Term > r.currentTerm {
+		r.becomeFollower(resp.Term, "", "")
+		return false
+	}
+	r.peerContact[peerID] = time.Now()
+	r.peerApplied[peerID] = resp.Applied
+	if !resp.Success {
+		return false
+	}
+
+	log.Printf("📸 已向节点 %s 发送快照，索引 %d", peerID, req.Snapshot.Index)
+	if req.Snapshot.Index > r.matchIndex[peerID] {
+		r.matchIndex[peerID] = req.Snapshot.Index
+	}
+	r.nextIndex[peerID] = req.Snapshot.Index + 1
+	r.advanceCommit()
+	return true
+}
+
+// advanceCommit 领导者把多数派已复制的当前任期条目标记为已提交，调用方需持有 r.mu
+func (r *Raft) advanceCommit() {
+	if r.state != RaftLeader {
+		return
+	}
+	r.matchIndex[r.config.NodeID] = r.lastIndex()
+
+	for index := r.lastIndex(); index > r.commitIndex; index-- {
+		if r.termAt(index) != r.currentTerm {
+			break // 只直接提交当前任期的条目，之前的条目随之提交
+		}
+		replicated := 0
+		for nodeID := range r.peers {
+			if r.matchIndex[nodeID] >= index {
+				replicated++
+			}
+		}
+		if replicated > len(r.peers)/2 {
+			r.commitIndex = index
+			r.notifyApply()
+			break
+		}
+	}
+
+	// 本节点已被移出投票成员且变更已提交时退位
+	if _, voter := r.peers[r.config.NodeID]; !voter && r.configIndex <= r.commitIndex {
+		log.Printf("👋 节点 %s 已不是投票成员，退出领导者", r.config.NodeID)
+		r.becomeFollower(r.currentTerm, "", "")
+	}
+}
+
+// HandleAppendEntries 处理日志复制和心跳
+func (r *Raft) HandleAppendEntries(req AppendEntriesRequest) AppendEntriesResponse {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if req.Term < r.currentTerm {
+		return AppendEntriesResponse{Term: r.currentTerm, LastIndex: r.lastIndex()}
+	}
+	if req.Term > r.currentTerm || r.state != RaftFollower || r.leaderID != req.LeaderID {
+		r.becomeFollower(req.Term, req.LeaderID, req.LeaderAddress)
+	}
+	r.lastContact = time.Now()
+
+	resp := AppendEntriesResponse{Term: r.currentTerm, Applied: r.lastApplied}
+	if req.PrevLogIndex > r.lastIndex() {
+		resp.LastIndex = r.lastIndex()
+		return resp
+	}
+	// 压缩点之前的条目都已提交，必然与领导者一致
+	if req.PrevLogIndex >= r.firstIndex() && r.termAt(req.PrevLogIndex) != req.PrevLogTerm {
+		resp.LastIndex = req.PrevLogIndex - 1
+		return resp
+	}
+
+	previous := r.log
+	var appended []RaftEntry
+	truncated, configChanged := false, false
+	for i, entry := range req.Entries {
+		index := req.PrevLogIndex + 1 + uint64(i)
+		if index <= r.firstIndex() {
+			continue
+		}
+		if index <= r.lastIndex() {
+			if r.termAt(index) == entry.Term {
+				continue
+			}
+			// 冲突的条目及之后的条目都未提交，截断后以领导者为准；限制容量使追加时重新分配，失败时可以回滚
+			offset := index - r.firstIndex()
+			r.log = r.log[:offset:offset]
+			truncated = true
+			configChanged = configChanged || r.configIndex >= index
+		}
+		r.log = append(r.log, entry)
+		appended = append(appended, entry)
+		configChanged = configChanged || entry.Type == RaftEntryConfig
+	}
+
+	// 条目落盘后才能回复成功
+	var err error
+	if truncated {
+		err = r.rewriteLog()
+	} else {
+		err = r.writeEntries(appended)
+	}
+	if err != nil {
+		log.Printf("❌ 保存Raft日志失败: %v", err)
+		r.log = previous
+		if configChanged {
+			r.reloadConfig()
+		}
+		resp.LastIndex = req.PrevLogIndex
+		return resp
+	}
+	if configChanged {
+		r.reloadConfig()
+	}
+
+	// 只提交与领导者确认一致的前缀
+	commit := req.LeaderCommit
+	if lastNew := req.PrevLogIndex + uint64(len(req.Entries)); lastNew < commit {
+		commit = lastNew
+	}
+	if commit > r.commitIndex {
+		r.commitIndex = commit
+		r.notifyApply()
+	}
+
+	resp.Success = true
+	resp.LastIndex = r.lastIndex()
+	return resp
+}
+
+// HandlePropose 领导者处理其他节点转发的提议，追加后立即返回索引，由提议节点等待应用
+func (r *Raft) HandlePropose(req ProposeRequest) ProposeResponse {
+	index, term, err := r.propose(req.Command, req.Change, time.Now().Add(r.config.CommitTimeout))
+	if err != nil {
+		return ProposeResponse{Error: err.Error(), NotLeader: err == errRaftNotLeader}
+	}
+	return ProposeResponse{Index: index, Term: term}
+}
+
+// HandleInstallSnapshot 安装领导者发送的快照，替换状态机和已被快照覆盖的日志
+func (r *Raft) HandleInstallSnapshot(req InstallSnapshotRequest) InstallSnapshotResponse {
+	// 持有 applyMu 期间应用协程不会修改状态机和已应用索引
+	r.applyMu.Lock()
+	defer r.applyMu.Unlock()
+
+	r.mu.Lock()
+	if req.Term < r.currentTerm {
+		defer r.mu.Unlock()
+		return InstallSnapshotResponse{Term: r.currentTerm, Applied: r.lastApplied}
+	}
+	if req.Term > r.currentTerm || r.state != RaftFollower || r.leaderID != req.LeaderID {
+		r.becomeFollower(req.Term, req.LeaderID, req.LeaderAddress)
+	}
+	r.lastContact = time.Now()
+	resp := InstallSnapshotResponse{Term: r.currentTerm, Applied: r.lastApplied}
+	snapshot := req.Snapshot
+	if snapshot.Index <= r.lastApplied {
+		// 已经应用过快照中的全部条目
+		r.mu.Unlock()
+		resp.Success = true
+		return resp
+	}
+	r.mu.Unlock()
+
+	snapshotter, ok := r.fsm.(RaftSnapshotter)
+	if !ok {
+		log.Printf("❌ 状态机不支持快照，无法安装索引 %d 的快照", snapshot.Index)
+		return resp
+	}
+	if err := snapshotter.Restore(snapshot.Data); err != nil {
+		log.Printf("❌ 恢复Raft快照失败: %v", err)
+		return resp
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.saveSnapshot(&snapshot); err != nil {
+		// 状态机已恢复，快照未落盘时重启后由领导者重新发送
+		log.Printf("❌ 保存Raft快照失败: %v", err)
+	}
+	if snapshot.Index >= r.firstIndex() && snapshot.Index <= r.lastIndex() && r.termAt(snapshot.Index) == snapshot.Term {
+		// 本地日志在快照位置与领导者一致，保留之后的条目
+		r.compactLog(snapshot.Index)
+	} else {
+		r.log = []RaftEntry{{Index: snapshot.Index, Term: snapshot.Term}}
+	}
+	if err := r.rewriteLog(); err != nil {
+		log.Printf("❌ 保存Raft日志失败: %v", err)
+	}
+	r.reloadConfig()
+	if snapshot.Index > r.commitIndex {
+		r.commitIndex = snapshot.Index
+	}
+	r.lastApplied = snapshot.Index
+	close(r.appliedCh)
+	r.appliedCh = make(chan struct{})
+	r.notifyApply()
+	log.Printf("📸 安装Raft快照: 索引 %d，任期 %d", snapshot.Index, snapshot.Term)
+
+	resp.Term = r.currentTerm
+	resp.Applied = r.lastApplied
+	resp.Success = true
+	return resp
+}
+
+// ===== 应用 =====
+
+// notifyApply 通知应用协程，调用方需持有 r.mu
+func (r *Raft) notifyApply() {
+	select {
+	case r.applyCh <- struct{}{}:
+	default:
+	}
+}
+
+// applyLoop 按顺序把已提交的条目应用到状态机
+func (r *Raft) applyLoop() {
+	for {
+		select {
+		case <-r.applyCh:
+		case <-r.stopChan:
+			return
+		}
+
+		r.applyMu.Lock()
+		for {
+			r.mu.Lock()
+			if r.lastApplied >= r.commitIndex {
+				r.mu.Unlock()
+				break
+			}
+			entry := r.entryAt(r.lastApplied + 1)
+			r.mu.Unlock()
+
+			if entry.Type != RaftEntryNoop && len(entry.Command) > 0 {
+				r.fsm.Apply(entry.Index, entry.Command)
+			}
+
+			r.mu.Lock()
+			r.lastApplied = entry.Index
+			close(r.appliedCh)
+			r.appliedCh = make(chan struct{})
+			r.mu.Unlock()
+		}
+		r.maybeSnapshot()
+		r.applyMu.Unlock()
+	}
+}
+
+// maybeSnapshot 距上次快照应用的条目达到阈值时生成快照并压缩日志，调用方需持有 r.applyMu
+func (r *Raft) maybeSnapshot() {
+	snapshotter, ok := r.fsm.(RaftSnapshotter)
+	if !ok {
+		return
+	}
+	r.mu.Lock()
+	applied := r.lastApplied
+	due := applied-r.snapshotIndex() >= uint64(r.config.SnapshotThreshold)
+	r.mu.Unlock()
+	if !due {
+		return
+	}
+
+	data, err := snapshotter.Snapshot()
+	if err != nil {
+		log.Printf("❌ 生成Raft快照失败: %v", err)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	peers, configIndex := r.configAt(applied)
+	snapshot := &RaftSnapshot{
+		Index:       applied,
+		Term:        r.termAt(applied),
+		Peers:       peers,
+		ConfigIndex: configIndex,
+		Data:        data,
+	}
+	if err := r.saveSnapshot(snapshot); err != nil {
+		log.Printf("❌ 保存Raft快照失败: %v", err)
+		return
+	}
+
+	// 保留最近一半阈值的条目，稍微落后的对端仍可通过日志追赶
+	compactTo := r.firstIndex()
+	if keep := uint64(r.config.SnapshotThreshold / 2); applied > compactTo+keep {
+		compactTo = applied - keep
+	}
+	r.compactLog(compactTo)
+	if err := r.rewriteLog(); err != nil {
+		log.Printf("❌ 保存Raft日志失败: %v", err)
+	}
+	log.Printf("📸 生成Raft快照: 索引 %d，日志压缩到 %d", applied, compactTo)
+}
+
+// ===== 成员与持久化 =====
+
+// setConfig 切换到新的成员集合，调用方需持有 r.mu
+func (r *Raft) setConfig(peers map[string]string, index uint64) {
+	r.peers = peers
+	r.configIndex = index
+	if address, ok := peers[r.config.NodeID]; ok && address != "" {
+		r.config.Address = address
+	}
+	for nodeID := range peers {
+		if _, ok := r.nextIndex[nodeID]; !ok {
+			r.nextIndex[nodeID] = r.lastIndex() + 1
+		}
+	}
+	for nodeID := range r.nextIndex {
+		if _, ok := peers[nodeID]; !ok {
+			delete(r.nextIndex, nodeID)
+			delete(r.matchIndex, nodeID)
+			delete(r.peerApplied, nodeID)
+			delete(r.peerContact, nodeID)
+		}
+	}
+}
+
+// reloadConfig 使用日志中最新的成员变更，调用方需持有 r.mu（或尚未启动）
+func (r *Raft) reloadConfig() {
+	r.setConfig(r.configAt(r.lastIndex()))
+}
+
+// configAt 截至 index 生效的成员：日志中最近的成员变更，其次是快照中的成员，都没有时使用初始配置。
+// index 不能小于快照索引，调用方需持有 r.mu
+func (r *Raft) configAt(index uint64) (map[string]string, uint64) {
+	for ; index > r.snapshotIndex(); index-- {
+		if entry := r.entryAt(index); entry.Type == RaftEntryConfig {
+			return entry.Peers, index
+		}
+	}
+	if r.snapshot != nil && r.snapshot.Peers != nil {
+		return r.snapshot.Peers, r.snapshot.ConfigIndex
+	}
+	peers := make(map[string]string, len(r.config.Peers))
+	for nodeID, address := range r.config.Peers {
+		peers[nodeID] = address
+	}
+	return peers, 0
+}
+
+// firstIndex 日志压缩点的索引，之前的条目只存在于快照中，调用方需持有 r.mu
+func (r *Raft) firstIndex() uint64 {
+	return r.log[0].Index
+}
+
+// lastIndex 最后一个条目的索引，调用方需持有 r.mu
+func (r *Raft) lastIndex() uint64 {
+	return r.firstIndex() + uint64(len(r.log)-1)
+}
+
+// entryAt 获取索引对应的条目，index 需在 [firstIndex, lastIndex] 之间，调用方需持有 r.mu
+func (r *Raft) entryAt(index uint64) RaftEntry {
+	return r.log[index-r.firstIndex()]
+}
+
+// termAt 获取索引对应条目的任期，调用方需持有 r.mu
+func (r *Raft) termAt(index uint64) uint64 {
+	return r.entryAt(index).Term
+}
+
+// snapshotIndex 最近一次快照的索引，没有快照时为0，调用方需持有 r.mu
+func (r *Raft) snapshotIndex() uint64 {
+	if r.snapshot == nil {
+		return 0
+	}
+	return r.snapshot.Index
+}
+
+// compactLog 丢弃 index 之前的条目，index 成为新的哨兵，调用方需持有 r.mu
+func (r *Raft) compactLog(index uint64) {
+	compacted := make([]RaftEntry, 0, r.lastIndex()-index+1)
+	compacted = append(compacted, RaftEntry{Index: index, Term: r.termAt(index)})
+	compacted = append(compacted, r.log[index-r.firstIndex()+1:]...)
+	r.log = compacted
+}
+
+// statePath 任期和投票的持久化文件路径
+func (r *Raft) statePath() string {
+	return filepath.Join(r.config.Dir, fmt.Sprintf("raft-%s.state", r.config.NodeID))
+}
+
+// logPath 日志文件路径，每行一个条目，第一行为哨兵
+func (r *Raft) logPath() string {
+	return filepath.Join(r.config.Dir, fmt.Sprintf("raft-%s.log", r.config.NodeID))
+}
+
+// snapshotPath 快照文件路径
+func (r *Raft) snapshotPath() string {
+	return filepath.Join(r.config.Dir, fmt.Sprintf("raft-%s.snapshot", r.config.NodeID))
+}
+
+// saveState 保存任期和投票，调用方需持有 r.mu
+func (r *Raft) saveState() error {
+	if r.config.Dir == "" {
+		return nil
+	}
+	return replaceFile(r.statePath(), func(w *bufio.Writer) error {
+		return json.NewEncoder(w).Encode(raftState{Term: r.currentTerm, VotedFor: r.votedFor})
+	})
+}
+
+// appendLog 追加条目并落盘，失败时回滚，调用方需持有 r.mu
+func (r *Raft) appendLog(entries ...RaftEntry) error {
+	previous := r.log
+	r.log = append(r.log, entries...)
+	if err := r.writeEntries(entries); err != nil {
+		r.log = previous
+		return fmt.Errorf("保存Raft日志失败: %v", err)
+	}
+	for _, entry := range entries {
+		if entry.Type == RaftEntryConfig {
+			r.setConfig(entry.Peers, entry.Index)
+		}
+	}
+	return nil
+}
+
+// writeEntries 把已追加到内存的条目写入日志文件末尾并 fsync，调用方需持有 r.mu
+func (r *Raft) writeEntries(entries []RaftEntry) error {
+	if r.config.Dir == "" || len(entries) == 0 {
+		return nil
+	}
+	if r.logFile == nil {
+		if _, err := os.Stat(r.logPath()); os.IsNotExist(err) {
+			// 日志文件还不存在，连同哨兵整体写入
+			return r.rewriteLog()
+		}
+		file, err := os.OpenFile(r.logPath(), os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		r.logFile = file
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+	}
+	info, err := r.logFile.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err := r.logFile.Write(buf.Bytes()); err != nil {
+		// 截掉写了一半的内容，避免之后的追加接在不完整的行后面
+		r.logFile.Truncate(info.Size())
+		return err
+	}
+	return r.logFile.Sync()
+}
+
+// rewriteLog 用内存中的日志整体替换日志文件，用于截断和压缩，调用方需持有 r.mu
+func (r *Raft) rewriteLog() error {
+	if r.config.Dir == "" {
+		return nil
+	}
+	if r.logFile != nil {
+		r.logFile.Close()
+		r.logFile = nil
+	}
+	return replaceFile(r.logPath(), func(w *bufio.Writer) error {
+		encoder := json.NewEncoder(w)
+		for _, entry := range r.log {
+			if err := encoder.Encode(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// saveSnapshot 保存快照并作为最近的快照，调用方需持有 r.mu
+func (r *Raft) saveSnapshot(snapshot *RaftSnapshot) error {
+	if r.config.Dir != "" {
+		err := replaceFile(r.snapshotPath(), func(w *bufio.Writer) error {
+			return json.NewEncoder(w).Encode(snapshot)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	r.snapshot = snapshot
+	return nil
+}
+
+// load 加载持久化的状态：任期和投票、快照、快照之后的日志，并用快照恢复状态机
+func (r *Raft) load() error {
+	if r.config.Dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(r.config.Dir, 0755); err != nil {
+		return fmt.Errorf("创建Raft目录失败: %v", err)
+	}
+
+	data, err := os.ReadFile(r.statePath())
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("读取Raft状态失败: %v", err)
+	}
+	if err == nil {
+		var state raftState
+		if err := json.Unmarshal(data, &state); err != nil {
+			return fmt.Errorf("解析Raft状态失败: %v", err)
+		}
+		r.currentTerm = state.Term
+		r.votedFor = state.VotedFor
+	}
+
+	data, err = os.ReadFile(r.snapshotPath())
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("读取Raft快照失败: %v", err)
+	}
+	if err == nil {
+		var snapshot RaftSnapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			return fmt.Errorf("解析Raft快照失败: %v", err)
+		}
+		r.snapshot = &snapshot
+	}
+
+	if err := r.loadLog(); err != nil {
+		return err
+	}
+
+	if snapshot := r.snapshot; snapshot != nil {
+		if snapshot.Index < r.firstIndex() {
+			return fmt.Errorf("Raft日志压缩点 %d 超过快照索引 %d", r.firstIndex(), snapshot.Index)
+		}
+		if snapshot.Index > r.firstIndex() {
+			// 快照落盘后、日志压缩前退出，按快照压缩日志
+			if snapshot.Index <= r.lastIndex() && r.termAt(snapshot.Index) == snapshot.Term {
+				r.compactLog(snapshot.Index)
+			} else {
+				r.log = []RaftEntry{{Index: snapshot.Index, Term: snapshot.Term}}
+			}
+			if err := r.rewriteLog(); err != nil {
+				return fmt.Errorf("保存Raft日志失败: %v", err)
+			}
+		}
+
+		snapshotter, ok := r.fsm.(RaftSnapshotter)
+		if !ok {
+			return fmt.Errorf("状态机不支持快照，无法加载索引 %d 的快照", snapshot.Index)
+		}
+		if err := snapshotter.Restore(snapshot.Data); err != nil {
+			return fmt.Errorf("恢复Raft快照失败: %v", err)
+		}
+		r.commitIndex = snapshot.Index
+		r.lastApplied = snapshot.Index
+	}
+
+	log.Printf("📂 加载Raft状态: 任期 %d，快照索引 %d，日志 %d 条", r.currentTerm, r.snapshotIndex(), len(r.log)-1)
+	return nil
+}
+
+// loadLog 读取日志文件，丢弃末尾未写完整的条目
+func (r *Raft) loadLog() error {
+	data, err := os.ReadFile(r.logPath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取Raft日志失败: %v", err)
+	}
+
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	if len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	var entries []RaftEntry
+	torn := false
+	for i, line := range lines {
+		var entry RaftEntry
+		if err := json.Unmarshal(line, &entry); err != nil || !bytes.HasSuffix(line, []byte("\n")) {
+			// 只有最后一行可能因为崩溃没有写完
+			if i != len(lines)-1 {
+				return fmt.Errorf("解析Raft日志第 %d 行失败: %v", i+1, err)
+			}
+			torn = true
+			break
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	for i, entry := range entries {
+		if entry.Index != entries[0].Index+uint64(i) {
+			return fmt.Errorf("Raft日志不连续: 第 %d 行的索引为 %d", i+1, entry.Index)
+		}
+	}
+
+	r.log = entries
+	if torn {
+		log.Printf("⚠️ 丢弃Raft日志末尾不完整的条目")
+		if err := r.rewriteLog(); err != nil {
+			return fmt.Errorf("保存Raft日志失败: %v", err)
+		}
+	}
+	return nil
+}
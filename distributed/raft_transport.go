@@ -0,0 +1,250 @@
+package distributed
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Raft 传输层：节点之间默认通过HTTP交换Raft消息（POST /internal/raft/*），
+// 测试可以使用进程内网络模拟节点宕机和网络分区
+
+// RaftPeer Raft对端
+type RaftPeer struct {
+	ID      string
+	Address string
+}
+
+// RequestVoteRequest 投票请求
+type RequestVoteRequest struct {
+	Term         uint64 `json:"term"`
+	CandidateID  string `json:"candidate_id"`
+	LastLogIndex uint64 `json:"last_log_index"`
+	LastLogTerm  uint64 `json:"last_log_term"`
+	PreVote      bool   `json:"pre_vote,omitempty"` // 预投票不改变接收方的状态
+}
+
+// RequestVoteResponse 投票响应
+type RequestVoteResponse struct {
+	Term    uint64 `json:"term"`
+	Granted bool   `json:"granted"`
+}
+
+// AppendEntriesRequest 日志复制请求，不携带条目时为心跳
+type AppendEntriesRequest struct {
+	Term          uint64      `json:"term"`
+	LeaderID      string      `json:"leader_id"`
+	LeaderAddress string      `json:"leader_address"`
+	PrevLogIndex  uint64      `json:"prev_log_index"`
+	PrevLogTerm   uint64      `json:"prev_log_term"`
+	Entries       []RaftEntry `json:"entries,omitempty"`
+	LeaderCommit  uint64      `json:"leader_commit"`
+}
+
+// AppendEntriesResponse 日志复制响应
+type AppendEntriesResponse struct {
+	Term      uint64 `json:"term"`
+	Success   bool   `json:"success"`
+	LastIndex uint64 `json:"last_index"` // 接收方最后的一致索引，领导者据此回退
+	Applied   uint64 `json:"applied"`    // 接收方已应用的索引，领导者据此判断上一次成员变更是否已生效
+}
+
+// InstallSnapshotRequest 领导者向落后到已压缩日志之前的对端发送快照
+type InstallSnapshotRequest struct {
+	Term          uint64       `json:"term"`
+	LeaderID      string       `json:"leader_id"`
+	LeaderAddress string       `json:"leader_address"`
+	Snapshot      RaftSnapshot `json:"snapshot"`
+}
+
+// InstallSnapshotResponse 快照安装响应
+type InstallSnapshotResponse struct {
+	Term    uint64 `json:"term"`
+	Success bool   `json:"success"`
+	Applied uint64 `json:"applied"`
+}
+
+// ProposeRequest 转发给领导者的提议
+type ProposeRequest struct {
+	Command []byte                `json:"command,omitempty"`
+	Change  *RaftMembershipChange `json:"change,omitempty"`
+}
+
+// ProposeResponse 提议结果
+type ProposeResponse struct {
+	Index     uint64 `json:"index"`
+	Term      uint64 `json:"term"`
+	Error     string `json:"error,omitempty"`
+	NotLeader bool   `json:"not_leader,omitempty"`
+}
+
+// RaftTransport Raft传输层
+type RaftTransport interface {
+	RequestVote(peer RaftPeer, req RequestVoteRequest) (RequestVoteResponse, error)
+	AppendEntries(peer RaftPeer, req AppendEntriesRequest) (AppendEntriesResponse, error)
+	Propose(peer RaftPeer, req ProposeRequest) (ProposeResponse, error)
+	InstallSnapshot(peer RaftPeer, req InstallSnapshotRequest) (InstallSnapshotResponse, error)
+}
+
+// ===== HTTP传输 =====
+
+// httpRaftTransport 通过节点HTTP服务交换Raft消息
+type httpRaftTransport struct {
+	client         *http.Client
+	proposeClient  *http.Client // 提议可能等待上一次成员变更提交，超时更长
+	snapshotClient *http.Client // 快照可能较大，超时更长
+}
+
+// newHTTPRaftTransport 创建HTTP传输
+func newHTTPRaftTransport(commitTimeout time.Duration) *httpRaftTransport {
+	if commitTimeout <= 0 {
+		commitTimeout = DefaultRaftCommitTimeout
+	}
+	return &httpRaftTransport{
+		client:         &http.Client{Timeout: 2 * time.Second},
+		proposeClient:  &http.Client{Timeout: commitTimeout + time.Second},
+		snapshotClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (t *httpRaftTransport) RequestVote(peer RaftPeer, req RequestVoteRequest) (RequestVoteResponse, error) {
+	var resp RequestVoteResponse
+	err := t.post(t.client, peer, "vote", req, &resp)
+	return resp, err
+}
+
+func (t *httpRaftTransport) AppendEntries(peer RaftPeer, req AppendEntriesRequest) (AppendEntriesResponse, error) {
+	var resp AppendEntriesResponse
+	err := t.post(t.client, peer, "append", req, &resp)
+	return resp, err
+}
+
+func (t *httpRaftTransport) Propose(peer RaftPeer, req ProposeRequest) (ProposeResponse, error) {
+	var resp ProposeResponse
+	err := t.post(t.proposeClient, peer, "propose", req, &resp)
+	return resp, err
+}
+
+func (t *httpRaftTransport) InstallSnapshot(peer RaftPeer, req InstallSnapshotRequest) (InstallSnapshotResponse, error) {
+	var resp InstallSnapshotResponse
+	err := t.post(t.snapshotClient, peer, "snapshot", req, &resp)
+	return resp, err
+}
+
+// post 发送Raft消息并解析响应
+func (t *httpRaftTransport) post(client *http.Client, peer RaftPeer, path string, req, resp interface{}) error {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("序列化Raft消息失败: %v", err)
+	}
+
+	url := fmt.Sprintf("http://%s/internal/raft/%s", peer.Address, path)
+	httpResp, err := client.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("发送Raft消息到 %s 失败: %v", peer.ID, err)
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		return fmt.Errorf("节点 %s 返回错误状态: %d", peer.ID, httpResp.StatusCode)
+	}
+	if err := json.NewDecoder(httpResp.Body).Decode(resp); err != nil {
+		return fmt.Errorf("解析Raft响应失败: %v", err)
+	}
+	return nil
+}
+
+// ===== 进程内传输 =====
+
+// InMemoryRaftNetwork 进程内Raft网络，直接调用目标节点的处理方法，可断开节点模拟宕机或分区
+type InMemoryRaftNetwork struct {
+	mu           sync.RWMutex
+	nodes        map[string]*Raft
+	disconnected map[string]bool
+}
+
+// NewInMemoryRaftNetwork 创建进程内Raft网络
+func NewInMemoryRaftNetwork() *InMemoryRaftNetwork {
+	return &InMemoryRaftNetwork{
+		nodes:        make(map[string]*Raft),
+		disconnected: make(map[string]bool),
+	}
+}
+
+// Transport 获取指定节点使用的传输层
+func (n *InMemoryRaftNetwork) Transport(nodeID string) RaftTransport {
+	return &inMemoryRaftTransport{network: n, from: nodeID}
+}
+
+// Register 把节点接入网络
+func (n *InMemoryRaftNetwork) Register(r *Raft) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.nodes[r.config.NodeID] = r
+}
+
+// Disconnect 断开节点，它发出和收到的消息都会失败
+func (n *InMemoryRaftNetwork) Disconnect(nodeID string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.disconnected[nodeID] = true
+}
+
+// Reconnect 恢复节点的网络连接
+func (n *InMemoryRaftNetwork) Reconnect(nodeID string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.disconnected, nodeID)
+}
+
+// target 获取可达的目标节点
+func (n *InMemoryRaftNetwork) target(from, to string) (*Raft, error) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	node, exists := n.nodes[to]
+	if !exists || n.disconnected[from] || n.disconnected[to] {
+		return nil, fmt.Errorf("节点 %s 不可达", to)
+	}
+	return node, nil
+}
+
+// inMemoryRaftTransport 进程内网络中一个节点的传输层
+type inMemoryRaftTransport struct {
+	network *InMemoryRaftNetwork
+	from    string
+}
+
+func (t *inMemoryRaftTransport) RequestVote(peer RaftPeer, req RequestVoteRequest) (RequestVoteResponse, error) {
+	node, err := t.network.target(t.from, peer.ID)
+	if err != nil {
+		return RequestVoteResponse{}, err
+	}
+	return node.HandleRequestVote(req), nil
+}
+
+func (t *inMemoryRaftTransport) AppendEntries(peer RaftPeer, req AppendEntriesRequest) (AppendEntriesResponse, error) {
+	node, err := t.network.target(t.from, peer.ID)
+	if err != nil {
+		return AppendEntriesResponse{}, err
+	}
+	return node.HandleAppendEntries(req), nil
+}
+
+func (t *inMemoryRaftTransport) Propose(peer RaftPeer, req ProposeRequest) (ProposeResponse, error) {
+	node, err := t.network.target(t.from, peer.ID)
+	if err != nil {
+		return ProposeResponse{}, err
+	}
+	return node.HandlePropose(req), nil
+}
+
+func (t *inMemoryRaftTransport) InstallSnapshot(peer RaftPeer, req InstallSnapshotRequest) (InstallSnapshotResponse, error) {
+	node, err := t.network.target(t.from, peer.ID)
+	if err != nil {
+		return InstallSnapshotResponse{}, err
+	}
+	return node.HandleInstallSnapshot(req), nil
+}
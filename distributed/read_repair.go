@@ -0,0 +1,108 @@
+package distributed
+
+import (
+	"log"
+	"math/rand"
+	"sync/atomic"
+
+	"tdd-learning/core"
+)
+
+// 读修复 - 仲裁读取时对比已响应副本的版本，发现缺失或落后的副本时计入统计；
+// 按配置的概率在后台等待所有副本响应，把最新版本写回落后的副本。读取结果不等待修复。
+
+// DefaultReadRepairChance 默认读修复概率
+const DefaultReadRepairChance = 0.1
+
+// ReadRepairStats 读修复统计
+type ReadRepairStats struct {
+	Chance     float64 `json:"chance"`     // 触发后台修复的概率
+	Mismatches uint64  `json:"mismatches"` // 已响应副本版本不一致的读取次数
+	Triggered  uint64  `json:"triggered"`  // 触发后台修复的次数
+	Repaired   uint64  `json:"repaired"`   // 修复的副本数
+	Failures   uint64  `json:"failures"`   // 修复失败的副本数
+}
+
+// readRepairState 读修复配置和计数
+type readRepairState struct {
+	chance                                    float64
+	mismatches, triggered, repaired, failures uint64
+}
+
+// newReadRepairState 未配置时使用默认概率，0表示关闭
+func newReadRepairState(chance *float64) *readRepairState {
+	state := &readRepairState{chance: DefaultReadRepairChance}
+	if chance != nil {
+		state.chance = *chance
+	}
+	return state
+}
+
+// readRepair 检查已响应副本的版本是否一致，按概率在后台修复
+// remaining 为尚未响应的副本数，它们的结果会继续写入 results
+func (dn *DistributedNode) readRepair(key string, responses []replicaResponse, results <-chan replicaResponse, remaining int) {
+	rr := dn.readRepairState
+	if _, lagging := newestReplica(responses); len(lagging) > 0 {
+		atomic.AddUint64(&rr.mismatches, 1)
+	}
+	if rr.chance <= 0 || rand.Float64() >= rr.chance {
+		return
+	}
+	atomic.AddUint64(&rr.triggered, 1)
+
+	collected := append([]replicaResponse(nil), responses...)
+	go func() {
+		for i := 0; i < remaining; i++ {
+			collected = append(collected, <-results)
+		}
+		newest, lagging := newestReplica(collected)
+		for _, nodeID := range lagging {
+			if err := dn.replicaWrite(nodeID, key, newest); err != nil {
+				atomic.AddUint64(&rr.failures, 1)
+				log.Printf("⚠️ 读修复写入副本 %s 失败: %v", nodeID, err)
+				continue
+			}
+			atomic.AddUint64(&rr.repaired, 1)
+		}
+		if len(lagging) > 0 {
+			log.Printf("🩹 读修复: key %q 的 %d 个副本已更新到版本 %d", key, len(lagging), newest.Version)
+		}
+	}()
+}
+
+// newestReplica 找出成功响应中最新的条目，以及缺失该条目或版本落后的副本
+func newestReplica(responses []replicaResponse) (core.VersionedEntry, []string) {
+	var newest core.VersionedEntry
+	found := false
+	for _, response := range responses {
+		if response.err == nil && response.found && (!found || response.entry.NewerThan(newest)) {
+			newest, found = response.entry, true
+		}
+	}
+	if !found {
+		return newest, nil
+	}
+
+	var lagging []string
+	for _, response := range responses {
+		if response.err != nil {
+			continue
+		}
+		if !response.found || newest.NewerThan(response.entry) {
+			lagging = append(lagging, response.nodeID)
+		}
+	}
+	return newest, lagging
+}
+
+// GetReadRepairStats 获取读修复统计
+func (dn *DistributedNode) GetReadRepairStats() ReadRepairStats {
+	rr := dn.readRepairState
+	return ReadRepairStats{
+		Chance:     rr.chance,
+		Mismatches: atomic.LoadUint64(&rr.mismatches),
+		Triggered:  atomic.LoadUint64(&rr.triggered),
+		Repaired:   atomic.LoadUint64(&rr.repaired),
+		Failures:   atomic.LoadUint64(&rr.failures),
+	}
+}
@@ -0,0 +1,471 @@
+package distributed
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"tdd-learning/core"
+)
+
+// 多副本仲裁读写（Dynamo风格）
+//
+// 每个key保存在 GetNodesForKey 选出的N个节点上。写入时由接收请求的节点分配版本号（纳秒时间戳），
+// 并行写入N个副本，W个副本确认后返回；读取时并行查询N个副本，收到R个响应后按版本号合并（最后写入胜出）。
+// 删除写入删除标记。N为1时与单副本行为完全一致（写入负责节点，支持过期路由重定向）。
+// memcached / Redis 协议的读写删除同样按仲裁执行，条件操作在主副本上执行后把结果复制到其他副本。
+
+// Quorum 多副本参数，0表示使用集群配置
+type Quorum struct {
+	N int `yaml:"n" json:"n"` // 副本数
+	R int `yaml:"r" json:"r"` // 读取时需要响应的副本数
+	W int `yaml:"w" json:"w"` // 写入时需要确认的副本数
+}
+
+// 客户端API中覆盖集群配置的请求头
+const (
+	QuorumHeaderN = "X-Quorum-N"
+	QuorumHeaderR = "X-Quorum-R"
+	QuorumHeaderW = "X-Quorum-W"
+)
+
+// 内部副本接口中携带版本号、删除标记、标志和过期时间的请求/响应头
+const (
+	entryVersionHeader   = "X-Entry-Version"
+	entryTombstoneHeader = "X-Entry-Tombstone"
+	entryFlagsHeader     = "X-Entry-Flags"
+	entryExpiresHeader   = "X-Entry-Expires-At" // UnixNano
+)
+
+// QuorumError 确认或响应的副本数未达到仲裁数
+type QuorumError struct {
+	Op       string // "read" / "write"
+	Acks     int    // 成功的副本数
+	Required int    // 需要的副本数
+	Replicas int    // 参与的副本数
+	Err      error  // 第一个失败原因
+}
+
+func (e *QuorumError) Error() string {
+	return fmt.Sprintf("%s仲裁失败: %d/%d 个副本成功，需要 %d: %v", e.Op, e.Acks, e.Replicas, e.Required, e.Err)
+}
+
+// majority 多数派
+func majority(n int) int {
+	return n/2 + 1
+}
+
+// newQuorum 根据节点配置生成集群默认的仲裁参数，R/W未配置时取多数派
+func newQuorum(n, r, w int) Quorum {
+	if n <= 0 {
+		n = 1
+	}
+	if r <= 0 || r > n {
+		r = majority(n)
+	}
+	if w <= 0 || w > n {
+		w = majority(n)
+	}
+	return Quorum{N: n, R: r, W: w}
+}
+
+// Validate 校验参数范围，未设置的字段不校验
+func (q Quorum) Validate() error {
+	if q.N < 0 || q.R < 0 || q.W < 0 {
+		return fmt.Errorf("副本数和读写仲裁数不能为负数")
+	}
+	if q.N > 0 && (q.R > q.N || q.W > q.N) {
+		return fmt.Errorf("读写仲裁数不能超过副本数: N=%d R=%d W=%d", q.N, q.R, q.W)
+	}
+	return nil
+}
+
+// GetQuorum 获取集群默认的仲裁参数
+func (dn *DistributedNode) GetQuorum() Quorum {
+	return dn.quorum
+}
+
+// ResolveQuorum 用请求中的覆盖值合并集群配置；只覆盖N时R/W取新N的多数派
+func (dn *DistributedNode) ResolveQuorum(override Quorum) (Quorum, error) {
+	if err := override.Validate(); err != nil {
+		return Quorum{}, err
+	}
+	q := dn.quorum
+	if override.N > 0 {
+		q = newQuorum(override.N, 0, 0)
+	}
+	if override.R > 0 {
+		q.R = override.R
+	}
+	if override.W > 0 {
+		q.W = override.W
+	}
+	if err := q.Validate(); err != nil {
+		return Quorum{}, err
+	}
+	return q, nil
+}
+
+// SetWithQuorum 按指定的仲裁参数写入
+func (dn *DistributedNode) SetWithQuorum(key, value string, override Quorum) error {
+	q, err := dn.ResolveQuorum(override)
+	if err != nil {
+		return err
+	}
+	if q.N == 1 {
+		return dn.setOnOwner(key, value)
+	}
+	return dn.writeQuorum(key, core.VersionedEntry{Value: value, Version: dn.localCache.NewVersion()}, q)
+}
+
+// SetEntryWithQuorum 按指定的仲裁参数写入带标志和过期时间的条目，由本节点分配版本号，返回写入的条目
+// N为1时在负责节点上执行
+func (dn *DistributedNode) SetEntryWithQuorum(key string, entry core.VersionedEntry, override Quorum) (core.VersionedEntry, error) {
+	q, err := dn.ResolveQuorum(override)
+	if err != nil {
+		return entry, err
+	}
+	if q.N == 1 {
+		op := CacheOp{Op: OpSet, Value: entry.Value, Flags: entry.Flags}
+		if entry.ExpiresAt != 0 {
+			// 不足1毫秒的剩余时间按1毫秒计算，避免被当作永不过期
+			op.TTLMillis = max(time.Until(time.Unix(0, entry.ExpiresAt)).Milliseconds(), 1)
+		}
+		result, err := dn.executeOnPrimary(key, op)
+		if err != nil {
+			return entry, err
+		}
+		if result.Status != OpStatusStored {
+			return entry, fmt.Errorf("节点 %s 拒绝写入: 超出内存限制", result.NodeID)
+		}
+		entry.Version = result.CAS
+		return entry, nil
+	}
+	entry.Version = dn.localCache.NewVersion()
+	entry.Tombstone = false
+	return entry, dn.writeQuorum(key, entry, q)
+}
+
+// GetWithQuorum 按指定的仲裁参数读取
+func (dn *DistributedNode) GetWithQuorum(key string, override Quorum) (string, bool, error) {
+	q, err := dn.ResolveQuorum(override)
+	if err != nil {
+		return "", false, err
+	}
+	if q.N == 1 {
+		return dn.getOnOwner(key)
+	}
+	entry, found, err := dn.readQuorum(key, q)
+	if err != nil || !found || entry.Tombstone {
+		return "", false, err
+	}
+	return entry.Value, true, nil
+}
+
+// GetEntryWithQuorum 按指定的仲裁参数读取条目及其版本号、标志和过期时间，删除标记视为不存在
+// N为1时从负责节点读取
+func (dn *DistributedNode) GetEntryWithQuorum(key string, override Quorum) (core.VersionedEntry, bool, error) {
+	q, err := dn.ResolveQuorum(override)
+	if err != nil {
+		return core.VersionedEntry{}, false, err
+	}
+	if q.N == 1 {
+		result, err := dn.executeOnPrimary(key, CacheOp{Op: OpGet})
+		if err != nil || result.Status != OpStatusFound {
+			return core.VersionedEntry{}, false, err
+		}
+		return core.VersionedEntry{Value: result.Value, Version: result.CAS, Flags: result.Flags}, true, nil
+	}
+	entry, found, err := dn.readQuorum(key, q)
+	if err != nil || !found || entry.Tombstone {
+		return core.VersionedEntry{}, false, err
+	}
+	return entry, true, nil
+}
+
+// DeleteWithQuorum 按指定的仲裁参数删除（写入删除标记）
+func (dn *DistributedNode) DeleteWithQuorum(key string, override Quorum) error {
+	q, err := dn.ResolveQuorum(override)
+	if err != nil {
+		return err
+	}
+	if q.N == 1 {
+		return dn.deleteOnOwner(key)
+	}
+	return dn.writeQuorum(key, core.VersionedEntry{Version: dn.localCache.NewVersion(), Tombstone: true}, q)
+}
+
+// replicated 集群是否配置了多副本
+func (dn *DistributedNode) replicated() bool {
+	return dn.quorum.N > 1
+}
+
+// replicaCount 每个key的副本数，未配置多副本时为1
+func (dn *DistributedNode) replicaCount() int {
+	if dn.quorum.N > 1 {
+		return dn.quorum.N
+	}
+	return 1
+}
+
+// GetReplicationStats 获取多副本统计
+func (dn *DistributedNode) GetReplicationStats() map[string]interface{} {
+	return map[string]interface{}{
+		"quorum":         dn.quorum,
+		"write_failures": atomic.LoadUint64(&dn.quorumWriteFailures),
+		"read_failures":  atomic.LoadUint64(&dn.quorumReadFailures),
+		"read_repair":    dn.GetReadRepairStats(),
+	}
+}
+
+// writeQuorum 并行写入所有副本，W个确认后返回，其余副本在后台继续写入
+func (dn *DistributedNode) writeQuorum(key string, entry core.VersionedEntry, q Quorum) error {
+	replicas := dn.hashRing.GetNodesForKey(key, q.N)
+	if len(replicas) < q.W {
+		atomic.AddUint64(&dn.quorumWriteFailures, 1)
+		return &QuorumError{Op: "write", Required: q.W, Replicas: len(replicas), Err: fmt.Errorf("集群节点数不足")}
+	}
+
+	results := make(chan error, len(replicas))
+	for _, nodeID := range replicas {
+		go func(nodeID string) {
+			err := dn.replicaWrite(nodeID, key, entry)
+			if err != nil && dn.hints != nil && isNodeUnavailable(err) {
+				// 提示不计入写仲裁数，只保证副本恢复后追上
+				dn.storeHint(nodeID, key, entry, err)
+			}
+			results <- err
+		}(nodeID)
+	}
+
+	acks, failures := 0, 0
+	var firstErr error
+	for range replicas {
+		err := <-results
+		if err == nil {
+			if acks++; acks >= q.W {
+				return nil
+			}
+			continue
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		// 剩余副本全部成功也无法达到W时提前失败
+		if failures++; len(replicas)-failures < q.W {
+			break
+		}
+	}
+	atomic.AddUint64(&dn.quorumWriteFailures, 1)
+	return &QuorumError{Op: "write", Acks: acks, Required: q.W, Replicas: len(replicas), Err: firstErr}
+}
+
+// replicaResponse 单个副本的读取结果
+type replicaResponse struct {
+	nodeID string
+	entry  core.VersionedEntry
+	found  bool
+	err    error
+}
+
+// readQuorum 并行读取所有副本，R个响应后返回版本号最新的条目（可能是删除标记），
+// 并按读修复概率在后台修复落后的副本
+func (dn *DistributedNode) readQuorum(key string, q Quorum) (core.VersionedEntry, bool, error) {
+	replicas := dn.hashRing.GetNodesForKey(key, q.N)
+	if len(replicas) < q.R {
+		atomic.AddUint64(&dn.quorumReadFailures, 1)
+		return core.VersionedEntry{}, false, &QuorumError{Op: "read", Required: q.R, Replicas: len(replicas), Err: fmt.Errorf("集群节点数不足")}
+	}
+
+	results := make(chan replicaResponse, len(replicas))
+	for _, nodeID := range replicas {
+		go func(nodeID string) {
+			entry, found, err := dn.replicaRead(nodeID, key)
+			results <- replicaResponse{nodeID: nodeID, entry: entry, found: found, err: err}
+		}(nodeID)
+	}
+
+	var latest core.VersionedEntry
+	found := false
+	acks, failures := 0, 0
+	var firstErr error
+	responses := make([]replicaResponse, 0, len(replicas))
+	for range replicas {
+		response := <-results
+		responses = append(responses, response)
+		if response.err != nil {
+			if firstErr == nil {
+				firstErr = response.err
+			}
+			if failures++; len(replicas)-failures < q.R {
+				break
+			}
+			continue
+		}
+		if response.found && (!found || response.entry.NewerThan(latest)) {
+			latest, found = response.entry, true
+		}
+		if acks++; acks >= q.R {
+			dn.readRepair(key, responses, results, len(replicas)-len(responses))
+			return latest, found, nil
+		}
+	}
+	atomic.AddUint64(&dn.quorumReadFailures, 1)
+	return core.VersionedEntry{}, false, &QuorumError{Op: "read", Acks: acks, Required: q.R, Replicas: len(replicas), Err: firstErr}
+}
+
+// ===== 单个副本的读写 =====
+
+// replicaWrite 把带版本的条目写入指定副本
+func (dn *DistributedNode) replicaWrite(nodeID, key string, entry core.VersionedEntry) error {
+	if nodeID == dn.nodeID {
+		return dn.SetLocalVersioned(key, entry)
+	}
+
+	address, err := dn.replicaAddress(nodeID)
+	if err != nil {
+		return err
+	}
+	if handled, err := dn.forwardViaRPC(address, func(pool *rpcPool) error {
+		return pool.replicaSet(key, entry)
+	}); handled {
+		return err
+	}
+
+	method := "PUT"
+	if entry.Tombstone {
+		method = "DELETE"
+	}
+	req, err := http.NewRequest(method, replicaURL(address, key), strings.NewReader(entry.Value))
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	setEntryHeaders(req.Header, entry)
+
+	resp, err := dn.httpClient.Do(req)
+	if err != nil {
+		return &NodeUnavailableError{Address: address, Err: err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("副本 %s 返回错误: %d", nodeID, resp.StatusCode)
+	}
+	return nil
+}
+
+// replicaRead 读取指定副本上的条目及其版本号
+func (dn *DistributedNode) replicaRead(nodeID, key string) (core.VersionedEntry, bool, error) {
+	if nodeID == dn.nodeID {
+		entry, found := dn.GetLocalVersioned(key)
+		return entry, found, nil
+	}
+
+	address, err := dn.replicaAddress(nodeID)
+	if err != nil {
+		return core.VersionedEntry{}, false, err
+	}
+	var entry core.VersionedEntry
+	var found bool
+	if handled, err := dn.forwardViaRPC(address, func(pool *rpcPool) (err error) {
+		entry, found, err = pool.replicaGet(key)
+		return err
+	}); handled {
+		return entry, found, err
+	}
+
+	resp, err := dn.httpClient.Get(replicaURL(address, key))
+	if err != nil {
+		return core.VersionedEntry{}, false, &NodeUnavailableError{Address: address, Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return core.VersionedEntry{}, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return core.VersionedEntry{}, false, fmt.Errorf("副本 %s 返回错误: %d", nodeID, resp.StatusCode)
+	}
+	value, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return core.VersionedEntry{}, false, fmt.Errorf("读取响应失败: %v", err)
+	}
+	entry, err = parseEntryHeaders(resp.Header)
+	if err != nil {
+		return core.VersionedEntry{}, false, fmt.Errorf("副本 %s 返回的条目无效: %v", nodeID, err)
+	}
+	entry.Value = string(value)
+	return entry, true, nil
+}
+
+// setEntryHeaders 把条目的版本号、删除标记、标志和过期时间写入请求/响应头
+func setEntryHeaders(header http.Header, entry core.VersionedEntry) {
+	header.Set(entryVersionHeader, strconv.FormatUint(entry.Version, 10))
+	if entry.Tombstone {
+		header.Set(entryTombstoneHeader, "1")
+	}
+	if entry.Flags != 0 {
+		header.Set(entryFlagsHeader, strconv.FormatUint(uint64(entry.Flags), 10))
+	}
+	if entry.ExpiresAt != 0 {
+		header.Set(entryExpiresHeader, strconv.FormatInt(entry.ExpiresAt, 10))
+	}
+}
+
+// parseEntryHeaders 从请求/响应头解析条目（不含value）
+func parseEntryHeaders(header http.Header) (core.VersionedEntry, error) {
+	entry := core.VersionedEntry{Tombstone: header.Get(entryTombstoneHeader) == "1"}
+	version, err := strconv.ParseUint(header.Get(entryVersionHeader), 10, 64)
+	if err != nil {
+		return entry, fmt.Errorf("%s必须为非负整数", entryVersionHeader)
+	}
+	entry.Version = version
+	if value := header.Get(entryFlagsHeader); value != "" {
+		flags, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return entry, fmt.Errorf("%s必须为32位非负整数", entryFlagsHeader)
+		}
+		entry.Flags = uint32(flags)
+	}
+	if value := header.Get(entryExpiresHeader); value != "" {
+		if entry.ExpiresAt, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return entry, fmt.Errorf("%s必须为整数", entryExpiresHeader)
+		}
+	}
+	return entry, nil
+}
+
+// replicaAddress 获取副本节点地址
+func (dn *DistributedNode) replicaAddress(nodeID string) (string, error) {
+	dn.mu.RLock()
+	address, exists := dn.clusterNodes[nodeID]
+	dn.mu.RUnlock()
+	if !exists {
+		return "", fmt.Errorf("目标节点不存在: %s", nodeID)
+	}
+	return address, nil
+}
+
+// replicaURL 内部副本接口地址
+func replicaURL(address, key string) string {
+	return fmt.Sprintf("http://%s/internal/replica/%s", address, core.EncodeKey(key))
+}
+
+// SetLocalVersioned 把带版本的条目写入本地缓存 - 用于内部副本接口
+func (dn *DistributedNode) SetLocalVersioned(key string, entry core.VersionedEntry) error {
+	dn.recordRequests(1)
+	if !dn.localCache.SetVersioned(key, entry) {
+		return fmt.Errorf("副本 %s 拒绝写入: 超出内存限制", dn.nodeID)
+	}
+	return nil
+}
+
+// GetLocalVersioned 读取本地条目及其版本号 - 用于内部副本接口
+func (dn *DistributedNode) GetLocalVersioned(key string) (core.VersionedEntry, bool) {
+	dn.recordRequests(1)
+	return dn.localCache.GetVersioned(key)
+}
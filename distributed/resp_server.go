@@ -0,0 +1,863 @@
+package distributed
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// RESPServer Redis RESP2/RESP3 协议监听器
+// 将 GET/SET/DEL/MGET/MSET/EXPIRE/TTL/INCR 等命令映射到 DistributedNode，现有Redis客户端可直接接入集群。
+// 非本地key的处理方式由 mode 决定：
+//   - proxy: 由本节点透明转发到负责节点（默认，适用于普通客户端）
+//   - redirect: 单key命令返回 MOVED 重定向到负责节点的RESP地址，多key命令和未知地址时退回转发。
+//     数据按一致性哈希分布，MOVED 中的槽位只用于兼容回复格式，同一槽位的key可能属于不同节点：
+//     只适合每条命令按 MOVED 重试、不缓存槽位映射的客户端，Redis集群客户端库请使用 proxy 模式
+type RESPServer struct {
+	node      *DistributedNode
+	listener  *tcpListener
+	mode      string
+	peers     map[string]string // 节点ID -> RESP地址，用于MOVED重定向
+	startTime time.Time
+	stats     respStats
+
+	nextClientID int64
+}
+
+// respStats 协议层统计
+type respStats struct {
+	commands       int64
+	keyspaceHits   int64
+	keyspaceMisses int64
+	redirects      int64
+}
+
+// RESP 非本地key处理方式
+const (
+	RESPModeProxy    = "proxy"
+	RESPModeRedirect = "redirect"
+)
+
+const (
+	respMaxLineLength = 64 * 1024
+	respMaxBulkLength = 64 * 1024 * 1024
+	respMaxArgs       = 1024 * 1024
+	respClusterSlots  = 16384
+	respServerVersion = "7.0.0"
+)
+
+// respConn 单个客户端连接的状态
+type respConn struct {
+	id     int64
+	reader *bufio.Reader
+	writer *bufio.Writer
+	proto  int // 2 或 3，由 HELLO 协商
+	quit   bool
+}
+
+// respCommand 命令定义，arity 与Redis一致：正数表示精确参数个数，负数表示最少参数个数（均包含命令名）
+type respCommand struct {
+	arity   int
+	handler func(rs *RESPServer, rc *respConn, args []string)
+}
+
+var respCommands map[string]respCommand
+
+func init() {
+	respCommands = map[string]respCommand{
+		"PING":      {-1, (*RESPServer).cmdPing},
+		"ECHO":      {2, (*RESPServer).cmdEcho},
+		"HELLO":     {-1, (*RESPServer).cmdHello},
+		"SELECT":    {2, (*RESPServer).cmdSelect},
+		"CLIENT":    {-2, (*RESPServer).cmdClient},
+		"COMMAND":   {-1, (*RESPServer).cmdCommand},
+		"QUIT":      {1, (*RESPServer).cmdQuit},
+		"ASKING":    {1, (*RESPServer).cmdOK},
+		"READONLY":  {1, (*RESPServer).cmdOK},
+		"READWRITE": {1, (*RESPServer).cmdOK},
+		"GET":       {2, (*RESPServer).cmdGet},
+		"SET":       {-3, (*RESPServer).cmdSet},
+		"DEL":       {-2, (*RESPServer).cmdDel},
+		"UNLINK":    {-2, (*RESPServer).cmdDel},
+		"EXISTS":    {-2, (*RESPServer).cmdExists},
+		"MGET":      {-2, (*RESPServer).cmdMGet},
+		"MSET":      {-3, (*RESPServer).cmdMSet},
+		"EXPIRE":    {3, (*RESPServer).cmdExpire},
+		"PEXPIRE":   {3, (*RESPServer).cmdExpire},
+		"TTL":       {2, (*RESPServer).cmdTTL},
+		"PTTL":      {2, (*RESPServer).cmdTTL},
+		"INCR":      {2, (*RESPServer).cmdIncr},
+		"DECR":      {2, (*RESPServer).cmdIncr},
+		"INCRBY":    {3, (*RESPServer).cmdIncr},
+		"DECRBY":    {3, (*RESPServer).cmdIncr},
+		"DBSIZE":    {1, (*RESPServer).cmdDBSize},
+		"INFO":      {-1, (*RESPServer).cmdInfo},
+		"CLUSTER":   {-2, (*RESPServer).cmdCluster},
+	}
+}
+
+// NewRESPServer 创建RESP协议服务器
+// peers: 其他节点的RESP地址，仅 redirect 模式使用
+func NewRESPServer(node *DistributedNode, address, mode string, peers map[string]string) *RESPServer {
+	if mode == "" {
+		mode = RESPModeProxy
+	}
+	rs := &RESPServer{
+		node:  node,
+		mode:  mode,
+		peers: make(map[string]string, len(peers)),
+	}
+	for nodeID, addr := range peers {
+		rs.peers[nodeID] = addr
+	}
+	rs.listener = newTCPListener("RESP", address, rs.serveConn)
+	return rs
+}
+
+// Start 开始监听，连接在后台协程中处理
+func (rs *RESPServer) Start() error {
+	rs.startTime = time.Now()
+	return rs.listener.start()
+}
+
+// Addr 实际监听地址
+func (rs *RESPServer) Addr() string {
+	return rs.listener.addr()
+}
+
+// Stop 停止监听并关闭所有连接
+func (rs *RESPServer) Stop() {
+	rs.listener.stop()
+}
+
+// serveConn 处理单个连接上的命令（支持流水线）
+func (rs *RESPServer) serveConn(conn net.Conn) {
+	rc := &respConn{
+		id:     atomic.AddInt64(&rs.nextClientID, 1),
+		reader: bufio.NewReaderSize(conn, respMaxLineLength),
+		writer: bufio.NewWriter(conn),
+		proto:  2,
+	}
+
+	for !rc.quit {
+		args, err := rc.readCommand()
+		if err != nil {
+			if protoErr, ok := err.(respProtocolError); ok {
+				rc.errorReply("ERR Protocol error: " + string(protoErr))
+				rc.writer.Flush()
+			}
+			return
+		}
+		if len(args) > 0 {
+			rs.dispatch(rc, args)
+		}
+
+		// 流水线中还有待处理命令时合并写出
+		if rc.reader.Buffered() == 0 || rc.quit {
+			if err := rc.writer.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// dispatch 执行单条命令
+func (rs *RESPServer) dispatch(rc *respConn, args []string) {
+	atomic.AddInt64(&rs.stats.commands, 1)
+
+	name := strings.ToUpper(args[0])
+	cmd, exists := respCommands[name]
+	if !exists {
+		rc.errorReply(fmt.Sprintf("ERR unknown command '%s'", args[0]))
+		return
+	}
+	if (cmd.arity > 0 && len(args) != cmd.arity) || (cmd.arity < 0 && len(args) < -cmd.arity) {
+		rc.errorReply(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(name)))
+		return
+	}
+
+	args[0] = name
+	cmd.handler(rs, rc, args)
+}
+
+// redirect redirect模式下，单key命令的key属于远端节点时返回MOVED
+// 返回 true 表示已回复重定向；多key命令或远端RESP地址未知时由本节点转发。
+// 多key命令不重定向：客户端会按槽位缓存MOVED的结果，而槽位不决定key的归属
+func (rs *RESPServer) redirect(rc *respConn, keys ...string) bool {
+	if rs.mode != RESPModeRedirect || len(keys) != 1 {
+		return false
+	}
+
+	owner := rs.node.GetNodeForKey(keys[0])
+	if owner == rs.node.GetNodeID() {
+		return false
+	}
+	address, known := rs.peers[owner]
+	if !known {
+		return false
+	}
+
+	atomic.AddInt64(&rs.stats.redirects, 1)
+	rc.errorReply(fmt.Sprintf("MOVED %d %s", respKeySlot(keys[0]), address))
+	return true
+}
+
+// execute 执行缓存操作，出错时回复错误
+func (rs *RESPServer) execute(rc *respConn, key string, op CacheOp) (CacheOpResult, bool) {
+	result, err := rs.node.Execute(key, op)
+	if err != nil {
+		rc.errorReply("ERR " + err.Error())
+		return result, false
+	}
+	return result, true
+}
+
+// ===== 连接与服务器命令 =====
+
+func (rs *RESPServer) cmdOK(rc *respConn, args []string) {
+	rc.simple("OK")
+}
+
+func (rs *RESPServer) cmdPing(rc *respConn, args []string) {
+	switch len(args) {
+	case 1:
+		rc.simple("PONG")
+	case 2:
+		rc.bulk(args[1])
+	default:
+		rc.errorReply("ERR wrong number of arguments for 'ping' command")
+	}
+}
+
+func (rs *RESPServer) cmdEcho(rc *respConn, args []string) {
+	rc.bulk(args[1])
+}
+
+// cmdHello HELLO [protover [AUTH username password] [SETNAME clientname]]
+func (rs *RESPServer) cmdHello(rc *respConn, args []string) {
+	proto := rc.proto
+	if len(args) > 1 {
+		version, err := strconv.Atoi(args[1])
+		if err != nil || (version != 2 && version != 3) {
+			rc.errorReply("NOPROTO unsupported protocol version")
+			return
+		}
+		proto = version
+
+		// 集群不做认证，AUTH/SETNAME 仅校验格式
+		for i := 2; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "AUTH":
+				i += 2
+			case "SETNAME":
+				i++
+			default:
+				rc.errorReply("ERR syntax error")
+				return
+			}
+			if i >= len(args) {
+				rc.errorReply("ERR syntax error")
+				return
+			}
+		}
+	}
+	rc.proto = proto
+
+	mode := "standalone"
+	if rs.mode == RESPModeRedirect {
+		mode = "cluster"
+	}
+	rc.mapHeader(7)
+	rc.bulk("server")
+	rc.bulk("tdd-learning")
+	rc.bulk("version")
+	rc.bulk(respServerVersion)
+	rc.bulk("proto")
+	rc.integer(int64(proto))
+	rc.bulk("id")
+	rc.integer(rc.id)
+	rc.bulk("mode")
+	rc.bulk(mode)
+	rc.bulk("role")
+	rc.bulk("master")
+	rc.bulk("modules")
+	rc.arrayHeader(0)
+}
+
+func (rs *RESPServer) cmdSelect(rc *respConn, args []string) {
+	if args[1] != "0" {
+		rc.errorReply("ERR DB index is out of range")
+		return
+	}
+	rc.simple("OK")
+}
+
+// cmdClient 客户端库连接时常用的 CLIENT 子命令
+func (rs *RESPServer) cmdClient(rc *respConn, args []string) {
+	switch strings.ToUpper(args[1]) {
+	case "SETNAME", "SETINFO":
+		rc.simple("OK")
+	case "GETNAME":
+		rc.null()
+	case "ID":
+		rc.integer(rc.id)
+	default:
+		rc.errorReply(fmt.Sprintf("ERR unknown subcommand '%s'", args[1]))
+	}
+}
+
+// cmdCommand 不提供命令元数据，返回空数组
+func (rs *RESPServer) cmdCommand(rc *respConn, args []string) {
+	rc.arrayHeader(0)
+}
+
+func (rs *RESPServer) cmdQuit(rc *respConn, args []string) {
+	rc.simple("OK")
+	rc.quit = true
+}
+
+// ===== 键值命令 =====
+
+func (rs *RESPServer) cmdGet(rc *respConn, args []string) {
+	if rs.redirect(rc, args[1]) {
+		return
+	}
+	result, ok := rs.execute(rc, args[1], CacheOp{Op: OpGet})
+	if !ok {
+		return
+	}
+	if result.Status != OpStatusFound {
+		atomic.AddInt64(&rs.stats.keyspaceMisses, 1)
+		rc.null()
+		return
+	}
+	atomic.AddInt64(&rs.stats.keyspaceHits, 1)
+	rc.bulk(result.Value)
+}
+
+// cmdSet SET key value [NX|XX] [EX seconds|PX milliseconds|KEEPTTL]
+func (rs *RESPServer) cmdSet(rc *respConn, args []string) {
+	op := CacheOp{Op: OpSet, Value: args[2]}
+	hasExpire := false
+
+	for i := 3; i < len(args); i++ {
+		switch option := strings.ToUpper(args[i]); option {
+		case "NX", "XX":
+			if op.Op != OpSet {
+				rc.errorReply("ERR syntax error")
+				return
+			}
+			op.Op = OpAdd
+			if option == "XX" {
+				op.Op = OpReplace
+			}
+		case "KEEPTTL":
+			if hasExpire {
+				rc.errorReply("ERR syntax error")
+				return
+			}
+			op.KeepTTL = true
+		case "EX", "PX":
+			if hasExpire || op.KeepTTL || i+1 >= len(args) {
+				rc.errorReply("ERR syntax error")
+				return
+			}
+			i++
+			amount, err := strconv.ParseInt(args[i], 10, 64)
+			if err != nil {
+				rc.errorReply("ERR value is not an integer or out of range")
+				return
+			}
+			millis, valid := respExpireMillis(amount, option == "EX")
+			if !valid || millis <= 0 {
+				rc.errorReply("ERR invalid expire time in 'set' command")
+				return
+			}
+			op.TTLMillis = millis
+			hasExpire = true
+		default:
+			rc.errorReply("ERR syntax error")
+			return
+		}
+	}
+
+	if rs.redirect(rc, args[1]) {
+		return
+	}
+	result, ok := rs.execute(rc, args[1], op)
+	if !ok {
+		return
+	}
+	if result.Status != OpStatusStored {
+		// NX/XX 条件不满足
+		rc.null()
+		return
+	}
+	rc.simple("OK")
+}
+
+// cmdDel DEL/UNLINK key [key ...]
+func (rs *RESPServer) cmdDel(rc *respConn, args []string) {
+	keys := args[1:]
+	if rs.redirect(rc, keys...) {
+		return
+	}
+	var deleted int64
+	for _, key := range keys {
+		result, ok := rs.execute(rc, key, CacheOp{Op: OpDelete})
+		if !ok {
+			return
+		}
+		if result.Status == OpStatusDeleted {
+			deleted++
+		}
+	}
+	rc.integer(deleted)
+}
+
+// cmdExists EXISTS key [key ...]
+func (rs *RESPServer) cmdExists(rc *respConn, args []string) {
+	keys := args[1:]
+	if rs.redirect(rc, keys...) {
+		return
+	}
+	var count int64
+	for _, key := range keys {
+		result, ok := rs.execute(rc, key, CacheOp{Op: OpGet})
+		if !ok {
+			return
+		}
+		if result.Status == OpStatusFound {
+			count++
+		}
+	}
+	rc.integer(count)
+}
+
+// cmdMGet MGET key [key ...]
+func (rs *RESPServer) cmdMGet(rc *respConn, args []string) {
+	keys := args[1:]
+	if rs.redirect(rc, keys...) {
+		return
+	}
+
+	results := make([]CacheOpResult, len(keys))
+	for i, key := range keys {
+		result, ok := rs.execute(rc, key, CacheOp{Op: OpGet})
+		if !ok {
+			return
+		}
+		results[i] = result
+	}
+
+	rc.arrayHeader(len(results))
+	for _, result := range results {
+		if result.Status == OpStatusFound {
+			atomic.AddInt64(&rs.stats.keyspaceHits, 1)
+			rc.bulk(result.Value)
+		} else {
+			atomic.AddInt64(&rs.stats.keyspaceMisses, 1)
+			rc.null()
+		}
+	}
+}
+
+// cmdMSet MSET key value [key value ...]
+// key分布在多个节点时逐个写入，不保证跨节点原子性
+func (rs *RESPServer) cmdMSet(rc *respConn, args []string) {
+	if len(args)%2 != 1 {
+		rc.errorReply("ERR wrong number of arguments for 'mset' command")
+		return
+	}
+
+	keys := make([]string, 0, len(args)/2)
+	for i := 1; i < len(args); i += 2 {
+		keys = append(keys, args[i])
+	}
+	if rs.redirect(rc, keys...) {
+		return
+	}
+
+	for i := 1; i < len(args); i += 2 {
+		if _, ok := rs.execute(rc, args[i], CacheOp{Op: OpSet, Value: args[i+1]}); !ok {
+			return
+		}
+	}
+	rc.simple("OK")
+}
+
+// cmdExpire EXPIRE key seconds / PEXPIRE key milliseconds
+func (rs *RESPServer) cmdExpire(rc *respConn, args []string) {
+	amount, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		rc.errorReply("ERR value is not an integer or out of range")
+		return
+	}
+	millis, valid := respExpireMillis(amount, args[0] == "EXPIRE")
+	if !valid {
+		rc.errorReply(fmt.Sprintf("ERR invalid expire time in '%s' command", strings.ToLower(args[0])))
+		return
+	}
+	if rs.redirect(rc, args[1]) {
+		return
+	}
+
+	// 与Redis一致，非正数过期时间直接删除key
+	if millis <= 0 {
+		result, ok := rs.execute(rc, args[1], CacheOp{Op: OpDelete})
+		if ok {
+			rc.boolean(result.Status == OpStatusDeleted)
+		}
+		return
+	}
+
+	result, ok := rs.execute(rc, args[1], CacheOp{Op: OpTouch, TTLMillis: millis})
+	if ok {
+		rc.boolean(result.Status == OpStatusTouched)
+	}
+}
+
+// cmdTTL TTL/PTTL key：-2 表示key不存在，-1 表示永不过期
+func (rs *RESPServer) cmdTTL(rc *respConn, args []string) {
+	if rs.redirect(rc, args[1]) {
+		return
+	}
+	inspection, err := rs.node.InspectKey(args[1])
+	if err != nil {
+		rc.errorReply("ERR " + err.Error())
+		return
+	}
+	if !inspection.Found || inspection.Metadata == nil {
+		rc.integer(-2)
+		return
+	}
+	if inspection.Metadata.ExpiresAt == nil {
+		rc.integer(-1)
+		return
+	}
+
+	remaining := time.Until(*inspection.Metadata.ExpiresAt).Milliseconds()
+	if remaining < 0 {
+		rc.integer(-2)
+		return
+	}
+	if args[0] == "PTTL" {
+		rc.integer(remaining)
+		return
+	}
+	rc.integer((remaining + 500) / 1000)
+}
+
+// cmdIncr INCR/DECR key, INCRBY/DECRBY key amount
+func (rs *RESPServer) cmdIncr(rc *respConn, args []string) {
+	amount := int64(1)
+	if len(args) == 3 {
+		var err error
+		if amount, err = strconv.ParseInt(args[2], 10, 64); err != nil {
+			rc.errorReply("ERR value is not an integer or out of range")
+			return
+		}
+	}
+	if args[0] == "DECR" || args[0] == "DECRBY" {
+		if amount == math.MinInt64 {
+			rc.errorReply("ERR decrement would overflow")
+			return
+		}
+		amount = -amount
+	}
+	if rs.redirect(rc, args[1]) {
+		return
+	}
+
+	result, ok := rs.execute(rc, args[1], CacheOp{Op: OpIncrBy, Amount: amount})
+	if !ok {
+		return
+	}
+	if result.Status != OpStatusStored {
+		rc.errorReply("ERR value is not an integer or out of range")
+		return
+	}
+	number, _ := strconv.ParseInt(result.Value, 10, 64)
+	rc.integer(number)
+}
+
+// cmdDBSize 与Redis集群一致，只返回本节点的key数量
+func (rs *RESPServer) cmdDBSize(rc *respConn, args []string) {
+	rc.integer(int64(rs.node.GetCacheConfig().Size))
+}
+
+// cmdInfo INFO [section]
+func (rs *RESPServer) cmdInfo(rc *respConn, args []string) {
+	section := "all"
+	if len(args) > 1 {
+		section = strings.ToLower(args[1])
+	}
+	if section == "default" || section == "everything" {
+		section = "all"
+	}
+
+	config := rs.node.GetCacheConfig()
+	currConnections, totalConnections := rs.listener.connectionStats()
+	mode := "standalone"
+	if rs.mode == RESPModeRedirect {
+		mode = "cluster"
+	}
+
+	sections := []struct {
+		name  string
+		lines []string
+	}{
+		{"server", []string{
+			"redis_version:" + respServerVersion,
+			"redis_mode:" + mode,
+			"node_id:" + rs.node.GetNodeID(),
+			"resp_mode:" + rs.mode,
+			"tcp_addr:" + rs.Addr(),
+			fmt.Sprintf("uptime_in_seconds:%d", int64(time.Since(rs.startTime).Seconds())),
+		}},
+		{"clients", []string{
+			fmt.Sprintf("connected_clients:%d", currConnections),
+		}},
+		{"memory", []string{
+			fmt.Sprintf("used_memory:%d", config.MemoryUsage),
+			fmt.Sprintf("maxmemory:%d", config.MemoryLimit),
+			fmt.Sprintf("maxkeys:%d", config.CacheSize),
+		}},
+		{"stats", []string{
+			fmt.Sprintf("total_connections_received:%d", totalConnections),
+			fmt.Sprintf("total_commands_processed:%d", atomic.LoadInt64(&rs.stats.commands)),
+			fmt.Sprintf("keyspace_hits:%d", atomic.LoadInt64(&rs.stats.keyspaceHits)),
+			fmt.Sprintf("keyspace_misses:%d", atomic.LoadInt64(&rs.stats.keyspaceMisses)),
+			fmt.Sprintf("moved_redirects:%d", atomic.LoadInt64(&rs.stats.redirects)),
+		}},
+		{"cluster", []string{
+			"cluster_enabled:1",
+			fmt.Sprintf("cluster_known_nodes:%d", len(rs.node.GetClusterNodes())),
+		}},
+		{"keyspace", []string{
+			fmt.Sprintf("db0:keys=%d", config.Size),
+		}},
+	}
+
+	var builder strings.Builder
+	for _, s := range sections {
+		if section != "all" && section != s.name {
+			continue
+		}
+		if builder.Len() > 0 {
+			builder.WriteString("\r\n")
+		}
+		builder.WriteString("# " + strings.ToUpper(s.name[:1]) + s.name[1:] + "\r\n")
+		for _, line := range s.lines {
+			builder.WriteString(line + "\r\n")
+		}
+	}
+	rc.bulk(builder.String())
+}
+
+// cmdCluster CLUSTER INFO|MYID|NODES|KEYSLOT
+// 数据按一致性哈希分布，不存在Redis集群的槽位分配，因此不支持 SLOTS/SHARDS
+func (rs *RESPServer) cmdCluster(rc *respConn, args []string) {
+	nodes := rs.node.GetClusterNodes()
+
+	switch sub := strings.ToUpper(args[1]); sub {
+	case "INFO":
+		info := fmt.Sprintf("cluster_enabled:1\r\ncluster_state:ok\r\ncluster_known_nodes:%d\r\ncluster_size:%d\r\ncluster_placement:consistent-hash\r\nresp_mode:%s\r\n",
+			len(nodes), len(nodes), rs.mode)
+		rc.bulk(info)
+
+	case "MYID":
+		rc.bulk(rs.node.GetNodeID())
+
+	case "NODES":
+		nodeIDs := make([]string, 0, len(nodes))
+		for nodeID := range nodes {
+			nodeIDs = append(nodeIDs, nodeID)
+		}
+		sort.Strings(nodeIDs)
+
+		var builder strings.Builder
+		for _, nodeID := range nodeIDs {
+			flags := "master"
+			address, known := rs.peers[nodeID]
+			if nodeID == rs.node.GetNodeID() {
+				flags = "myself,master"
+				address, known = rs.Addr(), true
+			}
+			if !known {
+				address = nodes[nodeID] // RESP地址未知时使用HTTP地址
+			}
+			fmt.Fprintf(&builder, "%s %s@0 %s - 0 0 0 connected\n", nodeID, address, flags)
+		}
+		rc.bulk(builder.String())
+
+	case "KEYSLOT":
+		if len(args) != 3 {
+			rc.errorReply("ERR wrong number of arguments for 'cluster|keyslot' command")
+			return
+		}
+		rc.integer(int64(respKeySlot(args[2])))
+
+	default:
+		rc.errorReply(fmt.Sprintf("ERR unsupported CLUSTER subcommand '%s', keys are placed by consistent hashing", args[1]))
+	}
+}
+
+// ===== RESP 编解码 =====
+
+// respProtocolError 协议格式错误，回复后关闭连接
+type respProtocolError string
+
+func (e respProtocolError) Error() string {
+	return string(e)
+}
+
+// readLine 读取一行（去掉结尾的\r\n）
+func (rc *respConn) readLine() (string, error) {
+	line, err := rc.reader.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return "", respProtocolError("too big inline request")
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
+
+// readCommand 读取一条命令，支持RESP数组格式和内联格式（便于telnet调试）
+func (rc *respConn) readCommand() ([]string, error) {
+	line, err := rc.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, nil
+	}
+	if line[0] != '*' {
+		return strings.Fields(line), nil
+	}
+
+	count, err := strconv.Atoi(line[1:])
+	if err != nil || count > respMaxArgs {
+		return nil, respProtocolError("invalid multibulk length")
+	}
+
+	args := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		header, err := rc.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if len(header) == 0 || header[0] != '$' {
+			return nil, respProtocolError(fmt.Sprintf("expected '$', got '%s'", header))
+		}
+		size, err := strconv.Atoi(header[1:])
+		if err != nil || size < 0 || size > respMaxBulkLength {
+			return nil, respProtocolError("invalid bulk length")
+		}
+
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(rc.reader, data); err != nil {
+			return nil, err
+		}
+		if string(data[size:]) != "\r\n" {
+			return nil, respProtocolError("bulk string not terminated by CRLF")
+		}
+		args = append(args, string(data[:size]))
+	}
+	return args, nil
+}
+
+func (rc *respConn) simple(s string) {
+	rc.writer.WriteString("+" + s + "\r\n")
+}
+
+func (rc *respConn) errorReply(s string) {
+	rc.writer.WriteString("-" + s + "\r\n")
+}
+
+func (rc *respConn) integer(n int64) {
+	rc.writer.WriteString(":" + strconv.FormatInt(n, 10) + "\r\n")
+}
+
+func (rc *respConn) boolean(b bool) {
+	if b {
+		rc.integer(1)
+	} else {
+		rc.integer(0)
+	}
+}
+
+func (rc *respConn) bulk(s string) {
+	rc.writer.WriteString("$" + strconv.Itoa(len(s)) + "\r\n")
+	rc.writer.WriteString(s)
+	rc.writer.WriteString("\r\n")
+}
+
+// null RESP3 使用独立的null类型，RESP2 使用空bulk string
+func (rc *respConn) null() {
+	if rc.proto == 3 {
+		rc.writer.WriteString("_\r\n")
+	} else {
+		rc.writer.WriteString("$-1\r\n")
+	}
+}
+
+func (rc *respConn) arrayHeader(n int) {
+	rc.writer.WriteString("*" + strconv.Itoa(n) + "\r\n")
+}
+
+// mapHeader RESP3 使用map类型，RESP2 退化为键值交替的数组
+func (rc *respConn) mapHeader(n int) {
+	if rc.proto == 3 {
+		rc.writer.WriteString("%" + strconv.Itoa(n) + "\r\n")
+	} else {
+		rc.arrayHeader(n * 2)
+	}
+}
+
+// ===== 辅助函数 =====
+
+// respExpireMillis 将过期时间换算为毫秒，溢出时返回 false
+func respExpireMillis(amount int64, seconds bool) (int64, bool) {
+	if !seconds {
+		return amount, true
+	}
+	if amount > math.MaxInt64/1000 || amount < math.MinInt64/1000 {
+		return 0, false
+	}
+	return amount * 1000, true
+}
+
+// respKeySlot 与Redis集群相同的槽位计算（CRC16，支持{hash tag}），用于MOVED回复和CLUSTER KEYSLOT
+func respKeySlot(key string) uint16 {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return crc16(key) % respClusterSlots
+}
+
+// crc16 CRC16-XMODEM
+func crc16(data string) uint16 {
+	var crc uint16
+	for i := 0; i < len(data); i++ {
+		crc ^= uint16(data[i]) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,108 @@
+package distributed
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+)
+
+// 拓扑版本与过期路由检测
+//
+// 每次拓扑变更（增删节点、修改权重）哈希环版本号加1，广播变更时携带发起方的版本号，
+// 接收方应用变更后对齐到该版本。转发单key请求时携带本节点的版本号：
+// 目标节点不负责该key且发送方版本不比自己新时，返回421重定向，附带目标节点的版本号和它认为的负责节点；
+// 发送方版本更新时说明目标节点尚未收到变更，目标节点直接在本地处理。
+// 按拓扑直连负责节点的客户端同样携带版本号（见 client_routing.go）。数据迁移和批量写入不携带版本号，不做检查。
+
+// ClusterEpochHeader 转发请求和重定向响应中携带拓扑版本号的HTTP头
+const ClusterEpochHeader = "X-Cluster-Epoch"
+
+// StaleRouteError 目标节点不负责该key时返回的重定向
+type StaleRouteError struct {
+	Epoch   uint64 `json:"epoch"`             // 目标节点的拓扑版本号
+	Owner   string `json:"owner"`             // 目标节点视图中的负责节点
+	Address string `json:"address,omitempty"` // 负责节点的地址
+}
+
+func (e *StaleRouteError) Error() string {
+	return fmt.Sprintf("key应由节点 %s 负责（拓扑版本 %d）", e.Owner, e.Epoch)
+}
+
+// GetRingEpoch 获取本节点的拓扑版本号
+func (dn *DistributedNode) GetRingEpoch() uint64 {
+	return dn.hashRing.Epoch()
+}
+
+// GetRingChecksum 获取本节点哈希环视图的校验和
+func (dn *DistributedNode) GetRingChecksum() string {
+	return dn.hashRing.RingChecksum()
+}
+
+// ObserveRingEpoch 拓扑视图与对端一致时对齐到对端的版本号
+func (dn *DistributedNode) ObserveRingEpoch(epoch uint64) {
+	dn.hashRing.ObserveEpoch(epoch)
+}
+
+// GetStaleRedirects 获取转发请求收到重定向的次数
+func (dn *DistributedNode) GetStaleRedirects() uint64 {
+	return atomic.LoadUint64(&dn.staleRedirects)
+}
+
+// checkRoute 检查转发来的请求是否应由本节点处理，不应处理时返回重定向
+func (dn *DistributedNode) checkRoute(key string, senderEpoch uint64) *StaleRouteError {
+	owner := dn.hashRing.GetNodeForKey(key)
+	epoch := dn.hashRing.Epoch()
+	if owner == dn.nodeID || senderEpoch > epoch {
+		return nil
+	}
+
+	dn.mu.RLock()
+	address := dn.clusterNodes[owner]
+	dn.mu.RUnlock()
+	return &StaleRouteError{Epoch: epoch, Owner: owner, Address: address}
+}
+
+// forwardWithRedirect 转发单key请求，目标节点的视图更新时按重定向重试一次
+func (dn *DistributedNode) forwardWithRedirect(targetAddress string, forward func(address string) error) error {
+	err := forward(targetAddress)
+	redirect, ok := err.(*StaleRouteError)
+	if !ok {
+		return err
+	}
+	atomic.AddUint64(&dn.staleRedirects, 1)
+
+	if epoch := dn.hashRing.Epoch(); redirect.Epoch <= epoch {
+		log.Printf("⚠️ 节点 %s 的哈希环视图过期或与本节点不一致（对端版本 %d，本节点版本 %d）", targetAddress, redirect.Epoch, epoch)
+		return err
+	}
+
+	dn.mu.RLock()
+	address, exists := dn.clusterNodes[redirect.Owner]
+	dn.mu.RUnlock()
+	if !exists {
+		address = redirect.Address
+	}
+	if redirect.Owner == dn.nodeID || address == "" {
+		return err
+	}
+
+	log.Printf("🔀 本节点哈希环视图过期（版本 %d），按重定向转发到节点 %s", dn.hashRing.Epoch(), redirect.Owner)
+	return forward(address)
+}
+
+// setEpochHeader 在转发请求上携带本节点的拓扑版本号
+func (dn *DistributedNode) setEpochHeader(req *http.Request) {
+	req.Header.Set(ClusterEpochHeader, strconv.FormatUint(dn.hashRing.Epoch(), 10))
+}
+
+// decodeStaleRoute 解析421重定向响应
+func decodeStaleRoute(resp *http.Response) error {
+	var redirect StaleRouteError
+	if err := json.NewDecoder(resp.Body).Decode(&redirect); err != nil {
+		return fmt.Errorf("解析重定向响应失败: %v", err)
+	}
+	return &redirect
+}
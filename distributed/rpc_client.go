@@ -0,0 +1,523 @@
+package distributed
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"tdd-learning/core"
+)
+
+// rpcRemoteError 对端执行请求时返回的错误（连接本身正常，不需要回退到HTTP）
+type rpcRemoteError struct {
+	message string
+}
+
+func (e *rpcRemoteError) Error() string {
+	return "目标节点返回错误: " + e.message
+}
+
+// rpcNotSentError 请求没有发出（建立连接失败或连接在发送前已断开），改用HTTP重试是安全的
+type rpcNotSentError struct {
+	err error
+}
+
+func (e *rpcNotSentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *rpcNotSentError) Unwrap() error {
+	return e.err
+}
+
+// isRPCNotSent 判断请求是否没有发出（此时应回退到HTTP）
+func isRPCNotSent(err error) bool {
+	var notSent *rpcNotSentError
+	return errors.As(err, &notSent)
+}
+
+// isRPCTransportError 判断是否为连接层错误（请求超时、发送后连接断开等）
+func isRPCTransportError(err error) bool {
+	var remote *rpcRemoteError
+	var redirect *StaleRouteError
+	var tooLarge *rpcFrameTooLargeError
+	return err != nil && !errors.As(err, &remote) && !errors.As(err, &redirect) && !errors.As(err, &tooLarge)
+}
+
+// ===== 单个连接 =====
+
+// rpcCallResult 等待中的请求结果
+type rpcCallResult struct {
+	frame rpcFrame
+	err   error
+}
+
+// rpcConn 多路复用的RPC连接：多个请求共享一条TCP连接，由后台协程按请求ID分发响应
+type rpcConn struct {
+	conn net.Conn
+
+	writeMu sync.Mutex
+	writer  *bufio.Writer
+
+	mu      sync.Mutex
+	pending map[uint64]chan rpcCallResult
+	nextID  uint64
+	err     error // 非nil表示连接已断开
+}
+
+func dialRPCConn(address string, timeout time.Duration) (*rpcConn, error) {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("连接RPC端口失败: %v", err)
+	}
+	rc := &rpcConn{
+		conn:    conn,
+		writer:  bufio.NewWriter(conn),
+		pending: make(map[uint64]chan rpcCallResult),
+	}
+	go rc.readLoop()
+	return rc, nil
+}
+
+// call 发送请求并等待响应
+func (rc *rpcConn) call(kind byte, payload []byte, timeout time.Duration) ([]byte, error) {
+	// 超过上限的帧对端会拒绝并断开连接，连累同一连接上的其他请求，发送前直接返回错误
+	if err := checkFrameSize(payload); err != nil {
+		return nil, err
+	}
+	done := make(chan rpcCallResult, 1)
+
+	rc.mu.Lock()
+	if rc.err != nil {
+		rc.mu.Unlock()
+		return nil, &rpcNotSentError{err: rc.err}
+	}
+	rc.nextID++
+	id := rc.nextID
+	rc.pending[id] = done
+	rc.mu.Unlock()
+
+	rc.writeMu.Lock()
+	rc.conn.SetWriteDeadline(time.Now().Add(timeout))
+	err := writeFrame(rc.writer, rpcFrame{id: id, kind: kind, payload: payload})
+	if err == nil {
+		err = rc.writer.Flush()
+	}
+	rc.writeMu.Unlock()
+	if err != nil {
+		rc.fail(fmt.Errorf("发送RPC请求失败: %v", err))
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case result := <-done:
+		if result.err != nil {
+			return nil, result.err
+		}
+		if result.frame.kind == rpcStatusError {
+			return nil, &rpcRemoteError{message: string(result.frame.payload)}
+		}
+		if result.frame.kind == rpcStatusRedirect {
+			return nil, decodeRPCRedirect(result.frame.payload)
+		}
+		return result.frame.payload, nil
+	case <-timer.C:
+		rc.mu.Lock()
+		delete(rc.pending, id)
+		rc.mu.Unlock()
+		return nil, fmt.Errorf("RPC请求超时: %v", timeout)
+	}
+}
+
+// readLoop 读取响应并分发给等待中的请求
+func (rc *rpcConn) readLoop() {
+	reader := bufio.NewReader(rc.conn)
+	for {
+		frame, err := readFrame(reader)
+		if err != nil {
+			rc.fail(fmt.Errorf("RPC连接断开: %v", err))
+			return
+		}
+
+		rc.mu.Lock()
+		done, exists := rc.pending[frame.id]
+		delete(rc.pending, frame.id)
+		rc.mu.Unlock()
+
+		if exists {
+			done <- rpcCallResult{frame: frame}
+		}
+	}
+}
+
+// fail 标记连接断开，并让所有等待中的请求立即失败
+func (rc *rpcConn) fail(err error) {
+	rc.mu.Lock()
+	if rc.err == nil {
+		rc.err = err
+	}
+	pending := rc.pending
+	rc.pending = make(map[uint64]chan rpcCallResult)
+	rc.mu.Unlock()
+
+	rc.conn.Close()
+	for _, done := range pending {
+		done <- rpcCallResult{err: err}
+	}
+}
+
+// broken 连接是否已断开
+func (rc *rpcConn) broken() bool {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.err != nil
+}
+
+// ===== 连接池 =====
+
+// rpcPool 到单个节点的RPC连接池，请求轮询分配到各连接上
+type rpcPool struct {
+	address string
+	timeout time.Duration
+
+	mu    sync.Mutex
+	conns []*rpcConn
+	next  int
+}
+
+func newRPCPool(address string, size int, timeout time.Duration) *rpcPool {
+	return &rpcPool{
+		address: address,
+		timeout: timeout,
+		conns:   make([]*rpcConn, size),
+	}
+}
+
+// acquire 轮询选择连接，空槽或已断开的连接重新建立
+func (p *rpcPool) acquire() (*rpcConn, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	slot := p.next
+	p.next = (p.next + 1) % len(p.conns)
+
+	if conn := p.conns[slot]; conn != nil && !conn.broken() {
+		return conn, nil
+	}
+	conn, err := dialRPCConn(p.address, p.timeout)
+	if err != nil {
+		return nil, err
+	}
+	p.conns[slot] = conn
+	return conn, nil
+}
+
+func (p *rpcPool) call(kind byte, payload []byte) (*rpcDecoder, error) {
+	conn, err := p.acquire()
+	if err != nil {
+		return nil, &rpcNotSentError{err: err}
+	}
+	response, err := conn.call(kind, payload, p.timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &rpcDecoder{buf: response}, nil
+}
+
+// close 关闭所有连接
+func (p *rpcPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, conn := range p.conns {
+		if conn != nil {
+			conn.fail(errors.New("RPC连接池已关闭"))
+			p.conns[i] = nil
+		}
+	}
+}
+
+// 单key请求在负载末尾附带发送方的拓扑版本号，用于过期路由检测
+
+func (p *rpcPool) get(key string, epoch uint64) (string, bool, error) {
+	e := &rpcEncoder{}
+	e.str(key)
+	e.uvarint(epoch)
+	d, err := p.call(rpcTypeGet, e.buf)
+	if err != nil {
+		return "", false, err
+	}
+	found, value := d.boolean(), d.str()
+	return value, found, d.err
+}
+
+func (p *rpcPool) set(key, value string, epoch uint64) error {
+	e := &rpcEncoder{}
+	e.str(key)
+	e.str(value)
+	e.uvarint(epoch)
+	_, err := p.call(rpcTypeSet, e.buf)
+	return err
+}
+
+func (p *rpcPool) delete(key string, epoch uint64) error {
+	e := &rpcEncoder{}
+	e.str(key)
+	e.uvarint(epoch)
+	_, err := p.call(rpcTypeDelete, e.buf)
+	return err
+}
+
+func (p *rpcPool) execute(key string, op CacheOp, epoch uint64) (CacheOpResult, error) {
+	e := &rpcEncoder{}
+	e.str(key)
+	encodeCacheOp(e, op)
+	e.uvarint(epoch)
+	d, err := p.call(rpcTypeOp, e.buf)
+	if err != nil {
+		return CacheOpResult{}, err
+	}
+	result := decodeCacheOpResult(d)
+	return result, d.err
+}
+
+func (p *rpcPool) batchGet(keys []string) (map[string]string, error) {
+	e := &rpcEncoder{}
+	e.uvarint(uint64(len(keys)))
+	for _, key := range keys {
+		e.str(key)
+	}
+	d, err := p.call(rpcTypeBatchGet, e.buf)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]string)
+	for _, key := range keys {
+		found, value := d.boolean(), d.str()
+		if found {
+			result[key] = value
+		}
+	}
+	return result, d.err
+}
+
+func (p *rpcPool) batchSet(data map[string]string) error {
+	return p.batchWrite(rpcTypeBatchSet, data)
+}
+
+// migrate 批量写入迁移的条目（含版本号和删除标记），对端跳过准入策略
+func (p *rpcPool) migrate(entries map[string]core.VersionedEntry) error {
+	e := &rpcEncoder{}
+	e.uvarint(uint64(len(entries)))
+	for key, entry := range entries {
+		e.str(key)
+		encodeVersionedEntry(e, entry)
+	}
+	_, err := p.call(rpcTypeMigrate, e.buf)
+	return err
+}
+
+func (p *rpcPool) batchWrite(kind byte, data map[string]string) error {
+	e := &rpcEncoder{}
+	e.uvarint(uint64(len(data)))
+	for key, value := range data {
+		e.str(key)
+		e.str(value)
+	}
+	_, err := p.call(kind, e.buf)
+	return err
+}
+
+func (p *rpcPool) batchDelete(keys []string) error {
+	e := &rpcEncoder{}
+	e.uvarint(uint64(len(keys)))
+	for _, key := range keys {
+		e.str(key)
+	}
+	_, err := p.call(rpcTypeBatchDelete, e.buf)
+	return err
+}
+
+func (p *rpcPool) replicaGet(key string) (core.VersionedEntry, bool, error) {
+	e := &rpcEncoder{}
+	e.str(key)
+	d, err := p.call(rpcTypeReplicaGet, e.buf)
+	if err != nil {
+		return core.VersionedEntry{}, false, err
+	}
+	found := d.boolean()
+	entry := decodeVersionedEntry(d)
+	return entry, found, d.err
+}
+
+func (p *rpcPool) replicaSet(key string, entry core.VersionedEntry) error {
+	e := &rpcEncoder{}
+	e.str(key)
+	encodeVersionedEntry(e, entry)
+	_, err := p.call(rpcTypeReplicaSet, e.buf)
+	return err
+}
+
+// ===== 对端发现 =====
+
+// RPCInfo /internal/rpc/info 的响应
+type RPCInfo struct {
+	NodeID     string `json:"node_id"`
+	RPCAddress string `json:"rpc_address"` // 为空表示未启用RPC
+}
+
+// RPCStats 节点间RPC统计
+type RPCStats struct {
+	Calls         int64 `json:"calls"`          // 通过RPC完成的转发
+	HTTPFallbacks int64 `json:"http_fallbacks"` // 回退到HTTP的转发
+}
+
+const (
+	rpcDefaultPoolSize = 4
+	// 对端不支持RPC或RPC连接失败后，间隔一段时间再重新探测
+	rpcProbeInterval = 30 * time.Second
+)
+
+// rpcPeer 对端RPC状态，pool为nil表示使用HTTP
+type rpcPeer struct {
+	pool      *rpcPool
+	checkedAt time.Time
+}
+
+// rpcTransport 按对端HTTP地址发现RPC地址并维护连接池
+type rpcTransport struct {
+	httpClient *http.Client
+	poolSize   int
+	timeout    time.Duration
+
+	mu    sync.Mutex
+	peers map[string]*rpcPeer // HTTP地址 -> RPC状态
+
+	calls     int64
+	fallbacks int64
+}
+
+func newRPCTransport(poolSize int, timeout time.Duration) *rpcTransport {
+	if poolSize <= 0 {
+		poolSize = rpcDefaultPoolSize
+	}
+	return &rpcTransport{
+		httpClient: createNodeHTTPClient(2 * time.Second),
+		poolSize:   poolSize,
+		timeout:    timeout,
+		peers:      make(map[string]*rpcPeer),
+	}
+}
+
+// poolFor 获取对端的RPC连接池，对端不支持RPC时返回nil
+func (t *rpcTransport) poolFor(httpAddress string) *rpcPool {
+	t.mu.Lock()
+	peer, exists := t.peers[httpAddress]
+	t.mu.Unlock()
+	if exists && (peer.pool != nil || time.Since(peer.checkedAt) < rpcProbeInterval) {
+		return peer.pool
+	}
+
+	// 探测不持锁，并发探测时以最后一次结果为准
+	var pool *rpcPool
+	if address, err := t.probe(httpAddress); err == nil && address != "" {
+		pool = newRPCPool(address, t.poolSize, t.timeout)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if current, exists := t.peers[httpAddress]; exists && current.pool != nil {
+		if pool != nil {
+			pool.close()
+		}
+		return current.pool
+	}
+	t.peers[httpAddress] = &rpcPeer{pool: pool, checkedAt: time.Now()}
+	return pool
+}
+
+// probe 查询对端公布的RPC地址，旧版本节点返回404
+func (t *rpcTransport) probe(httpAddress string) (string, error) {
+	resp, err := t.httpClient.Get(fmt.Sprintf("http://%s/internal/rpc/info", httpAddress))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("目标节点返回错误: %d", resp.StatusCode)
+	}
+	var info RPCInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return "", fmt.Errorf("解析响应失败: %v", err)
+	}
+	if info.RPCAddress == "" {
+		return "", nil
+	}
+	return resolveRPCAddress(info.RPCAddress, httpAddress), nil
+}
+
+// markFailed RPC连接失败后改用HTTP，等待下次探测
+func (t *rpcTransport) markFailed(httpAddress string) {
+	t.mu.Lock()
+	peer, exists := t.peers[httpAddress]
+	t.peers[httpAddress] = &rpcPeer{checkedAt: time.Now()}
+	t.mu.Unlock()
+
+	if exists && peer.pool != nil {
+		peer.pool.close()
+	}
+}
+
+// recordCall 记录一次转发使用的传输方式
+func (t *rpcTransport) recordCall(viaRPC bool) {
+	if viaRPC {
+		atomic.AddInt64(&t.calls, 1)
+	} else {
+		atomic.AddInt64(&t.fallbacks, 1)
+	}
+}
+
+func (t *rpcTransport) stats() RPCStats {
+	return RPCStats{
+		Calls:         atomic.LoadInt64(&t.calls),
+		HTTPFallbacks: atomic.LoadInt64(&t.fallbacks),
+	}
+}
+
+// close 关闭所有连接池
+func (t *rpcTransport) close() {
+	t.mu.Lock()
+	peers := t.peers
+	t.peers = make(map[string]*rpcPeer)
+	t.mu.Unlock()
+
+	for _, peer := range peers {
+		if peer.pool != nil {
+			peer.pool.close()
+		}
+	}
+}
+
+// resolveRPCAddress 监听地址未指定主机（如 ":9001"、"0.0.0.0:9001"）时使用对端HTTP地址的主机
+func resolveRPCAddress(rpcAddress, httpAddress string) string {
+	host, port, err := net.SplitHostPort(rpcAddress)
+	if err != nil {
+		return rpcAddress
+	}
+	if ip := net.ParseIP(host); host != "" && (ip == nil || !ip.IsUnspecified()) {
+		return rpcAddress
+	}
+	httpHost, _, err := net.SplitHostPort(httpAddress)
+	if err != nil || httpHost == "" {
+		httpHost = "localhost"
+	}
+	return net.JoinHostPort(httpHost, port)
+}
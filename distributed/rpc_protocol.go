@@ -0,0 +1,301 @@
+package distributed
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"tdd-learning/core"
+)
+
+// 节点间二进制RPC协议
+//
+// 帧格式（大端）:
+//
+//	| 长度 uint32 | 请求ID uint64 | 类型/状态 uint8 | 负载 |
+//
+// 长度不含自身的4字节。请求帧的第三个字段为请求类型，响应帧为状态，
+// 响应携带与请求相同的请求ID，因此同一连接上可以并发多个请求并乱序返回。
+// 负载中的字符串使用 uvarint 长度前缀，整数使用 varint 编码。
+
+const (
+	rpcFrameHeaderSize = 8 + 1
+	// 帧长度上限比单个value上限多留1MiB给key和其他字段，最大的value也能放进一帧
+	rpcMaxFrameSize = maxBinaryValueSize + 1024*1024
+)
+
+// rpcFrameTooLargeError 帧超过长度上限，发送前检查，不会写到连接上
+type rpcFrameTooLargeError struct {
+	size int
+}
+
+func (e *rpcFrameTooLargeError) Error() string {
+	return fmt.Sprintf("RPC帧过大: %d 字节，上限 %d 字节", e.size, rpcMaxFrameSize)
+}
+
+// checkFrameSize 检查负载能否放进一帧
+func checkFrameSize(payload []byte) error {
+	if size := rpcFrameHeaderSize + len(payload); size > rpcMaxFrameSize {
+		return &rpcFrameTooLargeError{size: size}
+	}
+	return nil
+}
+
+// 请求类型
+const (
+	rpcTypeGet byte = iota + 1
+	rpcTypeSet
+	rpcTypeDelete
+	rpcTypeOp
+	rpcTypeBatchGet
+	rpcTypeBatchSet
+	rpcTypeBatchDelete
+	rpcTypeReplicaGet // 多副本读写，携带版本号和删除标记
+	rpcTypeReplicaSet
+	rpcTypeMigrate // 数据迁移：批量写入带版本的条目，跳过准入策略
+)
+
+// 响应状态
+const (
+	rpcStatusOK       byte = 0
+	rpcStatusError    byte = 1 // 负载为错误信息
+	rpcStatusRedirect byte = 2 // 本节点不负责该key，负载为拓扑版本号、负责节点及其地址
+)
+
+// rpcFrame 协议帧
+type rpcFrame struct {
+	id      uint64
+	kind    byte
+	payload []byte
+}
+
+// writeFrame 写入一帧（调用方负责Flush和并发控制），超过长度上限时不写入
+func writeFrame(w *bufio.Writer, frame rpcFrame) error {
+	if err := checkFrameSize(frame.payload); err != nil {
+		return err
+	}
+	var header [4 + rpcFrameHeaderSize]byte
+	binary.BigEndian.PutUint32(header[0:4], uint32(rpcFrameHeaderSize+len(frame.payload)))
+	binary.BigEndian.PutUint64(header[4:12], frame.id)
+	header[12] = frame.kind
+
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(frame.payload)
+	return err
+}
+
+// readFrame 读取一帧
+func readFrame(r *bufio.Reader) (rpcFrame, error) {
+	var header [4 + rpcFrameHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return rpcFrame{}, err
+	}
+
+	length := binary.BigEndian.Uint32(header[0:4])
+	if length < rpcFrameHeaderSize || length > rpcMaxFrameSize {
+		return rpcFrame{}, fmt.Errorf("非法的RPC帧长度: %d", length)
+	}
+
+	frame := rpcFrame{
+		id:      binary.BigEndian.Uint64(header[4:12]),
+		kind:    header[12],
+		payload: make([]byte, length-rpcFrameHeaderSize),
+	}
+	if _, err := io.ReadFull(r, frame.payload); err != nil {
+		return rpcFrame{}, err
+	}
+	return frame, nil
+}
+
+// ===== 负载编解码 =====
+
+// rpcEncoder 负载编码器
+type rpcEncoder struct {
+	buf []byte
+}
+
+func (e *rpcEncoder) uvarint(v uint64) {
+	e.buf = binary.AppendUvarint(e.buf, v)
+}
+
+func (e *rpcEncoder) varint(v int64) {
+	e.buf = binary.AppendVarint(e.buf, v)
+}
+
+func (e *rpcEncoder) str(s string) {
+	e.uvarint(uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *rpcEncoder) boolean(b bool) {
+	if b {
+		e.buf = append(e.buf, 1)
+	} else {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+// rpcDecoder 负载解码器，出错后后续读取均返回零值，由调用方最后检查 err
+type rpcDecoder struct {
+	buf []byte
+	err error
+}
+
+var errRPCShortPayload = errors.New("RPC负载不完整")
+
+func (d *rpcDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.err = errRPCShortPayload
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *rpcDecoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.buf)
+	if n <= 0 {
+		d.err = errRPCShortPayload
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *rpcDecoder) str() string {
+	length := d.uvarint()
+	if d.err != nil {
+		return ""
+	}
+	if uint64(len(d.buf)) < length {
+		d.err = errRPCShortPayload
+		return ""
+	}
+	s := string(d.buf[:length])
+	d.buf = d.buf[length:]
+	return s
+}
+
+func (d *rpcDecoder) boolean() bool {
+	if d.err != nil {
+		return false
+	}
+	if len(d.buf) == 0 {
+		d.err = errRPCShortPayload
+		return false
+	}
+	b := d.buf[0] != 0
+	d.buf = d.buf[1:]
+	return b
+}
+
+// count 读取元素个数，并按剩余负载长度校验，防止恶意长度导致大量分配
+func (d *rpcDecoder) count() int {
+	n := d.uvarint()
+	if d.err == nil && n > uint64(len(d.buf)) {
+		d.err = errRPCShortPayload
+		return 0
+	}
+	return int(n)
+}
+
+// ===== 消息编解码 =====
+
+func encodeCacheOp(e *rpcEncoder, op CacheOp) {
+	e.str(op.Op)
+	e.str(op.Value)
+	e.uvarint(uint64(op.Flags))
+	e.varint(op.TTLMillis)
+	e.boolean(op.KeepTTL)
+	e.uvarint(op.CAS)
+	e.uvarint(op.Delta)
+	e.varint(op.Amount)
+	e.boolean(op.Expired)
+}
+
+func decodeCacheOp(d *rpcDecoder) CacheOp {
+	return CacheOp{
+		Op:        d.str(),
+		Value:     d.str(),
+		Flags:     uint32(d.uvarint()),
+		TTLMillis: d.varint(),
+		KeepTTL:   d.boolean(),
+		CAS:       d.uvarint(),
+		Delta:     d.uvarint(),
+		Amount:    d.varint(),
+		Expired:   d.boolean(),
+	}
+}
+
+func encodeCacheOpResult(e *rpcEncoder, result CacheOpResult) {
+	e.str(result.Status)
+	e.str(result.Value)
+	e.uvarint(uint64(result.Flags))
+	e.uvarint(result.CAS)
+	e.str(result.NodeID)
+}
+
+// readEpoch 读取单key请求末尾的拓扑版本号，不携带版本号的请求（旧版本节点）返回false
+func (d *rpcDecoder) readEpoch() (uint64, bool) {
+	if d.err != nil || len(d.buf) == 0 {
+		return 0, false
+	}
+	epoch := d.uvarint()
+	return epoch, d.err == nil
+}
+
+func encodeRPCRedirect(redirect *StaleRouteError) []byte {
+	e := &rpcEncoder{}
+	e.uvarint(redirect.Epoch)
+	e.str(redirect.Owner)
+	e.str(redirect.Address)
+	return e.buf
+}
+
+func decodeRPCRedirect(payload []byte) error {
+	d := &rpcDecoder{buf: payload}
+	redirect := &StaleRouteError{Epoch: d.uvarint(), Owner: d.str(), Address: d.str()}
+	if d.err != nil {
+		return &rpcRemoteError{message: "解析重定向失败: " + d.err.Error()}
+	}
+	return redirect
+}
+
+func decodeCacheOpResult(d *rpcDecoder) CacheOpResult {
+	return CacheOpResult{
+		Status: d.str(),
+		Value:  d.str(),
+		Flags:  uint32(d.uvarint()),
+		CAS:    d.uvarint(),
+		NodeID: d.str(),
+	}
+}
+
+func encodeVersionedEntry(e *rpcEncoder, entry core.VersionedEntry) {
+	e.str(entry.Value)
+	e.uvarint(entry.Version)
+	e.boolean(entry.Tombstone)
+	e.uvarint(uint64(entry.Flags))
+	e.varint(entry.ExpiresAt)
+}
+
+func decodeVersionedEntry(d *rpcDecoder) core.VersionedEntry {
+	return core.VersionedEntry{
+		Value:     d.str(),
+		Version:   d.uvarint(),
+		Tombstone: d.boolean(),
+		Flags:     uint32(d.uvarint()),
+		ExpiresAt: d.varint(),
+	}
+}
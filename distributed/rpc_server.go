@@ -0,0 +1,236 @@
+package distributed
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"sync"
+
+	"tdd-learning/core"
+)
+
+// RPCServer 节点间二进制RPC服务端
+// 只操作本地缓存（相当于 /internal/* HTTP接口），同一连接上的请求并发处理、乱序返回。
+type RPCServer struct {
+	node     *DistributedNode
+	listener *tcpListener
+}
+
+// 单个连接上同时处理的最大请求数
+const rpcMaxInflightPerConn = 64
+
+// NewRPCServer 创建RPC服务端
+func NewRPCServer(node *DistributedNode, address string) *RPCServer {
+	rs := &RPCServer{node: node}
+	rs.listener = newTCPListener("RPC", address, rs.serveConn)
+	return rs
+}
+
+// Start 开始监听，并通过 /internal/rpc/info 向其他节点公布RPC地址
+func (rs *RPCServer) Start() error {
+	if err := rs.listener.start(); err != nil {
+		return err
+	}
+	rs.node.setRPCAddress(rs.Addr())
+	return nil
+}
+
+// Addr 实际监听地址
+func (rs *RPCServer) Addr() string {
+	return rs.listener.addr()
+}
+
+// Stop 停止监听并关闭所有连接，其他节点随后回退到HTTP
+func (rs *RPCServer) Stop() {
+	rs.node.setRPCAddress("")
+	rs.listener.stop()
+}
+
+// serveConn 处理单个连接
+func (rs *RPCServer) serveConn(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(conn)
+
+	var writeMu sync.Mutex
+	var inflight sync.WaitGroup
+	slots := make(chan struct{}, rpcMaxInflightPerConn)
+	defer inflight.Wait()
+
+	for {
+		frame, err := readFrame(reader)
+		if err != nil {
+			return
+		}
+
+		slots <- struct{}{}
+		inflight.Add(1)
+		go func(request rpcFrame) {
+			defer inflight.Done()
+			defer func() { <-slots }()
+
+			status, payload := rs.handle(request)
+			if err := checkFrameSize(payload); err != nil {
+				status, payload = rpcStatusError, []byte(err.Error())
+			}
+
+			writeMu.Lock()
+			defer writeMu.Unlock()
+			if writeFrame(writer, rpcFrame{id: request.id, kind: status, payload: payload}) == nil {
+				writer.Flush()
+			}
+		}(frame)
+	}
+}
+
+// handle 执行请求，返回响应状态和负载
+func (rs *RPCServer) handle(request rpcFrame) (byte, []byte) {
+	decoder := &rpcDecoder{buf: request.payload}
+	encoder := &rpcEncoder{}
+
+	switch request.kind {
+	case rpcTypeGet:
+		key := decoder.str()
+		if redirect := rs.checkRoute(key, decoder); redirect != nil {
+			return rpcStatusRedirect, encodeRPCRedirect(redirect)
+		}
+		if decoder.err != nil {
+			break
+		}
+		value, found := rs.node.GetLocal(key)
+		encoder.boolean(found)
+		encoder.str(value)
+
+	case rpcTypeSet:
+		key, value := decoder.str(), decoder.str()
+		if redirect := rs.checkRoute(key, decoder); redirect != nil {
+			return rpcStatusRedirect, encodeRPCRedirect(redirect)
+		}
+		if decoder.err != nil {
+			break
+		}
+		if err := rs.node.SetLocal(key, value); err != nil {
+			return rpcStatusError, []byte(err.Error())
+		}
+
+	case rpcTypeDelete:
+		key := decoder.str()
+		if redirect := rs.checkRoute(key, decoder); redirect != nil {
+			return rpcStatusRedirect, encodeRPCRedirect(redirect)
+		}
+		if decoder.err != nil {
+			break
+		}
+		rs.node.DeleteLocal(key)
+
+	case rpcTypeOp:
+		key := decoder.str()
+		op := decodeCacheOp(decoder)
+		if redirect := rs.checkRoute(key, decoder); redirect != nil {
+			return rpcStatusRedirect, encodeRPCRedirect(redirect)
+		}
+		if decoder.err != nil {
+			break
+		}
+		result, err := rs.node.ExecuteLocal(key, op)
+		if err != nil {
+			return rpcStatusError, []byte(err.Error())
+		}
+		encodeCacheOpResult(encoder, result)
+
+	case rpcTypeBatchGet:
+		count := decoder.count()
+		keys := make([]string, 0, count)
+		for i := 0; i < count; i++ {
+			keys = append(keys, decoder.str())
+		}
+		if decoder.err != nil {
+			break
+		}
+		for _, key := range keys {
+			value, found := rs.node.GetLocal(key)
+			encoder.boolean(found)
+			encoder.str(value)
+		}
+
+	case rpcTypeBatchSet:
+		count := decoder.count()
+		data := make(map[string]string, count)
+		for i := 0; i < count; i++ {
+			key := decoder.str()
+			data[key] = decoder.str()
+		}
+		if decoder.err != nil {
+			break
+		}
+		for key, value := range data {
+			if err := rs.node.SetLocal(key, value); err != nil {
+				return rpcStatusError, []byte(err.Error())
+			}
+		}
+
+	case rpcTypeMigrate:
+		count := decoder.count()
+		entries := make(map[string]core.VersionedEntry, count)
+		for i := 0; i < count; i++ {
+			key := decoder.str()
+			entries[key] = decodeVersionedEntry(decoder)
+		}
+		if decoder.err != nil {
+			break
+		}
+		for key, entry := range entries {
+			if err := rs.node.MigrateLocal(key, entry); err != nil {
+				return rpcStatusError, []byte(err.Error())
+			}
+		}
+
+	case rpcTypeBatchDelete:
+		count := decoder.count()
+		keys := make([]string, 0, count)
+		for i := 0; i < count; i++ {
+			keys = append(keys, decoder.str())
+		}
+		if decoder.err != nil {
+			break
+		}
+		for _, key := range keys {
+			rs.node.DeleteLocal(key)
+		}
+
+	case rpcTypeReplicaGet:
+		key := decoder.str()
+		if decoder.err != nil {
+			break
+		}
+		entry, found := rs.node.GetLocalVersioned(key)
+		encoder.boolean(found)
+		encodeVersionedEntry(encoder, entry)
+
+	case rpcTypeReplicaSet:
+		key := decoder.str()
+		entry := decodeVersionedEntry(decoder)
+		if decoder.err != nil {
+			break
+		}
+		if err := rs.node.SetLocalVersioned(key, entry); err != nil {
+			return rpcStatusError, []byte(err.Error())
+		}
+
+	default:
+		return rpcStatusError, []byte(fmt.Sprintf("未知的RPC请求类型: %d", request.kind))
+	}
+
+	if decoder.err != nil {
+		return rpcStatusError, []byte(decoder.err.Error())
+	}
+	return rpcStatusOK, encoder.buf
+}
+
+// checkRoute 单key请求携带拓扑版本号时检查是否应由本节点处理
+func (rs *RPCServer) checkRoute(key string, decoder *rpcDecoder) *StaleRouteError {
+	epoch, ok := decoder.readEpoch()
+	if !ok {
+		return nil
+	}
+	return rs.node.checkRoute(key, epoch)
+}
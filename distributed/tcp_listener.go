@@ -0,0 +1,127 @@
+package distributed
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// tcpListener 文本协议监听器（memcached / RESP）的公共部分
+// 负责接受连接、跟踪活动连接，关闭时断开所有连接并等待处理协程退出。
+type tcpListener struct {
+	name     string
+	address  string
+	listener net.Listener
+	serve    func(conn net.Conn) // 处理单个连接，返回后连接被关闭
+
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+
+	currConnections  int64
+	totalConnections int64
+}
+
+func newTCPListener(name, address string, serve func(conn net.Conn)) *tcpListener {
+	return &tcpListener{
+		name:    name,
+		address: address,
+		serve:   serve,
+		conns:   make(map[net.Conn]struct{}),
+	}
+}
+
+// start 开始监听，连接在后台协程中处理
+func (l *tcpListener) start() error {
+	listener, err := net.Listen("tcp", l.address)
+	if err != nil {
+		return fmt.Errorf("%s监听失败: %v", l.name, err)
+	}
+	l.listener = listener
+
+	l.wg.Add(1)
+	go l.acceptLoop()
+
+	log.Printf("📡 %s协议监听地址: %s", l.name, listener.Addr())
+	return nil
+}
+
+// addr 实际监听地址
+func (l *tcpListener) addr() string {
+	if l.listener == nil {
+		return l.address
+	}
+	return l.listener.Addr().String()
+}
+
+// stop 停止监听并关闭所有连接
+func (l *tcpListener) stop() {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return
+	}
+	l.closed = true
+	if l.listener != nil {
+		l.listener.Close()
+	}
+	for conn := range l.conns {
+		conn.Close()
+	}
+	l.mu.Unlock()
+
+	l.wg.Wait()
+}
+
+// acceptLoop 接受连接
+func (l *tcpListener) acceptLoop() {
+	defer l.wg.Done()
+
+	for {
+		conn, err := l.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("⚠️ %s接受连接失败: %v", l.name, err)
+			continue
+		}
+
+		l.mu.Lock()
+		if l.closed {
+			l.mu.Unlock()
+			conn.Close()
+			return
+		}
+		l.conns[conn] = struct{}{}
+		l.mu.Unlock()
+
+		l.wg.Add(1)
+		go l.handle(conn)
+	}
+}
+
+// handle 处理连接并在结束后清理
+func (l *tcpListener) handle(conn net.Conn) {
+	defer l.wg.Done()
+	defer func() {
+		l.mu.Lock()
+		delete(l.conns, conn)
+		l.mu.Unlock()
+		conn.Close()
+		atomic.AddInt64(&l.currConnections, -1)
+	}()
+	atomic.AddInt64(&l.currConnections, 1)
+	atomic.AddInt64(&l.totalConnections, 1)
+
+	l.serve(conn)
+}
+
+// connectionStats 当前连接数与累计连接数
+func (l *tcpListener) connectionStats() (current, total int64) {
+	return atomic.LoadInt64(&l.currConnections), atomic.LoadInt64(&l.totalConnections)
+}
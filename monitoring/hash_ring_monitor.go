@@ -30,6 +30,8 @@ type HashRingSnapshot struct {
 	DataDistribution map[string]DataLocationInfo `json:"data_distribution"`
 	RingSize        uint32                       `json:"ring_size"`
 	LoadBalance     LoadBalanceInfo              `json:"load_balance"`
+	BoundedLoad     *BoundedLoadInfo             `json:"bounded_load,omitempty"` // 仅开启有界负载时存在
+	ZoneDistribution map[string]ZoneInfo         `json:"zone_distribution,omitempty"` // 仅节点标注了可用区时存在
 }
 
 // NodeInfo 节点信息
@@ -69,6 +71,25 @@ type LoadBalanceInfo struct {
 	Variance     float64 `json:"variance"`
 	BalanceScore float64 `json:"balance_score"` // 0-100，100表示完全均衡
 }
+
+// ZoneInfo 可用区分布信息，未标注可用区的节点归入 "unlabeled"
+type ZoneInfo struct {
+	Zone         string   `json:"zone"`
+	Nodes        []string `json:"nodes"`
+	VirtualNodes int      `json:"virtual_nodes"`
+	DataCount    int      `json:"data_count"`
+	RingShare    float64  `json:"ring_share"` // 该区虚拟节点覆盖的哈希空间比例 (0-1)
+}
+
+// BoundedLoadInfo 有界负载信息，按各节点上报的负载计算实际均衡程度
+type BoundedLoadInfo struct {
+	Epsilon          float64            `json:"epsilon"`
+	Loads            map[string]float64 `json:"loads"`
+	Capacities       map[string]float64 `json:"capacities"`
+	MaxLoadRatio     float64            `json:"max_load_ratio"`    // 不超过 1+ε 说明上限生效
+	Redirects        uint64             `json:"redirects"`         // 主节点超载而顺延的查找次数
+	EffectiveBalance float64            `json:"effective_balance"` // 0-100，按上报负载计算的均衡分数
+}
 //监控
 
 //监控
@@ -115,6 +136,9 @@ func (hrm *HashRingMonitor) CaptureSnapshot() *HashRingSnapshot {
 	hrm.mu.Lock()
 	defer hrm.mu.Unlock()
 
+	// 有界负载统计自行加锁，需在获取读锁之前读取
+	boundedLoad := hrm.calculateBoundedLoad()
+
 	// 获取分布式缓存的读锁
 	hrm.distributedCache.Mu.RLock()
 	defer hrm.distributedCache.Mu.RUnlock()
@@ -126,6 +150,8 @@ func (hrm *HashRingMonitor) CaptureSnapshot() *HashRingSnapshot {
 		DataDistribution: hrm.extractDataDistribution(),
 		RingSize:        hrm.calculateRingSize(),
 		LoadBalance:     hrm.calculateLoadBalance(),
+		BoundedLoad:     boundedLoad,
+		ZoneDistribution: hrm.extractZoneDistribution(),
 	}
 
 	// 添加到快照历史
@@ -189,6 +215,56 @@ func (hrm *HashRingMonitor) extractNodeInfo() []NodeInfo {
 	return result
 }
 
+// extractZoneDistribution 按可用区汇总节点、虚拟节点、数据量和哈希空间占比
+func (hrm *HashRingMonitor) extractZoneDistribution() map[string]ZoneInfo {
+	dc := hrm.distributedCache
+	if len(dc.Locations) == 0 {
+		return nil
+	}
+
+	zoneOf := func(node string) string {
+		if zone := dc.Locations[node].Zone; zone != "" {
+			return zone
+		}
+		return "unlabeled"
+	}
+
+	zones := make(map[string]ZoneInfo)
+	for _, node := range dc.Nodes {
+		zone := zoneOf(node)
+		info := zones[zone]
+		info.Zone = zone
+		info.Nodes = append(info.Nodes, node)
+		if cache := dc.LocalCaches[node]; cache != nil {
+			info.DataCount += cache.Size()
+		}
+		zones[zone] = info
+	}
+
+	// 每个虚拟节点负责从前一个虚拟节点到自身的区间
+	for i, hash := range dc.SortedHashes {
+		zone := zoneOf(dc.HashRing[hash])
+		info := zones[zone]
+		info.VirtualNodes++
+		prev := dc.SortedHashes[(i+len(dc.SortedHashes)-1)%len(dc.SortedHashes)]
+		info.RingShare += float64(hash-prev) / float64(1<<32)
+		zones[zone] = info
+	}
+	if len(dc.SortedHashes) == 1 {
+		// 只有一个虚拟节点时覆盖整个环
+		zone := zoneOf(dc.HashRing[dc.SortedHashes[0]])
+		info := zones[zone]
+		info.RingShare = 1
+		zones[zone] = info
+	}
+
+	for zone, info := range zones {
+		sort.Strings(info.Nodes)
+		zones[zone] = info
+	}
+	return zones
+}
+
 // extractVirtualNodeInfo 提取虚拟节点信息
 func (hrm *HashRingMonitor) extractVirtualNodeInfo() []VirtualNodeInfo {
 	var virtualNodes []VirtualNodeInfo
@@ -301,6 +377,38 @@ func (hrm *HashRingMonitor) calculateLoadBalance() LoadBalanceInfo {
 	}
 }
 
+// calculateBoundedLoad 计算有界负载信息，未开启时返回nil
+func (hrm *HashRingMonitor) calculateBoundedLoad() *BoundedLoadInfo {
+	stats := hrm.distributedCache.GetBoundedLoadStats()
+	if !stats.Enabled {
+		return nil
+	}
+
+	info := &BoundedLoadInfo{
+		Epsilon:          stats.Epsilon,
+		Loads:            stats.Loads,
+		Capacities:       stats.Capacities,
+		MaxLoadRatio:     stats.MaxLoadRatio,
+		Redirects:        stats.Redirects,
+		EffectiveBalance: 100.0,
+	}
+
+	maxLoad, minLoad, first := 0.0, 0.0, true
+	for _, load := range stats.Loads {
+		if first || load > maxLoad {
+			maxLoad = load
+		}
+		if first || load < minLoad {
+			minLoad = load
+		}
+		first = false
+	}
+	if maxLoad > 0 {
+		info.EffectiveBalance = (1.0 - (maxLoad-minLoad)/maxLoad) * 100
+	}
+	return info
+}
+
 // hashToPosition 将hash值转换为环上的位置（角度）
 func (hrm *HashRingMonitor) hashToPosition(hash uint32) float64 {
 	// 将32位hash值映射到0-360度
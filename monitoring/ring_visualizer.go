@@ -173,7 +173,26 @@ func (rv *RingVisualizer) renderDetails(snapshot *HashRingSnapshot, config Visua
 		lb.MaxLoad, lb.MinLoad, lb.AvgLoad))
 	output.WriteString(fmt.Sprintf("  方差: %.2f, 均衡分数: %.1f/100\n", 
 		lb.Variance, lb.BalanceScore))
+	if bl := snapshot.BoundedLoad; bl != nil {
+		output.WriteString(fmt.Sprintf("  有界负载: ε=%.2f, 最大负载比=%.2f, 顺延次数=%d, 实际均衡分数: %.1f/100\n",
+			bl.Epsilon, bl.MaxLoadRatio, bl.Redirects, bl.EffectiveBalance))
+	}
 	
+	// 可用区分布
+	if len(snapshot.ZoneDistribution) > 0 {
+		output.WriteString("\n🌍 可用区分布:\n")
+		zones := make([]string, 0, len(snapshot.ZoneDistribution))
+		for zone := range snapshot.ZoneDistribution {
+			zones = append(zones, zone)
+		}
+		sort.Strings(zones)
+		for _, zone := range zones {
+			info := snapshot.ZoneDistribution[zone]
+			output.WriteString(fmt.Sprintf("  • %s: 节点=%v, 虚拟节点=%d个, 数据=%d个, 哈希空间=%.1f%%\n",
+				zone, info.Nodes, info.VirtualNodes, info.DataCount, info.RingShare*100))
+		}
+	}
+
 	// 数据分布（如果启用）
 	if config.ShowDataKeys && len(snapshot.DataDistribution) > 0 {
 		output.WriteString("\n📍 数据分布:\n")
@@ -0,0 +1,208 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+
+	"tdd-learning/core"
+	"tdd-learning/distributed"
+)
+
+// TestTinyLFUAdmissionProtectsHotKeys 测试一次性扫描不会冲刷热点数据
+func TestTinyLFUAdmissionProtectsHotKeys(t *testing.T) {
+	plain := core.NewLRUCache(100)
+	filtered := core.NewLRUCacheWithAdmission(100, 1000)
+
+	for _, cache := range []*core.LRUCache{plain, filtered} {
+		// 热点key被反复访问
+		for round := 0; round < 5; round++ {
+			for i := 0; i < 100; i++ {
+				key := fmt.Sprintf("hot:%d", i)
+				if _, found := cache.Get(key); !found {
+					cache.Set(key, "v")
+				}
+			}
+		}
+		// 爬虫式扫描：每个key只出现一次
+		for i := 0; i < 1000; i++ {
+			cache.Set(fmt.Sprintf("scan:%d", i), "v")
+		}
+	}
+
+	countHot := func(cache *core.LRUCache) int {
+		hits := 0
+		for i := 0; i < 100; i++ {
+			if _, found := cache.Get(fmt.Sprintf("hot:%d", i)); found {
+				hits++
+			}
+		}
+		return hits
+	}
+
+	plainHot, filteredHot := countHot(plain), countHot(filtered)
+	t.Logf("📊 扫描后保留的热点key: LRU=%d, TinyLFU=%d", plainHot, filteredHot)
+
+	if plainHot != 0 {
+		t.Errorf("❌ 普通LRU应被扫描完全冲刷，实际保留%d个", plainHot)
+	}
+	if filteredHot < 90 {
+		t.Errorf("❌ TinyLFU应保留绝大部分热点key，实际保留%d个", filteredHot)
+	}
+
+	stats, enabled := filtered.GetAdmissionStats()
+	if !enabled {
+		t.Fatal("❌ 准入策略应已启用")
+	}
+	if stats.Rejected < 900 {
+		t.Errorf("❌ 拒绝次数过少: %d", stats.Rejected)
+	}
+	if _, enabled := plain.GetAdmissionStats(); enabled {
+		t.Error("❌ 普通LRU不应启用准入策略")
+	}
+}
+
+// TestTinyLFUAdmitsFrequentNewKeys 测试新key先进入准入窗口，离开窗口时访问足够频繁才会被接纳
+func TestTinyLFUAdmitsFrequentNewKeys(t *testing.T) {
+	// 容量2：窗口1个，主缓存1个（Inspect不计入访问频率）
+	cache := core.NewLRUCacheWithAdmission(2, 100)
+	cache.Set("a", "1")
+	cache.Set("b", "2")
+
+	// c进入窗口，b离开窗口时频率不高于a，被淘汰
+	cache.Set("c", "3")
+	if _, found := cache.Inspect("c"); !found {
+		t.Error("❌ 新key应先进入准入窗口")
+	}
+	if _, found := cache.Inspect("b"); found {
+		t.Error("❌ 低频key离开窗口时应被淘汰")
+	}
+	if _, found := cache.Inspect("a"); !found {
+		t.Error("❌ 主缓存中的a不应被低频key挤出")
+	}
+
+	// b多次访问后频率超过a，离开窗口时被接纳
+	for i := 0; i < 5; i++ {
+		cache.Get("b")
+	}
+	cache.Set("b", "2")
+	cache.Set("d", "4")
+	if _, found := cache.Inspect("b"); !found {
+		t.Error("❌ 高频key离开窗口时应被接纳")
+	}
+	if _, found := cache.Inspect("a"); found {
+		t.Error("❌ a应被高频key替换")
+	}
+
+	stats, _ := cache.GetAdmissionStats()
+	if stats.Admitted != 1 || stats.Rejected != 2 {
+		t.Errorf("❌ 准入统计错误: %+v", stats)
+	}
+}
+
+// TestTinyLFUAdmitsShiftingWorkload 测试热点迁移后新的热点key会逐步被接纳
+func TestTinyLFUAdmitsShiftingWorkload(t *testing.T) {
+	cache := core.NewLRUCacheWithAdmission(100, 1000)
+	access := func(prefix string, rounds int) {
+		for round := 0; round < rounds; round++ {
+			for i := 0; i < 100; i++ {
+				key := fmt.Sprintf("%s:%d", prefix, i)
+				if _, found := cache.Get(key); !found {
+					cache.Set(key, "v")
+				}
+			}
+		}
+	}
+	countResident := func(prefix string) int {
+		count := 0
+		for i := 0; i < 100; i++ {
+			if _, found := cache.Inspect(fmt.Sprintf("%s:%d", prefix, i)); found {
+				count++
+			}
+		}
+		return count
+	}
+
+	// 旧热点访问频率达到上限
+	access("old", 20)
+	// 访问模式整体切换到新的一组key，频率衰减后新热点应取代旧热点
+	access("new", 200)
+
+	newResident, oldResident := countResident("new"), countResident("old")
+	t.Logf("📊 热点迁移后驻留的key: 新热点=%d, 旧热点=%d", newResident, oldResident)
+	if newResident < 90 {
+		t.Errorf("❌ 新热点应被接纳，实际驻留%d个", newResident)
+	}
+	stats, _ := cache.GetAdmissionStats()
+	if stats.Admitted < 90 {
+		t.Errorf("❌ 接纳次数过少: %+v", stats)
+	}
+}
+
+// TestNodeConfigAdmissionPolicy 测试通过节点配置启用准入策略
+func TestNodeConfigAdmissionPolicy(t *testing.T) {
+	node := distributed.NewDistributedNode(distributed.NodeConfig{
+		NodeID:          "node1",
+		ClusterNodes:    map[string]string{"node1": "localhost:9101"},
+		CacheSize:       10,
+		AdmissionPolicy: distributed.AdmissionPolicyTinyLFU,
+	})
+
+	for i := 0; i < 20; i++ {
+		node.Set(fmt.Sprintf("key:%d", i), "v")
+	}
+
+	stats := node.GetLocalStats()
+	if _, ok := stats["admission_Rejected"]; !ok {
+		t.Fatalf("❌ 统计信息缺少准入数据: %+v", stats)
+	}
+	t.Logf("📊 节点统计: %+v", stats)
+}
+
+// TestAdmissionBypassAndRejectedWrites 测试迁移写入跳过准入策略，被拒绝的写入返回错误
+func TestAdmissionBypassAndRejectedWrites(t *testing.T) {
+	cache := core.NewLRUCacheWithAdmission(100, 1000)
+	for round := 0; round < 5; round++ {
+		for i := 0; i < 100; i++ {
+			key := fmt.Sprintf("hot:%d", i)
+			if _, found := cache.Get(key); !found {
+				cache.Set(key, "v")
+			}
+		}
+	}
+
+	// 迁移来的key在本节点没有访问记录，跳过准入后全部驻留
+	for i := 0; i < 50; i++ {
+		if !cache.SetBypassAdmission(fmt.Sprintf("migrated:%d", i), "v") {
+			t.Fatalf("❌ 迁移写入被拒绝: migrated:%d", i)
+		}
+	}
+	for i := 0; i < 50; i++ {
+		if _, found := cache.Inspect(fmt.Sprintf("migrated:%d", i)); !found {
+			t.Errorf("❌ 迁移的key不应被准入策略淘汰: migrated:%d", i)
+		}
+	}
+	t.Log("✅ 迁移写入跳过准入策略")
+
+	// 超出内存限制的写入返回错误，而不是静默丢弃
+	node := distributed.NewDistributedNode(distributed.NodeConfig{
+		NodeID:          "node1",
+		ClusterNodes:    map[string]string{"node1": "localhost:9101"},
+		CacheSize:       10,
+		MemoryLimit:     128,
+		AdmissionPolicy: distributed.AdmissionPolicyTinyLFU,
+	})
+	large := string(make([]byte, 256))
+	if err := node.Set("large", large); err == nil {
+		t.Error("❌ 超出内存限制的写入应返回错误")
+	}
+	if err := node.SetLocal("large", large); err == nil {
+		t.Error("❌ 超出内存限制的本地写入应返回错误")
+	}
+	if err := node.MigrateLocal("large", core.VersionedEntry{Value: large, Version: 1}); err == nil {
+		t.Error("❌ 超出内存限制的迁移写入应返回错误")
+	}
+	if err := node.Set("small", "v"); err != nil {
+		t.Errorf("❌ 正常写入失败: %v", err)
+	}
+	t.Log("✅ 被拒绝的写入返回错误")
+}
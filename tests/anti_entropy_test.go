@@ -0,0 +1,120 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"tdd-learning/core"
+	"tdd-learning/distributed"
+)
+
+// TestMerkleTreeDiff 测试Merkle树与加入顺序无关，并能定位不同的叶子区间
+func TestMerkleTreeDiff(t *testing.T) {
+	ring := core.NewDistributedCacheWithVirtualNodes([]string{"node1"}, 10)
+	keys := make([]string, 200)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("merkle:%d", i)
+	}
+
+	build := func(order []string, changed string) *core.MerkleTree {
+		tree := core.NewMerkleTree(core.DefaultMerkleLeaves)
+		for _, key := range order {
+			entry := core.VersionedEntry{Version: 1}
+			if key == changed {
+				entry.Version = 2
+			}
+			tree.Add(ring.HashKey(key), key, entry)
+		}
+		tree.Build()
+		return tree
+	}
+
+	reversed := make([]string, len(keys))
+	for i, key := range keys {
+		reversed[len(keys)-1-i] = key
+	}
+	a, b := build(keys, ""), build(reversed, "")
+	if a.Root() == 0 || a.Root() != b.Root() || len(a.Diff(b)) != 0 {
+		t.Fatalf("❌ 相同条目的Merkle树应一致: %x %x", a.Root(), b.Root())
+	}
+
+	changed := build(keys, "merkle:42")
+	diff := a.Diff(changed)
+	hash := ring.HashKey("merkle:42")
+	if len(diff) != 1 || diff[0] != a.LeafFor(hash) {
+		t.Fatalf("❌ 应只有一个叶子不同: %v", diff)
+	}
+	if start, end := a.LeafRange(diff[0]); hash < start || hash > end {
+		t.Errorf("❌ 叶子区间 [%d, %d] 应包含哈希值 %d", start, end, hash)
+	}
+	t.Log("✅ Merkle树对比测试通过")
+}
+
+// TestAntiEntropyRepairsReplicas 测试反熵修复按版本同步两个副本上不一致的key
+func TestAntiEntropyRepairsReplicas(t *testing.T) {
+	cluster := startTestNodes(t, []string{"node1", "node2"}, func(config *distributed.NodeConfig) {
+		config.ReplicationFactor = 2
+	})
+	node1, node2 := cluster.Node("node1"), cluster.Node("node2")
+
+	base := uint64(time.Now().UnixNano())
+	entry := func(value string, offset uint64) core.VersionedEntry {
+		return core.VersionedEntry{Value: value, Version: base + offset}
+	}
+	node1.SetLocalVersioned("only-node1", entry("a", 1))
+	node1.SetLocalVersioned("newer-node2", entry("old", 1))
+	node2.SetLocalVersioned("newer-node2", entry("new", 2))
+	node2.SetLocalVersioned("deleted-node1", entry("stale", 1))
+	node1.SetLocalVersioned("deleted-node1", core.VersionedEntry{Version: base + 2, Tombstone: true})
+	for _, node := range []*distributed.DistributedNode{node1, node2} {
+		node.SetLocalVersioned("same", entry("same", 1))
+	}
+
+	report := node1.RunAntiEntropy()
+	t.Logf("📊 反熵修复: %+v", report)
+	if len(report.Errors) != 0 || report.DifferingRanges == 0 || report.Discrepancies != 3 ||
+		report.KeysPushed != 2 || report.KeysPulled != 1 {
+		t.Fatalf("❌ 修复结果错误: %+v", report)
+	}
+
+	if value, found := node2.GetLocal("only-node1"); !found || value != "a" {
+		t.Errorf("❌ 缺失的副本应被推送: %q %v", value, found)
+	}
+	if value, found := node1.GetLocal("newer-node2"); !found || value != "new" {
+		t.Errorf("❌ 较旧的副本应被更新: %q %v", value, found)
+	}
+	if _, found := node2.GetLocal("deleted-node1"); found {
+		t.Error("❌ 删除标记应同步到对端")
+	}
+
+	// 修复后Merkle树一致，通过管理接口再执行一轮不应发现差异
+	resp, err := http.Post(cluster.URL("node1")+"/admin/anti-entropy", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body struct {
+		Report distributed.AntiEntropyReport `json:"report"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	resp.Body.Close()
+	if body.Report.PeersCompared != 1 || body.Report.DifferingRanges != 0 {
+		t.Errorf("❌ 修复后不应再有差异: %+v", body.Report)
+	}
+
+	resp, err = http.Get(cluster.URL("node1") + "/admin/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var metrics struct {
+		AntiEntropy distributed.AntiEntropyStats `json:"anti_entropy"`
+	}
+	json.NewDecoder(resp.Body).Decode(&metrics)
+	resp.Body.Close()
+	if metrics.AntiEntropy.Rounds != 2 || metrics.AntiEntropy.Discrepancies != 3 {
+		t.Errorf("❌ 反熵统计错误: %+v", metrics.AntiEntropy)
+	}
+	t.Log("✅ 反熵修复测试通过")
+}
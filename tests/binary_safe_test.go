@@ -0,0 +1,191 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"tdd-learning/core"
+	"tdd-learning/distributed"
+)
+
+// binaryKeys 会破坏URL路由或JSON编码的key
+var binaryKeys = []string{
+	"path/with/slash",
+	"query?x=1&y=2",
+	"percent%2Fencoded",
+	"space and #hash",
+	"\xff\xfe\x00binary",
+	"中文key",
+}
+
+// binaryValue 包含NUL和非UTF-8字节的value
+func binaryValue(key string) string {
+	return "\x00\xff\xfe" + key + "\r\n\x80"
+}
+
+// TestEncodeKeyRoundTrip 测试key编码
+func TestEncodeKeyRoundTrip(t *testing.T) {
+	for _, key := range binaryKeys {
+		encoded := core.EncodeKey(key)
+		if strings.ContainsAny(encoded, "/?%#+=") {
+			t.Errorf("❌ 编码结果不能直接用于URL: %q", encoded)
+		}
+		decoded, err := core.DecodeKey(encoded)
+		if err != nil || decoded != key {
+			t.Errorf("❌ 解码失败: %q -> %q (%v)", key, decoded, err)
+		}
+	}
+
+	// 兼容带填充的写法
+	if decoded, err := core.DecodeKey("YQ=="); err != nil || decoded != "a" {
+		t.Errorf("❌ 带填充的key解码失败: %q %v", decoded, err)
+	}
+	if _, err := core.DecodeKey("not base64!"); err == nil {
+		t.Error("❌ 非法编码应返回错误")
+	}
+	t.Log("✅ key编码测试通过")
+}
+
+// TestCacheServerBinaryAPI 测试core.CacheServer的 /bin/ 接口
+func TestCacheServerBinaryAPI(t *testing.T) {
+	server := httptest.NewServer(core.NewCacheServer(core.NewLRUCache(100)))
+	defer server.Close()
+
+	for _, key := range binaryKeys {
+		url := server.URL + "/bin/" + core.EncodeKey(key)
+		value := binaryValue(key)
+
+		req, _ := http.NewRequest(http.MethodPut, url, strings.NewReader(value))
+		req.Header.Set("Content-Type", "application/octet-stream")
+		if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("❌ PUT失败: %q %v", key, err)
+		}
+
+		resp, err := http.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || string(body) != value {
+			t.Errorf("❌ GET结果错误: %q => %d %q", key, resp.StatusCode, body)
+		}
+
+		req, _ = http.NewRequest(http.MethodDelete, url, nil)
+		if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusOK {
+			t.Errorf("❌ DELETE失败: %q %v", key, err)
+		}
+		if resp, _ := http.Get(url); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("❌ 删除后应返回404: %d", resp.StatusCode)
+		}
+	}
+
+	if resp, _ := http.Get(server.URL + "/bin/!!!"); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("❌ 非法key应返回400: %d", resp.StatusCode)
+	}
+	t.Log("✅ CacheServer二进制接口测试通过")
+}
+
+// TestBinarySafeClusterRoundTrip 测试二进制key/value经客户端、HTTP转发和条件操作后保持不变
+func TestBinarySafeClusterRoundTrip(t *testing.T) {
+	cluster := startTestNodes(t, []string{"node1", "node2"}, nil)
+
+	// 只连接node1，非本地key通过HTTP转发（未启用RPC）
+	client := distributed.NewDistributedClient(distributed.ClientConfig{
+		Nodes:      []string{cluster.addrs["node1"]},
+		Timeout:    5 * time.Second,
+		RetryCount: 1,
+	})
+	defer client.Close()
+
+	for _, key := range binaryKeys {
+		value := binaryValue(key)
+		if err := client.SetBytes([]byte(key), []byte(value)); err != nil {
+			t.Fatalf("❌ SetBytes失败: %q %v", key, err)
+		}
+
+		owner := cluster.Node("node1").GetNodeForKey(key)
+		if stored, found := cluster.Node(owner).GetLocal(key); !found || stored != value {
+			t.Errorf("❌ 负责节点 %s 中的数据错误: %q => %q", owner, key, stored)
+		}
+
+		got, found, err := client.GetBytes([]byte(key))
+		if err != nil || !found || !bytes.Equal(got, []byte(value)) {
+			t.Errorf("❌ GetBytes结果错误: %q => %q %v %v", key, got, found, err)
+		}
+	}
+
+	// 条件操作的HTTP转发同样二进制安全
+	remote := keyOwnedBy(t, []string{"node1", "node2"}, "node2", "\xffop/")
+	result, err := cluster.Node("node1").Execute(remote, distributed.CacheOp{Op: distributed.OpSet, Value: "\x00\xff"})
+	if err != nil || result.Status != distributed.OpStatusStored || result.Value != "\x00\xff" {
+		t.Errorf("❌ 条件操作转发错误: %+v %v", result, err)
+	}
+	if stored, _ := cluster.Node("node2").GetLocal(remote); stored != "\x00\xff" {
+		t.Errorf("❌ 条件操作写入的数据错误: %q", stored)
+	}
+	inspection, err := cluster.Node("node1").InspectKey(remote)
+	if err != nil || !inspection.Found || inspection.Key != remote {
+		t.Errorf("❌ 远端key元数据查询错误: %+v %v", inspection, err)
+	}
+
+	for _, key := range binaryKeys {
+		if err := client.DeleteBytes([]byte(key)); err != nil {
+			t.Errorf("❌ DeleteBytes失败: %q %v", key, err)
+		}
+		if _, found, _ := client.GetBytes([]byte(key)); found {
+			t.Errorf("❌ 删除后仍能读取: %q", key)
+		}
+	}
+	t.Log("✅ 集群二进制数据往返测试通过")
+}
+
+// TestBinarySafeMigration 测试节点加入时二进制key/value通过HTTP迁移
+func TestBinarySafeMigration(t *testing.T) {
+	cluster := startTestNodes(t, []string{"node1", "node2"}, func(config *distributed.NodeConfig) {
+		if config.NodeID == "node1" {
+			// node1 初始时只知道自己，所有数据都存放在本地
+			config.ClusterNodes = map[string]string{"node1": config.ClusterNodes["node1"]}
+		}
+	})
+	node1 := cluster.Node("node1")
+
+	data := make(map[string]string)
+	for i := 0; i < 50; i++ {
+		key := fmt.Sprintf("\xff/mig?%d", i)
+		data[key] = binaryValue(key)
+		node1.Set(key, data[key])
+	}
+
+	body := fmt.Sprintf(`{"node_id":"node2","address":%q}`, cluster.addrs["node2"])
+	resp, err := http.Post(cluster.URL("node1")+"/internal/cluster/join", "application/json", strings.NewReader(body))
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("❌ 节点加入失败: %v", err)
+	}
+	resp.Body.Close()
+
+	migrated := 0
+	for key, value := range data {
+		if node1.GetNodeForKey(key) != "node2" {
+			continue
+		}
+		migrated++
+		if stored, found := cluster.Node("node2").GetLocal(key); !found || stored != value {
+			t.Errorf("❌ 迁移后数据错误: %q => %q", key, stored)
+		}
+		if _, found := node1.GetLocal(key); found {
+			t.Errorf("❌ 迁移后源节点仍保留数据: %q", key)
+		}
+	}
+	if migrated == 0 {
+		t.Fatal("❌ 没有key需要迁移，测试数据无效")
+	}
+	t.Logf("📊 迁移了 %d 个二进制key", migrated)
+	t.Log("✅ 二进制数据迁移测试通过")
+}
@@ -0,0 +1,195 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"testing"
+
+	"tdd-learning/core"
+	"tdd-learning/distributed"
+	"tdd-learning/monitoring"
+)
+
+// TestBoundedLoadSkipsOverloadedNode 测试超载节点被跳过，其他节点的key归属不变
+func TestBoundedLoadSkipsOverloadedNode(t *testing.T) {
+	nodes := []string{"node1", "node2", "node3"}
+	dc := core.NewDistributedCacheWithVirtualNodes(nodes, 150)
+
+	before := make(map[string]string)
+	for i := 0; i < 3000; i++ {
+		key := fmt.Sprintf("key:%d", i)
+		before[key] = dc.GetNodeForKey(key)
+	}
+
+	if err := dc.EnableBoundedLoad(0.25); err != nil {
+		t.Fatalf("❌ 开启有界负载失败: %v", err)
+	}
+	// 未上报负载时与普通哈希环一致
+	for key, owner := range before {
+		if dc.GetNodeForKey(key) != owner {
+			t.Fatalf("❌ 无负载数据时key归属不应变化: %s", key)
+		}
+	}
+
+	// 平均负载400，容量上限 ceil(1.25×400)=500，node1 超载
+	dc.ReportNodeLoad("node1", 1000)
+	dc.ReportNodeLoad("node2", 100)
+	dc.ReportNodeLoad("node3", 100)
+
+	redirected := 0
+	for key, owner := range before {
+		newOwner := dc.GetNodeForKey(key)
+		if newOwner == "node1" {
+			t.Fatalf("❌ key %s 不应分配给超载节点", key)
+		}
+		if owner != "node1" && newOwner != owner {
+			t.Errorf("❌ 未超载节点的key %s 从 %s 变为 %s", key, owner, newOwner)
+		}
+		if owner == "node1" {
+			redirected++
+		}
+	}
+
+	stats := dc.GetBoundedLoadStats()
+	t.Logf("📊 有界负载统计: %+v", stats)
+	if stats.Capacities["node1"] != 500 || stats.MaxLoadRatio != 2.5 {
+		t.Errorf("❌ 容量或负载比计算错误: %+v", stats)
+	}
+	if stats.Redirects != uint64(redirected) {
+		t.Errorf("❌ 顺延次数错误: %d, 期望 %d", stats.Redirects, redirected)
+	}
+
+	dc.DisableBoundedLoad()
+	for key, owner := range before {
+		if dc.GetNodeForKey(key) != owner {
+			t.Fatalf("❌ 关闭有界负载后key归属应恢复: %s", key)
+		}
+	}
+
+	if err := dc.EnableBoundedLoad(0); err == nil {
+		t.Error("❌ ε 为0应返回错误")
+	}
+	rendezvous := newPlacementCache(t, nodes, core.PlacementRendezvous)
+	if err := rendezvous.EnableBoundedLoad(0.25); err == nil {
+		t.Error("❌ 非ring放置算法应拒绝有界负载")
+	}
+	t.Log("✅ 有界负载跳过超载节点测试通过")
+}
+
+// TestBoundedLoadSkewedTraffic 测试倾斜流量下最大负载不超过 (1+ε)×平均负载
+func TestBoundedLoadSkewedTraffic(t *testing.T) {
+	nodes := []string{"node1", "node2", "node3", "node4", "node5"}
+	const requests = 20000
+	const epsilon = 0.25
+
+	// 按Zipf分布访问1000个key，少数热点key占据大部分请求
+	simulate := func(dc *core.DistributedCache) map[string]float64 {
+		zipf := rand.NewZipf(rand.New(rand.NewSource(42)), 1.2, 1, 999)
+		loads := make(map[string]float64)
+		for i := 0; i < requests; i++ {
+			node := dc.GetNodeForKey(fmt.Sprintf("hot:%d", zipf.Uint64()))
+			loads[node]++
+			dc.ReportNodeLoad(node, loads[node])
+		}
+		return loads
+	}
+	maxRatio := func(loads map[string]float64) float64 {
+		max := 0.0
+		for _, load := range loads {
+			if load > max {
+				max = load
+			}
+		}
+		return max / (requests / float64(len(nodes)))
+	}
+
+	plain := simulate(core.NewDistributedCacheWithVirtualNodes(nodes, 150))
+
+	bounded := core.NewDistributedCacheWithVirtualNodes(nodes, 150)
+	if err := bounded.EnableBoundedLoad(epsilon); err != nil {
+		t.Fatal(err)
+	}
+	boundedLoads := simulate(bounded)
+
+	t.Logf("📊 普通哈希环: %v, 最大负载比 %.2f", plain, maxRatio(plain))
+	t.Logf("📊 有界负载: %v, 最大负载比 %.2f", boundedLoads, maxRatio(boundedLoads))
+	if maxRatio(plain) <= 1+epsilon {
+		t.Fatalf("❌ 测试流量不够倾斜: %.2f", maxRatio(plain))
+	}
+	// 容量上限向上取整，允许超出一个请求
+	if max := maxRatio(boundedLoads); max > 1+epsilon+float64(len(nodes))/requests {
+		t.Errorf("❌ 最大负载比 %.2f 超过 1+ε", max)
+	}
+
+	// 监控报告实际均衡程度
+	snapshot := monitoring.NewHashRingMonitor(bounded).CaptureSnapshot()
+	if snapshot.BoundedLoad == nil {
+		t.Fatal("❌ 快照缺少有界负载信息")
+	}
+	t.Logf("📊 实际均衡分数: %.1f, 顺延次数: %d", snapshot.BoundedLoad.EffectiveBalance, snapshot.BoundedLoad.Redirects)
+	if snapshot.BoundedLoad.MaxLoadRatio > 1+epsilon+0.01 || snapshot.BoundedLoad.Redirects == 0 {
+		t.Errorf("❌ 监控中的有界负载信息错误: %+v", snapshot.BoundedLoad)
+	}
+	t.Log("✅ 倾斜流量有界负载测试通过")
+}
+
+// TestBoundedLoadCluster 测试节点公布负载，并按上报负载把数据写到未超载的节点
+func TestBoundedLoadCluster(t *testing.T) {
+	nodeIDs := []string{"node1", "node2", "node3"}
+	cluster := startTestNodes(t, nodeIDs, func(config *distributed.NodeConfig) {
+		config.BoundedLoadEpsilon = 0.25
+		config.BoundedLoadMode = core.BoundedLoadModeKeys
+	})
+
+	if err := cluster.Node("node2").Set("warmup", "value"); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Get(cluster.URL("node1") + "/internal/cluster/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var health map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&health)
+	resp.Body.Close()
+	if _, ok := health["load"]; !ok {
+		t.Errorf("❌ 健康检查未公布负载: %v", health)
+	}
+
+	// 测试未启动健康检查循环，直接向各节点上报负载：node2 超载
+	for _, nodeID := range nodeIDs {
+		cluster.Node(nodeID).ReportNodeLoad("node1", 100)
+		cluster.Node(nodeID).ReportNodeLoad("node2", 1000)
+		cluster.Node(nodeID).ReportNodeLoad("node3", 100)
+	}
+
+	for i := 0; i < 200; i++ {
+		key := fmt.Sprintf("bounded:%d", i)
+		if err := cluster.Node("node1").Set(key, "value"); err != nil {
+			t.Fatalf("❌ 写入失败: %v", err)
+		}
+		owner := cluster.Node("node3").GetNodeForKey(key)
+		if owner == "node2" {
+			t.Fatalf("❌ key %s 不应分配给超载节点", key)
+		}
+		if _, found := cluster.Node(owner).GetLocal(key); !found {
+			t.Errorf("❌ key %s 不在负责节点 %s 上", key, owner)
+		}
+	}
+
+	resp, err = http.Get(cluster.URL("node1") + "/admin/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var metrics struct {
+		BoundedLoad map[string]interface{} `json:"bounded_load"`
+	}
+	json.NewDecoder(resp.Body).Decode(&metrics)
+	resp.Body.Close()
+	t.Logf("📊 有界负载指标: %v", metrics.BoundedLoad)
+	if metrics.BoundedLoad["enabled"] != true || metrics.BoundedLoad["mode"] != core.BoundedLoadModeKeys {
+		t.Errorf("❌ 指标缺少有界负载信息: %v", metrics.BoundedLoad)
+	}
+	t.Log("✅ 集群有界负载测试通过")
+}
@@ -0,0 +1,184 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"tdd-learning/distributed"
+)
+
+// forwardedRequests 统计节点之间转发的请求数
+func forwardedRequests(tc *testNodeCluster, nodeIDs []string) int64 {
+	var total int64
+	for _, nodeID := range nodeIDs {
+		stats := tc.Node(nodeID).GetLocalStats()
+		total += stats["rpc_Calls"].(int64) + stats["rpc_HTTPFallbacks"].(int64)
+	}
+	return total
+}
+
+// newRoutingTestClient 创建连接测试集群的客户端
+func newRoutingTestClient(tc *testNodeCluster, nodeIDs []string, proxyOnly bool) *distributed.DistributedClient {
+	var nodes []string
+	for _, nodeID := range nodeIDs {
+		nodes = append(nodes, tc.addrs[nodeID])
+	}
+	return distributed.NewDistributedClient(distributed.ClientConfig{
+		Nodes:               nodes,
+		Timeout:             2 * time.Second,
+		HealthCheckInterval: time.Minute,
+		ProxyOnly:           proxyOnly,
+	})
+}
+
+// TestClientDirectRouting 测试客户端按集群拓扑直连负责节点，拓扑变更后通过重定向和版本号刷新
+func TestClientDirectRouting(t *testing.T) {
+	nodeIDs := []string{"node1", "node2", "node3"}
+	tc := startTestNodes(t, nodeIDs, nil)
+	client := newRoutingTestClient(tc, nodeIDs, false)
+	defer client.Close()
+
+	if err := client.RefreshTopology(); err != nil {
+		t.Fatalf("❌ 获取集群拓扑失败: %v", err)
+	}
+	if stats := client.GetRoutingStats(); !stats.TopologyKnown || stats.Nodes != 3 {
+		t.Fatalf("❌ 客户端应构建出3个节点的哈希环: %+v", stats)
+	}
+
+	before := forwardedRequests(tc, nodeIDs)
+	for i := 0; i < 50; i++ {
+		key := fmt.Sprintf("route:%d", i)
+		if err := client.Set(key, fmt.Sprintf("v%d", i)); err != nil {
+			t.Fatalf("❌ 写入失败: %v", err)
+		}
+		if value, found, err := client.Get(key); err != nil || !found || value != fmt.Sprintf("v%d", i) {
+			t.Fatalf("❌ 读取 %s 失败: %q %v %v", key, value, found, err)
+		}
+		if _, found := tc.Node(tc.Node("node1").GetNodeForKey(key)).GetLocal(key); !found {
+			t.Errorf("❌ %s 应写入负责节点", key)
+		}
+	}
+	if forwarded := forwardedRequests(tc, nodeIDs) - before; forwarded != 0 {
+		t.Errorf("❌ 直连时节点不应转发请求，实际转发 %d 次", forwarded)
+	}
+	if stats := client.GetRoutingStats(); stats.Direct != 100 || stats.Proxied != 0 {
+		t.Errorf("❌ 100次请求都应直连负责节点: %+v", stats)
+	}
+	t.Log("✅ 客户端直接把请求发给负责节点")
+
+	// 修改权重后部分key的负责节点改变，客户端的旧拓扑收到421后刷新（先检查健康，权重变更才会广播到其他节点）
+	for _, nodeID := range nodeIDs {
+		tc.Server(nodeID).GetCluster().CheckHealth()
+	}
+	epoch := client.GetRoutingStats().Epoch
+	req, _ := http.NewRequest(http.MethodPut, tc.URL("node1")+"/admin/nodes/node2/weight", strings.NewReader(`{"weight": 3}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("❌ 修改权重失败: %v", err)
+	}
+	resp.Body.Close()
+
+	for i := 0; i < 50; i++ {
+		key := fmt.Sprintf("route:%d", i)
+		if value, found, err := client.Get(key); err != nil || !found || value != fmt.Sprintf("v%d", i) {
+			t.Fatalf("❌ 拓扑变更后读取 %s 失败: %q %v %v", key, value, found, err)
+		}
+	}
+	// 重定向或响应中更新的版本号都会触发刷新，先到者生效
+	stats := client.GetRoutingStats()
+	if stats.Refreshes < 2 || stats.Epoch <= epoch {
+		t.Errorf("❌ 拓扑变更后客户端应刷新拓扑: %+v", stats)
+	}
+	if stats.Epoch != tc.Node("node1").GetRingEpoch() {
+		t.Errorf("❌ 客户端拓扑版本 %d 应与节点一致 %d", stats.Epoch, tc.Node("node1").GetRingEpoch())
+	}
+	if stats.Proxied != 0 {
+		t.Errorf("❌ 各节点视图一致时请求都应直连或按重定向发送: %+v", stats)
+	}
+	t.Logf("📊 路由统计: %+v", stats)
+	t.Log("✅ 拓扑变更后客户端刷新拓扑")
+
+	// 直连的请求发到非负责节点时返回421，不携带版本号时照常转发
+	key := "route:redirect"
+	for i := 0; tc.Node("node1").GetNodeForKey(key) == "node1"; i++ {
+		key = fmt.Sprintf("route:redirect:%d", i)
+	}
+	get := func(epoch string) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, tc.URL("node1")+"/api/v1/cache/"+key, nil)
+		if epoch != "" {
+			req.Header.Set(distributed.ClusterEpochHeader, epoch)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("❌ 请求失败: %v", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	current := fmt.Sprint(tc.Node("node1").GetRingEpoch())
+	if resp := get(current); resp.StatusCode != http.StatusMisdirectedRequest {
+		t.Errorf("❌ 非负责节点应返回421: %d", resp.StatusCode)
+	}
+	if resp := get(""); resp.StatusCode != http.StatusOK || resp.Header.Get(distributed.ClusterEpochHeader) != current {
+		t.Errorf("❌ 未携带版本号时应转发并在响应中返回版本号: %d %q", resp.StatusCode, resp.Header.Get(distributed.ClusterEpochHeader))
+	}
+	t.Log("✅ 非负责节点对直连请求返回421")
+
+	// 二进制接口同样直连
+	before = forwardedRequests(tc, nodeIDs)
+	if err := client.SetBytes([]byte("route/bin\x00"), []byte{0, 1, 2}); err != nil {
+		t.Fatalf("❌ 二进制写入失败: %v", err)
+	}
+	if value, found, err := client.GetBytes([]byte("route/bin\x00")); err != nil || !found || string(value) != "\x00\x01\x02" {
+		t.Fatalf("❌ 二进制读取失败: %q %v %v", value, found, err)
+	}
+	if forwarded := forwardedRequests(tc, nodeIDs) - before; forwarded != 0 {
+		t.Errorf("❌ 二进制接口直连时节点不应转发请求，实际转发 %d 次", forwarded)
+	}
+	t.Log("✅ 二进制接口直连负责节点")
+}
+
+// TestClientRoutingFallback 测试拓扑未知、节点开启有界负载或只转发时客户端经任意节点转发
+func TestClientRoutingFallback(t *testing.T) {
+	nodeIDs := []string{"node1", "node2", "node3"}
+	tc := startTestNodes(t, nodeIDs, func(config *distributed.NodeConfig) {
+		config.BoundedLoadEpsilon = 0.25
+	})
+
+	client := newRoutingTestClient(tc, nodeIDs, false)
+	defer client.Close()
+	if err := client.RefreshTopology(); err != nil {
+		t.Fatalf("❌ 获取集群拓扑失败: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("fallback:%d", i)
+		if err := client.Set(key, "v"); err != nil {
+			t.Fatalf("❌ 写入失败: %v", err)
+		}
+		if value, found, err := client.Get(key); err != nil || !found || value != "v" {
+			t.Fatalf("❌ 读取 %s 失败: %q %v %v", key, value, found, err)
+		}
+	}
+	if stats := client.GetRoutingStats(); stats.TopologyKnown || stats.Direct != 0 || stats.Proxied != 20 {
+		t.Errorf("❌ 有界负载时负责节点由实时负载决定，客户端应经任意节点转发: %+v", stats)
+	}
+	t.Log("✅ 节点开启有界负载时客户端回退到转发")
+
+	// 只转发的客户端不获取拓扑
+	proxyClient := newRoutingTestClient(tc, nodeIDs, true)
+	defer proxyClient.Close()
+	if value, found, err := proxyClient.Get("fallback:0"); err != nil || !found || value != "v" {
+		t.Fatalf("❌ 读取失败: %q %v %v", value, found, err)
+	}
+	if stats := proxyClient.GetRoutingStats(); stats.Enabled {
+		t.Errorf("❌ proxy_only 时不应开启直连路由: %+v", stats)
+	}
+	if err := proxyClient.RefreshTopology(); err == nil {
+		t.Error("❌ proxy_only 时刷新拓扑应返回错误")
+	}
+	t.Log("✅ proxy_only 客户端经任意节点转发")
+}
@@ -0,0 +1,126 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"tdd-learning/distributed"
+)
+
+// eventTypes 获取节点记录的集群事件类型
+func eventTypes(server *distributed.NodeServer) map[string]int {
+	types := make(map[string]int)
+	for _, event := range server.GetCluster().GetEvents(0) {
+		types[event.Type]++
+	}
+	return types
+}
+
+// TestNodeEvictionAndReadmission 测试长时间宕机的节点经多数派同意后被驱逐，恢复后清空旧数据重新加入
+func TestNodeEvictionAndReadmission(t *testing.T) {
+	nodeIDs := []string{"node1", "node2", "node3"}
+	interval := 50 * time.Millisecond
+	tc := startTestNodes(t, nodeIDs, func(config *distributed.NodeConfig) {
+		config.HealthCheckInterval = interval
+		config.EvictionGracePeriod = 100 * time.Millisecond
+	})
+	checkHealth := func(nodeIDs ...string) {
+		for _, nodeID := range nodeIDs {
+			tc.Server(nodeID).GetCluster().CheckHealth()
+		}
+	}
+	for i := 0; i < 5; i++ {
+		checkHealth(nodeIDs...)
+		time.Sleep(interval)
+	}
+
+	rewrittenKey := keyOwnedBy(t, nodeIDs, "node3", "evict:rewritten")
+	deletedKey := keyOwnedBy(t, nodeIDs, "node3", "evict:deleted")
+	for _, key := range []string{rewrittenKey, deletedKey} {
+		if err := tc.Node("node1").Set(key, "old"); err != nil {
+			t.Fatalf("❌ 写入失败: %v", err)
+		}
+	}
+
+	tc.Stop("node3")
+	leader := tc.Server("node1")
+	if !waitForCondition(2*time.Second, func() bool {
+		checkHealth("node1")
+		return clusterNodeInfo(leader.GetCluster(), "node3").Status == "unhealthy"
+	}) {
+		t.Fatal("❌ node1 应判定 node3 不健康")
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	// 只有 node1 认为 node3 不健康，未达到多数派
+	leader.GetEvictor().Check()
+	if _, inRing := tc.Node("node1").GetClusterNodes()["node3"]; !inRing {
+		t.Fatal("❌ 少数派不应驱逐节点")
+	}
+	if types := eventTypes(leader); types[distributed.EventEvictionProposed] == 0 || types[distributed.EventEvictionRejected] == 0 {
+		t.Errorf("❌ 应记录驱逐提议和拒绝事件: %v", types)
+	}
+	t.Log("✅ 少数派同意时拒绝驱逐")
+
+	// node2 也判定 node3 不健康后驱逐
+	if !waitForCondition(2*time.Second, func() bool {
+		checkHealth("node1", "node2")
+		return clusterNodeInfo(tc.Server("node2").GetCluster(), "node3").Status == "unhealthy"
+	}) {
+		t.Fatal("❌ node2 应判定 node3 不健康")
+	}
+	leader.GetEvictor().Check()
+	for _, nodeID := range []string{"node1", "node2"} {
+		if _, inRing := tc.Node(nodeID).GetClusterNodes()["node3"]; inRing {
+			t.Fatalf("❌ %s 的哈希环中不应再有 node3", nodeID)
+		}
+		if types := eventTypes(tc.Server(nodeID)); types[distributed.EventNodeEvicted] == 0 {
+			t.Errorf("❌ %s 应记录驱逐事件: %v", nodeID, types)
+		}
+	}
+	if evicted := tc.Server("node2").GetEvictor().Evicted(); len(evicted) != 1 || evicted[0].NodeID != "node3" {
+		t.Errorf("❌ node2 应记住被驱逐的 node3: %+v", evicted)
+	}
+	t.Log("✅ 多数派同意后驱逐 node3")
+
+	// 驱逐期间 node3 负责的区间由其他节点接管
+	if err := tc.Node("node1").Set(rewrittenKey, "new"); err != nil {
+		t.Fatalf("❌ 驱逐后写入失败: %v", err)
+	}
+	if err := tc.Node("node1").Delete(deletedKey); err != nil {
+		t.Fatalf("❌ 驱逐后删除失败: %v", err)
+	}
+
+	// node3 恢复：清空旧数据，重新加入哈希环并同步最新数据
+	tc.Restart(t, "node3")
+	leader.GetEvictor().Check()
+	for _, nodeID := range nodeIDs {
+		if _, inRing := tc.Node(nodeID).GetClusterNodes()["node3"]; !inRing {
+			t.Errorf("❌ %s 的哈希环中应重新包含 node3", nodeID)
+		}
+	}
+	if evicted := leader.GetEvictor().Evicted(); len(evicted) != 0 {
+		t.Errorf("❌ 重新接纳后不应再有被驱逐的节点: %+v", evicted)
+	}
+	if value, found := tc.Node("node3").GetLocal(rewrittenKey); !found || value != "new" {
+		t.Errorf("❌ node3 应同步到最新数据: %q %v", value, found)
+	}
+	if value, found := tc.Node("node3").GetLocal(deletedKey); found {
+		t.Errorf("❌ node3 的旧数据应被清空: %q", value)
+	}
+	if value, found, err := tc.Node("node2").Get(rewrittenKey); err != nil || !found || value != "new" {
+		t.Errorf("❌ 重新接纳后应读到最新值: %q %v %v", value, found, err)
+	}
+
+	types := eventTypes(leader)
+	for _, eventType := range []string{distributed.EventNodeDown, distributed.EventNodeReturned, distributed.EventNodeReadmitted} {
+		if types[eventType] == 0 {
+			t.Errorf("❌ node1 应记录 %s 事件: %v", eventType, types)
+		}
+	}
+	if types := eventTypes(tc.Server("node3")); types[distributed.EventDataReset] == 0 {
+		t.Errorf("❌ node3 应记录清空数据事件: %v", types)
+	}
+	t.Logf("📊 node1 集群事件: %v", types)
+	t.Log("✅ node3 清空旧数据后重新加入")
+}
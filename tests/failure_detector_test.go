@@ -0,0 +1,120 @@
+package tests
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tdd-learning/distributed"
+)
+
+// clusterNodeInfo 获取集群管理器中指定节点的信息
+func clusterNodeInfo(cm *distributed.ClusterManager, nodeID string) *distributed.NodeInfo {
+	return cm.GetNodes()[nodeID]
+}
+
+// TestPhiAccrualFailureDetector 测试单次检查失败不会让节点不健康，持续失败后φ超过阈值
+func TestPhiAccrualFailureDetector(t *testing.T) {
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"healthy"}`))
+	}))
+	defer peer.Close()
+	address := peer.Listener.Addr().String()
+
+	interval := 50 * time.Millisecond
+	cm := distributed.NewClusterManager("node1", map[string]string{"node1": "127.0.0.1:1", "node2": address})
+	cm.SetFailureDetector(distributed.DefaultPhiThreshold, interval)
+	var recovered int32
+	cm.SetRecoveryHandler(func(nodeID string) { atomic.AddInt32(&recovered, 1) })
+
+	for i := 0; i < 10; i++ {
+		cm.CheckHealth()
+		time.Sleep(interval)
+	}
+	info := clusterNodeInfo(cm, "node2")
+	if info.Status != "healthy" || info.Phi >= distributed.DefaultPhiThreshold {
+		t.Fatalf("❌ 稳定心跳时节点应健康: %+v", info)
+	}
+
+	// 节点停止响应：紧接着的一次检查失败不改变状态
+	peer.Close()
+	cm.CheckHealth()
+	if info := clusterNodeInfo(cm, "node2"); info.Status != "healthy" || info.ResponseTime != -1 {
+		t.Fatalf("❌ 单次检查失败不应判定为不健康: %+v", info)
+	}
+	t.Logf("📊 单次失败后 φ=%.2f", clusterNodeInfo(cm, "node2").Phi)
+
+	if !waitForCondition(2*time.Second, func() bool {
+		cm.CheckHealth()
+		return clusterNodeInfo(cm, "node2").Status == "unhealthy"
+	}) {
+		t.Fatalf("❌ 持续失败后应判定为不健康: %+v", clusterNodeInfo(cm, "node2"))
+	}
+	if phi := clusterNodeInfo(cm, "node2").Phi; phi < distributed.DefaultPhiThreshold {
+		t.Errorf("❌ 不健康节点的φ应超过阈值: %.2f", phi)
+	}
+	t.Logf("📊 持续失败后 φ=%.2f", clusterNodeInfo(cm, "node2").Phi)
+
+	// 节点恢复：下一次成功的检查恢复健康并触发恢复回调
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		t.Fatalf("❌ 重新监听失败: %v", err)
+	}
+	restarted := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"healthy"}`))
+	}))
+	restarted.Listener.Close()
+	restarted.Listener = listener
+	restarted.Start()
+	defer restarted.Close()
+
+	cm.CheckHealth()
+	if info := clusterNodeInfo(cm, "node2"); info.Status != "healthy" || info.Phi >= 1 {
+		t.Errorf("❌ 恢复后应健康: %+v", info)
+	}
+	if !waitForCondition(time.Second, func() bool { return atomic.LoadInt32(&recovered) > 0 }) {
+		t.Error("❌ 恢复健康时应触发恢复回调")
+	}
+	t.Log("✅ Phi Accrual 故障检测测试通过")
+}
+
+// TestHealthCheckDoesNotBlockReads 测试健康检查的网络请求期间不阻塞集群状态查询
+func TestHealthCheckDoesNotBlockReads(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+		w.Write([]byte(`{"status":"healthy"}`))
+	}))
+	defer slow.Close()
+
+	cm := distributed.NewClusterManager("node1", map[string]string{
+		"node1": "127.0.0.1:1",
+		"node2": slow.Listener.Addr().String(),
+		"node3": slow.Listener.Addr().String(),
+	})
+	done := make(chan struct{})
+	start := time.Now()
+	go func() {
+		cm.CheckHealth()
+		close(done)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	queryStart := time.Now()
+	cm.GetClusterStatus()
+	if elapsed := time.Since(queryStart); elapsed > 200*time.Millisecond {
+		t.Errorf("❌ 健康检查期间查询被阻塞 %v", elapsed)
+	}
+
+	<-done
+	// 两个慢节点并行检查，总耗时接近单个节点
+	if elapsed := time.Since(start); elapsed > 900*time.Millisecond {
+		t.Errorf("❌ 健康检查应并行执行，耗时 %v", elapsed)
+	}
+	if status := cm.GetClusterStatus(); status.HealthyNodes != 3 {
+		t.Errorf("❌ 所有节点应健康: %+v", status)
+	}
+	t.Log("✅ 健康检查不持有锁测试通过")
+}
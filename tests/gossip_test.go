@@ -0,0 +1,190 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"tdd-learning/distributed"
+)
+
+// waitForCondition 轮询直到条件成立或超时
+func waitForCondition(timeout time.Duration, condition func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for !condition() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return true
+}
+
+// memberState 获取gossip视图中指定成员的状态
+func memberState(g *distributed.Gossiper, nodeID string) string {
+	for _, member := range g.Members() {
+		if member.NodeID == nodeID {
+			return member.State
+		}
+	}
+	return ""
+}
+
+// TestGossipMembership 测试新节点通过单个种子加入集群，以及故障探测、反驳和主动离开
+func TestGossipMembership(t *testing.T) {
+	nodeIDs := []string{"node1", "node2", "node3"}
+	cluster := startTestNodes(t, nodeIDs, func(config *distributed.NodeConfig) {
+		config.ClusterNodes = map[string]string{config.NodeID: config.Address}
+		config.Gossip = true
+		config.GossipProbeTimeout = 200 * time.Millisecond
+		config.GossipSuspicionTimeout = 300 * time.Millisecond
+	})
+	gossipers := make(map[string]*distributed.Gossiper)
+	for _, nodeID := range nodeIDs {
+		gossipers[nodeID] = cluster.Server(nodeID).GetGossiper()
+	}
+	probeAll := func(nodeIDs ...string) {
+		for _, nodeID := range nodeIDs {
+			gossipers[nodeID].Probe()
+		}
+	}
+
+	// node2、node3 只知道种子 node1
+	for _, nodeID := range []string{"node2", "node3"} {
+		if _, err := gossipers[nodeID].Join([]string{cluster.addrs["node1"]}); err != nil {
+			t.Fatalf("❌ %s 加入失败: %v", nodeID, err)
+		}
+	}
+	converged := waitForCondition(3*time.Second, func() bool {
+		probeAll(nodeIDs...)
+		for _, nodeID := range nodeIDs {
+			if len(cluster.Node(nodeID).GetClusterNodes()) != 3 || cluster.Server(nodeID).GetCluster().GetClusterStatus().HealthyNodes != 3 {
+				return false
+			}
+		}
+		return true
+	})
+	if !converged {
+		for _, nodeID := range nodeIDs {
+			t.Logf("📊 %s 成员: %+v", nodeID, gossipers[nodeID].Members())
+		}
+		t.Fatal("❌ 所有节点应通过gossip发现彼此")
+	}
+	for i := 0; i < 50; i++ {
+		key := fmt.Sprintf("gossip:%d", i)
+		owner := cluster.Node("node1").GetNodeForKey(key)
+		if cluster.Node("node2").GetNodeForKey(key) != owner || cluster.Node("node3").GetNodeForKey(key) != owner {
+			t.Fatalf("❌ 各节点对 %s 的归属判断不一致", key)
+		}
+	}
+	if err := cluster.Node("node2").Set("gossip:value", "ok"); err != nil {
+		t.Fatalf("❌ 写入失败: %v", err)
+	}
+	if value, found, err := cluster.Node("node3").Get("gossip:value"); err != nil || !found || value != "ok" {
+		t.Fatalf("❌ 经其他节点读取失败: %q %v %v", value, found, err)
+	}
+	t.Log("✅ 新节点通过单个种子加入集群")
+
+	// node3 宕机：直接和间接探测都失败后标记为可疑，超时后判定为下线
+	cluster.Stop("node3")
+	if !waitForCondition(3*time.Second, func() bool {
+		probeAll("node1", "node2")
+		return memberState(gossipers["node1"], "node3") == distributed.MemberDead
+	}) {
+		t.Fatalf("❌ node3 应被判定为下线，当前状态: %s", memberState(gossipers["node1"], "node3"))
+	}
+	for _, info := range cluster.Server("node1").GetCluster().GetClusterStatus().Nodes {
+		if info.NodeID == "node3" && info.Status != "unhealthy" {
+			t.Errorf("❌ 集群管理器中 node3 应为 unhealthy: %s", info.Status)
+		}
+	}
+	stats := gossipers["node1"].GetStats()
+	t.Logf("📊 node1 gossip统计: %+v", stats)
+	if stats.Suspicions == 0 || stats.IndirectProbes == 0 || stats.Dead != 1 {
+		t.Errorf("❌ 统计错误: %+v", stats)
+	}
+	if len(cluster.Node("node1").GetClusterNodes()) != 3 {
+		t.Error("❌ 下线的节点不应移出哈希环")
+	}
+	t.Log("✅ 宕机节点被判定为下线")
+
+	// node3 恢复：与种子同步后发现自己被判定下线，递增化身号反驳
+	cluster.Restart(t, "node3")
+	if _, err := gossipers["node3"].Join([]string{cluster.addrs["node1"]}); err != nil {
+		t.Fatalf("❌ node3 重新同步失败: %v", err)
+	}
+	if !waitForCondition(3*time.Second, func() bool {
+		probeAll(nodeIDs...)
+		return memberState(gossipers["node1"], "node3") == distributed.MemberAlive &&
+			memberState(gossipers["node2"], "node3") == distributed.MemberAlive
+	}) {
+		t.Fatalf("❌ node3 恢复后应重新标记为存活: %s", memberState(gossipers["node1"], "node3"))
+	}
+	if refutations := gossipers["node3"].GetStats().Refutations; refutations == 0 {
+		t.Error("❌ node3 应反驳关于自己的下线消息")
+	}
+	t.Log("✅ 恢复的节点通过递增化身号重新加入")
+
+	// node2 主动离开：其他节点把它移出哈希环
+	gossipers["node2"].Leave()
+	if !waitForCondition(3*time.Second, func() bool {
+		probeAll("node1", "node3")
+		_, in1 := cluster.Node("node1").GetClusterNodes()["node2"]
+		_, in3 := cluster.Node("node3").GetClusterNodes()["node2"]
+		return !in1 && !in3
+	}) {
+		t.Fatal("❌ 主动离开的节点应被移出哈希环")
+	}
+	t.Log("✅ 主动离开的节点被移出集群")
+}
+
+// TestGossipUDPTransport 测试基于UDP传输的加入、故障探测和离开
+func TestGossipUDPTransport(t *testing.T) {
+	newGossiper := func(nodeID string) *distributed.Gossiper {
+		g, err := distributed.NewGossiper(distributed.GossipConfig{
+			Transport:        distributed.GossipTransportUDP,
+			BindAddress:      "127.0.0.1:0",
+			ProbeTimeout:     100 * time.Millisecond,
+			SuspicionTimeout: 200 * time.Millisecond,
+		}, distributed.Member{NodeID: nodeID, Address: "127.0.0.1:0"})
+		if err != nil {
+			t.Fatalf("❌ 创建gossip失败: %v", err)
+		}
+		t.Cleanup(g.Stop)
+		return g
+	}
+	a, b, c := newGossiper("a"), newGossiper("b"), newGossiper("c")
+	seed := a.GetStats().GossipAddress
+	for _, g := range []*distributed.Gossiper{b, c} {
+		if _, err := g.Join([]string{seed}); err != nil {
+			t.Fatalf("❌ 通过UDP加入失败: %v", err)
+		}
+	}
+	if !waitForCondition(3*time.Second, func() bool {
+		for _, g := range []*distributed.Gossiper{a, b, c} {
+			g.Probe()
+			if stats := g.GetStats(); stats.Alive != 2 {
+				return false
+			}
+		}
+		return true
+	}) {
+		t.Fatalf("❌ UDP成员应相互发现: %+v", b.Members())
+	}
+	t.Log("✅ UDP传输加入成功")
+
+	c.Stop()
+	if !waitForCondition(3*time.Second, func() bool {
+		a.Probe()
+		return memberState(a, "c") == distributed.MemberDead
+	}) {
+		t.Fatalf("❌ 停止的成员应被判定为下线: %s", memberState(a, "c"))
+	}
+
+	b.Leave()
+	if state := memberState(a, "b"); state != distributed.MemberLeft {
+		t.Errorf("❌ 主动离开的成员状态应为 left: %s", state)
+	}
+	t.Logf("📊 a 的gossip统计: %+v", a.GetStats())
+	t.Log("✅ UDP传输故障探测和离开测试通过")
+}
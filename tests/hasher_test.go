@@ -0,0 +1,179 @@
+package tests
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strings"
+	"testing"
+
+	"tdd-learning/core"
+	"tdd-learning/distributed"
+)
+
+// TestHasherReferenceVectors 测试各哈希函数与参考实现的结果一致
+func TestHasherReferenceVectors(t *testing.T) {
+	vectors := map[string]map[string]uint32{
+		core.HasherXXHash: {
+			"":    0x02cc5d05,
+			"a":   0x550d7456,
+			"abc": 0x32d153ff,
+			"The quick brown fox jumps over the lazy dog": 0xe85ea4de,
+		},
+		core.HasherMurmur3: {
+			"":      0x00000000,
+			"hello": 0x248bfa47,
+			"The quick brown fox jumps over the lazy dog": 0x2e4ff723,
+		},
+	}
+
+	for name, cases := range vectors {
+		hasher, err := core.NewHasher(name)
+		if err != nil {
+			t.Fatalf("❌ 创建哈希函数失败: %v", err)
+		}
+		for input, expected := range cases {
+			if got := hasher.Hash(input); got != expected {
+				t.Errorf("❌ %s(%q) = %08x, 期望 %08x", name, input, got, expected)
+			}
+		}
+	}
+
+	// FNV-1a 和 SHA-1 与标准库对比
+	fnvHasher, _ := core.NewHasher(core.HasherFNV1a)
+	sha1Hasher, _ := core.NewHasher(core.HasherSHA1)
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key:%d:%s", i, strings.Repeat("x", i))
+
+		h := fnv.New32a()
+		h.Write([]byte(key))
+		if got := fnvHasher.Hash(key); got != h.Sum32() {
+			t.Errorf("❌ fnv1a(%q) = %08x, 期望 %08x", key, got, h.Sum32())
+		}
+
+		sum := sha1.Sum([]byte(key))
+		if got := sha1Hasher.Hash(key); got != binary.BigEndian.Uint32(sum[:4]) {
+			t.Errorf("❌ sha1(%q) 结果错误", key)
+		}
+	}
+
+	if _, err := core.NewHasher("md5"); err == nil {
+		t.Error("❌ 未知哈希函数应返回错误")
+	}
+	t.Log("✅ 哈希函数参考值测试通过")
+}
+
+// TestHashRingWithHasher 测试默认环布局不变，且每种哈希函数都能均匀分布
+func TestHashRingWithHasher(t *testing.T) {
+	nodes := []string{"node1", "node2", "node3"}
+
+	// 未指定哈希函数时与旧版本（SHA-1）的环布局完全一致
+	legacy := core.NewDistributedCacheWithVirtualNodes(nodes, 150)
+	sha1Ring := core.NewDistributedCacheWithHasher(nodes, 150, nil)
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key:%d", i)
+		if legacy.GetNodeForKey(key) != sha1Ring.GetNodeForKey(key) {
+			t.Fatalf("❌ 默认哈希函数改变了key归属: %s", key)
+		}
+	}
+	if sha1Ring.HasherName() != core.HasherSHA1 {
+		t.Errorf("❌ 默认哈希函数名称错误: %s", sha1Ring.HasherName())
+	}
+
+	for _, name := range core.HasherNames {
+		hasher, _ := core.NewHasher(name)
+		ring := core.NewDistributedCacheWithHasher(nodes, 150, hasher)
+
+		counts := make(map[string]int)
+		for i := 0; i < 30000; i++ {
+			counts[ring.GetNodeForKey(fmt.Sprintf("user:%d", i))]++
+		}
+		t.Logf("📊 %s 分布: %v", name, counts)
+		if name == core.HasherFNV1a {
+			// FNV-1a 雪崩效应弱，对 "node#i" 这类相近的虚拟节点名分布偏斜，仅用于兼容已有部署
+			continue
+		}
+		for _, node := range nodes {
+			if counts[node] < 7000 || counts[node] > 13000 {
+				t.Errorf("❌ %s 分布不均匀: %v", name, counts)
+				break
+			}
+		}
+	}
+	t.Log("✅ 哈希环哈希函数测试通过")
+}
+
+// TestHashFunctionMismatch 测试哈希函数不一致的节点不能加入集群或启动
+func TestHashFunctionMismatch(t *testing.T) {
+	cluster := startTestNodes(t, []string{"node1", "node2"}, func(config *distributed.NodeConfig) {
+		config.HashFunction = core.HasherXXHash
+	})
+
+	// 健康检查返回哈希函数名称
+	resp, err := http.Get(cluster.URL("node1") + "/internal/cluster/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var health map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&health)
+	resp.Body.Close()
+	if health["hash_function"] != core.HasherXXHash {
+		t.Errorf("❌ 健康检查未返回哈希函数: %v", health)
+	}
+
+	join := func(hashFunction string) int {
+		body := fmt.Sprintf(`{"node_id":"node3","address":"127.0.0.1:1","hash_function":%q}`, hashFunction)
+		resp, err := http.Post(cluster.URL("node1")+"/internal/cluster/join", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if status := join(core.HasherMurmur3); status != http.StatusConflict {
+		t.Errorf("❌ 哈希函数不一致应返回409: %d", status)
+	}
+	// 未携带哈希函数的旧版本节点视为SHA-1
+	if status := join(""); status != http.StatusConflict {
+		t.Errorf("❌ 旧版本节点应被拒绝: %d", status)
+	}
+	if len(cluster.Node("node1").GetClusterNodes()) != 2 {
+		t.Error("❌ 被拒绝的节点不应加入集群")
+	}
+
+	// 新节点启动前校验：与已运行节点一致时通过，不一致时拒绝启动
+	peers := map[string]string{"node1": cluster.addrs["node1"], "node2": cluster.addrs["node2"], "node3": "127.0.0.1:1"}
+	for name, expectErr := range map[string]bool{core.HasherXXHash: false, core.HasherFNV1a: true, "": true} {
+		server := distributed.NewNodeServer(distributed.NodeConfig{
+			NodeID:       "node3",
+			Address:      "127.0.0.1:1",
+			ClusterNodes: peers,
+			HashFunction: name,
+		})
+		err := server.GetCluster().VerifyRingConfig()
+		if (err != nil) != expectErr {
+			t.Errorf("❌ 哈希函数 %q 启动校验结果错误: %v", name, err)
+		}
+		if err != nil {
+			t.Logf("📊 拒绝启动: %v", err)
+		}
+	}
+	t.Log("✅ 哈希函数一致性校验测试通过")
+}
+
+// BenchmarkHasher 比较各哈希函数在哈希环查找中的开销
+func BenchmarkHasher(b *testing.B) {
+	nodes := []string{"node1", "node2", "node3"}
+	for _, name := range core.HasherNames {
+		hasher, _ := core.NewHasher(name)
+		ring := core.NewDistributedCacheWithHasher(nodes, 150, hasher)
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				ring.GetNodeForKey("user:session:1234567890")
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package tests
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"tdd-learning/distributed"
+)
+
+// TestHintedHandoffReplay 测试目标节点宕机时暂存写入，恢复后由健康检查触发重放
+func TestHintedHandoffReplay(t *testing.T) {
+	nodeIDs := []string{"node1", "node2"}
+	hintDir := t.TempDir()
+	cluster := startTestNodes(t, nodeIDs, func(config *distributed.NodeConfig) {
+		config.HintedHandoff = true
+		config.HintDir = hintDir
+	})
+	node1, node2 := cluster.Node("node1"), cluster.Node("node2")
+
+	setKey := keyOwnedBy(t, nodeIDs, "node2", "hint-set")
+	deleteKey := keyOwnedBy(t, nodeIDs, "node2", "hint-del")
+	if err := node1.Set(deleteKey, "old"); err != nil {
+		t.Fatal(err)
+	}
+
+	// node2 宕机期间的写入和删除由node1暂存，读取返回暂存的数据
+	cluster.Stop("node2")
+	if err := node1.Set(setKey, "during-outage"); err != nil {
+		t.Fatalf("❌ 目标节点宕机时写入应被暂存: %v", err)
+	}
+	if err := node1.Delete(deleteKey); err != nil {
+		t.Fatalf("❌ 目标节点宕机时删除应被暂存: %v", err)
+	}
+	if value, found, err := node1.Get(setKey); err != nil || !found || value != "during-outage" {
+		t.Errorf("❌ 宕机期间应读到暂存的写入: %q %v %v", value, found, err)
+	}
+	if _, found, err := node1.Get(deleteKey); err != nil || found {
+		t.Errorf("❌ 宕机期间应读到暂存的删除: %v %v", found, err)
+	}
+	if stats := node1.GetHintStats(); stats.Pending != 2 || stats.PendingByNode["node2"] != 2 {
+		t.Fatalf("❌ 待重放提示数错误: %+v", stats)
+	}
+
+	// node2 恢复，健康检查发现后重放
+	cluster.Restart(t, "node2")
+	cluster.Server("node1").GetCluster().CheckHealth()
+	deadline := time.Now().Add(3 * time.Second)
+	for node1.GetHintStats().Pending > 0 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	stats := node1.GetHintStats()
+	t.Logf("📊 提示移交统计: %+v", stats)
+	if stats.Pending != 0 || stats.Replayed != 2 {
+		t.Fatalf("❌ 提示应全部重放: %+v", stats)
+	}
+	if value, found := node2.GetLocal(setKey); !found || value != "during-outage" {
+		t.Errorf("❌ 重放后node2应有数据: %q %v", value, found)
+	}
+	if _, found := node2.GetLocal(deleteKey); found {
+		t.Error("❌ 重放后node2上的数据应被删除")
+	}
+	t.Log("✅ 提示移交重放测试通过")
+}
+
+// TestHintStoreBoundedAndDurable 测试提示数量上限、持久化恢复和过期清理
+func TestHintStoreBoundedAndDurable(t *testing.T) {
+	nodeIDs := []string{"node1", "node2"}
+	hintDir := t.TempDir()
+	cluster := startTestNodes(t, nodeIDs, func(config *distributed.NodeConfig) {
+		config.HintedHandoff = true
+		config.HintDir = hintDir
+		config.MaxHints = 2
+	})
+	node1 := cluster.Node("node1")
+	cluster.Stop("node2")
+
+	for i, prefix := range []string{"bound-a", "bound-b", "bound-c"} {
+		err := node1.Set(keyOwnedBy(t, nodeIDs, "node2", prefix), "value")
+		if i < 2 && err != nil {
+			t.Fatalf("❌ 未达到上限时写入应被暂存: %v", err)
+		}
+		if i == 2 && err == nil {
+			t.Fatal("❌ 提示存储已满时写入应返回错误")
+		}
+	}
+	if stats := node1.GetHintStats(); stats.Pending != 2 || stats.Dropped != 1 {
+		t.Fatalf("❌ 提示上限统计错误: %+v", stats)
+	}
+	node1.CloseHints()
+
+	// 同一节点重启后从文件恢复未重放的提示
+	config := distributed.NodeConfig{
+		NodeID:        "node1",
+		ClusterNodes:  cluster.addrs,
+		VirtualNodes:  150,
+		HintedHandoff: true,
+		HintDir:       hintDir,
+		HintTTL:       200 * time.Millisecond,
+	}
+	restarted := distributed.NewDistributedNode(config)
+	defer restarted.CloseHints()
+	if stats := restarted.GetHintStats(); stats.Pending != 2 {
+		t.Fatalf("❌ 重启后应恢复2条提示: %+v", stats)
+	}
+	if value, found, err := restarted.Get(keyOwnedBy(t, nodeIDs, "node2", "bound-a")); err != nil || !found || value != "value" {
+		t.Errorf("❌ 恢复的提示应可读取: %q %v %v", value, found, err)
+	}
+
+	// 超过保留时间后丢弃
+	time.Sleep(300 * time.Millisecond)
+	if replayed, err := restarted.ReplayHints("node2"); replayed != 0 || err != nil {
+		t.Errorf("❌ 过期提示不应重放: %d %v", replayed, err)
+	}
+	if stats := restarted.GetHintStats(); stats.Pending != 0 || stats.Expired != 2 {
+		t.Errorf("❌ 过期提示应被清理: %+v", stats)
+	}
+	t.Log("✅ 提示存储上限与持久化测试通过")
+}
+
+// TestHintReplayDurableWithoutRewrite 测试重放以追加删除记录的方式持久化，不重写日志，重启后不会恢复已重放的提示
+func TestHintReplayDurableWithoutRewrite(t *testing.T) {
+	nodeIDs := []string{"node1", "node2"}
+	hintDir := t.TempDir()
+	cluster := startTestNodes(t, nodeIDs, func(config *distributed.NodeConfig) {
+		config.HintedHandoff = true
+		config.HintDir = hintDir
+	})
+	node1 := cluster.Node("node1")
+	cluster.Stop("node2")
+
+	keys := []string{
+		keyOwnedBy(t, nodeIDs, "node2", "durable-a"),
+		keyOwnedBy(t, nodeIDs, "node2", "durable-b"),
+	}
+	for _, key := range keys {
+		if err := node1.Set(key, "value"); err != nil {
+			t.Fatalf("❌ 目标节点宕机时写入应被暂存: %v", err)
+		}
+	}
+	logPath := filepath.Join(hintDir, "hints-node1.log")
+	before, err := os.Stat(logPath)
+	if err != nil {
+		t.Fatalf("❌ 提示日志应已写入: %v", err)
+	}
+
+	cluster.Restart(t, "node2")
+	if replayed, err := node1.ReplayHints("node2"); replayed != 2 || err != nil {
+		t.Fatalf("❌ 提示应全部重放: %d %v", replayed, err)
+	}
+
+	// 失效记录未达到压缩阈值，日志只追加不重写
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(data)) <= before.Size() {
+		t.Errorf("❌ 重放应追加删除记录: %d -> %d 字节", before.Size(), len(data))
+	}
+	if lines := bytes.Count(data, []byte("\n")); lines != 4 {
+		t.Errorf("❌ 日志应包含2条提示和2条删除记录，实际 %d 行", lines)
+	}
+	node1.CloseHints()
+
+	// 重启后不恢复已重放的提示
+	restarted := distributed.NewDistributedNode(distributed.NodeConfig{
+		NodeID:        "node1",
+		ClusterNodes:  cluster.addrs,
+		VirtualNodes:  150,
+		HintedHandoff: true,
+		HintDir:       hintDir,
+	})
+	defer restarted.CloseHints()
+	stats := restarted.GetHintStats()
+	t.Logf("📊 重启后提示统计: %+v", stats)
+	if stats.Pending != 0 {
+		t.Errorf("❌ 已重放的提示不应在重启后恢复: %+v", stats)
+	}
+	t.Log("✅ 提示重放持久化测试通过")
+}
@@ -0,0 +1,169 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"tdd-learning/core"
+	"tdd-learning/distributed"
+)
+
+// TestLRUCacheInspect 测试单key元数据
+func TestLRUCacheInspect(t *testing.T) {
+	cache := core.NewLRUCacheWithCleanup(10, time.Minute)
+	defer cache.Close()
+
+	before := time.Now()
+	cache.Set("user:1", "alice")
+	cache.SetWithTTL("session:1", "token", time.Hour)
+	cache.Get("user:1")
+	cache.Get("user:1")
+
+	meta, found := cache.Inspect("user:1")
+	if !found {
+		t.Fatal("❌ 元数据应存在")
+	}
+	if meta.AccessCount != 2 || meta.Size != int64(len("user:1")+len("alice")+64) {
+		t.Errorf("❌ 元数据错误: %+v", meta)
+	}
+	if meta.CreatedAt.Before(before) || meta.LastAccess.Before(meta.CreatedAt) {
+		t.Errorf("❌ 时间戳错误: %+v", meta)
+	}
+	if meta.ExpiresAt != nil {
+		t.Error("❌ 未设置TTL的key不应有截止时间")
+	}
+
+	// Inspect 不计入访问
+	cache.Inspect("user:1")
+	if meta, _ := cache.Inspect("user:1"); meta.AccessCount != 2 {
+		t.Errorf("❌ Inspect不应增加访问次数: %d", meta.AccessCount)
+	}
+
+	session, _ := cache.Inspect("session:1")
+	if session.ExpiresAt == nil || session.ExpiresAt.Before(before.Add(59*time.Minute)) {
+		t.Errorf("❌ TTL截止时间错误: %+v", session.ExpiresAt)
+	}
+
+	if _, found := cache.Inspect("missing"); found {
+		t.Error("❌ 不存在的key不应返回元数据")
+	}
+}
+
+// TestLRUCacheLargestKeys 测试大key报告
+func TestLRUCacheLargestKeys(t *testing.T) {
+	cache := core.NewLRUCache(100)
+	for i := 1; i <= 50; i++ {
+		cache.Set(fmt.Sprintf("key:%02d", i), strings.Repeat("x", i*10))
+	}
+
+	top := cache.LargestKeys(3)
+	if len(top) != 3 {
+		t.Fatalf("❌ 数量错误: %d", len(top))
+	}
+	expected := []string{"key:50", "key:49", "key:48"}
+	for i, meta := range top {
+		if meta.Key != expected[i] {
+			t.Errorf("❌ 第%d大的key错误: 期望=%s, 实际=%s", i+1, expected[i], meta.Key)
+		}
+	}
+
+	if len(cache.LargestKeys(1000)) != 50 {
+		t.Error("❌ n超过大小时应返回全部key")
+	}
+}
+
+// TestAdminKeyInspectionRouting 测试 /admin/key/:key 路由到负责节点
+func TestAdminKeyInspectionRouting(t *testing.T) {
+	cluster := startTestNodes(t, []string{"node1", "node2"}, nil)
+	node1 := cluster.Node("node1")
+
+	// 找到一个属于node2的key，通过node1写入
+	var remoteKey string
+	for i := 0; ; i++ {
+		remoteKey = fmt.Sprintf("user:%d", i)
+		if !node1.IsLocalKey(remoteKey) {
+			break
+		}
+	}
+	if err := node1.Set(remoteKey, "payload"); err != nil {
+		t.Fatalf("❌ 写入失败: %v", err)
+	}
+
+	resp, err := http.Get(cluster.URL("node1") + "/admin/key/" + remoteKey)
+	if err != nil {
+		t.Fatalf("❌ 请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var inspection distributed.KeyInspection
+	json.NewDecoder(resp.Body).Decode(&inspection)
+	if resp.StatusCode != http.StatusOK || !inspection.Found || inspection.NodeID != "node2" {
+		t.Fatalf("❌ 查询结果错误: status=%d %+v", resp.StatusCode, inspection)
+	}
+	if inspection.Metadata.Size != int64(len(remoteKey)+len("payload")+64) {
+		t.Errorf("❌ 元数据大小错误: %+v", inspection.Metadata)
+	}
+
+	// 大key报告包含每个节点
+	resp, err = http.Get(cluster.URL("node1") + "/admin/large-keys?n=5")
+	if err != nil {
+		t.Fatalf("❌ 请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var report struct {
+		Nodes []distributed.LargeKeysReport `json:"nodes"`
+	}
+	json.NewDecoder(resp.Body).Decode(&report)
+	if len(report.Nodes) != 2 {
+		t.Fatalf("❌ 报告节点数错误: %+v", report)
+	}
+	for _, nodeReport := range report.Nodes {
+		if nodeReport.Error != "" {
+			t.Errorf("❌ 节点 %s 报告失败: %s", nodeReport.NodeID, nodeReport.Error)
+		}
+		if nodeReport.NodeID == "node2" && (len(nodeReport.Keys) != 1 || nodeReport.Keys[0].Key != remoteKey) {
+			t.Errorf("❌ node2 大key报告错误: %+v", nodeReport.Keys)
+		}
+	}
+}
+
+// TestAdminBinaryKeyInspection 测试按base64url编码查询包含'/'和非UTF-8字节的key
+func TestAdminBinaryKeyInspection(t *testing.T) {
+	cluster := startTestNodes(t, []string{"node1", "node2"}, nil)
+	node1 := cluster.Node("node1")
+
+	key := "user/1001\xff\x00"
+	if err := node1.Set(key, "payload"); err != nil {
+		t.Fatalf("❌ 写入失败: %v", err)
+	}
+
+	resp, err := http.Get(cluster.URL("node1") + "/admin/bin-key/" + core.EncodeKey(key))
+	if err != nil {
+		t.Fatalf("❌ 请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var inspection distributed.KeyInspection
+	json.NewDecoder(resp.Body).Decode(&inspection)
+	if resp.StatusCode != http.StatusOK || !inspection.Found || inspection.NodeID != node1.GetNodeForKey(key) {
+		t.Fatalf("❌ 查询结果错误: status=%d %+v", resp.StatusCode, inspection)
+	}
+	if inspection.Metadata.Size != int64(len(key)+len("payload")+64) {
+		t.Errorf("❌ 元数据大小错误: %+v", inspection.Metadata)
+	}
+
+	resp, err = http.Get(cluster.URL("node1") + "/admin/bin-key/!!!")
+	if err != nil {
+		t.Fatalf("❌ 请求失败: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("❌ 非法编码应返回400: %d", resp.StatusCode)
+	}
+	t.Log("✅ 二进制key可以按编码查询元数据")
+}
@@ -0,0 +1,333 @@
+package tests
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"tdd-learning/core"
+	"tdd-learning/distributed"
+)
+
+// memcachedConn 简单的memcached文本协议测试客户端
+type memcachedConn struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func dialMemcached(t *testing.T, address string) *memcachedConn {
+	t.Helper()
+	conn, err := net.DialTimeout("tcp", address, 2*time.Second)
+	if err != nil {
+		t.Fatalf("❌ 连接memcached端口失败: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	return &memcachedConn{t: t, conn: conn, reader: bufio.NewReader(conn)}
+}
+
+// send 发送原始命令（调用方负责\r\n）
+func (mc *memcachedConn) send(raw string) {
+	mc.t.Helper()
+	if _, err := mc.conn.Write([]byte(raw)); err != nil {
+		mc.t.Fatalf("❌ 发送命令失败: %v", err)
+	}
+}
+
+// line 读取一行响应
+func (mc *memcachedConn) line() string {
+	mc.t.Helper()
+	line, err := mc.reader.ReadString('\n')
+	if err != nil {
+		mc.t.Fatalf("❌ 读取响应失败: %v", err)
+	}
+	return strings.TrimRight(line, "\r\n")
+}
+
+// expect 发送命令并校验单行响应
+func (mc *memcachedConn) expect(raw, want string) {
+	mc.t.Helper()
+	mc.send(raw)
+	if got := mc.line(); got != want {
+		mc.t.Errorf("❌ 命令 %q 期望 %q，实际 %q", strings.TrimSpace(raw), want, got)
+	}
+}
+
+// readUntilEnd 读取直到END行，返回之前的所有行
+func (mc *memcachedConn) readUntilEnd() []string {
+	mc.t.Helper()
+	var lines []string
+	for {
+		line := mc.line()
+		if line == "END" {
+			return lines
+		}
+		lines = append(lines, line)
+	}
+}
+
+// startMemcachedCluster 启动两个节点，并在node1上开启memcached监听
+func startMemcachedCluster(t *testing.T) (*testNodeCluster, *distributed.MemcachedServer) {
+	cluster := startTestNodes(t, []string{"node1", "node2"}, nil)
+	server := distributed.NewMemcachedServer(cluster.Node("node1"), "127.0.0.1:0")
+	if err := server.Start(); err != nil {
+		t.Fatalf("❌ 启动memcached服务失败: %v", err)
+	}
+	t.Cleanup(server.Stop)
+	return cluster, server
+}
+
+// keyOwnedBy 找到一个由指定节点负责的key（与startTestNodes使用相同的哈希环参数）
+func keyOwnedBy(t *testing.T, nodeIDs []string, owner, prefix string) string {
+	t.Helper()
+	ring := core.NewDistributedCacheWithVirtualNodes(nodeIDs, 150)
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("%s:%d", prefix, i)
+		if ring.GetNodeForKey(key) == owner {
+			return key
+		}
+	}
+	t.Fatalf("❌ 找不到由 %s 负责的key", owner)
+	return ""
+}
+
+// TestMemcachedBasicCommands 测试基本的读写命令
+func TestMemcachedBasicCommands(t *testing.T) {
+	_, server := startMemcachedCluster(t)
+	mc := dialMemcached(t, server.Addr())
+
+	mc.expect("set user:1 42 0 5\r\nalice\r\n", "STORED")
+	mc.send("get user:1 user:missing\r\n")
+	lines := mc.readUntilEnd()
+	if len(lines) != 2 || lines[0] != "VALUE user:1 42 5" || lines[1] != "alice" {
+		t.Errorf("❌ get响应错误: %q", lines)
+	}
+
+	mc.expect("add user:1 0 0 3\r\nbob\r\n", "NOT_STORED")
+	mc.expect("replace user:missing 0 0 3\r\nbob\r\n", "NOT_STORED")
+	mc.expect("replace user:1 7 0 3\r\nbob\r\n", "STORED")
+
+	mc.expect("set counter 0 0 2\r\n10\r\n", "STORED")
+	mc.expect("incr counter 5\r\n", "15")
+	mc.expect("decr counter 100\r\n", "0")
+	mc.expect("incr user:1 1\r\n", "CLIENT_ERROR cannot increment or decrement non-numeric value")
+	mc.expect("incr missing 1\r\n", "NOT_FOUND")
+
+	mc.expect("delete user:1\r\n", "DELETED")
+	mc.expect("delete user:1\r\n", "NOT_FOUND")
+
+	// noreply 不返回响应，紧随其后的命令应得到自己的响应
+	mc.expect("set quiet 0 0 1 noreply\r\nx\r\nversion\r\n", "VERSION 1.6.0-tdd-learning")
+	mc.send("get quiet\r\n")
+	if lines := mc.readUntilEnd(); len(lines) != 2 || lines[1] != "x" {
+		t.Errorf("❌ noreply写入失败: %q", lines)
+	}
+
+	mc.expect("bogus\r\n", "ERROR")
+	mc.expect("get "+strings.Repeat("k", 251)+"\r\n", "CLIENT_ERROR bad command line format")
+	t.Log("✅ memcached基本命令测试通过")
+}
+
+// TestMemcachedCAS 测试gets返回的cas值和cas命令
+func TestMemcachedCAS(t *testing.T) {
+	_, server := startMemcachedCluster(t)
+	mc := dialMemcached(t, server.Addr())
+
+	mc.expect("set doc 0 0 2\r\nv1\r\n", "STORED")
+	mc.send("gets doc\r\n")
+	lines := mc.readUntilEnd()
+	if len(lines) != 2 {
+		t.Fatalf("❌ gets响应错误: %q", lines)
+	}
+	var key string
+	var flags, size int
+	var cas uint64
+	if _, err := fmt.Sscanf(lines[0], "VALUE %s %d %d %d", &key, &flags, &size, &cas); err != nil || cas == 0 {
+		t.Fatalf("❌ 解析cas失败: %q (%v)", lines[0], err)
+	}
+
+	mc.expect(fmt.Sprintf("cas doc 0 0 2 %d\r\nv2\r\n", cas), "STORED")
+	// 旧的cas值已失效
+	mc.expect(fmt.Sprintf("cas doc 0 0 2 %d\r\nv3\r\n", cas), "EXISTS")
+	mc.expect("cas missing 0 0 2 1\r\nv1\r\n", "NOT_FOUND")
+
+	mc.send("get doc\r\n")
+	if lines := mc.readUntilEnd(); len(lines) != 2 || lines[1] != "v2" {
+		t.Errorf("❌ cas写入结果错误: %q", lines)
+	}
+	t.Log("✅ memcached CAS测试通过")
+}
+
+// TestMemcachedExpiration 测试exptime和touch
+func TestMemcachedExpiration(t *testing.T) {
+	_, server := startMemcachedCluster(t)
+	mc := dialMemcached(t, server.Addr())
+
+	mc.expect("set short 0 1 1\r\nx\r\n", "STORED")
+	mc.expect("set gone 0 -1 1\r\nx\r\n", "STORED")
+	// Unix时间戳形式的过期时间
+	mc.expect(fmt.Sprintf("set absolute 0 %d 1\r\nx\r\n", time.Now().Add(time.Hour).Unix()), "STORED")
+	mc.expect("set kept 0 1 1\r\nx\r\n", "STORED")
+	mc.expect("touch kept 0\r\n", "TOUCHED")
+	mc.expect("touch missing 10\r\n", "NOT_FOUND")
+
+	time.Sleep(1200 * time.Millisecond)
+
+	mc.send("get short gone absolute kept\r\n")
+	lines := mc.readUntilEnd()
+	got := make(map[string]bool)
+	for _, line := range lines {
+		if strings.HasPrefix(line, "VALUE ") {
+			got[strings.Fields(line)[1]] = true
+		}
+	}
+	if got["short"] || got["gone"] {
+		t.Errorf("❌ 过期key仍可读取: %q", lines)
+	}
+	if !got["absolute"] || !got["kept"] {
+		t.Errorf("❌ 未过期key丢失: %q", lines)
+	}
+
+	// 已过期的条件写入：条件满足时返回STORED并删除key，条件不满足时不修改
+	mc.expect("set doc 0 0 2\r\nv1\r\n", "STORED")
+	mc.expect("add doc 0 -1 2\r\nv2\r\n", "NOT_STORED")
+	mc.expect("add fresh 0 -1 2\r\nv2\r\n", "STORED")
+	mc.expect("replace missing 0 -1 2\r\nv2\r\n", "NOT_STORED")
+	mc.send("get doc fresh\r\n")
+	if lines := mc.readUntilEnd(); len(lines) != 2 || lines[1] != "v1" {
+		t.Errorf("❌ 过期的条件写入结果错误: %q", lines)
+	}
+	mc.expect("replace doc 0 -1 2\r\nv2\r\n", "STORED")
+	mc.send("get doc\r\n")
+	if lines := mc.readUntilEnd(); len(lines) != 0 {
+		t.Errorf("❌ 过期的replace后key仍可读取: %q", lines)
+	}
+	t.Log("✅ memcached过期时间测试通过")
+}
+
+// TestMemcachedRoutesToOwner 测试非本地key透明转发到负责节点
+func TestMemcachedRoutesToOwner(t *testing.T) {
+	cluster, server := startMemcachedCluster(t)
+	mc := dialMemcached(t, server.Addr())
+
+	key := keyOwnedBy(t, []string{"node1", "node2"}, "node2", "remote")
+	mc.expect(fmt.Sprintf("set %s 3 0 6\r\nremote\r\n", key), "STORED")
+
+	// 数据应落在node2本地
+	result, err := cluster.Node("node2").ExecuteLocal(key, distributed.CacheOp{Op: distributed.OpGet})
+	if err != nil || result.Status != distributed.OpStatusFound || result.Value != "remote" || result.Flags != 3 {
+		t.Fatalf("❌ 数据未写入负责节点: %+v (%v)", result, err)
+	}
+	local, _ := cluster.Node("node1").ExecuteLocal(key, distributed.CacheOp{Op: distributed.OpGet})
+	if local.Status != distributed.OpStatusNotFound {
+		t.Errorf("❌ 转发的数据不应写入node1: %+v", local)
+	}
+
+	// 条件操作在远端原子执行
+	mc.expect(fmt.Sprintf("add %s 0 0 1\r\nx\r\n", key), "NOT_STORED")
+	mc.send(fmt.Sprintf("gets %s\r\n", key))
+	lines := mc.readUntilEnd()
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], fmt.Sprintf(" %d", result.CAS)) {
+		t.Errorf("❌ 远端gets的cas值错误: %q, 期望 %d", lines, result.CAS)
+	}
+
+	mc.send("stats\r\n")
+	stats := mc.readUntilEnd()
+	if len(stats) == 0 || !strings.HasPrefix(stats[0], "STAT ") {
+		t.Errorf("❌ stats响应错误: %q", stats)
+	}
+	t.Logf("📊 memcached stats 共 %d 项", len(stats))
+	t.Log("✅ memcached转发测试通过")
+}
+
+// TestMemcachedPartialGetFailure 测试多key读取中部分key失败时响应仍以END结束
+func TestMemcachedPartialGetFailure(t *testing.T) {
+	cluster, server := startMemcachedCluster(t)
+	mc := dialMemcached(t, server.Addr())
+
+	nodeIDs := []string{"node1", "node2"}
+	local := keyOwnedBy(t, nodeIDs, "node1", "local")
+	remote := keyOwnedBy(t, nodeIDs, "node2", "remote")
+	mc.expect(fmt.Sprintf("set %s 0 0 1\r\na\r\n", local), "STORED")
+	mc.expect(fmt.Sprintf("set %s 0 0 1\r\nb\r\n", remote), "STORED")
+
+	cluster.Stop("node2")
+
+	// 远端key读取失败时跳过，之后的key照常返回
+	mc.send(fmt.Sprintf("get %s %s %s\r\n", local, remote, local))
+	lines := mc.readUntilEnd()
+	if len(lines) != 4 || lines[1] != "a" || lines[3] != "a" {
+		t.Errorf("❌ 部分失败的get响应错误: %q", lines)
+	}
+	// 连接上的下一条命令应得到自己的响应
+	mc.expect("version\r\n", "VERSION 1.6.0-tdd-learning")
+
+	// 单key读取失败仍返回错误
+	mc.send(fmt.Sprintf("get %s\r\n", remote))
+	if line := mc.line(); !strings.HasPrefix(line, "SERVER_ERROR ") {
+		t.Errorf("❌ 单key读取失败应返回SERVER_ERROR，实际 %q", line)
+	}
+	t.Log("✅ memcached部分失败读取测试通过")
+}
+
+// TestMemcachedReplicated 测试开启多副本时memcached写入、条件操作和删除复制到所有副本
+func TestMemcachedReplicated(t *testing.T) {
+	cluster := replicatedCluster(t)
+	server := distributed.NewMemcachedServer(cluster.Node("node1"), "127.0.0.1:0")
+	if err := server.Start(); err != nil {
+		t.Fatalf("❌ 启动memcached服务失败: %v", err)
+	}
+	t.Cleanup(server.Stop)
+	mc := dialMemcached(t, server.Addr())
+	nodeIDs := []string{"node1", "node2", "node3"}
+
+	// 带标志和过期时间的写入复制到所有副本，各副本版本和过期时刻一致
+	mc.expect("set doc 5 60 2\r\nv1\r\n", "STORED")
+	mc.expect("set counter 0 0 1\r\n1\r\n", "STORED")
+	mc.expect("incr counter 4\r\n", "5")
+	// W=2 时第三个副本可能在返回后才写入
+	time.Sleep(100 * time.Millisecond)
+
+	first, _ := cluster.Node("node1").GetLocalVersioned("doc")
+	for _, nodeID := range nodeIDs {
+		node := cluster.Node(nodeID)
+		entry, found := node.GetLocalVersioned("doc")
+		if !found || entry.Value != "v1" || entry.Flags != 5 || entry.ExpiresAt == 0 {
+			t.Errorf("❌ %s 的副本错误: %+v %v", nodeID, entry, found)
+		}
+		if entry.Version != first.Version || entry.ExpiresAt != first.ExpiresAt {
+			t.Errorf("❌ %s 的版本或过期时间与其他副本不一致: %+v != %+v", nodeID, entry, first)
+		}
+		if value, found := node.GetLocal("counter"); !found || value != "5" {
+			t.Errorf("❌ %s 缺少自增结果: %q %v", nodeID, value, found)
+		}
+	}
+
+	// 删除在所有副本上写入删除标记
+	mc.expect("delete doc\r\n", "DELETED")
+	time.Sleep(100 * time.Millisecond)
+	for _, nodeID := range nodeIDs {
+		if entry, found := cluster.Node(nodeID).GetLocalVersioned("doc"); !found || !entry.Tombstone {
+			t.Errorf("❌ %s 应保存删除标记: %+v %v", nodeID, entry, found)
+		}
+	}
+	mc.expect("delete doc\r\n", "NOT_FOUND")
+	mc.send("get doc\r\n")
+	if lines := mc.readUntilEnd(); len(lines) != 0 {
+		t.Errorf("❌ 删除后不应读到数据: %q", lines)
+	}
+
+	// 已过期的条件写入以删除标记复制到所有副本
+	mc.expect("replace counter 0 -1 1\r\nx\r\n", "STORED")
+	time.Sleep(100 * time.Millisecond)
+	for _, nodeID := range nodeIDs {
+		if entry, found := cluster.Node(nodeID).GetLocalVersioned("counter"); !found || !entry.Tombstone {
+			t.Errorf("❌ %s 过期的replace应保存删除标记: %+v %v", nodeID, entry, found)
+		}
+	}
+	t.Log("✅ memcached 多副本测试通过")
+}
@@ -0,0 +1,190 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"tdd-learning/core"
+	"tdd-learning/distributed"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TestWeightedHashRing 测试虚拟节点数按权重缩放，数据量与权重成比例
+func TestWeightedHashRing(t *testing.T) {
+	nodes := []string{"small", "large"}
+	dc := core.NewDistributedCacheWithVirtualNodes(nodes, 150)
+	if _, err := dc.SetNodeWeight("large", 4); err != nil {
+		t.Fatalf("❌ 设置权重失败: %v", err)
+	}
+
+	if dc.GetVirtualNodeCount("small") != 150 || dc.GetVirtualNodeCount("large") != 600 {
+		t.Errorf("❌ 虚拟节点数错误: small=%d large=%d", dc.GetVirtualNodeCount("small"), dc.GetVirtualNodeCount("large"))
+	}
+
+	counts := make(map[string]int)
+	for i := 0; i < 50000; i++ {
+		counts[dc.GetNodeForKey(fmt.Sprintf("key:%d", i))]++
+	}
+	ratio := float64(counts["large"]) / float64(counts["small"])
+	t.Logf("📊 数据分布: %v, 比例 %.2f", counts, ratio)
+	if ratio < 3 || ratio > 5 {
+		t.Errorf("❌ 数据分布与权重不成比例: %.2f", ratio)
+	}
+
+	for _, weight := range []float64{0, -1} {
+		if _, err := dc.SetNodeWeight("small", weight); err == nil {
+			t.Errorf("❌ 权重 %v 应返回错误", weight)
+		}
+	}
+	t.Log("✅ 带权重哈希环测试通过")
+}
+
+// TestSetNodeWeightMigratesAffectedKeys 测试修改权重只迁移归属变化的key
+func TestSetNodeWeightMigratesAffectedKeys(t *testing.T) {
+	nodes := []string{"node1", "node2", "node3"}
+	dc := core.NewDistributedCacheWithVirtualNodes(nodes, 150)
+
+	before := make(map[string]string)
+	// 每个节点的本地缓存容量为1000，数据量需保证迁移后不触发淘汰
+	for i := 0; i < 1500; i++ {
+		key := fmt.Sprintf("key:%d", i)
+		dc.Set(key, "value")
+		before[key] = dc.GetNodeForKey(key)
+	}
+
+	migrated, err := dc.SetNodeWeight("node2", 2)
+	if err != nil {
+		t.Fatalf("❌ 设置权重失败: %v", err)
+	}
+
+	changed := 0
+	for key, oldOwner := range before {
+		newOwner := dc.GetNodeForKey(key)
+		if newOwner != oldOwner {
+			changed++
+			// 权重增加时，只有其他节点的数据会转移到node2
+			if newOwner != "node2" {
+				t.Errorf("❌ key %s 从 %s 迁移到了 %s", key, oldOwner, newOwner)
+			}
+		}
+		if value, found, _ := dc.Get(key); !found || value != "value" {
+			t.Errorf("❌ 迁移后数据丢失: %s", key)
+		}
+	}
+	t.Logf("📊 归属变化 %d 个key，迁移 %d 个key", changed, migrated)
+	if changed == 0 || migrated != changed {
+		t.Errorf("❌ 迁移数量应等于归属变化的key数量: changed=%d migrated=%d", changed, migrated)
+	}
+
+	// 降回原权重，所有key恢复原来的归属
+	dc.SetNodeWeight("node2", 1)
+	for key, oldOwner := range before {
+		if dc.GetNodeForKey(key) != oldOwner {
+			t.Fatalf("❌ 恢复权重后key归属不一致: %s", key)
+		}
+	}
+	t.Log("✅ 权重修改迁移测试通过")
+}
+
+// TestClusterNodesYAML 测试 cluster_nodes 支持地址字符串和带权重的对象两种写法
+func TestClusterNodesYAML(t *testing.T) {
+	data := `
+node_id: "node1"
+address: ":8001"
+cache_size: 500
+cluster_nodes:
+  node1: "localhost:8001"
+  node2: {address: "localhost:8002", weight: 4}
+  node3:
+    address: "localhost:8003"
+    weight: 0.5
+`
+	var config distributed.NodeConfig
+	if err := yaml.Unmarshal([]byte(data), &config); err != nil {
+		t.Fatalf("❌ 解析配置失败: %v", err)
+	}
+
+	if config.NodeID != "node1" || config.CacheSize != 500 {
+		t.Errorf("❌ 其他字段解析错误: %+v", config)
+	}
+	expected := map[string]string{"node1": "localhost:8001", "node2": "localhost:8002", "node3": "localhost:8003"}
+	for nodeID, address := range expected {
+		if config.ClusterNodes[nodeID] != address {
+			t.Errorf("❌ 节点 %s 地址错误: %q", nodeID, config.ClusterNodes[nodeID])
+		}
+	}
+	if len(config.NodeWeights) != 2 || config.NodeWeights["node2"] != 4 || config.NodeWeights["node3"] != 0.5 {
+		t.Errorf("❌ 权重解析错误: %v", config.NodeWeights)
+	}
+
+	node := distributed.NewDistributedNode(config)
+	if weights := node.GetNodeWeights(); weights["node1"] != 1 || weights["node2"] != 4 {
+		t.Errorf("❌ 节点权重未生效: %v", weights)
+	}
+	t.Log("✅ cluster_nodes 配置解析测试通过")
+}
+
+// TestSetNodeWeightAPI 测试在线修改节点权重并迁移数据
+func TestSetNodeWeightAPI(t *testing.T) {
+	nodeIDs := []string{"node1", "node2"}
+	cluster := startTestNodes(t, nodeIDs, nil)
+
+	keys := make([]string, 0, 500)
+	for i := 0; i < 500; i++ {
+		key := fmt.Sprintf("weight:%d", i)
+		keys = append(keys, key)
+		if err := cluster.Node("node1").Set(key, "v-"+key); err != nil {
+			t.Fatalf("❌ 写入失败: %v", err)
+		}
+	}
+
+	setWeight := func(nodeID string, body string) (int, map[string]interface{}) {
+		req, _ := http.NewRequest(http.MethodPut, cluster.URL(nodeID)+"/admin/nodes/node2/weight", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result
+	}
+
+	if status, _ := setWeight("node1", `{"weight": -1}`); status != http.StatusBadRequest {
+		t.Errorf("❌ 负权重应返回400: %d", status)
+	}
+
+	// 测试集群未启动健康检查，广播会跳过状态未知的节点，因此逐个节点修改
+	total := 0.0
+	for _, nodeID := range nodeIDs {
+		status, result := setWeight(nodeID, `{"weight": 3}`)
+		if status != http.StatusOK {
+			t.Fatalf("❌ 修改权重失败: %d %v", status, result)
+		}
+		if result["virtual_nodes"] != float64(450) {
+			t.Errorf("❌ 虚拟节点数错误: %v", result["virtual_nodes"])
+		}
+		total += result["migrated_count"].(float64)
+	}
+	t.Logf("📊 共迁移 %.0f 个key", total)
+	if total == 0 {
+		t.Error("❌ 提高权重后应有数据迁移到node2")
+	}
+
+	// 所有数据都在新的负责节点上
+	for _, key := range keys {
+		owner := cluster.Node("node1").GetNodeForKey(key)
+		if cluster.Node("node2").GetNodeForKey(key) != owner {
+			t.Fatalf("❌ 节点间哈希环不一致: %s", key)
+		}
+		if value, found := cluster.Node(owner).GetLocal(key); !found || value != "v-"+key {
+			t.Errorf("❌ key %s 不在负责节点 %s 上", key, owner)
+		}
+	}
+	t.Log("✅ 在线修改节点权重测试通过")
+}
@@ -0,0 +1,212 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"tdd-learning/core"
+	"tdd-learning/distributed"
+)
+
+// newPlacementCache 创建指定放置算法的分布式缓存
+func newPlacementCache(t testing.TB, nodes []string, placement string) *core.DistributedCache {
+	t.Helper()
+	hasher, _ := core.NewHasher(core.HasherXXHash)
+	dc, err := core.NewDistributedCacheWithPlacement(nodes, 150, hasher, placement)
+	if err != nil {
+		t.Fatalf("❌ 创建放置算法 %s 失败: %v", placement, err)
+	}
+	return dc
+}
+
+// TestPlacementDistribution 测试各放置算法分布均匀，且结果与节点顺序无关
+func TestPlacementDistribution(t *testing.T) {
+	nodes := []string{"node1", "node2", "node3", "node4", "node5"}
+	reversed := []string{"node5", "node4", "node3", "node2", "node1"}
+
+	for _, placement := range core.PlacementNames {
+		dc := newPlacementCache(t, nodes, placement)
+		other := newPlacementCache(t, reversed, placement)
+		if dc.PlacementName() != placement {
+			t.Errorf("❌ 放置算法名称错误: %s", dc.PlacementName())
+		}
+
+		counts := make(map[string]int)
+		for i := 0; i < 50000; i++ {
+			key := fmt.Sprintf("user:%d", i)
+			owner := dc.GetNodeForKey(key)
+			counts[owner]++
+			if other.GetNodeForKey(key) != owner {
+				t.Fatalf("❌ %s 的结果依赖节点顺序: %s", placement, key)
+			}
+		}
+		t.Logf("📊 %s 分布: %v", placement, counts)
+		for _, node := range nodes {
+			if counts[node] < 7500 || counts[node] > 12500 {
+				t.Errorf("❌ %s 分布不均匀: %v", placement, counts)
+				break
+			}
+		}
+	}
+
+	if _, err := core.NewDistributedCacheWithPlacement(nodes, 150, nil, "random"); err == nil {
+		t.Error("❌ 未知放置算法应返回错误")
+	}
+	t.Log("✅ 放置算法分布测试通过")
+}
+
+// TestPlacementMinimalDisruption 测试增加节点时迁移量接近 1/(n+1)，且主要迁移到新节点
+func TestPlacementMinimalDisruption(t *testing.T) {
+	nodes := []string{"node1", "node2", "node3", "node4"}
+	const total = 20000
+
+	for _, placement := range core.PlacementNames {
+		dc := newPlacementCache(t, nodes, placement)
+		before := make([]string, total)
+		for i := range before {
+			before[i] = dc.GetNodeForKey(fmt.Sprintf("key:%d", i))
+		}
+
+		dc.AddNode("node5")
+
+		moved, movedToNew := 0, 0
+		for i := range before {
+			owner := dc.GetNodeForKey(fmt.Sprintf("key:%d", i))
+			if owner != before[i] {
+				moved++
+				if owner == "node5" {
+					movedToNew++
+				}
+			}
+		}
+		t.Logf("📊 %s 迁移 %d/%d，其中迁移到新节点 %d", placement, moved, total, movedToNew)
+
+		// 理想迁移比例为 1/5
+		if moved > total*3/10 || movedToNew < total/8 {
+			t.Errorf("❌ %s 迁移量异常: moved=%d toNew=%d", placement, moved, movedToNew)
+		}
+		if placement != core.PlacementMaglev && moved != movedToNew {
+			t.Errorf("❌ %s 的key不应在已有节点之间迁移", placement)
+		}
+	}
+	t.Log("✅ 放置算法迁移量测试通过")
+}
+
+// TestPlacementGenericMigration 测试任意放置算法在增删节点后数据都在负责节点上
+func TestPlacementGenericMigration(t *testing.T) {
+	for _, placement := range core.PlacementNames {
+		dc := newPlacementCache(t, []string{"node1", "node2", "node3"}, placement)
+		for i := 0; i < 1000; i++ {
+			dc.Set(fmt.Sprintf("key:%d", i), fmt.Sprintf("value:%d", i))
+		}
+
+		dc.AddNode("node4")
+		dc.RemoveNode("node2")
+
+		for i := 0; i < 1000; i++ {
+			key := fmt.Sprintf("key:%d", i)
+			if value, found, err := dc.Get(key); err != nil || !found || value != fmt.Sprintf("value:%d", i) {
+				t.Fatalf("❌ %s 迁移后数据丢失: %s", placement, key)
+			}
+		}
+		stats := dc.GetMigrationStats()
+		t.Logf("📊 %s 迁移了 %d 个key", placement, stats.MigratedKeys)
+	}
+	t.Log("✅ 通用数据迁移测试通过")
+}
+
+// TestPlacementWeights 测试Rendezvous/Maglev按权重分配，Jump拒绝权重
+func TestPlacementWeights(t *testing.T) {
+	for _, placement := range []string{core.PlacementRendezvous, core.PlacementMaglev} {
+		dc := newPlacementCache(t, []string{"small", "large"}, placement)
+		if _, err := dc.SetNodeWeight("large", 3); err != nil {
+			t.Fatalf("❌ %s 设置权重失败: %v", placement, err)
+		}
+
+		counts := make(map[string]int)
+		for i := 0; i < 40000; i++ {
+			counts[dc.GetNodeForKey(fmt.Sprintf("key:%d", i))]++
+		}
+		ratio := float64(counts["large"]) / float64(counts["small"])
+		t.Logf("📊 %s 权重分布: %v, 比例 %.2f", placement, counts, ratio)
+		if ratio < 2.5 || ratio > 3.5 {
+			t.Errorf("❌ %s 分布与权重不成比例: %.2f", placement, ratio)
+		}
+	}
+
+	dc := newPlacementCache(t, []string{"node1", "node2"}, core.PlacementJump)
+	if _, err := dc.SetNodeWeight("node1", 2); err == nil {
+		t.Error("❌ jump 不支持权重，应返回错误")
+	}
+	t.Log("✅ 放置算法权重测试通过")
+}
+
+// TestPlacementCluster 测试节点使用配置的放置算法，且拒绝放置算法不一致的节点加入
+func TestPlacementCluster(t *testing.T) {
+	nodeIDs := []string{"node1", "node2", "node3"}
+	cluster := startTestNodes(t, nodeIDs, func(config *distributed.NodeConfig) {
+		config.Placement = core.PlacementMaglev
+	})
+
+	for i := 0; i < 200; i++ {
+		key := fmt.Sprintf("maglev:%d", i)
+		if err := cluster.Node("node1").Set(key, "value"); err != nil {
+			t.Fatalf("❌ 写入失败: %v", err)
+		}
+		owner := cluster.Node("node2").GetNodeForKey(key)
+		if _, found := cluster.Node(owner).GetLocal(key); !found {
+			t.Errorf("❌ key %s 不在负责节点 %s 上", key, owner)
+		}
+	}
+
+	resp, err := http.Get(cluster.URL("node1") + "/api/v1/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var health map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&health)
+	resp.Body.Close()
+	if health["placement"] != core.PlacementMaglev {
+		t.Errorf("❌ 健康检查未返回放置算法: %v", health)
+	}
+
+	body := `{"node_id":"node4","address":"127.0.0.1:1","placement":"ring"}`
+	resp, err = http.Post(cluster.URL("node1")+"/internal/cluster/join", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("❌ 放置算法不一致应返回409: %d", resp.StatusCode)
+	}
+
+	server := distributed.NewNodeServer(distributed.NodeConfig{
+		NodeID:       "node4",
+		Address:      "127.0.0.1:1",
+		ClusterNodes: map[string]string{"node1": cluster.addrs["node1"], "node4": "127.0.0.1:1"},
+		Placement:    core.PlacementRendezvous,
+	})
+	if err := server.GetCluster().VerifyRingConfig(); err == nil {
+		t.Error("❌ 放置算法不一致应拒绝启动")
+	}
+	t.Log("✅ 集群放置算法测试通过")
+}
+
+// BenchmarkPlacement 比较各放置算法的查找开销
+func BenchmarkPlacement(b *testing.B) {
+	nodes := make([]string, 20)
+	for i := range nodes {
+		nodes[i] = fmt.Sprintf("node%d", i)
+	}
+	for _, placement := range core.PlacementNames {
+		dc := newPlacementCache(b, nodes, placement)
+		b.Run(placement, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				dc.GetNodeForKey("user:session:1234567890")
+			}
+		})
+	}
+}
@@ -0,0 +1,749 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"tdd-learning/distributed"
+)
+
+// raftLogFSM 记录应用顺序的测试状态机
+type raftLogFSM struct {
+	mu      sync.Mutex
+	applied []string
+}
+
+func (f *raftLogFSM) Apply(index uint64, command []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.applied = append(f.applied, string(command))
+}
+
+func (f *raftLogFSM) Snapshot() ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return json.Marshal(f.applied)
+}
+
+func (f *raftLogFSM) Restore(data []byte) error {
+	var applied []string
+	if err := json.Unmarshal(data, &applied); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.applied = applied
+	return nil
+}
+
+func (f *raftLogFSM) commands() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.applied...)
+}
+
+// raftTestCluster 进程内Raft集群
+type raftTestCluster struct {
+	network *distributed.InMemoryRaftNetwork
+	nodes   map[string]*distributed.Raft
+	fsms    map[string]*raftLogFSM
+	peers   map[string]string
+	dir     string
+	// snapshotThreshold 生成快照的阈值，0表示默认
+	snapshotThreshold int
+}
+
+// startRaftCluster 启动使用进程内网络的Raft集群
+func startRaftCluster(t *testing.T, nodeIDs []string) *raftTestCluster {
+	t.Helper()
+	rc := &raftTestCluster{
+		network: distributed.NewInMemoryRaftNetwork(),
+		nodes:   make(map[string]*distributed.Raft),
+		fsms:    make(map[string]*raftLogFSM),
+		peers:   make(map[string]string),
+	}
+	for _, nodeID := range nodeIDs {
+		rc.peers[nodeID] = nodeID
+	}
+	for _, nodeID := range nodeIDs {
+		rc.start(t, nodeID, rc.peers)
+	}
+	t.Cleanup(func() {
+		for _, node := range rc.nodes {
+			node.Stop()
+		}
+	})
+	return rc
+}
+
+// start 创建并启动一个Raft节点
+func (rc *raftTestCluster) start(t *testing.T, nodeID string, peers map[string]string) *distributed.Raft {
+	t.Helper()
+	fsm := &raftLogFSM{}
+	node, err := distributed.NewRaft(distributed.RaftConfig{
+		NodeID:            nodeID,
+		Peers:             peers,
+		ElectionTimeout:   150 * time.Millisecond,
+		HeartbeatInterval: 30 * time.Millisecond,
+		CommitTimeout:     2 * time.Second,
+		Dir:               rc.dir,
+		SnapshotThreshold: rc.snapshotThreshold,
+	}, fsm, rc.network.Transport(nodeID))
+	if err != nil {
+		t.Fatalf("❌ 创建Raft节点失败: %v", err)
+	}
+	rc.network.Register(node)
+	node.Start()
+	rc.nodes[nodeID] = node
+	rc.fsms[nodeID] = fsm
+	return node
+}
+
+// waitForLeader 等待连通的节点中选出唯一的领导者
+func (rc *raftTestCluster) waitForLeader(t *testing.T, nodeIDs ...string) string {
+	t.Helper()
+	var leader string
+	if !waitForCondition(3*time.Second, func() bool {
+		leader = ""
+		for _, nodeID := range nodeIDs {
+			status := rc.nodes[nodeID].Status()
+			if status.State == distributed.RaftLeader {
+				if leader != "" {
+					return false
+				}
+				leader = nodeID
+			}
+		}
+		if leader == "" {
+			return false
+		}
+		for _, nodeID := range nodeIDs {
+			if rc.nodes[nodeID].Leader() != leader {
+				return false
+			}
+		}
+		return true
+	}) {
+		t.Fatalf("❌ 未能在 %v 中选出领导者", nodeIDs)
+	}
+	return leader
+}
+
+// waitForApplied 等待节点应用指定的命令序列
+func (rc *raftTestCluster) waitForApplied(t *testing.T, expected []string, nodeIDs ...string) {
+	t.Helper()
+	want := strings.Join(expected, ",")
+	for _, nodeID := range nodeIDs {
+		if !waitForCondition(3*time.Second, func() bool {
+			return strings.Join(rc.fsms[nodeID].commands(), ",") == want
+		}) {
+			t.Fatalf("❌ %s 应用的命令 %v，期望 %v", nodeID, rc.fsms[nodeID].commands(), expected)
+		}
+	}
+}
+
+// TestRaftLeaderElectionAndReplication 测试选出唯一领导者，任意节点的提议按相同顺序应用到所有节点
+func TestRaftLeaderElectionAndReplication(t *testing.T) {
+	nodeIDs := []string{"node1", "node2", "node3"}
+	rc := startRaftCluster(t, nodeIDs)
+	leader := rc.waitForLeader(t, nodeIDs...)
+	t.Logf("📊 领导者: %s，任期 %d", leader, rc.nodes[leader].Status().Term)
+
+	// 并发从所有节点提议，跟随者转发给领导者
+	var wg sync.WaitGroup
+	errs := make(chan error, 30)
+	for _, nodeID := range nodeIDs {
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(nodeID string, i int) {
+				defer wg.Done()
+				if _, err := rc.nodes[nodeID].Propose([]byte(fmt.Sprintf("%s-%d", nodeID, i))); err != nil {
+					errs <- err
+				}
+			}(nodeID, i)
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("❌ 提议失败: %v", err)
+	}
+
+	expected := rc.fsms[leader].commands()
+	if len(expected) != 30 {
+		t.Fatalf("❌ 领导者应用了 %d 条命令，期望30", len(expected))
+	}
+	rc.waitForApplied(t, expected, nodeIDs...)
+	t.Log("✅ 所有节点按相同顺序应用了30条并发提议")
+}
+
+// TestRaftLeaderFailover 测试领导者断开后重新选举，旧领导者未提交的条目被覆盖
+func TestRaftLeaderFailover(t *testing.T) {
+	nodeIDs := []string{"node1", "node2", "node3"}
+	rc := startRaftCluster(t, nodeIDs)
+	oldLeader := rc.waitForLeader(t, nodeIDs...)
+	if _, err := rc.nodes[oldLeader].Propose([]byte("a")); err != nil {
+		t.Fatalf("❌ 提议失败: %v", err)
+	}
+
+	// 旧领导者被隔离后仍接受提议，但无法提交
+	rc.network.Disconnect(oldLeader)
+	lost := make(chan error, 1)
+	go func() {
+		_, err := rc.nodes[oldLeader].Propose([]byte("lost"))
+		lost <- err
+	}()
+
+	var others []string
+	for _, nodeID := range nodeIDs {
+		if nodeID != oldLeader {
+			others = append(others, nodeID)
+		}
+	}
+	newLeader := rc.waitForLeader(t, others...)
+	if _, err := rc.nodes[others[0]].Propose([]byte("b")); err != nil {
+		t.Fatalf("❌ 新领导者选出后提议失败: %v", err)
+	}
+	t.Logf("📊 新领导者: %s，任期 %d", newLeader, rc.nodes[newLeader].Status().Term)
+
+	// 旧领导者恢复连接后退位，未提交的条目被新领导者的日志覆盖
+	rc.network.Reconnect(oldLeader)
+	if err := <-lost; err == nil {
+		t.Error("❌ 隔离期间的提议不应成功")
+	}
+	rc.waitForApplied(t, []string{"a", "b"}, nodeIDs...)
+	if state := rc.nodes[oldLeader].Status().State; state == distributed.RaftLeader {
+		t.Errorf("❌ 旧领导者应退位: %s", state)
+	}
+	t.Log("✅ 领导者故障后重新选举，旧领导者的未提交条目被丢弃")
+}
+
+// TestRaftPreVotePreventsDisruption 测试被隔离的跟随者恢复连接后不会推高任期打断领导者
+func TestRaftPreVotePreventsDisruption(t *testing.T) {
+	nodeIDs := []string{"node1", "node2", "node3"}
+	rc := startRaftCluster(t, nodeIDs)
+	leader := rc.waitForLeader(t, nodeIDs...)
+	term := rc.nodes[leader].Status().Term
+
+	var follower string
+	for _, nodeID := range nodeIDs {
+		if nodeID != leader {
+			follower = nodeID
+			break
+		}
+	}
+	rc.network.Disconnect(follower)
+	time.Sleep(time.Second) // 多个选举超时
+	if followerTerm := rc.nodes[follower].Status().Term; followerTerm != term {
+		t.Errorf("❌ 预投票未通过时不应递增任期: %d -> %d", term, followerTerm)
+	}
+	rc.network.Reconnect(follower)
+
+	if _, err := rc.nodes[follower].Propose([]byte("after-partition")); err != nil {
+		t.Fatalf("❌ 提议失败: %v", err)
+	}
+	rc.waitForApplied(t, []string{"after-partition"}, nodeIDs...)
+	if status := rc.nodes[leader].Status(); status.State != distributed.RaftLeader || status.Term != term {
+		t.Errorf("❌ 领导者不应被打断: %+v", status)
+	}
+	t.Log("✅ 隔离的跟随者恢复后领导者和任期不变")
+}
+
+// TestRaftMembershipChange 测试逐个增删投票成员，新成员追上完整日志
+func TestRaftMembershipChange(t *testing.T) {
+	nodeIDs := []string{"node1", "node2", "node3"}
+	rc := startRaftCluster(t, nodeIDs)
+	leader := rc.waitForLeader(t, nodeIDs...)
+	if _, err := rc.nodes[leader].Propose([]byte("before-join")); err != nil {
+		t.Fatalf("❌ 提议失败: %v", err)
+	}
+
+	// 新节点只知道自己和现有成员，加入前不会发起选举打断集群
+	joinPeers := map[string]string{"node4": "node4"}
+	for nodeID, address := range rc.peers {
+		joinPeers[nodeID] = address
+	}
+	rc.start(t, "node4", joinPeers)
+	if _, err := rc.nodes["node2"].ProposeMembership([]byte("add-node4"), distributed.RaftMembershipChange{NodeID: "node4", Address: "node4"}); err != nil {
+		t.Fatalf("❌ 添加成员失败: %v", err)
+	}
+	all := []string{"node1", "node2", "node3", "node4"}
+	rc.waitForApplied(t, []string{"before-join", "add-node4"}, all...)
+	for _, nodeID := range all {
+		if peers := rc.nodes[nodeID].Status().Peers; len(peers) != 4 {
+			t.Errorf("❌ %s 的投票成员应为4个: %v", nodeID, peers)
+		}
+	}
+	t.Log("✅ 新成员加入并追上日志")
+
+	// 重复的变更不改变成员集合
+	if _, err := rc.nodes["node3"].ProposeMembership([]byte("add-node4-again"), distributed.RaftMembershipChange{NodeID: "node4", Address: "node4"}); err != nil {
+		t.Fatalf("❌ 重复添加成员失败: %v", err)
+	}
+
+	// 移除领导者：变更提交后它退位，其余节点选出新领导者
+	if _, err := rc.nodes[leader].ProposeMembership([]byte("remove-"+leader), distributed.RaftMembershipChange{NodeID: leader, Remove: true}); err != nil {
+		t.Fatalf("❌ 移除成员失败: %v", err)
+	}
+	var remaining []string
+	for _, nodeID := range all {
+		if nodeID != leader {
+			remaining = append(remaining, nodeID)
+		}
+	}
+	newLeader := rc.waitForLeader(t, remaining...)
+	if _, err := rc.nodes[newLeader].Propose([]byte("after-remove")); err != nil {
+		t.Fatalf("❌ 移除成员后提议失败: %v", err)
+	}
+	rc.waitForApplied(t, []string{"before-join", "add-node4", "add-node4-again", "remove-" + leader, "after-remove"}, remaining...)
+	if peers := rc.nodes[newLeader].Status().Peers; len(peers) != 3 {
+		t.Errorf("❌ 移除后投票成员应为3个: %v", peers)
+	}
+	if _, voter := rc.nodes[newLeader].Status().Peers[leader]; voter {
+		t.Errorf("❌ %s 应已被移出投票成员", leader)
+	}
+	t.Logf("📊 移除 %s 后的领导者: %s", leader, newLeader)
+}
+
+// TestRaftPersistence 测试节点重启后从持久化的日志恢复状态
+func TestRaftPersistence(t *testing.T) {
+	nodeIDs := []string{"node1", "node2", "node3"}
+	rc := &raftTestCluster{
+		network: distributed.NewInMemoryRaftNetwork(),
+		nodes:   make(map[string]*distributed.Raft),
+		fsms:    make(map[string]*raftLogFSM),
+		peers:   map[string]string{"node1": "node1", "node2": "node2", "node3": "node3"},
+		dir:     t.TempDir(),
+	}
+	for _, nodeID := range nodeIDs {
+		rc.start(t, nodeID, rc.peers)
+	}
+	t.Cleanup(func() {
+		for _, node := range rc.nodes {
+			node.Stop()
+		}
+	})
+
+	leader := rc.waitForLeader(t, nodeIDs...)
+	for _, command := range []string{"x", "y"} {
+		if _, err := rc.nodes[leader].Propose([]byte(command)); err != nil {
+			t.Fatalf("❌ 提议失败: %v", err)
+		}
+	}
+	rc.waitForApplied(t, []string{"x", "y"}, nodeIDs...)
+	term := rc.nodes[leader].Status().Term
+
+	// 所有节点重启，从文件加载日志后重新选举并重放
+	for _, nodeID := range nodeIDs {
+		rc.nodes[nodeID].Stop()
+	}
+	for _, nodeID := range nodeIDs {
+		rc.start(t, nodeID, rc.peers)
+	}
+	leader = rc.waitForLeader(t, nodeIDs...)
+	if status := rc.nodes[leader].Status(); status.Term <= term || status.LastIndex < 3 {
+		t.Errorf("❌ 重启后应保留任期和日志: %+v", status)
+	}
+	if _, err := rc.nodes[leader].Propose([]byte("z")); err != nil {
+		t.Fatalf("❌ 重启后提议失败: %v", err)
+	}
+	rc.waitForApplied(t, []string{"x", "y", "z"}, nodeIDs...)
+	t.Log("✅ 重启后从持久化日志恢复并重放")
+}
+
+// TestRaftSnapshotCompaction 测试应用的条目达到阈值后生成快照并压缩日志，
+// 落后到压缩点之前的节点通过快照追赶，重启后从快照和剩余日志恢复
+func TestRaftSnapshotCompaction(t *testing.T) {
+	nodeIDs := []string{"node1", "node2", "node3"}
+	rc := &raftTestCluster{
+		network:           distributed.NewInMemoryRaftNetwork(),
+		nodes:             make(map[string]*distributed.Raft),
+		fsms:              make(map[string]*raftLogFSM),
+		peers:             map[string]string{"node1": "node1", "node2": "node2", "node3": "node3"},
+		dir:               t.TempDir(),
+		snapshotThreshold: 4,
+	}
+	for _, nodeID := range nodeIDs {
+		rc.start(t, nodeID, rc.peers)
+	}
+	t.Cleanup(func() {
+		for _, node := range rc.nodes {
+			node.Stop()
+		}
+	})
+
+	leader := rc.waitForLeader(t, nodeIDs...)
+	var lagging string
+	var connected []string
+	for _, nodeID := range nodeIDs {
+		if nodeID != leader && lagging == "" {
+			lagging = nodeID
+		} else {
+			connected = append(connected, nodeID)
+		}
+	}
+
+	// 断开一个跟随者，其余节点应用的条目超过阈值后压缩日志
+	rc.network.Disconnect(lagging)
+	var expected []string
+	for i := 0; i < 12; i++ {
+		command := fmt.Sprintf("cmd%d", i)
+		if _, err := rc.nodes[leader].Propose([]byte(command)); err != nil {
+			t.Fatalf("❌ 提议失败: %v", err)
+		}
+		expected = append(expected, command)
+	}
+	rc.waitForApplied(t, expected, connected...)
+	if !waitForCondition(3*time.Second, func() bool { return rc.nodes[leader].Status().SnapshotIndex > 4 }) {
+		t.Fatalf("❌ 领导者应已生成快照: %+v", rc.nodes[leader].Status())
+	}
+	t.Logf("📊 领导者快照索引 %d，最后索引 %d", rc.nodes[leader].Status().SnapshotIndex, rc.nodes[leader].Status().LastIndex)
+
+	// 重新连接后，需要的条目已被压缩，领导者改为发送快照
+	rc.network.Reconnect(lagging)
+	rc.waitForApplied(t, expected, lagging)
+	if status := rc.nodes[lagging].Status(); status.SnapshotIndex == 0 {
+		t.Errorf("❌ %s 应通过快照追赶: %+v", lagging, status)
+	}
+	t.Logf("✅ %s 通过快照追赶", lagging)
+
+	// 所有节点重启，其中一个节点的日志末尾有崩溃时未写完的条目
+	for _, nodeID := range nodeIDs {
+		rc.nodes[nodeID].Stop()
+	}
+	file, err := os.OpenFile(filepath.Join(rc.dir, fmt.Sprintf("raft-%s.log", lagging)), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("❌ 打开日志文件失败: %v", err)
+	}
+	file.WriteString(`{"index":`)
+	file.Close()
+
+	for _, nodeID := range nodeIDs {
+		rc.start(t, nodeID, rc.peers)
+	}
+	rc.waitForApplied(t, expected, nodeIDs...)
+	leader = rc.waitForLeader(t, nodeIDs...)
+	if _, err := rc.nodes[leader].Propose([]byte("after")); err != nil {
+		t.Fatalf("❌ 重启后提议失败: %v", err)
+	}
+	rc.waitForApplied(t, append(expected, "after"), nodeIDs...)
+	t.Log("✅ 重启后从快照和剩余日志恢复")
+}
+
+// TestRaftClusterMetadata 测试开启Raft后并发加入的节点在所有节点上按相同顺序生效，权重和集群配置同样经日志复制
+func TestRaftClusterMetadata(t *testing.T) {
+	nodeIDs := []string{"node1", "node2", "node3", "node4", "node5"}
+	initial := map[string]bool{"node1": true, "node2": true, "node3": true}
+	var tc *testNodeCluster
+	tc = startTestNodes(t, nodeIDs, func(config *distributed.NodeConfig) {
+		// 初始集群为 node1-node3，node4 和 node5 之后以加入模式启动，只知道初始集群和自己
+		if !initial[config.NodeID] {
+			config.RaftMode = distributed.RaftModeJoin
+		}
+		clusterNodes := make(map[string]string)
+		for nodeID, address := range config.ClusterNodes {
+			if initial[nodeID] || nodeID == config.NodeID {
+				clusterNodes[nodeID] = address
+			}
+		}
+		config.ClusterNodes = clusterNodes
+		config.Raft = true
+		config.RaftElectionTimeout = 150 * time.Millisecond
+		config.RaftHeartbeatInterval = 30 * time.Millisecond
+	})
+	for _, nodeID := range []string{"node1", "node2", "node3"} {
+		tc.Server(nodeID).GetRaft().Start()
+	}
+	if !waitForCondition(3*time.Second, func() bool { return tc.Server("node1").GetRaft().Leader() != "" }) {
+		t.Fatal("❌ 初始集群未选出领导者")
+	}
+	for _, nodeID := range []string{"node4", "node5"} {
+		tc.Server(nodeID).GetRaft().Start()
+	}
+
+	// 加入模式的节点在被领导者加入前没有投票成员，不会自己选举
+	time.Sleep(500 * time.Millisecond)
+	for _, nodeID := range []string{"node4", "node5"} {
+		if status := tc.Server(nodeID).GetRaft().Status(); len(status.Peers) != 0 || status.State != distributed.RaftFollower || status.Term != 0 {
+			t.Fatalf("❌ %s 加入前不应有投票成员或发起选举: %+v", nodeID, status)
+		}
+	}
+
+	for i := 0; i < 30; i++ {
+		if err := tc.Node("node1").Set(fmt.Sprintf("raft:%d", i), fmt.Sprintf("v%d", i)); err != nil {
+			t.Fatalf("❌ 写入失败: %v", err)
+		}
+	}
+
+	// node4 和 node5 同时通过不同的节点加入
+	var wg sync.WaitGroup
+	for joiner, via := range map[string]string{"node4": "node1", "node5": "node3"} {
+		wg.Add(1)
+		go func(joiner, via string) {
+			defer wg.Done()
+			body := fmt.Sprintf(`{"node_id":%q,"address":%q}`, joiner, tc.addrs[joiner])
+			resp, err := http.Post(tc.URL(via)+"/internal/cluster/join", "application/json", strings.NewReader(body))
+			if err != nil {
+				t.Errorf("❌ %s 加入失败: %v", joiner, err)
+				return
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("❌ %s 加入返回 %d", joiner, resp.StatusCode)
+			}
+		}(joiner, via)
+	}
+	wg.Wait()
+
+	// 所有节点的哈希环、元数据和投票成员一致
+	converged := func() bool {
+		checksum := tc.Node("node1").GetRingChecksum()
+		applied := tc.Server("node1").GetClusterMetadata().AppliedIndex
+		for _, nodeID := range nodeIDs {
+			metadata := tc.Server(nodeID).GetClusterMetadata()
+			if len(tc.Node(nodeID).GetClusterNodes()) != 5 || tc.Node(nodeID).GetRingChecksum() != checksum ||
+				len(metadata.Nodes) != 5 || metadata.AppliedIndex != applied || len(tc.Server(nodeID).GetRaft().Status().Peers) != 5 {
+				return false
+			}
+		}
+		return true
+	}
+	if !waitForCondition(3*time.Second, converged) {
+		for _, nodeID := range nodeIDs {
+			t.Logf("📊 %s: 哈希环 %v，校验和 %s，Raft %+v", nodeID, tc.Node(nodeID).GetClusterNodes(),
+				tc.Node(nodeID).GetRingChecksum(), tc.Server(nodeID).GetRaft().Status())
+		}
+		t.Fatal("❌ 并发加入后各节点的拓扑不一致")
+	}
+	t.Log("✅ 并发加入的节点在所有节点上以相同顺序生效")
+
+	for i := 0; i < 30; i++ {
+		key := fmt.Sprintf("raft:%d", i)
+		if value, found, err := tc.Node("node5").Get(key); err != nil || !found || value != fmt.Sprintf("v%d", i) {
+			t.Fatalf("❌ 加入后读取 %s 失败: %q %v %v", key, value, found, err)
+		}
+	}
+	t.Log("✅ 加入过程中数据迁移到新节点")
+
+	// 权重修改经Raft日志应用到所有节点
+	req, _ := http.NewRequest(http.MethodPut, tc.URL("node4")+"/admin/nodes/node2/weight", strings.NewReader(`{"weight": 3}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("❌ 修改权重失败: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("❌ 修改权重返回 %d", resp.StatusCode)
+	}
+
+	// 集群级缓存配置
+	req, _ = http.NewRequest(http.MethodPut, tc.URL("node2")+"/admin/config?scope=cluster", strings.NewReader(`{"cache_size": 500}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("❌ 修改集群配置失败: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("❌ 修改集群配置返回 %d", resp.StatusCode)
+	}
+
+	if !waitForCondition(3*time.Second, func() bool {
+		for _, nodeID := range nodeIDs {
+			if tc.Node(nodeID).GetNodeWeights()["node2"] != 3 || tc.Node(nodeID).GetCacheConfig().CacheSize != 500 {
+				return false
+			}
+		}
+		return converged()
+	}) {
+		t.Fatal("❌ 权重和集群配置未应用到所有节点")
+	}
+	metadata := tc.Server("node3").GetClusterMetadata()
+	if metadata.Nodes["node2"].Weight != 3 || metadata.Config.CacheSize == nil || *metadata.Config.CacheSize != 500 {
+		t.Errorf("❌ 元数据应记录权重和集群配置: %+v", metadata)
+	}
+	if epoch := tc.Node("node3").GetRingEpoch(); epoch < metadata.Epoch {
+		t.Errorf("❌ 拓扑版本号 %d 不应小于元数据版本 %d", epoch, metadata.Epoch)
+	}
+	t.Logf("📊 元数据: 已应用索引 %d，拓扑版本 %d，领导者 %s", metadata.AppliedIndex, metadata.Epoch, tc.Server("node3").GetRaft().Leader())
+	t.Log("✅ 权重和集群配置经Raft复制到所有节点")
+
+	// 无效的位置、权重和配置在提议前被拒绝，不写入日志，也不改变任何节点的哈希环
+	applied := tc.Server("node1").GetClusterMetadata().AppliedIndex
+	invalid := []struct{ method, path, body string }{
+		{http.MethodPost, "/internal/cluster/join", fmt.Sprintf(`{"node_id":"node6","address":%q,"zone":"a/b"}`, tc.addrs["node1"])},
+		{http.MethodPut, "/admin/nodes/node6/weight", `{"weight": 2}`},
+		{http.MethodPut, "/admin/config?scope=cluster", `{"cache_size": 0}`},
+	}
+	for _, request := range invalid {
+		req, _ := http.NewRequest(request.method, tc.URL("node1")+request.path, strings.NewReader(request.body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			t.Errorf("❌ 无效的请求应被拒绝: %s %s", request.path, request.body)
+		}
+	}
+	time.Sleep(200 * time.Millisecond)
+	for _, nodeID := range nodeIDs {
+		if metadata := tc.Server(nodeID).GetClusterMetadata(); metadata.AppliedIndex != applied || len(metadata.Nodes) != 5 {
+			t.Errorf("❌ %s 不应应用无效的变更: 已应用索引 %d -> %d", nodeID, applied, metadata.AppliedIndex)
+		}
+		if len(tc.Node(nodeID).GetClusterNodes()) != 5 {
+			t.Errorf("❌ %s 的哈希环不应变化: %v", nodeID, tc.Node(nodeID).GetClusterNodes())
+		}
+	}
+	t.Log("✅ 无效的变更在提议前被拒绝")
+}
+
+// TestRaftClusterMetadataAddressChange 测试已在哈希环中的节点换了地址重新加入后，其他节点更新其地址
+func TestRaftClusterMetadataAddressChange(t *testing.T) {
+	nodeIDs := []string{"node1", "node2", "node3"}
+	tc := startTestNodes(t, nodeIDs, func(config *distributed.NodeConfig) {
+		config.Raft = true
+		config.RaftElectionTimeout = 150 * time.Millisecond
+		config.RaftHeartbeatInterval = 30 * time.Millisecond
+	})
+	for _, nodeID := range nodeIDs {
+		tc.Server(nodeID).GetRaft().Start()
+	}
+	if !waitForCondition(3*time.Second, func() bool { return tc.Server("node1").GetRaft().Leader() != "" }) {
+		t.Fatal("❌ 集群未选出领导者")
+	}
+
+	// 一个跟随者换到新地址上重启
+	leader := tc.Server("node1").GetRaft().Leader()
+	mover := "node1"
+	for _, nodeID := range nodeIDs {
+		if nodeID != leader {
+			mover = nodeID
+		}
+	}
+	moved := httptest.NewServer(tc.Server(mover).Handler())
+	defer moved.Close()
+	newAddress := moved.Listener.Addr().String()
+	tc.Stop(mover)
+
+	body := fmt.Sprintf(`{"node_id":%q,"address":%q}`, mover, newAddress)
+	resp, err := http.Post(tc.URL(leader)+"/internal/cluster/join", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("❌ 重新加入失败: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("❌ 重新加入返回 %d", resp.StatusCode)
+	}
+
+	if !waitForCondition(3*time.Second, func() bool {
+		for _, nodeID := range nodeIDs {
+			if tc.Server(nodeID).GetClusterMetadata().Nodes[mover].Address != newAddress {
+				return false
+			}
+			// 换地址的节点自己的地址来自启动配置
+			if nodeID != mover && tc.Node(nodeID).GetClusterNodes()[mover] != newAddress {
+				return false
+			}
+		}
+		return true
+	}) {
+		for _, nodeID := range nodeIDs {
+			t.Logf("📊 %s: %v", nodeID, tc.Node(nodeID).GetClusterNodes())
+		}
+		t.Fatalf("❌ 其他节点都应更新 %s 的地址", mover)
+	}
+	if peers := tc.Server(leader).GetRaft().Status().Peers; peers[mover] != newAddress {
+		t.Errorf("❌ Raft投票成员中的地址应更新: %v", peers)
+	}
+	t.Log("✅ 节点换地址重新加入后各节点更新了地址")
+}
+
+// TestRaftClusterMetadataSnapshot 测试元数据日志压缩后加入的节点通过快照获得哈希环、权重和集群配置
+func TestRaftClusterMetadataSnapshot(t *testing.T) {
+	nodeIDs := []string{"node1", "node2", "node3", "node4"}
+	initial := map[string]bool{"node1": true, "node2": true, "node3": true}
+	tc := startTestNodes(t, nodeIDs, func(config *distributed.NodeConfig) {
+		if !initial[config.NodeID] {
+			config.RaftMode = distributed.RaftModeJoin
+		}
+		clusterNodes := make(map[string]string)
+		for nodeID, address := range config.ClusterNodes {
+			if initial[nodeID] || nodeID == config.NodeID {
+				clusterNodes[nodeID] = address
+			}
+		}
+		config.ClusterNodes = clusterNodes
+		config.Raft = true
+		config.RaftElectionTimeout = 150 * time.Millisecond
+		config.RaftHeartbeatInterval = 30 * time.Millisecond
+		config.RaftSnapshotThreshold = 4
+	})
+	for _, nodeID := range []string{"node1", "node2", "node3"} {
+		tc.Server(nodeID).GetRaft().Start()
+	}
+	if !waitForCondition(3*time.Second, func() bool { return tc.Server("node1").GetRaft().Leader() != "" }) {
+		t.Fatal("❌ 初始集群未选出领导者")
+	}
+
+	put := func(path, body string) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPut, tc.URL("node1")+path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("❌ 请求 %s 失败: %v", path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("❌ 请求 %s 返回 %d", path, resp.StatusCode)
+		}
+	}
+	for weight := 1; weight <= 6; weight++ {
+		put("/admin/nodes/node2/weight", fmt.Sprintf(`{"weight": %d}`, weight))
+	}
+	put("/admin/config?scope=cluster", `{"cache_size": 500}`)
+	if !waitForCondition(3*time.Second, func() bool { return tc.Server("node1").GetRaft().Status().SnapshotIndex > 4 }) {
+		t.Fatalf("❌ 应已生成元数据快照: %+v", tc.Server("node1").GetRaft().Status())
+	}
+
+	// node4 加入时需要的条目已被压缩，领导者发送快照
+	tc.Server("node4").GetRaft().Start()
+	body := fmt.Sprintf(`{"node_id":"node4","address":%q}`, tc.addrs["node4"])
+	resp, err := http.Post(tc.URL("node1")+"/internal/cluster/join", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("❌ node4 加入失败: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("❌ node4 加入返回 %d", resp.StatusCode)
+	}
+
+	if !waitForCondition(3*time.Second, func() bool {
+		checksum := tc.Node("node1").GetRingChecksum()
+		for _, nodeID := range nodeIDs {
+			metadata := tc.Server(nodeID).GetClusterMetadata()
+			if len(tc.Node(nodeID).GetClusterNodes()) != 4 || tc.Node(nodeID).GetRingChecksum() != checksum || len(metadata.Nodes) != 4 {
+				return false
+			}
+		}
+		return tc.Node("node4").GetNodeWeights()["node2"] == 6 && tc.Node("node4").GetCacheConfig().CacheSize == 500
+	}) {
+		t.Fatalf("❌ node4 未从快照恢复元数据: 哈希环 %v，权重 %v，Raft %+v", tc.Node("node4").GetClusterNodes(),
+			tc.Node("node4").GetNodeWeights(), tc.Server("node4").GetRaft().Status())
+	}
+	if status := tc.Server("node4").GetRaft().Status(); status.SnapshotIndex == 0 {
+		t.Errorf("❌ node4 应通过快照追赶: %+v", status)
+	}
+	t.Log("✅ 加入的节点从快照恢复哈希环、权重和集群配置")
+}
@@ -0,0 +1,409 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"tdd-learning/core"
+	"tdd-learning/distributed"
+)
+
+// TestHashIndexRangeQuery 测试哈希索引的区间查询与逐个比较的结果一致，且随删除/淘汰同步更新
+func TestHashIndexRangeQuery(t *testing.T) {
+	hasher, _ := core.NewHasher(core.HasherXXHash)
+	cache := core.NewLRUCache(2000)
+	for i := 0; i < 500; i++ {
+		cache.Set(fmt.Sprintf("before:%d", i), "value")
+	}
+	// 启用前已存在的key也会被索引
+	cache.EnableHashIndex(hasher.Hash)
+	for i := 0; i < 1500; i++ {
+		cache.Set(fmt.Sprintf("after:%d", i), "value")
+	}
+	for i := 0; i < 100; i++ {
+		cache.Delete(fmt.Sprintf("after:%d", i))
+	}
+
+	inRange := func(h, start, end uint32) bool {
+		if start < end {
+			return h > start && h <= end
+		}
+		return h > start || h <= end
+	}
+	ranges := [][2]uint32{
+		{0, 1 << 31},
+		{0x12345678, 0x12349999}, // 同一个桶内
+		{0xF0000000, 0x10000000}, // 跨过0点
+		{0x80000005, 0x80000001}, // 跨过0点且首尾在同一个桶，几乎整个环
+		{0x40000000, 0x40000000}, // 整个环
+		{0xFFFFFFFF, 0x0000FFFF}, // 从最大值开始
+	}
+	for _, r := range ranges {
+		expected := 0
+		for key := range cache.GetAllData() {
+			if inRange(hasher.Hash(key), r[0], r[1]) {
+				expected++
+			}
+		}
+		data := cache.GetDataInHashRange(r[0], r[1])
+		for key := range data {
+			if !inRange(hasher.Hash(key), r[0], r[1]) {
+				t.Errorf("❌ key %s 不在区间 (%08x, %08x] 内", key, r[0], r[1])
+			}
+		}
+		if len(data) != expected {
+			t.Errorf("❌ 区间 (%08x, %08x] 数量错误: %d, 期望 %d", r[0], r[1], len(data), expected)
+		}
+	}
+
+	// 容量淘汰后被淘汰的key不再出现在索引中
+	if _, err := cache.Resize(300); err != nil {
+		t.Fatal(err)
+	}
+	if all := cache.GetDataInHashRange(0, 0); len(all) != 300 {
+		t.Errorf("❌ 淘汰后索引未同步: %d", len(all))
+	}
+	t.Log("✅ 哈希索引区间查询测试通过")
+}
+
+// TestHashIndexHashesOncePerInsert 测试每个key只在写入时计算一次环哈希，覆盖写、删除和淘汰不再计算
+func TestHashIndexHashesOncePerInsert(t *testing.T) {
+	hasher, _ := core.NewHasher(core.HasherXXHash)
+	calls := 0
+	cache := core.NewLRUCache(100)
+	cache.EnableHashIndex(func(key string) uint32 {
+		calls++
+		return hasher.Hash(key)
+	})
+
+	for i := 0; i < 150; i++ {
+		cache.Set(fmt.Sprintf("key:%d", i), "v1") // 后50个写入淘汰最早的50个
+	}
+	for i := 50; i < 150; i++ {
+		cache.Set(fmt.Sprintf("key:%d", i), "v2")
+	}
+	for i := 50; i < 100; i++ {
+		cache.Delete(fmt.Sprintf("key:%d", i))
+	}
+	if calls != 150 {
+		t.Errorf("❌ 环哈希计算次数 %d，期望每个新key一次共 150 次", calls)
+	}
+	if data := cache.GetDataInHashRange(0, 0); len(data) != 50 {
+		t.Errorf("❌ 删除和淘汰后索引应剩 50 个key: %d", len(data))
+	}
+	t.Logf("✅ 环哈希计算 %d 次", calls)
+}
+
+// TestRangeMigrationMovesOnlyChangedKeys 测试增删节点和修改权重时只迁移归属变化的区间
+func TestRangeMigrationMovesOnlyChangedKeys(t *testing.T) {
+	dc := core.NewDistributedCacheWithVirtualNodes([]string{"node1", "node2", "node3", "node4"}, 150)
+	// 每个节点的本地缓存容量为1000，数据量需保证迁移后不触发淘汰
+	const total = 2500
+	owners := make(map[string]string, total)
+	for i := 0; i < total; i++ {
+		key := fmt.Sprintf("key:%d", i)
+		dc.Set(key, "value")
+		owners[key] = dc.GetNodeForKey(key)
+	}
+
+	check := func(step string, change func()) {
+		before, ok := dc.SnapshotRing()
+		if !ok {
+			t.Fatal("❌ 哈希环应支持按区间迁移")
+		}
+		migratedBefore := dc.GetMigrationStats().MigratedKeys
+		change()
+		after, _ := dc.SnapshotRing()
+		ranges := core.ChangedRanges(before, after)
+
+		changed := 0
+		for key, oldOwner := range owners {
+			newOwner := dc.GetNodeForKey(key)
+			if newOwner != oldOwner {
+				changed++
+				// 归属变化的key一定落在某个变化区间内，且迁移方向一致
+				covered := false
+				h := dc.HashKey(key)
+				for _, r := range ranges {
+					inRange := (r.Start < r.End && h > r.Start && h <= r.End) || (r.Start >= r.End && (h > r.Start || h <= r.End))
+					if inRange {
+						covered = r.From == oldOwner && r.To == newOwner
+						break
+					}
+				}
+				if !covered {
+					t.Fatalf("❌ %s: key %s 的归属变化未被区间覆盖", step, key)
+				}
+			}
+			if value, found, _ := dc.Get(key); !found || value != "value" {
+				t.Fatalf("❌ %s: 迁移后数据丢失: %s", step, key)
+			}
+			owners[key] = newOwner
+		}
+
+		migrated := dc.GetMigrationStats().MigratedKeys - migratedBefore
+		t.Logf("📊 %s: %d 个变化区间，迁移 %d 个key", step, len(ranges), migrated)
+		if migrated != changed {
+			t.Errorf("❌ %s: 迁移数量 %d 应等于归属变化的key数量 %d", step, migrated, changed)
+		}
+	}
+
+	check("添加节点", func() { dc.AddNode("node5") })
+	check("修改权重", func() { dc.SetNodeWeight("node2", 1.5) })
+	check("移除节点", func() { dc.RemoveNode("node3") })
+
+	// 有界负载下归属不只由哈希区间决定，不能按区间迁移
+	dc.EnableBoundedLoad(0.25)
+	if _, ok := dc.SnapshotRing(); ok {
+		t.Error("❌ 开启有界负载后不应按区间迁移")
+	}
+	t.Log("✅ 按区间迁移测试通过")
+}
+
+// TestNodeJoinRangeMigration 测试节点加入时各节点只把新节点接管的区间迁移过去（两种存储引擎）
+func TestNodeJoinRangeMigration(t *testing.T) {
+	for _, engine := range core.StorageEngineNames {
+		t.Run(engine, func(t *testing.T) {
+			testNodeJoinRangeMigration(t, engine)
+		})
+	}
+}
+
+func testNodeJoinRangeMigration(t *testing.T, engine string) {
+	// node1/node2 初始只知道彼此，node3 稍后加入
+	cluster := startTestNodes(t, []string{"node1", "node2", "node3"}, func(config *distributed.NodeConfig) {
+		config.StorageEngine = engine
+		config.AdmissionPolicy = distributed.AdmissionPolicyTinyLFU
+		if config.NodeID != "node3" {
+			nodes := make(map[string]string)
+			for nodeID, address := range config.ClusterNodes {
+				if nodeID != "node3" {
+					nodes[nodeID] = address
+				}
+			}
+			config.ClusterNodes = nodes
+		}
+	})
+
+	keys := make([]string, 0, 600)
+	for i := 0; i < 600; i++ {
+		key := fmt.Sprintf("join:%d", i)
+		keys = append(keys, key)
+		if err := cluster.Node("node1").Set(key, "v-"+key); err != nil {
+			t.Fatalf("❌ 写入失败: %v", err)
+		}
+	}
+
+	// 测试集群未启动健康检查，广播会跳过状态未知的节点，因此分别通知node1和node2
+	join := fmt.Sprintf(`{"node_id":"node3","address":%q}`, cluster.addrs["node3"])
+	resp, err := http.Post(cluster.URL("node1")+"/internal/cluster/join", "application/json", strings.NewReader(join))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	sync := fmt.Sprintf(`{"node_id":"node3","address":%q,"operation":"add"}`, cluster.addrs["node3"])
+	resp, err = http.Post(cluster.URL("node2")+"/internal/cluster/sync-add", "application/json", strings.NewReader(sync))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	moved := 0
+	for _, key := range keys {
+		owner := cluster.Node("node3").GetNodeForKey(key)
+		if cluster.Node("node1").GetNodeForKey(key) != owner || cluster.Node("node2").GetNodeForKey(key) != owner {
+			t.Fatalf("❌ 节点间哈希环不一致: %s", key)
+		}
+		if value, found := cluster.Node(owner).GetLocal(key); !found || value != "v-"+key {
+			t.Errorf("❌ key %s 不在负责节点 %s 上", key, owner)
+		}
+		if owner == "node3" {
+			moved++
+		}
+	}
+	t.Logf("📊 node3 接管 %d/%d 个key", moved, len(keys))
+	if moved == 0 {
+		t.Error("❌ 新节点应接管部分数据")
+	}
+	t.Log("✅ 节点加入按区间迁移测试通过")
+}
+
+// TestNodeWeightRangeMigration 测试修改权重时各节点只迁移归属变化的区间，不扫描全部本地数据
+func TestNodeWeightRangeMigration(t *testing.T) {
+	nodeIDs := []string{"node1", "node2", "node3"}
+	cluster := startTestNodes(t, nodeIDs, nil)
+
+	keys := make([]string, 0, 600)
+	before := make(map[string]string)
+	for i := 0; i < 600; i++ {
+		key := fmt.Sprintf("weight:%d", i)
+		keys = append(keys, key)
+		before[key] = cluster.Node("node1").GetNodeForKey(key)
+		if err := cluster.Node("node1").Set(key, "v-"+key); err != nil {
+			t.Fatalf("❌ 写入失败: %v", err)
+		}
+	}
+
+	// 在node1上放一些归node3负责的key：归属不变的区间按区间迁移不会碰它们，全量扫描会把它们迁走
+	var orphans []string
+	for i := 0; len(orphans) < 20; i++ {
+		key := fmt.Sprintf("orphan:%d", i)
+		if cluster.Node("node1").GetNodeForKey(key) == "node3" {
+			orphans = append(orphans, key)
+			if err := cluster.Node("node1").SetLocal(key, "stray"); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	sync := `{"node_id":"node2","weight":3,"operation":"weight"}`
+	for _, nodeID := range nodeIDs {
+		resp, err := http.Post(cluster.URL(nodeID)+"/internal/cluster/sync-weight", "application/json", strings.NewReader(sync))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("❌ %s 修改权重返回 %d", nodeID, resp.StatusCode)
+		}
+	}
+
+	changed := 0
+	for _, key := range keys {
+		owner := cluster.Node("node1").GetNodeForKey(key)
+		if value, found := cluster.Node(owner).GetLocal(key); !found || value != "v-"+key {
+			t.Errorf("❌ key %s 不在负责节点 %s 上", key, owner)
+		}
+		if owner != before[key] {
+			changed++
+		}
+	}
+	unchanged := 0
+	for _, key := range orphans {
+		if cluster.Node("node1").GetNodeForKey(key) != "node3" {
+			continue
+		}
+		unchanged++
+		if _, found := cluster.Node("node1").GetLocal(key); !found {
+			t.Errorf("❌ 归属未变化的区间中的 %s 不应被迁移", key)
+		}
+	}
+	if unchanged == 0 {
+		t.Error("❌ 应有归属未变化的key用于检查")
+	}
+	t.Logf("📊 修改权重后 %d/%d 个key改变归属", changed, len(keys))
+	if changed == 0 {
+		t.Error("❌ 提高权重后应有key改变归属")
+	}
+	t.Log("✅ 修改权重按区间迁移测试通过")
+}
+
+// TestReplicatedWeightMigration 测试多副本时修改权重只迁移副本集合变化的key：
+// 仍是副本的节点保留数据，迁移保留版本号，旧副本上的数据不会覆盖更新的写入
+func TestReplicatedWeightMigration(t *testing.T) {
+	nodeIDs := []string{"node1", "node2", "node3", "node4"}
+	cluster := startTestNodes(t, nodeIDs, func(config *distributed.NodeConfig) {
+		config.ReplicationFactor = 3
+	})
+	node1 := cluster.Node("node1")
+
+	keys := make([]string, 0, 200)
+	for i := 0; i < 200; i++ {
+		key := fmt.Sprintf("replica:%d", i)
+		keys = append(keys, key)
+		if err := node1.Set(key, "old-"+key); err != nil {
+			t.Fatalf("❌ 写入失败: %v", err)
+		}
+	}
+	// W=2 时第三个副本可能在返回后才写入
+	time.Sleep(100 * time.Millisecond)
+
+	// 前一半key只在前两个副本上写入新版本，第三个副本保留旧值
+	newest := make(map[string]string)
+	for i, key := range keys {
+		newest[key] = "old-" + key
+		if i%2 != 0 {
+			continue
+		}
+		replicas := node1.GetNodesForKey(key, 3)
+		entry, _ := cluster.Node(replicas[0]).GetLocalVersioned(key)
+		entry = core.VersionedEntry{Value: "new-" + key, Version: entry.Version + 1}
+		for _, nodeID := range replicas[:2] {
+			cluster.Node(nodeID).SetLocalVersioned(key, entry)
+		}
+		newest[key] = entry.Value
+	}
+	before := make(map[string]int)
+	for _, nodeID := range nodeIDs {
+		before[nodeID] = cluster.Node(nodeID).GetCacheConfig().Size
+	}
+
+	sync := `{"node_id":"node2","weight":3,"operation":"weight"}`
+	for _, nodeID := range nodeIDs {
+		resp, err := http.Post(cluster.URL(nodeID)+"/internal/cluster/sync-weight", "application/json", strings.NewReader(sync))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("❌ %s 修改权重返回 %d", nodeID, resp.StatusCode)
+		}
+	}
+
+	// 每个key恰好在新的三个副本上，至少两个副本持有最新值，按R=3读取得到最新值
+	for _, key := range keys {
+		replicas := node1.GetNodesForKey(key, 3)
+		holders, fresh := 0, 0
+		for _, nodeID := range nodeIDs {
+			value, found := cluster.Node(nodeID).GetLocal(key)
+			if !found {
+				continue
+			}
+			holders++
+			if !containsString(replicas, nodeID) {
+				t.Errorf("❌ %s 已不是 %s 的副本，迁移后应删除", nodeID, key)
+			}
+			if value == newest[key] {
+				fresh++
+			}
+		}
+		if holders != 3 {
+			t.Errorf("❌ %s 应有3个副本，实际 %d 个", key, holders)
+		}
+		if fresh < 2 {
+			t.Errorf("❌ %s 的最新值只在 %d 个副本上，旧副本覆盖了新写入", key, fresh)
+		}
+		if value, found, err := node1.GetWithQuorum(key, distributed.Quorum{R: 3}); err != nil || !found || value != newest[key] {
+			t.Errorf("❌ %s 读取应返回最新值 %q: %q %v %v", key, newest[key], value, found, err)
+		}
+	}
+
+	// 每个节点的key数量等于它作为副本负责的key数量
+	for _, nodeID := range nodeIDs {
+		expected := 0
+		for _, key := range keys {
+			if containsString(node1.GetNodesForKey(key, 3), nodeID) {
+				expected++
+			}
+		}
+		after := cluster.Node(nodeID).GetCacheConfig().Size
+		t.Logf("📊 %s: %d -> %d 个key", nodeID, before[nodeID], after)
+		if after != expected {
+			t.Errorf("❌ %s 应持有 %d 个key，实际 %d 个", nodeID, expected, after)
+		}
+	}
+	t.Log("✅ 多副本修改权重迁移测试通过")
+}
+
+// containsString 列表中是否包含指定字符串
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
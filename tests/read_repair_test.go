@@ -0,0 +1,68 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"tdd-learning/core"
+	"tdd-learning/distributed"
+)
+
+// TestReadRepair 测试仲裁读取返回最新版本，并在后台修复缺失和落后的副本
+func TestReadRepair(t *testing.T) {
+	for _, chance := range []float64{1, 0} {
+		chance := chance
+		cluster := startTestNodes(t, []string{"node1", "node2", "node3"}, func(config *distributed.NodeConfig) {
+			config.ReplicationFactor = 3
+			config.ReadQuorum = 3
+			config.ReadRepairChance = &chance
+		})
+		node1, node2, node3 := cluster.Node("node1"), cluster.Node("node2"), cluster.Node("node3")
+
+		base := uint64(time.Now().UnixNano())
+		node1.SetLocalVersioned("repair:key", core.VersionedEntry{Value: "new", Version: base + 2})
+		node2.SetLocalVersioned("repair:key", core.VersionedEntry{Value: "old", Version: base + 1})
+
+		value, found, err := node1.Get("repair:key")
+		if err != nil || !found || value != "new" {
+			t.Fatalf("❌ 概率 %v: 应读到最新版本: %q %v %v", chance, value, found, err)
+		}
+
+		repaired := func() bool {
+			for _, node := range []*distributed.DistributedNode{node2, node3} {
+				if entry, ok := node.GetLocalVersioned("repair:key"); !ok || entry.Version != base+2 {
+					return false
+				}
+			}
+			return true
+		}
+		deadline := time.Now().Add(2 * time.Second)
+		for chance > 0 && !repaired() && time.Now().Before(deadline) {
+			time.Sleep(20 * time.Millisecond)
+		}
+
+		stats := node1.GetReadRepairStats()
+		t.Logf("📊 概率 %v 的读修复统计: %+v", chance, stats)
+		if stats.Mismatches != 1 {
+			t.Errorf("❌ 概率 %v: 应记录1次版本不一致: %+v", chance, stats)
+		}
+		if chance > 0 {
+			if !repaired() || stats.Triggered != 1 || stats.Repaired != 2 {
+				t.Errorf("❌ 落后的副本应被修复: %+v", stats)
+			}
+		} else {
+			if entry, _ := node2.GetLocalVersioned("repair:key"); entry.Value != "old" || stats.Triggered != 0 {
+				t.Errorf("❌ 关闭读修复时不应写回副本: %+v %+v", entry, stats)
+			}
+		}
+
+		// 副本一致后不再记录不一致
+		if chance > 0 {
+			node1.Get("repair:key")
+			if stats := node1.GetReadRepairStats(); stats.Mismatches != 1 {
+				t.Errorf("❌ 副本一致时不应记录不一致: %+v", stats)
+			}
+		}
+	}
+	t.Log("✅ 读修复测试通过")
+}
@@ -0,0 +1,132 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+
+	"tdd-learning/core"
+)
+
+// TestGetNodesForKey 测试副本节点互不相同、主节点在首位，且主节点下线后由第二个副本接管
+func TestGetNodesForKey(t *testing.T) {
+	nodes := []string{"node1", "node2", "node3", "node4", "node5"}
+
+	for _, placement := range core.PlacementNames {
+		dc := newPlacementCache(t, nodes, placement)
+		for i := 0; i < 500; i++ {
+			key := fmt.Sprintf("key:%d", i)
+			replicas := dc.GetNodesForKey(key, 3)
+			if len(replicas) != 3 || replicas[0] != dc.GetNodeForKey(key) {
+				t.Fatalf("❌ %s 副本列表错误: %v", placement, replicas)
+			}
+			seen := make(map[string]bool)
+			for _, node := range replicas {
+				if seen[node] {
+					t.Fatalf("❌ %s 副本重复: %v", placement, replicas)
+				}
+				seen[node] = true
+			}
+			// 前缀一致：取更多副本时前面的结果不变
+			if more := dc.GetNodesForKey(key, 4); fmt.Sprint(more[:3]) != fmt.Sprint(replicas) {
+				t.Fatalf("❌ %s 副本顺序不稳定: %v / %v", placement, replicas, more)
+			}
+		}
+
+		if all := dc.GetNodesForKey("key", 10); len(all) != len(nodes) {
+			t.Errorf("❌ %s 节点不足时应返回全部节点: %v", placement, all)
+		}
+		if none := dc.GetNodesForKey("key", 0); len(none) != 0 {
+			t.Errorf("❌ n为0时应返回空: %v", none)
+		}
+	}
+
+	// 哈希环和Rendezvous的副本顺序即故障接管顺序
+	for _, placement := range []string{core.PlacementRing, core.PlacementRendezvous} {
+		dc := newPlacementCache(t, nodes, placement)
+		replicas := make(map[string][]string)
+		for i := 0; i < 500; i++ {
+			key := fmt.Sprintf("key:%d", i)
+			replicas[key] = dc.GetNodesForKey(key, 2)
+		}
+		dc.RemoveNode("node3")
+		for key, nodes := range replicas {
+			if nodes[0] == "node3" && dc.GetNodeForKey(key) != nodes[1] {
+				t.Fatalf("❌ %s 主节点下线后应由第二个副本接管: %s", placement, key)
+			}
+		}
+	}
+	t.Log("✅ 多副本放置测试通过")
+}
+
+// TestReplicaZoneConstraint 测试副本优先分布在不同可用区和机架
+func TestReplicaZoneConstraint(t *testing.T) {
+	locations := map[string]core.NodeLocation{
+		"a1": {Zone: "zone-a", Rack: "r1"},
+		"a2": {Zone: "zone-a", Rack: "r2"},
+		"b1": {Zone: "zone-b", Rack: "r1"},
+		"b2": {Zone: "zone-b", Rack: "r1"},
+		"c1": {Zone: "zone-c", Rack: "r1"},
+		"c2": {Zone: "zone-c", Rack: "r2"},
+	}
+	nodes := make([]string, 0, len(locations))
+	for node := range locations {
+		nodes = append(nodes, node)
+	}
+
+	dc := core.NewDistributedCacheWithVirtualNodes(nodes, 150)
+	for node, location := range locations {
+		dc.SetNodeLocation(node, location)
+	}
+	if err := dc.SetReplicaConstraint(core.ReplicaConstraintZone); err != nil {
+		t.Fatal(err)
+	}
+
+	sameZone := 0
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key:%d", i)
+		replicas := dc.GetNodesForKey(key, 3)
+		zones := make(map[string]bool)
+		for _, node := range replicas {
+			zones[locations[node].Zone] = true
+		}
+		if len(zones) != 3 || replicas[0] != dc.GetNodeForKey(key) {
+			t.Fatalf("❌ 3个副本应分布在3个可用区: %v", replicas)
+		}
+
+		// 可用区不足时放宽约束，第4个副本优先选择不同机架：zone-b 的两个节点在同一机架
+		replicas = dc.GetNodesForKey(key, 4)
+		if len(replicas) != 4 {
+			t.Fatalf("❌ 副本数量错误: %v", replicas)
+		}
+		if locations[replicas[3]].Zone == "zone-b" {
+			sameZone++
+		}
+	}
+	// zone-a/zone-c 还有其他机架可选，第4个副本不应落在 zone-b 已使用的机架上
+	if sameZone != 0 {
+		t.Errorf("❌ 第4个副本应优先选择不同机架: %d 次选择了同机架节点", sameZone)
+	}
+
+	// 无位置约束时与哈希环顺序一致，可能落在同一可用区
+	dc.SetReplicaConstraint(core.ReplicaConstraintNone)
+	collocated := 0
+	for i := 0; i < 1000; i++ {
+		replicas := dc.GetNodesForKey(fmt.Sprintf("key:%d", i), 3)
+		zones := make(map[string]bool)
+		for _, node := range replicas {
+			zones[locations[node].Zone] = true
+		}
+		if len(zones) < 3 {
+			collocated++
+		}
+	}
+	t.Logf("📊 无约束时 %d/1000 个key的副本有同区节点", collocated)
+	if collocated == 0 {
+		t.Error("❌ 无约束时应存在同区副本，测试数据无效")
+	}
+
+	if err := dc.SetReplicaConstraint("region"); err == nil {
+		t.Error("❌ 未知约束应返回错误")
+	}
+	t.Log("✅ 副本位置约束测试通过")
+}
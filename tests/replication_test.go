@@ -0,0 +1,173 @@
+package tests
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"tdd-learning/core"
+	"tdd-learning/distributed"
+)
+
+// TestVersionedEntryLastWriteWins 测试副本只接受更新的版本，删除标记阻止旧数据复活
+func TestVersionedEntryLastWriteWins(t *testing.T) {
+	cache := core.NewLRUCache(10)
+	v1, v2, v3 := cache.NewVersion(), cache.NewVersion(), cache.NewVersion()
+
+	cache.SetVersioned("k", core.VersionedEntry{Value: "new", Version: v2})
+	cache.SetVersioned("k", core.VersionedEntry{Value: "old", Version: v1})
+	if entry, found := cache.GetVersioned("k"); !found || entry.Value != "new" || entry.Version != v2 {
+		t.Fatalf("❌ 旧版本不应覆盖新版本: %+v %v", entry, found)
+	}
+
+	// 同版本时删除优先，之后旧版本的写入被忽略
+	cache.SetVersioned("k", core.VersionedEntry{Version: v2, Tombstone: true})
+	if _, found := cache.Get("k"); found {
+		t.Fatal("❌ 删除标记应移除数据")
+	}
+	cache.SetVersioned("k", core.VersionedEntry{Value: "stale", Version: v1})
+	if entry, found := cache.GetVersioned("k"); !found || !entry.Tombstone {
+		t.Fatalf("❌ 旧数据不应在删除后复活: %+v %v", entry, found)
+	}
+
+	// 比删除标记新的写入生效，普通写入分配的版本号更大
+	cache.SetVersioned("k", core.VersionedEntry{Value: "again", Version: v3})
+	if value, found := cache.Get("k"); !found || value != "again" {
+		t.Fatalf("❌ 更新的版本应生效: %q %v", value, found)
+	}
+	cache.Set("k", "plain")
+	if entry, _ := cache.GetVersioned("k"); entry.Version <= v3 {
+		t.Errorf("❌ 普通写入的版本号应递增: %d <= %d", entry.Version, v3)
+	}
+	t.Log("✅ 版本合并测试通过")
+}
+
+// replicatedCluster 启动三副本集群
+func replicatedCluster(t *testing.T) *testNodeCluster {
+	return startTestNodes(t, []string{"node1", "node2", "node3"}, func(config *distributed.NodeConfig) {
+		config.ReplicationFactor = 3
+	})
+}
+
+// TestQuorumReplication 测试写入所有副本，单个副本宕机时默认仲裁仍可读写
+func TestQuorumReplication(t *testing.T) {
+	cluster := replicatedCluster(t)
+	node1 := cluster.Node("node1")
+	if q := node1.GetQuorum(); q != (distributed.Quorum{N: 3, R: 2, W: 2}) {
+		t.Fatalf("❌ 默认仲裁参数错误: %+v", q)
+	}
+
+	if err := node1.Set("user:1", "alice"); err != nil {
+		t.Fatalf("❌ 写入失败: %v", err)
+	}
+	// W=2 时第三个副本可能在返回后才写入
+	time.Sleep(100 * time.Millisecond)
+	for _, nodeID := range []string{"node1", "node2", "node3"} {
+		if value, found := cluster.Node(nodeID).GetLocal("user:1"); !found || value != "alice" {
+			t.Errorf("❌ %s 缺少副本: %q %v", nodeID, value, found)
+		}
+	}
+
+	// 一个副本上是旧版本时读取返回最新版本
+	cluster.Node("node2").SetLocalVersioned("user:1", core.VersionedEntry{Value: "stale", Version: 1})
+	if value, found, err := cluster.Node("node2").GetWithQuorum("user:1", distributed.Quorum{R: 3}); err != nil || !found || value != "alice" {
+		t.Errorf("❌ 读取应返回最新版本: %q %v %v", value, found, err)
+	}
+
+	if err := node1.Delete("user:1"); err != nil {
+		t.Fatalf("❌ 删除失败: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, found, _ := cluster.Node("node3").Get("user:1"); found {
+		t.Error("❌ 删除后不应读到数据")
+	}
+	if entry, found := cluster.Node("node2").GetLocalVersioned("user:1"); !found || !entry.Tombstone {
+		t.Errorf("❌ 副本应保留删除标记: %+v %v", entry, found)
+	}
+
+	// node3 宕机：R=W=2 仍可读写，W=3 失败
+	cluster.Stop("node3")
+	if err := node1.Set("user:2", "bob"); err != nil {
+		t.Fatalf("❌ 单副本宕机时写入应成功: %v", err)
+	}
+	if value, found, err := node1.Get("user:2"); err != nil || !found || value != "bob" {
+		t.Fatalf("❌ 单副本宕机时读取应成功: %q %v %v", value, found, err)
+	}
+	err := node1.SetWithQuorum("user:2", "carol", distributed.Quorum{W: 3})
+	if _, ok := err.(*distributed.QuorumError); !ok {
+		t.Errorf("❌ W=3 应返回仲裁错误: %v", err)
+	}
+
+	stats := node1.GetReplicationStats()
+	t.Logf("📊 多副本统计: %+v", stats)
+	if stats["write_failures"].(uint64) != 1 {
+		t.Errorf("❌ 写仲裁失败次数错误: %v", stats["write_failures"])
+	}
+	t.Log("✅ 多副本仲裁读写测试通过")
+}
+
+// TestQuorumOverrides 测试通过请求头和客户端选项按请求覆盖仲裁参数
+func TestQuorumOverrides(t *testing.T) {
+	cluster := replicatedCluster(t)
+	startRPCServer(t, cluster.Node("node2"))
+	defer cluster.Node("node1").CloseRPC()
+	cluster.Stop("node3")
+
+	put := func(headers map[string]string) int {
+		req, _ := http.NewRequest("PUT", cluster.URL("node1")+"/api/v1/cache/order:1", strings.NewReader(`{"value":"paid"}`))
+		req.Header.Set("Content-Type", "application/json")
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	cases := []struct {
+		headers map[string]string
+		status  int
+	}{
+		{nil, http.StatusOK},
+		{map[string]string{distributed.QuorumHeaderW: "3"}, http.StatusServiceUnavailable},
+		{map[string]string{distributed.QuorumHeaderN: "2", distributed.QuorumHeaderW: "3"}, http.StatusBadRequest},
+		{map[string]string{distributed.QuorumHeaderR: "abc"}, http.StatusBadRequest},
+		{map[string]string{distributed.QuorumHeaderW: "1"}, http.StatusOK},
+	}
+	for _, tc := range cases {
+		if status := put(tc.headers); status != tc.status {
+			t.Errorf("❌ 请求头 %v 的状态码错误: %d, 期望 %d", tc.headers, status, tc.status)
+		}
+	}
+	if rpcStats := cluster.Node("node1").GetRPCStats(); rpcStats.Calls == 0 {
+		t.Error("❌ 副本读写应优先通过RPC")
+	}
+
+	// 客户端默认仲裁参数和按请求覆盖
+	client := distributed.NewDistributedClient(distributed.ClientConfig{
+		Nodes:      []string{cluster.addrs["node1"]},
+		Timeout:    5 * time.Second,
+		RetryCount: 1,
+		Quorum:     distributed.Quorum{R: 2, W: 2},
+	})
+	defer client.Close()
+
+	if err := client.Set("order:2", "shipped"); err != nil {
+		t.Fatalf("❌ 客户端写入失败: %v", err)
+	}
+	if value, found, err := client.Get("order:2"); err != nil || !found || value != "shipped" {
+		t.Errorf("❌ 客户端读取错误: %q %v %v", value, found, err)
+	}
+	if err := client.DeleteWithQuorum("order:2", distributed.Quorum{W: 3}); err == nil {
+		t.Error("❌ 两个副本存活时 W=3 的删除应失败")
+	}
+	// 未达到仲裁数的写入不回滚，存活的副本已写入删除标记
+	if _, found, err := client.GetWithQuorum("order:2", distributed.Quorum{R: 2}); err != nil || found {
+		t.Errorf("❌ 存活副本上的删除应生效: %v %v", found, err)
+	}
+	t.Log("✅ 仲裁参数覆盖测试通过")
+}
@@ -0,0 +1,115 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"tdd-learning/core"
+	"tdd-learning/distributed"
+)
+
+// TestLRUCacheResize 测试运行时调整容量
+func TestLRUCacheResize(t *testing.T) {
+	cache := core.NewLRUCache(1000)
+	for i := 0; i < 1000; i++ {
+		cache.Set(fmt.Sprintf("key:%d", i), "v")
+	}
+
+	// 缩容：淘汰最久未使用的条目
+	evicted, err := cache.Resize(100)
+	if err != nil {
+		t.Fatalf("❌ 缩容失败: %v", err)
+	}
+	if evicted != 900 || cache.Size() != 100 {
+		t.Errorf("❌ 缩容结果错误: 淘汰=%d, 大小=%d", evicted, cache.Size())
+	}
+	if _, found := cache.Get("key:999"); !found {
+		t.Error("❌ 最近写入的key不应被淘汰")
+	}
+	if _, found := cache.Get("key:0"); found {
+		t.Error("❌ 最早写入的key应被淘汰")
+	}
+
+	// 扩容：不淘汰，新容量立即生效
+	if _, err := cache.Resize(200); err != nil {
+		t.Fatalf("❌ 扩容失败: %v", err)
+	}
+	for i := 0; i < 200; i++ {
+		cache.Set(fmt.Sprintf("new:%d", i), "v")
+	}
+	if cache.Size() != 200 || cache.Capacity() != 200 {
+		t.Errorf("❌ 扩容后大小错误: %d", cache.Size())
+	}
+
+	if _, err := cache.Resize(0); err == nil {
+		t.Error("❌ 容量为0应返回错误")
+	}
+}
+
+// TestLRUCacheSetMemoryLimit 测试运行时调整内存限制
+func TestLRUCacheSetMemoryLimit(t *testing.T) {
+	cache := core.NewLRUCache(100)
+	for i := 0; i < 10; i++ {
+		cache.Set(fmt.Sprintf("k%d", i), "0123456789") // 每条 2+10+64 = 76 字节
+	}
+
+	evicted, err := cache.SetMemoryLimit(76 * 4)
+	if err != nil {
+		t.Fatalf("❌ 设置内存限制失败: %v", err)
+	}
+	if evicted != 6 || cache.GetMemoryUsage() > 76*4 {
+		t.Errorf("❌ 内存缩减错误: 淘汰=%d, 使用=%d", evicted, cache.GetMemoryUsage())
+	}
+
+	// 取消限制后可以继续写入
+	cache.SetMemoryLimit(0)
+	for i := 10; i < 20; i++ {
+		cache.Set(fmt.Sprintf("k%d", i), "0123456789")
+	}
+	if cache.Size() != 14 {
+		t.Errorf("❌ 取消限制后大小错误: %d", cache.Size())
+	}
+}
+
+// TestAdminConfigEndpoint 测试 /admin/config 在线调整节点缓存
+func TestAdminConfigEndpoint(t *testing.T) {
+	server := distributed.NewNodeServer(distributed.NodeConfig{
+		NodeID:       "node1",
+		ClusterNodes: map[string]string{"node1": "localhost:9201"},
+		CacheSize:    100,
+	})
+	node := server.GetNode()
+	for i := 0; i < 100; i++ {
+		node.Set(fmt.Sprintf("key:%d", i), "v")
+	}
+
+	body, _ := json.Marshal(map[string]int{"cache_size": 10})
+	req := httptest.NewRequest(http.MethodPut, "/admin/config", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	server.Handler().ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("❌ 更新配置失败: %d %s", recorder.Code, recorder.Body.String())
+	}
+	var response struct {
+		Config      distributed.CacheConfig `json:"config"`
+		EvictedKeys int                     `json:"evicted_keys"`
+	}
+	json.Unmarshal(recorder.Body.Bytes(), &response)
+	if response.Config.CacheSize != 10 || response.Config.Size != 10 || response.EvictedKeys != 90 {
+		t.Errorf("❌ 响应错误: %+v", response)
+	}
+
+	// 非法配置
+	req = httptest.NewRequest(http.MethodPut, "/admin/config", bytes.NewReader([]byte(`{"cache_size":-1}`)))
+	recorder = httptest.NewRecorder()
+	server.Handler().ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("❌ 非法配置应返回400, 实际=%d", recorder.Code)
+	}
+}
@@ -0,0 +1,278 @@
+package tests
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"tdd-learning/distributed"
+)
+
+// respClient 简单的RESP测试客户端，回复统一转换为字符串便于断言
+type respClient struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func dialRESP(t *testing.T, address string) *respClient {
+	t.Helper()
+	conn, err := net.DialTimeout("tcp", address, 2*time.Second)
+	if err != nil {
+		t.Fatalf("❌ 连接RESP端口失败: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	return &respClient{t: t, conn: conn, reader: bufio.NewReader(conn)}
+}
+
+// do 以RESP数组格式发送命令并读取回复
+func (rc *respClient) do(args ...string) string {
+	rc.t.Helper()
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&builder, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := rc.conn.Write([]byte(builder.String())); err != nil {
+		rc.t.Fatalf("❌ 发送命令失败: %v", err)
+	}
+	return rc.read()
+}
+
+// read 读取一个回复：简单字符串/错误/整数原样返回（带类型前缀），bulk返回内容，null返回"(nil)"，聚合类型返回[a b ...]
+func (rc *respClient) read() string {
+	rc.t.Helper()
+	line, err := rc.reader.ReadString('\n')
+	if err != nil {
+		rc.t.Fatalf("❌ 读取回复失败: %v", err)
+	}
+	line = strings.TrimRight(line, "\r\n")
+
+	switch line[0] {
+	case '+', '-', ':':
+		return line
+	case '_':
+		return "(nil)"
+	case '$':
+		size, _ := strconv.Atoi(line[1:])
+		if size < 0 {
+			return "(nil)"
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(rc.reader, data); err != nil {
+			rc.t.Fatalf("❌ 读取bulk失败: %v", err)
+		}
+		return string(data[:size])
+	case '*', '%':
+		count, _ := strconv.Atoi(line[1:])
+		if line[0] == '%' {
+			count *= 2
+		}
+		items := make([]string, count)
+		for i := range items {
+			items[i] = rc.read()
+		}
+		return "[" + strings.Join(items, " ") + "]"
+	}
+	rc.t.Fatalf("❌ 未知的回复类型: %q", line)
+	return ""
+}
+
+// expect 发送命令并校验回复
+func (rc *respClient) expect(want string, args ...string) {
+	rc.t.Helper()
+	if got := rc.do(args...); got != want {
+		rc.t.Errorf("❌ 命令 %q 期望 %q，实际 %q", args, want, got)
+	}
+}
+
+// startRESPCluster 启动两个节点，每个节点都开启RESP监听
+func startRESPCluster(t *testing.T, mode string) (*testNodeCluster, map[string]*distributed.RESPServer) {
+	nodeIDs := []string{"node1", "node2"}
+	cluster := startTestNodes(t, nodeIDs, nil)
+
+	// 先占用端口，确定所有RESP地址后再创建服务器
+	peers := make(map[string]string)
+	listeners := make(map[string]net.Listener)
+	for _, nodeID := range nodeIDs {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("❌ 监听端口失败: %v", err)
+		}
+		listeners[nodeID] = listener
+		peers[nodeID] = listener.Addr().String()
+	}
+
+	servers := make(map[string]*distributed.RESPServer)
+	for _, nodeID := range nodeIDs {
+		listeners[nodeID].Close()
+		server := distributed.NewRESPServer(cluster.Node(nodeID), peers[nodeID], mode, peers)
+		if err := server.Start(); err != nil {
+			t.Fatalf("❌ 启动RESP服务失败: %v", err)
+		}
+		t.Cleanup(server.Stop)
+		servers[nodeID] = server
+	}
+	return cluster, servers
+}
+
+// TestRESPBasicCommands 测试基本的键值命令
+func TestRESPBasicCommands(t *testing.T) {
+	_, servers := startRESPCluster(t, distributed.RESPModeProxy)
+	rc := dialRESP(t, servers["node1"].Addr())
+
+	rc.expect("+PONG", "PING")
+	rc.expect("hello", "PING", "hello")
+	rc.expect("+OK", "SET", "user:1", "alice")
+	rc.expect("alice", "GET", "user:1")
+	rc.expect("(nil)", "GET", "user:missing")
+
+	rc.expect("(nil)", "SET", "user:1", "bob", "NX")
+	rc.expect("(nil)", "SET", "user:missing", "bob", "XX")
+	rc.expect("+OK", "SET", "user:1", "bob", "XX")
+	rc.expect("-ERR syntax error", "SET", "user:1", "bob", "NX", "XX")
+	rc.expect("-ERR invalid expire time in 'set' command", "SET", "user:1", "bob", "EX", "0")
+
+	rc.expect("+OK", "MSET", "a", "1", "b", "2", "c", "3")
+	rc.expect("[1 2 (nil) 3]", "MGET", "a", "b", "missing", "c")
+	rc.expect(":2", "EXISTS", "a", "b", "missing")
+	rc.expect(":2", "DEL", "a", "b", "missing")
+
+	rc.expect(":1", "INCR", "counter")
+	rc.expect(":11", "INCRBY", "counter", "10")
+	rc.expect(":-4", "DECRBY", "counter", "15")
+	rc.expect("-ERR value is not an integer or out of range", "INCR", "user:1")
+	rc.expect("+OK", "SET", "big", "9223372036854775807")
+	rc.expect("-ERR value is not an integer or out of range", "INCR", "big")
+
+	rc.expect("-ERR unknown command 'BOGUS'", "BOGUS")
+	rc.expect("-ERR wrong number of arguments for 'get' command", "GET")
+
+	info := rc.do("INFO", "keyspace")
+	if !strings.HasPrefix(info, "# Keyspace\r\ndb0:keys=") {
+		t.Errorf("❌ INFO keyspace 格式错误: %q", info)
+	}
+
+	// 内联命令
+	if _, err := rc.conn.Write([]byte("GET user:1\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got := rc.read(); got != "bob" {
+		t.Errorf("❌ 内联命令回复错误: %q", got)
+	}
+	t.Log("✅ RESP基本命令测试通过")
+}
+
+// TestRESPExpiration 测试EX/PX/KEEPTTL/EXPIRE/TTL
+func TestRESPExpiration(t *testing.T) {
+	_, servers := startRESPCluster(t, distributed.RESPModeProxy)
+	rc := dialRESP(t, servers["node1"].Addr())
+
+	rc.expect("+OK", "SET", "session", "v", "EX", "100")
+	if ttl := rc.do("TTL", "session"); ttl != ":100" && ttl != ":99" {
+		t.Errorf("❌ TTL错误: %s", ttl)
+	}
+	rc.expect("+OK", "SET", "session", "v2", "KEEPTTL")
+	if ttl := rc.do("TTL", "session"); ttl == ":-1" {
+		t.Error("❌ KEEPTTL 不应清除过期时间")
+	}
+	rc.expect("+OK", "SET", "session", "v3")
+	rc.expect(":-1", "TTL", "session")
+	rc.expect(":-2", "TTL", "missing")
+
+	rc.expect(":1", "EXPIRE", "session", "100")
+	rc.expect(":0", "EXPIRE", "missing", "100")
+	rc.expect("+OK", "SET", "short", "v", "PX", "100")
+	time.Sleep(200 * time.Millisecond)
+	rc.expect("(nil)", "GET", "short")
+
+	// 非正数过期时间直接删除
+	rc.expect(":1", "EXPIRE", "session", "0")
+	rc.expect("(nil)", "GET", "session")
+	t.Log("✅ RESP过期时间测试通过")
+}
+
+// TestRESP3Hello 测试HELLO协商RESP3
+func TestRESP3Hello(t *testing.T) {
+	_, servers := startRESPCluster(t, distributed.RESPModeProxy)
+	rc := dialRESP(t, servers["node1"].Addr())
+
+	rc.expect("-NOPROTO unsupported protocol version", "HELLO", "4")
+
+	hello := rc.do("HELLO", "3")
+	if !strings.Contains(hello, "proto :3") {
+		t.Errorf("❌ HELLO回复错误: %s", hello)
+	}
+
+	// RESP3 使用独立的null类型
+	rc.conn.Write([]byte("*2\r\n$3\r\nGET\r\n$7\r\nmissing\r\n"))
+	line, _ := rc.reader.ReadString('\n')
+	if line != "_\r\n" {
+		t.Errorf("❌ RESP3 null格式错误: %q", line)
+	}
+	t.Log("✅ RESP3协商测试通过")
+}
+
+// TestRESPRoutingModes 测试非本地key的转发与MOVED重定向
+func TestRESPRoutingModes(t *testing.T) {
+	t.Run("proxy", func(t *testing.T) {
+		cluster, servers := startRESPCluster(t, distributed.RESPModeProxy)
+		rc := dialRESP(t, servers["node1"].Addr())
+
+		key := keyOwnedBy(t, []string{"node1", "node2"}, "node2", "remote")
+		rc.expect("+OK", "SET", key, "v")
+		if value, found := cluster.Node("node2").GetLocal(key); !found || value != "v" {
+			t.Errorf("❌ 数据未转发到负责节点")
+		}
+		rc.expect("v", "GET", key)
+	})
+
+	t.Run("redirect", func(t *testing.T) {
+		_, servers := startRESPCluster(t, distributed.RESPModeRedirect)
+		rc := dialRESP(t, servers["node1"].Addr())
+
+		remote := keyOwnedBy(t, []string{"node1", "node2"}, "node2", "remote")
+		local := keyOwnedBy(t, []string{"node1", "node2"}, "node1", "local")
+
+		moved := rc.do("SET", remote, "v")
+		want := fmt.Sprintf("-MOVED %s %s", rc.do("CLUSTER", "KEYSLOT", remote)[1:], servers["node2"].Addr())
+		if moved != want {
+			t.Fatalf("❌ MOVED回复错误: %q，期望 %q", moved, want)
+		}
+		rc.expect("+OK", "SET", local, "v")
+
+		// 按MOVED重定向到负责节点后成功
+		rc2 := dialRESP(t, servers["node2"].Addr())
+		rc2.expect("+OK", "SET", remote, "v")
+		rc2.expect("v", "GET", remote)
+
+		// 多key命令由本节点转发，即使所有key属于同一个远端节点
+		rc.expect("[v v]", "MGET", local, remote)
+		rc.expect("[v v]", "MGET", remote, remote)
+
+		nodes := rc.do("CLUSTER", "NODES")
+		if !strings.Contains(nodes, "node1 "+servers["node1"].Addr()+"@0 myself,master") ||
+			!strings.Contains(nodes, "node2 "+servers["node2"].Addr()+"@0 master") {
+			t.Errorf("❌ CLUSTER NODES 错误: %q", nodes)
+		}
+		rc.expect("node1", "CLUSTER", "MYID")
+	})
+	t.Log("✅ RESP路由模式测试通过")
+}
+
+// TestRESPKeySlot 测试与Redis集群一致的槽位计算
+func TestRESPKeySlot(t *testing.T) {
+	_, servers := startRESPCluster(t, distributed.RESPModeProxy)
+	rc := dialRESP(t, servers["node1"].Addr())
+
+	rc.expect(":12182", "CLUSTER", "KEYSLOT", "foo")
+	// hash tag 只对花括号内的内容计算槽位
+	rc.expect(rc.do("CLUSTER", "KEYSLOT", "user1000"), "CLUSTER", "KEYSLOT", "{user1000}.following")
+	t.Log("✅ 槽位计算测试通过")
+}
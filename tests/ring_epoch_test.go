@@ -0,0 +1,194 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"tdd-learning/core"
+	"tdd-learning/distributed"
+)
+
+// TestRingEpochAndChecksum 测试拓扑变更时版本号递增，校验和只取决于视图内容
+func TestRingEpochAndChecksum(t *testing.T) {
+	dc := core.NewDistributedCacheWithVirtualNodes([]string{"node1", "node2", "node3"}, 150)
+	same := core.NewDistributedCacheWithVirtualNodes([]string{"node3", "node1", "node2"}, 150)
+	if dc.Epoch() != 0 || dc.RingChecksum() != same.RingChecksum() {
+		t.Fatalf("❌ 相同视图的初始版本号和校验和应一致: %d %s %s", dc.Epoch(), dc.RingChecksum(), same.RingChecksum())
+	}
+
+	checksums := map[string]bool{dc.RingChecksum(): true}
+	steps := []func(){
+		func() { dc.AddNode("node4") },
+		func() { dc.SetNodeWeight("node2", 2) },
+		func() { dc.RemoveNode("node1") },
+	}
+	for i, step := range steps {
+		step()
+		if dc.Epoch() != uint64(i+1) {
+			t.Errorf("❌ 第%d次变更后版本号错误: %d", i+1, dc.Epoch())
+		}
+		checksum := dc.RingChecksum()
+		if checksums[checksum] {
+			t.Errorf("❌ 第%d次变更后校验和未变化: %s", i+1, checksum)
+		}
+		checksums[checksum] = true
+	}
+
+	// 从另一条路径得到相同视图时校验和一致，版本号可以不同
+	rebuilt := core.NewDistributedCacheWithVirtualNodes([]string{"node2", "node3", "node4"}, 150)
+	rebuilt.SetNodeWeight("node2", 2)
+	if rebuilt.RingChecksum() != dc.RingChecksum() {
+		t.Error("❌ 相同节点和权重的视图校验和应一致")
+	}
+
+	if dc.ObserveEpoch(1) != 3 || dc.ObserveEpoch(10) != 10 || dc.Epoch() != 10 {
+		t.Errorf("❌ ObserveEpoch 应只向前推进: %d", dc.Epoch())
+	}
+	t.Log("✅ 拓扑版本号和校验和测试通过")
+}
+
+// staleRouteKey 找到旧视图中由node2负责、node3权重提高后改由node3负责的key
+func staleRouteKey(t *testing.T, prefix string) string {
+	t.Helper()
+	nodes := []string{"node1", "node2", "node3"}
+	before := core.NewDistributedCacheWithVirtualNodes(nodes, 150)
+	after := core.NewDistributedCacheWithVirtualNodes(nodes, 150)
+	after.SetNodeWeight("node3", 4)
+	for i := 0; i < 10000; i++ {
+		key := fmt.Sprintf("%s:%d", prefix, i)
+		if before.GetNodeForKey(key) == "node2" && after.GetNodeForKey(key) == "node3" {
+			return key
+		}
+	}
+	t.Fatal("❌ 找不到归属变化的key")
+	return ""
+}
+
+// TestStaleRouteRedirect 测试视图过期的节点转发请求时收到重定向并转发到新的负责节点
+func TestStaleRouteRedirect(t *testing.T) {
+	cluster := startTestNodes(t, []string{"node1", "node2", "node3"}, nil)
+
+	// node2/node3 收到权重变更，node1 错过了这次变更
+	for _, nodeID := range []string{"node2", "node3"} {
+		body := `{"node_id":"node3","weight":4,"operation":"weight","epoch":1}`
+		resp, err := http.Post(cluster.URL(nodeID)+"/internal/cluster/sync-weight", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if cluster.Node(nodeID).GetRingEpoch() != 1 {
+			t.Fatalf("❌ %s 版本号应对齐到1: %d", nodeID, cluster.Node(nodeID).GetRingEpoch())
+		}
+	}
+	node1 := cluster.Node("node1")
+	if node1.GetRingChecksum() == cluster.Node("node2").GetRingChecksum() {
+		t.Fatal("❌ 错过变更的节点校验和应不同")
+	}
+
+	// 携带旧版本号直接访问node2，返回421和新的负责节点
+	key := staleRouteKey(t, "stale")
+	req, _ := http.NewRequest("GET", cluster.URL("node2")+"/internal/bin/"+core.EncodeKey(key), nil)
+	req.Header.Set(distributed.ClusterEpochHeader, "0")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var redirect distributed.StaleRouteError
+	json.NewDecoder(resp.Body).Decode(&redirect)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMisdirectedRequest || redirect.Owner != "node3" || redirect.Epoch != 1 ||
+		resp.Header.Get(distributed.ClusterEpochHeader) != "1" {
+		t.Fatalf("❌ 重定向响应错误: %d %+v", resp.StatusCode, redirect)
+	}
+
+	// 发送方版本更新（目标节点尚未收到变更）或不携带版本号（数据迁移）时直接在本地处理
+	for _, header := range []string{"5", ""} {
+		req, _ := http.NewRequest("GET", cluster.URL("node2")+"/internal/bin/"+core.EncodeKey(key), nil)
+		if header != "" {
+			req.Header.Set(distributed.ClusterEpochHeader, header)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("❌ 版本号 %q 时应由本地处理: %d", header, resp.StatusCode)
+		}
+	}
+
+	// node1 通过二进制RPC转发，按重定向写入node3
+	rpcServer := startRPCServer(t, cluster.Node("node2"))
+	defer node1.CloseRPC()
+	rpcKey := staleRouteKey(t, "stale-rpc")
+	if _, err := node1.Execute(rpcKey, distributed.CacheOp{Op: distributed.OpSet, Value: "via-rpc"}); err != nil {
+		t.Fatalf("❌ RPC转发失败: %v", err)
+	}
+	if value, found := cluster.Node("node3").GetLocal(rpcKey); !found || value != "via-rpc" {
+		t.Fatalf("❌ RPC转发的数据应写入node3: %q %v", value, found)
+	}
+	if _, found := cluster.Node("node2").GetLocal(rpcKey); found {
+		t.Error("❌ 不负责该key的node2不应写入数据")
+	}
+	if rpcStats := node1.GetRPCStats(); rpcStats.Calls == 0 {
+		t.Error("❌ 应通过RPC转发")
+	}
+
+	// 停止RPC后回退到HTTP，同样按重定向处理
+	rpcServer.Stop()
+	if err := node1.Set(key, "via-http"); err != nil {
+		t.Fatalf("❌ 转发SET失败: %v", err)
+	}
+	if value, found := cluster.Node("node3").GetLocal(key); !found || value != "via-http" {
+		t.Fatalf("❌ 数据应按重定向写入node3: %q %v", value, found)
+	}
+	if value, found, err := node1.Get(key); err != nil || !found || value != "via-http" {
+		t.Errorf("❌ 转发GET错误: %q %v %v", value, found, err)
+	}
+
+	t.Logf("📊 node1 收到 %d 次重定向", node1.GetStaleRedirects())
+	if node1.GetStaleRedirects() < 3 {
+		t.Errorf("❌ 重定向次数错误: %d", node1.GetStaleRedirects())
+	}
+	t.Log("✅ 过期路由重定向测试通过")
+}
+
+// TestHealthReportsRingChecksum 测试集群健康检查返回拓扑版本号和哈希环校验和
+func TestHealthReportsRingChecksum(t *testing.T) {
+	cluster := startTestNodes(t, []string{"node1", "node2"}, nil)
+
+	health := func(nodeID string) (epoch uint64, checksum string) {
+		resp, err := http.Get(cluster.URL(nodeID) + "/internal/cluster/health")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var body struct {
+			Epoch        uint64 `json:"epoch"`
+			RingChecksum string `json:"ring_checksum"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		return body.Epoch, body.RingChecksum
+	}
+
+	epoch1, checksum1 := health("node1")
+	epoch2, checksum2 := health("node2")
+	if checksum1 == "" || checksum1 != checksum2 || epoch1 != epoch2 {
+		t.Fatalf("❌ 相同配置的节点视图应一致: %d/%s %d/%s", epoch1, checksum1, epoch2, checksum2)
+	}
+
+	// 加入一个新节点后版本号增加、校验和变化
+	body := `{"node_id":"node3","address":"127.0.0.1:1"}`
+	resp, err := http.Post(cluster.URL("node1")+"/internal/cluster/join", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if epoch, checksum := health("node1"); epoch != epoch1+1 || checksum == checksum1 {
+		t.Errorf("❌ 节点加入后视图未更新: %d/%s", epoch, checksum)
+	}
+	t.Log("✅ 健康检查哈希环校验和测试通过")
+}
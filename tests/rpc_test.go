@@ -0,0 +1,350 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tdd-learning/core"
+	"tdd-learning/distributed"
+)
+
+// startRPCServer 为节点启动RPC监听
+func startRPCServer(t testing.TB, node *distributed.DistributedNode) *distributed.RPCServer {
+	server := distributed.NewRPCServer(node, "127.0.0.1:0")
+	if err := server.Start(); err != nil {
+		t.Fatalf("❌ 启动RPC服务失败: %v", err)
+	}
+	t.Cleanup(server.Stop)
+	return server
+}
+
+// TestRPCForwarding 测试非本地key通过二进制RPC转发
+func TestRPCForwarding(t *testing.T) {
+	cluster := startTestNodes(t, []string{"node1", "node2"}, nil)
+	startRPCServer(t, cluster.Node("node2"))
+	node1 := cluster.Node("node1")
+	defer node1.CloseRPC()
+
+	key := keyOwnedBy(t, []string{"node1", "node2"}, "node2", "rpc")
+
+	if err := node1.Set(key, "value"); err != nil {
+		t.Fatalf("❌ 转发SET失败: %v", err)
+	}
+	if value, found := cluster.Node("node2").GetLocal(key); !found || value != "value" {
+		t.Fatalf("❌ 数据未写入负责节点")
+	}
+	if value, found, err := node1.Get(key); err != nil || !found || value != "value" {
+		t.Errorf("❌ 转发GET错误: %q %v %v", value, found, err)
+	}
+
+	result, err := node1.Execute(key, distributed.CacheOp{Op: distributed.OpAdd, Value: "other"})
+	if err != nil || result.Status != distributed.OpStatusNotStored || result.NodeID != "node2" {
+		t.Errorf("❌ 转发条件操作错误: %+v %v", result, err)
+	}
+
+	if err := node1.Delete(key); err != nil {
+		t.Errorf("❌ 转发DELETE失败: %v", err)
+	}
+	if _, found, _ := node1.Get(key); found {
+		t.Error("❌ 删除后仍能读取")
+	}
+
+	stats := node1.GetRPCStats()
+	t.Logf("📊 RPC统计: %+v", stats)
+	if stats.Calls != 5 || stats.HTTPFallbacks != 0 {
+		t.Errorf("❌ 应全部通过RPC转发: %+v", stats)
+	}
+	t.Log("✅ RPC转发测试通过")
+}
+
+// TestRPCBatchOperations 测试批量操作按节点分组，每个远端节点一次RPC
+func TestRPCBatchOperations(t *testing.T) {
+	cluster := startTestNodes(t, []string{"node1", "node2", "node3"}, nil)
+	startRPCServer(t, cluster.Node("node2"))
+	startRPCServer(t, cluster.Node("node3"))
+	node1 := cluster.Node("node1")
+	defer node1.CloseRPC()
+
+	data := make(map[string]string)
+	keys := make([]string, 0, 100)
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("batch:%d", i)
+		data[key] = fmt.Sprintf("value-%d", i)
+		keys = append(keys, key)
+	}
+
+	if err := node1.BatchSet(data); err != nil {
+		t.Fatalf("❌ 批量设置失败: %v", err)
+	}
+	values, err := node1.BatchGet(append(keys, "batch:missing"))
+	if err != nil {
+		t.Fatalf("❌ 批量获取失败: %v", err)
+	}
+	if len(values) != len(data) {
+		t.Errorf("❌ 批量获取数量错误: %d", len(values))
+	}
+	for key, value := range data {
+		if values[key] != value {
+			t.Errorf("❌ key %s 值错误: %q", key, values[key])
+		}
+	}
+
+	if err := node1.BatchDelete(keys); err != nil {
+		t.Fatalf("❌ 批量删除失败: %v", err)
+	}
+	if values, _ := node1.BatchGet(keys); len(values) != 0 {
+		t.Errorf("❌ 批量删除后仍有 %d 个key", len(values))
+	}
+
+	// 3次批量操作 + 1次校验，每次对两个远端节点各发一个请求
+	stats := node1.GetRPCStats()
+	t.Logf("📊 RPC统计: %+v", stats)
+	if stats.Calls != 8 || stats.HTTPFallbacks != 0 {
+		t.Errorf("❌ 批量操作应每个远端节点一次RPC: %+v", stats)
+	}
+	t.Log("✅ RPC批量操作测试通过")
+}
+
+// TestRPCFallbackToHTTP 测试对端不支持RPC或RPC断开时回退到HTTP
+func TestRPCFallbackToHTTP(t *testing.T) {
+	cluster := startTestNodes(t, []string{"node1", "node2"}, nil)
+	node1 := cluster.Node("node1")
+	defer node1.CloseRPC()
+	key := keyOwnedBy(t, []string{"node1", "node2"}, "node2", "fallback")
+
+	// node2 未启用RPC
+	if err := node1.Set(key, "http"); err != nil {
+		t.Fatalf("❌ HTTP转发失败: %v", err)
+	}
+	if stats := node1.GetRPCStats(); stats.Calls != 0 || stats.HTTPFallbacks != 1 {
+		t.Errorf("❌ 应回退到HTTP: %+v", stats)
+	}
+	if value, found := cluster.Node("node2").GetLocal(key); !found || value != "http" {
+		t.Error("❌ HTTP转发的数据未写入")
+	}
+
+	// RPC服务停止后，已建立的连接失效，之后的请求没有发出，应自动回退到HTTP
+	fallbackCluster := startTestNodes(t, []string{"node1", "node2"}, nil)
+	nodeA := fallbackCluster.Node("node1")
+	defer nodeA.CloseRPC()
+	server := startRPCServer(t, fallbackCluster.Node("node2"))
+
+	if err := nodeA.Set(key, "rpc"); err != nil {
+		t.Fatalf("❌ RPC转发失败: %v", err)
+	}
+	server.Stop()
+	time.Sleep(50 * time.Millisecond) // 等待客户端发现连接断开
+	if err := nodeA.Set(key, "after-stop"); err != nil {
+		t.Fatalf("❌ RPC停止后转发失败: %v", err)
+	}
+	if value, _ := fallbackCluster.Node("node2").GetLocal(key); value != "after-stop" {
+		t.Errorf("❌ 回退后数据错误: %q", value)
+	}
+	stats := nodeA.GetRPCStats()
+	t.Logf("📊 RPC统计: %+v", stats)
+	if stats.Calls != 1 || stats.HTTPFallbacks != 1 {
+		t.Errorf("❌ RPC停止后应回退到HTTP: %+v", stats)
+	}
+	t.Log("✅ RPC回退测试通过")
+}
+
+// TestRPCNoHTTPRetryAfterSend 测试RPC请求发出后连接断开时直接返回错误，不再通过HTTP重试，
+// 避免incr等非幂等操作在对端执行两次
+func TestRPCNoHTTPRetryAfterSend(t *testing.T) {
+	// 读取请求后直接断开连接的RPC端口
+	rpcListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rpcListener.Close()
+	go func() {
+		for {
+			conn, err := rpcListener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Read(make([]byte, 1024))
+			conn.Close()
+		}
+	}()
+
+	// 假的node2：公布上面的RPC端口，记录收到的其他HTTP请求
+	var httpRequests int64
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/internal/rpc/info" {
+			json.NewEncoder(w).Encode(distributed.RPCInfo{NodeID: "node2", RPCAddress: rpcListener.Addr().String()})
+			return
+		}
+		atomic.AddInt64(&httpRequests, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer peer.Close()
+
+	node1 := distributed.NewDistributedNode(distributed.NodeConfig{
+		NodeID:       "node1",
+		ClusterNodes: map[string]string{"node1": "127.0.0.1:1", "node2": peer.Listener.Addr().String()},
+		CacheSize:    100,
+		VirtualNodes: 150,
+	})
+	defer node1.CloseRPC()
+	key := keyOwnedBy(t, []string{"node1", "node2"}, "node2", "incr")
+
+	if _, err := node1.Execute(key, distributed.CacheOp{Op: distributed.OpIncr, Delta: 1}); err == nil {
+		t.Error("❌ 请求发出后连接断开应返回错误")
+	}
+	if n := atomic.LoadInt64(&httpRequests); n != 0 {
+		t.Errorf("❌ 已发出的RPC请求不应通过HTTP重试: %d 次HTTP请求", n)
+	}
+	t.Log("✅ 已发出的请求不回退到HTTP")
+}
+
+// TestRPCLargeFrames 测试最大的value能放进一帧，超过帧上限的请求在发送前返回错误，
+// 大value的迁移按字节数拆分批次
+func TestRPCLargeFrames(t *testing.T) {
+	nodeIDs := []string{"node1", "node2", "node3"}
+	cluster := startTestNodes(t, nodeIDs, nil)
+	startRPCServer(t, cluster.Node("node2"))
+	node1 := cluster.Node("node1")
+	defer node1.CloseRPC()
+	key := keyOwnedBy(t, nodeIDs, "node2", "large")
+
+	maxValue := strings.Repeat("x", 64*1024*1024)
+	if err := node1.Set(key, maxValue); err != nil {
+		t.Fatalf("❌ 最大value转发失败: %v", err)
+	}
+	if value, _ := cluster.Node("node2").GetLocal(key); len(value) != len(maxValue) {
+		t.Fatalf("❌ 最大value写入错误: %d 字节", len(value))
+	}
+	cluster.Node("node2").DeleteLocal(key)
+
+	err := node1.Set(key, maxValue+strings.Repeat("x", 2*1024*1024))
+	if err == nil || !strings.Contains(err.Error(), "RPC帧过大") {
+		t.Errorf("❌ 超过帧上限应返回明确的错误: %v", err)
+	}
+	if err := node1.Set(key, "small"); err != nil {
+		t.Errorf("❌ 过大的请求不应影响同一连接上的其他请求: %v", err)
+	}
+	if stats := node1.GetRPCStats(); stats.HTTPFallbacks != 0 {
+		t.Errorf("❌ 不应回退到HTTP: %+v", stats)
+	}
+	maxValue = ""
+
+	// 修改权重后从node1移到node2的80个1MiB的key，总大小超过一帧
+	before := core.NewDistributedCacheWithVirtualNodes(nodeIDs, 150)
+	after := core.NewDistributedCacheWithVirtualNodes(nodeIDs, 150)
+	after.SetNodeWeight("node2", 3)
+	var moved []string
+	for i := 0; len(moved) < 80; i++ {
+		key := fmt.Sprintf("bulk:%d", i)
+		if before.GetNodeForKey(key) == "node1" && after.GetNodeForKey(key) == "node2" {
+			moved = append(moved, key)
+			node1.SetLocal(key, strings.Repeat("v", 1024*1024))
+		}
+	}
+	sync := `{"node_id":"node2","weight":3,"operation":"weight"}`
+	resp, err := http.Post(cluster.URL("node1")+"/internal/cluster/sync-weight", "application/json", strings.NewReader(sync))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	for _, key := range moved {
+		if value, found := cluster.Node("node2").GetLocal(key); !found || len(value) != 1024*1024 {
+			t.Fatalf("❌ %s 未迁移到node2", key)
+		}
+		if _, found := node1.GetLocal(key); found {
+			t.Errorf("❌ %s 迁移后应从node1删除", key)
+		}
+	}
+	t.Log("✅ RPC大帧测试通过")
+}
+
+// TestRPCMultiplexing 测试同一连接池上大量并发请求的响应不会串位
+func TestRPCMultiplexing(t *testing.T) {
+	cluster := startTestNodes(t, []string{"node1", "node2"}, func(config *distributed.NodeConfig) {
+		config.RPCPoolSize = 2
+	})
+	startRPCServer(t, cluster.Node("node2"))
+	node1 := cluster.Node("node1")
+	defer node1.CloseRPC()
+
+	keys := make([]string, 0, 50)
+	for i := 0; len(keys) < 50; i++ {
+		key := fmt.Sprintf("mux:%d", i)
+		if !node1.IsLocalKey(key) {
+			keys = append(keys, key)
+			cluster.Node("node2").SetLocal(key, "value-"+key)
+		}
+	}
+
+	var wg sync.WaitGroup
+	errors := make(chan string, 1000)
+	for worker := 0; worker < 20; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				key := keys[(worker+i)%len(keys)]
+				value, found, err := node1.Get(key)
+				if err != nil || !found || value != "value-"+key {
+					errors <- fmt.Sprintf("%s => %q %v %v", key, value, found, err)
+				}
+			}
+		}(worker)
+	}
+	wg.Wait()
+	close(errors)
+
+	for msg := range errors {
+		t.Errorf("❌ 并发响应错误: %s", msg)
+	}
+	if stats := node1.GetRPCStats(); stats.Calls != 1000 {
+		t.Errorf("❌ RPC调用次数错误: %+v", stats)
+	}
+	t.Log("✅ RPC多路复用测试通过")
+}
+
+// benchmarkForwardGet 测量非本地key的转发延迟
+func benchmarkForwardGet(b *testing.B, withRPC bool) {
+	cluster := startTestNodes(b, []string{"node1", "node2"}, nil)
+	if withRPC {
+		startRPCServer(b, cluster.Node("node2"))
+	}
+	node1 := cluster.Node("node1")
+	defer node1.CloseRPC()
+
+	key := ""
+	for i := 0; key == ""; i++ {
+		if candidate := fmt.Sprintf("bench:%d", i); !node1.IsLocalKey(candidate) {
+			key = candidate
+		}
+	}
+	node1.Set(key, "value")
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, _, err := node1.Get(key); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+// BenchmarkForwardGetHTTP 通过JSON-over-HTTP转发
+func BenchmarkForwardGetHTTP(b *testing.B) {
+	benchmarkForwardGet(b, false)
+}
+
+// BenchmarkForwardGetRPC 通过二进制RPC转发
+func BenchmarkForwardGetRPC(b *testing.B) {
+	benchmarkForwardGet(b, true)
+}
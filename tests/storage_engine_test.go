@@ -0,0 +1,396 @@
+package tests
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"tdd-learning/core"
+	"tdd-learning/distributed"
+)
+
+// cacheEngine 两种存储引擎的公共方法
+type cacheEngine interface {
+	Set(key, value string)
+	Get(key string) (string, bool)
+	Delete(key string) bool
+	Size() int
+}
+
+// TestArenaLRUCacheEviction 测试arena引擎与LRUCache的淘汰语义一致
+func TestArenaLRUCacheEviction(t *testing.T) {
+	engines := map[string]cacheEngine{
+		"heap":  core.NewLRUCache(3),
+		"arena": core.NewArenaLRUCache(3),
+	}
+
+	for name, cache := range engines {
+		cache.Set("a", "1")
+		cache.Set("b", "2")
+		cache.Set("c", "3")
+
+		// 访问a使其成为最近使用，再写入d应淘汰b
+		cache.Get("a")
+		cache.Set("d", "4")
+
+		if _, found := cache.Get("b"); found {
+			t.Errorf("❌ [%s] b应该被淘汰", name)
+		}
+		for _, key := range []string{"a", "c", "d"} {
+			if _, found := cache.Get(key); !found {
+				t.Errorf("❌ [%s] %s不应该被淘汰", name, key)
+			}
+		}
+
+		// 覆盖写入不改变大小
+		cache.Set("a", "updated")
+		if value, _ := cache.Get("a"); value != "updated" {
+			t.Errorf("❌ [%s] 覆盖写入失败: %s", name, value)
+		}
+		if cache.Size() != 3 {
+			t.Errorf("❌ [%s] 大小错误: 期望=3, 实际=%d", name, cache.Size())
+		}
+
+		if !cache.Delete("c") || cache.Delete("c") {
+			t.Errorf("❌ [%s] 删除结果错误", name)
+		}
+		if cache.Size() != 2 {
+			t.Errorf("❌ [%s] 删除后大小错误: 期望=2, 实际=%d", name, cache.Size())
+		}
+	}
+}
+
+// TestArenaLRUCacheMemoryLimitAndTTL 测试arena引擎的内存限制和TTL
+func TestArenaLRUCacheMemoryLimitAndTTL(t *testing.T) {
+	// 每个条目占用 1+10+64 = 75 字节，限制为两个条目
+	cache := core.NewArenaLRUCacheWithMemoryLimit(100, 150)
+	value := strings.Repeat("x", 10)
+	cache.Set("a", value)
+	cache.Set("b", value)
+	cache.Set("c", value)
+
+	if cache.Size() != 2 {
+		t.Errorf("❌ 内存限制淘汰失败: 大小=%d", cache.Size())
+	}
+	if _, found := cache.Get("a"); found {
+		t.Error("❌ a应该因内存限制被淘汰")
+	}
+	if cache.GetMemoryUsage() != 150 {
+		t.Errorf("❌ 内存使用量错误: %d", cache.GetMemoryUsage())
+	}
+
+	ttlCache := core.NewArenaLRUCacheWithCleanup(10, 20*time.Millisecond)
+	defer ttlCache.Close()
+	ttlCache.SetWithTTL("temp", "v", 30*time.Millisecond)
+	ttlCache.Set("keep", "v")
+
+	time.Sleep(80 * time.Millisecond)
+	if _, found := ttlCache.Get("temp"); found {
+		t.Error("❌ 过期key应该被清理")
+	}
+	if _, found := ttlCache.Get("keep"); !found {
+		t.Error("❌ 未设置TTL的key不应被清理")
+	}
+}
+
+// TestArenaLRUCacheCompaction 测试大量覆盖写入后arena压缩不丢数据
+func TestArenaLRUCacheCompaction(t *testing.T) {
+	cache := core.NewArenaLRUCache(1000)
+	payload := strings.Repeat("v", 4096)
+
+	for round := 0; round < 5; round++ {
+		for i := 0; i < 1000; i++ {
+			cache.Set(fmt.Sprintf("key:%d", i), fmt.Sprintf("%d:%s", round, payload))
+		}
+	}
+
+	// 存活数据约4MB，未压缩时会接近20MB
+	if cache.ArenaBytes() > 10*1024*1024 {
+		t.Errorf("❌ arena未压缩: %d 字节", cache.ArenaBytes())
+	}
+	for i := 0; i < 1000; i++ {
+		value, found := cache.Get(fmt.Sprintf("key:%d", i))
+		if !found || !strings.HasPrefix(value, "4:") {
+			t.Fatalf("❌ 压缩后数据错误: key:%d", i)
+		}
+	}
+	if len(cache.GetAllData()) != 1000 {
+		t.Error("❌ GetAllData数量错误")
+	}
+}
+
+// TestArenaLRUCacheHashCollision 测试64位哈希冲突时各key互不覆盖
+func TestArenaLRUCacheHashCollision(t *testing.T) {
+	// 所有key哈希相同，全部落在同一条链上
+	cache := core.NewArenaLRUCacheWithHasher(3, func(key string) uint64 { return 42 })
+	cache.Set("a", "1")
+	cache.Set("b", "2")
+	cache.Set("c", "3")
+
+	for key, expected := range map[string]string{"a": "1", "b": "2", "c": "3"} {
+		if value, found := cache.Get(key); !found || value != expected {
+			t.Errorf("❌ 哈希冲突的key %s 读取错误: %q %v", key, value, found)
+		}
+	}
+
+	// 删除链中间的key不影响其余key
+	cache.Set("a", "updated")
+	if !cache.Delete("b") || cache.Size() != 2 {
+		t.Fatalf("❌ 删除冲突key失败: 大小=%d", cache.Size())
+	}
+	if value, found := cache.Get("a"); !found || value != "updated" {
+		t.Errorf("❌ 更新冲突key失败: %q %v", value, found)
+	}
+	if _, found := cache.Get("b"); found {
+		t.Error("❌ b应该已被删除")
+	}
+
+	// 按LRU淘汰最久未使用的c，而不是哈希相同的其他key
+	cache.Set("d", "4")
+	cache.Set("e", "5")
+	if _, found := cache.Get("c"); found {
+		t.Error("❌ c应该被淘汰")
+	}
+	for _, key := range []string{"a", "d", "e"} {
+		if _, found := cache.Get(key); !found {
+			t.Errorf("❌ %s 不应被淘汰", key)
+		}
+	}
+	t.Log("✅ 哈希冲突的key通过链式存储共存")
+}
+
+// TestStorageEngineParity 测试两种存储引擎通过 Storage 接口提供一致的语义
+func TestStorageEngineParity(t *testing.T) {
+	hasher, _ := core.NewHasher(core.HasherXXHash)
+
+	for _, engine := range core.StorageEngineNames {
+		cache, err := core.NewStorage(engine, 10, 0)
+		if err != nil {
+			t.Fatalf("❌ 创建存储引擎 %s 失败: %v", engine, err)
+		}
+
+		// 元数据与大key
+		cache.Set("small", "v")
+		cache.Set("large", strings.Repeat("x", 100))
+		cache.Get("small")
+		if meta, found := cache.Inspect("small"); !found || meta.AccessCount != 1 || meta.Size != int64(len("small")+1+64) {
+			t.Errorf("❌ [%s] 元数据错误: %+v", engine, meta)
+		}
+		if top := cache.LargestKeys(1); len(top) != 1 || top[0].Key != "large" {
+			t.Errorf("❌ [%s] 大key报告错误: %+v", engine, top)
+		}
+
+		// 原子读改写与TTL
+		entry, written := cache.Update("counter", func(current core.Entry, found bool) (core.Entry, time.Duration, bool) {
+			return core.Entry{Value: "1", Flags: 7}, time.Hour, !found
+		})
+		if !written || entry.Flags != 7 || entry.Version == 0 {
+			t.Errorf("❌ [%s] Update结果错误: %+v %v", engine, entry, written)
+		}
+		if meta, _ := cache.Inspect("counter"); meta.ExpiresAt == nil {
+			t.Errorf("❌ [%s] Update应设置过期时间", engine)
+		}
+		if !cache.Expire("counter", 0) {
+			t.Errorf("❌ [%s] Expire失败", engine)
+		}
+		if entry, found := cache.GetEntry("counter"); !found || entry.Flags != 7 {
+			t.Errorf("❌ [%s] GetEntry错误: %+v %v", engine, entry, found)
+		}
+		// DeleteTTL 在同一次读改写中删除key并留下删除标记
+		cache.Set("doomed", "v")
+		if _, written := cache.Update("doomed", func(current core.Entry, found bool) (core.Entry, time.Duration, bool) {
+			return current, core.DeleteTTL, found
+		}); !written {
+			t.Errorf("❌ [%s] DeleteTTL 应视为写入", engine)
+		}
+		if entry, found := cache.GetVersioned("doomed"); !found || !entry.Tombstone {
+			t.Errorf("❌ [%s] DeleteTTL 应留下删除标记: %+v %v", engine, entry, found)
+		}
+
+		// 多副本版本与删除标记
+		version := cache.NewVersion()
+		cache.SetVersioned("replica", core.VersionedEntry{Value: "new", Version: version + 10})
+		cache.SetVersioned("replica", core.VersionedEntry{Value: "old", Version: version})
+		if entry, _ := cache.GetVersioned("replica"); entry.Value != "new" {
+			t.Errorf("❌ [%s] 旧版本不应覆盖新版本: %+v", engine, entry)
+		}
+		cache.SetVersioned("replica", core.VersionedEntry{Version: version + 20, Tombstone: true})
+		if entry, found := cache.GetVersioned("replica"); !found || !entry.Tombstone {
+			t.Errorf("❌ [%s] 删除标记错误: %+v", engine, entry)
+		}
+		if entries := cache.VersionedEntries(); !entries["replica"].Tombstone || entries["small"].Version == 0 {
+			t.Errorf("❌ [%s] 版本快照错误: %+v", engine, entries)
+		}
+
+		// 哈希区间
+		cache.EnableHashIndex(hasher.Hash)
+		if all := cache.GetDataInHashRange(0, 0); len(all) != cache.Size() {
+			t.Errorf("❌ [%s] 整个环应包含全部 %d 个key: %d", engine, cache.Size(), len(all))
+		}
+
+		// 运行时缩容与内存限制
+		if evicted, _ := cache.Resize(2); evicted != 1 || cache.Size() != 2 || cache.Capacity() != 2 {
+			t.Errorf("❌ [%s] 缩容错误: 淘汰=%d 大小=%d", engine, evicted, cache.Size())
+		}
+		if _, err := cache.SetMemoryLimit(100); err != nil || cache.GetMemoryUsage() > 100 || cache.GetMemoryLimit() != 100 {
+			t.Errorf("❌ [%s] 内存限制错误: %d %v", engine, cache.GetMemoryUsage(), err)
+		}
+		if cache.TrySet("huge", strings.Repeat("x", 200)) {
+			t.Errorf("❌ [%s] 超出内存限制的写入应被拒绝", engine)
+		}
+
+		// 准入窗口：容量2时窗口1个，低频key离开窗口时被淘汰
+		cache.SetMemoryLimit(0)
+		cache.EnableAdmission(100)
+		cache.Resize(2)
+		for _, key := range []string{"a", "b", "c"} {
+			cache.Set(key, "v")
+		}
+		if _, found := cache.Inspect("b"); found {
+			t.Errorf("❌ [%s] 低频key离开窗口时应被淘汰", engine)
+		}
+		if !cache.SetBypassAdmission("migrated", "v") {
+			t.Errorf("❌ [%s] 跳过准入的写入失败", engine)
+		}
+		if _, found := cache.Inspect("migrated"); !found {
+			t.Errorf("❌ [%s] 跳过准入的key应直接进入主缓存", engine)
+		}
+		if stats, enabled := cache.GetAdmissionStats(); !enabled || stats.Rejected == 0 {
+			t.Errorf("❌ [%s] 准入统计错误: %+v", engine, stats)
+		}
+	}
+	t.Log("✅ 两种存储引擎语义一致")
+}
+
+// TestVersionedEntriesInHashRanges 测试两种引擎一次取出多个区间的结果与逐个区间查询一致，并带上版本和删除标记
+func TestVersionedEntriesInHashRanges(t *testing.T) {
+	hasher, _ := core.NewHasher(core.HasherXXHash)
+	ranges := []core.HashInterval{
+		{Start: 0x10000000, End: 0x30000000},
+		{Start: 0x30000000, End: 0x30000100}, // 与上一个区间首尾相接
+		{Start: 0x80000000, End: 0xA0000000},
+		{Start: 0xF0000000, End: 0x08000000}, // 跨过0点
+	}
+
+	for _, engine := range core.StorageEngineNames {
+		cache, err := core.NewStorage(engine, 5000, 0)
+		if err != nil {
+			t.Fatalf("❌ 创建存储引擎 %s 失败: %v", engine, err)
+		}
+		cache.EnableHashIndex(hasher.Hash)
+		for i := 0; i < 2000; i++ {
+			cache.Set(fmt.Sprintf("key:%d", i), fmt.Sprintf("v%d", i))
+		}
+		version := cache.NewVersion()
+		for i := 0; i < 100; i++ {
+			cache.SetVersioned(fmt.Sprintf("key:%d", i), core.VersionedEntry{Version: version + 1, Tombstone: true})
+		}
+
+		result := cache.VersionedEntriesInHashRanges(ranges)
+		if len(result) != len(ranges) {
+			t.Fatalf("❌ [%s] 结果数量 %d，期望 %d", engine, len(result), len(ranges))
+		}
+		tombstones := 0
+		for i, r := range ranges {
+			expected := cache.GetDataInHashRange(r.Start, r.End)
+			live := 0
+			for key, entry := range result[i] {
+				if entry.Tombstone {
+					tombstones++
+					h := hasher.Hash(key)
+					if (r.Start < r.End && (h <= r.Start || h > r.End)) || (r.Start >= r.End && h <= r.Start && h > r.End) {
+						t.Errorf("❌ [%s] 删除标记 %s 不在区间 %d 内", engine, key, i)
+					}
+					continue
+				}
+				live++
+				if entry.Value != expected[key] || entry.Version == 0 {
+					t.Errorf("❌ [%s] 区间 %d 中 %s 的条目错误: %+v", engine, i, key, entry)
+				}
+			}
+			if live != len(expected) {
+				t.Errorf("❌ [%s] 区间 %d 数量 %d，期望 %d", engine, i, live, len(expected))
+			}
+		}
+		if tombstones == 0 {
+			t.Errorf("❌ [%s] 区间内的删除标记应一并返回", engine)
+		}
+		t.Logf("📊 [%s] 区间内删除标记 %d 个", engine, tombstones)
+	}
+	t.Log("✅ 多区间查询与逐个区间查询一致")
+}
+
+// TestNodeArenaStorageEngine 测试节点通过配置选择arena存储引擎
+func TestNodeArenaStorageEngine(t *testing.T) {
+	cluster := startTestNodes(t, []string{"node1", "node2"}, func(config *distributed.NodeConfig) {
+		config.StorageEngine = core.StorageEngineArena
+	})
+
+	for i := 0; i < 50; i++ {
+		if err := cluster.Node("node1").Set(fmt.Sprintf("arena:%d", i), "v"); err != nil {
+			t.Fatalf("❌ 写入失败: %v", err)
+		}
+	}
+	for i := 0; i < 50; i++ {
+		if value, found, err := cluster.Node("node2").Get(fmt.Sprintf("arena:%d", i)); err != nil || !found || value != "v" {
+			t.Fatalf("❌ 读取失败: %q %v %v", value, found, err)
+		}
+	}
+	if result, err := cluster.Node("node1").InspectKey("arena:0"); err != nil || !result.Found {
+		t.Errorf("❌ 查询元数据失败: %+v %v", result, err)
+	}
+	if _, err := cluster.Node("node1").ResizeCache(10); err != nil {
+		t.Errorf("❌ 调整容量失败: %v", err)
+	}
+	if size := cluster.Node("node1").GetLocalStats()["total_Size"]; size.(int) > 10 {
+		t.Errorf("❌ 缩容后本地条目数错误: %v", size)
+	}
+	t.Log("✅ 节点使用arena存储引擎")
+}
+
+// benchmarkEngineGC 预填充大量条目后执行读写，报告GC耗时
+// gc-ms 为强制完整GC的墙钟时间，主要由标记阶段需要扫描的指针数量决定
+func benchmarkEngineGC(b *testing.B, cache cacheEngine, entries int) {
+	for i := 0; i < entries; i++ {
+		cache.Set(fmt.Sprintf("key:%d", i), fmt.Sprintf("value:%d", i))
+	}
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		key := fmt.Sprintf("key:%d", i%entries)
+		if i%4 == 0 {
+			cache.Set(key, key)
+		} else {
+			cache.Get(key)
+		}
+	}
+	b.StopTimer()
+
+	gcStart := time.Now()
+	runtime.GC()
+	gcDuration := time.Since(gcStart)
+
+	runtime.ReadMemStats(&after)
+	runtime.KeepAlive(cache)
+
+	b.ReportMetric(float64(gcDuration.Microseconds())/1000, "gc-ms")
+	b.ReportMetric(float64(after.PauseTotalNs-before.PauseTotalNs)/1e6, "gc-pause-ms")
+	b.ReportMetric(float64(after.NumGC-before.NumGC), "gc-cycles")
+	b.ReportMetric(float64(after.HeapObjects), "heap-objects")
+}
+
+// BenchmarkLRUCacheGC 指针链表引擎的GC开销
+func BenchmarkLRUCacheGC(b *testing.B) {
+	benchmarkEngineGC(b, core.NewLRUCache(1_000_000), 1_000_000)
+}
+
+// BenchmarkArenaLRUCacheGC arena引擎的GC开销
+func BenchmarkArenaLRUCacheGC(b *testing.B) {
+	benchmarkEngineGC(b, core.NewArenaLRUCache(1_000_000), 1_000_000)
+}
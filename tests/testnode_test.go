@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	"tdd-learning/distributed"
+)
+
+// testNodeCluster 基于httptest的进程内多节点集群（不启动集群管理器后台任务）
+type testNodeCluster struct {
+	servers map[string]*distributed.NodeServer
+	https   map[string]*httptest.Server
+	addrs   map[string]string
+}
+
+// startTestNodes 启动进程内节点，configure 可在创建前修改每个节点的配置
+func startTestNodes(t testing.TB, nodeIDs []string, configure func(*distributed.NodeConfig)) *testNodeCluster {
+	t.Helper()
+
+	cluster := &testNodeCluster{
+		servers: make(map[string]*distributed.NodeServer),
+		https:   make(map[string]*httptest.Server),
+		addrs:   make(map[string]string),
+	}
+
+	// 先占用端口，确定所有节点地址后再创建节点
+	listeners := make(map[string]net.Listener)
+	for _, nodeID := range nodeIDs {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("❌ 监听端口失败: %v", err)
+		}
+		listeners[nodeID] = listener
+		cluster.addrs[nodeID] = listener.Addr().String()
+	}
+
+	for _, nodeID := range nodeIDs {
+		config := distributed.NodeConfig{
+			NodeID:       nodeID,
+			Address:      cluster.addrs[nodeID],
+			ClusterNodes: cluster.addrs,
+			CacheSize:    1000,
+			VirtualNodes: 150,
+		}
+		if configure != nil {
+			configure(&config)
+		}
+
+		server := distributed.NewNodeServer(config)
+		httpServer := httptest.NewUnstartedServer(server.Handler())
+		httpServer.Listener.Close()
+		httpServer.Listener = listeners[nodeID]
+		httpServer.Start()
+
+		cluster.servers[nodeID] = server
+		cluster.https[nodeID] = httpServer
+	}
+
+	t.Cleanup(cluster.Close)
+	return cluster
+}
+
+// Node 获取指定节点的DistributedNode
+func (tc *testNodeCluster) Node(nodeID string) *distributed.DistributedNode {
+	return tc.servers[nodeID].GetNode()
+}
+
+// URL 获取指定节点的HTTP地址
+func (tc *testNodeCluster) URL(nodeID string) string {
+	return "http://" + tc.addrs[nodeID]
+}
+
+// Close 关闭所有节点
+func (tc *testNodeCluster) Close() {
+	for _, httpServer := range tc.https {
+		httpServer.Close()
+	}
+}
+
+// Stop 关闭指定节点的HTTP服务，模拟节点宕机
+func (tc *testNodeCluster) Stop(nodeID string) {
+	tc.https[nodeID].Close()
+}
+
+// Restart 在原地址上重新启动节点的HTTP服务（节点内存中的数据保留），模拟节点恢复
+func (tc *testNodeCluster) Restart(t testing.TB, nodeID string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", tc.addrs[nodeID])
+	if err != nil {
+		t.Fatalf("❌ 重新监听端口失败: %v", err)
+	}
+	httpServer := httptest.NewUnstartedServer(tc.servers[nodeID].Handler())
+	httpServer.Listener.Close()
+	httpServer.Listener = listener
+	httpServer.Start()
+	tc.https[nodeID] = httpServer
+}
+
+// Server 获取指定节点的NodeServer
+func (tc *testNodeCluster) Server(nodeID string) *distributed.NodeServer {
+	return tc.servers[nodeID]
+}
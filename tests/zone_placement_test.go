@@ -0,0 +1,127 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+
+	"tdd-learning/core"
+	"tdd-learning/distributed"
+	"tdd-learning/monitoring"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TestClusterNodesZoneConfig 测试从配置读取可用区和机架，默认副本分布在不同可用区
+func TestClusterNodesZoneConfig(t *testing.T) {
+	data := `
+node_id: "a1"
+cluster_nodes:
+  a1: {address: "localhost:8001", zone: "zone-a", rack: "r1"}
+  a2: {address: "localhost:8002", zone: "zone-a", rack: "r2"}
+  b1: {address: "localhost:8003", zone: "zone-b"}
+  b2: {address: "localhost:8004", zone: "zone-b", weight: 2}
+  c1: {address: "localhost:8005", zone: "zone-c"}
+  c2: "localhost:8006"
+`
+	var config distributed.NodeConfig
+	if err := yaml.Unmarshal([]byte(data), &config); err != nil {
+		t.Fatalf("❌ 解析配置失败: %v", err)
+	}
+	if len(config.NodeLocations) != 5 || config.NodeLocations["a2"] != (core.NodeLocation{Zone: "zone-a", Rack: "r2"}) {
+		t.Errorf("❌ 位置解析错误: %v", config.NodeLocations)
+	}
+	if config.NodeWeights["b2"] != 2 || config.ClusterNodes["c2"] != "localhost:8006" {
+		t.Errorf("❌ 其他字段解析错误: %v %v", config.NodeWeights, config.ClusterNodes)
+	}
+
+	node := distributed.NewDistributedNode(config)
+	if locations := node.GetNodeLocations(); len(locations) != 5 || locations["b1"].Zone != "zone-b" {
+		t.Errorf("❌ 节点位置未生效: %v", locations)
+	}
+
+	// c2 未标注位置，不与任何节点冲突；其余副本不应同区
+	for i := 0; i < 1000; i++ {
+		replicas := node.GetNodesForKey(fmt.Sprintf("key:%d", i), 3)
+		zones := make(map[string]bool)
+		for _, nodeID := range replicas {
+			zone := config.NodeLocations[nodeID].Zone
+			if zone != "" && zones[zone] {
+				t.Fatalf("❌ 副本落在同一可用区: %v", replicas)
+			}
+			zones[zone] = true
+		}
+	}
+	t.Log("✅ 可用区配置测试通过")
+}
+
+// TestZoneDistributionSnapshot 测试监控按可用区汇总分布
+func TestZoneDistributionSnapshot(t *testing.T) {
+	nodes := []string{"a1", "a2", "b1", "c1"}
+	dc := core.NewDistributedCacheWithVirtualNodes(nodes, 150)
+
+	monitor := monitoring.NewHashRingMonitor(dc)
+	if snapshot := monitor.CaptureSnapshot(); snapshot.ZoneDistribution != nil {
+		t.Error("❌ 未标注可用区时不应输出可用区分布")
+	}
+
+	dc.SetNodeLocation("a1", core.NodeLocation{Zone: "zone-a"})
+	dc.SetNodeLocation("a2", core.NodeLocation{Zone: "zone-a"})
+	dc.SetNodeLocation("b1", core.NodeLocation{Zone: "zone-b"})
+	for i := 0; i < 600; i++ {
+		dc.Set(fmt.Sprintf("key:%d", i), "value")
+	}
+
+	snapshot := monitor.CaptureSnapshot()
+	zones := snapshot.ZoneDistribution
+	t.Logf("📊 可用区分布: %+v", zones)
+	if len(zones) != 3 || len(zones["zone-a"].Nodes) != 2 || len(zones["unlabeled"].Nodes) != 1 {
+		t.Fatalf("❌ 可用区分组错误: %+v", zones)
+	}
+
+	share, vnodes, data := 0.0, 0, 0
+	for _, info := range zones {
+		share += info.RingShare
+		vnodes += info.VirtualNodes
+		data += info.DataCount
+	}
+	if math.Abs(share-1) > 1e-9 || vnodes != 600 || data != 600 {
+		t.Errorf("❌ 汇总数据错误: share=%f vnodes=%d data=%d", share, vnodes, data)
+	}
+	if zones["zone-a"].RingShare < 0.35 || zones["zone-a"].RingShare > 0.65 {
+		t.Errorf("❌ zone-a 有两个节点，哈希空间占比应接近一半: %.2f", zones["zone-a"].RingShare)
+	}
+	t.Log("✅ 可用区分布监控测试通过")
+}
+
+// TestJoinWithZone 测试新节点加入时携带可用区和机架
+func TestJoinWithZone(t *testing.T) {
+	cluster := startTestNodes(t, []string{"node1", "node2"}, nil)
+
+	body := `{"node_id":"node3","address":"127.0.0.1:1","zone":"zone-c","rack":"r9"}`
+	resp, err := http.Post(cluster.URL("node1")+"/internal/cluster/join", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("❌ 节点加入失败: %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(cluster.URL("node1") + "/admin/nodes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var nodes struct {
+		Locations map[string]core.NodeLocation `json:"locations"`
+	}
+	json.NewDecoder(resp.Body).Decode(&nodes)
+	resp.Body.Close()
+	if nodes.Locations["node3"] != (core.NodeLocation{Zone: "zone-c", Rack: "r9"}) {
+		t.Errorf("❌ 新节点位置未记录: %v", nodes.Locations)
+	}
+	t.Log("✅ 节点携带位置加入测试通过")
+}